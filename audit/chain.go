@@ -0,0 +1,378 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+)
+
+// entry is a single line in the audit trail file. Every entry includes the hash of the preceding
+// entry so that removed, reordered or edited entries break the chain, and the periodic checkpoint
+// entries are signed so that the chain cannot simply be recalculated after an edit.
+type entry struct {
+	Seq        uint64      `json:"seq"`
+	Timestamp  time.Time   `json:"timestamp"`
+	Record     *record     `json:"record,omitempty"`
+	Checkpoint *checkpoint `json:"checkpoint,omitempty"`
+	Prev       string      `json:"prev"`
+	Hash       string      `json:"hash"`
+	Signature  string      `json:"signature,omitempty"`
+}
+
+type record struct {
+	UID       string     `json:"uid"`
+	OID       schema.OID `json:"OID,omitempty"`
+	Component string     `json:"component"`
+	Operation string     `json:"operation"`
+	Details   Details    `json:"details"`
+}
+
+type checkpoint struct {
+	Key string `json:"key"`
+}
+
+// chain appends hash-chained entries to the audit trail file, rotating the file when it exceeds
+// the maximum size. Rotated files are renamed with a timestamp suffix (as for the uhppoted-lib
+// eventlog) and the chain continues across files.
+type chain struct {
+	file     string
+	key      ed25519.PrivateKey
+	keyID    string
+	records  uint32
+	maxSize  int64
+	f        *os.File
+	size     int64
+	seq      uint64
+	hash     string
+	unsigned uint32
+	sync.Mutex
+}
+
+const maxFileSize = 10 * 1024 * 1024
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+func newChain(file string, key ed25519.PrivateKey, records uint32) (*chain, error) {
+	c := chain{
+		file:    file,
+		key:     key,
+		keyID:   keyID(key.Public().(ed25519.PublicKey)),
+		records: records,
+		maxSize: maxFileSize,
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0744); err != nil {
+		return nil, err
+	}
+
+	if err := c.resume(); err != nil {
+		return nil, err
+	}
+
+	if err := c.open(); err != nil {
+		return nil, err
+	}
+
+	// ... sanity check and start of trail marker
+	if err := c.checkpoint(); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+func (c *chain) write(r AuditRecord) error {
+	c.Lock()
+	defer c.Unlock()
+
+	timestamp := r.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	e := entry{
+		Timestamp: timestamp.UTC(),
+		Record: &record{
+			UID:       r.UID,
+			OID:       r.OID,
+			Component: r.Component,
+			Operation: r.Operation,
+			Details:   r.Details,
+		},
+	}
+
+	if err := c.append(&e); err != nil {
+		return err
+	}
+
+	if c.records > 0 && c.unsigned >= c.records {
+		if err := c.checkpoint(); err != nil {
+			return err
+		}
+	}
+
+	if c.size >= c.maxSize {
+		return c.rotate()
+	}
+
+	return nil
+}
+
+// Writes a checkpoint if there are any entries that have not been signed.
+func (c *chain) tick() error {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.unsigned > 0 {
+		return c.checkpoint()
+	}
+
+	return nil
+}
+
+func (c *chain) Rotate() error {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.rotate()
+}
+
+// Signs the end of the current file and starts a new file. The current file is renamed with a
+// timestamp suffix unless it has already been renamed externally (e.g. by logrotate).
+func (c *chain) rotate() error {
+	if c.unsigned > 0 {
+		if err := c.checkpoint(); err != nil {
+			return err
+		}
+	}
+
+	info, err := c.f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if err := c.f.Close(); err != nil {
+		return err
+	}
+
+	if current, err := os.Stat(c.file); err == nil && os.SameFile(info, current) {
+		if err := os.Rename(c.file, backup(c.file)); err != nil {
+			return err
+		}
+	}
+
+	return c.open()
+}
+
+func (c *chain) checkpoint() error {
+	return c.append(&entry{
+		Timestamp:  time.Now().UTC(),
+		Checkpoint: &checkpoint{Key: c.keyID},
+	})
+}
+
+func (c *chain) append(e *entry) error {
+	e.Seq = c.seq + 1
+	e.Prev = c.hash
+
+	if hash, err := e.digest(); err != nil {
+		return err
+	} else {
+		e.Hash = hash
+	}
+
+	if e.Checkpoint != nil {
+		e.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(c.key, []byte(e.Hash)))
+	}
+
+	bytes, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	N, err := c.f.Write(append(bytes, '\n'))
+	c.size += int64(N)
+	if err != nil {
+		return err
+	}
+
+	c.seq = e.Seq
+	c.hash = e.Hash
+
+	if e.Checkpoint != nil {
+		c.unsigned = 0
+	} else {
+		c.unsigned++
+	}
+
+	return nil
+}
+
+func (c *chain) open() error {
+	f, err := os.OpenFile(c.file, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	c.f = f
+	c.size = info.Size()
+
+	// ... terminate a partial entry (e.g. after a crash) so that it doesn't corrupt the next entry
+	if c.size > 0 {
+		last := []byte{0}
+		if _, err := f.ReadAt(last, c.size-1); err != nil {
+			return err
+		} else if last[0] != '\n' {
+			if N, err := f.Write([]byte("\n")); err != nil {
+				return err
+			} else {
+				c.size += int64(N)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Continues the chain from the last entry in the audit trail files. A pre-existing audit trail
+// file in the old free text format is archived so that the new chain starts in a new file.
+func (c *chain) resume() error {
+	if info, err := os.Stat(c.file); err == nil && info.Size() > 0 {
+		if e, err := last(c.file); err != nil {
+			return err
+		} else if e == nil {
+			archived := backup(c.file)
+			if err := os.Rename(c.file, archived); err != nil {
+				return err
+			}
+
+			log.Printf("Archived audit trail file '%s' to '%s'\n", c.file, archived)
+		}
+	}
+
+	files, err := trailFiles(c.file)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if e, err := last(file); err == nil && e != nil && e.Seq > c.seq {
+			c.seq = e.Seq
+			c.hash = e.Hash
+		}
+	}
+
+	return nil
+}
+
+func (e entry) digest() (string, error) {
+	e.Hash = ""
+	e.Signature = ""
+
+	bytes, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(bytes)
+
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// Returns the last audit trail entry in a file, reading only the end of the file. Returns nil if
+// the file does not contain any hash-chained entries.
+func last(file string) (*entry, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	offset := max(0, info.Size()-65536)
+	buffer := make([]byte, info.Size()-offset)
+	if _, err := f.ReadAt(buffer, offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	lines := bytes.Split(buffer, []byte("\n"))
+	for i := len(lines) - 1; i >= 0; i-- {
+		var e entry
+		if err := json.Unmarshal(lines[i], &e); err == nil && e.Hash != "" {
+			return &e, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Returns the audit trail file and any rotated files in the same folder i.e. files named
+// <name>-<timestamp><ext> or <name><ext>.<suffix> (logrotate).
+func trailFiles(file string) ([]string, error) {
+	dir, base := filepath.Split(file)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext)
+
+	if dir == "" {
+		dir = "."
+	}
+
+	list, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	for _, f := range list {
+		name := f.Name()
+		if !f.Type().IsRegular() {
+			continue
+		}
+
+		if name == base || (strings.HasPrefix(name, prefix+"-") && strings.HasSuffix(name, ext)) || strings.HasPrefix(name, base+".") {
+			files = append(files, filepath.Join(dir, name))
+		}
+	}
+
+	return files, nil
+}
+
+func backup(file string) string {
+	dir, base := filepath.Split(file)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext)
+	timestamp := time.Now().UTC().Format(backupTimeFormat)
+	name := filepath.Join(dir, fmt.Sprintf("%s-%s%s", prefix, timestamp, ext))
+
+	// ... don't overwrite a file rotated in the same millisecond
+	for i := 1; ; i++ {
+		if _, err := os.Stat(name); os.IsNotExist(err) {
+			return name
+		}
+
+		name = filepath.Join(dir, fmt.Sprintf("%s-%s.%d%s", prefix, timestamp, i, ext))
+	}
+}
@@ -0,0 +1,293 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTrail(t *testing.T, records uint32, maxSize int64, N int) (string, ed25519.PublicKey, *chain) {
+	file := filepath.Join(t.TempDir(), "audit.log")
+	public, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	c, err := newChain(file, key, records)
+	if err != nil {
+		t.Fatalf("Error creating audit trail (%v)", err)
+	}
+
+	c.maxSize = maxSize
+
+	for i := 1; i <= N; i++ {
+		record := AuditRecord{
+			UID:       "admin",
+			OID:       "0.4.1",
+			Component: "card",
+			Operation: "update",
+			Details: Details{
+				ID:          "10058400",
+				Name:        "Hermione Granger",
+				Field:       "PIN",
+				Description: fmt.Sprintf("Updated PIN (%v)", i),
+			},
+		}
+
+		if err := c.write(record); err != nil {
+			t.Fatalf("Error writing audit record %v (%v)", i, err)
+		}
+	}
+
+	return file, public, c
+}
+
+func verify(t *testing.T, file string, key ed25519.PublicKey, checkpoints uint32) *Report {
+	report, err := Verify(file, key, checkpoints, 0)
+	if err != nil {
+		t.Fatalf("Error verifying audit trail (%v)", err)
+	}
+
+	return report
+}
+
+func rewrite(t *testing.T, file string, f func(lines []string) []string) {
+	bytes, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	lines := f(strings.Split(strings.TrimSpace(string(bytes)), "\n"))
+
+	if err := os.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatalf("%v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	file, key, _ := newTrail(t, 5, maxFileSize, 12)
+
+	report := verify(t, file, key, 5)
+	if !report.Valid() {
+		t.Errorf("Unexpected audit trail issues: %v", report.Issues)
+	}
+
+	if report.Records != 12 {
+		t.Errorf("Incorrect number of records - expected:%v, got:%v", 12, report.Records)
+	}
+
+	// ... 1 start checkpoint and 2 periodic checkpoints
+	if report.Checkpoints != 3 {
+		t.Errorf("Incorrect number of checkpoints - expected:%v, got:%v", 3, report.Checkpoints)
+	}
+
+	if report.First != 1 || report.Last != 15 || report.Signed != 13 {
+		t.Errorf("Incorrect audit trail range - expected:%v-%v (%v), got:%v-%v (%v)", 1, 15, 13, report.First, report.Last, report.Signed)
+	}
+}
+
+func TestVerifyAcrossRotatedFiles(t *testing.T) {
+	file, key, c := newTrail(t, 100, 2048, 20)
+
+	// ... continues chain after restart
+	if _, err := newChain(file, c.key, 100); err != nil {
+		t.Fatalf("Error reopening audit trail (%v)", err)
+	}
+
+	files, _ := trailFiles(file)
+	if len(files) < 3 {
+		t.Fatalf("Expected rotated audit trail files, got %v", files)
+	}
+
+	if report := verify(t, file, key, 100); !report.Valid() {
+		t.Errorf("Unexpected audit trail issues: %v", report.Issues)
+	} else if report.Records != 20 || len(report.Files) != len(files) {
+		t.Errorf("Incorrect audit trail - expected %v records in %v files, got %v records in %v files", 20, len(files), report.Records, len(report.Files))
+	}
+
+	// ... removed rotated file (the oldest file is only reported as a warning because rotated files
+	//     may be removed by e.g. logrotate)
+	var latest *entry
+	var removed string
+
+	for _, f := range files {
+		if e, _ := last(f); f != file && (latest == nil || e.Seq > latest.Seq) {
+			latest = e
+			removed = f
+		}
+	}
+
+	os.Remove(removed)
+
+	if report := verify(t, file, key, 100); report.Valid() {
+		t.Errorf("Expected issues verifying audit trail with removed file")
+	}
+}
+
+func TestVerifyWithTamperedEntries(t *testing.T) {
+	tests := map[string]func(lines []string) []string{
+		"edited": func(lines []string) []string {
+			lines[3] = strings.Replace(lines[3], "Updated PIN", "Cleared PIN", 1)
+			return lines
+		},
+
+		"removed": func(lines []string) []string {
+			return append(lines[:3], lines[4:]...)
+		},
+
+		"reordered": func(lines []string) []string {
+			lines[3], lines[4] = lines[4], lines[3]
+			return lines
+		},
+
+		"inserted": func(lines []string) []string {
+			return append(lines[:3], append([]string{lines[3]}, lines[3:]...)...)
+		},
+
+		// ... edited and rehashed
+		"rehashed": func(lines []string) []string {
+			var prev string
+			for i := range lines {
+				var e entry
+				json.Unmarshal([]byte(lines[i]), &e)
+				if i == 3 {
+					e.Record.Details.Description = "Cleared PIN"
+				}
+
+				if i > 0 {
+					e.Prev = prev
+				}
+
+				e.Hash, _ = e.digest()
+				prev = e.Hash
+
+				b, _ := json.Marshal(e)
+				lines[i] = string(b)
+			}
+
+			return lines
+		},
+	}
+
+	for name, f := range tests {
+		file, key, _ := newTrail(t, 5, maxFileSize, 8)
+
+		rewrite(t, file, f)
+
+		if report := verify(t, file, key, 5); report.Valid() {
+			t.Errorf("%v: expected audit trail issues", name)
+		}
+	}
+}
+
+func TestVerifyWithAnotherKey(t *testing.T) {
+	file, _, _ := newTrail(t, 5, maxFileSize, 8)
+	key, _, _ := ed25519.GenerateKey(rand.Reader)
+
+	if report := verify(t, file, key, 0); report.Valid() {
+		t.Errorf("Expected issues verifying audit trail with another key")
+	}
+}
+
+func TestVerifyTruncatedAuditTrail(t *testing.T) {
+	file, key, _ := newTrail(t, 5, maxFileSize, 8)
+
+	if report, err := Verify(file, key, 5, time.Hour); err != nil {
+		t.Fatalf("Error verifying audit trail (%v)", err)
+	} else if !report.Valid() || len(report.Warnings) != 1 {
+		t.Errorf("Expected warning for recent unsigned entries - got:%v %v", report.Issues, report.Warnings)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	if report, err := Verify(file, key, 5, 5*time.Millisecond); err != nil {
+		t.Fatalf("Error verifying audit trail (%v)", err)
+	} else if report.Valid() {
+		t.Errorf("Expected issue for unsigned entries older than the checkpoint interval")
+	}
+}
+
+func TestKeyFile(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected string
+		ok       bool
+	}{
+		{"", "/var/httpd/audit.key", true},
+		{"/etc/uhppoted/httpd/audit.key", "/etc/uhppoted/httpd/audit.key", true},
+		{"/var/httpd/audit/audit.key", "", false},
+		{"/var/httpd/audit/keys/audit.key", "", false},
+		{"/var/httpd/audit/../audit.key", "/var/httpd/audit/../audit.key", true},
+	}
+
+	for _, test := range tests {
+		if keyfile, err := KeyFile("/var/httpd/audit/audit.log", test.key); test.ok && err != nil {
+			t.Errorf("%v: unexpected error (%v)", test.key, err)
+		} else if !test.ok && err == nil {
+			t.Errorf("%v: expected error for key file in audit trail folder", test.key)
+		} else if keyfile != test.expected {
+			t.Errorf("%v: incorrect key file - expected:%v, got:%v", test.key, test.expected, keyfile)
+		}
+	}
+}
+
+func TestLoadPublicKey(t *testing.T) {
+	dir := t.TempDir()
+	keyfile := filepath.Join(dir, "audit.key")
+
+	key, err := LoadKey(keyfile)
+	if err != nil {
+		t.Fatalf("Error creating audit trail key (%v)", err)
+	}
+
+	if err := SavePublicKey(PublicKeyFile(keyfile), key); err != nil {
+		t.Fatalf("Error saving audit trail public key (%v)", err)
+	}
+
+	if public, err := LoadPublicKey(PublicKeyFile(keyfile)); err != nil {
+		t.Errorf("Error loading audit trail public key (%v)", err)
+	} else if !public.Equal(key.Public()) {
+		t.Errorf("Incorrect audit trail public key")
+	}
+
+	if _, err := LoadPublicKey(keyfile); err == nil {
+		t.Errorf("Expected error loading audit trail signing key as public key")
+	}
+}
+
+func TestLegacyAuditTrail(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "audit.log")
+	legacy := []byte("2026/10/01 12:34:56 AUDIT TRAIL START\n")
+
+	if err := os.WriteFile(file, legacy, 0644); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	if _, err := newChain(file, key, 5); err != nil {
+		t.Fatalf("Error creating audit trail (%v)", err)
+	}
+
+	if bytes, err := os.ReadFile(file); err != nil {
+		t.Fatalf("%v", err)
+	} else if bytes[0] != '{' {
+		t.Errorf("Expected legacy audit trail to be archived")
+	}
+
+	report := verify(t, file, key.Public().(ed25519.PublicKey), 5)
+	if !report.Valid() || len(report.Skipped) != 1 {
+		t.Fatalf("Incorrect legacy audit trail verification - expected:valid with 1 skipped file, got:%v with %v skipped", report.Issues, report.Skipped)
+	}
+
+	if archived, err := os.ReadFile(report.Skipped[0]); err != nil || !bytes.Equal(archived, legacy) {
+		t.Errorf("Incorrect archived legacy audit trail (%v)", err)
+	}
+}
@@ -0,0 +1,193 @@
+package audit
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// KeyFile returns the audit trail checkpoint signing key file, defaulting to audit.key in the
+// folder containing the audit trail folder. The signing key cannot be kept in the audit trail
+// folder, where anyone able to rewrite the audit trail could also re-sign it.
+func KeyFile(file, key string) (string, error) {
+	dir := filepath.Dir(file)
+
+	if key == "" {
+		return filepath.Join(filepath.Dir(dir), "audit.key"), nil
+	}
+
+	if inside(key, dir) {
+		return "", fmt.Errorf("audit trail key %v must be outside the audit trail folder %v", key, dir)
+	}
+
+	return key, nil
+}
+
+// PublicKeyFile returns the file for the public key used to verify audit trail checkpoints
+// i.e. the signing key file with a .pub extension.
+func PublicKeyFile(key string) string {
+	return strings.TrimSuffix(key, filepath.Ext(key)) + ".pub"
+}
+
+// LoadKey reads the Ed25519 checkpoint signing key from a PEM encoded PKCS8 file, creating a
+// new key if the file does not exist.
+func LoadKey(file string) (ed25519.PrivateKey, error) {
+	bytes, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return newKey(file)
+	} else if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(bytes)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("%v: invalid audit trail key", file)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%v: invalid audit trail key (%v)", file, err)
+	}
+
+	if k, ok := key.(ed25519.PrivateKey); !ok {
+		return nil, fmt.Errorf("%v: audit trail key is not an Ed25519 key", file)
+	} else {
+		return k, nil
+	}
+}
+
+// LoadPublicKey reads the Ed25519 key used to verify audit trail checkpoints from a PEM encoded
+// PKIX public key file. A private key is rejected so that verifying the audit trail does not
+// require access to the signing key.
+func LoadPublicKey(file string) (ed25519.PublicKey, error) {
+	bytes, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(bytes)
+	if block == nil {
+		return nil, fmt.Errorf("%v: invalid audit trail key", file)
+	} else if block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("%v: invalid audit trail public key type (%v)", file, block.Type)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%v: invalid audit trail key (%v)", file, err)
+	}
+
+	if k, ok := key.(ed25519.PublicKey); !ok {
+		return nil, fmt.Errorf("%v: audit trail key is not an Ed25519 key", file)
+	} else {
+		return k, nil
+	}
+}
+
+// SavePublicKey writes the public key for the checkpoint signing key to a PEM encoded PKIX
+// file, if the file does not already exist.
+func SavePublicKey(file string, key ed25519.PrivateKey) error {
+	if _, err := os.Stat(file); err == nil || !os.IsNotExist(err) {
+		return err
+	}
+
+	bytes, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: bytes}), 0644); err != nil {
+		return err
+	}
+
+	log.Printf("Created audit trail public key '%s'\n", file)
+
+	return nil
+}
+
+func newKey(file string) (ed25519.PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	bytes, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	if err := pem.Encode(f, &pem.Block{Type: "PRIVATE KEY", Bytes: bytes}); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Created audit trail signing key '%s'\n", file)
+
+	return key, nil
+}
+
+// Moves a signing key to a new key file, unless the new key file already exists.
+func moveKey(from, to string) error {
+	if _, err := os.Stat(from); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(to); err == nil || !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.Rename(from, to); err != nil {
+		return err
+	}
+
+	log.Printf("Moved audit trail signing key '%s' to '%s'\n", from, to)
+
+	return nil
+}
+
+// Returns true if the file is in the folder (or a subfolder of the folder).
+func inside(file, dir string) bool {
+	f, err := filepath.Abs(file)
+	if err != nil {
+		return false
+	}
+
+	d, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+
+	if rel, err := filepath.Rel(d, f); err != nil {
+		return false
+	} else {
+		return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+	}
+}
+
+// keyID identifies the key used to sign a checkpoint so that a checkpoint signed with a different
+// key can be reported as such rather than as an invalid signature.
+func keyID(key ed25519.PublicKey) string {
+	hash := sha256.Sum256(key)
+
+	return hex.EncodeToString(hash[:8])
+}
@@ -2,16 +2,15 @@ package audit
 
 import (
 	"encoding/json"
-	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
-	"github.com/uhppoted/uhppoted-lib/eventlog"
 )
 
 type AuditTrail interface {
@@ -19,7 +18,16 @@ type AuditTrail interface {
 }
 
 type trail struct {
-	logger *log.Logger
+	chain *chain
+}
+
+// Config holds the audit trail checkpoint settings. Key is the Ed25519 checkpoint signing key file
+// (created if it does not exist), Checkpoints is the maximum number of records between checkpoints
+// and Interval is the time after which any unsigned records are signed with a checkpoint.
+type Config struct {
+	Key         string
+	Checkpoints uint32
+	Interval    time.Duration
 }
 
 type Details struct {
@@ -48,12 +56,36 @@ func MakeTrail() AuditTrail {
 	return &auditTrail
 }
 
-func SetAuditFile(file string) error {
+func SetAuditFile(file string, config Config) error {
 	guard.Lock()
 	defer guard.Unlock()
 
-	events := eventlog.Ticker{Filename: file, MaxSize: 10}
-	logger := log.New(&events, "", log.Ldate|log.Ltime|log.LUTC)
+	keyfile, err := KeyFile(file, config.Key)
+	if err != nil {
+		return err
+	}
+
+	// ... move a signing key created in the audit trail folder by an earlier version
+	if config.Key == "" {
+		if err := moveKey(filepath.Join(filepath.Dir(file), "audit.key"), keyfile); err != nil {
+			return err
+		}
+	}
+
+	key, err := LoadKey(keyfile)
+	if err != nil {
+		return err
+	}
+
+	if err := SavePublicKey(PublicKeyFile(keyfile), key); err != nil {
+		return err
+	}
+
+	c, err := newChain(file, key, config.Checkpoints)
+	if err != nil {
+		return err
+	}
+
 	rotate := make(chan os.Signal, 1)
 
 	signal.Notify(rotate, syscall.SIGHUP)
@@ -62,30 +94,72 @@ func SetAuditFile(file string) error {
 		for {
 			<-rotate
 			log.Printf("Rotating audit trail file '%s'\n", file)
-			events.Rotate()
+			if err := c.Rotate(); err != nil {
+				log.Printf("ERROR rotating audit trail file '%s' (%v)\n", file, err)
+			}
 		}
 	}()
 
-	auditTrail.logger = logger
+	if config.Interval > 0 {
+		go func() {
+			for range time.Tick(config.Interval) {
+				if err := c.tick(); err != nil {
+					log.Printf("ERROR writing audit trail checkpoint (%v)\n", err)
+				}
+			}
+		}()
+	}
+
+	auditTrail.chain = c
 
-	// Basic sanity check because log.Printf(...) does not return an error if the logfile
-	// is not writeable
-	return logger.Output(2, "AUDIT TRAIL START")
+	return nil
 }
 
 func (t *trail) Write(records ...AuditRecord) {
 	for _, record := range records {
-		var logmsg string
+		if t.chain != nil {
+			if err := t.chain.write(record); err != nil {
+				log.Printf("ERROR writing audit trail record (%v)\n", err)
+			}
+
+			continue
+		}
+
 		if info, err := json.Marshal(record.Details); err == nil {
-			logmsg = fmt.Sprintf("%-10v %-10v %-10v %s", record.UID, record.Component, record.Operation, info)
+			log.Printf("%-10v %-10v %-10v %s", record.UID, record.Component, record.Operation, info)
 		} else {
-			logmsg = fmt.Sprintf("%-10v %-10v %-10v %v", record.UID, record.Component, record.Operation, record.Details)
+			log.Printf("%-10v %-10v %-10v %v", record.UID, record.Component, record.Operation, record.Details)
 		}
+	}
+}
 
-		if t.logger != nil {
-			t.logger.Printf("%s", logmsg)
-		} else {
-			log.Printf("%s", logmsg)
+// Snapshot returns the contents of the audit trail file and any rotated audit trail files, keyed
+// by file name. The active audit trail is locked while the files are read so that the snapshot
+// ends on a complete entry.
+func Snapshot(file string) (map[string][]byte, error) {
+	guard.Lock()
+	c := auditTrail.chain
+	guard.Unlock()
+
+	if c != nil && c.file == file {
+		c.Lock()
+		defer c.Unlock()
+	}
+
+	files, err := trailFiles(file)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	snapshot := map[string][]byte{}
+	for _, f := range files {
+		bytes, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
 		}
+
+		snapshot[filepath.Base(f)] = bytes
 	}
+
+	return snapshot, nil
 }
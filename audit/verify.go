@@ -0,0 +1,229 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// Report summarises the result of verifying the audit trail. Issues are entries that have been
+// edited, removed or reordered (or are otherwise invalid), warnings are conditions that cannot be
+// verified e.g. recent entries after the last signed checkpoint.
+type Report struct {
+	Files       []string
+	Skipped     []string
+	Records     uint64
+	Checkpoints uint64
+	First       uint64
+	Last        uint64
+	Signed      uint64
+	Issues      []Issue
+	Warnings    []string
+}
+
+type Issue struct {
+	File    string
+	Line    int
+	Seq     uint64
+	Message string
+}
+
+type item struct {
+	entry
+	file  string
+	line  int
+	valid bool
+}
+
+func (r Report) Valid() bool {
+	return len(r.Issues) == 0
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%v:%v  %v", filepath.Base(i.File), i.Line, i.Message)
+}
+
+// Verify checks the hash chain and checkpoint signatures of the audit trail file and any rotated
+// audit trail files. Files that do not contain hash-chained entries (e.g. audit trail files written
+// by earlier versions) are skipped. If checkpoints is not zero, a run of more than 'checkpoints'
+// records without a signed checkpoint is reported as an issue. If interval is not zero, entries
+// after the last signed checkpoint that are older than the checkpoint interval are reported as
+// an issue, since the checkpoint that should have signed them has been removed i.e. the audit
+// trail has been truncated.
+func Verify(file string, key ed25519.PublicKey, checkpoints uint32, interval time.Duration) (*Report, error) {
+	report := Report{
+		Files:    []string{},
+		Skipped:  []string{},
+		Issues:   []Issue{},
+		Warnings: []string{},
+	}
+
+	files, err := trailFiles(file)
+	if err != nil {
+		return nil, err
+	}
+
+	chains := [][]item{}
+	for _, f := range files {
+		if info, err := os.Stat(f); err != nil {
+			return nil, err
+		} else if info.Size() == 0 {
+			continue
+		}
+
+		if items, err := report.parse(f, key); err != nil {
+			return nil, err
+		} else if len(items) == 0 {
+			report.Skipped = append(report.Skipped, f)
+		} else {
+			report.Files = append(report.Files, f)
+			chains = append(chains, items)
+		}
+	}
+
+	slices.SortFunc(chains, func(p, q []item) int {
+		return cmp.Compare(p[0].Seq, q[0].Seq)
+	})
+
+	var previous *item
+	var unsigned uint32
+	var first *item
+
+	for _, list := range chains {
+		for _, v := range list {
+			switch {
+			case previous == nil:
+				report.First = v.Seq
+				if v.Seq != 1 || v.Prev != "" {
+					report.Warnings = append(report.Warnings, fmt.Sprintf("audit trail starts at entry %v - earlier entries are not available", v.Seq))
+				}
+
+			case v.Seq == previous.Seq:
+				report.issue(v, "duplicate entry %v", v.Seq)
+				continue
+
+			case v.Seq < previous.Seq:
+				report.issue(v, "entry %v is out of sequence (follows entry %v)", v.Seq, previous.Seq)
+				continue
+
+			case v.Seq > previous.Seq+1:
+				report.issue(v, "entries %v to %v are missing", previous.Seq+1, v.Seq-1)
+
+			case v.Prev != previous.Hash:
+				report.issue(v, "entry %v does not chain to entry %v", v.Seq, previous.Seq)
+			}
+
+			if v.Checkpoint != nil && v.valid {
+				report.Checkpoints++
+				report.Signed = v.Seq
+				unsigned = 0
+				first = nil
+			} else if v.Record != nil {
+				report.Records++
+				unsigned++
+				if first == nil {
+					first = &v
+				}
+
+				if checkpoints > 0 && unsigned == checkpoints+1 {
+					report.issue(v, "more than %v entries without a signed checkpoint", checkpoints)
+				}
+			}
+
+			report.Last = v.Seq
+			previous = &v
+		}
+	}
+
+	if previous != nil && report.Signed < previous.Seq {
+		if first != nil && interval > 0 && time.Since(first.Timestamp) > interval {
+			report.issue(*previous, "entries %v to %v follow the last signed checkpoint and were not signed within %v - the audit trail may have been truncated", report.Signed+1, previous.Seq, interval)
+		} else {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("entries %v to %v follow the last signed checkpoint", report.Signed+1, previous.Seq))
+		}
+	}
+
+	return &report, nil
+}
+
+// Parses the audit trail entries in a file, verifying the entry hashes and checkpoint signatures.
+// Returns an empty list if the file does not contain any hash-chained entries.
+func (r *Report) parse(file string, key ed25519.PublicKey) ([]item, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	items := []item{}
+	invalid := []item{}
+	scanner := bufio.NewScanner(f)
+	line := 0
+
+	scanner.Buffer(make([]byte, 65536), 1024*1024)
+
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		v := item{file: file, line: line, valid: true}
+		decoder := json.NewDecoder(bytes.NewReader(text))
+		decoder.DisallowUnknownFields()
+
+		if err := decoder.Decode(&v.entry); err != nil || v.Hash == "" {
+			invalid = append(invalid, v)
+			continue
+		}
+
+		if (v.Record == nil) == (v.Checkpoint == nil) {
+			r.issue(v, "invalid entry %v", v.Seq)
+			v.valid = false
+		} else if hash, err := v.digest(); err != nil || hash != v.Hash {
+			r.issue(v, "entry %v has been modified", v.Seq)
+			v.valid = false
+		} else if v.Checkpoint != nil {
+			if v.Checkpoint.Key != keyID(key) {
+				r.issue(v, "checkpoint %v is signed with another key (%v)", v.Seq, v.Checkpoint.Key)
+				v.valid = false
+			} else if signature, err := base64.StdEncoding.DecodeString(v.Signature); err != nil || !ed25519.Verify(key, []byte(v.Hash), signature) {
+				r.issue(v, "checkpoint %v has an invalid signature", v.Seq)
+				v.valid = false
+			}
+		}
+
+		items = append(items, v)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%v: %v", file, err)
+	}
+
+	if len(items) > 0 {
+		for _, v := range invalid {
+			r.issue(v, "invalid entry")
+		}
+	}
+
+	return items, nil
+}
+
+func (r *Report) issue(v item, format string, args ...any) {
+	r.Issues = append(r.Issues, Issue{
+		File:    v.file,
+		Line:    v.line,
+		Seq:     v.Seq,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
@@ -1,6 +1,8 @@
 package auth
 
-import ()
+import (
+	"context"
+)
 
 type TokenType int
 
@@ -20,23 +22,44 @@ const (
 	Events
 	Logs
 	Users
+	TimeProfiles
+	Tasks
 )
 
 func (r RuleSet) String() string {
-	return [...]string{"interfaces", "controllers", "doors", "cards", "groups", "events", "logs", "users"}[r]
+	return [...]string{"interfaces", "controllers", "doors", "cards", "groups", "events", "logs", "users", "time-profiles", "tasks"}[r]
 }
 
 type IAuthenticate interface {
 	Preauthenticate() (string, error)
 	Authenticate(uid, pwd string) (string, error)
+	Credentials(uid, pwd string) (string, error)
 	Validate(uid, pwd string) error
 	Verify(tokenType TokenType, token string) error
-	Authenticated(token string) (string, string, string, error)
+	Authenticated(token string, client Client) (string, string, string, error)
+	AuthenticateToken(token string) (string, string, []string, error)
+	NewSession(uid, role string) (string, error)
 	Invalidate(tokenType TokenType, token string) error
 	Options(uid, role string) Options
 	AdminRole() string
 }
 
+// Client identifies the remote client of an authenticated request (for display in the list of
+// active sessions).
+type Client struct {
+	Address   string
+	UserAgent string
+}
+
+// ISSO is implemented by authentication providers that delegate login to an external identity
+// provider (e.g. OpenID Connect). Login returns the identity provider URL and the opaque state
+// for an authorization request and Callback completes the login, returning a session token.
+type ISSO interface {
+	IAuthenticate
+	Login() (string, string, error)
+	Callback(ctx context.Context, state, code string) (string, error)
+}
+
 type IUser interface {
 	Password() ([]byte, string)
 	OTPKey() string
@@ -57,6 +80,7 @@ type OpAuth interface {
 	CanUpdate(o Operant, field string, value any, rulesets ...RuleSet) error
 	CanDelete(o Operant, rulesets ...RuleSet) error
 	CanCache(o Operant, field string, cache string, rulesets ...RuleSet) error
+	CanAction(o Operant, action string, rulesets ...RuleSet) error
 }
 
 type Options struct {
@@ -82,6 +106,31 @@ func NewAuthorizator(uid, role string) *Authorizator {
 	}
 }
 
+// Returns an authorizator for operations initiated by the system itself (e.g. scheduled tasks)
+// rather than by a logged in user. The operations are recorded in the audit trail against 'uid'
+// and are not subject to the authorisation rules.
+func NewSystemAuthorizator(uid string) *Authorizator {
+	return &Authorizator{
+		uid:    uid,
+		role:   "",
+		OpAuth: &unrestricted{},
+	}
+}
+
+// Returns true if the authorised user has the administrator role. Operations initiated by the
+// system itself are treated as administrator operations.
+func IsAdmin(a *Authorizator) bool {
+	if a != nil {
+		if _, ok := a.OpAuth.(*unrestricted); ok {
+			return true
+		}
+
+		return a.role == grules.roles.admin
+	}
+
+	return false
+}
+
 func UID(a *Authorizator) string {
 	if a != nil {
 		return a.uid
@@ -89,3 +138,30 @@ func UID(a *Authorizator) string {
 
 	return ""
 }
+
+type unrestricted struct {
+}
+
+func (u *unrestricted) CanView(o Operant, field string, value any, rulesets ...RuleSet) error {
+	return nil
+}
+
+func (u *unrestricted) CanAdd(o Operant, rulesets ...RuleSet) error {
+	return nil
+}
+
+func (u *unrestricted) CanUpdate(o Operant, field string, value any, rulesets ...RuleSet) error {
+	return nil
+}
+
+func (u *unrestricted) CanDelete(o Operant, rulesets ...RuleSet) error {
+	return nil
+}
+
+func (u *unrestricted) CanCache(o Operant, field string, cache string, rulesets ...RuleSet) error {
+	return nil
+}
+
+func (u *unrestricted) CanAction(o Operant, action string, rulesets ...RuleSet) error {
+	return nil
+}
@@ -69,14 +69,16 @@ func Init(rules map[RuleSet]string, adminRole string) error {
 		tag  string
 		file string
 	}{
-		Interfaces:  {"interfaces", "grules/interfaces.grl"},
-		Controllers: {"controllers", "grules/controllers.grl"},
-		Doors:       {"doors", "grules/doors.grl"},
-		Cards:       {"cards", "grules/cards.grl"},
-		Groups:      {"groups", "grules/groups.grl"},
-		Events:      {"events", "grules/events.grl"},
-		Logs:        {"logs", "grules/logs.grl"},
-		Users:       {"users", "grules/users.grl"},
+		Interfaces:   {"interfaces", "grules/interfaces.grl"},
+		Controllers:  {"controllers", "grules/controllers.grl"},
+		Doors:        {"doors", "grules/doors.grl"},
+		Cards:        {"cards", "grules/cards.grl"},
+		Groups:       {"groups", "grules/groups.grl"},
+		Events:       {"events", "grules/events.grl"},
+		Logs:         {"logs", "grules/logs.grl"},
+		Users:        {"users", "grules/users.grl"},
+		TimeProfiles: {"time-profiles", "grules/time-profiles.grl"},
+		Tasks:        {"tasks", "grules/tasks.grl"},
 	}
 
 	for k, v := range resources {
@@ -149,6 +151,14 @@ func CanCache[T TAuthable](a OpAuth, u T, field string, cache string, rulesets .
 	return nil
 }
 
+func CanAction[T TAuthable](a OpAuth, u T, action string, rulesets ...RuleSet) error {
+	if !isNil(a) {
+		return a.CanAction(u, action, rulesets...)
+	}
+
+	return nil
+}
+
 func isNil(v any) bool {
 	if v == nil {
 		return true
@@ -319,6 +329,36 @@ func (a *authorizator) CanCache(operant Operant, field string, cache string, rul
 	return nil
 }
 
+// Authorises an operation other than view/add/update/delete on an object e.g. 'open::door'.
+func (a *authorizator) CanAction(operant Operant, action string, rulesets ...RuleSet) error {
+	if a != nil && operant != nil {
+		tag, object := operant.AsRuleEntity()
+		op := fmt.Sprintf("%v::%v", action, tag)
+
+		m := map[string]any{
+			"OBJECT": object,
+			"FIELD":  "",
+		}
+
+		rs := result{
+			Allow:  false,
+			Refuse: false,
+		}
+
+		for _, r := range rulesets {
+			if err := a.eval(r, op, &rs, m); err != nil {
+				return ErrUnauthorised
+			}
+		}
+
+		if rs.Allow && !rs.Refuse {
+			return nil
+		}
+	}
+
+	return ErrUnauthorised
+}
+
 func (a *authorizator) eval(ruleset RuleSet, op string, r *result, m map[string]any) error {
 	context := ast.NewDataContext()
 	tag := fmt.Sprintf("%v", ruleset)
@@ -343,6 +383,10 @@ func (a *authorizator) eval(ruleset RuleSet, op string, r *result, m map[string]
 		return err
 	}
 
+	if err := context.Add("SCOPE", scope(a.uid)); err != nil {
+		return err
+	}
+
 	for k, v := range m {
 		if err := context.Add(k, v); err != nil {
 			return err
@@ -71,6 +71,9 @@ func cacheCanView(a *authorizator, operant Operant, field string, f func() (resu
 
 	// ... get cache for uid:role
 	cacheId := strings.Join([]string{a.uid, a.role}, ":")
+	if s := scope(a.uid); s != nil {
+		cacheId = strings.Join([]string{cacheId, s.key()}, ":")
+	}
 	v, _ := _cache.LoadOrStore(cacheId, &cache{
 		canView:  map[string]result{},
 		canCache: sync.Map{},
@@ -2,7 +2,6 @@ package local
 
 import (
 	"crypto/rand"
-	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -16,6 +15,7 @@ import (
 
 	"github.com/uhppoted/uhppoted-httpd/auth"
 	"github.com/uhppoted/uhppoted-httpd/auth/otp"
+	"github.com/uhppoted/uhppoted-httpd/auth/passwords"
 	"github.com/uhppoted/uhppoted-httpd/system"
 )
 
@@ -24,8 +24,8 @@ var constants = struct {
 	KEY_LENGTH  int           // bytes
 	SALT_LENGTH int           // bytes
 	REGENERATE  time.Duration // Interval at which the internal secret keys are regenerated
-	IDLETIME    time.Duration // Interval after which an untouched session is marked 'idle'
-	SWEEP       time.Duration // Interval at which the session list is 'swept'
+	IDLETIME    time.Duration // Interval after which an untouched login or session is marked 'idle'
+	SWEEP       time.Duration // Interval at which the login and session lists are 'swept'
 
 }{
 	KEYS:        2,                // 2 historical session secrets
@@ -36,30 +36,31 @@ var constants = struct {
 	SWEEP:       60 * time.Second, // Sweep session and login caches every minute
 }
 
-const MaxFailed uint32 = 5
-
+// Local authenticates users against the system users list. Logins are short-lived JWTs signed
+// with the (regularly regenerated) internal secret keys while sessions are opaque tokens for the
+// persistent sessions held by the system.
 type Local struct {
 	keys          [][]byte
 	loginExpiry   time.Duration
 	sessionExpiry time.Duration
+	idleTime      time.Duration
+	maxSessions   uint32
 	allowOTPLogin bool
 	adminRole     string
 
-	logins   sessions
-	sessions sessions
+	logins logins
 
 	sync.RWMutex
 }
 
-type sessions struct {
+type logins struct {
 	list map[uuid.UUID]time.Time
 	sync.Mutex
 }
 
 type claims struct {
 	jwt.StandardClaims
-	Login   *login   `json:"login,omitempty"`
-	Session *session `json:"session,omitempty"`
+	Login *login `json:"login,omitempty"`
 }
 
 type login struct {
@@ -67,24 +68,16 @@ type login struct {
 	Salt    []byte    `json:"login.salt,omitempty"`
 }
 
-type session struct {
-	LoggedInAs string    `json:"uid,omitempty"`
-	SessionId  uuid.UUID `json:"session.id,omitempty"`
-	Role       string    `json:"session.role,omitempty"`
-}
-
 func NewAuthProvider(file string, loginExpiry, sessionExpiry string, allowOTPLogin bool, adminRole string) (*Local, error) {
 	provider := Local{
 		keys: make([][]byte, constants.KEYS),
-		logins: sessions{
-			list: map[uuid.UUID]time.Time{},
-		},
-		sessions: sessions{
+		logins: logins{
 			list: map[uuid.UUID]time.Time{},
 		},
 
 		sessionExpiry: 60 * time.Minute,
 		loginExpiry:   1 * time.Minute,
+		idleTime:      constants.IDLETIME,
 		allowOTPLogin: allowOTPLogin,
 		adminRole:     adminRole,
 	}
@@ -128,6 +121,24 @@ func NewAuthProvider(file string, loginExpiry, sessionExpiry string, allowOTPLog
 	return &provider, nil
 }
 
+// Sets the maximum number of concurrent sessions for a user (0 for unlimited), the absolute
+// session lifetime and the idle time after which an unused session expires. A zero lifetime or
+// idle time retains the existing value.
+func (p *Local) SetSessionLimits(maxSessions uint32, lifetime, idleTime time.Duration) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.maxSessions = maxSessions
+
+	if lifetime > 0 {
+		p.sessionExpiry = lifetime
+	}
+
+	if idleTime > 0 {
+		p.idleTime = idleTime
+	}
+}
+
 func (p *Local) Preauthenticate() (string, error) {
 	p.RLock()
 	defer p.RUnlock()
@@ -172,92 +183,74 @@ func (p *Local) Preauthenticate() (string, error) {
 		return "", err
 	}
 
-	p.touched(auth.Login, loginId)
+	p.logins.touched(loginId)
 
 	return token.String(), nil
 }
 
-func (p *Local) Authenticate(uid, pwd string) (token string, err error) {
+func (p *Local) Authenticate(uid, pwd string) (string, error) {
+	role, err := p.Credentials(uid, pwd)
+	if err != nil {
+		return "", err
+	}
+
+	return p.NewSession(uid, role)
+}
+
+// Verifies the user ID and password (or OTP) for a login without issuing a session token,
+// returning the user role. The login is audited and counts towards locking the account.
+func (p *Local) Credentials(uid, pwd string) (role string, err error) {
 	p.RLock()
 	defer p.RUnlock()
 
 	// .. verify uid + pwd
-	secret := p.keys[0]
-	expiry := p.sessionExpiry
-
 	var salt []byte
 	var password string
-	var role string
-	var locked bool
 
 	u, ok := system.GetUser(uid)
 	if !ok || u == nil || u.IsDeleted() {
 		err = fmt.Errorf("invalid login credentials")
+		system.UserLogin(uid, "", err)
 		return
 	}
 
-	if locked {
-		err = fmt.Errorf("%v account locked", uid)
-		return
-	}
-
-	// ... ok'ish
-	salt, password = u.Password()
 	role = u.Role()
 
 	defer func() {
 		system.UserLogin(uid, role, err)
 	}()
 
-	h := sha256.New()
-	h.Write(salt)
-	h.Write([]byte(pwd))
-	hash := fmt.Sprintf("%0x", h.Sum(nil))
-
-	if hash != password && (!p.allowOTPLogin || !otp.Verify(uid, role, pwd)) {
-		err = fmt.Errorf("invalid login credentials")
+	if u.Locked() {
+		err = fmt.Errorf("%v account locked", uid)
 		return
 	}
 
-	var signer jwt.Signer
-	var UUID uuid.UUID
-	var sessionId uuid.UUID
-	var t *jwt.Token
-
-	if signer, err = jwt.NewSignerHS(jwt.HS256, secret); err != nil {
-		return
-	}
+	// ... ok'ish
+	salt, password = u.Password()
 
-	if UUID, err = uuid.NewUUID(); err != nil {
-		return
-	}
+	verified, rehash := passwords.Verify(pwd, salt, password)
 
-	if sessionId, err = uuid.NewUUID(); err != nil {
+	if !verified && (!p.allowOTPLogin || !otp.Verify(uid, role, pwd)) {
+		err = fmt.Errorf("invalid login credentials")
 		return
 	}
 
-	claims := &claims{
-		StandardClaims: jwt.StandardClaims{
-			ID:        UUID.String(),
-			Audience:  []string{"admin"},
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(expiry))),
-		},
-		Session: &session{
-			LoggedInAs: uid,
-			SessionId:  sessionId,
-			Role:       role,
-		},
+	if verified && rehash {
+		if e := system.RehashPassword(uid, password, pwd); e != nil {
+			log.Printf("%-5v %v: error rehashing password (%v)", "WARN", uid, e)
+		}
 	}
 
-	if t, err = jwt.NewBuilder(signer).Build(claims); err != nil {
-		return
-	} else {
-		token = t.String()
-	}
+	return
+}
 
-	p.touched(auth.Session, sessionId)
+// Issues a session token for a user that has been authenticated by an external identity
+// provider (e.g. OIDC) or with a passkey.
+func (p *Local) NewSession(uid, role string) (string, error) {
+	p.RLock()
+	defer p.RUnlock()
 
-	return
+	return p.session(uid, role)
 }
 
 func (p *Local) Validate(uid, pwd string) error {
@@ -270,12 +263,7 @@ func (p *Local) Validate(uid, pwd string) error {
 		salt, password = u.Password()
 	}
 
-	h := sha256.New()
-	h.Write(salt)
-	h.Write([]byte(pwd))
-
-	hash := fmt.Sprintf("%0x", h.Sum(nil))
-	if hash != password {
+	if ok, _ := passwords.Verify(pwd, salt, password); !ok {
 		return fmt.Errorf("invalid user ID or password")
 	}
 
@@ -283,6 +271,11 @@ func (p *Local) Validate(uid, pwd string) error {
 }
 
 func (p *Local) Invalidate(tokenType auth.TokenType, cookie string) error {
+	if tokenType == auth.Session {
+		system.EndSession(cookie)
+		return nil
+	}
+
 	token, _, err := p.getToken(cookie)
 	if err != nil {
 		return err
@@ -293,18 +286,19 @@ func (p *Local) Invalidate(tokenType auth.TokenType, cookie string) error {
 		return err
 	}
 
-	switch tokenType {
-	case auth.Login:
+	if claims.Login != nil {
 		p.logins.delete(claims.Login.LoginId)
-
-	case auth.Session:
-		p.sessions.delete(claims.Session.SessionId)
 	}
 
 	return nil
 }
 
 func (p *Local) Verify(tokenType auth.TokenType, cookie string) error {
+	if tokenType == auth.Session {
+		_, err := system.GetSession(cookie, p.idle())
+		return err
+	}
+
 	token, _, err := p.getToken(cookie)
 	if err != nil {
 		return err
@@ -319,91 +313,43 @@ func (p *Local) Verify(tokenType auth.TokenType, cookie string) error {
 		return fmt.Errorf("JWT token expired")
 	}
 
-	switch tokenType {
-	case auth.Login:
-		if !claims.IsForAudience("login") {
-			return fmt.Errorf("invalid audience in JWT claims")
-		} else if claims.Login == nil {
-			return fmt.Errorf("invalid login token")
-		} else if err := p.extant(auth.Login, claims.Login.LoginId); err != nil {
-			return err
-		} else {
-			return nil
-		}
-
-	case auth.Session:
-		if !claims.IsForAudience("admin") {
-			return fmt.Errorf("invalid audience in JWT claims")
-		} else if claims.Session == nil {
-			return fmt.Errorf("invalid session token")
-		} else if err := p.extant(auth.Session, claims.Session.SessionId); err != nil {
-			return err
-		} else {
-			return nil
-		}
+	if !claims.IsForAudience("login") {
+		return fmt.Errorf("invalid audience in JWT claims")
+	} else if claims.Login == nil {
+		return fmt.Errorf("invalid login token")
+	} else if err := p.logins.extant(claims.Login.LoginId); err != nil {
+		return err
 	}
 
 	return nil
 }
 
-func (p *Local) Authenticated(cookie string) (string, string, string, error) {
-	token, keyID, err := p.getToken(cookie)
+// Validates a session token against the persistent sessions and records the client address and
+// user agent against the session. The session is ended if the user has been deleted or locked.
+// Session tokens are never reissued so the returned token is always blank.
+func (p *Local) Authenticated(cookie string, client auth.Client) (string, string, string, error) {
+	uid, role, err := system.AuthenticateSession(cookie, client.Address, client.UserAgent, p.idle())
 	if err != nil {
 		return "", "", "", err
 	}
 
-	var claims claims
-	if err := json.Unmarshal(token.RawClaims(), &claims); err != nil {
-		return "", "", "", err
-	}
-
-	if !claims.IsForAudience("admin") {
-		return "", "", "", fmt.Errorf("invalid audience in JWT claims")
-	}
-
-	if !claims.IsValidAt(time.Now()) {
-		return "", "", "", fmt.Errorf("JWT token expired")
-	}
-
-	if claims.Session == nil {
-		return "", "", "", fmt.Errorf("invalid session token")
-	}
-
-	if err := p.extant(auth.Session, claims.Session.SessionId); err != nil {
-		return "", "", "", err
-	}
-
-	uid := claims.Session.LoggedInAs
-	role := claims.Session.Role
-	user, ok := system.GetUser(uid)
-
-	if !ok || user == nil || user.IsDeleted() {
-		p.sessions.delete(claims.Session.SessionId)
+	if user, ok := system.GetUser(uid); !ok || user == nil || user.IsDeleted() {
+		system.EndSession(cookie)
 
 		return "", "", "", fmt.Errorf("invalid user")
-	}
-
-	p.touched(auth.Session, claims.Session.SessionId)
+	} else if user.Locked() {
+		system.EndSession(cookie)
 
-	if keyID == 1 {
-		return claims.Session.LoggedInAs, claims.Session.Role, "", nil
-	}
-
-	p.RLock()
-	defer p.RUnlock()
-	secret := p.keys[0]
-
-	signer, err := jwt.NewSignerHS(jwt.HS256, secret)
-	if err != nil {
-		return "", "", "", err
+		return "", "", "", fmt.Errorf("%v account locked", uid)
 	}
 
-	token2, err := jwt.NewBuilder(signer).Build(claims)
-	if err != nil {
-		return "", "", "", err
-	}
+	return uid, role, "", nil
+}
 
-	return uid, role, token2.String(), nil
+// Validates an API bearer token issued to a user, returning the user UID, role and the
+// token scopes.
+func (p *Local) AuthenticateToken(token string) (string, string, []string, error) {
+	return system.AuthenticateToken(token)
 }
 
 func (p *Local) Options(uid, role string) auth.Options {
@@ -426,6 +372,18 @@ func (p *Local) AdminRole() string {
 	}
 }
 
+// NTS: expects the caller to hold the read lock
+func (p *Local) session(uid, role string) (string, error) {
+	return system.CreateSession(uid, role, p.sessionExpiry, p.maxSessions)
+}
+
+func (p *Local) idle() time.Duration {
+	p.RLock()
+	defer p.RUnlock()
+
+	return p.idleTime
+}
+
 func (p *Local) getToken(cookie string) (*jwt.Token, int, error) {
 	p.RLock()
 	defer p.RUnlock()
@@ -479,55 +437,26 @@ func (p *Local) regenerate() {
 	log.Printf("%-5v Regenerated session secret", "INFO")
 }
 
-func (p *Local) extant(tokenType auth.TokenType, id uuid.UUID) error {
-	switch tokenType {
-	case auth.Login:
-		return p.logins.extant(id)
-
-	case auth.Session:
-		return p.sessions.extant(id)
-	}
-
-	return nil
-}
-
-func (p *Local) touched(tt auth.TokenType, uuid uuid.UUID) {
-	switch tt {
-	case auth.Login:
-		p.logins.touched(uuid)
-	case auth.Session:
-		p.sessions.touched(uuid)
-	}
-}
-
 func (p *Local) sweep() {
-	caches := []struct {
-		cache  *sessions
-		format string
-	}{
-		{&p.logins, "%-5v Deleted idle login %v"},
-		{&p.sessions, "%-5v Deleted idle session %v"},
-	}
-
 	cutoff := time.Now().Add(-2 * constants.IDLETIME)
 
-	for _, c := range caches {
-		c.cache.Lock()
-
-		list := []uuid.UUID{}
-		for k, touched := range c.cache.list {
-			if touched.Before(cutoff) {
-				list = append(list, k)
-			}
-		}
+	p.logins.Lock()
 
-		for _, k := range list {
-			delete(c.cache.list, k)
-			log.Printf(c.format, "INFO", k)
+	list := []uuid.UUID{}
+	for k, touched := range p.logins.list {
+		if touched.Before(cutoff) {
+			list = append(list, k)
 		}
+	}
 
-		c.cache.Unlock()
+	for _, k := range list {
+		delete(p.logins.list, k)
+		log.Printf("%-5v Deleted idle login %v", "INFO", k)
 	}
+
+	p.logins.Unlock()
+
+	system.SweepSessions(p.idle())
 }
 
 func genKey() ([]byte, error) {
@@ -540,31 +469,31 @@ func genKey() ([]byte, error) {
 	return key, nil
 }
 
-func (ss *sessions) touched(uuid uuid.UUID) {
-	ss.Lock()
-	defer ss.Unlock()
+func (ll *logins) touched(uuid uuid.UUID) {
+	ll.Lock()
+	defer ll.Unlock()
 
-	ss.list[uuid] = time.Now()
+	ll.list[uuid] = time.Now()
 }
 
-func (ss *sessions) extant(uuid uuid.UUID) error {
+func (ll *logins) extant(uuid uuid.UUID) error {
 	cutoff := time.Now().Add(-constants.IDLETIME)
 
-	ss.Lock()
-	defer ss.Unlock()
+	ll.Lock()
+	defer ll.Unlock()
 
-	if touched, ok := ss.list[uuid]; !ok {
-		return fmt.Errorf("no extant session for ID '%v'", uuid)
+	if touched, ok := ll.list[uuid]; !ok {
+		return fmt.Errorf("no extant login for ID '%v'", uuid)
 	} else if touched.Before(cutoff) {
-		return fmt.Errorf("session '%v' expired", uuid)
+		return fmt.Errorf("login '%v' expired", uuid)
 	}
 
 	return nil
 }
 
-func (ss *sessions) delete(uuid uuid.UUID) {
-	ss.Lock()
-	defer ss.Unlock()
+func (ll *logins) delete(uuid uuid.UUID) {
+	ll.Lock()
+	defer ll.Unlock()
 
-	delete(ss.list, uuid)
+	delete(ll.list, uuid)
 }
@@ -0,0 +1,274 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	lib "github.com/go-ldap/ldap/v3"
+
+	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/auth/impl"
+	"github.com/uhppoted/uhppoted-httpd/log"
+	"github.com/uhppoted/uhppoted-httpd/system"
+)
+
+// Config is the LDAP directory configuration.
+type Config struct {
+	URL            string
+	StartTLS       bool
+	BindDN         string
+	BindPassword   string
+	BaseDN         string
+	UserFilter     string
+	NameAttribute  string
+	GroupAttribute string
+	AdminGroup     string
+	Roles          []auth.Role
+	Fallback       []string
+	Timeout        time.Duration
+}
+
+// Provider authenticates users with an LDAP (or Active Directory) search and bind, on top of the
+// local authentication provider which manages the session cookies and tokens.
+type Provider struct {
+	*local.Local
+	config Config
+	roles  []auth.Role
+
+	locked func(uid string) bool
+	link   func(uid, name, role string, fallback bool) error
+	login  func(uid, role string, err error)
+}
+
+var ErrUnavailable = errors.New("directory unavailable")
+
+func NewProvider(p *local.Local, config Config) (*Provider, error) {
+	if strings.TrimSpace(config.URL) == "" {
+		return nil, fmt.Errorf("missing LDAP URL")
+	}
+
+	if strings.TrimSpace(config.BaseDN) == "" {
+		return nil, fmt.Errorf("missing LDAP base DN")
+	}
+
+	if config.UserFilter == "" {
+		config.UserFilter = "(uid=%s)"
+	}
+
+	if strings.Count(config.UserFilter, "%s") != 1 {
+		return nil, fmt.Errorf("invalid LDAP user filter (%v)", config.UserFilter)
+	}
+
+	if config.NameAttribute == "" {
+		config.NameAttribute = "cn"
+	}
+
+	if config.GroupAttribute == "" {
+		config.GroupAttribute = "memberOf"
+	}
+
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+
+	// ... LDAP group names are case insensitive
+	roles := []auth.Role{}
+	if group := strings.TrimSpace(config.AdminGroup); group != "" {
+		roles = append(roles, auth.Role{Group: strings.ToLower(group), Role: p.AdminRole()})
+	}
+
+	for _, r := range config.Roles {
+		roles = append(roles, auth.Role{Group: strings.ToLower(r.Group), Role: r.Role})
+	}
+
+	if len(roles) == 0 {
+		return nil, fmt.Errorf("missing LDAP group role mappings")
+	}
+
+	return &Provider{
+		Local:  p,
+		config: config,
+		roles:  roles,
+		locked: func(uid string) bool {
+			u, ok := system.GetUser(uid)
+			return ok && u != nil && u.Locked()
+		},
+		link:  system.DirectoryLogin,
+		login: system.UserLogin,
+	}, nil
+}
+
+// Authenticates the user against the directory and issues a session token. Users listed in the
+// fallback configuration are authenticated against the local users if the directory is
+// unavailable.
+func (p *Provider) Authenticate(uid, pwd string) (string, error) {
+	role, err := p.Credentials(uid, pwd)
+	if err != nil {
+		return "", err
+	}
+
+	infof("%v logged in with role %v", uid, role)
+
+	return p.NewSession(uid, role)
+}
+
+// Authenticates the user against the directory (or the local users for a fallback user if the
+// directory is unavailable) without issuing a session token, returning the user role.
+func (p *Provider) Credentials(uid, pwd string) (string, error) {
+	if p.locked(uid) {
+		err := fmt.Errorf("%v account locked", uid)
+		p.login(uid, "", err)
+
+		return "", err
+	}
+
+	name, role, err := p.bind(uid, pwd)
+	if errors.Is(err, ErrUnavailable) && p.fallback(uid) {
+		warnf("%v (falling back to local login for %v)", err, uid)
+		return p.Local.Credentials(uid, pwd)
+	}
+
+	if err == nil {
+		err = p.link(uid, name, role, p.fallback(uid))
+	}
+
+	p.login(uid, role, err)
+
+	if err != nil {
+		return "", err
+	}
+
+	return role, nil
+}
+
+func (p *Provider) Validate(uid, pwd string) error {
+	_, _, err := p.bind(uid, pwd)
+	if errors.Is(err, ErrUnavailable) && p.fallback(uid) {
+		return p.Local.Validate(uid, pwd)
+	}
+
+	return err
+}
+
+func (p *Provider) fallback(uid string) bool {
+	return uid != "" && slices.Contains(p.config.Fallback, uid)
+}
+
+// Looks up the user entry with the service account (or anonymously), verifies the password with
+// a bind as the user and returns the user name and mapped role.
+func (p *Provider) bind(uid, pwd string) (string, string, error) {
+	// ... an empty password is an 'unauthenticated' bind which succeeds on most servers
+	if strings.TrimSpace(uid) == "" || pwd == "" {
+		return "", "", fmt.Errorf("invalid login credentials")
+	}
+
+	conn, err := p.dial()
+	if err != nil {
+		return "", "", err
+	}
+
+	defer conn.Close()
+
+	if p.config.BindDN != "" {
+		if err := conn.Bind(p.config.BindDN, p.config.BindPassword); err != nil {
+			return "", "", fmt.Errorf("%w (service account bind failed: %v)", ErrUnavailable, err)
+		}
+	}
+
+	rq := lib.NewSearchRequest(
+		p.config.BaseDN,
+		lib.ScopeWholeSubtree,
+		lib.NeverDerefAliases,
+		2,
+		int(p.config.Timeout.Seconds()),
+		false,
+		fmt.Sprintf(p.config.UserFilter, lib.EscapeFilter(uid)),
+		[]string{p.config.NameAttribute, p.config.GroupAttribute},
+		nil)
+
+	response, err := conn.Search(rq)
+	if err != nil && lib.IsErrorWithCode(err, lib.ErrorNetwork) {
+		return "", "", fmt.Errorf("%w (%v)", ErrUnavailable, err)
+	} else if err != nil {
+		return "", "", fmt.Errorf("directory search for %v failed (%v)", uid, err)
+	} else if len(response.Entries) != 1 {
+		return "", "", fmt.Errorf("invalid login credentials")
+	}
+
+	entry := response.Entries[0]
+
+	if err := conn.Bind(entry.DN, pwd); err != nil && lib.IsErrorWithCode(err, lib.ErrorNetwork) {
+		return "", "", fmt.Errorf("%w (%v)", ErrUnavailable, err)
+	} else if err != nil {
+		return "", "", fmt.Errorf("invalid login credentials")
+	}
+
+	groups := []string{}
+	for _, dn := range entry.GetAttributeValues(p.config.GroupAttribute) {
+		groups = append(groups, strings.ToLower(dn))
+
+		if cn := commonName(dn); cn != "" {
+			groups = append(groups, strings.ToLower(cn))
+		}
+	}
+
+	role, ok := auth.MapRole(p.roles, groups)
+	if !ok {
+		return "", "", fmt.Errorf("%v: no role mapped for directory groups", uid)
+	}
+
+	return entry.GetAttributeValue(p.config.NameAttribute), role, nil
+}
+
+func (p *Provider) dial() (*lib.Conn, error) {
+	dialer := net.Dialer{Timeout: p.config.Timeout}
+
+	conn, err := lib.DialURL(p.config.URL, lib.DialWithDialer(&dialer))
+	if err != nil {
+		return nil, fmt.Errorf("%w (%v)", ErrUnavailable, err)
+	}
+
+	conn.SetTimeout(p.config.Timeout)
+
+	if p.config.StartTLS {
+		host := ""
+		if u, err := url.Parse(p.config.URL); err == nil {
+			host = u.Hostname()
+		}
+
+		if err := conn.StartTLS(&tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("%w (StartTLS failed: %v)", ErrUnavailable, err)
+		}
+	}
+
+	return conn, nil
+}
+
+// Returns the value of the leading CN of a group DN e.g. 'uhppoted-admins' for
+// 'cn=uhppoted-admins,ou=groups,dc=example,dc=com'.
+func commonName(dn string) string {
+	if v, err := lib.ParseDN(dn); err == nil && len(v.RDNs) > 0 {
+		for _, a := range v.RDNs[0].Attributes {
+			if strings.EqualFold(a.Type, "cn") {
+				return a.Value
+			}
+		}
+	}
+
+	return ""
+}
+
+func infof(format string, args ...any) {
+	log.Infof(fmt.Sprintf("%-8v %v", "LDAP", format), args...)
+}
+
+func warnf(format string, args ...any) {
+	log.Warnf(fmt.Sprintf("%-8v %v", "LDAP", format), args...)
+}
@@ -0,0 +1,371 @@
+package ldap
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+
+	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/auth/impl"
+)
+
+type entry struct {
+	password   string
+	attributes map[string][]string
+}
+
+// directory is a minimal in-process LDAP server that implements simple binds and equality match
+// searches.
+type directory struct {
+	listener net.Listener
+	entries  map[string]entry
+}
+
+func newDirectory(t *testing.T) *directory {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error starting LDAP server (%v)", err)
+	}
+
+	d := directory{
+		listener: listener,
+		entries: map[string]entry{
+			"cn=service,dc=example,dc=com": {
+				password: "service-password",
+			},
+			"uid=moony,ou=people,dc=example,dc=com": {
+				password: "qwerty",
+				attributes: map[string][]string{
+					"uid":      {"moony"},
+					"cn":       {"Remus Lupin"},
+					"memberOf": {"cn=staff,ou=groups,dc=example,dc=com", "CN=Uhppoted-Admins,ou=groups,dc=example,dc=com"},
+				},
+			},
+			"uid=padfoot,ou=people,dc=example,dc=com": {
+				password: "uiop",
+				attributes: map[string][]string{
+					"uid":      {"padfoot"},
+					"cn":       {"Sirius Black"},
+					"memberOf": {"cn=staff,ou=groups,dc=example,dc=com"},
+				},
+			},
+			"uid=wormtail,ou=people,dc=example,dc=com": {
+				password: "asdf",
+				attributes: map[string][]string{
+					"uid":      {"wormtail"},
+					"cn":       {"Peter Pettigrew"},
+					"memberOf": {"cn=rats,ou=groups,dc=example,dc=com"},
+				},
+			},
+		},
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go d.serve(conn)
+		}
+	}()
+
+	return &d
+}
+
+func (d *directory) URL() string {
+	return fmt.Sprintf("ldap://%v", d.listener.Addr())
+}
+
+func (d *directory) Close() {
+	d.listener.Close()
+}
+
+func (d *directory) serve(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+
+		id := packet.Children[0].Value
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case 0: // bind
+			dn := str(op.Children[1])
+			password := str(op.Children[2])
+			code := 49
+
+			if e, ok := d.entries[dn]; ok && e.password == password {
+				code = 0
+			}
+
+			conn.Write(response(id, result(1, code)).Bytes())
+
+		case 3: // search
+			attribute, value := match(op.Children[6])
+
+			for dn, e := range d.entries {
+				if v, ok := e.attributes[attribute]; ok && len(v) > 0 && strings.EqualFold(v[0], value) {
+					conn.Write(response(id, searchEntry(dn, e)).Bytes())
+				}
+			}
+
+			conn.Write(response(id, result(5, 0)).Bytes())
+
+		default: // unbind
+			return
+		}
+	}
+}
+
+// Returns the attribute and value of the first equality match in a search filter, ignoring any
+// objectClass matches.
+func match(filter *ber.Packet) (string, string) {
+	if filter.ClassType == ber.ClassContext && filter.Tag == 3 && len(filter.Children) == 2 {
+		if attribute := str(filter.Children[0]); !strings.EqualFold(attribute, "objectClass") {
+			return attribute, str(filter.Children[1])
+		}
+
+		return "", ""
+	}
+
+	for _, f := range filter.Children {
+		if attribute, value := match(f); attribute != "" {
+			return attribute, value
+		}
+	}
+
+	return "", ""
+}
+
+func response(id any, op *ber.Packet) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "MessageID"))
+	packet.AppendChild(op)
+
+	return packet
+}
+
+func result(tag ber.Tag, code int) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "resultCode"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+
+	return op
+}
+
+func searchEntry(dn string, e entry) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, 4, nil, "Search Result Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, "objectName"))
+
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attributes")
+	for k, values := range e.attributes {
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, k, "type"))
+
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "values")
+		for _, v := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "value"))
+		}
+
+		attribute.AppendChild(set)
+		attributes.AppendChild(attribute)
+	}
+
+	op.AppendChild(attributes)
+
+	return op
+}
+
+func str(p *ber.Packet) string {
+	if v, ok := p.Value.(string); ok {
+		return v
+	}
+
+	return p.Data.String()
+}
+
+type audit struct {
+	logins []string
+	linked map[string]string
+	sync.Mutex
+}
+
+func newProvider(t *testing.T, url string, fallback ...string) (*Provider, *audit) {
+	l, err := local.NewAuthProvider("", "1m", "60m", false, "admin")
+	if err != nil {
+		t.Fatalf("Error creating local auth provider (%v)", err)
+	}
+
+	p, err := NewProvider(l, Config{
+		URL:          url,
+		BindDN:       "cn=service,dc=example,dc=com",
+		BindPassword: "service-password",
+		BaseDN:       "dc=example,dc=com",
+		UserFilter:   "(&(objectClass=person)(uid=%s))",
+		AdminGroup:   "uhppoted-admins",
+		Roles:        []auth.Role{{Group: "staff", Role: "user"}},
+		Fallback:     fallback,
+	})
+	if err != nil {
+		t.Fatalf("Error creating LDAP provider (%v)", err)
+	}
+
+	a := audit{
+		linked: map[string]string{},
+	}
+
+	p.locked = func(uid string) bool {
+		return uid == "prongs"
+	}
+
+	p.link = func(uid, name, role string, fallback bool) error {
+		a.Lock()
+		defer a.Unlock()
+
+		a.linked[uid] = role
+		return nil
+	}
+
+	p.login = func(uid, role string, err error) {
+		a.Lock()
+		defer a.Unlock()
+
+		a.logins = append(a.logins, fmt.Sprintf("%v:%v:%v", uid, role, err == nil))
+	}
+
+	return p, &a
+}
+
+func TestAuthenticate(t *testing.T) {
+	d := newDirectory(t)
+	defer d.Close()
+
+	p, a := newProvider(t, d.URL())
+
+	tests := []struct {
+		uid  string
+		pwd  string
+		role string
+		ok   bool
+	}{
+		{"moony", "qwerty", "admin", true},
+		{"padfoot", "uiop", "user", true},
+		{"moony", "uiop", "", false},
+		{"moony", "", "", false},
+		{"wormtail", "asdf", "", false},
+		{"tonks", "qwerty", "", false},
+		{"*", "qwerty", "", false},
+	}
+
+	for _, test := range tests {
+		token, err := p.Authenticate(test.uid, test.pwd)
+		if test.ok && err != nil {
+			t.Errorf("%v: unexpected error (%v)", test.uid, err)
+		} else if !test.ok && err == nil {
+			t.Errorf("%v: expected error authenticating with password '%v'", test.uid, test.pwd)
+		} else if test.ok {
+			if err := p.Verify(auth.Session, token); err != nil {
+				t.Errorf("%v: invalid session token (%v)", test.uid, err)
+			}
+
+			if role := a.linked[test.uid]; role != test.role {
+				t.Errorf("%v: incorrect role - expected:%v, got:%v", test.uid, test.role, role)
+			}
+		}
+	}
+
+	expected := []string{
+		"moony:admin:true",
+		"padfoot:user:true",
+		"moony::false",
+		"moony::false",
+		"wormtail::false",
+		"tonks::false",
+		"*::false",
+	}
+
+	if strings.Join(a.logins, ",") != strings.Join(expected, ",") {
+		t.Errorf("Incorrect login audit\n   expected:%v\n   got:     %v", expected, a.logins)
+	}
+}
+
+func TestAuthenticateLockedUser(t *testing.T) {
+	d := newDirectory(t)
+	defer d.Close()
+
+	p, a := newProvider(t, d.URL())
+
+	if _, err := p.Authenticate("prongs", "qwerty"); err == nil {
+		t.Errorf("Expected error authenticating locked user")
+	}
+
+	if expected := []string{"prongs::false"}; strings.Join(a.logins, ",") != strings.Join(expected, ",") {
+		t.Errorf("Incorrect login audit for locked user - expected:%v, got:%v", expected, a.logins)
+	}
+}
+
+func TestAuthenticateLocalUser(t *testing.T) {
+	d := newDirectory(t)
+	defer d.Close()
+
+	p, a := newProvider(t, d.URL())
+
+	p.link = func(uid, name, role string, fallback bool) error {
+		return fmt.Errorf("%v is a local user and cannot login with the directory", uid)
+	}
+
+	if _, err := p.Authenticate("padfoot", "uiop"); err == nil {
+		t.Errorf("Expected error authenticating local user")
+	}
+
+	if expected := []string{"padfoot:user:false"}; strings.Join(a.logins, ",") != strings.Join(expected, ",") {
+		t.Errorf("Incorrect login audit for local user - expected:%v, got:%v", expected, a.logins)
+	}
+}
+
+func TestAuthenticateWithDirectoryUnavailable(t *testing.T) {
+	d := newDirectory(t)
+	url := d.URL()
+	d.Close()
+
+	p, a := newProvider(t, url, "admin")
+
+	if _, err := p.Authenticate("moony", "qwerty"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Expected 'directory unavailable' error, got %v", err)
+	}
+
+	if len(a.logins) != 1 || a.logins[0] != "moony::false" {
+		t.Errorf("Incorrect login audit - expected:%v, got:%v", []string{"moony::false"}, a.logins)
+	}
+
+	if !p.fallback("admin") || p.fallback("moony") {
+		t.Errorf("Incorrect fallback accounts - expected:%v, got:%v", []string{"admin"}, p.config.Fallback)
+	}
+}
+
+func TestCommonName(t *testing.T) {
+	tests := map[string]string{
+		"cn=uhppoted-admins,ou=groups,dc=example,dc=com": "uhppoted-admins",
+		"CN=Domain Admins,CN=Users,DC=example,DC=com":    "Domain Admins",
+		"ou=groups,dc=example,dc=com":                    "",
+		"uhppoted-admins":                                "",
+	}
+
+	for dn, expected := range tests {
+		if cn := commonName(dn); cn != expected {
+			t.Errorf("Incorrect CN for '%v' - expected:%v, got:%v", dn, expected, cn)
+		}
+	}
+}
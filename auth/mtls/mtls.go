@@ -0,0 +1,288 @@
+package mtls
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/log"
+	"github.com/uhppoted/uhppoted-httpd/system"
+)
+
+// Config is the client certificate login configuration. The identities are the certificate
+// fields that are matched against the user certificate identities ('subject' and/or 'san').
+type Config struct {
+	CRL        string
+	Identities []string
+}
+
+// MTLS logs in users with a verified TLS client certificate, mapping the certificate subject
+// and subject alternative names to the certificate identities in the user records. Revocation
+// is checked against a local CRL file, which is reloaded whenever it is modified.
+type MTLS struct {
+	config  Config
+	subject bool
+	san     bool
+	crl     *crl
+	guard   sync.Mutex
+
+	user  func(identities []string) (string, bool)
+	get   func(uid string) (auth.IUser, bool)
+	login func(uid, role string, err error)
+}
+
+type crl struct {
+	file     string
+	modified time.Time
+	list     *x509.RevocationList
+	revoked  map[string]struct{}
+	stale    bool
+}
+
+var ErrNoCertificate = errors.New("no verified client certificate")
+
+func NewMTLS(config Config) (*MTLS, error) {
+	m := MTLS{
+		config: config,
+		user:   system.CertificateUser,
+		get:    system.GetUser,
+		login:  system.UserLogin,
+	}
+
+	for _, v := range config.Identities {
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "subject":
+			m.subject = true
+
+		case "san":
+			m.san = true
+
+		case "":
+
+		default:
+			return nil, fmt.Errorf("invalid client certificate identity (%v)", v)
+		}
+	}
+
+	if !m.subject && !m.san {
+		return nil, fmt.Errorf("missing client certificate identities")
+	}
+
+	if file := strings.TrimSpace(config.CRL); file != "" {
+		m.crl = &crl{
+			file: file,
+		}
+
+		if err := m.crl.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &m, nil
+}
+
+// Returns the identities of a client certificate i.e. the subject distinguished name and the
+// subject alternative names, as 'dns:...', 'email:...', 'ip:...' and 'uri:...'.
+func Identities(certificate *x509.Certificate, subject, san bool) []string {
+	list := []string{}
+
+	if subject && len(certificate.Subject.Names) > 0 {
+		list = append(list, certificate.Subject.String())
+	}
+
+	if san {
+		for _, v := range certificate.DNSNames {
+			list = append(list, "dns:"+v)
+		}
+
+		for _, v := range certificate.EmailAddresses {
+			list = append(list, "email:"+v)
+		}
+
+		for _, v := range certificate.IPAddresses {
+			list = append(list, "ip:"+v.String())
+		}
+
+		for _, v := range certificate.URIs {
+			list = append(list, "uri:"+v.String())
+		}
+	}
+
+	return list
+}
+
+// Maps the verified client certificate for a TLS connection to a user, returning the UID and
+// role of the user. Fails if the certificate does not map to exactly one user, the user is locked
+// or the certificate has been revoked. Failed logins for a known user are recorded against the
+// user.
+func (m *MTLS) Login(state *tls.ConnectionState) (uid string, role string, err error) {
+	certificate, chain, err := verified(state)
+	if err != nil {
+		return
+	}
+
+	uid, ok := m.user(Identities(certificate, m.subject, m.san))
+	if !ok {
+		err = fmt.Errorf("no user for client certificate %v", describe(certificate))
+		return
+	}
+
+	defer func() {
+		m.login(uid, role, err)
+	}()
+
+	if u, ok := m.get(uid); !ok || u == nil || u.IsDeleted() {
+		err = fmt.Errorf("invalid user %v", uid)
+		return
+	} else if u.Locked() {
+		err = fmt.Errorf("%v account locked", uid)
+		return
+	} else {
+		role = u.Role()
+	}
+
+	err = m.revoked(certificate, chain)
+
+	return
+}
+
+// Returns an error if the verified client certificate for a TLS connection has been revoked. A
+// connection without a verified client certificate is not an error.
+func (m *MTLS) Verify(state *tls.ConnectionState) error {
+	if certificate, chain, err := verified(state); errors.Is(err, ErrNoCertificate) {
+		return nil
+	} else if err != nil {
+		return err
+	} else {
+		return m.revoked(certificate, chain)
+	}
+}
+
+func (m *MTLS) revoked(certificate *x509.Certificate, chain []*x509.Certificate) error {
+	if m.crl == nil {
+		return nil
+	}
+
+	m.guard.Lock()
+	defer m.guard.Unlock()
+
+	if err := m.crl.refresh(); err != nil {
+		return err
+	}
+
+	if len(chain) < 2 {
+		return fmt.Errorf("missing issuer for client certificate %v", describe(certificate))
+	}
+
+	return m.crl.check(certificate, chain[1])
+}
+
+// Returns the leaf certificate and verified chain for a TLS connection.
+func verified(state *tls.ConnectionState) (*x509.Certificate, []*x509.Certificate, error) {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil, nil, ErrNoCertificate
+	}
+
+	chain := state.VerifiedChains[0]
+
+	return chain[0], chain, nil
+}
+
+// Reloads the CRL if the file has been modified since it was last loaded.
+func (c *crl) refresh() error {
+	info, err := os.Stat(c.file)
+	if err != nil {
+		return fmt.Errorf("error reading CRL (%v)", err)
+	}
+
+	if c.list == nil || !info.ModTime().Equal(c.modified) {
+		if err := c.load(); err != nil {
+			return err
+		}
+
+		infof("reloaded CRL %v", c.file)
+	}
+
+	return nil
+}
+
+func (c *crl) load() error {
+	info, err := os.Stat(c.file)
+	if err != nil {
+		return fmt.Errorf("error reading CRL (%v)", err)
+	}
+
+	blob, err := os.ReadFile(c.file)
+	if err != nil {
+		return fmt.Errorf("error reading CRL (%v)", err)
+	}
+
+	if block, _ := pem.Decode(blob); block != nil {
+		if block.Type != "X509 CRL" {
+			return fmt.Errorf("invalid CRL %v (%v)", c.file, block.Type)
+		}
+
+		blob = block.Bytes
+	}
+
+	list, err := x509.ParseRevocationList(blob)
+	if err != nil {
+		return fmt.Errorf("invalid CRL %v (%v)", c.file, err)
+	}
+
+	revoked := map[string]struct{}{}
+	for _, v := range list.RevokedCertificateEntries {
+		revoked[v.SerialNumber.String()] = struct{}{}
+	}
+
+	c.modified = info.ModTime()
+	c.list = list
+	c.revoked = revoked
+	c.stale = false
+
+	return nil
+}
+
+// Verifies that the CRL was signed by the certificate issuer and that the certificate is not
+// listed. An out of date CRL is still used (with a warning) rather than locking out all
+// certificate logins until the CRL is updated.
+func (c *crl) check(certificate, issuer *x509.Certificate) error {
+	if !bytes.Equal(c.list.RawIssuer, certificate.RawIssuer) {
+		return fmt.Errorf("CRL %v is not for client certificate issuer %v", c.file, certificate.Issuer)
+	}
+
+	if err := c.list.CheckSignatureFrom(issuer); err != nil {
+		return fmt.Errorf("invalid CRL signature (%v)", err)
+	}
+
+	if !c.stale && !c.list.NextUpdate.IsZero() && time.Now().After(c.list.NextUpdate) {
+		c.stale = true
+		warnf("CRL %v is out of date (next update %v)", c.file, c.list.NextUpdate.Format(time.RFC3339))
+	}
+
+	if _, ok := c.revoked[certificate.SerialNumber.String()]; ok {
+		return fmt.Errorf("client certificate %v revoked", describe(certificate))
+	}
+
+	return nil
+}
+
+func describe(certificate *x509.Certificate) string {
+	return fmt.Sprintf("'%v' (serial number %v)", certificate.Subject, certificate.SerialNumber)
+}
+
+func infof(format string, args ...any) {
+	log.Infof(fmt.Sprintf("%-8v %v", "MTLS", format), args...)
+}
+
+func warnf(format string, args ...any) {
+	log.Warnf(fmt.Sprintf("%-8v %v", "MTLS", format), args...)
+}
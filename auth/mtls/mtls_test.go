@@ -0,0 +1,297 @@
+package mtls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uhppoted/uhppoted-httpd/auth"
+)
+
+type testuser struct {
+	role   string
+	locked bool
+}
+
+func (u testuser) Password() ([]byte, string) { return nil, "" }
+func (u testuser) OTPKey() string             { return "" }
+func (u testuser) Role() string               { return u.role }
+func (u testuser) Locked() bool               { return u.locked }
+func (u testuser) IsDeleted() bool            { return false }
+
+type ca struct {
+	key         *ecdsa.PrivateKey
+	certificate *x509.Certificate
+}
+
+func newCA(t *testing.T) ca {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating CA key (%v)", err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Hogwarts CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Error creating CA certificate (%v)", err)
+	}
+
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Error parsing CA certificate (%v)", err)
+	}
+
+	return ca{key, certificate}
+}
+
+func (c ca) issue(t *testing.T, serial int64, cn string, dns ...string) *tls.ConnectionState {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating client key (%v)", err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn, OrganizationalUnit: []string{"Guards"}, Organization: []string{"Hogwarts"}},
+		DNSNames:     dns,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, c.certificate, &key.PublicKey, c.key)
+	if err != nil {
+		t.Fatalf("Error creating client certificate (%v)", err)
+	}
+
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Error parsing client certificate (%v)", err)
+	}
+
+	return &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{certificate},
+		VerifiedChains:   [][]*x509.Certificate{{certificate, c.certificate}},
+	}
+}
+
+func (c ca) crl(t *testing.T, file string, next time.Time, serials ...int64) {
+	template := x509.RevocationList{
+		Number:     big.NewInt(time.Now().UnixNano()),
+		ThisUpdate: time.Now().Add(-time.Hour),
+		NextUpdate: next,
+	}
+
+	for _, v := range serials {
+		template.RevokedCertificateEntries = append(template.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber:   big.NewInt(v),
+			RevocationTime: time.Now(),
+		})
+	}
+
+	der, err := x509.CreateRevocationList(rand.Reader, &template, c.certificate, c.key)
+	if err != nil {
+		t.Fatalf("Error creating CRL (%v)", err)
+	}
+
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), 0600); err != nil {
+		t.Fatalf("Error writing CRL (%v)", err)
+	}
+}
+
+func newMTLS(t *testing.T, config Config, identities map[string]string, users map[string]testuser) (*MTLS, *[]string) {
+	m, err := NewMTLS(config)
+	if err != nil {
+		t.Fatalf("Error creating MTLS (%v)", err)
+	}
+
+	logins := []string{}
+
+	m.user = func(list []string) (string, bool) {
+		for _, v := range list {
+			if uid, ok := identities[strings.ToLower(v)]; ok {
+				return uid, true
+			}
+		}
+
+		return "", false
+	}
+
+	m.get = func(uid string) (auth.IUser, bool) {
+		if u, ok := users[uid]; ok {
+			return u, true
+		}
+
+		return nil, false
+	}
+
+	m.login = func(uid, role string, err error) {
+		if err != nil {
+			logins = append(logins, uid+":failed")
+		} else {
+			logins = append(logins, uid+":ok")
+		}
+	}
+
+	return m, &logins
+}
+
+func TestIdentities(t *testing.T) {
+	state := newCA(t).issue(t, 2, "kiosk-1", "kiosk-1.hogwarts.edu")
+	certificate := state.PeerCertificates[0]
+
+	tests := []struct {
+		subject  bool
+		san      bool
+		expected []string
+	}{
+		{true, true, []string{"CN=kiosk-1,OU=Guards,O=Hogwarts", "dns:kiosk-1.hogwarts.edu"}},
+		{true, false, []string{"CN=kiosk-1,OU=Guards,O=Hogwarts"}},
+		{false, true, []string{"dns:kiosk-1.hogwarts.edu"}},
+	}
+
+	for _, test := range tests {
+		if identities := Identities(certificate, test.subject, test.san); !reflect.DeepEqual(identities, test.expected) {
+			t.Errorf("Incorrect certificate identities\n   expected:%v\n   got:     %v", test.expected, identities)
+		}
+	}
+}
+
+func TestNewMTLSWithInvalidIdentities(t *testing.T) {
+	for _, identities := range [][]string{{}, {"subject", "serial"}} {
+		if _, err := NewMTLS(Config{Identities: identities}); err == nil {
+			t.Errorf("Expected error creating MTLS with identities %v", identities)
+		}
+	}
+}
+
+func TestLogin(t *testing.T) {
+	c := newCA(t)
+	identities := map[string]string{
+		"cn=kiosk-1,ou=guards,o=hogwarts": "moony",
+		"dns:kiosk-2.hogwarts.edu":        "padfoot",
+		"dns:kiosk-3.hogwarts.edu":        "wormtail",
+	}
+
+	users := map[string]testuser{
+		"moony":    {role: "guard"},
+		"padfoot":  {role: "admin"},
+		"wormtail": {role: "guard", locked: true},
+	}
+
+	m, logins := newMTLS(t, Config{Identities: []string{"subject", "san"}}, identities, users)
+
+	tests := []struct {
+		state *tls.ConnectionState
+		uid   string
+		role  string
+		ok    bool
+	}{
+		{c.issue(t, 2, "kiosk-1"), "moony", "guard", true},
+		{c.issue(t, 3, "kiosk-2", "kiosk-2.hogwarts.edu"), "padfoot", "admin", true},
+		{c.issue(t, 4, "kiosk-3", "kiosk-3.hogwarts.edu"), "wormtail", "", false},
+		{c.issue(t, 5, "kiosk-4"), "", "", false},
+		{&tls.ConnectionState{}, "", "", false},
+		{nil, "", "", false},
+	}
+
+	for _, test := range tests {
+		uid, role, err := m.Login(test.state)
+		if test.ok && err != nil {
+			t.Errorf("Unexpected error logging in %v (%v)", test.uid, err)
+		} else if !test.ok && err == nil {
+			t.Errorf("Expected error logging in %v", test.uid)
+		} else if test.ok && (uid != test.uid || role != test.role) {
+			t.Errorf("Incorrect login - expected:%v/%v, got:%v/%v", test.uid, test.role, uid, role)
+		}
+	}
+
+	expected := []string{"moony:ok", "padfoot:ok", "wormtail:failed"}
+	if !slices.Equal(*logins, expected) {
+		t.Errorf("Incorrect login audit\n   expected:%v\n   got:     %v", expected, *logins)
+	}
+}
+
+func TestLoginWithRevokedCertificate(t *testing.T) {
+	c := newCA(t)
+	file := filepath.Join(t.TempDir(), "ca.crl")
+	identities := map[string]string{
+		"cn=kiosk-1,ou=guards,o=hogwarts": "moony",
+		"cn=kiosk-2,ou=guards,o=hogwarts": "padfoot",
+	}
+
+	users := map[string]testuser{
+		"moony":   {role: "guard"},
+		"padfoot": {role: "guard"},
+	}
+
+	c.crl(t, file, time.Now().Add(24*time.Hour), 3)
+
+	m, logins := newMTLS(t, Config{CRL: file, Identities: []string{"subject"}}, identities, users)
+	kiosk1 := c.issue(t, 2, "kiosk-1")
+	kiosk2 := c.issue(t, 3, "kiosk-2")
+
+	if _, _, err := m.Login(kiosk1); err != nil {
+		t.Errorf("Unexpected error logging in with valid certificate (%v)", err)
+	}
+
+	if _, _, err := m.Login(kiosk2); err == nil {
+		t.Errorf("Expected error logging in with revoked certificate")
+	}
+
+	// ... reloads updated CRL
+	time.Sleep(10 * time.Millisecond)
+	c.crl(t, file, time.Now().Add(24*time.Hour), 2, 3)
+	os.Chtimes(file, time.Now(), time.Now().Add(time.Second))
+
+	if err := m.Verify(kiosk1); err == nil {
+		t.Errorf("Expected error verifying revoked certificate after CRL update")
+	}
+
+	if err := m.Verify(&tls.ConnectionState{}); err != nil {
+		t.Errorf("Unexpected error verifying connection without client certificate (%v)", err)
+	}
+
+	expected := []string{"moony:ok", "padfoot:failed"}
+	if !slices.Equal(*logins, expected) {
+		t.Errorf("Incorrect login audit\n   expected:%v\n   got:     %v", expected, *logins)
+	}
+}
+
+func TestLoginWithCRLFromAnotherCA(t *testing.T) {
+	c := newCA(t)
+	other := newCA(t)
+	file := filepath.Join(t.TempDir(), "ca.crl")
+
+	other.crl(t, file, time.Now().Add(24*time.Hour))
+
+	m, _ := newMTLS(t, Config{CRL: file, Identities: []string{"subject"}},
+		map[string]string{"cn=kiosk-1,ou=guards,o=hogwarts": "moony"},
+		map[string]testuser{"moony": {role: "guard"}})
+
+	if _, _, err := m.Login(c.issue(t, 2, "kiosk-1")); err == nil {
+		t.Errorf("Expected error logging in with CRL signed by another CA")
+	}
+}
@@ -0,0 +1,123 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+
+	"github.com/cristalhq/jwt/v3"
+)
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+type jwk struct {
+	KeyID string `json:"kid"`
+	Type  string `json:"kty"`
+	Use   string `json:"use"`
+	N     string `json:"n"`
+	E     string `json:"e"`
+	Curve string `json:"crv"`
+	X     string `json:"x"`
+	Y     string `json:"y"`
+}
+
+// Returns the RSA and EC signing keys in the key set, indexed by key ID. Keys with unsupported
+// types or invalid parameters are ignored.
+func (set jwks) keys() map[string]any {
+	keys := map[string]any{}
+
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		if key, err := k.publicKey(); err == nil {
+			keys[k.KeyID] = key
+		}
+	}
+
+	return keys
+}
+
+func (k jwk) publicKey() (any, error) {
+	switch k.Type {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		if !e.IsInt64() || e.Int64() < 3 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported EC curve (%v)", k.Curve)
+		}
+
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type (%v)", k.Type)
+}
+
+func newVerifier(alg jwt.Algorithm, key any) (jwt.Verifier, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		switch alg {
+		case jwt.RS256, jwt.RS384, jwt.RS512:
+			return jwt.NewVerifierRS(alg, k)
+		case jwt.PS256, jwt.PS384, jwt.PS512:
+			return jwt.NewVerifierPS(alg, k)
+		}
+
+	case *ecdsa.PublicKey:
+		switch alg {
+		case jwt.ES256, jwt.ES384, jwt.ES512:
+			return jwt.NewVerifierES(alg, k)
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported OIDC ID token algorithm (%v)", alg)
+}
+
+func decode(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
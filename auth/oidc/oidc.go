@@ -0,0 +1,445 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cristalhq/jwt/v3"
+	"golang.org/x/oauth2"
+
+	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/auth/impl"
+	"github.com/uhppoted/uhppoted-httpd/log"
+	"github.com/uhppoted/uhppoted-httpd/system"
+)
+
+var constants = struct {
+	LOGIN_EXPIRY time.Duration // Interval within which an authorization request must be completed
+	JWKS_REFRESH time.Duration // Minimum interval between JWKS refreshes for unknown key IDs
+	LEEWAY       time.Duration // Allowed clock skew when validating ID token expiry
+	TIMEOUT      time.Duration // Identity provider request timeout
+}{
+	LOGIN_EXPIRY: 5 * time.Minute,
+	JWKS_REFRESH: 30 * time.Second,
+	LEEWAY:       60 * time.Second,
+	TIMEOUT:      15 * time.Second,
+}
+
+// Config is the OpenID Connect client configuration.
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	UIDClaim     string
+	RoleClaim    string
+	Roles        []auth.Role
+	Provision    bool
+	LocalLogin   bool
+}
+
+// Provider implements an OpenID Connect authorization code flow login on top of the local
+// authentication provider, which manages the session cookies and tokens.
+type Provider struct {
+	*local.Local
+	config  Config
+	client  *http.Client
+	link    func(subject, uid, name, role string, provision bool) (string, error)
+	login   func(uid, role string, err error)
+	pending map[string]pending
+
+	discovery *discovery
+	keys      map[string]any
+	refreshed time.Time
+
+	guard sync.Mutex
+}
+
+type pending struct {
+	nonce    string
+	verifier string
+	expires  time.Time
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type idtoken struct {
+	jwt.StandardClaims
+	Nonce string `json:"nonce"`
+}
+
+func NewProvider(p *local.Local, config Config) (*Provider, error) {
+	if strings.TrimSpace(config.Issuer) == "" {
+		return nil, fmt.Errorf("missing OIDC issuer")
+	}
+
+	if strings.TrimSpace(config.ClientID) == "" {
+		return nil, fmt.Errorf("missing OIDC client ID")
+	}
+
+	if strings.TrimSpace(config.RedirectURL) == "" {
+		return nil, fmt.Errorf("missing OIDC redirect URL")
+	}
+
+	if len(config.Roles) == 0 {
+		return nil, fmt.Errorf("missing OIDC role mappings")
+	}
+
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "profile", "email"}
+	}
+
+	if config.UIDClaim == "" {
+		config.UIDClaim = "preferred_username"
+	}
+
+	if config.RoleClaim == "" {
+		config.RoleClaim = "groups"
+	}
+
+	config.Issuer = strings.TrimSuffix(strings.TrimSpace(config.Issuer), "/")
+
+	return &Provider{
+		Local:   p,
+		config:  config,
+		client:  &http.Client{Timeout: constants.TIMEOUT},
+		link:    system.OIDCLogin,
+		login:   system.UserLogin,
+		pending: map[string]pending{},
+		keys:    map[string]any{},
+	}, nil
+}
+
+// Password logins are only allowed if local logins are enabled in the configuration.
+func (p *Provider) Authenticate(uid, pwd string) (string, error) {
+	if !p.config.LocalLogin {
+		return "", fmt.Errorf("local login disabled")
+	}
+
+	return p.Local.Authenticate(uid, pwd)
+}
+
+func (p *Provider) Credentials(uid, pwd string) (string, error) {
+	if !p.config.LocalLogin {
+		return "", fmt.Errorf("local login disabled")
+	}
+
+	return p.Local.Credentials(uid, pwd)
+}
+
+// Starts an authorization code flow, returning the identity provider URL and the request state.
+func (p *Provider) Login() (string, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), constants.TIMEOUT)
+	defer cancel()
+
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := random()
+	if err != nil {
+		return "", "", err
+	}
+
+	nonce, err := random()
+	if err != nil {
+		return "", "", err
+	}
+
+	verifier := oauth2.GenerateVerifier()
+	url := p.oauth2(d).AuthCodeURL(state, oauth2.S256ChallengeOption(verifier), oauth2.SetAuthURLParam("nonce", nonce))
+
+	p.guard.Lock()
+	defer p.guard.Unlock()
+
+	now := time.Now()
+	for k, v := range p.pending {
+		if now.After(v.expires) {
+			delete(p.pending, k)
+		}
+	}
+
+	p.pending[state] = pending{
+		nonce:    nonce,
+		verifier: verifier,
+		expires:  now.Add(constants.LOGIN_EXPIRY),
+	}
+
+	return url, state, nil
+}
+
+// Completes an authorization code flow, returning a session token for the linked user. The login
+// is recorded for the user (and in the audit trail) in the same way as a local login, including
+// failed logins once the user ID is known.
+func (p *Provider) Callback(ctx context.Context, state, code string) (string, error) {
+	p.guard.Lock()
+	request, ok := p.pending[state]
+	delete(p.pending, state)
+	p.guard.Unlock()
+
+	if !ok || time.Now().After(request.expires) {
+		return "", fmt.Errorf("invalid or expired OIDC login state")
+	}
+
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := p.oauth2(d).Exchange(context.WithValue(ctx, oauth2.HTTPClient, p.client), code, oauth2.VerifierOption(request.verifier))
+	if err != nil {
+		return "", fmt.Errorf("OIDC token exchange failed (%v)", err)
+	}
+
+	raw, ok := token.Extra("id_token").(string)
+	if !ok || raw == "" {
+		return "", fmt.Errorf("missing OIDC ID token")
+	}
+
+	subject, claims, err := p.verify(ctx, raw, request.nonce)
+	if err != nil {
+		return "", err
+	}
+
+	uid, _ := claims[p.config.UIDClaim].(string)
+	name, _ := claims["name"].(string)
+
+	if uid = strings.TrimSpace(uid); uid == "" {
+		return "", fmt.Errorf("missing '%v' claim in OIDC ID token", p.config.UIDClaim)
+	}
+
+	role, err := p.role(claims)
+	if err != nil {
+		p.login(uid, "", err)
+		return "", fmt.Errorf("%v: %v", uid, err)
+	}
+
+	linked, err := p.link(subject, uid, name, role, p.config.Provision)
+	if err != nil {
+		p.login(uid, role, err)
+		return "", err
+	}
+
+	if u, ok := system.GetUser(linked); ok && u.Locked() {
+		err := fmt.Errorf("%v account locked", linked)
+		p.login(linked, role, err)
+		return "", err
+	}
+
+	p.login(linked, role, nil)
+
+	infof("%v logged in with OIDC identity %v (%v)", linked, subject, role)
+
+	return p.NewSession(linked, role)
+}
+
+func (p *Provider) oauth2(d *discovery) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.config.ClientID,
+		ClientSecret: p.config.ClientSecret,
+		RedirectURL:  p.config.RedirectURL,
+		Scopes:       p.config.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  d.AuthorizationEndpoint,
+			TokenURL: d.TokenEndpoint,
+		},
+	}
+}
+
+// Verifies the ID token signature and standard claims, returning the subject and the token
+// claims.
+func (p *Provider) verify(ctx context.Context, raw string, nonce string) (string, map[string]any, error) {
+	token, err := jwt.ParseString(raw)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid OIDC ID token (%v)", err)
+	}
+
+	header := token.Header()
+	key, err := p.key(ctx, header.KeyID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	verifier, err := newVerifier(header.Algorithm, key)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := verifier.Verify(token.Payload(), token.Signature()); err != nil {
+		return "", nil, fmt.Errorf("invalid OIDC ID token signature (%v)", err)
+	}
+
+	var standard idtoken
+	var claims map[string]any
+
+	if err := json.Unmarshal(token.RawClaims(), &standard); err != nil {
+		return "", nil, fmt.Errorf("invalid OIDC ID token claims (%v)", err)
+	}
+
+	if err := json.Unmarshal(token.RawClaims(), &claims); err != nil {
+		return "", nil, fmt.Errorf("invalid OIDC ID token claims (%v)", err)
+	}
+
+	now := time.Now()
+
+	if strings.TrimSuffix(standard.Issuer, "/") != p.config.Issuer {
+		return "", nil, fmt.Errorf("invalid OIDC ID token issuer (%v)", standard.Issuer)
+	}
+
+	if !standard.IsForAudience(p.config.ClientID) {
+		return "", nil, fmt.Errorf("invalid OIDC ID token audience (%v)", standard.Audience)
+	}
+
+	if standard.ExpiresAt == nil || now.Add(-constants.LEEWAY).After(standard.ExpiresAt.Time) {
+		return "", nil, fmt.Errorf("expired OIDC ID token")
+	}
+
+	if standard.Nonce != nonce {
+		return "", nil, fmt.Errorf("invalid OIDC ID token nonce")
+	}
+
+	if strings.TrimSpace(standard.Subject) == "" {
+		return "", nil, fmt.Errorf("missing OIDC ID token subject")
+	}
+
+	return standard.Subject, claims, nil
+}
+
+// Returns the role for the first role mapping that matches a role claim value.
+func (p *Provider) role(claims map[string]any) (string, error) {
+	values := []string{}
+
+	switch v := claims[p.config.RoleClaim].(type) {
+	case string:
+		values = append(values, v)
+
+	case []any:
+		for _, u := range v {
+			if s, ok := u.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	if role, ok := auth.MapRole(p.config.Roles, values); ok {
+		return role, nil
+	}
+
+	return "", fmt.Errorf("no role mapped for OIDC '%v' claim %v", p.config.RoleClaim, values)
+}
+
+func (p *Provider) discover(ctx context.Context) (*discovery, error) {
+	p.guard.Lock()
+	d := p.discovery
+	p.guard.Unlock()
+
+	if d != nil {
+		return d, nil
+	}
+
+	var v discovery
+	if err := p.get(ctx, p.config.Issuer+"/.well-known/openid-configuration", &v); err != nil {
+		return nil, fmt.Errorf("OIDC discovery failed (%v)", err)
+	}
+
+	if strings.TrimSuffix(v.Issuer, "/") != p.config.Issuer {
+		return nil, fmt.Errorf("OIDC discovery issuer mismatch (%v)", v.Issuer)
+	}
+
+	if v.AuthorizationEndpoint == "" || v.TokenEndpoint == "" || v.JWKSURI == "" {
+		return nil, fmt.Errorf("incomplete OIDC discovery document")
+	}
+
+	p.guard.Lock()
+	p.discovery = &v
+	p.guard.Unlock()
+
+	return &v, nil
+}
+
+// Returns the identity provider public key for the key ID, refreshing the cached JWKS if the
+// key is unknown.
+func (p *Provider) key(ctx context.Context, kid string) (any, error) {
+	p.guard.Lock()
+	key, ok := p.keys[kid]
+	refresh := time.Since(p.refreshed) > constants.JWKS_REFRESH
+	p.guard.Unlock()
+
+	if ok {
+		return key, nil
+	} else if !refresh {
+		return nil, fmt.Errorf("unknown OIDC signing key (%v)", kid)
+	}
+
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var set jwks
+	if err := p.get(ctx, d.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("error retrieving OIDC signing keys (%v)", err)
+	}
+
+	keys := set.keys()
+
+	p.guard.Lock()
+	p.keys = keys
+	p.refreshed = time.Now()
+	p.guard.Unlock()
+
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("unknown OIDC signing key (%v)", kid)
+}
+
+func (p *Provider) get(ctx context.Context, url string, v any) error {
+	rq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	rq.Header.Set("Accept", "application/json")
+
+	response, err := p.client.Do(rq)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%v", response.Status)
+	}
+
+	return json.NewDecoder(response.Body).Decode(v)
+}
+
+func random() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func infof(format string, args ...any) {
+	log.Infof(fmt.Sprintf("%-8v %v", "OIDC", format), args...)
+}
@@ -0,0 +1,325 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cristalhq/jwt/v3"
+
+	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/auth/impl"
+	"github.com/uhppoted/uhppoted-httpd/system"
+)
+
+// idp is a minimal mock OpenID Connect identity provider that issues an ID token for a single
+// authorization code.
+type idp struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	signer *rsa.PrivateKey
+	claims map[string]any
+
+	code      string
+	nonce     string
+	challenge string
+	sync.Mutex
+}
+
+func newIdP(t *testing.T) *idp {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating IdP key (%v)", err)
+	}
+
+	m := idp{
+		key:    key,
+		signer: key,
+		claims: map[string]any{
+			"sub":                "00001",
+			"aud":                "uhppoted-httpd",
+			"preferred_username": "moony",
+			"name":               "Remus Lupin",
+			"groups":             []string{"staff", "uhppoted-admins"},
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.discovery)
+	mux.HandleFunc("/jwks", m.jwks)
+	mux.HandleFunc("/token", m.token)
+
+	m.Server = httptest.NewServer(mux)
+	m.claims["iss"] = m.URL
+
+	return &m
+}
+
+func (m *idp) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]any{
+		"issuer":                 m.URL,
+		"authorization_endpoint": m.URL + "/authorize",
+		"token_endpoint":         m.URL + "/token",
+		"jwks_uri":               m.URL + "/jwks",
+	})
+}
+
+func (m *idp) jwks(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]any{
+		"keys": []any{
+			map[string]any{
+				"kid": "K1",
+				"kty": "RSA",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+			},
+		},
+	})
+}
+
+// Simulates the user authenticating at the authorization endpoint, returning the authorization code.
+func (m *idp) authorize(t *testing.T, uri string) (string, string) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("Invalid authorization URL (%v)", err)
+	}
+
+	q := u.Query()
+	if q.Get("response_type") != "code" || q.Get("client_id") != "uhppoted-httpd" || q.Get("code_challenge_method") != "S256" {
+		t.Fatalf("Invalid authorization request (%v)", uri)
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	m.code = "AC-0001"
+	m.nonce = q.Get("nonce")
+	m.challenge = q.Get("code_challenge")
+
+	return q.Get("state"), m.code
+}
+
+func (m *idp) token(w http.ResponseWriter, r *http.Request) {
+	m.Lock()
+	defer m.Unlock()
+
+	r.ParseForm()
+
+	h := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+	if r.Form.Get("code") != m.code || base64.RawURLEncoding.EncodeToString(h[:]) != m.challenge {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	claims := map[string]any{
+		"nonce": m.nonce,
+		"exp":   time.Now().Add(5 * time.Minute).Unix(),
+		"iat":   time.Now().Unix(),
+	}
+
+	for k, v := range m.claims {
+		claims[k] = v
+	}
+
+	signer, _ := jwt.NewSignerRS(jwt.RS256, m.signer)
+	token, err := jwt.NewBuilder(signer, jwt.WithKeyID("K1")).Build(claims)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"access_token": "AT-0001",
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     token.String(),
+	})
+}
+
+func newProvider(t *testing.T, m *idp) *Provider {
+	l, err := local.NewAuthProvider("", "1m", "60m", false, "admin")
+	if err != nil {
+		t.Fatalf("Error creating local auth provider (%v)", err)
+	}
+
+	p, err := NewProvider(l, Config{
+		Issuer:      m.URL,
+		ClientID:    "uhppoted-httpd",
+		RedirectURL: "http://127.0.0.1:8080/oidc/callback",
+		Roles:       []auth.Role{{Group: "uhppoted-admins", Role: "admin"}, {Group: "staff", Role: "user"}},
+	})
+	if err != nil {
+		t.Fatalf("Error creating OIDC provider (%v)", err)
+	}
+
+	p.link = func(subject, uid, name, role string, provision bool) (string, error) {
+		if subject != "00001" {
+			return "", fmt.Errorf("no user linked to OIDC identity %v", subject)
+		}
+
+		return uid, nil
+	}
+
+	p.login = func(uid, role string, err error) {
+	}
+
+	return p
+}
+
+func login(t *testing.T, m *idp, p *Provider) (string, error) {
+	uri, _, err := p.Login()
+	if err != nil {
+		t.Fatalf("Error starting OIDC login (%v)", err)
+	}
+
+	state, code := m.authorize(t, uri)
+
+	return p.Callback(context.Background(), state, code)
+}
+
+func TestLogin(t *testing.T) {
+	m := newIdP(t)
+	defer m.Close()
+
+	p := newProvider(t, m)
+
+	token, err := login(t, m, p)
+	if err != nil {
+		t.Fatalf("Unexpected error logging in (%v)", err)
+	}
+
+	if err := p.Verify(auth.Session, token); err != nil {
+		t.Errorf("Invalid session token (%v)", err)
+	}
+
+	session, err := system.GetSession(token, 0)
+	if err != nil {
+		t.Fatalf("Error retrieving session (%v)", err)
+	}
+
+	if session.UID != "moony" || session.Role != "admin" {
+		t.Errorf("Incorrect session - expected:%v/%v, got:%v/%v", "moony", "admin", session.UID, session.Role)
+	}
+}
+
+func TestLoginRecordsUserLogin(t *testing.T) {
+	m := newIdP(t)
+	defer m.Close()
+
+	p := newProvider(t, m)
+	logins := []string{}
+
+	p.login = func(uid, role string, err error) {
+		logins = append(logins, fmt.Sprintf("%v:%v:%v", uid, role, err))
+	}
+
+	if _, err := login(t, m, p); err != nil {
+		t.Fatalf("Unexpected error logging in (%v)", err)
+	}
+
+	m.claims["sub"] = "00002"
+	if _, err := login(t, m, p); err == nil {
+		t.Fatalf("Expected error logging in with unlinked OIDC identity")
+	}
+
+	expected := []string{
+		"moony:admin:<nil>",
+		"moony:admin:no user linked to OIDC identity 00002",
+	}
+
+	if !reflect.DeepEqual(logins, expected) {
+		t.Errorf("Incorrect user logins\n   expected:%v\n   got:     %v", expected, logins)
+	}
+}
+
+func TestLoginWithInvalidIDToken(t *testing.T) {
+	tests := map[string]func(m *idp){
+		"issuer":    func(m *idp) { m.claims["iss"] = "http://127.0.0.1:1234" },
+		"audience":  func(m *idp) { m.claims["aud"] = "uhppoted-rest" },
+		"subject":   func(m *idp) { m.claims["sub"] = "00002" },
+		"uid":       func(m *idp) { delete(m.claims, "preferred_username") },
+		"role":      func(m *idp) { m.claims["groups"] = []string{"students"} },
+		"expired":   func(m *idp) { m.claims["exp"] = time.Now().Add(-5 * time.Minute).Unix() },
+		"nonce":     func(m *idp) { m.claims["nonce"] = "0xdeadbeef" },
+		"signature": func(m *idp) { m.signer, _ = rsa.GenerateKey(rand.Reader, 2048) },
+	}
+
+	for k, f := range tests {
+		m := newIdP(t)
+		p := newProvider(t, m)
+
+		f(m)
+
+		if _, err := login(t, m, p); err == nil {
+			t.Errorf("%v: expected error logging in with invalid ID token", k)
+		}
+
+		m.Close()
+	}
+}
+
+func TestCallbackWithInvalidState(t *testing.T) {
+	m := newIdP(t)
+	defer m.Close()
+
+	p := newProvider(t, m)
+
+	uri, _, err := p.Login()
+	if err != nil {
+		t.Fatalf("Error starting OIDC login (%v)", err)
+	}
+
+	_, code := m.authorize(t, uri)
+
+	if _, err := p.Callback(context.Background(), "0xdeadbeef", code); err == nil {
+		t.Errorf("Expected error for invalid OIDC state")
+	}
+}
+
+func TestCallbackReplay(t *testing.T) {
+	m := newIdP(t)
+	defer m.Close()
+
+	p := newProvider(t, m)
+
+	uri, _, err := p.Login()
+	if err != nil {
+		t.Fatalf("Error starting OIDC login (%v)", err)
+	}
+
+	state, code := m.authorize(t, uri)
+
+	if _, err := p.Callback(context.Background(), state, code); err != nil {
+		t.Fatalf("Unexpected error logging in (%v)", err)
+	}
+
+	if _, err := p.Callback(context.Background(), state, code); err == nil {
+		t.Errorf("Expected error for replayed OIDC state")
+	}
+}
+
+func TestLocalLogin(t *testing.T) {
+	m := newIdP(t)
+	defer m.Close()
+
+	p := newProvider(t, m)
+
+	if _, err := p.Authenticate("moony", "qwerty"); err == nil || !strings.Contains(err.Error(), "disabled") {
+		t.Errorf("Expected 'local login disabled' error, got %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+package passwords
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+)
+
+// Password hashing parameters. Memory is in KiB.
+type Parameters struct {
+	Algorithm   string
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	Cost        int
+}
+
+const saltLength = 16
+const keyLength = 32
+
+var b64 = base64.RawStdEncoding
+
+var parameters = Parameters{
+	Algorithm:   Argon2id,
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	Cost:        bcrypt.DefaultCost,
+}
+
+// Sets the algorithm and cost parameters used to hash new passwords. Existing hashes with
+// different parameters are still verified but are flagged for rehashing.
+func SetParameters(p Parameters) error {
+	switch strings.ToLower(strings.TrimSpace(p.Algorithm)) {
+	case Argon2id:
+		if p.Memory < 8*uint32(p.Parallelism) || p.Iterations < 1 || p.Parallelism < 1 {
+			return fmt.Errorf("invalid argon2id parameters (memory:%v iterations:%v parallelism:%v)", p.Memory, p.Iterations, p.Parallelism)
+		}
+
+		parameters.Algorithm = Argon2id
+		parameters.Memory = p.Memory
+		parameters.Iterations = p.Iterations
+		parameters.Parallelism = p.Parallelism
+
+	case Bcrypt:
+		if p.Cost < bcrypt.MinCost || p.Cost > bcrypt.MaxCost {
+			return fmt.Errorf("invalid bcrypt cost (%v)", p.Cost)
+		}
+
+		parameters.Algorithm = Bcrypt
+		parameters.Cost = p.Cost
+
+	default:
+		return fmt.Errorf("unsupported password hash algorithm '%v'", p.Algorithm)
+	}
+
+	return nil
+}
+
+// Returns a self-describing hash of the password, in PHC string format for argon2id or the
+// standard modular crypt format for bcrypt.
+func Hash(pwd string) (string, error) {
+	switch parameters.Algorithm {
+	case Bcrypt:
+		if hash, err := bcrypt.GenerateFromPassword([]byte(pwd), parameters.Cost); err != nil {
+			return "", err
+		} else {
+			return string(hash), nil
+		}
+
+	default:
+		salt := make([]byte, saltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+
+		m := parameters.Memory
+		t := parameters.Iterations
+		p := parameters.Parallelism
+		key := argon2.IDKey([]byte(pwd), salt, t, m, p, keyLength)
+
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, m, t, p, b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+	}
+}
+
+// Verifies a password against a stored hash. Recognises argon2id and bcrypt hashes as well as
+// the legacy salted SHA-256 hashes, which are the only format that uses the separate salt.
+// Returns ok if the password matches and rehash if the hash is not in the currently configured
+// format and should be replaced.
+func Verify(pwd string, salt []byte, hash string) (ok bool, rehash bool) {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return verifyArgon2id(pwd, hash)
+
+	case strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$"):
+		return verifyBcrypt(pwd, hash)
+
+	default:
+		return verifySHA256(pwd, salt, hash), true
+	}
+}
+
+func verifyArgon2id(pwd string, hash string) (bool, bool) {
+	var version int
+	var m, t uint32
+	var p uint8
+
+	fields := strings.Split(hash, "$")
+	if len(fields) != 6 {
+		return false, false
+	}
+
+	if _, err := fmt.Sscanf(fields[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false
+	}
+
+	if _, err := fmt.Sscanf(fields[3], "m=%d,t=%d,p=%d", &m, &t, &p); err != nil || t < 1 || p < 1 {
+		return false, false
+	}
+
+	salt, err := b64.DecodeString(fields[4])
+	if err != nil {
+		return false, false
+	}
+
+	key, err := b64.DecodeString(fields[5])
+	if err != nil || len(key) == 0 {
+		return false, false
+	}
+
+	k := argon2.IDKey([]byte(pwd), salt, t, m, p, uint32(len(key)))
+	if subtle.ConstantTimeCompare(k, key) != 1 {
+		return false, false
+	}
+
+	rehash := parameters.Algorithm != Argon2id ||
+		m != parameters.Memory ||
+		t != parameters.Iterations ||
+		p != parameters.Parallelism
+
+	return true, rehash
+}
+
+func verifyBcrypt(pwd string, hash string) (bool, bool) {
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(pwd)); err != nil {
+		return false, false
+	}
+
+	cost, err := bcrypt.Cost([]byte(hash))
+
+	return true, err != nil || parameters.Algorithm != Bcrypt || cost != parameters.Cost
+}
+
+func verifySHA256(pwd string, salt []byte, hash string) bool {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(pwd))
+
+	return subtle.ConstantTimeCompare([]byte(hex.EncodeToString(h.Sum(nil))), []byte(hash)) == 1
+}
@@ -0,0 +1,142 @@
+package passwords
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHashArgon2id(t *testing.T) {
+	defer reset(t)
+
+	if err := SetParameters(Parameters{Algorithm: Argon2id, Memory: 1024, Iterations: 1, Parallelism: 1}); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	hash, err := Hash("qwerty")
+	if err != nil {
+		t.Fatalf("Error hashing password (%v)", err)
+	}
+
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("Incorrect argon2id hash format - got:%v", hash)
+	}
+
+	if ok, rehash := Verify("qwerty", nil, hash); !ok || rehash {
+		t.Errorf("Incorrect verification - expected:%v/%v, got:%v/%v", true, false, ok, rehash)
+	}
+
+	if ok, _ := Verify("uiop", nil, hash); ok {
+		t.Errorf("Incorrect verification - expected:%v, got:%v", false, ok)
+	}
+
+	if other, _ := Hash("qwerty"); other == hash {
+		t.Errorf("Expected unique salt for each hash")
+	}
+}
+
+func TestHashBcrypt(t *testing.T) {
+	defer reset(t)
+
+	if err := SetParameters(Parameters{Algorithm: Bcrypt, Cost: 4}); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	hash, err := Hash("qwerty")
+	if err != nil {
+		t.Fatalf("Error hashing password (%v)", err)
+	}
+
+	if !strings.HasPrefix(hash, "$2a$04$") {
+		t.Errorf("Incorrect bcrypt hash format - got:%v", hash)
+	}
+
+	if ok, rehash := Verify("qwerty", nil, hash); !ok || rehash {
+		t.Errorf("Incorrect verification - expected:%v/%v, got:%v/%v", true, false, ok, rehash)
+	}
+
+	if ok, _ := Verify("uiop", nil, hash); ok {
+		t.Errorf("Incorrect verification - expected:%v, got:%v", false, ok)
+	}
+}
+
+func TestVerifyLegacySHA256(t *testing.T) {
+	salt := []byte{0x3a, 0x0b, 0x91, 0x7e, 0x55, 0x1c, 0xd2, 0x44, 0x08, 0xf6, 0x6d, 0x2e, 0xa1, 0x37, 0xc9, 0x80}
+	hash := "30f55dc91ac4ab3f1390639708f923578c58abf8be9abfa51d117a69b254662c"
+
+	if ok, rehash := Verify("qwerty", salt, hash); !ok || !rehash {
+		t.Errorf("Incorrect verification - expected:%v/%v, got:%v/%v", true, true, ok, rehash)
+	}
+
+	if ok, _ := Verify("uiop", salt, hash); ok {
+		t.Errorf("Incorrect verification - expected:%v, got:%v", false, ok)
+	}
+
+	if ok, _ := Verify("qwerty", salt, ""); ok {
+		t.Errorf("Incorrect verification of empty hash - expected:%v, got:%v", false, ok)
+	}
+}
+
+func TestVerifyWithChangedParameters(t *testing.T) {
+	defer reset(t)
+
+	if err := SetParameters(Parameters{Algorithm: Argon2id, Memory: 1024, Iterations: 1, Parallelism: 1}); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	argon2id, _ := Hash("qwerty")
+
+	if err := SetParameters(Parameters{Algorithm: Argon2id, Memory: 2048, Iterations: 1, Parallelism: 1}); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if ok, rehash := Verify("qwerty", nil, argon2id); !ok || !rehash {
+		t.Errorf("Incorrect verification - expected:%v/%v, got:%v/%v", true, true, ok, rehash)
+	}
+
+	if err := SetParameters(Parameters{Algorithm: Bcrypt, Cost: 4}); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if ok, rehash := Verify("qwerty", nil, argon2id); !ok || !rehash {
+		t.Errorf("Incorrect verification - expected:%v/%v, got:%v/%v", true, true, ok, rehash)
+	}
+
+	bcrypt, _ := Hash("qwerty")
+
+	if err := SetParameters(Parameters{Algorithm: Bcrypt, Cost: 5}); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if ok, rehash := Verify("qwerty", nil, bcrypt); !ok || !rehash {
+		t.Errorf("Incorrect verification - expected:%v/%v, got:%v/%v", true, true, ok, rehash)
+	}
+}
+
+func TestSetParameters(t *testing.T) {
+	defer reset(t)
+
+	tests := []Parameters{
+		{Algorithm: "md5"},
+		{Algorithm: Argon2id, Memory: 65536, Iterations: 0, Parallelism: 1},
+		{Algorithm: Argon2id, Memory: 65536, Iterations: 1, Parallelism: 0},
+		{Algorithm: Argon2id, Memory: 4, Iterations: 1, Parallelism: 1},
+		{Algorithm: Bcrypt, Cost: 3},
+		{Algorithm: Bcrypt, Cost: 32},
+	}
+
+	for _, p := range tests {
+		if err := SetParameters(p); err == nil {
+			t.Errorf("Expected error for invalid parameters %+v", p)
+		}
+	}
+}
+
+func reset(t *testing.T) {
+	if err := SetParameters(Parameters{Algorithm: Bcrypt, Cost: 10}); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if err := SetParameters(Parameters{Algorithm: Argon2id, Memory: 64 * 1024, Iterations: 3, Parallelism: 2}); err != nil {
+		t.Fatalf("%v", err)
+	}
+}
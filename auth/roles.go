@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Role maps an external identity provider group or claim value to a uhppoted-httpd role. A '*'
+// group matches any authenticated user.
+type Role struct {
+	Group string
+	Role  string
+}
+
+// Parses a list of comma separated group:role pairs e.g. "uhppoted-admins:admin,staff:user". The
+// list is ordered i.e. the first matching group determines the user role.
+func ParseRoles(s string) ([]Role, error) {
+	roles := []Role{}
+
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+
+		ix := strings.LastIndex(v, ":")
+		if ix < 0 {
+			return nil, fmt.Errorf("invalid role mapping (%v)", v)
+		}
+
+		group := strings.TrimSpace(v[:ix])
+		role := strings.TrimSpace(v[ix+1:])
+		if group == "" || role == "" {
+			return nil, fmt.Errorf("invalid role mapping (%v)", v)
+		}
+
+		roles = append(roles, Role{Group: group, Role: role})
+	}
+
+	return roles, nil
+}
+
+// Returns the role for the first role mapping that matches one of the groups.
+func MapRole(roles []Role, groups []string) (string, bool) {
+	for _, r := range roles {
+		if r.Group == "*" {
+			return r.Role, true
+		}
+
+		for _, g := range groups {
+			if g == r.Group {
+				return r.Role, true
+			}
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRoles(t *testing.T) {
+	roles, err := ParseRoles("uhppoted-admins:admin, https://example.com/staff:user ,*:guest")
+	if err != nil {
+		t.Fatalf("Unexpected error parsing roles (%v)", err)
+	}
+
+	expected := []Role{
+		{"uhppoted-admins", "admin"},
+		{"https://example.com/staff", "user"},
+		{"*", "guest"},
+	}
+
+	if !reflect.DeepEqual(roles, expected) {
+		t.Errorf("Incorrect roles - expected:%v, got:%v", expected, roles)
+	}
+
+	if _, err := ParseRoles("uhppoted-admins"); err == nil {
+		t.Errorf("Expected error parsing invalid role mapping")
+	}
+}
+
+func TestMapRole(t *testing.T) {
+	roles := []Role{
+		{"uhppoted-admins", "admin"},
+		{"staff", "user"},
+	}
+
+	tests := []struct {
+		groups   []string
+		expected string
+		ok       bool
+	}{
+		{[]string{"staff", "uhppoted-admins"}, "admin", true},
+		{[]string{"staff"}, "user", true},
+		{[]string{"students"}, "", false},
+		{[]string{}, "", false},
+	}
+
+	for _, test := range tests {
+		if role, ok := MapRole(roles, test.groups); ok != test.ok || role != test.expected {
+			t.Errorf("Incorrect role for %v - expected:%v, got:%v", test.groups, test.expected, role)
+		}
+	}
+
+	if role, ok := MapRole(append(roles, Role{"*", "guest"}), []string{"students"}); !ok || role != "guest" {
+		t.Errorf("Incorrect wildcard role - expected:%v, got:%v", "guest", role)
+	}
+}
@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Scope restricts a (delegated) administrator to a subset of the groups, doors and controllers.
+// A user without a scope (nil) is restricted only by the authorisation rules. The scope is
+// available to the grules as SCOPE e.g. SCOPE.HasGroup("0.5.1"). Cards that are not a member
+// of any group are only in scope if Ungrouped is set.
+type Scope struct {
+	Groups      []string
+	Doors       []string
+	Controllers []string
+	Ungrouped   bool
+}
+
+var scopes = struct {
+	lookup func(uid string) *Scope
+	sync.RWMutex
+}{}
+
+var cards = struct {
+	lookup func(card uint32) ([]string, bool)
+	sync.RWMutex
+}{}
+
+// SetScopes sets the function used to retrieve the scope for a user ID.
+func SetScopes(f func(uid string) *Scope) {
+	scopes.Lock()
+	defer scopes.Unlock()
+
+	scopes.lookup = f
+}
+
+// SetCardGroups sets the function used to retrieve the groups for a card number, returning false
+// if the card is not in the cards list.
+func SetCardGroups(f func(card uint32) ([]string, bool)) {
+	cards.Lock()
+	defer cards.Unlock()
+
+	cards.lookup = f
+}
+
+// ScopeOf returns the scope of the user for an authorizator, or nil if the user is unscoped or
+// the authorizator is a system authorizator.
+func ScopeOf(a OpAuth) *Scope {
+	switch v := a.(type) {
+	case *Authorizator:
+		if v != nil {
+			return ScopeOf(v.OpAuth)
+		}
+
+	case *authorizator:
+		if v != nil {
+			return scope(v.uid)
+		}
+	}
+
+	return nil
+}
+
+func scope(uid string) *Scope {
+	scopes.RLock()
+	defer scopes.RUnlock()
+
+	if scopes.lookup != nil && uid != "" {
+		return scopes.lookup(uid)
+	}
+
+	return nil
+}
+
+// Returns true if the scope is restricted i.e. not nil.
+func (s *Scope) IsScoped() bool {
+	return s != nil
+}
+
+// Returns true if the group OID is in scope.
+func (s *Scope) HasGroup(oid string) bool {
+	return s == nil || slices.Contains(s.Groups, oid)
+}
+
+// Returns true if the door OID is in scope.
+func (s *Scope) HasDoor(oid string) bool {
+	return s == nil || slices.Contains(s.Doors, oid)
+}
+
+// Returns true if the controller OID is in scope.
+func (s *Scope) HasController(oid string) bool {
+	return s == nil || slices.Contains(s.Controllers, oid)
+}
+
+// Returns true if the card is in scope i.e. if the card is a member of any group in scope. Cards
+// that are not in the cards list are never in scope for a scoped administrator.
+func (s *Scope) HasCard(card uint32) bool {
+	if s == nil {
+		return true
+	}
+
+	cards.RLock()
+	defer cards.RUnlock()
+
+	if cards.lookup != nil {
+		if groups, ok := cards.lookup(card); ok {
+			return s.HasAnyGroup(groups...)
+		}
+	}
+
+	return false
+}
+
+// Returns true if any of the group OIDs is in scope. An empty list (e.g. for a card that is not
+// a member of any group) is only in scope if the scope includes ungrouped cards.
+func (s *Scope) HasAnyGroup(oids ...string) bool {
+	if s == nil {
+		return true
+	} else if len(oids) == 0 {
+		return s.Ungrouped
+	}
+
+	return slices.ContainsFunc(oids, s.HasGroup)
+}
+
+// Returns true if all of the group OIDs are in scope. An empty list is only in scope if the
+// scope includes ungrouped cards.
+func (s *Scope) HasAllGroups(oids ...string) bool {
+	if s == nil {
+		return true
+	} else if len(oids) == 0 {
+		return s.Ungrouped
+	}
+
+	for _, oid := range oids {
+		if !s.HasGroup(oid) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns a key that uniquely identifies the scope, for use in the 'can view' cache.
+func (s *Scope) key() string {
+	return fmt.Sprintf("%v;%v;%v;%v", s.Groups, s.Doors, s.Controllers, s.Ungrouped)
+}
@@ -0,0 +1,208 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/hyperjumptech/grule-rule-engine/ast"
+	"github.com/hyperjumptech/grule-rule-engine/builder"
+	"github.com/hyperjumptech/grule-rule-engine/pkg"
+)
+
+type operant struct {
+	Name string
+}
+
+func (o operant) AsRuleEntity() (string, any) {
+	return "card", &o
+}
+
+func (o operant) CacheKey() string {
+	return ""
+}
+
+func TestScope(t *testing.T) {
+	var unscoped *Scope
+
+	scoped := &Scope{
+		Groups:      []string{"0.5.1", "0.5.3"},
+		Doors:       []string{"0.3.2"},
+		Controllers: []string{},
+	}
+
+	ungrouped := &Scope{
+		Groups:    []string{"0.5.1"},
+		Ungrouped: true,
+	}
+
+	tests := []struct {
+		scope    *Scope
+		method   string
+		check    func(s *Scope) bool
+		expected bool
+	}{
+		{unscoped, "IsScoped", func(s *Scope) bool { return s.IsScoped() }, false},
+		{unscoped, "HasGroup", func(s *Scope) bool { return s.HasGroup("0.5.2") }, true},
+		{unscoped, "HasController", func(s *Scope) bool { return s.HasController("0.2.1") }, true},
+		{scoped, "IsScoped", func(s *Scope) bool { return s.IsScoped() }, true},
+		{scoped, "HasGroup", func(s *Scope) bool { return s.HasGroup("0.5.1") }, true},
+		{scoped, "HasGroup", func(s *Scope) bool { return s.HasGroup("0.5.2") }, false},
+		{scoped, "HasDoor", func(s *Scope) bool { return s.HasDoor("0.3.2") }, true},
+		{scoped, "HasController", func(s *Scope) bool { return s.HasController("0.2.1") }, false},
+		{scoped, "HasAnyGroup", func(s *Scope) bool { return s.HasAnyGroup() }, false},
+		{ungrouped, "HasAnyGroup", func(s *Scope) bool { return s.HasAnyGroup() }, true},
+		{scoped, "HasAnyGroup", func(s *Scope) bool { return s.HasAnyGroup("0.5.2", "0.5.3") }, true},
+		{scoped, "HasAnyGroup", func(s *Scope) bool { return s.HasAnyGroup("0.5.2", "0.5.4") }, false},
+		{scoped, "HasAllGroups", func(s *Scope) bool { return s.HasAllGroups("0.5.1", "0.5.3") }, true},
+		{scoped, "HasAllGroups", func(s *Scope) bool { return s.HasAllGroups("0.5.1", "0.5.2") }, false},
+		{scoped, "HasAllGroups", func(s *Scope) bool { return s.HasAllGroups() }, false},
+		{ungrouped, "HasAllGroups", func(s *Scope) bool { return s.HasAllGroups() }, true},
+	}
+
+	for i, test := range tests {
+		if v := test.check(test.scope); v != test.expected {
+			t.Errorf("test %v: incorrect %v - expected:%v, got:%v", i+1, test.method, test.expected, v)
+		}
+	}
+}
+
+func TestScopeHasCard(t *testing.T) {
+	SetCardGroups(func(card uint32) ([]string, bool) {
+		switch card {
+		case 10058400:
+			return []string{"0.5.1", "0.5.2"}, true
+		case 10058401:
+			return []string{"0.5.2"}, true
+		case 10058402:
+			return []string{}, true
+		}
+
+		return nil, false
+	})
+
+	defer SetCardGroups(nil)
+
+	var unscoped *Scope
+
+	scoped := &Scope{
+		Groups:    []string{"0.5.1"},
+		Ungrouped: true,
+	}
+
+	tests := []struct {
+		scope    *Scope
+		card     uint32
+		expected bool
+	}{
+		{unscoped, 10058401, true},
+		{unscoped, 10058409, true},
+		{scoped, 10058400, true},
+		{scoped, 10058401, false},
+		{scoped, 10058402, true},
+		{scoped, 10058409, false},
+	}
+
+	for i, test := range tests {
+		if v := test.scope.HasCard(test.card); v != test.expected {
+			t.Errorf("test %v: incorrect HasCard(%v) - expected:%v, got:%v", i+1, test.card, test.expected, v)
+		}
+	}
+}
+
+func TestScopeOf(t *testing.T) {
+	SetScopes(func(uid string) *Scope {
+		if uid == "moony" {
+			return &Scope{Groups: []string{"0.5.1"}}
+		}
+
+		return nil
+	})
+
+	defer SetScopes(nil)
+
+	if s := ScopeOf(NewAuthorizator("moony", "admin")); s == nil || !s.HasGroup("0.5.1") || s.HasGroup("0.5.2") {
+		t.Errorf("Incorrect scope for 'moony' - got:%v", s)
+	}
+
+	if s := ScopeOf(NewAuthorizator("padfoot", "admin")); s != nil {
+		t.Errorf("Expected nil scope for 'padfoot', got:%v", s)
+	}
+
+	if s := ScopeOf(NewSystemAuthorizator("moony")); s != nil {
+		t.Errorf("Expected nil scope for system authorizator, got:%v", s)
+	}
+
+	if s := ScopeOf(nil); s != nil {
+		t.Errorf("Expected nil scope for nil authorizator, got:%v", s)
+	}
+}
+
+func TestScopeInGrules(t *testing.T) {
+	rules := `
+rule UpdateCard "(allowed)" {
+     when
+         OP == "update::card"
+     then
+         RESULT.Allow = true;
+         Retract("UpdateCard");
+}
+
+rule UpdateCardPIN "(scoped)" {
+     when
+         OP == "update::card" && FIELD == "PIN" && SCOPE.IsScoped() && !SCOPE.HasGroup("0.5.2")
+     then
+         RESULT.Refuse = true;
+         Retract("UpdateCardPIN");
+}`
+
+	kb := ast.NewKnowledgeLibrary()
+	if err := builder.NewRuleBuilder(kb).BuildRuleFromResource("cards", "0.0.0", pkg.NewBytesResource([]byte(rules))); err != nil {
+		t.Fatalf("Error building test rules (%v)", err)
+	}
+
+	grules.Lock()
+	original, ok := grules.ruleset[Cards]
+	grules.ruleset[Cards] = ruleset{kb: kb}
+	grules.Unlock()
+
+	defer func() {
+		grules.Lock()
+		if ok {
+			grules.ruleset[Cards] = original
+		} else {
+			delete(grules.ruleset, Cards)
+		}
+		grules.Unlock()
+	}()
+
+	SetScopes(func(uid string) *Scope {
+		if uid == "moony" {
+			return &Scope{Groups: []string{"0.5.1"}}
+		}
+
+		return nil
+	})
+
+	defer SetScopes(nil)
+
+	tests := []struct {
+		uid   string
+		field string
+		allow bool
+	}{
+		{"padfoot", "name", true},
+		{"padfoot", "PIN", true},
+		{"moony", "name", true},
+		{"moony", "PIN", false},
+	}
+
+	for _, test := range tests {
+		a := NewAuthorizator(test.uid, "admin")
+		err := a.CanUpdate(operant{Name: "Le Card"}, test.field, 1234, Cards)
+
+		if test.allow && err != nil {
+			t.Errorf("%v: unexpected error updating %v (%v)", test.uid, test.field, err)
+		} else if !test.allow && err == nil {
+			t.Errorf("%v: expected error updating %v", test.uid, test.field)
+		}
+	}
+}
@@ -0,0 +1,454 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	lib "github.com/go-webauthn/webauthn/webauthn"
+
+	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/log"
+	"github.com/uhppoted/uhppoted-httpd/system"
+	"github.com/uhppoted/uhppoted-httpd/system/users"
+)
+
+var constants = struct {
+	CEREMONY_EXPIRY time.Duration // Interval within which a registration or login must be completed
+}{
+	CEREMONY_EXPIRY: 5 * time.Minute,
+}
+
+// Config is the WebAuthn relying party configuration.
+type Config struct {
+	RPID         string
+	RPName       string
+	Origins      []string
+	Passwordless bool
+	SecondFactor bool
+}
+
+// WebAuthn implements passkey registration and passkey logins, either passwordless (with a
+// discoverable credential) or as a second factor after a password login.
+type WebAuthn struct {
+	config   Config
+	webauthn *lib.WebAuthn
+	pending  map[string]ceremony
+	guard    sync.Mutex
+
+	user     func(uid string) (auth.IUser, bool)
+	passkeys func(uid string) (string, []users.Passkey)
+	owner    func(handle string) (string, bool)
+	add      func(uid, role, handle, id, name string, credential []byte) (users.Passkey, error)
+	use      func(uid, id string, credential []byte) error
+	login    func(uid, role string, err error)
+}
+
+// ceremony is the server side state for an incomplete registration or login. A login ceremony
+// for a known user is a second factor login and can only be started after a password login.
+type ceremony struct {
+	uid     string
+	role    string
+	handle  string
+	data    lib.SessionData
+	expires time.Time
+}
+
+// user adapts a user and the registered passkeys to the WebAuthn library user interface.
+type user struct {
+	uid         string
+	handle      string
+	credentials []lib.Credential
+}
+
+func (u user) WebAuthnID() []byte {
+	return []byte(u.handle)
+}
+
+func (u user) WebAuthnName() string {
+	return u.uid
+}
+
+func (u user) WebAuthnDisplayName() string {
+	return u.uid
+}
+
+func (u user) WebAuthnCredentials() []lib.Credential {
+	return u.credentials
+}
+
+func NewWebAuthn(config Config) (*WebAuthn, error) {
+	if strings.TrimSpace(config.RPID) == "" {
+		return nil, fmt.Errorf("missing WebAuthn relying party ID")
+	}
+
+	if len(config.Origins) == 0 {
+		return nil, fmt.Errorf("missing WebAuthn origins")
+	}
+
+	if config.RPName == "" {
+		config.RPName = "uhppoted-httpd"
+	}
+
+	w, err := lib.New(&lib.Config{
+		RPID:          config.RPID,
+		RPDisplayName: config.RPName,
+		RPOrigins:     config.Origins,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementPreferred,
+			UserVerification: protocol.VerificationPreferred,
+		},
+		Timeouts: lib.TimeoutsConfig{
+			Login: lib.TimeoutConfig{
+				Enforce: true,
+				Timeout: constants.CEREMONY_EXPIRY,
+			},
+			Registration: lib.TimeoutConfig{
+				Enforce: true,
+				Timeout: constants.CEREMONY_EXPIRY,
+			},
+		},
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid WebAuthn configuration (%v)", err)
+	}
+
+	return &WebAuthn{
+		config:   config,
+		webauthn: w,
+		pending:  map[string]ceremony{},
+
+		user:     system.GetUser,
+		passkeys: system.Passkeys,
+		owner:    system.PasskeyUser,
+		add:      system.AddPasskey,
+		use:      system.UsePasskey,
+		login:    system.UserLogin,
+	}, nil
+}
+
+func (w *WebAuthn) Passwordless() bool {
+	return w.config.Passwordless
+}
+
+// Returns true if a password login for the user must be completed with a passkey i.e. second
+// factor logins are enabled and the user has at least one registered passkey.
+func (w *WebAuthn) Required(uid string) bool {
+	if !w.config.SecondFactor {
+		return false
+	}
+
+	_, passkeys := w.passkeys(uid)
+
+	return len(passkeys) > 0
+}
+
+// Starts a passkey registration for a logged in user, returning the credential creation options
+// for navigator.credentials.create() and the key for the pending registration.
+func (w *WebAuthn) BeginRegistration(uid, role string) (*protocol.CredentialCreation, string, error) {
+	u, err := w.lookup(uid)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if u.handle == "" {
+		if u.handle, err = users.NewHandle(); err != nil {
+			return nil, "", err
+		}
+	}
+
+	exclusions := lib.Credentials(u.credentials).CredentialDescriptors()
+
+	options, data, err := w.webauthn.BeginRegistration(u, lib.WithExclusions(exclusions))
+	if err != nil {
+		return nil, "", err
+	}
+
+	key, err := w.stash(ceremony{
+		uid:    uid,
+		role:   role,
+		handle: u.handle,
+		data:   *data,
+	})
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return options, key, nil
+}
+
+// Verifies the attestation returned by navigator.credentials.create() and stores the new passkey
+// with the user record.
+func (w *WebAuthn) FinishRegistration(key, uid, role, name string, response []byte) (users.Passkey, error) {
+	c, ok := w.take(key)
+	if !ok || c.uid != uid || c.handle == "" {
+		return users.Passkey{}, fmt.Errorf("invalid or expired passkey registration")
+	}
+
+	u, err := w.lookup(uid)
+	if err != nil {
+		return users.Passkey{}, err
+	}
+
+	u.handle = c.handle
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		return users.Passkey{}, fmt.Errorf("invalid passkey registration (%v)", err)
+	}
+
+	credential, err := w.webauthn.CreateCredential(u, c.data, parsed)
+	if err != nil {
+		return users.Passkey{}, fmt.Errorf("passkey attestation failed (%v)", describe(err))
+	}
+
+	record, err := json.Marshal(credential)
+	if err != nil {
+		return users.Passkey{}, err
+	}
+
+	if strings.TrimSpace(name) == "" {
+		name = fmt.Sprintf("passkey %v", time.Now().Format("2006-01-02 15:04"))
+	}
+
+	p, err := w.add(uid, role, c.handle, id(credential.ID), name, record)
+	if err != nil {
+		return users.Passkey{}, err
+	}
+
+	infof("%v registered passkey %v", uid, p.Name)
+
+	return p, nil
+}
+
+// Starts a passwordless login, returning the credential request options for
+// navigator.credentials.get() and the key for the pending login.
+func (w *WebAuthn) BeginLogin() (*protocol.CredentialAssertion, string, error) {
+	if !w.config.Passwordless {
+		return nil, "", fmt.Errorf("passwordless login disabled")
+	}
+
+	options, data, err := w.webauthn.BeginDiscoverableLogin(lib.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		return nil, "", err
+	}
+
+	key, err := w.stash(ceremony{
+		data: *data,
+	})
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return options, key, nil
+}
+
+// Starts a second factor login for a user that has been authenticated with a password.
+func (w *WebAuthn) BeginSecondFactor(uid, role string) (*protocol.CredentialAssertion, string, error) {
+	u, err := w.lookup(uid)
+	if err != nil {
+		return nil, "", err
+	} else if len(u.credentials) == 0 {
+		return nil, "", fmt.Errorf("%v has no registered passkeys", uid)
+	}
+
+	options, data, err := w.webauthn.BeginLogin(u)
+	if err != nil {
+		return nil, "", err
+	}
+
+	key, err := w.stash(ceremony{
+		uid:  uid,
+		role: role,
+		data: *data,
+	})
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return options, key, nil
+}
+
+// Verifies the assertion returned by navigator.credentials.get(), returning the UID and role of
+// the authenticated user.
+func (w *WebAuthn) FinishLogin(key string, response []byte) (uid string, role string, err error) {
+	c, ok := w.take(key)
+	if !ok || c.handle != "" {
+		err = fmt.Errorf("invalid or expired passkey login")
+		return
+	}
+
+	uid = c.uid
+	role = c.role
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		err = fmt.Errorf("invalid passkey assertion (%v)", err)
+		return
+	}
+
+	// ... passwordless logins identify the user by the user handle
+	if uid == "" {
+		if v, ok := w.owner(string(parsed.Response.UserHandle)); !ok {
+			err = fmt.Errorf("unknown passkey")
+			return
+		} else if u, ok := w.user(v); !ok || u == nil || u.IsDeleted() {
+			err = fmt.Errorf("unknown passkey")
+			return
+		} else {
+			uid = v
+			role = u.Role()
+		}
+	}
+
+	defer func() {
+		w.login(uid, role, err)
+	}()
+
+	if u, ok := w.user(uid); !ok || u == nil || u.IsDeleted() {
+		err = fmt.Errorf("invalid user %v", uid)
+		return
+	} else if u.Locked() {
+		err = fmt.Errorf("%v account locked", uid)
+		return
+	}
+
+	u, err := w.lookup(uid)
+	if err != nil {
+		return
+	}
+
+	var credential *lib.Credential
+
+	if c.uid == "" {
+		if !bytes.Equal(parsed.Response.UserHandle, u.WebAuthnID()) {
+			err = fmt.Errorf("invalid passkey user handle")
+			return
+		}
+
+		_, credential, err = w.webauthn.ValidatePasskeyLogin(func(rawID, handle []byte) (lib.User, error) { return u, nil }, c.data, parsed)
+	} else {
+		credential, err = w.webauthn.ValidateLogin(u, c.data, parsed)
+	}
+
+	if err != nil {
+		err = fmt.Errorf("passkey login failed (%v)", describe(err))
+		return
+	}
+
+	if credential.Authenticator.CloneWarning {
+		err = fmt.Errorf("passkey signature counter mismatch (possible cloned authenticator)")
+		return
+	}
+
+	record, err := json.Marshal(credential)
+	if err != nil {
+		return
+	}
+
+	if err = w.use(uid, id(credential.ID), record); err != nil {
+		return
+	}
+
+	infof("%v logged in with passkey", uid)
+
+	return
+}
+
+// Returns the user handle and registered passkey credentials for a user.
+func (w *WebAuthn) lookup(uid string) (user, error) {
+	if u, ok := w.user(uid); !ok || u == nil || u.IsDeleted() {
+		return user{}, fmt.Errorf("invalid user %v", uid)
+	}
+
+	handle, passkeys := w.passkeys(uid)
+	credentials := []lib.Credential{}
+
+	for _, p := range passkeys {
+		var credential lib.Credential
+		if err := json.Unmarshal(p.Credential(), &credential); err != nil {
+			warnf("%v: invalid passkey %v (%v)", uid, p.ID, err)
+		} else {
+			credentials = append(credentials, credential)
+		}
+	}
+
+	return user{
+		uid:         uid,
+		handle:      handle,
+		credentials: credentials,
+	}, nil
+}
+
+func (w *WebAuthn) stash(c ceremony) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	key := base64.RawURLEncoding.EncodeToString(b)
+	now := time.Now()
+
+	w.guard.Lock()
+	defer w.guard.Unlock()
+
+	for k, v := range w.pending {
+		if now.After(v.expires) {
+			delete(w.pending, k)
+		}
+	}
+
+	c.expires = now.Add(constants.CEREMONY_EXPIRY)
+	w.pending[key] = c
+
+	return key, nil
+}
+
+// Removes and returns a pending ceremony i.e. a ceremony can only be completed once.
+func (w *WebAuthn) take(key string) (ceremony, bool) {
+	w.guard.Lock()
+	defer w.guard.Unlock()
+
+	c, ok := w.pending[key]
+	delete(w.pending, key)
+
+	if !ok || time.Now().After(c.expires) {
+		return ceremony{}, false
+	}
+
+	return c, true
+}
+
+func id(credentialID []byte) string {
+	return base64.RawURLEncoding.EncodeToString(credentialID)
+}
+
+// Includes the WebAuthn library diagnostic information, which is otherwise not part of the error
+// message.
+func describe(err error) string {
+	var e *protocol.Error
+	if errors.As(err, &e) && e.DevInfo != "" {
+		return fmt.Sprintf("%v: %v", e.Details, e.DevInfo)
+	}
+
+	return err.Error()
+}
+
+func infof(format string, args ...any) {
+	log.Infof(fmt.Sprintf("%-8v %v", "WEBAUTHN", format), args...)
+}
+
+func warnf(format string, args ...any) {
+	log.Warnf(fmt.Sprintf("%-8v %v", "WEBAUTHN", format), args...)
+}
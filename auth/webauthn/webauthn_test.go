@@ -0,0 +1,421 @@
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+
+	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/system/users"
+)
+
+const origin = "https://uhppoted.example.com"
+
+// authenticator is a minimal software authenticator that creates ES256 credentials with 'none'
+// attestation.
+type authenticator struct {
+	key    *ecdsa.PrivateKey
+	id     []byte
+	handle []byte
+	count  uint32
+	uv     bool
+}
+
+type testuser struct {
+	role   string
+	locked bool
+}
+
+func (u testuser) Password() ([]byte, string) { return nil, "" }
+func (u testuser) OTPKey() string             { return "" }
+func (u testuser) Role() string               { return u.role }
+func (u testuser) Locked() bool               { return u.locked }
+func (u testuser) IsDeleted() bool            { return false }
+
+type store struct {
+	users    map[string]testuser
+	handles  map[string]string
+	passkeys map[string][]users.Passkey
+	logins   []string
+	sync.Mutex
+}
+
+func newAuthenticator(t *testing.T, uv bool) *authenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating authenticator key (%v)", err)
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		t.Fatalf("Error generating credential ID (%v)", err)
+	}
+
+	return &authenticator{
+		key: key,
+		id:  id,
+		uv:  uv,
+	}
+}
+
+func (a *authenticator) authData(rpid string, attested bool) []byte {
+	hash := sha256.Sum256([]byte(rpid))
+	flags := byte(protocol.FlagUserPresent)
+
+	if a.uv {
+		flags |= byte(protocol.FlagUserVerified)
+	}
+
+	if attested {
+		flags |= byte(protocol.FlagAttestedCredentialData)
+	}
+
+	data := slices.Concat(hash[:], []byte{flags}, binary.BigEndian.AppendUint32(nil, a.count))
+
+	if attested {
+		x := make([]byte, 32)
+		y := make([]byte, 32)
+
+		a.key.PublicKey.X.FillBytes(x)
+		a.key.PublicKey.Y.FillBytes(y)
+
+		cose, _ := webauthncbor.Marshal(map[int]any{1: 2, 3: -7, -1: 1, -2: x, -3: y})
+
+		data = slices.Concat(data, make([]byte, 16), binary.BigEndian.AppendUint16(nil, uint16(len(a.id))), a.id, cose)
+	}
+
+	return data
+}
+
+func (a *authenticator) create(options *protocol.CredentialCreation, origin string) []byte {
+	a.handle = []byte(options.Response.User.ID.(protocol.URLEncodedBase64))
+
+	clientData, _ := json.Marshal(map[string]any{
+		"type":      "webauthn.create",
+		"challenge": base64.RawURLEncoding.EncodeToString(options.Response.Challenge),
+		"origin":    origin,
+	})
+
+	attestation, _ := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": a.authData(options.Response.RelyingParty.ID, true),
+	})
+
+	response, _ := json.Marshal(map[string]any{
+		"id":    base64.RawURLEncoding.EncodeToString(a.id),
+		"rawId": base64.RawURLEncoding.EncodeToString(a.id),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientData),
+			"attestationObject": base64.RawURLEncoding.EncodeToString(attestation),
+		},
+	})
+
+	return response
+}
+
+func (a *authenticator) get(options *protocol.CredentialAssertion, origin string) []byte {
+	a.count++
+
+	clientData, _ := json.Marshal(map[string]any{
+		"type":      "webauthn.get",
+		"challenge": base64.RawURLEncoding.EncodeToString(options.Response.Challenge),
+		"origin":    origin,
+	})
+
+	authData := a.authData(options.Response.RelyingPartyID, false)
+	hash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(slices.Concat(authData, hash[:]))
+	signature, _ := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+
+	response, _ := json.Marshal(map[string]any{
+		"id":    base64.RawURLEncoding.EncodeToString(a.id),
+		"rawId": base64.RawURLEncoding.EncodeToString(a.id),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientData),
+			"authenticatorData": base64.RawURLEncoding.EncodeToString(authData),
+			"signature":         base64.RawURLEncoding.EncodeToString(signature),
+			"userHandle":        base64.RawURLEncoding.EncodeToString(a.handle),
+		},
+	})
+
+	return response
+}
+
+func newTestWebAuthn(t *testing.T, secondFactor bool) (*WebAuthn, *store) {
+	w, err := NewWebAuthn(Config{
+		RPID:         "uhppoted.example.com",
+		Origins:      []string{origin},
+		Passwordless: true,
+		SecondFactor: secondFactor,
+	})
+
+	if err != nil {
+		t.Fatalf("Error creating WebAuthn relying party (%v)", err)
+	}
+
+	s := store{
+		users: map[string]testuser{
+			"moony":  {role: "admin"},
+			"prongs": {role: "user", locked: true},
+		},
+		handles:  map[string]string{},
+		passkeys: map[string][]users.Passkey{},
+	}
+
+	w.user = func(uid string) (auth.IUser, bool) {
+		s.Lock()
+		defer s.Unlock()
+
+		u, ok := s.users[uid]
+		return u, ok
+	}
+
+	w.passkeys = func(uid string) (string, []users.Passkey) {
+		s.Lock()
+		defer s.Unlock()
+
+		for handle, v := range s.handles {
+			if v == uid {
+				return handle, slices.Clone(s.passkeys[uid])
+			}
+		}
+
+		return "", nil
+	}
+
+	w.owner = func(handle string) (string, bool) {
+		s.Lock()
+		defer s.Unlock()
+
+		uid, ok := s.handles[handle]
+		return uid, ok
+	}
+
+	w.add = func(uid, role, handle, id, name string, credential []byte) (users.Passkey, error) {
+		s.Lock()
+		defer s.Unlock()
+
+		p, err := users.NewPasskey(id, name, credential)
+		if err == nil {
+			s.handles[handle] = uid
+			s.passkeys[uid] = append(s.passkeys[uid], p)
+		}
+
+		return p, err
+	}
+
+	w.use = func(uid, id string, credential []byte) error {
+		s.Lock()
+		defer s.Unlock()
+
+		for i, p := range s.passkeys[uid] {
+			if p.ID == id {
+				if v, err := users.NewPasskey(id, p.Name, credential); err != nil {
+					return err
+				} else {
+					s.passkeys[uid][i] = v
+				}
+			}
+		}
+
+		return nil
+	}
+
+	w.login = func(uid, role string, err error) {
+		s.Lock()
+		defer s.Unlock()
+
+		s.logins = append(s.logins, fmt.Sprintf("%v:%v:%v", uid, role, err == nil))
+	}
+
+	return w, &s
+}
+
+func register(t *testing.T, w *WebAuthn, a *authenticator, uid string) {
+	options, key, err := w.BeginRegistration(uid, "admin")
+	if err != nil {
+		t.Fatalf("Error starting passkey registration (%v)", err)
+	}
+
+	if _, err := w.FinishRegistration(key, uid, "admin", "yubikey", a.create(options, origin)); err != nil {
+		t.Fatalf("Error registering passkey (%v)", err)
+	}
+}
+
+func TestPasswordlessLogin(t *testing.T) {
+	w, s := newTestWebAuthn(t, false)
+
+	a := newAuthenticator(t, true)
+
+	register(t, w, a, "moony")
+
+	if len(s.passkeys["moony"]) != 1 {
+		t.Fatalf("Passkey not registered - expected:%v, got:%v", 1, len(s.passkeys["moony"]))
+	}
+
+	for i := 0; i < 2; i++ {
+		options, key, err := w.BeginLogin()
+		if err != nil {
+			t.Fatalf("Error starting passkey login (%v)", err)
+		}
+
+		uid, role, err := w.FinishLogin(key, a.get(options, origin))
+		if err != nil {
+			t.Fatalf("Unexpected error logging in with passkey (%v)", err)
+		} else if uid != "moony" || role != "admin" {
+			t.Errorf("Incorrect passkey login - expected:%v/%v, got:%v/%v", "moony", "admin", uid, role)
+		}
+	}
+
+	expected := []string{"moony:admin:true", "moony:admin:true"}
+	if !slices.Equal(s.logins, expected) {
+		t.Errorf("Incorrect login audit\n   expected:%v\n   got:     %v", expected, s.logins)
+	}
+}
+
+func TestSecondFactorLogin(t *testing.T) {
+	w, s := newTestWebAuthn(t, true)
+
+	a := newAuthenticator(t, false)
+
+	if w.Required("moony") {
+		t.Errorf("Passkey required for user without passkeys")
+	}
+
+	register(t, w, a, "moony")
+
+	if !w.Required("moony") {
+		t.Errorf("Passkey not required for user with passkeys")
+	}
+
+	// ... valid passkey
+	options, key, err := w.BeginSecondFactor("moony", "admin")
+	if err != nil {
+		t.Fatalf("Error starting second factor login (%v)", err)
+	}
+
+	if uid, role, err := w.FinishLogin(key, a.get(options, origin)); err != nil {
+		t.Errorf("Unexpected error completing second factor login (%v)", err)
+	} else if uid != "moony" || role != "admin" {
+		t.Errorf("Incorrect second factor login - expected:%v/%v, got:%v/%v", "moony", "admin", uid, role)
+	}
+
+	// ... unregistered authenticator
+	b := newAuthenticator(t, false)
+	b.handle = a.handle
+
+	options, key, err = w.BeginSecondFactor("moony", "admin")
+	if err != nil {
+		t.Fatalf("Error starting second factor login (%v)", err)
+	}
+
+	if _, _, err := w.FinishLogin(key, b.get(options, origin)); err == nil {
+		t.Errorf("Expected error completing second factor login with unregistered passkey")
+	}
+
+	expected := []string{"moony:admin:true", "moony:admin:false"}
+	if !slices.Equal(s.logins, expected) {
+		t.Errorf("Incorrect login audit\n   expected:%v\n   got:     %v", expected, s.logins)
+	}
+}
+
+func TestLoginWithInvalidAssertion(t *testing.T) {
+	tests := []struct {
+		name   string
+		origin string
+		modify func(a *authenticator, options *protocol.CredentialAssertion)
+	}{
+		{"origin", "https://phishing.example.com", nil},
+		{"challenge", origin, func(a *authenticator, options *protocol.CredentialAssertion) { options.Response.Challenge[0] ^= 0xff }},
+		{"user verification", origin, func(a *authenticator, options *protocol.CredentialAssertion) { a.uv = false }},
+		{"signature counter", origin, func(a *authenticator, options *protocol.CredentialAssertion) { a.count = 0 }},
+		{"signature", origin, func(a *authenticator, options *protocol.CredentialAssertion) {
+			a.key, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		}},
+	}
+
+	for _, test := range tests {
+		w, _ := newTestWebAuthn(t, false)
+
+		a := newAuthenticator(t, true)
+		register(t, w, a, "moony")
+
+		// ... establish a non-zero signature counter
+		options, key, _ := w.BeginLogin()
+		if _, _, err := w.FinishLogin(key, a.get(options, origin)); err != nil {
+			t.Fatalf("%v: unexpected error logging in with passkey (%v)", test.name, err)
+		}
+
+		options, key, _ = w.BeginLogin()
+		if test.modify != nil {
+			test.modify(a, options)
+		}
+
+		if _, _, err := w.FinishLogin(key, a.get(options, test.origin)); err == nil {
+			t.Errorf("%v: expected error logging in with invalid passkey assertion", test.name)
+		}
+	}
+}
+
+func TestLoginReplay(t *testing.T) {
+	w, _ := newTestWebAuthn(t, false)
+
+	a := newAuthenticator(t, true)
+	register(t, w, a, "moony")
+
+	options, key, _ := w.BeginLogin()
+	response := a.get(options, origin)
+
+	if _, _, err := w.FinishLogin(key, response); err != nil {
+		t.Fatalf("Unexpected error logging in with passkey (%v)", err)
+	}
+
+	if _, _, err := w.FinishLogin(key, response); err == nil {
+		t.Errorf("Expected error replaying passkey login")
+	}
+}
+
+func TestLoginWithLockedUser(t *testing.T) {
+	w, _ := newTestWebAuthn(t, false)
+
+	a := newAuthenticator(t, true)
+	register(t, w, a, "prongs")
+
+	options, key, _ := w.BeginLogin()
+	if _, _, err := w.FinishLogin(key, a.get(options, origin)); err == nil {
+		t.Errorf("Expected error logging in with passkey for locked user")
+	}
+}
+
+func TestRegistrationForDifferentUser(t *testing.T) {
+	w, s := newTestWebAuthn(t, false)
+
+	a := newAuthenticator(t, true)
+
+	options, key, err := w.BeginRegistration("moony", "admin")
+	if err != nil {
+		t.Fatalf("Error starting passkey registration (%v)", err)
+	}
+
+	if _, err := w.FinishRegistration(key, "prongs", "user", "yubikey", a.create(options, origin)); err == nil {
+		t.Errorf("Expected error completing passkey registration for a different user")
+	}
+
+	if len(s.passkeys) != 0 {
+		t.Errorf("Unexpected passkey registration (%v)", s.passkeys)
+	}
+}
@@ -0,0 +1,243 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Version is the backup archive format version. Archives with a later version are rejected by
+// Read.
+const Version = 1
+
+const (
+	System = "system"
+	Auth   = "auth"
+	Grules = "grules"
+	Audit  = "audit"
+)
+
+const manifest = "manifest.json"
+
+// Manifest describes the contents of a backup archive. Each file is listed with its type (system,
+// auth, grules or audit), the tag identifying the system file or grules ruleset and a SHA-256
+// checksum.
+type Manifest struct {
+	Version     int       `json:"version"`
+	Application string    `json:"application"`
+	Release     string    `json:"release"`
+	Created     time.Time `json:"created"`
+	Files       []File    `json:"files"`
+}
+
+type File struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Tag    string `json:"tag,omitempty"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Archive is a gzipped tar file containing a manifest.json and the backed up files.
+type Archive struct {
+	Manifest Manifest
+	contents map[string][]byte
+}
+
+func NewArchive(application, release string) *Archive {
+	return &Archive{
+		Manifest: Manifest{
+			Version:     Version,
+			Application: application,
+			Release:     release,
+			Created:     time.Now().UTC(),
+			Files:       []File{},
+		},
+		contents: map[string][]byte{},
+	}
+}
+
+// Filename returns the name for an archive created at 'timestamp' e.g.
+// uhppoted-httpd-2026-10-18T10-43-21.tar.gz.
+func Filename(application string, timestamp time.Time) string {
+	return fmt.Sprintf("%v-%v.tar.gz", application, timestamp.UTC().Format("2006-01-02T15-04-05"))
+}
+
+func (a *Archive) Add(filetype, tag, name string, bytes []byte) {
+	hash := sha256.Sum256(bytes)
+	name = path.Join(filetype, name)
+
+	a.Manifest.Files = append(a.Manifest.Files, File{
+		Name:   name,
+		Type:   filetype,
+		Tag:    tag,
+		Size:   int64(len(bytes)),
+		SHA256: hex.EncodeToString(hash[:]),
+	})
+
+	a.contents[name] = bytes
+}
+
+// Returns the list of files of the requested type.
+func (a *Archive) Files(filetype string) []File {
+	list := []File{}
+	for _, f := range a.Manifest.Files {
+		if f.Type == filetype {
+			list = append(list, f)
+		}
+	}
+
+	return list
+}
+
+func (a *Archive) Contents(f File) []byte {
+	return a.contents[f.Name]
+}
+
+func (a *Archive) Write(w io.Writer) error {
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+
+	m, err := json.MarshalIndent(a.Manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	write := func(name string, bytes []byte) error {
+		header := tar.Header{
+			Name:    name,
+			Mode:    0600,
+			Size:    int64(len(bytes)),
+			ModTime: a.Manifest.Created,
+		}
+
+		if err := tw.WriteHeader(&header); err != nil {
+			return err
+		}
+
+		_, err := tw.Write(bytes)
+
+		return err
+	}
+
+	if err := write(manifest, m); err != nil {
+		return err
+	}
+
+	for _, f := range a.Manifest.Files {
+		if err := write(f.Name, a.contents[f.Name]); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// Read unpacks a backup archive, verifying the manifest version and the file checksums. An archive
+// with files that are not in the manifest (or vice versa) is rejected.
+func Read(r io.Reader) (*Archive, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup archive (%v)", err)
+	}
+
+	tr := tar.NewReader(zr)
+	a := Archive{
+		contents: map[string][]byte{},
+	}
+
+	var m []byte
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid backup archive (%v)", err)
+		}
+
+		if header.Typeflag == tar.TypeDir {
+			continue
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("invalid backup archive entry '%v'", header.Name)
+		}
+
+		bytes, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid backup archive (%v)", err)
+		}
+
+		if header.Name == manifest {
+			m = bytes
+		} else {
+			a.contents[header.Name] = bytes
+		}
+	}
+
+	if m == nil {
+		return nil, fmt.Errorf("invalid backup archive (missing %v)", manifest)
+	}
+
+	if err := json.Unmarshal(m, &a.Manifest); err != nil {
+		return nil, fmt.Errorf("invalid backup manifest (%v)", err)
+	}
+
+	if a.Manifest.Version < 1 || a.Manifest.Version > Version {
+		return nil, fmt.Errorf("unsupported backup archive version (%v)", a.Manifest.Version)
+	}
+
+	names := []string{}
+	for _, f := range a.Manifest.Files {
+		if err := f.validate(); err != nil {
+			return nil, err
+		}
+
+		bytes, ok := a.contents[f.Name]
+		if !ok {
+			return nil, fmt.Errorf("backup archive is missing %v", f.Name)
+		}
+
+		hash := sha256.Sum256(bytes)
+		if int64(len(bytes)) != f.Size || hex.EncodeToString(hash[:]) != f.SHA256 {
+			return nil, fmt.Errorf("backup archive file %v does not match the manifest checksum", f.Name)
+		}
+
+		names = append(names, f.Name)
+	}
+
+	for name := range a.contents {
+		if !slices.Contains(names, name) {
+			return nil, fmt.Errorf("backup archive file %v is not in the manifest", name)
+		}
+	}
+
+	return &a, nil
+}
+
+func (f File) validate() error {
+	switch f.Type {
+	case System, Auth, Grules, Audit:
+	default:
+		return fmt.Errorf("invalid backup archive file type '%v' (%v)", f.Type, f.Name)
+	}
+
+	if dir, file := path.Split(f.Name); dir != f.Type+"/" || file == "" || file == "." || file == ".." || strings.Contains(file, "\\") {
+		return fmt.Errorf("invalid backup archive file name '%v'", f.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,259 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// S3 stores backup archives in a bucket on an S3 compatible object store (e.g. AWS S3 or MinIO).
+// Requests use path-style URLs and AWS Signature Version 4 authentication.
+type S3 struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+}
+
+var client = &http.Client{
+	Timeout: 5 * time.Minute,
+}
+
+func (s S3) Put(name string, archive []byte) error {
+	rq, err := http.NewRequest(http.MethodPut, s.url(s.Prefix+name, nil), bytes.NewReader(archive))
+	if err != nil {
+		return err
+	}
+
+	rq.Header.Set("Content-Type", "application/gzip")
+
+	_, err = s.do(rq, archive)
+
+	return err
+}
+
+// Returns the names (without the prefix) of the objects in the bucket with the store prefix.
+func (s S3) List() ([]string, error) {
+	list := []string{}
+	token := ""
+
+	for {
+		query := url.Values{
+			"list-type": {"2"},
+			"prefix":    {s.Prefix},
+		}
+
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		rq, err := http.NewRequest(http.MethodGet, s.url("", query), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := s.do(rq, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		result := struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}{}
+
+		if err := xml.Unmarshal(body, &result); err != nil {
+			return nil, fmt.Errorf("invalid S3 object list (%v)", err)
+		}
+
+		for _, v := range result.Contents {
+			if name := strings.TrimPrefix(v.Key, s.Prefix); name != "" && !strings.Contains(name, "/") {
+				list = append(list, name)
+			}
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return list, nil
+		}
+
+		token = result.NextContinuationToken
+	}
+}
+
+func (s S3) Delete(name string) error {
+	rq, err := http.NewRequest(http.MethodDelete, s.url(s.Prefix+name, nil), nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.do(rq, nil)
+
+	return err
+}
+
+func (s S3) String() string {
+	return fmt.Sprintf("s3://%v/%v", s.Bucket, s.Prefix)
+}
+
+func (s S3) url(key string, query url.Values) string {
+	u := strings.TrimSuffix(s.Endpoint, "/") + "/" + encode(s.Bucket, false)
+	if key != "" {
+		u += "/" + encode(key, true)
+	}
+
+	if len(query) > 0 {
+		u += "?" + canonicalQuery(query)
+	}
+
+	return u
+}
+
+func (s S3) do(rq *http.Request, payload []byte) ([]byte, error) {
+	s.sign(rq, payload, time.Now())
+
+	response, err := client.Do(rq)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		e := struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}{}
+
+		if err := xml.Unmarshal(body, &e); err == nil && e.Code != "" {
+			return nil, fmt.Errorf("S3 %v %v failed (%v: %v)", rq.Method, rq.URL.Path, e.Code, e.Message)
+		}
+
+		return nil, fmt.Errorf("S3 %v %v failed (%v)", rq.Method, rq.URL.Path, response.Status)
+	}
+
+	return body, nil
+}
+
+// Adds the AWS Signature Version 4 Authorization header to a request. All the request headers
+// set at this point are signed, along with the host.
+func (s S3) sign(rq *http.Request, payload []byte, now time.Time) {
+	timestamp := now.UTC().Format("20060102T150405Z")
+	date := timestamp[:8]
+	hash := sha256.Sum256(payload)
+	scope := fmt.Sprintf("%v/%v/s3/aws4_request", date, s.Region)
+
+	rq.Header.Set("x-amz-date", timestamp)
+	rq.Header.Set("x-amz-content-sha256", hex.EncodeToString(hash[:]))
+
+	headers := map[string]string{
+		"host": rq.URL.Host,
+	}
+
+	for k, v := range rq.Header {
+		values := []string{}
+		for _, s := range v {
+			values = append(values, strings.Join(strings.Fields(s), " "))
+		}
+
+		headers[strings.ToLower(k)] = strings.Join(values, ",")
+	}
+
+	keys := []string{}
+	for k := range headers {
+		keys = append(keys, k)
+	}
+
+	slices.Sort(keys)
+
+	var canonical strings.Builder
+
+	canonical.WriteString(rq.Method + "\n")
+	canonical.WriteString(rq.URL.EscapedPath() + "\n")
+	canonical.WriteString(canonicalQuery(rq.URL.Query()) + "\n")
+	for _, k := range keys {
+		canonical.WriteString(k + ":" + headers[k] + "\n")
+	}
+	canonical.WriteString("\n")
+	canonical.WriteString(strings.Join(keys, ";") + "\n")
+	canonical.WriteString(hex.EncodeToString(hash[:]))
+
+	request := sha256.Sum256([]byte(canonical.String()))
+	stringToSign := fmt.Sprintf("AWS4-HMAC-SHA256\n%v\n%v\n%v", timestamp, scope, hex.EncodeToString(request[:]))
+
+	key := []byte("AWS4" + s.SecretKey)
+	for _, v := range []string{date, s.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, v)
+	}
+
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	rq.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%v/%v, SignedHeaders=%v, Signature=%v",
+		s.AccessKey, scope, strings.Join(keys, ";"), signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+
+	return h.Sum(nil)
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := []string{}
+	for k := range query {
+		keys = append(keys, k)
+	}
+
+	slices.Sort(keys)
+
+	list := []string{}
+	for _, k := range keys {
+		values := slices.Clone(query[k])
+		slices.Sort(values)
+		for _, v := range values {
+			list = append(list, encode(k, false)+"="+encode(v, false))
+		}
+	}
+
+	return strings.Join(list, "&")
+}
+
+// URI encodes a string as specified for AWS Signature Version 4 i.e. everything except the
+// RFC 3986 unreserved characters (and optionally '/') is percent encoded.
+func encode(s string, slash bool) string {
+	var b strings.Builder
+
+	for _, c := range []byte(s) {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+
+		case c == '/' && slash:
+			b.WriteByte(c)
+
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
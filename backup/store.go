@@ -0,0 +1,109 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Store is a destination for scheduled backups.
+type Store interface {
+	Put(name string, archive []byte) error
+	List() ([]string, error)
+	Delete(name string) error
+	String() string
+}
+
+// Dir stores backup archives in a local folder.
+type Dir struct {
+	Path string
+}
+
+func (d Dir) Put(name string, archive []byte) error {
+	if err := os.MkdirAll(d.Path, 0700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(d.Path, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(archive); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(d.Path, name))
+}
+
+func (d Dir) List() ([]string, error) {
+	files, err := os.ReadDir(d.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	list := []string{}
+	for _, f := range files {
+		if f.Type().IsRegular() {
+			list = append(list, f.Name())
+		}
+	}
+
+	return list, nil
+}
+
+func (d Dir) Delete(name string) error {
+	return os.Remove(filepath.Join(d.Path, name))
+}
+
+func (d Dir) String() string {
+	return d.Path
+}
+
+// Rotate deletes all but the 'keep' most recent archives for the application from a store. The
+// archive names include the UTC creation time so the oldest archives sort first.
+func Rotate(store Store, application string, keep int) ([]string, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+
+	list, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	archives := []string{}
+	for _, name := range list {
+		if strings.HasPrefix(name, application+"-") && strings.HasSuffix(name, ".tar.gz") {
+			archives = append(archives, name)
+		}
+	}
+
+	slices.Sort(archives)
+
+	deleted := []string{}
+	for len(archives) > keep {
+		if err := store.Delete(archives[0]); err != nil {
+			return deleted, fmt.Errorf("error deleting %v from %v (%v)", archives[0], store, err)
+		}
+
+		deleted = append(deleted, archives[0])
+		archives = archives[1:]
+	}
+
+	return deleted, nil
+}
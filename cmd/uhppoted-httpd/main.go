@@ -13,6 +13,10 @@ import (
 var cli = []uhppoted.Command{
 	&commands.DAEMONIZE,
 	&commands.UNDAEMONIZE,
+	&commands.CERTIFICATES,
+	&commands.VERIFY_AUDIT,
+	&commands.BACKUP,
+	&commands.RESTORE,
 	&uhppoted.Version{
 		Application: commands.SERVICE,
 		Version:     uhppote.VERSION,
@@ -2,6 +2,7 @@ package commands
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -11,6 +12,8 @@ import (
 	"log"
 	"math/big"
 	"net"
+	"os"
+	"path/filepath"
 	"time"
 )
 
@@ -193,3 +196,83 @@ func encode(p any) []byte {
 
 	return b.Bytes()
 }
+
+// Creates a certificate for the public key signed by the CA key, with a random serial number and
+// a validity period starting now.
+func sign(template *x509.Certificate, key crypto.PublicKey, CA *x509.Certificate, signer crypto.Signer, days int) ([]byte, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	template.SerialNumber = serial
+	template.NotBefore = time.Now().Add(-5 * time.Minute)
+	template.NotAfter = time.Now().AddDate(0, 0, days)
+
+	if CA == nil {
+		CA = template
+	}
+
+	return x509.CreateCertificate(rand.Reader, template, CA, key, signer)
+}
+
+func readKey(file string) (crypto.Signer, error) {
+	blob, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(blob)
+	if block == nil {
+		return nil, fmt.Errorf("invalid key file %v", file)
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+
+	case "PRIVATE KEY":
+		if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+			return nil, err
+		} else if signer, ok := key.(crypto.Signer); !ok {
+			return nil, fmt.Errorf("unsupported key type in %v (%T)", file, key)
+		} else {
+			return signer, nil
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported key type in %v (%v)", file, block.Type)
+	}
+}
+
+func readCertificate(file string) (*x509.Certificate, error) {
+	blob, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(blob)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("invalid certificate file %v", file)
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// Writes a PEM encoded key or certificate to a temporary file and then renames it, so that a
+// running server never sees a partially written file.
+func writePEM(file string, v any, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0770); err != nil {
+		return err
+	}
+
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, encode(v), mode); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, file)
+}
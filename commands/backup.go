@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/uhppoted/uhppoted-lib/config"
+	"github.com/uhppoted/uhppoted-lib/lockfile"
+
+	"github.com/uhppoted/uhppoted-httpd/settings"
+	"github.com/uhppoted/uhppoted-httpd/system"
+)
+
+var BACKUP = Backup{}
+
+// Backup creates a backup archive of the system files, auth DB, grules files and audit trail.
+// The service lockfile is held while the archive is created so that the backup is consistent.
+type Backup struct {
+	config   string
+	file     string
+	lockfile string
+}
+
+func (cmd *Backup) Name() string {
+	return "backup"
+}
+
+func (cmd *Backup) FlagSet() *flag.FlagSet {
+	flagset := flag.NewFlagSet("backup", flag.ExitOnError)
+
+	flagset.StringVar(&cmd.config, "config", RUN.configuration, "Sets the configuration file path")
+	flagset.StringVar(&cmd.file, "file", "", "Backup archive file. Defaults to uhppoted-httpd-<timestamp>.tar.gz in the current folder")
+	flagset.StringVar(&cmd.lockfile, "lockfile", "", fmt.Sprintf("Service lockfile. Defaults to %v", filepath.Join(os.TempDir(), fmt.Sprintf("%s.pid", SERVICE))))
+
+	return flagset
+}
+
+func (cmd *Backup) Description() string {
+	return "Creates a backup archive of the system configuration"
+}
+
+func (cmd *Backup) Usage() string {
+	return "backup [--config <file>] [--file <file>] [--lockfile <file>]"
+}
+
+func (cmd *Backup) Help() {
+	fmt.Println()
+	fmt.Printf("  Usage: %s backup [--config <file>] [--file <file>] [--lockfile <file>]\n", SERVICE)
+	fmt.Println()
+	fmt.Println("    Creates a versioned backup archive of the system files, auth DB, grules files and audit trail.")
+	fmt.Println("    The service lockfile is held while the archive is created and the command fails if the service")
+	fmt.Println("    is running - use the /backup endpoint to back up a running system.")
+	fmt.Println()
+
+	helpOptions(cmd.FlagSet())
+}
+
+func (cmd *Backup) Execute(args ...any) error {
+	conf := config.NewConfig()
+	if err := conf.Load(cmd.config); err != nil {
+		fmt.Printf("   ... WARNING: could not load configuration (%v)\n", err)
+	}
+
+	s := settings.NewSettings()
+	if err := s.Load(cmd.config); err != nil {
+		fmt.Printf("   ... WARNING: could not load uhppoted-httpd settings (%v)\n", err)
+	}
+
+	lock, err := lock(cmd.lockfile, conf.LockfileRemove)
+	if err != nil {
+		return fmt.Errorf("%v - use the /backup endpoint to back up a running system", err)
+	}
+
+	defer lock.Release()
+
+	var b bytes.Buffer
+	manifest, err := system.Snapshot(*conf, s, &b)
+	if err != nil {
+		return err
+	}
+
+	file := fallback(cmd.file, system.BackupFile(time.Now()))
+	if err := os.WriteFile(file, b.Bytes(), 0600); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	for _, f := range manifest.Files {
+		fmt.Printf("   ... %-32v %v bytes\n", f.Name, f.Size)
+	}
+
+	fmt.Println()
+	fmt.Printf("   created backup archive %v\n", file)
+	fmt.Println()
+
+	return nil
+}
+
+// Acquires the service lockfile, which fails if the service is running.
+func lock(file string, remove bool) (lockfile.Lockfile, error) {
+	lockFile := config.Lockfile{
+		File:   file,
+		Remove: remove,
+	}
+
+	if lockFile.File == "" {
+		lockFile.File = filepath.Join(os.TempDir(), fmt.Sprintf("%s.pid", SERVICE))
+	}
+
+	return lockfile.MakeLockFile(lockFile)
+}
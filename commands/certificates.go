@@ -0,0 +1,404 @@
+package commands
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/uhppoted/uhppoted-lib/config"
+
+	"github.com/uhppoted/uhppoted-httpd/auth/mtls"
+)
+
+var CERTIFICATES = Certificates{}
+
+// Certificates creates and renews the local CA, the HTTPS server certificate and per-user TLS
+// client certificates. The CA and server files are the httpd.tls files in the configuration.
+type Certificates struct {
+	command string
+	config  string
+	caKey   string
+	out     string
+	uid     string
+	cn      string
+	email   string
+	dns     string
+	ip      string
+	days    int
+	renew   bool
+}
+
+func (cmd *Certificates) Name() string {
+	return "certificates"
+}
+
+func (cmd *Certificates) FlagSet() *flag.FlagSet {
+	flagset := flag.NewFlagSet("certificates", flag.ExitOnError)
+
+	flagset.StringVar(&cmd.config, "config", RUN.configuration, "Sets the configuration file path")
+	flagset.StringVar(&cmd.caKey, "ca-key", "", "CA key file. Defaults to ca.key in the CA certificate folder")
+	flagset.StringVar(&cmd.cn, "cn", "", "Certificate common name. Defaults to uhppoted-httpd-CA, the host name or the user ID")
+	flagset.StringVar(&cmd.dns, "dns", "", "Comma separated DNS names for a server certificate")
+	flagset.StringVar(&cmd.ip, "ip", "", "Comma separated IP addresses for a server certificate")
+	flagset.StringVar(&cmd.uid, "uid", "", "User ID for a client certificate")
+	flagset.StringVar(&cmd.email, "email", "", "Email address for a client certificate")
+	flagset.StringVar(&cmd.out, "out", "", "Folder for client keys and certificates. Defaults to the 'clients' subfolder of the CA certificate folder")
+	flagset.IntVar(&cmd.days, "days", 0, "Certificate validity (days). Defaults to 3650 for the CA and 397 for server and client certificates")
+	flagset.BoolVar(&cmd.renew, "renew", false, "Renews an existing certificate with the existing key, subject and alternative names")
+
+	return flagset
+}
+
+func (cmd *Certificates) ParseCmd(args ...string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing certificates command (ca, server or client)")
+	}
+
+	switch args[0] {
+	case "ca", "server", "client":
+		cmd.command = args[0]
+
+	default:
+		return fmt.Errorf("invalid certificates command '%v' (expected ca, server or client)", args[0])
+	}
+
+	return cmd.FlagSet().Parse(args[1:])
+}
+
+func (cmd *Certificates) Description() string {
+	return "Creates and renews the local CA, server and client TLS certificates"
+}
+
+func (cmd *Certificates) Usage() string {
+	return "certificates ca|server|client [--config <file>] [--renew] [--days <days>] ..."
+}
+
+func (cmd *Certificates) Help() {
+	fmt.Println()
+	fmt.Printf("  Usage: %s certificates ca     [--config <file>] [--ca-key <file>] [--cn <name>] [--days <days>] [--renew]\n", SERVICE)
+	fmt.Printf("         %s certificates server [--config <file>] [--ca-key <file>] [--cn <name>] [--dns <names>] [--ip <addresses>] [--days <days>] [--renew]\n", SERVICE)
+	fmt.Printf("         %s certificates client --uid <uid> [--config <file>] [--ca-key <file>] [--cn <name>] [--email <address>] [--out <folder>] [--days <days>] [--renew]\n", SERVICE)
+	fmt.Println()
+	fmt.Println("    Creates (or renews) the local CA, the HTTPS server certificate and per-user TLS client certificates.")
+	fmt.Println("    The CA and server key and certificate files are the httpd.tls.ca, httpd.tls.certificate and")
+	fmt.Println("    httpd.tls.key files in the configuration. A running server reloads renewed certificates")
+	fmt.Println("    automatically.")
+	fmt.Println()
+
+	helpOptions(cmd.FlagSet())
+}
+
+func (cmd *Certificates) Execute(args ...any) error {
+	conf := config.NewConfig()
+	if err := conf.Load(cmd.config); err != nil {
+		fmt.Printf("   ... WARNING: could not load configuration (%v)\n", err)
+	}
+
+	switch cmd.command {
+	case "ca":
+		return cmd.ca(conf)
+
+	case "server":
+		return cmd.server(conf)
+
+	case "client":
+		return cmd.client(conf)
+
+	default:
+		return fmt.Errorf("invalid certificates command '%v'", cmd.command)
+	}
+}
+
+func (cmd *Certificates) ca(conf *config.Config) error {
+	certfile := conf.HTTPD.CACertificate
+	keyfile := cmd.caKeyFile(conf)
+	template := x509.Certificate{
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+
+	var key crypto.Signer
+
+	if cmd.renew {
+		k, err := readKey(keyfile)
+		if err != nil {
+			return err
+		}
+
+		cert, err := readCertificate(certfile)
+		if err != nil {
+			return err
+		}
+
+		key = k
+		template.Subject = cert.Subject
+		template.SubjectKeyId = cert.SubjectKeyId
+	} else if err := missing(keyfile, certfile); err != nil {
+		return err
+	} else if k, err := rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		return err
+	} else {
+		key = k
+		template.Subject = pkix.Name{
+			Organization: []string{"uhppoted"},
+			CommonName:   fallback(cmd.cn, "uhppoted-httpd-CA"),
+		}
+	}
+
+	der, err := sign(&template, key.Public(), nil, key, validity(cmd.days, 3650))
+	if err != nil {
+		return err
+	}
+
+	return cmd.write(keyfile, key, certfile, der)
+}
+
+func (cmd *Certificates) server(conf *config.Config) error {
+	certfile := conf.HTTPD.TLSCertificate
+	keyfile := conf.HTTPD.TLSKey
+	template := x509.Certificate{
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	CA, signer, err := cmd.loadCA(conf)
+	if err != nil {
+		return err
+	}
+
+	var key crypto.Signer
+
+	if cmd.renew {
+		k, err := readKey(keyfile)
+		if err != nil {
+			return err
+		}
+
+		cert, err := readCertificate(certfile)
+		if err != nil {
+			return err
+		}
+
+		key = k
+		template.Subject = cert.Subject
+		template.DNSNames = cert.DNSNames
+		template.IPAddresses = cert.IPAddresses
+	} else if err := missing(keyfile, certfile); err != nil {
+		return err
+	} else if k, err := rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		return err
+	} else {
+		hostname, _ := os.Hostname()
+
+		key = k
+		template.Subject = pkix.Name{
+			Organization: []string{"uhppoted"},
+			CommonName:   fallback(cmd.cn, hostname, "localhost"),
+		}
+
+		template.DNSNames = []string{"localhost"}
+		if hostname != "" && hostname != "localhost" {
+			template.DNSNames = append(template.DNSNames, hostname)
+		}
+
+		template.IPAddresses = []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	}
+
+	if cmd.dns != "" {
+		template.DNSNames = split(cmd.dns)
+	}
+
+	if cmd.ip != "" {
+		template.IPAddresses = []net.IP{}
+		for _, v := range split(cmd.ip) {
+			if ip := net.ParseIP(v); ip == nil {
+				return fmt.Errorf("invalid IP address '%v'", v)
+			} else {
+				template.IPAddresses = append(template.IPAddresses, ip)
+			}
+		}
+	}
+
+	der, err := sign(&template, key.Public(), CA, signer, validity(cmd.days, 397))
+	if err != nil {
+		return err
+	}
+
+	return cmd.write(keyfile, key, certfile, der)
+}
+
+func (cmd *Certificates) client(conf *config.Config) error {
+	uid := strings.TrimSpace(cmd.uid)
+	if uid == "" {
+		return fmt.Errorf("missing user ID (--uid)")
+	} else if filepath.Base(uid) != uid {
+		return fmt.Errorf("invalid user ID '%v'", uid)
+	}
+
+	dir := cmd.out
+	if dir == "" {
+		dir = filepath.Join(filepath.Dir(conf.HTTPD.CACertificate), "clients")
+	}
+
+	certfile := filepath.Join(dir, uid+".cert")
+	keyfile := filepath.Join(dir, uid+".key")
+	template := x509.Certificate{
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	CA, signer, err := cmd.loadCA(conf)
+	if err != nil {
+		return err
+	}
+
+	var key crypto.Signer
+
+	if cmd.renew {
+		k, err := readKey(keyfile)
+		if err != nil {
+			return err
+		}
+
+		cert, err := readCertificate(certfile)
+		if err != nil {
+			return err
+		}
+
+		key = k
+		template.Subject = cert.Subject
+		template.EmailAddresses = cert.EmailAddresses
+	} else if err := missing(keyfile, certfile); err != nil {
+		return err
+	} else if k, err := rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		return err
+	} else {
+		key = k
+		template.Subject = pkix.Name{
+			Organization: []string{SERVICE},
+			CommonName:   fallback(cmd.cn, uid),
+		}
+	}
+
+	if cmd.email != "" {
+		template.EmailAddresses = split(cmd.email)
+	}
+
+	der, err := sign(&template, key.Public(), CA, signer, validity(cmd.days, 397))
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.write(keyfile, key, certfile, der); err != nil {
+		return err
+	}
+
+	if cert, err := x509.ParseCertificate(der); err == nil {
+		fmt.Println()
+		fmt.Printf("   To login as %v with the client certificate, add one of the following identities to the\n", uid)
+		fmt.Println("   user 'certificates' in users.json (with httpd.security.mtls.enabled = true):")
+		fmt.Println()
+		for _, v := range mtls.Identities(cert, true, true) {
+			fmt.Printf("       %q\n", v)
+		}
+		fmt.Println()
+		fmt.Println("   To convert the key and certificate to a PKCS12 file for importing into a browser or smartcard:")
+		fmt.Println()
+		fmt.Printf("   openssl pkcs12 -export -in %v -inkey %v -certfile %v -out %v\n", certfile, keyfile, conf.HTTPD.CACertificate, filepath.Join(dir, uid+".p12"))
+		fmt.Println()
+	}
+
+	return nil
+}
+
+func (cmd *Certificates) caKeyFile(conf *config.Config) string {
+	if cmd.caKey != "" {
+		return cmd.caKey
+	}
+
+	return filepath.Join(filepath.Dir(conf.HTTPD.CACertificate), "ca.key")
+}
+
+func (cmd *Certificates) loadCA(conf *config.Config) (*x509.Certificate, crypto.Signer, error) {
+	CA, err := readCertificate(conf.HTTPD.CACertificate)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading CA certificate (%v)", err)
+	}
+
+	key, err := readKey(cmd.caKeyFile(conf))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading CA key (%v)", err)
+	}
+
+	return CA, key, nil
+}
+
+// Writes the certificate and, for a new certificate, the key. The key is written first so that
+// a running server never loads a new certificate with an old key.
+func (cmd *Certificates) write(keyfile string, key crypto.Signer, certfile string, der []byte) error {
+	if !cmd.renew {
+		if err := writePEM(keyfile, key, 0600); err != nil {
+			return err
+		}
+
+		fmt.Printf("   ... created %v\n", keyfile)
+	}
+
+	if err := writePEM(certfile, der, 0644); err != nil {
+		return err
+	}
+
+	if cert, err := x509.ParseCertificate(der); err != nil {
+		return err
+	} else if cmd.renew {
+		fmt.Printf("   ... renewed %v (expires %v)\n", certfile, cert.NotAfter.Format("2006-01-02"))
+	} else {
+		fmt.Printf("   ... created %v (expires %v)\n", certfile, cert.NotAfter.Format("2006-01-02"))
+	}
+
+	return nil
+}
+
+// Returns an error if any of the files already exist.
+func missing(files ...string) error {
+	for _, file := range files {
+		if _, err := os.Stat(file); err == nil {
+			return fmt.Errorf("%v already exists (use --renew to renew the certificate)", file)
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validity(days int, defval int) int {
+	if days > 0 {
+		return days
+	}
+
+	return defval
+}
+
+func fallback(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
+
+func split(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' })
+}
@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestCertificates(t *testing.T) {
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "uhppoted.conf")
+	ca := filepath.Join(dir, "httpd", "ca.cert")
+	server := filepath.Join(dir, "httpd", "uhppoted.cert")
+	client := filepath.Join(dir, "httpd", "clients", "gatehouse.cert")
+
+	if err := os.WriteFile(conf, fmt.Appendf(nil, "httpd.tls.ca = %v\nhttpd.tls.certificate = %v\nhttpd.tls.key = %v\n",
+		ca, server, filepath.Join(dir, "httpd", "uhppoted.key")), 0600); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	execute := func(command string, renew bool, f func(*Certificates)) error {
+		cmd := Certificates{command: command, config: conf, renew: renew}
+		if f != nil {
+			f(&cmd)
+		}
+
+		return cmd.Execute()
+	}
+
+	if err := execute("ca", false, nil); err != nil {
+		t.Fatalf("Error creating CA (%v)", err)
+	}
+
+	if err := execute("server", false, func(cmd *Certificates) { cmd.dns = "localhost,kiosk.local" }); err != nil {
+		t.Fatalf("Error creating server certificate (%v)", err)
+	}
+
+	if err := execute("client", false, func(cmd *Certificates) { cmd.uid = "gatehouse" }); err != nil {
+		t.Fatalf("Error creating client certificate (%v)", err)
+	}
+
+	if err := execute("ca", false, nil); err == nil {
+		t.Errorf("Expected error recreating existing CA")
+	}
+
+	if err := execute("client", false, func(cmd *Certificates) { cmd.uid = "../gatehouse" }); err == nil {
+		t.Errorf("Expected error creating client certificate with invalid user ID")
+	}
+
+	original, err := readCertificate(server)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// ... renew CA and server certificate
+	if err := execute("ca", true, nil); err != nil {
+		t.Fatalf("Error renewing CA (%v)", err)
+	}
+
+	if err := execute("server", true, func(cmd *Certificates) { cmd.days = 30 }); err != nil {
+		t.Fatalf("Error renewing server certificate (%v)", err)
+	}
+
+	CA, err := readCertificate(ca)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(CA)
+
+	renewed, err := readCertificate(server)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if renewed.SerialNumber.Cmp(original.SerialNumber) == 0 {
+		t.Errorf("Expected new serial number for renewed server certificate")
+	}
+
+	if !slices.Equal(renewed.DNSNames, []string{"localhost", "kiosk.local"}) {
+		t.Errorf("Incorrect renewed server certificate DNS names - expected:%v, got:%v", []string{"localhost", "kiosk.local"}, renewed.DNSNames)
+	}
+
+	if _, err := renewed.Verify(x509.VerifyOptions{Roots: roots, DNSName: "kiosk.local"}); err != nil {
+		t.Errorf("Error verifying renewed server certificate (%v)", err)
+	}
+
+	// ... client certificate issued by the original CA certificate is still valid
+	cert, err := readCertificate(client)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if cert.Subject.String() != "CN=gatehouse,O=uhppoted-httpd" {
+		t.Errorf("Incorrect client certificate subject - expected:%v, got:%v", "CN=gatehouse,O=uhppoted-httpd", cert.Subject)
+	}
+
+	if _, err := cert.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}); err != nil {
+		t.Errorf("Error verifying client certificate with renewed CA (%v)", err)
+	}
+}
@@ -34,6 +34,9 @@ const controllers = `{ "controllers": [] }`
 const doors = `{ "doors": [] }`
 const cards = `{ "cards": [] }`
 const groups = `{ "groups": [] }`
+const timeprofiles = `{ "time-profiles": [] }`
+const tasks = `{ "tasks": [] }`
+const taskRuns = `{ "task-runs": [] }`
 const events = `{ "events": [] }`
 const logs = `{ "logs": [] }`
 const users = `{ "users": [] }`
@@ -84,6 +87,14 @@ const default_auth = `
       "path": "^/sys/groups.html$",
       "authorised": "^(admin|user)$"
     },
+    {
+      "path": "^/sys/time-profiles.html$",
+      "authorised": "^(admin|user)$"
+    },
+    {
+      "path": "^/sys/tasks.html$",
+      "authorised": "^(admin|user)$"
+    },
     {
       "path": "^/sys/events.html$",
       "authorised": "^(admin|user)$"
@@ -120,14 +131,38 @@ const default_auth = `
       "path": "^/doors$",
       "authorised": "^(admin)$"
     },
+    {
+      "path": "^/doors/open$",
+      "authorised": "^(admin)$"
+    },
     {
       "path": "^/cards$",
       "authorised": "^(admin|user)$"
     },
+    {
+      "path": "^/cards/export$",
+      "authorised": "^(admin|user)$"
+    },
+    {
+      "path": "^/cards/import$",
+      "authorised": "^(admin)$"
+    },
     {
       "path": "^/groups$",
       "authorised": "^(admin|user)$"
     },
+    {
+      "path": "^/time-profiles$",
+      "authorised": "^(admin|user)$"
+    },
+    {
+      "path": "^/tasks$",
+      "authorised": "^(admin|user)$"
+    },
+    {
+      "path": "^/tasks/runs$",
+      "authorised": "^(admin)$"
+    },
     {
       "path": "^/events$",
       "authorised": "^(admin)$"
@@ -152,9 +187,17 @@ const default_auth = `
       "path": "^/synchronize/doors$",
       "authorised": "^(admin)$"
     },
+    {
+      "path": "^/stream$",
+      "authorised": ".*"
+    },
     {
       "path": "^/otp$",
       "authorised": ".*"
+    },
+    {
+      "path": "^/tokens$",
+      "authorised": ".*"
     }
   ]
 }
@@ -426,6 +469,9 @@ func (cmd *Daemonize) sysinit(i info) error {
 		{"doors.json", doors},
 		{"cards.json", cards},
 		{"groups.json", groups},
+		{"time-profiles.json", timeprofiles},
+		{"tasks.json", tasks},
+		{"task-runs.json", taskRuns},
 		{"events.json", events},
 		{"logs.json", logs},
 		{"users.json", users},
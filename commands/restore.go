@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/uhppoted/uhppoted-lib/config"
+
+	"github.com/uhppoted/uhppoted-httpd/settings"
+	"github.com/uhppoted/uhppoted-httpd/system"
+)
+
+var RESTORE = Restore{}
+
+// Restore validates a backup archive and replaces the system files, auth DB, grules files and
+// (optionally) audit trail with the contents of the archive.
+type Restore struct {
+	config   string
+	file     string
+	audit    bool
+	lockfile string
+}
+
+func (cmd *Restore) Name() string {
+	return "restore"
+}
+
+func (cmd *Restore) FlagSet() *flag.FlagSet {
+	flagset := flag.NewFlagSet("restore", flag.ExitOnError)
+
+	flagset.StringVar(&cmd.config, "config", RUN.configuration, "Sets the configuration file path")
+	flagset.StringVar(&cmd.file, "file", "", "Backup archive file")
+	flagset.BoolVar(&cmd.audit, "audit", false, "Also restores the audit trail files")
+	flagset.StringVar(&cmd.lockfile, "lockfile", "", fmt.Sprintf("Service lockfile. Defaults to %v", filepath.Join(os.TempDir(), fmt.Sprintf("%s.pid", SERVICE))))
+
+	return flagset
+}
+
+func (cmd *Restore) Description() string {
+	return "Restores the system configuration from a backup archive"
+}
+
+func (cmd *Restore) Usage() string {
+	return "restore --file <file> [--config <file>] [--audit] [--lockfile <file>]"
+}
+
+func (cmd *Restore) Help() {
+	fmt.Println()
+	fmt.Printf("  Usage: %s restore --file <file> [--config <file>] [--audit] [--lockfile <file>]\n", SERVICE)
+	fmt.Println()
+	fmt.Println("    Validates a backup archive and then replaces the system files, auth DB and grules files with")
+	fmt.Println("    the contents of the archive. The current system is backed up to the backup folder before any")
+	fmt.Println("    files are replaced. The audit trail files are only restored if --audit is specified.")
+	fmt.Println()
+	fmt.Println("    The service must be stopped before restoring a backup.")
+	fmt.Println()
+
+	helpOptions(cmd.FlagSet())
+}
+
+func (cmd *Restore) Execute(args ...any) error {
+	if cmd.file == "" {
+		return fmt.Errorf("missing backup archive file")
+	}
+
+	conf := config.NewConfig()
+	if err := conf.Load(cmd.config); err != nil {
+		fmt.Printf("   ... WARNING: could not load configuration (%v)\n", err)
+	}
+
+	s := settings.NewSettings()
+	if err := s.Load(cmd.config); err != nil {
+		fmt.Printf("   ... WARNING: could not load uhppoted-httpd settings (%v)\n", err)
+	}
+
+	lock, err := lock(cmd.lockfile, conf.LockfileRemove)
+	if err != nil {
+		return fmt.Errorf("%v - stop the service before restoring a backup", err)
+	}
+
+	defer lock.Release()
+
+	f, err := os.Open(cmd.file)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	restored, err := system.Restore(*conf, s, f, cmd.audit)
+	if err != nil {
+		return fmt.Errorf("backup archive %v not restored (%v)", filepath.Base(cmd.file), err)
+	}
+
+	fmt.Println()
+	fmt.Printf("   backup archive: %v (%v, created %v)\n", filepath.Base(cmd.file), restored.Manifest.Release, restored.Manifest.Created.Local().Format("2006-01-02 15:04:05"))
+	if restored.Backup != "" {
+		fmt.Printf("   previous state: %v\n", restored.Backup)
+	}
+
+	fmt.Println()
+	for _, f := range restored.Files {
+		fmt.Printf("   ... restored %v\n", f)
+	}
+
+	for _, f := range restored.Skipped {
+		fmt.Printf("   ... skipped  %v\n", f)
+	}
+
+	fmt.Println()
+
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/uhppoted/uhppoted-lib/config"
 	"github.com/uhppoted/uhppoted-lib/lockfile"
@@ -12,10 +13,17 @@ import (
 	"github.com/uhppoted/uhppoted-httpd/audit"
 	provider "github.com/uhppoted/uhppoted-httpd/auth"
 	"github.com/uhppoted/uhppoted-httpd/auth/impl"
+	"github.com/uhppoted/uhppoted-httpd/auth/ldap"
+	"github.com/uhppoted/uhppoted-httpd/auth/mtls"
+	"github.com/uhppoted/uhppoted-httpd/auth/oidc"
 	"github.com/uhppoted/uhppoted-httpd/auth/otp"
+	"github.com/uhppoted/uhppoted-httpd/auth/passwords"
+	"github.com/uhppoted/uhppoted-httpd/auth/webauthn"
 	"github.com/uhppoted/uhppoted-httpd/httpd"
 	"github.com/uhppoted/uhppoted-httpd/httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/httpd/throttle"
 	"github.com/uhppoted/uhppoted-httpd/log"
+	"github.com/uhppoted/uhppoted-httpd/settings"
 	"github.com/uhppoted/uhppoted-httpd/system"
 	"github.com/uhppoted/uhppoted-httpd/types"
 )
@@ -25,6 +33,7 @@ type Run struct {
 	mode          string
 	configuration string
 	debug         bool
+	simulate      bool
 	workdir       string
 	lockfile      string
 	logFile       string
@@ -40,6 +49,7 @@ func (r *Run) FlagSet() *flag.FlagSet {
 	flagset.StringVar(&r.configuration, "config", r.configuration, "Sets the configuration file path")
 	flagset.StringVar(&r.lockfile, "lockfile", r.lockfile, fmt.Sprintf("(optional) lockfile used to prevent running multiple copies of the service. Defaults to %v", lockfile))
 	flagset.BoolVar(&r.debug, "debug", false, "Enables detailed debugging logs")
+	flagset.BoolVar(&r.simulate, "simulate", false, "Runs with simulated controllers instead of the LAN (for demos and testing)")
 
 	return flagset
 }
@@ -53,7 +63,7 @@ func (cmd *Run) Description() string {
 }
 
 func (cmd *Run) Usage() string {
-	return "uhppoted-httpd [--debug] [--config <file>] [--logfile <file>] [--logfilesize <bytes>] [--pid <file>]"
+	return "uhppoted-httpd [--debug] [--config <file>] [--logfile <file>] [--logfilesize <bytes>] [--pid <file>] [--simulate]"
 }
 
 func (cmd *Run) Help() {
@@ -122,6 +132,12 @@ func (cmd *Run) execute(f func(c config.Config)) error {
 }
 
 func (cmd *Run) run(conf config.Config, interrupt chan os.Signal) {
+	// ... load uhppoted-httpd specific settings
+	s := settings.NewSettings()
+	if err := s.Load(cmd.configuration); err != nil {
+		log.Warnf("Could not load uhppoted-httpd settings (%v)", err)
+	}
+
 	// ... initialise auth providers
 	var authentication auth.IAuth
 
@@ -129,36 +145,130 @@ func (cmd *Run) run(conf config.Config, interrupt chan os.Signal) {
 	case "none":
 		authentication = auth.NewNoneAuthenticator()
 
-	default:
-		p, err := local.NewAuthProvider(
-			conf.HTTPD.Security.AuthDB,
-			conf.HTTPD.Security.LoginExpiry,
-			conf.HTTPD.Security.SessionExpiry,
-			conf.HTTPD.Security.OTP.Login == "allow",
-			conf.HTTPD.Security.AdminRole)
+	case "oidc":
+		p := newLocalProvider(conf, s)
+
+		roles, err := provider.ParseRoles(s.OIDC.Roles)
 		if err != nil {
-			panic(fmt.Sprintf("Error instantiating auth provider (%v)", err))
+			panic(fmt.Sprintf("Error instantiating OIDC auth provider (%v)", err))
 		}
 
-		authentication, err = auth.NewBasic(p, conf.HTTPD.Security.AuthDB, conf.HTTPD.Security.CookieMaxAge)
+		sso, err := oidc.NewProvider(p, oidc.Config{
+			Issuer:       s.OIDC.Issuer,
+			ClientID:     s.OIDC.ClientID,
+			ClientSecret: s.OIDC.ClientSecret,
+			RedirectURL:  s.OIDC.RedirectURL,
+			Scopes:       strings.FieldsFunc(s.OIDC.Scopes, func(r rune) bool { return r == ',' || r == ' ' }),
+			UIDClaim:     s.OIDC.UIDClaim,
+			RoleClaim:    s.OIDC.RoleClaim,
+			Roles:        roles,
+			Provision:    s.OIDC.Provisioning == settings.ProvisioningAuto,
+			LocalLogin:   s.OIDC.LocalLogin,
+		})
+		if err != nil {
+			panic(fmt.Sprintf("Error instantiating OIDC auth provider (%v)", err))
+		}
+
+		authentication, err = auth.NewSSO(sso, conf.HTTPD.Security.AuthDB, conf.HTTPD.Security.CookieMaxAge)
+		if err != nil {
+			panic(fmt.Sprintf("Error instantiating 'SSO' auth provider (%v)", err))
+		}
+
+	case "ldap":
+		p := newLocalProvider(conf, s)
+
+		roles, err := provider.ParseRoles(s.LDAP.Roles)
+		if err != nil {
+			panic(fmt.Sprintf("Error instantiating LDAP auth provider (%v)", err))
+		}
+
+		directory, err := ldap.NewProvider(p, ldap.Config{
+			URL:            s.LDAP.URL,
+			StartTLS:       s.LDAP.StartTLS,
+			BindDN:         s.LDAP.BindDN,
+			BindPassword:   s.LDAP.BindPassword,
+			BaseDN:         s.LDAP.BaseDN,
+			UserFilter:     s.LDAP.UserFilter,
+			NameAttribute:  s.LDAP.NameAttribute,
+			GroupAttribute: s.LDAP.GroupAttribute,
+			AdminGroup:     s.LDAP.AdminGroup,
+			Roles:          roles,
+			Fallback:       strings.FieldsFunc(s.LDAP.Fallback, func(r rune) bool { return r == ',' || r == ' ' }),
+			Timeout:        s.LDAP.Timeout,
+		})
+		if err != nil {
+			panic(fmt.Sprintf("Error instantiating LDAP auth provider (%v)", err))
+		}
+
+		authentication, err = auth.NewBasic(directory, conf.HTTPD.Security.AuthDB, conf.HTTPD.Security.CookieMaxAge)
 		if err != nil {
 			panic(fmt.Sprintf("Error instantiating 'basic' auth provider (%v)", err))
 		}
+
+	default:
+		p := newLocalProvider(conf, s)
+
+		if basic, err := auth.NewBasic(p, conf.HTTPD.Security.AuthDB, conf.HTTPD.Security.CookieMaxAge); err != nil {
+			panic(fmt.Sprintf("Error instantiating 'basic' auth provider (%v)", err))
+		} else {
+			authentication = basic
+		}
 	}
 
-	if err := audit.SetAuditFile(conf.HTTPD.Audit.File); err != nil {
+	// ... initialise passkeys
+	var passkeys *webauthn.WebAuthn
+
+	if s.WebAuthn.RPID != "" && conf.HTTPD.Security.Auth != "none" {
+		if p, err := webauthn.NewWebAuthn(webauthn.Config{
+			RPID:         s.WebAuthn.RPID,
+			RPName:       s.WebAuthn.RPName,
+			Origins:      strings.FieldsFunc(s.WebAuthn.Origins, func(r rune) bool { return r == ',' || r == ' ' }),
+			Passwordless: s.WebAuthn.Passwordless,
+			SecondFactor: s.WebAuthn.SecondFactor,
+		}); err != nil {
+			panic(fmt.Sprintf("Error instantiating WebAuthn (%v)", err))
+		} else {
+			passkeys = p
+		}
+	}
+
+	// ... initialise client certificate logins
+	var certificates *mtls.MTLS
+
+	if s.MTLS.Enabled && conf.HTTPD.Security.Auth != "none" {
+		if !conf.HTTPD.HttpsEnabled {
+			log.Warnf("Client certificate logins require HTTPS")
+		}
+
+		if p, err := mtls.NewMTLS(mtls.Config{
+			CRL:        s.MTLS.CRL,
+			Identities: strings.FieldsFunc(s.MTLS.Identities, func(r rune) bool { return r == ',' || r == ' ' }),
+		}); err != nil {
+			panic(fmt.Sprintf("Error instantiating client certificate login (%v)", err))
+		} else {
+			certificates = p
+		}
+	}
+
+	if err := audit.SetAuditFile(conf.HTTPD.Audit.File, audit.Config{
+		Key:         s.Audit.Key,
+		Checkpoints: s.Audit.Checkpoint.Records,
+		Interval:    s.Audit.Checkpoint.Interval,
+	}); err != nil {
 		panic(err)
 	}
 
 	ruleset := map[provider.RuleSet]string{
-		provider.Interfaces:  conf.HTTPD.DB.Rules.Interfaces,
-		provider.Controllers: conf.HTTPD.DB.Rules.Controllers,
-		provider.Doors:       conf.HTTPD.DB.Rules.Doors,
-		provider.Cards:       conf.HTTPD.DB.Rules.Cards,
-		provider.Groups:      conf.HTTPD.DB.Rules.Groups,
-		provider.Events:      conf.HTTPD.DB.Rules.Events,
-		provider.Logs:        conf.HTTPD.DB.Rules.Logs,
-		provider.Users:       conf.HTTPD.DB.Rules.Users,
+		provider.Interfaces:   conf.HTTPD.DB.Rules.Interfaces,
+		provider.Controllers:  conf.HTTPD.DB.Rules.Controllers,
+		provider.Doors:        conf.HTTPD.DB.Rules.Doors,
+		provider.Cards:        conf.HTTPD.DB.Rules.Cards,
+		provider.Groups:       conf.HTTPD.DB.Rules.Groups,
+		provider.Events:       conf.HTTPD.DB.Rules.Events,
+		provider.Logs:         conf.HTTPD.DB.Rules.Logs,
+		provider.Users:        conf.HTTPD.DB.Rules.Users,
+		provider.TimeProfiles: s.DB.Rules.TimeProfiles,
+		provider.Tasks:        s.DB.Rules.Tasks,
 	}
 
 	provider.Init(ruleset, conf.HTTPD.Security.AdminRole)
@@ -167,11 +277,33 @@ func (cmd *Run) run(conf config.Config, interrupt chan os.Signal) {
 
 	otp.SetIssuer(conf.Security.OTP.Issuer)
 
+	// ... initialise password hashing
+
+	if err := passwords.SetParameters(passwords.Parameters{
+		Algorithm:   s.Passwords.Algorithm,
+		Memory:      s.Passwords.Argon2id.Memory,
+		Iterations:  s.Passwords.Argon2id.Iterations,
+		Parallelism: s.Passwords.Argon2id.Parallelism,
+		Cost:        s.Passwords.Bcrypt.Cost,
+	}); err != nil {
+		panic(fmt.Sprintf("Error initialising password hashing (%v)", err))
+	}
+
+	// ... initialise account lockout
+
+	system.SetLockout(s.Lockout.Attempts, s.Lockout.Cooldown)
+
 	// ... initialise default card start/end dates
 
 	system.SetDefaultCardStartDate(conf.HTTPD.Cards.DefaultStartDate)
 	system.SetDefaultCardEndDate(conf.HTTPD.Cards.DefaultEndDate)
 
+	// ... simulation mode
+
+	if cmd.simulate {
+		system.Simulate(s.Simulator.Controllers, s.Simulator.Swipes)
+	}
+
 	// ... run
 	h := httpd.HTTPD{
 		HTML:                     conf.HTTPD.HTML,
@@ -180,6 +312,9 @@ func (cmd *Run) run(conf config.Config, interrupt chan os.Signal) {
 		HttpPort:                 conf.HTTPD.HttpPort,
 		HttpsPort:                conf.HTTPD.HttpsPort,
 		AuthProvider:             authentication,
+		WebAuthn:                 passkeys,
+		MTLS:                     certificates,
+		LoginThrottle:            throttle.NewThrottle(s.Throttle.Attempts, s.Throttle.Delay, s.Throttle.MaxDelay),
 		CACertificate:            conf.HTTPD.CACertificate,
 		TLSCertificate:           conf.HTTPD.TLSCertificate,
 		TLSKey:                   conf.HTTPD.TLSKey,
@@ -189,13 +324,29 @@ func (cmd *Run) run(conf config.Config, interrupt chan os.Signal) {
 
 	runMode := types.ParseRunMode(cmd.mode)
 
-	if err := system.Init(conf, cmd.configuration, runMode, cmd.debug); err != nil {
+	if err := system.Init(conf, s, cmd.configuration, runMode, cmd.debug); err != nil {
 		panic(fmt.Errorf("could not load system configuration (%v)", err))
 	}
 
 	h.Run(runMode, conf.HTTPD.PIN.Enabled, conf.HTTPD.Security.NoSetup, interrupt)
 }
 
+func newLocalProvider(conf config.Config, s *settings.Settings) *local.Local {
+	p, err := local.NewAuthProvider(
+		conf.HTTPD.Security.AuthDB,
+		conf.HTTPD.Security.LoginExpiry,
+		conf.HTTPD.Security.SessionExpiry,
+		conf.HTTPD.Security.OTP.Login == "allow",
+		conf.HTTPD.Security.AdminRole)
+	if err != nil {
+		panic(fmt.Sprintf("Error instantiating auth provider (%v)", err))
+	}
+
+	p.SetSessionLimits(s.Sessions.MaxSessions, s.Sessions.Lifetime, s.Sessions.IdleTime)
+
+	return p
+}
+
 func cleanup(cfg config.Config) {
 	folders := map[string]struct{}{}
 	files := []string{
@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"github.com/uhppoted/uhppoted-lib/config"
+
+	"github.com/uhppoted/uhppoted-httpd/audit"
+	"github.com/uhppoted/uhppoted-httpd/settings"
+)
+
+var VERIFY_AUDIT = VerifyAudit{}
+
+// VerifyAudit checks the hash chain and signed checkpoints of the audit trail file and any rotated
+// audit trail files for removed, reordered or edited entries.
+type VerifyAudit struct {
+	config string
+	file   string
+	key    string
+}
+
+func (cmd *VerifyAudit) Name() string {
+	return "verify-audit"
+}
+
+func (cmd *VerifyAudit) FlagSet() *flag.FlagSet {
+	flagset := flag.NewFlagSet("verify-audit", flag.ExitOnError)
+
+	flagset.StringVar(&cmd.config, "config", RUN.configuration, "Sets the configuration file path")
+	flagset.StringVar(&cmd.file, "file", "", "Audit trail file. Defaults to httpd.audit.file in the configuration")
+	flagset.StringVar(&cmd.key, "key", "", "Checkpoint public key file. Defaults to the .pub file for httpd.audit.key in the configuration")
+
+	return flagset
+}
+
+func (cmd *VerifyAudit) Description() string {
+	return "Verifies that the audit trail has not been modified"
+}
+
+func (cmd *VerifyAudit) Usage() string {
+	return "verify-audit [--config <file>] [--file <file>] [--key <file>]"
+}
+
+func (cmd *VerifyAudit) Help() {
+	fmt.Println()
+	fmt.Printf("  Usage: %s verify-audit [--config <file>] [--file <file>] [--key <file>]\n", SERVICE)
+	fmt.Println()
+	fmt.Println("    Verifies the hash chain and signed checkpoints of the audit trail file and any rotated audit")
+	fmt.Println("    trail files, reporting removed, reordered and edited entries. The key is the public key for")
+	fmt.Println("    the checkpoint signing key - the signing key itself is not accepted.")
+	fmt.Println()
+
+	helpOptions(cmd.FlagSet())
+}
+
+func (cmd *VerifyAudit) Execute(args ...any) error {
+	conf := config.NewConfig()
+	if err := conf.Load(cmd.config); err != nil {
+		fmt.Printf("   ... WARNING: could not load configuration (%v)\n", err)
+	}
+
+	s := settings.NewSettings()
+	if err := s.Load(cmd.config); err != nil {
+		fmt.Printf("   ... WARNING: could not load uhppoted-httpd settings (%v)\n", err)
+	}
+
+	file := fallback(cmd.file, conf.HTTPD.Audit.File)
+	keyfile := cmd.key
+
+	if keyfile == "" {
+		if signing, err := audit.KeyFile(file, s.Audit.Key); err != nil {
+			return err
+		} else {
+			keyfile = audit.PublicKeyFile(signing)
+		}
+	}
+
+	key, err := audit.LoadPublicKey(keyfile)
+	if err != nil {
+		return err
+	}
+
+	report, err := audit.Verify(file, key, s.Audit.Checkpoint.Records, s.Audit.Checkpoint.Interval)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	for _, f := range report.Files {
+		fmt.Printf("   ... verified %v\n", f)
+	}
+
+	for _, f := range report.Skipped {
+		fmt.Printf("   ... skipped  %v (not a hash-chained audit trail file)\n", f)
+	}
+
+	fmt.Println()
+	fmt.Printf("   entries:     %v-%v\n", report.First, report.Last)
+	fmt.Printf("   records:     %v\n", report.Records)
+	fmt.Printf("   checkpoints: %v (last signed entry %v)\n", report.Checkpoints, report.Signed)
+
+	if len(report.Warnings) > 0 {
+		fmt.Println()
+		for _, w := range report.Warnings {
+			fmt.Printf("   WARNING %v\n", w)
+		}
+	}
+
+	if !report.Valid() {
+		fmt.Println()
+		for _, issue := range report.Issues {
+			fmt.Printf("   ERROR   %v\n", issue)
+		}
+
+		fmt.Println()
+		return fmt.Errorf("audit trail %v failed verification (%v issues)", filepath.Base(file), len(report.Issues))
+	}
+
+	fmt.Println()
+	fmt.Printf("   audit trail %v verified\n", filepath.Base(file))
+	fmt.Println()
+
+	return nil
+}
@@ -2,8 +2,10 @@ package auth
 
 import (
 	"fmt"
+	"net"
 	"net/http"
 
+	"github.com/uhppoted/uhppoted-httpd/auth"
 	"github.com/uhppoted/uhppoted-httpd/log"
 )
 
@@ -17,7 +19,10 @@ type options struct {
 type IAuth interface {
 	Preauthenticate() (*http.Cookie, error)
 	Authenticate(uid, pwd string, cookie *http.Cookie) (*http.Cookie, error)
-	Authenticated(cookie *http.Cookie) (string, string, *http.Cookie, error)
+	Credentials(uid, pwd string, cookie *http.Cookie) (string, error)
+	Authenticated(cookie *http.Cookie, client auth.Client) (string, string, *http.Cookie, error)
+	AuthenticatedToken(token string) (string, string, []string, error)
+	Session(uid, role string) (*http.Cookie, error)
 	Authorised(uid, role, path string) error
 	Logout(cookie *http.Cookie)
 
@@ -28,6 +33,19 @@ type IAuth interface {
 	AdminRole() string
 }
 
+// Returns the remote address and user agent of the client for a request.
+func Client(r *http.Request) auth.Client {
+	address := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		address = host
+	}
+
+	return auth.Client{
+		Address:   address,
+		UserAgent: r.UserAgent(),
+	}
+}
+
 func warnf(subsystem string, format string, args ...any) {
 	if subsystem == "" {
 		log.Warnf("%v", args...)
@@ -94,8 +94,24 @@ func (b *Basic) Authenticate(uid, pwd string, cookie *http.Cookie) (*http.Cookie
 	}
 }
 
-func (b *Basic) Authenticated(cookie *http.Cookie) (string, string, *http.Cookie, error) {
-	uid, role, token, err := b.auth.Authenticated(cookie.Value)
+// Verifies the login credentials without issuing a session cookie, returning the user role. Used
+// for a password login that is completed with a second factor.
+func (b *Basic) Credentials(uid, pwd string, cookie *http.Cookie) (string, error) {
+	if cookie == nil {
+		return "", fmt.Errorf("invalid login cookie")
+	}
+
+	if err := b.auth.Verify(auth.Login, cookie.Value); err != nil {
+		return "", err
+	}
+
+	b.auth.Invalidate(auth.Login, cookie.Value)
+
+	return b.auth.Credentials(uid, pwd)
+}
+
+func (b *Basic) Authenticated(cookie *http.Cookie, client auth.Client) (string, string, *http.Cookie, error) {
+	uid, role, token, err := b.auth.Authenticated(cookie.Value, client)
 	if err != nil {
 		return "", "", nil, err
 	}
@@ -117,6 +133,31 @@ func (b *Basic) Authenticated(cookie *http.Cookie) (string, string, *http.Cookie
 	return uid, role, nil, nil
 }
 
+// Issues a session cookie for a user that has been authenticated without a password (e.g. with
+// a passkey).
+func (b *Basic) Session(uid, role string) (*http.Cookie, error) {
+	token, err := b.auth.NewSession(uid, role)
+	if err != nil {
+		return nil, err
+	}
+
+	cookie := http.Cookie{
+		Name:     cookies.SessionCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   b.cookieMaxAge * int(time.Hour.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+		//	Secure:   true,
+	}
+
+	return &cookie, nil
+}
+
+func (b *Basic) AuthenticatedToken(token string) (string, string, []string, error) {
+	return b.auth.AuthenticateToken(token)
+}
+
 func (b *Basic) Verify(uid, pwd string) error {
 	return b.auth.Validate(uid, pwd)
 }
@@ -189,6 +230,14 @@ func (b *Basic) load(file string) error {
 	return b.deserialize(f)
 }
 
+// ValidateAuthDB checks that an auth DB can be loaded i.e. that it is valid JSON and that the
+// resource paths and roles are valid regular expressions.
+func ValidateAuthDB(r io.Reader) error {
+	b := Basic{}
+
+	return b.deserialize(r)
+}
+
 func (b *Basic) deserialize(r io.Reader) error {
 	bytes, err := io.ReadAll(r)
 	if err != nil {
@@ -2,6 +2,8 @@ package auth
 
 import (
 	"net/http"
+
+	"github.com/uhppoted/uhppoted-httpd/auth"
 )
 
 type None struct {
@@ -19,10 +21,22 @@ func (n None) Authenticate(uid, pwd string, cookie *http.Cookie) (*http.Cookie,
 	return nil, nil
 }
 
-func (n None) Authenticated(cookie *http.Cookie) (string, string, *http.Cookie, error) {
+func (n None) Credentials(uid, pwd string, cookie *http.Cookie) (string, error) {
+	return "-", nil
+}
+
+func (n None) Authenticated(cookie *http.Cookie, client auth.Client) (string, string, *http.Cookie, error) {
 	return "-", "-", nil, nil
 }
 
+func (n None) Session(uid, role string) (*http.Cookie, error) {
+	return nil, nil
+}
+
+func (n None) AuthenticatedToken(token string) (string, string, []string, error) {
+	return "-", "-", []string{"*"}, nil
+}
+
 func (n *None) Authorised(uid, role, path string) error {
 	return nil
 }
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/httpd/cookies"
+)
+
+// ISSO is implemented by authenticators that support single sign-on logins via an external
+// identity provider.
+type ISSO interface {
+	Login() (string, *http.Cookie, error)
+	Callback(ctx context.Context, cookie *http.Cookie, state, code string) (*http.Cookie, error)
+}
+
+// SSO extends the Basic authenticator with an external identity provider login. Sessions are
+// managed by the Basic authenticator.
+type SSO struct {
+	*Basic
+	sso auth.ISSO
+}
+
+func NewSSO(p auth.ISSO, file string, cookieMaxAge int) (*SSO, error) {
+	basic, err := NewBasic(p, file, cookieMaxAge)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SSO{
+		Basic: basic,
+		sso:   p,
+	}, nil
+}
+
+// Returns the identity provider login URL and a cookie binding the authorization request state
+// to the browser.
+//
+// NTS: the state cookie has to be SameSite=Lax because the identity provider redirect to the
+//
+//	callback is a cross-site navigation
+func (s *SSO) Login() (string, *http.Cookie, error) {
+	url, state, err := s.sso.Login()
+	if err != nil {
+		return "", nil, err
+	}
+
+	cookie := http.Cookie{
+		Name:     cookies.OIDCCookie,
+		Value:    state,
+		Path:     "/oidc",
+		MaxAge:   int((5 * time.Minute).Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		//	Secure:   true,
+	}
+
+	return url, &cookie, nil
+}
+
+func (s *SSO) Callback(ctx context.Context, cookie *http.Cookie, state, code string) (*http.Cookie, error) {
+	if cookie == nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		return nil, fmt.Errorf("invalid OIDC state")
+	}
+
+	if code == "" {
+		return nil, fmt.Errorf("missing OIDC authorization code")
+	}
+
+	token, err := s.sso.Callback(ctx, state, code)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Cookie{
+		Name:     cookies.SessionCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   s.cookieMaxAge * int(time.Hour.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+		//	Secure:   true,
+	}, nil
+}
@@ -1,6 +1,7 @@
 package cards
 
 import (
+	"fmt"
 	"math"
 	"net/http"
 	"regexp"
@@ -8,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/uhppoted/uhppoted-httpd/system"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
 )
 
 func Get(uid, role string, rq *http.Request) any {
@@ -62,3 +64,55 @@ func Post(uid, role string, body map[string]any) (any, error) {
 		Cards: updated,
 	}, nil
 }
+
+func Export(uid, role string, rq *http.Request) ([]byte, error) {
+	return system.ExportCards(uid, role)
+}
+
+// Imports a cards CSV file. The request body is expected to be a JSON object with the CSV file
+// in the 'csv' field. The changes are only applied if 'confirm' is true, otherwise the response
+// is a 'dry run' list of the cards that would be added, updated and deleted. Cards that are not
+// in the CSV file are only deleted if 'delete-missing' is true.
+func Import(uid, role string, body map[string]any) (any, error) {
+	csv := ""
+	confirm := false
+	deleteMissing := false
+
+	switch v := body["csv"].(type) {
+	case string:
+		csv = v
+	case []string:
+		csv = strings.Join(v, "")
+	default:
+		return nil, fmt.Errorf("missing CSV file")
+	}
+
+	switch v := body["confirm"].(type) {
+	case bool:
+		confirm = v
+	case []string:
+		confirm = len(v) > 0 && strings.TrimSpace(strings.ToLower(v[0])) == "true"
+	}
+
+	switch v := body["delete-missing"].(type) {
+	case bool:
+		deleteMissing = v
+	case []string:
+		deleteMissing = len(v) > 0 && strings.TrimSpace(strings.ToLower(v[0])) == "true"
+	}
+
+	changes, updated, err := system.ImportCards(uid, role, csv, confirm, deleteMissing)
+	if err != nil {
+		return nil, err
+	}
+
+	return struct {
+		Changes   []system.CardChange `json:"changes"`
+		Cards     []schema.Object     `json:"cards,omitempty"`
+		Confirmed bool                `json:"confirmed"`
+	}{
+		Changes:   changes,
+		Cards:     updated,
+		Confirmed: confirm,
+	}, nil
+}
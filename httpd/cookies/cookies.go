@@ -9,6 +9,8 @@ const (
 	LoginCookie    = "uhppoted-httpd-login"
 	SessionCookie  = "uhppoted-httpd-session"
 	OTPCookie      = "uhppoted-httpd-otp"
+	OIDCCookie     = "uhppoted-httpd-oidc"
+	WebAuthnCookie = "uhppoted-httpd-webauthn"
 )
 
 // cf. https://stackoverflow.com/questions/27671061/how-to-delete-cookie
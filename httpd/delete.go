@@ -29,6 +29,15 @@ func (d *dispatcher) delete(w http.ResponseWriter, r *http.Request) {
 	case "/otp":
 		users.RevokeOTP(uid, role, w, r, d.auth)
 
+	case "/tokens":
+		users.RevokeToken(uid, role, w, r, d.auth)
+
+	case "/passkeys":
+		users.RevokePasskey(uid, role, w, r, d.auth)
+
+	case "/sessions":
+		users.RevokeSessions(uid, role, w, r)
+
 	default:
 		http.Error(w, "API not implemented", http.StatusNotImplemented)
 	}
@@ -1,7 +1,11 @@
 package doors
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/uhppoted/uhppoted-httpd/system"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
 )
 
 func Get(uid, role string) any {
@@ -23,3 +27,32 @@ func Post(uid, role string, body map[string]any) (any, error) {
 		}, nil
 	}
 }
+
+func Open(uid, role string, body map[string]any) (any, error) {
+	door := ""
+
+	switch v := body["door"].(type) {
+	case string:
+		door = strings.TrimSpace(v)
+	case []string:
+		if len(v) > 0 {
+			door = strings.TrimSpace(v[0])
+		}
+	}
+
+	if door == "" {
+		return nil, fmt.Errorf("missing door")
+	}
+
+	if err := system.OpenDoor(uid, role, schema.OID(door)); err != nil {
+		return nil, err
+	}
+
+	return struct {
+		Door   string `json:"door"`
+		Opened bool   `json:"opened"`
+	}{
+		Door:   door,
+		Opened: true,
+	}, nil
+}
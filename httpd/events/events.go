@@ -1,18 +1,43 @@
 package events
 
 import (
+	"fmt"
 	"math"
 	"net/http"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/uhppoted/uhppoted-httpd/system"
 )
 
 const GZIP_MINIMUM = 16384
 
+// Query parameters for filtered, sorted and paged event queries. A request without any of
+// these parameters returns the events in the 'range' window (if any).
+var parameters = []string{
+	"from",
+	"to",
+	"device",
+	"door",
+	"card",
+	"card-name",
+	"granted",
+	"type",
+	"reason",
+	"sort",
+	"count",
+	"cursor",
+}
+
 func Get(uid, role string, rq *http.Request) any {
+	for _, p := range parameters {
+		if rq.FormValue(p) != "" {
+			return query(uid, role, rq)
+		}
+	}
+
 	start := 0
 	count := math.MaxInt32
 
@@ -49,3 +74,104 @@ func Get(uid, role string, rq *http.Request) any {
 		Events: system.Events(uid, role, start, count),
 	}
 }
+
+func query(uid, role string, rq *http.Request) any {
+	q := system.EventsQuery{
+		Device:   strings.TrimSpace(rq.FormValue("device")),
+		Door:     strings.TrimSpace(rq.FormValue("door")),
+		CardName: strings.TrimSpace(rq.FormValue("card-name")),
+		Type:     strings.TrimSpace(rq.FormValue("type")),
+		Reason:   strings.TrimSpace(rq.FormValue("reason")),
+		Cursor:   strings.TrimSpace(rq.FormValue("cursor")),
+	}
+
+	if v := rq.FormValue("from"); v != "" {
+		if t, err := parseTime(v); err != nil {
+			return err
+		} else {
+			q.From = t
+		}
+	}
+
+	if v := rq.FormValue("to"); v != "" {
+		if t, err := parseTime(v); err != nil {
+			return err
+		} else {
+			q.To = t
+		}
+	}
+
+	if v := rq.FormValue("card"); v != "" {
+		if card, err := strconv.ParseUint(v, 10, 32); err != nil {
+			return fmt.Errorf("invalid card number '%v'", v)
+		} else {
+			q.Card = uint32(card)
+		}
+	}
+
+	if v := rq.FormValue("granted"); v != "" {
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "granted":
+			granted := true
+			q.Granted = &granted
+
+		case "false", "denied":
+			granted := false
+			q.Granted = &granted
+
+		default:
+			return fmt.Errorf("invalid 'granted' value '%v'", v)
+		}
+	}
+
+	if v := rq.FormValue("sort"); v != "" {
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "asc", "ascending":
+			q.Ascending = true
+
+		case "desc", "descending":
+			q.Ascending = false
+
+		default:
+			return fmt.Errorf("invalid sort order '%v'", v)
+		}
+	}
+
+	if v := rq.FormValue("count"); v != "" {
+		if count, err := strconv.ParseUint(v, 10, 16); err != nil || count == 0 {
+			return fmt.Errorf("invalid count '%v'", v)
+		} else {
+			q.Count = int(count)
+		}
+	}
+
+	objects, cursor, err := system.QueryEvents(uid, role, q)
+	if err != nil {
+		return err
+	}
+
+	return struct {
+		Events any    `json:"events"`
+		Cursor string `json:"cursor,omitempty"`
+	}{
+		Events: objects,
+		Cursor: cursor,
+	}
+}
+
+func parseTime(s string) (time.Time, error) {
+	formats := []string{
+		time.RFC3339,
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04",
+		"2006-01-02",
+	}
+
+	for _, format := range formats {
+		if t, err := time.ParseInLocation(format, strings.TrimSpace(s), time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date/time '%v'", s)
+}
@@ -5,6 +5,7 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"io/fs"
@@ -14,7 +15,11 @@ import (
 	"regexp"
 	"strings"
 	text "text/template"
+	"time"
 
+	core "github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/httpd/cards"
 	"github.com/uhppoted/uhppoted-httpd/httpd/cookies"
 	"github.com/uhppoted/uhppoted-httpd/httpd/users"
 	"github.com/uhppoted/uhppoted-httpd/system"
@@ -40,15 +45,32 @@ func (d *dispatcher) get(w http.ResponseWriter, r *http.Request) {
 	case "/otp":
 		d.generateOTP(w, r)
 		return
+
+	case "/cards/export":
+		d.download(r, w, "cards.csv", "text/csv", cards.Export)
+		return
+
+	case "/backup":
+		d.download(r, w, system.BackupFile(time.Now()), "application/gzip", func(uid, role string, rq *http.Request) ([]byte, error) {
+			return system.Backup(uid, role)
+		})
+		return
+
 	case
 		"/interfaces",
 		"/controllers",
 		"/doors",
 		"/cards",
 		"/groups",
+		"/time-profiles",
+		"/tasks",
+		"/tasks/runs",
 		"/events",
 		"/logs",
-		"/users":
+		"/users",
+		"/tokens",
+		"/passkeys",
+		"/sessions":
 		if handler := d.vtable(path); handler != nil && handler.get != nil {
 			d.fetch(r, w, *handler)
 		}
@@ -68,10 +90,13 @@ func (d *dispatcher) getNoAuth(w http.ResponseWriter, r *http.Request) {
 
 	// ... parse headers, etc
 	acceptsGzip := parseHeader(r)
+	_, sso := d.auth.(auth.ISSO)
 	context := map[string]any{
-		"Theme":   parseSettings(r),
-		"Mode":    d.mode,
-		"WithPIN": d.withPIN,
+		"Theme":    parseSettings(r),
+		"Mode":     d.mode,
+		"WithPIN":  d.withPIN,
+		"SSO":      sso,
+		"Passkeys": d.webauthn != nil && d.webauthn.Passwordless(),
 	}
 
 	// ... normalise path
@@ -198,21 +223,25 @@ func (d *dispatcher) getWithAuth(w http.ResponseWriter, r *http.Request) {
 	// ... good to go
 	acceptsGzip := parseHeader(r)
 	context := map[string]any{
-		"Theme":   parseSettings(r),
-		"Mode":    d.mode,
-		"User":    uid,
-		"Options": options,
-		"WithPIN": d.withPIN,
+		"Theme":    parseSettings(r),
+		"Mode":     d.mode,
+		"User":     uid,
+		"Options":  options,
+		"WithPIN":  d.withPIN,
+		"Passkeys": d.webauthn != nil,
 	}
 
 	authorised := map[string]bool{
-		"/sys/controllers.html": true,
-		"/sys/doors.html":       true,
-		"/sys/cards.html":       true,
-		"/sys/groups.html":      true,
-		"/sys/events.html":      true,
-		"/sys/logs.html":        true,
-		"/sys/users.html":       false,
+		"/sys/controllers.html":   true,
+		"/sys/doors.html":         true,
+		"/sys/cards.html":         true,
+		"/sys/groups.html":        true,
+		"/sys/time-profiles.html": true,
+		"/sys/tasks.html":         true,
+		"/sys/events.html":        true,
+		"/sys/logs.html":          true,
+		"/sys/users.html":         false,
+		"/sys/sessions.html":      false,
 	}
 
 	for path := range authorised {
@@ -228,14 +257,17 @@ func (d *dispatcher) getWithAuth(w http.ResponseWriter, r *http.Request) {
 
 func (d *dispatcher) translate(file string, context map[string]any, authorised map[string]bool, w http.ResponseWriter, acceptsGzip bool) {
 	type nav struct {
-		Overview bool
-		System   bool
-		Doors    bool
-		Cards    bool
-		Groups   bool
-		Events   bool
-		Logs     bool
-		Users    bool
+		Overview     bool
+		System       bool
+		Doors        bool
+		Cards        bool
+		Groups       bool
+		TimeProfiles bool
+		Tasks        bool
+		Events       bool
+		Logs         bool
+		Users        bool
+		Sessions     bool
 	}
 
 	page := map[string]any{}
@@ -287,14 +319,17 @@ func (d *dispatcher) translate(file string, context map[string]any, authorised m
 			}{
 				Page: page,
 				Authorised: nav{
-					Overview: authorised["/sys/overview.html"],
-					System:   authorised["/sys/controllers.html"],
-					Doors:    authorised["/sys/doors.html"],
-					Cards:    authorised["/sys/cards.html"],
-					Groups:   authorised["/sys/groups.html"],
-					Events:   authorised["/sys/events.html"],
-					Logs:     authorised["/sys/logs.html"],
-					Users:    authorised["/sys/users.html"],
+					Overview:     authorised["/sys/overview.html"],
+					System:       authorised["/sys/controllers.html"],
+					Doors:        authorised["/sys/doors.html"],
+					Cards:        authorised["/sys/cards.html"],
+					Groups:       authorised["/sys/groups.html"],
+					TimeProfiles: authorised["/sys/time-profiles.html"],
+					Tasks:        authorised["/sys/tasks.html"],
+					Events:       authorised["/sys/events.html"],
+					Logs:         authorised["/sys/logs.html"],
+					Users:        authorised["/sys/users.html"],
+					Sessions:     authorised["/sys/sessions.html"],
 				},
 			}
 		},
@@ -346,9 +381,11 @@ func (d *dispatcher) fetch(r *http.Request, w http.ResponseWriter, h handler) {
 	}
 
 	// Returns empty JSON object if not authorised for the resource because this request may be
-	// a legitimate part of the user interface.
+	// a legitimate part of the user interface (API clients get a 403 instead).
 	if ok := d.authorised(uid, role, path); !ok {
-		if b, err := json.Marshal(struct{}{}); err != nil {
+		if _, ok := bearer(r); ok {
+			d.unauthorised(r, w)
+		} else if b, err := json.Marshal(struct{}{}); err != nil {
 			http.Error(w, "Error generating response", http.StatusInternalServerError)
 		} else {
 			w.Header().Set("Content-Type", "application/json")
@@ -378,6 +415,12 @@ func (d *dispatcher) fetch(r *http.Request, w http.ResponseWriter, h handler) {
 		return
 	}
 
+	if err, ok := response.(error); ok {
+		warnf("HTTPD", "%v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	b, err := json.Marshal(response)
 	if err != nil {
 		http.Error(w, "Error generating response", http.StatusInternalServerError)
@@ -397,6 +440,59 @@ func (d *dispatcher) fetch(r *http.Request, w http.ResponseWriter, h handler) {
 	}
 }
 
+// Returns the response from f as a file attachment.
+func (d *dispatcher) download(r *http.Request, w http.ResponseWriter, file string, contentType string, f func(uid, role string, rq *http.Request) ([]byte, error)) {
+	path, err := resolve(r.URL)
+	if err != nil {
+		http.Error(w, "invalid URL", http.StatusBadRequest)
+		return
+	}
+
+	uid, role, ok := d.authenticated(r, w)
+	if !ok {
+		d.unauthenticated(r, w)
+		return
+	}
+
+	if ok := d.authorised(uid, role, path); !ok {
+		d.unauthorised(r, w)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(d.context, d.timeout)
+
+	defer cancel()
+
+	var b []byte
+
+	go func() {
+		b, err = f(uid, role, r)
+		cancel()
+	}()
+
+	<-ctx.Done()
+
+	if err := ctx.Err(); err != context.Canceled {
+		warnf("HTTPD", "%v", err)
+		http.Error(w, "Timeout waiting for response from system", http.StatusInternalServerError)
+		return
+	}
+
+	if errors.Is(err, core.ErrUnauthorised) {
+		warnf("HTTPD", "%v", err)
+		http.Error(w, "Not authorised", http.StatusForbidden)
+		return
+	} else if err != nil {
+		warnf("HTTPD", "%v", err)
+		http.Error(w, "Error generating response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%v"`, file))
+	w.Write(b)
+}
+
 func (d *dispatcher) generateOTP(w http.ResponseWriter, r *http.Request) {
 	path, err := resolve(r.URL)
 	if err != nil {
@@ -95,6 +95,11 @@ function add(oid, _record) {
         oid: `${oid}${schema.controllers.endpoint.protocol}`,
         selector: 'td label.protocol input',
       },
+      {
+        suffix: 'interface',
+        oid: `${oid}${schema.controllers.interface}`,
+        selector: 'td select.interface',
+      },
       {
         suffix: 'datetime',
         oid: `${oid}${schema.controllers.datetime.current}`,
@@ -173,6 +178,7 @@ function updateFromDB(oid, record) {
   const deviceID = row.querySelector(`[data-oid="${oid}${schema.controllers.deviceID}"]`)
   const address = row.querySelector(`[data-oid="${oid}${schema.controllers.endpoint.address}"]`)
   const protocol = row.querySelector(`[data-oid="${oid}${schema.controllers.endpoint.protocol}"]`)
+  const iface = row.querySelector(`[data-oid="${oid}${schema.controllers.interface}"]`)
   const datetime = row.querySelector(`[data-oid="${oid}${schema.controllers.datetime.current}"]`)
   const interlock = row.querySelector(`[data-oid="${oid}${schema.controllers.interlock}"]`)
   const antipassback = row.querySelector(`[data-oid="${oid}${schema.controllers.antipassback.antipassback}"]`)
@@ -215,6 +221,33 @@ function updateFromDB(oid, record) {
     }
   })
 
+  // ... populate interface dropdown
+  const interfaces = [...DB.interfaces.values()].filter((o) => alive(o)).sort((p, q) => p.OID.localeCompare(q.OID, 'en', { numeric: true }))
+  const options = iface.options
+
+  let ix = 1
+
+  interfaces.forEach((i) => {
+    const value = i.OID
+    const label = i.name !== '' ? i.name : `<${i.type}${i.OID}>`.replaceAll('.', '')
+
+    if (ix < options.length) {
+      if (options[ix].value !== value) {
+        options.add(new Option(label, value, false, false), ix)
+      } else if (options[ix].label !== label) {
+        options[ix].label = label
+      }
+    } else {
+      options.add(new Option(label, value, false, false))
+    }
+
+    ix++
+  })
+
+  while (options.length > interfaces.length + 1) {
+    options.remove(options.length - 1)
+  }
+
   // ... set record values
   row.dataset.status = record.status
 
@@ -224,6 +257,7 @@ function updateFromDB(oid, record) {
   update(deviceID, record.deviceID)
   update(address, record.address.address, record.address.status)
   update(protocol, record.protocol === 'tcp' ? 'tcp' : 'udp', null, (v) => v === 'tcp')
+  update(iface, record.interface)
   update(datetime, dt, record.datetime.status)
   update(interlock, record.interlock)
   update(antipassback, record.antipassback.antipassback, record.antipassback.status)
@@ -8,6 +8,8 @@ class DBC {
     this.doors = new Map()
     this.cards = new Map()
     this.groups = new Map()
+    this.timeprofiles = new Map()
+    this.tasks = new Map()
 
     this.tables = {
       events: {
@@ -43,9 +45,12 @@ class DBC {
         case 'doors':
         case 'cards':
         case 'groups':
+        case 'time-profiles':
+        case 'tasks':
         case 'events':
         case 'logs':
         case 'users':
+        case 'objects':
           recordset.forEach((o) => object(o))
           break
       }
@@ -98,6 +103,14 @@ class DBC {
           this.groups.delete(oid)
           break
 
+        case 'timeprofiles':
+          this.timeprofiles.delete(oid)
+          break
+
+        case 'tasks':
+          this.tasks.delete(oid)
+          break
+
         case 'users':
           this.tables.users.users.delete(oid)
           break
@@ -143,27 +156,36 @@ export const DB = new DBC()
 function object(o) {
   const oid = o.OID
 
-  if (oid.startsWith(schema.system.base)) {
+  if (under(oid, schema.system.base)) {
     system(o)
-  } else if (oid.startsWith(schema.interfaces.base)) {
+  } else if (under(oid, schema.interfaces.base)) {
     interfaces(o)
-  } else if (oid.startsWith(schema.controllers.base)) {
+  } else if (under(oid, schema.controllers.base)) {
     controllers(o)
-  } else if (oid.startsWith(schema.doors.base)) {
+  } else if (under(oid, schema.doors.base)) {
     doors(o)
-  } else if (oid.startsWith(schema.cards.base)) {
+  } else if (under(oid, schema.cards.base)) {
     cards(o)
-  } else if (oid.startsWith(schema.groups.base)) {
+  } else if (under(oid, schema.groups.base)) {
     groups(o)
-  } else if (oid.startsWith(schema.events.base)) {
+  } else if (under(oid, schema.events.base)) {
     events(o)
-  } else if (oid.startsWith(schema.logs.base)) {
+  } else if (under(oid, schema.logs.base)) {
     logs(o)
-  } else if (oid.startsWith(schema.users.base)) {
+  } else if (under(oid, schema.users.base)) {
     users(o)
+  } else if (under(oid, schema.timeprofiles.base)) {
+    timeprofiles(o)
+  } else if (under(oid, schema.tasks.base)) {
+    tasks(o)
   }
 }
 
+// Matches whole OID components only i.e. 0.10.1 is not under 0.1
+function under(oid, base) {
+  return oid === base || oid.startsWith(`${base}.`)
+}
+
 function system(o) {
   const oid = o.OID
 
@@ -203,6 +225,9 @@ function interfaces(o) {
       bind: '',
       broadcast: '',
       listen: '',
+      url: '',
+      broker: '',
+      clientID: '',
       status: '',
       touched: new Date(),
     })
@@ -236,6 +261,18 @@ function interfaces(o) {
     case `${base}${schema.interfaces.listen}`:
       v.listen = o.value
       break
+
+    case `${base}${schema.interfaces.url}`:
+      v.url = o.value
+      break
+
+    case `${base}${schema.interfaces.broker}`:
+      v.broker = o.value
+      break
+
+    case `${base}${schema.interfaces.clientID}`:
+      v.clientID = o.value
+      break
   }
 }
 
@@ -261,6 +298,7 @@ function controllers(o) {
       datetime: { datetime: '', configured: '', status: 'unknown' },
       interlock: '',
       antipassback: { antipassback: '', configured: '', status: 'unknown' },
+      interface: '',
       cards: { cards: '', status: 'unknown' },
       events: { events: '', status: 'unknown' },
       doors: { 1: '', 2: '', 3: '', 4: '' },
@@ -338,6 +376,10 @@ function controllers(o) {
       v.antipassback.status = o.value
       break
 
+    case `${base}${schema.controllers.interface}`:
+      v.interface = o.value
+      break
+
     case `${base}${schema.controllers.cards.status}`:
       v.cards.status = o.value
       break
@@ -400,6 +442,7 @@ function doors(o) {
       mode: { mode: '', configured: '', status: 'unknown', err: '' },
       keypad: false,
       passcodes: '',
+      state: { open: '', button: '', unlocked: '' },
       status: o.value,
       touched: new Date(),
     })
@@ -465,6 +508,18 @@ function doors(o) {
     case `${base}.5`:
       v.passcodes = o.value
       break
+
+    case `${base}${schema.doors.open}`:
+      v.state.open = o.value
+      break
+
+    case `${base}${schema.doors.button}`:
+      v.state.button = o.value
+      break
+
+    case `${base}${schema.doors.unlocked}`:
+      v.state.unlocked = o.value
+      break
   }
 }
 
@@ -607,7 +662,7 @@ function groups(o) {
         const suffix = m[2]
 
         if (!v.doors.has(suboid)) {
-          v.doors.set(suboid, { door: '', allowed: false })
+          v.doors.set(suboid, { door: '', allowed: false, profile: '' })
         }
 
         const door = v.doors.get(suboid)
@@ -616,6 +671,8 @@ function groups(o) {
           door.allowed = o.value === 'true'
         } else if (suffix === '.1') {
           door.door = o.value
+        } else if (suffix === '.2') {
+          door.profile = o.value
         }
       }
     }
@@ -814,6 +871,8 @@ function users(o) {
       password: '',
       otp: '',
       locked: '',
+      passkeys: '',
+      certificates: '',
       details: '',
       created: '',
       deleted: '',
@@ -862,11 +921,175 @@ function users(o) {
     case `${base}${schema.users.locked}`:
       v.locked = o.value
       break
+
+    case `${base}${schema.users.passkeys}`:
+      v.passkeys = o.value
+      break
+
+    case `${base}${schema.users.certificates}`:
+      v.certificates = o.value
+      break
+  }
+}
+
+function timeprofiles(o) {
+  const oid = o.OID
+  const match = oid.match(schema.timeprofiles.regex)
+
+  if (!match || match.length < 2) {
+    return
+  }
+
+  const base = match[1]
+
+  if (!DB.timeprofiles.has(base)) {
+    DB.timeprofiles.set(base, {
+      OID: base,
+      created: '',
+      deleted: '',
+      name: '',
+      ID: '',
+      from: '',
+      to: '',
+      weekdays: '',
+      segments: ['', '', ''],
+      status: o.value,
+      touched: new Date(),
+    })
+  }
+
+  const v = DB.timeprofiles.get(base)
+
+  v.touched = new Date()
+
+  switch (oid) {
+    case `${base}${schema.timeprofiles.status}`:
+      v.status = o.value
+      break
+
+    case `${base}${schema.timeprofiles.created}`:
+      v.created = o.value
+      break
+
+    case `${base}${schema.timeprofiles.deleted}`:
+      v.deleted = o.value
+      break
+
+    case `${base}${schema.timeprofiles.name}`:
+      v.name = o.value
+      break
+
+    case `${base}${schema.timeprofiles.ID}`:
+      v.ID = o.value
+      break
+
+    case `${base}${schema.timeprofiles.from}`:
+      v.from = o.value
+      break
+
+    case `${base}${schema.timeprofiles.to}`:
+      v.to = o.value
+      break
+
+    case `${base}${schema.timeprofiles.weekdays}`:
+      v.weekdays = o.value
+      break
+
+    case `${base}${schema.timeprofiles.segment1}`:
+      v.segments[0] = o.value
+      break
+
+    case `${base}${schema.timeprofiles.segment2}`:
+      v.segments[1] = o.value
+      break
+
+    case `${base}${schema.timeprofiles.segment3}`:
+      v.segments[2] = o.value
+      break
+  }
+}
+
+function tasks(o) {
+  const oid = o.OID
+  const match = oid.match(schema.tasks.regex)
+
+  if (!match || match.length < 2) {
+    return
+  }
+
+  const base = match[1]
+
+  if (!DB.tasks.has(base)) {
+    DB.tasks.set(base, {
+      OID: base,
+      created: '',
+      deleted: '',
+      name: '',
+      schedule: '',
+      action: '',
+      door: '',
+      days: '',
+      enabled: false,
+      lastRun: '',
+      result: '',
+      status: o.value,
+      touched: new Date(),
+    })
+  }
+
+  const v = DB.tasks.get(base)
+
+  v.touched = new Date()
+
+  switch (oid) {
+    case `${base}${schema.tasks.status}`:
+      v.status = o.value
+      break
+
+    case `${base}${schema.tasks.created}`:
+      v.created = o.value
+      break
+
+    case `${base}${schema.tasks.deleted}`:
+      v.deleted = o.value
+      break
+
+    case `${base}${schema.tasks.name}`:
+      v.name = o.value
+      break
+
+    case `${base}${schema.tasks.schedule}`:
+      v.schedule = o.value
+      break
+
+    case `${base}${schema.tasks.action}`:
+      v.action = o.value
+      break
+
+    case `${base}${schema.tasks.door}`:
+      v.door = o.value
+      break
+
+    case `${base}${schema.tasks.days}`:
+      v.days = o.value
+      break
+
+    case `${base}${schema.tasks.enabled}`:
+      v.enabled = o.value === 'true'
+      break
+
+    case `${base}${schema.tasks.lastRun}`:
+      v.lastRun = o.value
+      break
+
+    case `${base}${schema.tasks.result}`:
+      v.result = o.value
+      break
   }
 }
 
 function sweep() {
-  const tables = [DB.interfaces, DB.controllers, DB.doors, DB.cards, DB.groups]
+  const tables = [DB.interfaces, DB.controllers, DB.doors, DB.cards, DB.groups, DB.timeprofiles, DB.tasks]
   const now = new Date()
   const sweepable = 5 * 60 * 1000 // 5 minutes
 
@@ -1,7 +1,8 @@
 import { update, trim } from './tabular.js'
 import { DB, alive } from './db.js'
+import { schema } from './schema.js'
 import { Combobox } from './combobox.js'
-import { loaded } from './uhppoted.js'
+import { loaded, postAsJSON, warning } from './uhppoted.js'
 
 export function refreshed() {
   const doors = [...DB.doors.values()].filter((d) => alive(d)).sort((p, q) => p.created.localeCompare(q.created))
@@ -22,6 +23,32 @@ export function refreshed() {
   loaded()
 }
 
+export function onOpenDoor(event) {
+  event.preventDefault()
+
+  const row = event.currentTarget.closest('tr')
+
+  if (row && row.dataset.oid) {
+    postAsJSON('/doors/open', { door: row.dataset.oid })
+      .then((response) => {
+        if (response.status === 200) {
+          return ''
+        } else {
+          return response.text()
+        }
+      })
+      .then((msg) => {
+        if (msg !== '') {
+          warning(msg)
+        }
+      })
+      .catch(function (err) {
+        console.error(err)
+        warning(`${err.message}`)
+      })
+  }
+}
+
 export function deletable(row) {
   const name = row.querySelector('td input.name')
   const re = /^\s*$/
@@ -89,6 +116,9 @@ function add(oid) {
       { suffix: 'mode', oid: `${oid}.3`, selector: 'td input.mode' },
       { suffix: 'keypad', oid: `${oid}.4`, selector: 'td label.keypad input' },
       { suffix: 'passcodes', oid: `${oid}.5`, selector: 'td input.passcodes' },
+      { suffix: 'state', oid: `${oid}${schema.doors.open}`, selector: 'td input.state' },
+      { suffix: 'button', oid: `${oid}${schema.doors.button}`, selector: 'td input.button' },
+      { suffix: 'lock', oid: `${oid}${schema.doors.unlocked}`, selector: 'td input.lock' },
     ]
 
     fields.forEach((f) => {
@@ -128,6 +158,9 @@ function updateFromDB(oid, record) {
   const mode = row.querySelector(`[data-oid="${oid}.3"]`)
   const keypad = row.querySelector(`[data-oid="${oid}.4"]`)
   const passcodes = row.querySelector(`[data-oid="${oid}.5"]`)
+  const state = row.querySelector(`[data-oid="${oid}${schema.doors.open}"]`)
+  const button = row.querySelector(`[data-oid="${oid}${schema.doors.button}"]`)
+  const lock = row.querySelector(`[data-oid="${oid}${schema.doors.unlocked}"]`)
 
   row.dataset.status = record.status
 
@@ -143,6 +176,9 @@ function updateFromDB(oid, record) {
   update(mode, m, record.mode.status)
   update(keypad, record.keypad)
   update(passcodes, record.passcodes)
+  update(state, record.state.open)
+  update(button, record.state.button)
+  update(lock, record.state.unlocked)
 
   // ... set tooltips for error'd values
   {
@@ -96,6 +96,7 @@ function realize(groups) {
       cell.innerHTML = template.innerHTML
 
       const field = cell.querySelector('.field')
+      const profile = cell.querySelector('select.profile')
 
       field.id = uuid + '-' + `d${door}`
       field.dataset.oid = oid
@@ -103,6 +104,12 @@ function realize(groups) {
       field.dataset.original = ''
       field.dataset.value = ''
       field.checked = false
+
+      profile.id = uuid + '-' + `d${door}-profile`
+      profile.dataset.oid = `${oid}${schema.groups.profile}`
+      profile.dataset.record = uuid
+      profile.dataset.original = ''
+      profile.dataset.value = ''
     })
 
     surplus.forEach(([, v]) => {
@@ -164,6 +171,9 @@ function updateFromDB(oid, record) {
 
   const name = row.querySelector(`[data-oid="${oid}${schema.groups.name}"]`)
   const doors = [...DB.doors.values()].filter((o) => o.status && o.status !== '<new>' && alive(o))
+  const profiles = [...DB.timeprofiles.values()]
+    .filter((o) => o.status && o.status !== 'new' && alive(o))
+    .sort((p, q) => p.created.localeCompare(q.created))
 
   row.dataset.status = record.status
 
@@ -174,11 +184,45 @@ function updateFromDB(oid, record) {
 
     if (td) {
       const e = td.querySelector('.field')
+      const select = td.querySelector('select.profile')
       const d = record.doors.get(`${e.dataset.oid}`)
 
       update(e, d && d.allowed)
+
+      if (select) {
+        options(select, profiles)
+        update(select, d ? d.profile : '')
+      }
     }
   })
 
   return row
 }
+
+// Populates the time profile dropdown for a group door.
+function options(select, profiles) {
+  const options = select.options
+
+  let ix = 1
+
+  profiles.forEach((p) => {
+    const value = p.OID
+    const label = p.name !== '' ? p.name : `<P${p.ID}>`
+
+    if (ix < options.length) {
+      if (options[ix].value !== value) {
+        options.add(new Option(label, value, false, false), ix)
+      } else if (options[ix].label !== label) {
+        options[ix].label = label
+      }
+    } else {
+      options.add(new Option(label, value, false, false))
+    }
+
+    ix++
+  })
+
+  while (options.length > profiles.length + 1) {
+    options.remove(options.length - 1)
+  }
+}
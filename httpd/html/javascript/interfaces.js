@@ -1,10 +1,41 @@
 import { mark, unmark } from './tabular.js'
-import { DB } from './db.js'
+import { DB, alive } from './db.js'
 import { schema } from './schema.js'
 
 export function refreshed() {
-  DB.interfaces.forEach((c) => {
-    updateFromDB(c.OID, c)
+  const list = [...DB.interfaces.values()]
+    .filter((o) => alive(o) && o.type === 'LAN')
+    .sort((p, q) => p.OID.localeCompare(q.OID, 'en', { numeric: true }))
+
+  realize(list)
+
+  list.forEach((o) => {
+    updateFromDB(o.OID, o)
+  })
+}
+
+// Adds a panel for each LAN interface (the LAN interfaces are defined in the interfaces file
+// and cannot be created or deleted from the UI).
+function realize(interfaces) {
+  const container = document.querySelector('#interfaces')
+  const template = document.querySelector('#lan')
+
+  if (!container || !template) {
+    return
+  }
+
+  interfaces.forEach((o) => {
+    if (!container.querySelector(`table[data-oid="${o.OID}"]`)) {
+      const section = template.content.firstElementChild.cloneNode(true)
+
+      section.id = 'I' + o.OID.replaceAll(/[^0-9]/g, '')
+      section.dataset.oid = o.OID
+      section.querySelector('input.name').dataset.oid = `${o.OID}${schema.interfaces.name}`
+      section.querySelector('input.bind').dataset.oid = `${o.OID}${schema.interfaces.bind}`
+      section.querySelector('input.broadcast').dataset.oid = `${o.OID}${schema.interfaces.broadcast}`
+
+      container.appendChild(section)
+    }
   })
 }
 
@@ -39,8 +70,8 @@ export function set(element, value, _status) {
   percolate(oid)
 }
 
-export function rollback(tag, _element) {
-  const section = document.getElementById(tag)
+export function rollback(_tag, element) {
+  const section = element.closest('table.interface')
   const oid = section.dataset.oid
 
   const children = section.querySelectorAll(`[data-oid^="${oid}."]`)
@@ -58,8 +89,8 @@ export function rollback(tag, _element) {
   section.classList.remove('modified')
 }
 
-export function changeset(_element) {
-  const section = document.getElementById('interface')
+export function changeset(element) {
+  const section = element.closest('table.interface')
   const oid = section.dataset.oid
   const list = []
 
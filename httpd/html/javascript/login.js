@@ -1,6 +1,7 @@
 /* global messages */
 
 import { postAsForm } from './uhppoted.js'
+import { get } from './webauthn.js'
 
 export function login(event) {
   dismiss()
@@ -25,6 +26,41 @@ export function login(event) {
     })
 }
 
+// Passwordless login with a passkey.
+export function passkey(event) {
+  dismiss()
+
+  event.preventDefault()
+
+  const init = {
+    method: 'GET',
+    mode: 'cors',
+    cache: 'no-cache',
+    credentials: 'same-origin',
+    redirect: 'follow',
+    referrerPolicy: 'no-referrer',
+  }
+
+  fetch('/webauthn/login', init)
+    .then((response) => {
+      switch (response.status) {
+        case 200:
+          return response.json()
+
+        default:
+          return response.text().then((msg) => {
+            throw new Error(msg.trim())
+          })
+      }
+    })
+    .then((options) => {
+      return assert(options)
+    })
+    .catch(function (err) {
+      warning(`${err.message}`)
+    })
+}
+
 // HEAD request to refresh the uhppoted-httpd-login cookie.
 // (preempts the double login needed if the cookie has expired)
 async function preauth() {
@@ -54,6 +90,8 @@ function auth() {
         case 200:
           if (response.redirected) {
             window.location = response.url
+          } else if ((response.headers.get('Content-Type') || '').startsWith('application/json')) {
+            return response.json().then((v) => assert(v.passkey))
           } else {
             window.location = '/index.html'
           }
@@ -76,6 +114,37 @@ function auth() {
     })
 }
 
+// Signs the passkey assertion request and completes the login.
+async function assert(options) {
+  const credential = await get(options)
+  const init = {
+    method: 'POST',
+    mode: 'cors',
+    cache: 'no-cache',
+    credentials: 'same-origin',
+    headers: { 'Content-Type': 'application/json' },
+    redirect: 'follow',
+    referrerPolicy: 'no-referrer',
+    body: JSON.stringify(credential),
+  }
+
+  return fetch('/webauthn/login', init).then((response) => {
+    switch (response.status) {
+      case 200:
+        window.location = '/index.html'
+        return
+
+      case 401:
+        throw new Error(messages.passkey)
+
+      default:
+        return response.text().then((msg) => {
+          throw new Error(msg.trim())
+        })
+    }
+  })
+}
+
 function warning(msg) {
   const message = document.getElementById('message')
   const text = document.getElementById('warning')
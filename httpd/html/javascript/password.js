@@ -1,6 +1,7 @@
 /* global messages */
 
 import { GET, POST, DELETE } from './uhppoted.js'
+import { supported, create } from './webauthn.js'
 
 let expired = -1
 
@@ -166,6 +167,142 @@ export function onVerifyOTP(event) {
     })
 }
 
+export function onAddPasskey(event) {
+  event.preventDefault()
+
+  dismiss()
+
+  if (!supported()) {
+    warning(messages.passkeys.unsupported)
+    return
+  }
+
+  const uid = document.getElementById('uid').value
+  const pwd = document.getElementById('pwd').value
+  const name = document.getElementById('passkey-name')
+  const auth = btoa(`${uid}:${pwd}`)
+
+  POST('/passkeys/register', `Basic ${auth}`, {})
+    .then((response) => {
+      switch (response.status) {
+        case 200:
+          return response.json()
+
+        case 401:
+          throw new Error(messages.unauthorized)
+
+        default:
+          return response.text().then((err) => {
+            throw new Error(err)
+          })
+      }
+    })
+    .then((options) => create(options))
+    .then((credential) => {
+      const init = {
+        method: 'POST',
+        mode: 'cors',
+        cache: 'no-cache',
+        credentials: 'same-origin',
+        headers: { 'Content-Type': 'application/json' },
+        redirect: 'follow',
+        referrerPolicy: 'no-referrer',
+        body: JSON.stringify({ name: name.value, credential: credential }),
+      }
+
+      return fetch('/passkeys', init)
+    })
+    .then((response) => {
+      switch (response.status) {
+        case 200:
+          name.value = ''
+          warning(messages.passkeys.registered)
+          return refreshPasskeys()
+
+        default:
+          return response.text().then((err) => {
+            throw new Error(err)
+          })
+      }
+    })
+    .catch(function (err) {
+      warning(`${err.message}`)
+    })
+}
+
+export function onRevokePasskey(event, id) {
+  event.preventDefault()
+
+  dismiss()
+
+  const uid = document.getElementById('uid').value
+  const pwd = document.getElementById('pwd').value
+  const auth = btoa(`${uid}:${pwd}`)
+
+  DELETE(`/passkeys?id=${encodeURIComponent(id)}`, `Basic ${auth}`)
+    .then((response) => {
+      switch (response.status) {
+        case 200:
+          warning(messages.passkeys.revoked)
+          return refreshPasskeys()
+
+        case 401:
+          throw new Error(messages.unauthorized)
+
+        default:
+          return response.text().then((err) => {
+            throw new Error(err)
+          })
+      }
+    })
+    .catch(function (err) {
+      warning(`${err.message}`)
+    })
+}
+
+export async function refreshPasskeys() {
+  const list = document.getElementById('passkey-list')
+
+  return GET('/passkeys')
+    .then((response) => {
+      switch (response.status) {
+        case 200:
+          return response.json()
+
+        default:
+          return response.text().then((err) => {
+            throw new Error(err)
+          })
+      }
+    })
+    .then((v) => {
+      const rows = []
+
+      for (const p of v.passkeys) {
+        const tr = document.createElement('tr')
+        const name = document.createElement('td')
+        const used = document.createElement('td')
+        const revoke = document.createElement('td')
+        const button = document.createElement('button')
+
+        name.innerText = p.name ? p.name : p.id
+        used.innerText = p.used ? p.used : messages.passkeys.never
+        button.className = 'plain'
+        button.innerText = messages.passkeys.revoke
+        button.onclick = (event) => onRevokePasskey(event, p.id)
+
+        revoke.appendChild(button)
+        tr.append(name, used, revoke)
+        rows.push(tr)
+      }
+
+      list.replaceChildren(...rows)
+    })
+    .catch(function (err) {
+      warning(`${err.message}`)
+    })
+}
+
 async function getOTP(_event) {
   const uid = document.getElementById('uid').value
   const pwd = document.getElementById('pwd').value
@@ -21,6 +21,9 @@ export const schema = {
     bind: '.3.1',
     broadcast: '.3.2',
     listen: '.3.3',
+    url: '.4.1',
+    broker: '.5.1',
+    clientID: '.5.2',
 
     regex: /^(0\.1\.[1-9][0-9]*).*$/,
   },
@@ -68,6 +71,7 @@ export const schema = {
       configured: '.9.2',
       modified: '.9.3',
     },
+    interface: '.10',
 
     regex: /^(0\.2\.[1-9][0-9]*).*$/,
   },
@@ -81,6 +85,10 @@ export const schema = {
     modified: '.0.3',
     type: '.0.4',
 
+    open: '.6.1',
+    button: '.6.2',
+    unlocked: '.6.3',
+
     regex: /^(0\.3\.([1-9][0-9]*)).*$/,
   },
 
@@ -117,6 +125,7 @@ export const schema = {
 
     name: '.1',
     door: '.2',
+    profile: '.2',
 
     regex: /^(0\.5\.([1-9][0-9]*)).*$/,
     doors: /^(0\.5\.[1-9][0-9]*\.2\.[1-9][0-9]*)(\.[1-3])?$/,
@@ -176,7 +185,49 @@ export const schema = {
     password: '.4',
     otp: '.5',
     locked: '.6',
+    passkeys: '.8',
+    certificates: '.9',
 
     regex: /^(0\.8\.[1-9][0-9]*).*$/,
   },
+
+  timeprofiles: {
+    base: '0.9',
+
+    status: '.0.0',
+    created: '.0.1',
+    deleted: '.0.2',
+    modified: '.0.3',
+
+    name: '.1',
+    ID: '.2',
+    from: '.3',
+    to: '.4',
+    weekdays: '.5',
+    segment1: '.6.1',
+    segment2: '.6.2',
+    segment3: '.6.3',
+
+    regex: /^(0\.9\.[1-9][0-9]*).*$/,
+  },
+
+  tasks: {
+    base: '0.10',
+
+    status: '.0.0',
+    created: '.0.1',
+    deleted: '.0.2',
+    modified: '.0.3',
+
+    name: '.1',
+    schedule: '.2',
+    action: '.3',
+    door: '.4',
+    days: '.5',
+    enabled: '.6',
+    lastRun: '.7',
+    result: '.8',
+
+    regex: /^(0\.10\.[1-9][0-9]*).*$/,
+  },
 }
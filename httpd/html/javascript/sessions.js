@@ -0,0 +1,114 @@
+/* global messages */
+
+import { GET, DELETE, busy, unbusy, warning, dismiss } from './uhppoted.js'
+
+export function onRefreshSessions(event) {
+  if (event) {
+    event.preventDefault()
+  }
+
+  busy()
+  dismiss()
+
+  refreshSessions().finally(() => {
+    unbusy()
+  })
+}
+
+export function onRevokeSession(event, id) {
+  event.preventDefault()
+
+  revoke(`/sessions?id=${encodeURIComponent(id)}`, messages.revoked)
+}
+
+export function onRevokeUser(event, uid) {
+  event.preventDefault()
+
+  if (confirm(`${messages.confirm} ${uid}?`)) {
+    revoke(`/sessions?uid=${encodeURIComponent(uid)}`, messages.revokedAll)
+  }
+}
+
+export async function refreshSessions() {
+  const list = document.getElementById('session-list')
+
+  return GET('/sessions')
+    .then((response) => {
+      switch (response.status) {
+        case 200:
+          return response.json()
+
+        default:
+          return response.text().then((err) => {
+            throw new Error(err)
+          })
+      }
+    })
+    .then((v) => {
+      const rows = []
+
+      for (const s of v.sessions) {
+        const tr = document.createElement('tr')
+        const revoke = document.createElement('td')
+        const one = document.createElement('button')
+        const all = document.createElement('button')
+
+        revoke.className = 'rowheader controls'
+        one.className = 'plain'
+        one.innerText = messages.revoke
+        one.onclick = (event) => onRevokeSession(event, s.id)
+        all.className = 'plain'
+        all.innerText = messages.revokeAll
+        all.onclick = (event) => onRevokeUser(event, s.uid)
+
+        revoke.append(one, all)
+        tr.appendChild(revoke)
+
+        const fields = {
+          uid: s.uid,
+          role: s.role,
+          address: s.address,
+          created: s.created,
+          'last-seen': s['last-seen'],
+          expires: s.expires,
+          'user-agent': s['user-agent'],
+        }
+
+        for (const [k, v] of Object.entries(fields)) {
+          const td = document.createElement('td')
+
+          td.className = k
+          td.innerText = v ? v : '-'
+          tr.appendChild(td)
+        }
+
+        rows.push(tr)
+      }
+
+      list.replaceChildren(...rows)
+    })
+    .catch(function (err) {
+      warning(`${err.message}`)
+    })
+}
+
+function revoke(url, msg) {
+  dismiss()
+
+  DELETE(url)
+    .then((response) => {
+      switch (response.status) {
+        case 200:
+          warning(msg)
+          return refreshSessions()
+
+        default:
+          return response.text().then((err) => {
+            throw new Error(err)
+          })
+      }
+    })
+    .catch(function (err) {
+      warning(`${err.message}`)
+    })
+}
@@ -7,9 +7,11 @@ import * as groups from './groups.js'
 import * as events from './events.js'
 import * as logs from './logs.js'
 import * as users from './users.js'
+import * as timeprofiles from './timeprofiles.js'
+import * as tasks from './tasks.js'
 import { DB } from './db.js'
 import { Cache } from './cache.js'
-import { busy, unbusy, warning, dismiss, getAsJSON, postAsJSON } from './uhppoted.js'
+import { busy, unbusy, warning, dismiss, getAsJSON, postAsJSON, subscribe } from './uhppoted.js'
 
 class Warning extends Error {
   constructor(...params) {
@@ -70,7 +72,7 @@ const pages = {
   },
 
   groups: {
-    get: ['/groups', '/doors'],
+    get: ['/groups', '/doors', '/time-profiles'],
     post: '/groups',
     refreshed: groups.refreshed,
     deletable: groups.deletable,
@@ -96,6 +98,20 @@ const pages = {
     refreshed: users.refreshed,
     deletable: users.deletable,
   },
+
+  timeprofiles: {
+    get: ['/time-profiles'],
+    post: '/time-profiles',
+    refreshed: timeprofiles.refreshed,
+    deletable: timeprofiles.deletable,
+  },
+
+  tasks: {
+    get: ['/tasks', '/doors'],
+    post: '/tasks',
+    refreshed: tasks.refreshed,
+    deletable: tasks.deletable,
+  },
 }
 
 export function onEdited(tag, event) {
@@ -138,6 +154,14 @@ export function onEdited(tag, event) {
     case 'user':
       set(event.target, event.target.value)
       break
+
+    case 'timeprofile':
+      set(event.target, event.target.value)
+      break
+
+    case 'task':
+      set(event.target, event.target.value)
+      break
   }
 }
 
@@ -187,6 +211,14 @@ export function onEnter(tag, event) {
       case 'user':
         set(element, element.value)
         break
+
+      case 'timeprofile':
+        set(element, element.value)
+        break
+
+      case 'task':
+        set(element, element.value)
+        break
     }
   }
 }
@@ -224,6 +256,10 @@ export function onTick(tag, event) {
     case 'user':
       set(event.target, event.target.checked ? 'true' : 'false')
       break
+
+    case 'task':
+      set(event.target, event.target.checked ? 'true' : 'false')
+      break
   }
 }
 
@@ -242,6 +278,8 @@ export function onCommit(tag, event) {
     case 'card':
     case 'group':
     case 'user':
+    case 'timeprofile':
+    case 'task':
       page = getPage(tag)
       commit(page, changeset(page, row))
       break
@@ -258,6 +296,8 @@ export function onCommitAll(tag, _event, _table) {
     case 'cards':
     case 'groups':
     case 'users':
+    case 'timeprofiles':
+    case 'tasks':
       commit(page, changeset(page, ...rows))
       break
   }
@@ -291,6 +331,14 @@ export function onRollback(tag, event) {
     case 'user':
       rollback('users', row, users.refreshed)
       break
+
+    case 'timeprofile':
+      rollback('timeprofiles', row, timeprofiles.refreshed)
+      break
+
+    case 'task':
+      rollback('tasks', row, tasks.refreshed)
+      break
   }
 }
 
@@ -332,6 +380,14 @@ export function onRollbackAll(tag, _event) {
     case 'users':
       f('users', 'users', users.refreshed)
       break
+
+    case 'timeprofiles':
+      f('timeprofiles', 'timeprofiles', timeprofiles.refreshed)
+      break
+
+    case 'tasks':
+      f('tasks', 'tasks', tasks.refreshed)
+      break
   }
 
   console.log(`cards:rolled-back (${Date.now() - start}ms)`)
@@ -358,6 +414,14 @@ export function onNew(tag, _event) {
     case 'user':
       create(pages.users)
       break
+
+    case 'timeprofile':
+      create(pages.timeprofiles)
+      break
+
+    case 'task':
+      create(pages.tasks)
+      break
   }
 }
 
@@ -369,6 +433,13 @@ export function prefetch(tag) {
   }
 
   onRefresh(tag)
+
+  if (page) {
+    subscribe((objects) => {
+      DB.updated('objects', objects)
+      page.refreshed()
+    })
+  }
 }
 
 export function onRefresh(tag, event) {
@@ -854,6 +925,14 @@ function getPage(tag) {
     case 'user':
     case 'users':
       return pages.users
+
+    case 'timeprofile':
+    case 'timeprofiles':
+      return pages.timeprofiles
+
+    case 'task':
+    case 'tasks':
+      return pages.tasks
   }
 
   return null
@@ -866,6 +945,8 @@ function pageForRow(row) {
     { tag: 'card', page: pages.cards },
     { tag: 'group', page: pages.groups },
     { tag: 'user', page: pages.users },
+    { tag: 'timeprofile', page: pages.timeprofiles },
+    { tag: 'task', page: pages.tasks },
   ]
 
   for (const v of list) {
@@ -0,0 +1,162 @@
+import { update, trim } from './tabular.js'
+import { DB, alive } from './db.js'
+import { schema } from './schema.js'
+import { loaded } from './uhppoted.js'
+
+export function refreshed() {
+  const tasks = [...DB.tasks.values()].filter((t) => alive(t)).sort((p, q) => p.created.localeCompare(q.created))
+
+  realize(tasks)
+
+  tasks.forEach((o) => {
+    const row = updateFromDB(o.OID, o)
+    if (row) {
+      if (o.status === 'new') {
+        row.classList.add('new')
+      } else {
+        row.classList.remove('new')
+      }
+    }
+  })
+
+  loaded()
+}
+
+export function deletable(row) {
+  const name = row.querySelector('td input.name')
+  const re = /^\s*$/
+
+  if (name && name.dataset.oid !== '' && re.test(name.dataset.value)) {
+    return true
+  }
+
+  return false
+}
+
+function realize(tasks) {
+  const table = document.querySelector('#tasks table')
+  const tbody = table.tBodies[0]
+
+  trim('tasks', tasks, tbody.querySelectorAll('tr.task'))
+
+  tasks.forEach((o) => {
+    let row = tbody.querySelector("tr[data-oid='" + o.OID + "']")
+
+    if (!row) {
+      row = add(o.OID, o)
+    }
+  })
+}
+
+function add(oid, _record) {
+  const uuid = 'R' + oid.replaceAll(/[^0-9]/g, '')
+  const tbody = document.getElementById('tasks').querySelector('table tbody')
+
+  if (tbody) {
+    const template = document.querySelector('#task')
+    const row = tbody.insertRow()
+
+    row.id = uuid
+    row.classList.add('task')
+    row.classList.add('new')
+    row.dataset.oid = oid
+    row.dataset.status = 'unknown'
+    row.innerHTML = template.innerHTML
+
+    const commit = row.querySelector('td span.commit')
+    commit.id = uuid + '_commit'
+    commit.dataset.record = uuid
+
+    const rollback = row.querySelector('td span.rollback')
+    rollback.id = uuid + '_rollback'
+    rollback.dataset.record = uuid
+
+    const fields = [
+      { suffix: 'name', oid: `${oid}${schema.tasks.name}`, selector: 'td input.name' },
+      { suffix: 'schedule', oid: `${oid}${schema.tasks.schedule}`, selector: 'td input.schedule' },
+      { suffix: 'action', oid: `${oid}${schema.tasks.action}`, selector: 'td select.action' },
+      { suffix: 'door', oid: `${oid}${schema.tasks.door}`, selector: 'td select.door' },
+      { suffix: 'days', oid: `${oid}${schema.tasks.days}`, selector: 'td input.days' },
+      { suffix: 'enabled', oid: `${oid}${schema.tasks.enabled}`, selector: 'td label.enabled input' },
+      { suffix: 'last-run', oid: `${oid}${schema.tasks.lastRun}`, selector: 'td input.last-run' },
+      { suffix: 'result', oid: `${oid}${schema.tasks.result}`, selector: 'td input.result' },
+    ]
+
+    fields.forEach((f) => {
+      const field = row.querySelector(f.selector)
+      if (field) {
+        field.id = uuid + '-' + f.suffix
+        field.value = ''
+        field.dataset.oid = f.oid
+        field.dataset.record = uuid
+        field.dataset.original = ''
+        field.dataset.value = ''
+
+        // ... sigh .. Safari is awful
+        if (`${navigator.vendor}`.toLowerCase().includes('apple')) {
+          field.classList.add('apple')
+        }
+      } else {
+        console.error(f)
+      }
+    })
+
+    return row
+  }
+}
+
+function updateFromDB(oid, record) {
+  const row = document.querySelector("div#tasks tr[data-oid='" + oid + "']")
+
+  const name = row.querySelector(`[data-oid="${oid}${schema.tasks.name}"]`)
+  const schedule = row.querySelector(`[data-oid="${oid}${schema.tasks.schedule}"]`)
+  const action = row.querySelector(`[data-oid="${oid}${schema.tasks.action}"]`)
+  const door = row.querySelector(`[data-oid="${oid}${schema.tasks.door}"]`)
+  const days = row.querySelector(`[data-oid="${oid}${schema.tasks.days}"]`)
+  const enabled = row.querySelector(`[data-oid="${oid}${schema.tasks.enabled}"]`)
+  const lastRun = row.querySelector(`[data-oid="${oid}${schema.tasks.lastRun}"]`)
+  const result = row.querySelector(`[data-oid="${oid}${schema.tasks.result}"]`)
+
+  // ... populate door dropdown
+  const doors = [...DB.doors.values()]
+    .filter((o) => o.status && o.status !== '<new>' && alive(o))
+    .sort((p, q) => p.created.localeCompare(q.created))
+
+  const options = door.options
+  let ix = 1
+
+  doors.forEach((d) => {
+    const value = d.OID
+    const label = d.name !== '' ? d.name : `<D${d.OID}>`.replaceAll('.', '')
+
+    if (ix < options.length) {
+      if (options[ix].value !== value) {
+        options.add(new Option(label, value, false, false), ix)
+      } else if (options[ix].label !== label) {
+        options[ix].label = label
+      }
+    } else {
+      options.add(new Option(label, value, false, false))
+    }
+
+    ix++
+  })
+
+  while (options.length > doors.length + 1) {
+    options.remove(options.length - 1)
+  }
+
+  // ... set record values
+  row.dataset.status = record.status
+
+  update(name, record.name)
+  update(schedule, record.schedule)
+  update(action, record.action)
+  update(door, record.door)
+  update(days, record.days)
+  update(enabled, record.enabled)
+  update(lastRun, record.lastRun)
+  update(result, record.result)
+
+  return row
+}
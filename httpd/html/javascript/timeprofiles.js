@@ -0,0 +1,134 @@
+import { update, trim } from './tabular.js'
+import { DB, alive } from './db.js'
+import { schema } from './schema.js'
+import { loaded } from './uhppoted.js'
+
+export function refreshed() {
+  const profiles = [...DB.timeprofiles.values()]
+    .filter((p) => alive(p))
+    .sort((p, q) => p.created.localeCompare(q.created))
+
+  realize(profiles)
+
+  profiles.forEach((o) => {
+    const row = updateFromDB(o.OID, o)
+    if (row) {
+      if (o.status === 'new') {
+        row.classList.add('new')
+      } else {
+        row.classList.remove('new')
+      }
+    }
+  })
+
+  loaded()
+}
+
+export function deletable(row) {
+  const name = row.querySelector('td input.name')
+  const re = /^\s*$/
+
+  if (name && name.dataset.oid !== '' && re.test(name.dataset.value)) {
+    return true
+  }
+
+  return false
+}
+
+function realize(profiles) {
+  const table = document.querySelector('#timeprofiles table')
+  const tbody = table.tBodies[0]
+
+  trim('timeprofiles', profiles, tbody.querySelectorAll('tr.timeprofile'))
+
+  profiles.forEach((o) => {
+    let row = tbody.querySelector("tr[data-oid='" + o.OID + "']")
+
+    if (!row) {
+      row = add(o.OID, o)
+    }
+  })
+}
+
+function add(oid, _record) {
+  const uuid = 'R' + oid.replaceAll(/[^0-9]/g, '')
+  const tbody = document.getElementById('timeprofiles').querySelector('table tbody')
+
+  if (tbody) {
+    const template = document.querySelector('#timeprofile')
+    const row = tbody.insertRow()
+
+    row.id = uuid
+    row.classList.add('timeprofile')
+    row.classList.add('new')
+    row.dataset.oid = oid
+    row.dataset.status = 'unknown'
+    row.innerHTML = template.innerHTML
+
+    const commit = row.querySelector('td span.commit')
+    commit.id = uuid + '_commit'
+    commit.dataset.record = uuid
+
+    const rollback = row.querySelector('td span.rollback')
+    rollback.id = uuid + '_rollback'
+    rollback.dataset.record = uuid
+
+    const fields = [
+      { suffix: 'name', oid: `${oid}${schema.timeprofiles.name}`, selector: 'td input.name' },
+      { suffix: 'ID', oid: `${oid}${schema.timeprofiles.ID}`, selector: 'td input.ID' },
+      { suffix: 'from', oid: `${oid}${schema.timeprofiles.from}`, selector: 'td input.from' },
+      { suffix: 'to', oid: `${oid}${schema.timeprofiles.to}`, selector: 'td input.to' },
+      { suffix: 'weekdays', oid: `${oid}${schema.timeprofiles.weekdays}`, selector: 'td input.weekdays' },
+      { suffix: 'segment1', oid: `${oid}${schema.timeprofiles.segment1}`, selector: 'td input.segment1' },
+      { suffix: 'segment2', oid: `${oid}${schema.timeprofiles.segment2}`, selector: 'td input.segment2' },
+      { suffix: 'segment3', oid: `${oid}${schema.timeprofiles.segment3}`, selector: 'td input.segment3' },
+    ]
+
+    fields.forEach((f) => {
+      const field = row.querySelector(f.selector)
+      if (field) {
+        field.id = uuid + '-' + f.suffix
+        field.value = ''
+        field.dataset.oid = f.oid
+        field.dataset.record = uuid
+        field.dataset.original = ''
+        field.dataset.value = ''
+
+        // ... sigh .. Safari is awful
+        if (`${navigator.vendor}`.toLowerCase().includes('apple')) {
+          field.classList.add('apple')
+        }
+      } else {
+        console.error(f)
+      }
+    })
+
+    return row
+  }
+}
+
+function updateFromDB(oid, record) {
+  const row = document.querySelector("div#timeprofiles tr[data-oid='" + oid + "']")
+
+  const name = row.querySelector(`[data-oid="${oid}${schema.timeprofiles.name}"]`)
+  const ID = row.querySelector(`[data-oid="${oid}${schema.timeprofiles.ID}"]`)
+  const from = row.querySelector(`[data-oid="${oid}${schema.timeprofiles.from}"]`)
+  const to = row.querySelector(`[data-oid="${oid}${schema.timeprofiles.to}"]`)
+  const weekdays = row.querySelector(`[data-oid="${oid}${schema.timeprofiles.weekdays}"]`)
+  const segment1 = row.querySelector(`[data-oid="${oid}${schema.timeprofiles.segment1}"]`)
+  const segment2 = row.querySelector(`[data-oid="${oid}${schema.timeprofiles.segment2}"]`)
+  const segment3 = row.querySelector(`[data-oid="${oid}${schema.timeprofiles.segment3}"]`)
+
+  row.dataset.status = record.status
+
+  update(name, record.name)
+  update(ID, record.ID)
+  update(from, record.from)
+  update(to, record.to)
+  update(weekdays, record.weekdays)
+  update(segment1, record.segments[0])
+  update(segment2, record.segments[1])
+  update(segment3, record.segments[2])
+
+  return row
+}
@@ -1,6 +1,8 @@
 let refreshTimer
+let refreshTicks = 0
 let idleTimer
 let disconnected
+let stream
 
 document.addEventListener('mousedown', (event) => {
   resetIdle(event)
@@ -19,7 +21,24 @@ document.addEventListener('keypress', (event) => {
 })
 
 export function setRefresh(f) {
-  refreshTimer = setInterval(f, 15000)
+  // ... polls less often while the event stream is open but still often enough to keep the session cookie current
+  refreshTimer = setInterval(() => {
+    refreshTicks++
+
+    if (!stream || stream.readyState !== EventSource.OPEN || refreshTicks % 4 === 0) {
+      f()
+    }
+  }, 15000)
+}
+
+export function subscribe(f) {
+  if (window.EventSource && !stream) {
+    stream = new EventSource('/stream')
+
+    stream.onmessage = (event) => {
+      f(JSON.parse(event.data))
+    }
+  }
 }
 
 export function onIdle() {
@@ -492,4 +511,8 @@ function offline() {
     '<div id="offline"><div><div><p>SYSTEM OFFLINE</p></div><div><a onclick="onReload()">RELOAD</a></div></div></div><div><p/></div>'
 
   clearInterval(refreshTimer)
+
+  if (stream) {
+    stream.close()
+  }
 }
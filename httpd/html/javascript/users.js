@@ -150,6 +150,16 @@ function add(oid, _record) {
         oid: `${oid}${schema.users.locked}`,
         selector: 'td label.locked input',
       },
+      {
+        suffix: 'passkeys',
+        oid: `${oid}${schema.users.passkeys}`,
+        selector: 'td label.passkeys input',
+      },
+      {
+        suffix: 'certificates',
+        oid: `${oid}${schema.users.certificates}`,
+        selector: 'td input.certificates',
+      },
     ]
 
     fields.forEach((f) => {
@@ -184,6 +194,8 @@ function updateFromDB(oid, record) {
   const password = row.querySelector(`[data-oid="${oid}${schema.users.password}"]`)
   const otp = row.querySelector(`[data-oid="${oid}${schema.users.otp}"]`)
   const locked = row.querySelector(`[data-oid="${oid}${schema.users.locked}"]`)
+  const passkeys = row.querySelector(`[data-oid="${oid}${schema.users.passkeys}"]`)
+  const certificates = row.querySelector(`[data-oid="${oid}${schema.users.certificates}"]`)
 
   row.dataset.status = record.status
 
@@ -193,6 +205,8 @@ function updateFromDB(oid, record) {
   update(password, record.password)
   update(otp, record.otp)
   update(locked, record.locked)
+  update(passkeys, record.passkeys)
+  update(certificates, record.certificates)
 
   if (record.otp === 'true') {
     otp.disabled = false
@@ -210,5 +224,13 @@ function updateFromDB(oid, record) {
     locked.parentElement.classList.remove('visible')
   }
 
+  if (record.passkeys === 'true') {
+    passkeys.disabled = false
+    passkeys.parentElement.classList.add('visible')
+  } else {
+    passkeys.disabled = true
+    passkeys.parentElement.classList.remove('visible')
+  }
+
   return row
 }
@@ -0,0 +1,80 @@
+// WebAuthn helpers to convert between the JSON encoded (base64url) options and credentials
+// exchanged with the server and the ArrayBuffers used by navigator.credentials.
+
+export function supported() {
+  return window.PublicKeyCredential !== undefined && navigator.credentials !== undefined
+}
+
+// Creates a new passkey from the server credential creation options.
+export async function create(options) {
+  const publicKey = structuredClone(options.publicKey)
+
+  publicKey.challenge = decode(publicKey.challenge)
+  publicKey.user.id = decode(publicKey.user.id)
+
+  if (publicKey.excludeCredentials) {
+    publicKey.excludeCredentials = publicKey.excludeCredentials.map((c) => ({ ...c, id: decode(c.id) }))
+  }
+
+  const credential = await navigator.credentials.create({ publicKey: publicKey })
+
+  return {
+    id: credential.id,
+    rawId: encode(credential.rawId),
+    type: credential.type,
+    response: {
+      clientDataJSON: encode(credential.response.clientDataJSON),
+      attestationObject: encode(credential.response.attestationObject),
+      transports: credential.response.getTransports ? credential.response.getTransports() : [],
+    },
+  }
+}
+
+// Signs the server assertion request with a passkey.
+export async function get(options) {
+  const publicKey = structuredClone(options.publicKey)
+
+  publicKey.challenge = decode(publicKey.challenge)
+
+  if (publicKey.allowCredentials) {
+    publicKey.allowCredentials = publicKey.allowCredentials.map((c) => ({ ...c, id: decode(c.id) }))
+  }
+
+  const credential = await navigator.credentials.get({ publicKey: publicKey })
+
+  return {
+    id: credential.id,
+    rawId: encode(credential.rawId),
+    type: credential.type,
+    response: {
+      clientDataJSON: encode(credential.response.clientDataJSON),
+      authenticatorData: encode(credential.response.authenticatorData),
+      signature: encode(credential.response.signature),
+      userHandle: credential.response.userHandle ? encode(credential.response.userHandle) : null,
+    },
+  }
+}
+
+function encode(buffer) {
+  const bytes = new Uint8Array(buffer)
+  let s = ''
+
+  for (const b of bytes) {
+    s += String.fromCharCode(b)
+  }
+
+  return btoa(s).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '')
+}
+
+function decode(s) {
+  const b64 = s.replace(/-/g, '+').replace(/_/g, '/')
+  const padded = b64 + '='.repeat((4 - (b64.length % 4)) % 4)
+  const binary = atob(padded)
+  const bytes = new Uint8Array(binary.length)
+
+  for (let i = 0; i < binary.length; i++) {
+    bytes[i] = binary.charCodeAt(i)
+  }
+
+  return bytes.buffer
+}
@@ -3,7 +3,6 @@ package httpd
 import (
 	"context"
 	"crypto/tls"
-	"crypto/x509"
 	"fmt"
 	"io/fs"
 	"net/http"
@@ -13,9 +12,12 @@ import (
 	"strings"
 	"time"
 
+	"github.com/uhppoted/uhppoted-httpd/auth/mtls"
+	"github.com/uhppoted/uhppoted-httpd/auth/webauthn"
 	"github.com/uhppoted/uhppoted-httpd/httpd/auth"
 	"github.com/uhppoted/uhppoted-httpd/httpd/cookies"
 	"github.com/uhppoted/uhppoted-httpd/httpd/html"
+	"github.com/uhppoted/uhppoted-httpd/httpd/throttle"
 	"github.com/uhppoted/uhppoted-httpd/log"
 	"github.com/uhppoted/uhppoted-httpd/types"
 )
@@ -27,6 +29,9 @@ type HTTPD struct {
 	HttpPort                 uint16
 	HttpsPort                uint16
 	AuthProvider             auth.IAuth
+	WebAuthn                 *webauthn.WebAuthn
+	MTLS                     *mtls.MTLS
+	LoginThrottle            *throttle.Throttle
 	CACertificate            string
 	TLSCertificate           string
 	TLSKey                   string
@@ -35,13 +40,17 @@ type HTTPD struct {
 }
 
 type dispatcher struct {
-	fs      fs.FS
-	auth    auth.IAuth
-	context context.Context
-	timeout time.Duration
-	mode    types.RunMode
-	withPIN bool
-	noSetup bool
+	fs           fs.FS
+	auth         auth.IAuth
+	webauthn     *webauthn.WebAuthn
+	mtls         *mtls.MTLS
+	certificates *certificates
+	throttle     *throttle.Throttle
+	context      context.Context
+	timeout      time.Duration
+	mode         types.RunMode
+	withPIN      bool
+	noSetup      bool
 }
 
 func (h *HTTPD) Run(mode types.RunMode, withPIN bool, noSetup bool, interrupt chan os.Signal) {
@@ -50,13 +59,17 @@ func (h *HTTPD) Run(mode types.RunMode, withPIN bool, noSetup bool, interrupt ch
 	defer cancel()
 
 	d := dispatcher{
-		fs:      html.HTML,
-		auth:    h.AuthProvider,
-		context: ctx,
-		timeout: h.RequestTimeout,
-		mode:    mode,
-		withPIN: withPIN,
-		noSetup: noSetup,
+		fs:           html.HTML,
+		auth:         h.AuthProvider,
+		webauthn:     h.WebAuthn,
+		mtls:         h.MTLS,
+		certificates: &certificates{},
+		throttle:     h.LoginThrottle,
+		context:      ctx,
+		timeout:      h.RequestTimeout,
+		mode:         mode,
+		withPIN:      withPIN,
+		noSetup:      noSetup,
 	}
 
 	if h.HTML != "" {
@@ -84,8 +97,11 @@ func (h *HTTPD) Run(mode types.RunMode, withPIN bool, noSetup bool, interrupt ch
 	mux.HandleFunc("/sys/doors.html", d.getWithAuth)
 	mux.HandleFunc("/sys/cards.html", d.getWithAuth)
 	mux.HandleFunc("/sys/groups.html", d.getWithAuth)
+	mux.HandleFunc("/sys/time-profiles.html", d.getWithAuth)
+	mux.HandleFunc("/sys/tasks.html", d.getWithAuth)
 	mux.HandleFunc("/sys/events.html", d.getWithAuth)
 	mux.HandleFunc("/sys/logs.html", d.getWithAuth)
+	mux.HandleFunc("/sys/sessions.html", d.getWithAuth)
 
 	mux.HandleFunc("/javascript/", d.getJS)
 
@@ -97,14 +113,35 @@ func (h *HTTPD) Run(mode types.RunMode, withPIN bool, noSetup bool, interrupt ch
 	mux.HandleFunc("/interfaces", d.dispatch)
 	mux.HandleFunc("/controllers", d.dispatch)
 	mux.HandleFunc("/doors", d.dispatch)
+	mux.HandleFunc("/doors/open", d.dispatch)
 	mux.HandleFunc("/cards", d.dispatch)
+	mux.HandleFunc("/cards/export", d.dispatch)
+	mux.HandleFunc("/cards/import", d.dispatch)
 	mux.HandleFunc("/groups", d.dispatch)
+	mux.HandleFunc("/time-profiles", d.dispatch)
+	mux.HandleFunc("/tasks", d.dispatch)
+	mux.HandleFunc("/tasks/runs", d.dispatch)
 	mux.HandleFunc("/events", d.dispatch)
 	mux.HandleFunc("/logs", d.dispatch)
 	mux.HandleFunc("/users", d.dispatch)
+	mux.HandleFunc("/tokens", d.dispatch)
+	mux.HandleFunc("/passkeys", d.dispatch)
+	mux.HandleFunc("/passkeys/register", d.dispatch)
+	mux.HandleFunc("/sessions", d.dispatch)
+	mux.HandleFunc("/backup", d.dispatch)
 	mux.HandleFunc("/synchronize/ACL", d.dispatch)
 	mux.HandleFunc("/synchronize/datetime", d.dispatch)
 	mux.HandleFunc("/synchronize/doors", d.dispatch)
+	mux.HandleFunc("/stream", d.stream)
+
+	if _, ok := d.auth.(auth.ISSO); ok {
+		mux.HandleFunc("/oidc/login", d.oidcLogin)
+		mux.HandleFunc("/oidc/callback", d.oidcCallback)
+	}
+
+	if d.webauthn != nil {
+		mux.HandleFunc("/webauthn/login", d.passkeyLogin)
+	}
 
 	mux.HandleFunc("/", d.getWithAuth)
 	mux.HandleFunc("/usr/", d.getNoAuth) // NTS: for custom user pages
@@ -122,20 +159,7 @@ func (h *HTTPD) Run(mode types.RunMode, withPIN bool, noSetup bool, interrupt ch
 	}
 
 	if h.HttpsEnabled {
-		ca, err := os.ReadFile(h.CACertificate)
-		if err != nil {
-			errorf("HTTPD", "Error reading CA certificate file (%v)", err)
-			return
-		}
-
-		certificates := x509.NewCertPool()
-		if !certificates.AppendCertsFromPEM(ca) {
-			errorf("HTTPD", "Error parsing CA certificate")
-			return
-		}
-
 		tlsConfig := tls.Config{
-			ClientCAs: certificates,
 			CipherSuites: []uint16{
 				tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
 				tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
@@ -144,6 +168,7 @@ func (h *HTTPD) Run(mode types.RunMode, withPIN bool, noSetup bool, interrupt ch
 			},
 			PreferServerCipherSuites: true,
 			MinVersion:               tls.VersionTLS12,
+			NextProtos:               []string{"h2", "http/1.1"},
 		}
 
 		if h.RequireClientCertificate {
@@ -152,12 +177,20 @@ func (h *HTTPD) Run(mode types.RunMode, withPIN bool, noSetup bool, interrupt ch
 			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
 		}
 
-		tlsConfig.BuildNameToCertificate()
+		keys, err := newKeystore(h.CACertificate, h.TLSCertificate, h.TLSKey, &tlsConfig)
+		if err != nil {
+			errorf("HTTPD", "%v", err)
+			return
+		}
 
 		srvs = &http.Server{
-			Addr:      fmt.Sprintf(":%v", h.HttpsPort),
-			TLSConfig: &tlsConfig,
-			Handler:   mux,
+			Addr: fmt.Sprintf(":%v", h.HttpsPort),
+			TLSConfig: &tls.Config{
+				MinVersion:         tls.VersionTLS12,
+				GetCertificate:     keys.getCertificate,
+				GetConfigForClient: keys.getConfigForClient,
+			},
+			Handler: mux,
 		}
 	}
 
@@ -200,7 +233,7 @@ func (h *HTTPD) Run(mode types.RunMode, withPIN bool, noSetup bool, interrupt ch
 	if srvs != nil {
 		go func() {
 			infof("HTTPD", "HTTPS server starting on port %v", srvs.Addr)
-			if err := srvs.ListenAndServeTLS(h.TLSCertificate, h.TLSKey); err != http.ErrServerClosed {
+			if err := srvs.ListenAndServeTLS("", ""); err != http.ErrServerClosed {
 				fatalf("HTTPD", "%v", err)
 			}
 		}()
@@ -231,19 +264,43 @@ func (d *dispatcher) dispatch(w http.ResponseWriter, r *http.Request) {
 }
 
 func (d *dispatcher) authenticated(r *http.Request, w http.ResponseWriter) (string, string, bool) {
+	if token, ok := bearer(r); ok {
+		return d.authenticatedToken(r, token)
+	}
+
 	cookie, err := r.Cookie(cookies.SessionCookie)
 	if err != nil {
+		if uid, role, ok := d.certificateLogin(r, w); ok {
+			return uid, role, true
+		}
+
 		warnf("HTTPD", "No session cookie in request")
 		return "", "", false
 	}
 
-	uid, role, cookie2, err := d.auth.Authenticated(cookie)
+	uid, role, cookie2, err := d.auth.Authenticated(cookie, auth.Client(r))
 
 	if err != nil {
+		if uid, role, ok := d.certificateLogin(r, w); ok {
+			return uid, role, true
+		}
+
 		warnf("HTTPD", "%v", err)
 		return "", "", false
 	}
 
+	if d.mtls != nil {
+		if err := d.mtls.Verify(r.TLS); err != nil {
+			warnf("MTLS", "%v", err)
+			d.auth.Logout(cookie)
+			if fingerprint, ok := fingerprint(r); ok {
+				d.certificates.delete(fingerprint)
+			}
+			cookies.Clear(w, cookies.SessionCookie)
+			return "", "", false
+		}
+	}
+
 	if cookie2 != nil {
 		http.SetCookie(w, cookie2)
 	}
@@ -261,11 +318,22 @@ func (d *dispatcher) authorised(uid, role, path string) bool {
 }
 
 func (d *dispatcher) unauthenticated(r *http.Request, w http.ResponseWriter) {
+	if _, ok := bearer(r); ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="uhppoted-httpd"`)
+		http.Error(w, "Invalid or insufficient API token", http.StatusUnauthorized)
+		return
+	}
+
 	cookies.Clear(w, cookies.SessionCookie, cookies.OTPCookie)
 	http.Redirect(w, r, "/sys/login.html", http.StatusFound)
 }
 
 func (d *dispatcher) unauthorised(r *http.Request, w http.ResponseWriter) {
+	if _, ok := bearer(r); ok {
+		http.Error(w, "Not authorised", http.StatusForbidden)
+		return
+	}
+
 	http.Redirect(w, r, "/sys/unauthorized.html", http.StatusFound)
 }
 
@@ -1,8 +1,17 @@
 package httpd
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"reflect"
 	"testing"
+
+	core "github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/httpd/cookies"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
 )
 
 func TestResolveURL(t *testing.T) {
@@ -22,3 +31,147 @@ func TestResolveURL(t *testing.T) {
 		t.Errorf("URL %v incorrectly resolved - expected:%v, got:%v", u, expected, resolved)
 	}
 }
+
+func TestScoped(t *testing.T) {
+	tests := []struct {
+		scopes   []string
+		method   string
+		path     string
+		expected bool
+	}{
+		{[]string{"cards:read"}, "GET", "/cards", true},
+		{[]string{"cards:read"}, "POST", "/cards", false},
+		{[]string{"cards:write"}, "POST", "/cards/import", true},
+		{[]string{"cards:write"}, "GET", "/cards/export", false},
+		{[]string{"cards:*"}, "GET", "/cards/export", true},
+		{[]string{"*:read"}, "GET", "/events", true},
+		{[]string{"*:read"}, "DELETE", "/events", false},
+		{[]string{"cards:read", "doors:write"}, "POST", "/doors/open", true},
+		{[]string{"*"}, "POST", "/synchronize/ACL", true},
+		{[]string{"backup:read"}, "GET", "/backup", true},
+		{[]string{}, "GET", "/cards", false},
+	}
+
+	for _, test := range tests {
+		if v := scoped(test.scopes, test.method, test.path); v != test.expected {
+			t.Errorf("Incorrect scope check for %v %v with %v - expected:%v, got:%v", test.method, test.path, test.scopes, test.expected, v)
+		}
+	}
+}
+
+func TestRevalidateStream(t *testing.T) {
+	d := dispatcher{
+		auth: &stub{
+			tokens:   map[string][]string{"qwerty": {"stream:read"}, "uiop": {"cards:read"}},
+			sessions: map[string]bool{"abc123": true},
+		},
+	}
+
+	tests := []struct {
+		token    string
+		session  string
+		expected bool
+	}{
+		{"qwerty", "", true},
+		{"uiop", "", false},
+		{"asdf", "", false},
+		{"", "abc123", true},
+		{"", "def456", false},
+		{"", "", false},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/stream", nil)
+		if test.token != "" {
+			r.Header.Set("Authorization", "Bearer "+test.token)
+		}
+
+		if test.session != "" {
+			r.AddCookie(&http.Cookie{Name: cookies.SessionCookie, Value: test.session})
+		}
+
+		if _, v := d.revalidate(r); v != test.expected {
+			t.Errorf("Incorrect stream revalidation for token:'%v' session:'%v' - expected:%v, got:%v", test.token, test.session, test.expected, v)
+		}
+	}
+}
+
+func TestStreamScope(t *testing.T) {
+	d := dispatcher{
+		auth: &stub{
+			tokens: map[string][]string{
+				"qwerty": {"stream:read"},
+				"uiop":   {"stream:read", "cards:read", "events:read"},
+				"asdf":   {"*:read"},
+			},
+			sessions: map[string]bool{"abc123": true},
+		},
+	}
+
+	all := []schema.OID{
+		schema.InterfacesOID,
+		schema.ControllersOID,
+		schema.DoorsOID,
+		schema.CardsOID,
+		schema.GroupsOID,
+		schema.EventsOID,
+		schema.LogsOID,
+		schema.UsersOID,
+		schema.TimeProfilesOID,
+		schema.TasksOID,
+	}
+
+	tests := []struct {
+		token    string
+		session  string
+		expected []schema.OID
+	}{
+		{"qwerty", "", []schema.OID{}},
+		{"uiop", "", []schema.OID{schema.CardsOID, schema.EventsOID}},
+		{"asdf", "", all},
+		{"", "abc123", all},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/stream", nil)
+		if test.token != "" {
+			r.Header.Set("Authorization", "Bearer "+test.token)
+		}
+
+		if test.session != "" {
+			r.AddCookie(&http.Cookie{Name: cookies.SessionCookie, Value: test.session})
+		}
+
+		if v, ok := d.revalidate(r); !ok {
+			t.Errorf("Stream revalidation failed for token:'%v' session:'%v'", test.token, test.session)
+		} else if !reflect.DeepEqual(v, test.expected) {
+			t.Errorf("Incorrect stream scope for token:'%v' session:'%v'\n   expected:%v\n   got:     %v", test.token, test.session, test.expected, v)
+		}
+	}
+}
+
+type stub struct {
+	auth.IAuth
+	tokens   map[string][]string
+	sessions map[string]bool
+}
+
+func (s *stub) Authenticated(cookie *http.Cookie, client core.Client) (string, string, *http.Cookie, error) {
+	if s.sessions[cookie.Value] {
+		return "qwerty", "admin", nil, nil
+	}
+
+	return "", "", nil, fmt.Errorf("invalid session")
+}
+
+func (s *stub) AuthenticatedToken(token string) (string, string, []string, error) {
+	if scopes, ok := s.tokens[token]; ok {
+		return "qwerty", "admin", scopes, nil
+	}
+
+	return "", "", nil, fmt.Errorf("invalid token")
+}
+
+func (s *stub) Authorised(uid, role, path string) error {
+	return nil
+}
@@ -0,0 +1,136 @@
+package httpd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/uhppoted/uhppoted-httpd/auth/mtls"
+	"github.com/uhppoted/uhppoted-httpd/httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/httpd/cookies"
+)
+
+// certificates binds the sessions started with a client certificate to the certificate, so that
+// requests without a session cookie (e.g. from API clients) reuse the existing session rather than
+// logging in again.
+type certificates struct {
+	sessions map[string]*http.Cookie
+	sync.Mutex
+}
+
+// Returns the session for the verified client certificate for a request that does not have a valid
+// session cookie, starting a new session if the certificate does not already have a valid session.
+func (d *dispatcher) certificateLogin(r *http.Request, w http.ResponseWriter) (string, string, bool) {
+	if d.mtls == nil {
+		return "", "", false
+	}
+
+	fingerprint, ok := fingerprint(r)
+	if !ok {
+		return "", "", false
+	}
+
+	if cookie := d.certificates.get(fingerprint); cookie != nil {
+		if err := d.mtls.Verify(r.TLS); err != nil {
+			warnf("MTLS", "%v", err)
+			d.endCertificateSession(fingerprint, w)
+			return "", "", false
+		}
+
+		if uid, role, cookie2, err := d.auth.Authenticated(cookie, auth.Client(r)); err == nil {
+			if cookie2 != nil {
+				cookie = cookie2
+				d.certificates.put(fingerprint, cookie)
+			}
+
+			http.SetCookie(w, cookie)
+
+			return uid, role, true
+		}
+
+		d.certificates.delete(fingerprint)
+	}
+
+	uid, role, err := d.mtls.Login(r.TLS)
+	if errors.Is(err, mtls.ErrNoCertificate) {
+		return "", "", false
+	} else if err != nil {
+		warnf("MTLS", "%v", err)
+		return "", "", false
+	}
+
+	session, err := d.auth.Session(uid, role)
+	if err != nil {
+		warnf("MTLS", "%v", err)
+		return "", "", false
+	}
+
+	if session != nil {
+		d.certificates.put(fingerprint, session)
+		http.SetCookie(w, session)
+	}
+
+	infof("MTLS", "%v logged in with client certificate", uid)
+
+	return uid, role, true
+}
+
+// Ends the session bound to a client certificate that is no longer valid (e.g. revoked).
+func (d *dispatcher) endCertificateSession(fingerprint string, w http.ResponseWriter) {
+	if cookie := d.certificates.get(fingerprint); cookie != nil {
+		d.auth.Logout(cookie)
+		d.certificates.delete(fingerprint)
+	}
+
+	cookies.Clear(w, cookies.SessionCookie)
+}
+
+// Returns the SHA-256 fingerprint of the client certificate for a request.
+func fingerprint(r *http.Request) (string, bool) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return "", false
+	}
+
+	hash := sha256.Sum256(r.TLS.PeerCertificates[0].Raw)
+
+	return hex.EncodeToString(hash[:]), true
+}
+
+func (c *certificates) get(fingerprint string) *http.Cookie {
+	if c == nil {
+		return nil
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	return c.sessions[fingerprint]
+}
+
+func (c *certificates) put(fingerprint string, cookie *http.Cookie) {
+	if c == nil {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if c.sessions == nil {
+		c.sessions = map[string]*http.Cookie{}
+	}
+
+	c.sessions[fingerprint] = cookie
+}
+
+func (c *certificates) delete(fingerprint string) {
+	if c == nil {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	delete(c.sessions, fingerprint)
+}
@@ -0,0 +1,84 @@
+package httpd
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/uhppoted/uhppoted-httpd/httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/httpd/cookies"
+)
+
+// Redirects the browser to the identity provider login page.
+func (d *dispatcher) oidcLogin(w http.ResponseWriter, r *http.Request) {
+	if strings.ToUpper(r.Method) != http.MethodGet {
+		http.Error(w, "Invalid request", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sso, ok := d.auth.(auth.ISSO)
+	if !ok {
+		http.Error(w, "Single sign-on not enabled", http.StatusNotFound)
+		return
+	}
+
+	url, cookie, err := sso.Login()
+	if err != nil {
+		warnf("OIDC", "%v", err)
+		http.Error(w, "Error starting single sign-on login", http.StatusBadGateway)
+		return
+	}
+
+	http.SetCookie(w, cookie)
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+// Completes an identity provider login and starts a session.
+//
+// NTS: the session cookie is SameSite=Strict and is not sent with the requests following a
+//
+//	cross-site redirect so the response 'refreshes' to the start page rather than
+//	redirecting to it
+func (d *dispatcher) oidcCallback(w http.ResponseWriter, r *http.Request) {
+	if strings.ToUpper(r.Method) != http.MethodGet {
+		http.Error(w, "Invalid request", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sso, ok := d.auth.(auth.ISSO)
+	if !ok {
+		http.Error(w, "Single sign-on not enabled", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	cookie, _ := r.Cookie(cookies.OIDCCookie)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookies.OIDCCookie,
+		Value:    "",
+		Path:     "/oidc",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	if e := query.Get("error"); e != "" {
+		warnf("OIDC", "identity provider error (%v: %v)", e, query.Get("error_description"))
+		http.Redirect(w, r, "/sys/unauthorized.html", http.StatusFound)
+		return
+	}
+
+	session, err := sso.Callback(r.Context(), cookie, query.Get("state"), query.Get("code"))
+	if err != nil {
+		warnf("OIDC", "%v", err)
+		http.Redirect(w, r, "/sys/unauthorized.html", http.StatusFound)
+		return
+	}
+
+	http.SetCookie(w, session)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	fmt.Fprint(w, `<!DOCTYPE html><html><head><meta http-equiv="refresh" content="0;url=/index.html"></head><body></body></html>`)
+}
@@ -20,7 +20,7 @@ func (d *dispatcher) post(w http.ResponseWriter, r *http.Request) {
 
 	// ... allow unauthenticated access to /authenticate, /logout and /setup
 	if path == "/authenticate" {
-		post.Login(w, r, d.auth)
+		post.Login(w, r, d.auth, d.webauthn, d.throttle)
 		return
 	}
 
@@ -58,8 +58,12 @@ func (d *dispatcher) post(w http.ResponseWriter, r *http.Request) {
 		"/interfaces",
 		"/controllers",
 		"/doors",
+		"/doors/open",
 		"/cards",
+		"/cards/import",
 		"/groups",
+		"/time-profiles",
+		"/tasks",
 		"/users":
 		if handler := d.vtable(path); handler == nil || handler.post == nil {
 			warnf("HTTPD", "No vtable entry for %v", path)
@@ -104,6 +108,21 @@ func (d *dispatcher) post(w http.ResponseWriter, r *http.Request) {
 			return users.VerifyOTP(uid, role, w, r, d.auth)
 		})
 
+	case "/tokens":
+		d.exec2(w, r, func() (any, error) {
+			return users.CreateToken(uid, role, w, r, d.auth)
+		})
+
+	case "/passkeys/register":
+		d.exec2(w, r, func() (any, error) {
+			return users.BeginPasskey(uid, role, w, r, d.auth, d.webauthn)
+		})
+
+	case "/passkeys":
+		d.exec2(w, r, func() (any, error) {
+			return users.RegisterPasskey(uid, role, w, r, d.webauthn)
+		})
+
 	default:
 		http.Error(w, "API not implemented", http.StatusNotImplemented)
 	}
@@ -1,13 +1,26 @@
 package post
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/uhppoted/uhppoted-httpd/auth/webauthn"
 	"github.com/uhppoted/uhppoted-httpd/httpd/auth"
 	"github.com/uhppoted/uhppoted-httpd/httpd/cookies"
+	"github.com/uhppoted/uhppoted-httpd/httpd/throttle"
 )
 
-func Login(w http.ResponseWriter, r *http.Request, auth auth.IAuth) {
+// Authenticates a user with a UID and password. If passkeys are configured as a second factor
+// and the user has registered a passkey, the password is verified without issuing a session and
+// the response is the passkey assertion request - the session is only issued once the passkey
+// login has been completed (POST /webauthn/login).
+//
+// Failed logins are throttled per client IP address and per user ID with an exponential backoff.
+// The login attempt is reserved before the credentials are verified so that parallel requests
+// cannot bypass the backoff.
+func Login(w http.ResponseWriter, r *http.Request, a auth.IAuth, passkeys *webauthn.WebAuthn, limiter *throttle.Throttle) {
 	var uid string
 	var pwd string
 
@@ -34,16 +47,86 @@ func Login(w http.ResponseWriter, r *http.Request, auth auth.IAuth) {
 		return
 	}
 
-	sessionCookie, err := auth.Authenticate(uid, pwd, loginCookie)
+	address := auth.Client(r).Address
+	keys := []string{"ip:" + address, "uid:" + uid}
+
+	if wait := limiter.Wait(keys...); wait > 0 {
+		seconds := int(wait.Round(time.Second).Seconds())
+
+		warnf("LOGIN", "%v login from %v throttled for %vs", uid, address, seconds)
+		w.Header().Set("Retry-After", fmt.Sprintf("%v", seconds))
+		http.Error(w, fmt.Sprintf("Too many failed login attempts - please try again in %vs", seconds), http.StatusTooManyRequests)
+		return
+	}
+
+	var sessionCookie *http.Cookie
+	var role string
+
+	twoFactor := passkeys != nil && passkeys.Required(uid)
+
+	if twoFactor {
+		role, err = a.Credentials(uid, pwd, loginCookie)
+	} else {
+		sessionCookie, err = a.Authenticate(uid, pwd, loginCookie)
+	}
+
 	if err != nil {
-		warnf("LOGIN", "%v", err)
+		warnf("LOGIN", "%v (%v)", err, address)
+		limiter.Failed(keys...)
 		http.Error(w, "Invalid login credentials", http.StatusUnauthorized)
 		return
 	}
 
+	// ... failed attempts for the user are only cleared once the passkey login has completed
+	if twoFactor {
+		limiter.Release(keys...)
+		secondFactor(w, uid, role, passkeys)
+		return
+	}
+
+	limiter.Release("ip:" + address)
+	limiter.Succeeded("uid:" + uid)
+
 	if sessionCookie != nil {
 		http.SetCookie(w, sessionCookie)
 	}
 
 	cookies.Clear(w, cookies.LoginCookie)
 }
+
+func secondFactor(w http.ResponseWriter, uid, role string, passkeys *webauthn.WebAuthn) {
+	options, key, err := passkeys.BeginSecondFactor(uid, role)
+	if err != nil {
+		warnf("LOGIN", "%v", err)
+		http.Error(w, "Error starting passkey login", http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		Passkey any `json:"passkey"`
+	}{
+		Passkey: options,
+	}
+
+	b, err := json.Marshal(response)
+	if err != nil {
+		warnf("LOGIN", "%v", err)
+		http.Error(w, "Error starting passkey login", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookies.WebAuthnCookie,
+		Value:    key,
+		Path:     "/",
+		MaxAge:   int((5 * time.Minute).Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+		//  Secure:   true,
+	})
+
+	cookies.Clear(w, cookies.LoginCookie)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
@@ -0,0 +1,170 @@
+package httpd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/uhppoted/uhppoted-httpd/httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/httpd/cookies"
+	"github.com/uhppoted/uhppoted-httpd/system"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+)
+
+// Interval between 'keep-alive' comments on an idle stream. The session (or API token) is also
+// re-validated at this interval so that a stream is closed when the user logs out, the session
+// expires or the token is revoked.
+const STREAM_KEEPALIVE = 15 * time.Second
+
+// Resources for which object updates are published on the stream.
+var streamed = []struct {
+	path string
+	oid  schema.OID
+}{
+	{"/interfaces", schema.InterfacesOID},
+	{"/controllers", schema.ControllersOID},
+	{"/doors", schema.DoorsOID},
+	{"/cards", schema.CardsOID},
+	{"/groups", schema.GroupsOID},
+	{"/events", schema.EventsOID},
+	{"/logs", schema.LogsOID},
+	{"/users", schema.UsersOID},
+	{"/time-profiles", schema.TimeProfilesOID},
+	{"/tasks", schema.TasksOID},
+}
+
+// Server-Sent Events stream of object updates. Each message is a JSON array of schema.Object's,
+// filtered by the same rules as the GET request for the associated resource - objects are only
+// published for resources the user (and API token, if any) is authorised to GET.
+func (d *dispatcher) stream(w http.ResponseWriter, r *http.Request) {
+	if strings.ToUpper(r.Method) != http.MethodGet {
+		http.Error(w, "Invalid request", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path, err := resolve(r.URL)
+	if err != nil {
+		http.Error(w, "invalid URL", http.StatusBadRequest)
+		return
+	}
+
+	// ... authenticated and authorised?
+	// NTS: EventSource doesn't do anything sensible with a redirect so just return an HTTP error
+	uid, role, ok := d.authenticated(r, w)
+	if !ok {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	if !d.authorised(uid, role, path) {
+		http.Error(w, "Not authorised", http.StatusForbidden)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	scope := d.scope(r, uid, role)
+
+	// ... good to go
+	ch := make(chan []schema.Object)
+	q := make(chan struct{})
+	keepalive := time.NewTicker(STREAM_KEEPALIVE)
+
+	defer close(q)
+	defer keepalive.Stop()
+
+	go system.Stream(uid, role, scope, ch, q)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	infof("HTTPD", "%v opened event stream", uid)
+
+	for {
+		select {
+		case <-r.Context().Done():
+			infof("HTTPD", "%v closed event stream", uid)
+			return
+
+		case <-d.context.Done():
+			return
+
+		case objects := <-ch:
+			if b, err := json.Marshal(objects); err != nil {
+				warnf("HTTPD", "%v", err)
+			} else {
+				fmt.Fprintf(w, "data: %s\n\n", b)
+				flusher.Flush()
+			}
+
+		case <-keepalive.C:
+			if v, ok := d.revalidate(r); !ok {
+				infof("HTTPD", "%v event stream session expired", uid)
+				return
+			} else if !slices.Equal(v, scope) {
+				infof("HTTPD", "%v event stream authorisation changed", uid)
+				return
+			}
+
+			fmt.Fprintf(w, ": keep-alive\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// Returns the OIDs of the resources the user is authorised to GET and, if the stream was opened
+// with an API bearer token, for which the token has a read scope.
+func (d *dispatcher) scope(r *http.Request, uid, role string) []schema.OID {
+	var scopes []string
+
+	token, isToken := bearer(r)
+	if isToken {
+		if _, _, v, err := d.auth.AuthenticatedToken(token); err == nil {
+			scopes = v
+		}
+	}
+
+	scope := []schema.OID{}
+	for _, v := range streamed {
+		if isToken && !scoped(scopes, http.MethodGet, v.path) {
+			continue
+		}
+
+		if err := d.auth.Authorised(uid, role, v.path); err == nil {
+			scope = append(scope, v.oid)
+		}
+	}
+
+	return scope
+}
+
+// Re-validates the credentials with which the stream was opened i.e. the API bearer token if
+// the stream was opened with a token, otherwise the session cookie, returning the resources
+// the stream is (still) authorised for.
+func (d *dispatcher) revalidate(r *http.Request) ([]schema.OID, bool) {
+	if token, ok := bearer(r); ok {
+		if uid, role, ok := d.authenticatedToken(r, token); ok {
+			return d.scope(r, uid, role), true
+		}
+
+		return nil, false
+	}
+
+	if cookie, err := r.Cookie(cookies.SessionCookie); err != nil {
+		return nil, false
+	} else if uid, role, _, err := d.auth.Authenticated(cookie, auth.Client(r)); err != nil {
+		return nil, false
+	} else {
+		return d.scope(r, uid, role), true
+	}
+}
@@ -0,0 +1,49 @@
+package tasks
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/uhppoted/uhppoted-httpd/system"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+)
+
+func Get(uid, role string) any {
+	return struct {
+		Tasks any `json:"tasks"`
+	}{
+		Tasks: system.Tasks(uid, role),
+	}
+}
+
+func Post(uid, role string, body map[string]any) (any, error) {
+	updated, err := system.UpdateTasks(uid, role, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return struct {
+		Tasks any `json:"tasks"`
+	}{
+		Tasks: updated,
+	}, nil
+}
+
+// Returns the run history (latest first) for the task specified by the 'task' query parameter,
+// or for all tasks if no task is specified. The number of runs returned is limited by the optional
+// 'count' query parameter.
+func Runs(uid, role string, rq *http.Request) any {
+	task := schema.OID(strings.TrimSpace(rq.FormValue("task")))
+	count := 0
+
+	if v, err := strconv.ParseUint(rq.FormValue("count"), 10, 32); err == nil {
+		count = int(v)
+	}
+
+	return struct {
+		Runs any `json:"runs"`
+	}{
+		Runs: system.TaskRuns(uid, role, task, count),
+	}
+}
@@ -0,0 +1,175 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// Throttle implements an exponential backoff for failed login attempts. Each key (e.g. client IP
+// address or user ID) is allowed a number of free failed attempts after which every subsequent
+// attempt has to wait twice as long as the previous one, up to the maximum delay. A key is
+// forgotten once it has been idle for longer than the maximum delay.
+//
+// An attempt is reserved by Wait and completed by Failed, Release or Succeeded so that concurrent
+// attempts cannot all be allowed before any of them has failed.
+type Throttle struct {
+	attempts uint32
+	delay    time.Duration
+	maxDelay time.Duration
+	entries  map[string]*entry
+	sync.Mutex
+}
+
+type entry struct {
+	failed  uint32
+	pending uint32
+	next    time.Time
+	touched time.Time
+}
+
+// Returns a new throttle, or nil if the delay is zero (i.e. throttling is disabled). The methods
+// of a nil throttle are no-ops.
+func NewThrottle(attempts uint32, delay, maxDelay time.Duration) *Throttle {
+	if delay <= 0 {
+		return nil
+	}
+
+	if maxDelay < delay {
+		maxDelay = delay
+	}
+
+	return &Throttle{
+		attempts: attempts,
+		delay:    delay,
+		maxDelay: maxDelay,
+		entries:  map[string]*entry{},
+	}
+}
+
+// Returns the time remaining until another attempt is allowed for all the keys, or zero if an
+// attempt is allowed now. An allowed attempt is reserved (as a pending attempt) for each key
+// until it is completed by Failed, Release or Succeeded. An attempt that would be delayed if the
+// pending attempts were to fail is not allowed until the pending attempts have completed.
+func (t *Throttle) Wait(keys ...string) time.Duration {
+	if t == nil {
+		return 0
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	now := time.Now()
+	wait := time.Duration(0)
+
+	for _, k := range keys {
+		if e, ok := t.entries[k]; ok {
+			if e.next.After(now) {
+				wait = max(wait, e.next.Sub(now))
+			} else if delay := t.backoff(e.failed + e.pending + 1); e.pending > 0 && delay > 0 {
+				wait = max(wait, delay)
+			}
+		}
+	}
+
+	if wait == 0 {
+		for _, k := range keys {
+			e, ok := t.entries[k]
+			if !ok {
+				e = &entry{}
+				t.entries[k] = e
+			}
+
+			e.pending++
+			e.touched = now
+		}
+	}
+
+	return wait
+}
+
+// Records a failed attempt for each of the keys, completing the attempt reserved by Wait.
+func (t *Throttle) Failed(keys ...string) {
+	if t == nil {
+		return
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	now := time.Now()
+
+	t.sweep(now)
+
+	for _, k := range keys {
+		e, ok := t.entries[k]
+		if !ok {
+			e = &entry{}
+			t.entries[k] = e
+		}
+
+		if e.pending > 0 {
+			e.pending--
+		}
+
+		e.failed++
+		e.touched = now
+		e.next = now.Add(t.backoff(e.failed))
+	}
+}
+
+// Completes the attempt reserved by Wait for each of the keys without recording a failed attempt
+// or clearing the failed attempts e.g. when a password has been verified but the login still
+// requires a second factor.
+func (t *Throttle) Release(keys ...string) {
+	if t == nil {
+		return
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	for _, k := range keys {
+		if e, ok := t.entries[k]; ok && e.pending > 0 {
+			e.pending--
+
+			if e.pending == 0 && e.failed == 0 {
+				delete(t.entries, k)
+			}
+		}
+	}
+}
+
+// Clears the failed (and pending) attempts recorded for each of the keys.
+func (t *Throttle) Succeeded(keys ...string) {
+	if t == nil {
+		return
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	for _, k := range keys {
+		delete(t.entries, k)
+	}
+}
+
+func (t *Throttle) backoff(failed uint32) time.Duration {
+	if failed <= t.attempts {
+		return 0
+	}
+
+	delay := t.delay
+	for i := t.attempts + 1; i < failed && delay < t.maxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, t.maxDelay)
+}
+
+func (t *Throttle) sweep(now time.Time) {
+	for k, e := range t.entries {
+		if now.Sub(e.touched) > t.maxDelay && !e.next.After(now) {
+			delete(t.entries, k)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	throttle := NewThrottle(3, 1*time.Second, 10*time.Second)
+
+	tests := []struct {
+		failed   uint32
+		expected time.Duration
+	}{
+		{1, 0},
+		{3, 0},
+		{4, 1 * time.Second},
+		{5, 2 * time.Second},
+		{6, 4 * time.Second},
+		{7, 8 * time.Second},
+		{8, 10 * time.Second},
+		{100, 10 * time.Second},
+	}
+
+	for _, test := range tests {
+		if delay := throttle.backoff(test.failed); delay != test.expected {
+			t.Errorf("Incorrect backoff after %v failed attempts - expected:%v, got:%v", test.failed, test.expected, delay)
+		}
+	}
+}
+
+func TestWait(t *testing.T) {
+	throttle := NewThrottle(2, 1*time.Minute, 10*time.Minute)
+
+	throttle.Failed("ip:127.0.0.1", "uid:moony")
+	throttle.Failed("ip:127.0.0.1", "uid:moony")
+
+	if wait := throttle.Wait("ip:127.0.0.1", "uid:moony"); wait != 0 {
+		t.Errorf("Unexpected wait before backoff - expected:%v, got:%v", 0, wait)
+	}
+
+	throttle.Failed("ip:127.0.0.1", "uid:moony")
+
+	if wait := throttle.Wait("ip:127.0.0.1", "uid:padfoot"); wait <= 0 || wait > 1*time.Minute {
+		t.Errorf("Incorrect per-IP wait - expected:%v, got:%v", 1*time.Minute, wait)
+	}
+
+	if wait := throttle.Wait("ip:127.0.0.2", "uid:moony"); wait <= 0 || wait > 1*time.Minute {
+		t.Errorf("Incorrect per-account wait - expected:%v, got:%v", 1*time.Minute, wait)
+	}
+
+	if wait := throttle.Wait("ip:127.0.0.2", "uid:padfoot"); wait != 0 {
+		t.Errorf("Unexpected wait for unrelated keys - expected:%v, got:%v", 0, wait)
+	}
+
+	throttle.Succeeded("uid:moony")
+
+	if wait := throttle.Wait("ip:127.0.0.2", "uid:moony"); wait != 0 {
+		t.Errorf("Unexpected wait after successful login - expected:%v, got:%v", 0, wait)
+	}
+}
+
+func TestWaitWithPendingAttempts(t *testing.T) {
+	throttle := NewThrottle(2, 1*time.Minute, 10*time.Minute)
+
+	// ... parallel attempts are only allowed up to the number of free attempts
+	for i := 0; i < 2; i++ {
+		if wait := throttle.Wait("ip:127.0.0.1", "uid:moony"); wait != 0 {
+			t.Errorf("Unexpected wait for free attempt %v - expected:%v, got:%v", i+1, 0, wait)
+		}
+	}
+
+	if wait := throttle.Wait("ip:127.0.0.1", "uid:padfoot"); wait <= 0 || wait > 1*time.Minute {
+		t.Errorf("Incorrect wait with pending attempts - expected:%v, got:%v", 1*time.Minute, wait)
+	}
+
+	// ... a released attempt is not counted as a failed attempt
+	throttle.Release("ip:127.0.0.1", "uid:moony")
+
+	if wait := throttle.Wait("ip:127.0.0.1", "uid:padfoot"); wait != 0 {
+		t.Errorf("Unexpected wait after released attempt - expected:%v, got:%v", 0, wait)
+	}
+
+	throttle.Failed("ip:127.0.0.1", "uid:moony")
+	throttle.Failed("ip:127.0.0.1", "uid:padfoot")
+
+	if wait := throttle.Wait("ip:127.0.0.1"); wait != 0 {
+		t.Errorf("Unexpected wait before backoff - expected:%v, got:%v", 0, wait)
+	}
+
+	throttle.Failed("ip:127.0.0.1")
+
+	if wait := throttle.Wait("ip:127.0.0.1"); wait <= 0 || wait > 1*time.Minute {
+		t.Errorf("Incorrect wait after failed attempts - expected:%v, got:%v", 1*time.Minute, wait)
+	}
+}
+
+func TestDisabledThrottle(t *testing.T) {
+	throttle := NewThrottle(0, 0, 10*time.Minute)
+
+	if throttle != nil {
+		t.Fatalf("Expected nil throttle for zero delay")
+	}
+
+	throttle.Failed("ip:127.0.0.1")
+
+	if wait := throttle.Wait("ip:127.0.0.1"); wait != 0 {
+		t.Errorf("Unexpected wait for disabled throttle - expected:%v, got:%v", 0, wait)
+	}
+}
@@ -0,0 +1,26 @@
+package timeprofiles
+
+import (
+	"github.com/uhppoted/uhppoted-httpd/system"
+)
+
+func Get(uid, role string) any {
+	return struct {
+		TimeProfiles any `json:"time-profiles"`
+	}{
+		TimeProfiles: system.TimeProfiles(uid, role),
+	}
+}
+
+func Post(uid, role string, body map[string]any) (any, error) {
+	updated, err := system.UpdateTimeProfiles(uid, role, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return struct {
+		TimeProfiles any `json:"time-profiles"`
+	}{
+		TimeProfiles: updated,
+	}, nil
+}
@@ -0,0 +1,110 @@
+package httpd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// keystore holds the HTTPS server certificate and the CA certificate used to verify client
+// certificates, reloading them whenever the files are modified so that renewed certificates
+// are used without restarting the server.
+type keystore struct {
+	ca          string
+	certificate string
+	key         string
+	base        *tls.Config
+	modified    map[string]time.Time
+	cert        *tls.Certificate
+	config      *tls.Config
+	sync.Mutex
+}
+
+func newKeystore(ca, certificate, key string, base *tls.Config) (*keystore, error) {
+	k := keystore{
+		ca:          ca,
+		certificate: certificate,
+		key:         key,
+		base:        base,
+	}
+
+	if err := k.load(); err != nil {
+		return nil, err
+	}
+
+	return &k, nil
+}
+
+// Returns the TLS configuration for a client connection, reloading the certificates if any
+// of the files have changed.
+func (k *keystore) getConfigForClient(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+	k.refresh()
+
+	k.Lock()
+	defer k.Unlock()
+
+	return k.config, nil
+}
+
+func (k *keystore) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	k.Lock()
+	defer k.Unlock()
+
+	return k.cert, nil
+}
+
+func (k *keystore) refresh() {
+	k.Lock()
+	defer k.Unlock()
+
+	for _, file := range []string{k.ca, k.certificate, k.key} {
+		if info, err := os.Stat(file); err == nil && !info.ModTime().Equal(k.modified[file]) {
+			if err := k.load(); err != nil {
+				warnf("HTTPD", "Error reloading TLS certificates (%v)", err)
+			} else {
+				infof("HTTPD", "Reloaded TLS certificates")
+			}
+
+			return
+		}
+	}
+}
+
+// Loads the CA certificate and the server key and certificate. The file modification times are
+// updated even if the load fails so that a failed reload is only retried after the next change
+// (e.g. when a new certificate has been written but the matching key has not).
+func (k *keystore) load() error {
+	k.modified = map[string]time.Time{}
+	for _, file := range []string{k.ca, k.certificate, k.key} {
+		if info, err := os.Stat(file); err == nil {
+			k.modified[file] = info.ModTime()
+		}
+	}
+
+	ca, err := os.ReadFile(k.ca)
+	if err != nil {
+		return fmt.Errorf("error reading CA certificate file (%v)", err)
+	}
+
+	certificates := x509.NewCertPool()
+	if !certificates.AppendCertsFromPEM(ca) {
+		return fmt.Errorf("error parsing CA certificate")
+	}
+
+	cert, err := tls.LoadX509KeyPair(k.certificate, k.key)
+	if err != nil {
+		return fmt.Errorf("error loading TLS key and certificate (%v)", err)
+	}
+
+	config := k.base.Clone()
+	config.ClientCAs = certificates
+	config.GetCertificate = k.getCertificate
+
+	k.cert = &cert
+	k.config = config
+
+	return nil
+}
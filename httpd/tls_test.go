@@ -0,0 +1,113 @@
+package httpd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeKeypair(t *testing.T, dir string, serial int64) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	mtime := time.Now().Add(time.Duration(serial) * time.Second)
+
+	for file, bytes := range map[string][]byte{
+		"ca.cert":       cert,
+		"uhppoted.cert": cert,
+		"uhppoted.key":  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}),
+	} {
+		path := filepath.Join(dir, file)
+		if err := os.WriteFile(path, bytes, 0600); err != nil {
+			t.Fatalf("%v", err)
+		} else if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+}
+
+func serialNumber(t *testing.T, k *keystore) int64 {
+	if _, err := k.getConfigForClient(&tls.ClientHelloInfo{}); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	cert, err := k.getCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	return cert.Leaf.SerialNumber.Int64()
+}
+
+func TestKeystoreReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := filepath.Join(dir, "ca.cert")
+	certificate := filepath.Join(dir, "uhppoted.cert")
+	key := filepath.Join(dir, "uhppoted.key")
+
+	writeKeypair(t, dir, 1)
+
+	k, err := newKeystore(ca, certificate, key, &tls.Config{MinVersion: tls.VersionTLS12})
+	if err != nil {
+		t.Fatalf("Error loading keystore (%v)", err)
+	}
+
+	if serial := serialNumber(t, k); serial != 1 {
+		t.Errorf("Incorrect certificate - expected serial number %v, got %v", 1, serial)
+	}
+
+	// ... renewed certificate
+	writeKeypair(t, dir, 2)
+
+	if serial := serialNumber(t, k); serial != 2 {
+		t.Errorf("Renewed certificate not reloaded - expected serial number %v, got %v", 2, serial)
+	}
+
+	if config, _ := k.getConfigForClient(&tls.ClientHelloInfo{}); config.ClientCAs == nil || config.GetCertificate == nil {
+		t.Errorf("Invalid client TLS configuration (%v)", config)
+	}
+
+	// ... certificate without a matching key
+	bytes, _ := os.ReadFile(certificate)
+	writeKeypair(t, dir, 3)
+	os.WriteFile(certificate, bytes, 0600)
+	os.Chtimes(certificate, time.Now().Add(5*time.Second), time.Now().Add(5*time.Second))
+
+	if serial := serialNumber(t, k); serial != 2 {
+		t.Errorf("Expected previous certificate after invalid update - expected serial number %v, got %v", 2, serial)
+	}
+}
@@ -0,0 +1,72 @@
+package httpd
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Returns the bearer token from the request Authorization header, if any.
+func bearer(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(r.Header.Get("Authorization")), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	if token = strings.TrimSpace(token); token == "" {
+		return "", false
+	}
+
+	return token, true
+}
+
+// Authenticates a request with an API bearer token. The token must have been issued with a
+// <resource>:<access> scope matching the first segment of the request path and the request
+// method.
+func (d *dispatcher) authenticatedToken(r *http.Request, token string) (string, string, bool) {
+	path, err := resolve(r.URL)
+	if err != nil {
+		warnf("HTTPD", "%v", err)
+		return "", "", false
+	}
+
+	// ... API tokens can't be used to manage API tokens or passkeys
+	if path == "/tokens" || path == "/passkeys" || path == "/passkeys/register" {
+		warnf("HTTPD", "API token not valid for %v", path)
+		return "", "", false
+	}
+
+	uid, role, scopes, err := d.auth.AuthenticatedToken(token)
+	if err != nil {
+		warnf("HTTPD", "%v", err)
+		return "", "", false
+	}
+
+	if !scoped(scopes, r.Method, path) {
+		warnf("HTTPD", "%v API token not scoped for %v %v", uid, r.Method, path)
+		return "", "", false
+	}
+
+	return uid, role, true
+}
+
+func scoped(scopes []string, method string, path string) bool {
+	resource, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	access := "write"
+
+	switch strings.ToUpper(method) {
+	case http.MethodGet, http.MethodHead:
+		access = "read"
+	}
+
+	for _, scope := range scopes {
+		if scope == "*" {
+			return true
+		}
+
+		if r, a, ok := strings.Cut(scope, ":"); ok && (r == "*" || r == resource) && (a == "*" || a == access) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,118 @@
+package users
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/uhppoted/uhppoted-httpd/auth/webauthn"
+	"github.com/uhppoted/uhppoted-httpd/httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/httpd/cookies"
+	"github.com/uhppoted/uhppoted-httpd/system"
+	"github.com/uhppoted/uhppoted-httpd/system/users"
+)
+
+func Passkeys(uid, role string) any {
+	_, passkeys := system.Passkeys(uid)
+	if passkeys == nil {
+		passkeys = []users.Passkey{}
+	}
+
+	return struct {
+		Passkeys []users.Passkey `json:"passkeys"`
+	}{
+		Passkeys: passkeys,
+	}
+}
+
+// Starts registering a new passkey for the logged in user. Requires the user password in the
+// Authorization header (as for OTP and API tokens) and returns the WebAuthn credential creation
+// options for navigator.credentials.create().
+func BeginPasskey(uid, role string, w http.ResponseWriter, r *http.Request, auth auth.IAuth, passkeys *webauthn.WebAuthn) (any, error) {
+	if passkeys == nil {
+		http.Error(w, "Passkeys not enabled", http.StatusNotFound)
+		return nil, fmt.Errorf("passkeys not enabled")
+	}
+
+	if err := verifyAuthHeader(uid, r, auth); err != nil {
+		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		return nil, err
+	}
+
+	options, key, err := passkeys.BeginRegistration(uid, role)
+	if err != nil {
+		http.Error(w, "Error registering passkey", http.StatusInternalServerError)
+		return nil, err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookies.WebAuthnCookie,
+		Value:    key,
+		Path:     "/",
+		MaxAge:   int((5 * time.Minute).Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+		//  Secure:   true,
+	})
+
+	return options, nil
+}
+
+// Completes a passkey registration with the attestation returned by navigator.credentials.create().
+func RegisterPasskey(uid, role string, w http.ResponseWriter, r *http.Request, passkeys *webauthn.WebAuthn) (any, error) {
+	if passkeys == nil {
+		http.Error(w, "Passkeys not enabled", http.StatusNotFound)
+		return nil, fmt.Errorf("passkeys not enabled")
+	}
+
+	cookie, err := r.Cookie(cookies.WebAuthnCookie)
+	cookies.Clear(w, cookies.WebAuthnCookie)
+
+	if err != nil {
+		http.Error(w, "Passkey registration expired", http.StatusBadRequest)
+		return nil, err
+	}
+
+	request := struct {
+		Name       string          `json:"name"`
+		Credential json.RawMessage `json:"credential"`
+	}{}
+
+	if blob, err := io.ReadAll(io.LimitReader(r.Body, 65536)); err != nil {
+		http.Error(w, "Error reading request", http.StatusBadRequest)
+		return nil, err
+	} else if err := json.Unmarshal(blob, &request); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return nil, err
+	}
+
+	passkey, err := passkeys.FinishRegistration(cookie.Value, uid, role, request.Name, request.Credential)
+	if err != nil {
+		http.Error(w, "Invalid passkey", http.StatusBadRequest)
+		return nil, err
+	}
+
+	return passkey, nil
+}
+
+func RevokePasskey(uid, role string, w http.ResponseWriter, r *http.Request, auth auth.IAuth) {
+	if err := verifyAuthHeader(uid, r, auth); err != nil {
+		warnf("PASSKEYS", "%v", err)
+		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		return
+	}
+
+	id := r.FormValue("id")
+	if id == "" {
+		http.Error(w, "Missing passkey ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := system.RevokePasskey(uid, role, id); err != nil {
+		warnf("PASSKEYS", "%v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
@@ -0,0 +1,35 @@
+package users
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/system"
+	"github.com/uhppoted/uhppoted-httpd/system/sessions"
+)
+
+func Sessions(uid, role string) any {
+	return struct {
+		Sessions []sessions.Session `json:"sessions"`
+	}{
+		Sessions: system.Sessions(uid, role),
+	}
+}
+
+// Revokes a single session (?id=<session ID>) or all the sessions for a user (?uid=<user ID>).
+func RevokeSessions(uid, role string, w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimSpace(r.FormValue("id"))
+	user := strings.TrimSpace(r.FormValue("uid"))
+
+	if _, err := system.RevokeSessions(uid, role, id, user); errors.Is(err, auth.ErrUnauthorised) {
+		warnf("SESSIONS", "%v", err)
+		http.Error(w, "Not authorised", http.StatusForbidden)
+		return
+	} else if err != nil {
+		warnf("SESSIONS", "%v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
@@ -0,0 +1,136 @@
+package users
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/uhppoted/uhppoted-httpd/httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/system"
+	"github.com/uhppoted/uhppoted-httpd/system/users"
+	"github.com/uhppoted/uhppoted-httpd/types"
+)
+
+func Tokens(uid, role string) any {
+	return struct {
+		Tokens []users.Token `json:"tokens"`
+	}{
+		Tokens: system.Tokens(uid, role),
+	}
+}
+
+// Issues a new API token for the logged in user. Requires the user password in the
+// Authorization header (as for password changes and OTP) so that a hijacked session
+// can't be used to mint long-lived credentials.
+func CreateToken(uid, role string, w http.ResponseWriter, r *http.Request, auth auth.IAuth) (any, error) {
+	if err := verifyAuthHeader(uid, r, auth); err != nil {
+		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		return nil, err
+	}
+
+	body, err := parseRequest(r)
+	if err != nil {
+		http.Error(w, "Error reading request", http.StatusBadRequest)
+		return nil, err
+	}
+
+	name := ""
+	if v, ok := body["name"]; ok {
+		name = strings.Join(values(v), " ")
+	}
+
+	scopes := []string{}
+	if v, ok := body["scopes"]; ok {
+		for _, s := range values(v) {
+			scopes = append(scopes, strings.Fields(strings.ReplaceAll(s, ",", " "))...)
+		}
+	}
+
+	expires := types.Timestamp{}
+	if v, ok := body["expires"]; ok {
+		if t, err := parseExpires(strings.Join(values(v), "")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil, err
+		} else {
+			expires = t
+		}
+	}
+
+	token, t, err := system.CreateToken(uid, role, name, scopes, expires)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, err
+	}
+
+	return struct {
+		users.Token
+		Bearer string `json:"token"`
+	}{
+		Token:  t,
+		Bearer: token,
+	}, nil
+}
+
+func RevokeToken(uid, role string, w http.ResponseWriter, r *http.Request, auth auth.IAuth) {
+	if err := verifyAuthHeader(uid, r, auth); err != nil {
+		warnf("TOKENS", "%v", err)
+		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		return
+	}
+
+	id := strings.TrimSpace(r.FormValue("id"))
+	if id == "" {
+		http.Error(w, "Missing token ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := system.RevokeToken(uid, role, id); err != nil {
+		warnf("TOKENS", "%v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+// Returns a request field as a list of strings (form fields are []string, JSON fields may be a
+// string or an array).
+func values(v any) []string {
+	list := []string{}
+
+	switch vv := v.(type) {
+	case string:
+		list = append(list, vv)
+
+	case []string:
+		list = append(list, vv...)
+
+	case []any:
+		for _, u := range vv {
+			if s, ok := u.(string); ok {
+				list = append(list, s)
+			}
+		}
+	}
+
+	return list
+}
+
+// Accepts a date (yyyy-mm-dd), local date/time (yyyy-mm-dd HH:mm:ss) or RFC3339 timestamp.
+func parseExpires(s string) (types.Timestamp, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return types.Timestamp{}, nil
+	}
+
+	for _, layout := range []string{"2006-01-02", "2006-01-02 15:04:05"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return types.Timestamp(t), nil
+		}
+	}
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return types.Timestamp(t), nil
+	}
+
+	return types.Timestamp{}, fmt.Errorf("invalid API token expiry '%v'", s)
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/uhppoted/uhppoted-httpd/httpd/groups"
 	"github.com/uhppoted/uhppoted-httpd/httpd/interfaces"
 	"github.com/uhppoted/uhppoted-httpd/httpd/logs"
+	"github.com/uhppoted/uhppoted-httpd/httpd/tasks"
+	"github.com/uhppoted/uhppoted-httpd/httpd/timeprofiles"
 	"github.com/uhppoted/uhppoted-httpd/httpd/users"
 )
 
@@ -38,18 +40,48 @@ func (d *dispatcher) vtable(path string) *handler {
 			post: doors.Post,
 		}
 
+	case "/doors/open":
+		return &handler{
+			get:  nil,
+			post: doors.Open,
+		}
+
 	case "/cards":
 		return &handler{
 			get:  func(uid, role string, rq *http.Request) any { return cards.Get(uid, role, rq) },
 			post: cards.Post,
 		}
 
+	case "/cards/import":
+		return &handler{
+			get:  nil,
+			post: cards.Import,
+		}
+
 	case "/groups":
 		return &handler{
 			get:  func(uid, role string, rq *http.Request) any { return groups.Get(uid, role) },
 			post: groups.Post,
 		}
 
+	case "/time-profiles":
+		return &handler{
+			get:  func(uid, role string, rq *http.Request) any { return timeprofiles.Get(uid, role) },
+			post: timeprofiles.Post,
+		}
+
+	case "/tasks":
+		return &handler{
+			get:  func(uid, role string, rq *http.Request) any { return tasks.Get(uid, role) },
+			post: tasks.Post,
+		}
+
+	case "/tasks/runs":
+		return &handler{
+			get:  func(uid, role string, rq *http.Request) any { return tasks.Runs(uid, role, rq) },
+			post: nil,
+		}
+
 	case "/events":
 		return &handler{
 			get:  func(uid, role string, rq *http.Request) any { return events.Get(uid, role, rq) },
@@ -67,6 +99,24 @@ func (d *dispatcher) vtable(path string) *handler {
 			get:  func(uid, role string, rq *http.Request) any { return users.Get(uid, role) },
 			post: users.Post,
 		}
+
+	case "/tokens":
+		return &handler{
+			get:  func(uid, role string, rq *http.Request) any { return users.Tokens(uid, role) },
+			post: nil,
+		}
+
+	case "/passkeys":
+		return &handler{
+			get:  func(uid, role string, rq *http.Request) any { return users.Passkeys(uid, role) },
+			post: nil,
+		}
+
+	case "/sessions":
+		return &handler{
+			get:  func(uid, role string, rq *http.Request) any { return users.Sessions(uid, role) },
+			post: nil,
+		}
 	}
 
 	return nil
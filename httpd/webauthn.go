@@ -0,0 +1,99 @@
+package httpd
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/uhppoted/uhppoted-httpd/httpd/cookies"
+)
+
+// Handles passkey logins:
+//
+//   - GET returns the WebAuthn assertion request for a passwordless login
+//   - POST completes a passwordless or second factor login and starts a session
+//
+// The ceremony state is bound to the browser by a single use, short-lived cookie (as for the
+// login cookie used by a password login).
+func (d *dispatcher) passkeyLogin(w http.ResponseWriter, r *http.Request) {
+	switch strings.ToUpper(r.Method) {
+	case http.MethodGet:
+		d.beginPasskeyLogin(w, r)
+
+	case http.MethodPost:
+		d.finishPasskeyLogin(w, r)
+
+	default:
+		http.Error(w, "Invalid request", http.StatusMethodNotAllowed)
+	}
+}
+
+func (d *dispatcher) beginPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	options, key, err := d.webauthn.BeginLogin()
+	if err != nil {
+		warnf("WEBAUTHN", "%v", err)
+		http.Error(w, "Passkey login not available", http.StatusBadRequest)
+		return
+	}
+
+	b, err := json.Marshal(options)
+	if err != nil {
+		warnf("WEBAUTHN", "%v", err)
+		http.Error(w, "Internal error generating response", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookies.WebAuthnCookie,
+		Value:    key,
+		Path:     "/",
+		MaxAge:   int((5 * time.Minute).Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+		//  Secure:   true,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(b)
+}
+
+func (d *dispatcher) finishPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(cookies.WebAuthnCookie)
+	cookies.Clear(w, cookies.WebAuthnCookie)
+
+	if err != nil {
+		warnf("WEBAUTHN", "%v", err)
+		http.Error(w, "Passkey login expired", http.StatusUnauthorized)
+		return
+	}
+
+	response, err := io.ReadAll(io.LimitReader(r.Body, 65536))
+	if err != nil {
+		warnf("WEBAUTHN", "%v", err)
+		http.Error(w, "Error reading request", http.StatusBadRequest)
+		return
+	}
+
+	uid, role, err := d.webauthn.FinishLogin(cookie.Value, response)
+	if err != nil {
+		warnf("WEBAUTHN", "%v", err)
+		http.Error(w, "Invalid passkey", http.StatusUnauthorized)
+		return
+	}
+
+	session, err := d.auth.Session(uid, role)
+	if err != nil {
+		warnf("WEBAUTHN", "%v", err)
+		http.Error(w, "Error starting session", http.StatusInternalServerError)
+		return
+	}
+
+	if session != nil {
+		http.SetCookie(w, session)
+	}
+
+	d.throttle.Succeeded("uid:" + uid)
+}
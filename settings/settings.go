@@ -0,0 +1,214 @@
+package settings
+
+import (
+	"os"
+	"time"
+
+	"github.com/uhppoted/uhppoted-lib/encoding/conf"
+)
+
+// Settings holds the uhppoted-httpd configuration that is not (yet) part of the uhppoted-lib
+// HTTPD configuration. The settings are read from the same uhppoted.conf file.
+type Settings struct {
+	DB struct {
+		Backend string `conf:"backend"`
+		SQLite  struct {
+			File string `conf:"file"`
+		} `conf:"sqlite"`
+		Rules struct {
+			TimeProfiles string `conf:"time-profiles"`
+			Tasks        string `conf:"tasks"`
+		} `conf:"rules"`
+	} `conf:"httpd.db"`
+
+	System struct {
+		TimeProfiles string `conf:"time-profiles"`
+		Tasks        string `conf:"tasks"`
+		TaskRuns     string `conf:"task-runs"`
+		Sessions     string `conf:"sessions"`
+	} `conf:"httpd.system"`
+
+	Notifications struct {
+		File string `conf:"file"`
+	} `conf:"httpd.notifications"`
+
+	OIDC struct {
+		Issuer       string `conf:"issuer"`
+		ClientID     string `conf:"client-id"`
+		ClientSecret string `conf:"client-secret"`
+		RedirectURL  string `conf:"redirect-url"`
+		Scopes       string `conf:"scopes"`
+		UIDClaim     string `conf:"uid-claim"`
+		RoleClaim    string `conf:"role-claim"`
+		Roles        string `conf:"roles"`
+		Provisioning string `conf:"provisioning"`
+		LocalLogin   bool   `conf:"local-login"`
+	} `conf:"httpd.security.oidc"`
+
+	LDAP struct {
+		URL            string        `conf:"url"`
+		StartTLS       bool          `conf:"start-tls"`
+		BindDN         string        `conf:"bind-dn"`
+		BindPassword   string        `conf:"bind-password"`
+		BaseDN         string        `conf:"base-dn"`
+		UserFilter     string        `conf:"user-filter"`
+		NameAttribute  string        `conf:"name-attribute"`
+		GroupAttribute string        `conf:"group-attribute"`
+		AdminGroup     string        `conf:"admin-group"`
+		Roles          string        `conf:"roles"`
+		Fallback       string        `conf:"fallback"`
+		Timeout        time.Duration `conf:"timeout"`
+	} `conf:"httpd.security.ldap"`
+
+	WebAuthn struct {
+		RPID         string `conf:"rp-id"`
+		RPName       string `conf:"rp-name"`
+		Origins      string `conf:"origins"`
+		Passwordless bool   `conf:"passwordless"`
+		SecondFactor bool   `conf:"second-factor"`
+	} `conf:"httpd.security.webauthn"`
+
+	Passwords struct {
+		Algorithm string `conf:"algorithm"`
+		Argon2id  struct {
+			Memory      uint32 `conf:"memory"`
+			Iterations  uint32 `conf:"iterations"`
+			Parallelism uint8  `conf:"parallelism"`
+		} `conf:"argon2id"`
+		Bcrypt struct {
+			Cost int `conf:"cost"`
+		} `conf:"bcrypt"`
+	} `conf:"httpd.security.passwords"`
+
+	Lockout struct {
+		Attempts uint32        `conf:"attempts"`
+		Cooldown time.Duration `conf:"cooldown"`
+	} `conf:"httpd.security.lockout"`
+
+	Throttle struct {
+		Attempts uint32        `conf:"attempts"`
+		Delay    time.Duration `conf:"delay"`
+		MaxDelay time.Duration `conf:"max-delay"`
+	} `conf:"httpd.security.throttle"`
+
+	Sessions struct {
+		MaxSessions uint32        `conf:"max-sessions"`
+		Lifetime    time.Duration `conf:"lifetime"`
+		IdleTime    time.Duration `conf:"idle-time"`
+	} `conf:"httpd.security.sessions"`
+
+	MTLS struct {
+		Enabled    bool   `conf:"enabled"`
+		CRL        string `conf:"crl"`
+		Identities string `conf:"identities"`
+	} `conf:"httpd.security.mtls"`
+
+	Audit struct {
+		Key        string `conf:"key"`
+		Checkpoint struct {
+			Records  uint32        `conf:"records"`
+			Interval time.Duration `conf:"interval"`
+		} `conf:"checkpoint"`
+	} `conf:"httpd.audit"`
+
+	Backup struct {
+		Dir  string `conf:"dir"`
+		Keep uint32 `conf:"keep"`
+		S3   struct {
+			Endpoint  string `conf:"endpoint"`
+			Region    string `conf:"region"`
+			Bucket    string `conf:"bucket"`
+			Prefix    string `conf:"prefix"`
+			AccessKey string `conf:"access-key"`
+			SecretKey string `conf:"secret-key"`
+		} `conf:"s3"`
+	} `conf:"httpd.backup"`
+
+	Simulator struct {
+		Controllers string        `conf:"controllers"`
+		Swipes      time.Duration `conf:"swipes"`
+	} `conf:"httpd.simulator"`
+}
+
+const (
+	BackendJSON   = "json"
+	BackendSQLite = "sqlite"
+)
+
+const (
+	ProvisioningLinked = "linked"
+	ProvisioningAuto   = "auto"
+)
+
+func NewSettings() *Settings {
+	s := Settings{}
+
+	s.DB.Backend = BackendJSON
+	s.DB.SQLite.File = ""
+	s.DB.Rules.TimeProfiles = ""
+	s.DB.Rules.Tasks = ""
+	s.System.TimeProfiles = ""
+	s.System.Tasks = ""
+	s.System.TaskRuns = ""
+	s.System.Sessions = ""
+	s.Notifications.File = ""
+	s.OIDC.Scopes = "openid,profile,email"
+	s.OIDC.UIDClaim = "preferred_username"
+	s.OIDC.RoleClaim = "groups"
+	s.OIDC.Provisioning = ProvisioningLinked
+	s.OIDC.LocalLogin = false
+	s.LDAP.UserFilter = "(uid=%s)"
+	s.LDAP.NameAttribute = "cn"
+	s.LDAP.GroupAttribute = "memberOf"
+	s.LDAP.Fallback = ""
+	s.LDAP.Timeout = 5 * time.Second
+	s.WebAuthn.RPID = ""
+	s.WebAuthn.RPName = "uhppoted-httpd"
+	s.WebAuthn.Origins = ""
+	s.WebAuthn.Passwordless = true
+	s.WebAuthn.SecondFactor = false
+	s.Passwords.Algorithm = "argon2id"
+	s.Passwords.Argon2id.Memory = 65536
+	s.Passwords.Argon2id.Iterations = 3
+	s.Passwords.Argon2id.Parallelism = 2
+	s.Passwords.Bcrypt.Cost = 10
+	s.Lockout.Attempts = 5
+	s.Lockout.Cooldown = 0
+	s.Throttle.Attempts = 3
+	s.Throttle.Delay = 1 * time.Second
+	s.Throttle.MaxDelay = 5 * time.Minute
+	s.Sessions.MaxSessions = 0
+	s.Sessions.Lifetime = 0
+	s.Sessions.IdleTime = 10 * time.Minute
+	s.MTLS.Enabled = false
+	s.MTLS.CRL = ""
+	s.MTLS.Identities = "subject,san"
+	s.Audit.Key = ""
+	s.Audit.Checkpoint.Records = 100
+	s.Audit.Checkpoint.Interval = 1 * time.Hour
+	s.Backup.Dir = ""
+	s.Backup.Keep = 7
+	s.Backup.S3.Endpoint = ""
+	s.Backup.S3.Region = "us-east-1"
+	s.Backup.S3.Bucket = ""
+	s.Backup.S3.Prefix = ""
+	s.Backup.S3.AccessKey = ""
+	s.Backup.S3.SecretKey = ""
+	s.Simulator.Controllers = ""
+	s.Simulator.Swipes = 30 * time.Second
+
+	return &s
+}
+
+func (s *Settings) Load(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return conf.Unmarshal(bytes, s)
+}
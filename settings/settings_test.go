@@ -0,0 +1,260 @@
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "uhppoted.conf")
+	conf := `
+httpd.system.events = ./var/httpd/system/events.json
+httpd.db.rules.acl = ./etc/httpd/acl.grl
+httpd.db.backend = sqlite
+httpd.db.sqlite.file = ./var/httpd/system/httpd.db
+httpd.db.rules.time-profiles = ./etc/httpd/grules/time-profiles.grl
+httpd.system.time-profiles = ./var/httpd/system/time-profiles.json
+httpd.db.rules.tasks = ./etc/httpd/grules/tasks.grl
+httpd.system.tasks = ./var/httpd/system/tasks.json
+httpd.system.task-runs = ./var/httpd/system/task-runs.json
+httpd.notifications.file = ./etc/httpd/notifications.json
+`
+
+	if err := os.WriteFile(file, []byte(conf), 0600); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	s := NewSettings()
+	if err := s.Load(file); err != nil {
+		t.Fatalf("Error loading settings (%v)", err)
+	}
+
+	if s.DB.Backend != BackendSQLite {
+		t.Errorf("Incorrect DB backend - expected:%v, got:%v", BackendSQLite, s.DB.Backend)
+	}
+
+	if s.DB.SQLite.File != "./var/httpd/system/httpd.db" {
+		t.Errorf("Incorrect SQLite file - expected:%v, got:%v", "./var/httpd/system/httpd.db", s.DB.SQLite.File)
+	}
+
+	if s.DB.Rules.TimeProfiles != "./etc/httpd/grules/time-profiles.grl" {
+		t.Errorf("Incorrect time profiles rules - expected:%v, got:%v", "./etc/httpd/grules/time-profiles.grl", s.DB.Rules.TimeProfiles)
+	}
+
+	if s.System.TimeProfiles != "./var/httpd/system/time-profiles.json" {
+		t.Errorf("Incorrect time profiles file - expected:%v, got:%v", "./var/httpd/system/time-profiles.json", s.System.TimeProfiles)
+	}
+
+	if s.DB.Rules.Tasks != "./etc/httpd/grules/tasks.grl" {
+		t.Errorf("Incorrect tasks rules - expected:%v, got:%v", "./etc/httpd/grules/tasks.grl", s.DB.Rules.Tasks)
+	}
+
+	if s.System.Tasks != "./var/httpd/system/tasks.json" {
+		t.Errorf("Incorrect tasks file - expected:%v, got:%v", "./var/httpd/system/tasks.json", s.System.Tasks)
+	}
+
+	if s.System.TaskRuns != "./var/httpd/system/task-runs.json" {
+		t.Errorf("Incorrect task runs file - expected:%v, got:%v", "./var/httpd/system/task-runs.json", s.System.TaskRuns)
+	}
+
+	if s.Notifications.File != "./etc/httpd/notifications.json" {
+		t.Errorf("Incorrect notifications file - expected:%v, got:%v", "./etc/httpd/notifications.json", s.Notifications.File)
+	}
+}
+
+func TestLoadWithDefaults(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "uhppoted.conf")
+
+	if err := os.WriteFile(file, []byte("httpd.http.port = 8080\n"), 0600); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	s := NewSettings()
+	if err := s.Load(file); err != nil {
+		t.Fatalf("Error loading settings (%v)", err)
+	}
+
+	if s.DB.Backend != BackendJSON {
+		t.Errorf("Incorrect default DB backend - expected:%v, got:%v", BackendJSON, s.DB.Backend)
+	}
+}
+
+func TestLoadSecuritySettings(t *testing.T) {
+	type setting struct {
+		value    func(s *Settings) any
+		expected any
+	}
+
+	tests := []struct {
+		name     string
+		conf     string
+		settings map[string]setting
+	}{
+		{
+			name: "OIDC",
+			conf: `
+httpd.security.auth = oidc
+httpd.security.oidc.issuer = https://login.example.com/realms/uhppoted
+httpd.security.oidc.client-id = uhppoted-httpd
+httpd.security.oidc.roles = uhppoted-admins:admin,staff:user
+httpd.security.oidc.provisioning = auto
+httpd.security.oidc.local-login = true
+`,
+			settings: map[string]setting{
+				"issuer":       {func(s *Settings) any { return s.OIDC.Issuer }, "https://login.example.com/realms/uhppoted"},
+				"roles":        {func(s *Settings) any { return s.OIDC.Roles }, "uhppoted-admins:admin,staff:user"},
+				"provisioning": {func(s *Settings) any { return s.OIDC.Provisioning }, ProvisioningAuto},
+				"local login":  {func(s *Settings) any { return s.OIDC.LocalLogin }, true},
+				"UID claim":    {func(s *Settings) any { return s.OIDC.UIDClaim }, "preferred_username"},
+			},
+		},
+		{
+			name: "LDAP",
+			conf: `
+httpd.security.auth = ldap
+httpd.security.ldap.url = ldaps://ldap.example.com
+httpd.security.ldap.base-dn = ou=people,dc=example,dc=com
+httpd.security.ldap.user-filter = (&(objectClass=user)(sAMAccountName=%s))
+httpd.security.ldap.admin-group = uhppoted-admins
+httpd.security.ldap.fallback = admin
+httpd.security.ldap.timeout = 10s
+`,
+			settings: map[string]setting{
+				"URL":             {func(s *Settings) any { return s.LDAP.URL }, "ldaps://ldap.example.com"},
+				"user filter":     {func(s *Settings) any { return s.LDAP.UserFilter }, "(&(objectClass=user)(sAMAccountName=%s))"},
+				"admin group":     {func(s *Settings) any { return s.LDAP.AdminGroup }, "uhppoted-admins"},
+				"timeout":         {func(s *Settings) any { return s.LDAP.Timeout }, 10 * time.Second},
+				"group attribute": {func(s *Settings) any { return s.LDAP.GroupAttribute }, "memberOf"},
+			},
+		},
+		{
+			name: "WebAuthn",
+			conf: `
+httpd.security.webauthn.rp-id = uhppoted.example.com
+httpd.security.webauthn.origins = https://uhppoted.example.com,https://uhppoted.example.com:8443
+httpd.security.webauthn.passwordless = false
+httpd.security.webauthn.second-factor = true
+`,
+			settings: map[string]setting{
+				"RP ID":         {func(s *Settings) any { return s.WebAuthn.RPID }, "uhppoted.example.com"},
+				"RP name":       {func(s *Settings) any { return s.WebAuthn.RPName }, "uhppoted-httpd"},
+				"origins":       {func(s *Settings) any { return s.WebAuthn.Origins }, "https://uhppoted.example.com,https://uhppoted.example.com:8443"},
+				"passwordless":  {func(s *Settings) any { return s.WebAuthn.Passwordless }, false},
+				"second factor": {func(s *Settings) any { return s.WebAuthn.SecondFactor }, true},
+			},
+		},
+		{
+			name: "passwords",
+			conf: `
+httpd.security.passwords.algorithm = bcrypt
+httpd.security.passwords.argon2id.memory = 131072
+httpd.security.passwords.argon2id.parallelism = 4
+httpd.security.passwords.bcrypt.cost = 12
+`,
+			settings: map[string]setting{
+				"algorithm":            {func(s *Settings) any { return s.Passwords.Algorithm }, "bcrypt"},
+				"argon2id memory":      {func(s *Settings) any { return s.Passwords.Argon2id.Memory }, uint32(131072)},
+				"argon2id iterations":  {func(s *Settings) any { return s.Passwords.Argon2id.Iterations }, uint32(3)},
+				"argon2id parallelism": {func(s *Settings) any { return s.Passwords.Argon2id.Parallelism }, uint8(4)},
+				"bcrypt cost":          {func(s *Settings) any { return s.Passwords.Bcrypt.Cost }, 12},
+			},
+		},
+		{
+			name: "lockout",
+			conf: `
+httpd.security.lockout.attempts = 10
+httpd.security.lockout.cooldown = 15m
+httpd.security.throttle.delay = 2s
+httpd.security.throttle.max-delay = 1m
+`,
+			settings: map[string]setting{
+				"lockout attempts":   {func(s *Settings) any { return s.Lockout.Attempts }, uint32(10)},
+				"lockout cooldown":   {func(s *Settings) any { return s.Lockout.Cooldown }, 15 * time.Minute},
+				"throttle attempts":  {func(s *Settings) any { return s.Throttle.Attempts }, uint32(3)},
+				"throttle delay":     {func(s *Settings) any { return s.Throttle.Delay }, 2 * time.Second},
+				"throttle max delay": {func(s *Settings) any { return s.Throttle.MaxDelay }, 1 * time.Minute},
+			},
+		},
+		{
+			name: "sessions",
+			conf: `
+httpd.system.sessions = /var/uhppoted/httpd/system/sessions.json
+httpd.security.sessions.max-sessions = 3
+httpd.security.sessions.lifetime = 8h
+`,
+			settings: map[string]setting{
+				"file":         {func(s *Settings) any { return s.System.Sessions }, "/var/uhppoted/httpd/system/sessions.json"},
+				"max sessions": {func(s *Settings) any { return s.Sessions.MaxSessions }, uint32(3)},
+				"lifetime":     {func(s *Settings) any { return s.Sessions.Lifetime }, 8 * time.Hour},
+				"idle time":    {func(s *Settings) any { return s.Sessions.IdleTime }, 10 * time.Minute},
+			},
+		},
+		{
+			name: "client certificates",
+			conf: `
+httpd.security.mtls.enabled = true
+httpd.security.mtls.crl = /etc/uhppoted/httpd/ca.crl
+`,
+			settings: map[string]setting{
+				"enabled":    {func(s *Settings) any { return s.MTLS.Enabled }, true},
+				"CRL":        {func(s *Settings) any { return s.MTLS.CRL }, "/etc/uhppoted/httpd/ca.crl"},
+				"identities": {func(s *Settings) any { return s.MTLS.Identities }, "subject,san"},
+			},
+		},
+		{
+			name: "audit",
+			conf: `
+httpd.audit.file = /var/uhppoted/httpd/audit/audit.log
+httpd.audit.key = /etc/uhppoted/httpd/audit.key
+httpd.audit.checkpoint.interval = 15m
+`,
+			settings: map[string]setting{
+				"key":                 {func(s *Settings) any { return s.Audit.Key }, "/etc/uhppoted/httpd/audit.key"},
+				"checkpoint interval": {func(s *Settings) any { return s.Audit.Checkpoint.Interval }, 15 * time.Minute},
+				"checkpoint records":  {func(s *Settings) any { return s.Audit.Checkpoint.Records }, uint32(100)},
+			},
+		},
+		{
+			name: "backup",
+			conf: `
+httpd.backup.dir = /var/uhppoted/httpd/backup
+httpd.backup.s3.endpoint = http://localhost:9000
+httpd.backup.s3.bucket = uhppoted
+httpd.backup.s3.prefix = httpd/
+httpd.backup.s3.access-key = minioadmin
+httpd.backup.s3.secret-key = minioadmin
+`,
+			settings: map[string]setting{
+				"folder":        {func(s *Settings) any { return s.Backup.Dir }, "/var/uhppoted/httpd/backup"},
+				"keep":          {func(s *Settings) any { return s.Backup.Keep }, uint32(7)},
+				"S3 endpoint":   {func(s *Settings) any { return s.Backup.S3.Endpoint }, "http://localhost:9000"},
+				"S3 region":     {func(s *Settings) any { return s.Backup.S3.Region }, "us-east-1"},
+				"S3 bucket":     {func(s *Settings) any { return s.Backup.S3.Bucket }, "uhppoted"},
+				"S3 prefix":     {func(s *Settings) any { return s.Backup.S3.Prefix }, "httpd/"},
+				"S3 access key": {func(s *Settings) any { return s.Backup.S3.AccessKey }, "minioadmin"},
+				"S3 secret key": {func(s *Settings) any { return s.Backup.S3.SecretKey }, "minioadmin"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		file := filepath.Join(t.TempDir(), "uhppoted.conf")
+
+		if err := os.WriteFile(file, []byte(test.conf), 0600); err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		s := NewSettings()
+		if err := s.Load(file); err != nil {
+			t.Fatalf("%v: error loading settings (%v)", test.name, err)
+		}
+
+		for k, v := range test.settings {
+			if value := v.value(s); value != v.expected {
+				t.Errorf("%v: incorrect %v - expected:%v (%T), got:%v (%T)", test.name, k, v.expected, v.expected, value, value)
+			}
+		}
+	}
+}
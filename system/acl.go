@@ -5,17 +5,37 @@ import (
 
 	lib "github.com/uhppoted/uhppote-core/types"
 
+	"github.com/uhppoted/uhppoted-httpd/system/cards"
 	"github.com/uhppoted/uhppoted-httpd/system/catalog"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
 	"github.com/uhppoted/uhppoted-httpd/types"
 	"github.com/uhppoted/uhppoted-lib/acl"
 )
 
 func (s *system) synchronizeACL() error {
 	controllers := s.controllers.AsIControllers()
+	profiles := s.profiles.AsTimeProfiles()
+
+	// ... time profiles first so that the cards don't reference undefined profiles
+	var wg sync.WaitGroup
+
+	for _, c := range controllers {
+		wg.Add(1)
+
+		go func(controller types.IController) {
+			defer wg.Done()
+
+			for _, p := range profiles {
+				s.interfaces.PutTimeProfile(controller, p)
+			}
+		}(c)
+	}
+
+	wg.Wait()
 
 	if acl, err := s.permissions(controllers); err != nil {
 		warnf("ACL", "%v", err)
-	} else if diff, err := s.interfaces.CompareACL(controllers, acl, s.withPIN); err != nil {
+	} else if diff, _, err := s.interfaces.CompareACL(controllers, acl, profiles, s.withPIN); err != nil {
 		warnf("ACL", "%v", err)
 	} else if diff == nil {
 		warnf("ACL", "invalid ACL diff (%v)", diff)
@@ -36,8 +56,6 @@ func (s *system) synchronizeACL() error {
 			}
 		}
 
-		var wg sync.WaitGroup
-
 		for _, c := range controllers {
 			wg.Add(1)
 
@@ -59,10 +77,11 @@ func (s *system) synchronizeACL() error {
 
 func (s *system) compareACL() {
 	controllers := s.controllers.AsIControllers()
+	profiles := s.profiles.AsTimeProfiles()
 
 	if acl, err := s.permissions(controllers); err != nil {
 		warnf("ACL", "%v", err)
-	} else if diff, err := s.interfaces.CompareACL(controllers, acl, s.withPIN); err != nil {
+	} else if diff, _, err := s.interfaces.CompareACL(controllers, acl, profiles, s.withPIN); err != nil {
 		warnf("ACL", "%v", err)
 	} else if diff == nil {
 		warnf("ACL", "invalid ACL diff (%v)", diff)
@@ -103,6 +122,7 @@ func (s *system) compareACL() {
 
 		sys.cards.Found(remap(found))
 		sys.cards.MarkIncorrect(remap(cards))
+		sys.notifications.ACL(len(cards))
 	}
 }
 
@@ -134,42 +154,16 @@ func (s *system) updateCardPermissions(controller types.IController, cardID uint
 			PIN = card.PIN()
 		}
 
-		// ... get base permissions from groups
-		groups := card.Groups()
-		doors := s.groups.Doors(groups...)
+		permissions, err := s.doorPermissions(*card)
+		if err != nil {
+			warnf("ACL", "%v", err)
+			return
+		}
 
-		for _, door := range doors {
+		for door, v := range permissions {
 			for _, d := range []uint8{1, 2, 3, 4} {
-				doorID := d
 				if oid, ok := controller.Door(d); ok && oid == door {
-					acl[doorID] = 1
-				}
-			}
-		}
-
-		// ... updated base permissions with grules
-		if sys.rules != nil {
-			allowed, forbidden, err := sys.rules.Eval(*card, sys.doors)
-			if err != nil {
-				warnf("ACL", "%v", err)
-				return
-			}
-
-			for _, door := range allowed {
-				for _, d := range []uint8{1, 2, 3, 4} {
-					doorID := d
-					if oid, ok := controller.Door(d); ok && oid == door.OID {
-						acl[doorID] = 1
-					}
-				}
-			}
-
-			for _, door := range forbidden {
-				for _, d := range []uint8{1, 2, 3, 4} {
-					doorID := d
-					if oid, ok := controller.Door(d); ok && oid == door.OID {
-						acl[doorID] = 0
-					}
+					acl[d] = v
 				}
 			}
 		}
@@ -198,8 +192,6 @@ func (s *system) updateCardPermissions(controller types.IController, cardID uint
 
 func (s *system) permissions(controllers []types.IController) (acl.ACL, error) {
 	cards := s.cards.List()
-	groups := s.groups
-	doors := s.doors
 
 	// initialise empty ACL
 	acl := make(acl.ACL)
@@ -217,42 +209,27 @@ func (s *system) permissions(controllers []types.IController) (acl.ACL, error) {
 		}
 	}
 
-	// ... populate ACL from cards + groups + doors
-	grant := func(card uint32, device uint32, door uint8) {
-		if card > 0 && device > 0 && door >= 1 && door <= 4 {
-			if _, ok := acl[device]; ok {
-				if _, ok := acl[device][card]; ok {
-					if _, ok := acl[device][card].Doors[door]; ok {
-						acl[device][card].Doors[door] = 1
-					}
-				}
-			}
+	// ... populate ACL from cards + groups + doors + rules
+	for _, c := range cards {
+		if c.IsDeleted() {
+			continue
 		}
-	}
 
-	revoke := func(card uint32, device uint32, door uint8) {
-		if card > 0 && device > 0 && door >= 1 && door <= 4 {
-			if _, ok := acl[device]; ok {
-				if _, ok := acl[device][card]; ok {
-					if _, ok := acl[device][card].Doors[door]; ok {
-						acl[device][card].Doors[door] = 0
-					}
-				}
-			}
+		permissions, err := s.doorPermissions(c)
+		if err != nil {
+			return nil, err
 		}
-	}
 
-	for _, c := range cards {
-		card := c.CardID
-		membership := c.Groups()
-		for _, g := range membership {
-			if group, ok := groups.Group(g); ok {
-				for d, allowed := range group.Doors {
-					if door, ok := doors.Door(d); ok && allowed {
-						device := catalog.GetDoorDeviceID(door.OID)
-						doorID := catalog.GetDoorDeviceDoor(door.OID)
-
-						grant(card, device, doorID)
+		for door, v := range permissions {
+			device := catalog.GetDoorDeviceID(door)
+			doorID := catalog.GetDoorDeviceDoor(door)
+
+			if card := c.CardID; card > 0 && device > 0 && doorID >= 1 && doorID <= 4 {
+				if _, ok := acl[device]; ok {
+					if _, ok := acl[device][card]; ok {
+						if _, ok := acl[device][card].Doors[doorID]; ok {
+							acl[device][card].Doors[doorID] = v
+						}
 					}
 				}
 			}
@@ -276,28 +253,71 @@ func (s *system) permissions(controllers []types.IController) (acl.ACL, error) {
 		}
 	}
 
-	// ... post-process ACL with rules
-	if sys.rules != nil {
-		for _, c := range cards {
-			card := c.CardID
-			allowed, forbidden, err := sys.rules.Eval(c, sys.doors)
-			if err != nil {
-				return nil, err
-			}
+	return acl, nil
+}
+
+// Returns the door permissions for a card from the card groups and ACL rules as a map of door
+// OID to the controller door permission i.e. 1 for unrestricted access or the time profile ID
+// for time restricted access. Doors that are not included are 'no access'.
+//
+// Unrestricted access takes precedence if a door is granted more than once, otherwise the lowest
+// time profile ID is used (for lack of any better way to combine time profiles). Access to a door
+// with a missing or incomplete time profile is denied.
+func (s *system) doorPermissions(card cards.Card) (map[schema.OID]uint8, error) {
+	permissions := map[schema.OID]uint8{}
+
+	grant := func(door schema.OID, v uint8) {
+		if u, ok := permissions[door]; !ok || (u != 1 && (v == 1 || v < u)) {
+			permissions[door] = v
+		}
+	}
 
-			for _, door := range allowed {
-				device := catalog.GetDoorDeviceID(door.OID)
-				doorID := catalog.GetDoorDeviceDoor(door.OID)
-				grant(card, device, doorID)
+	// ... base permissions from groups
+	for _, g := range card.Groups() {
+		if group, ok := s.groups.Group(g); ok {
+			for door, allowed := range group.Doors {
+				if !allowed {
+					continue
+				}
+
+				if _, ok := s.doors.Door(door); !ok {
+					continue
+				}
+
+				if profile, ok := group.Profiles[door]; !ok || profile == "" {
+					grant(door, 1)
+				} else if id, ok := s.profiles.ProfileID(profile); !ok {
+					warnf("ACL", "card %v: invalid time profile %v for door %v in group %v - access denied", card.CardID, profile, door, group.Name)
+				} else {
+					grant(door, id)
+				}
 			}
+		}
+	}
+
+	// ... update base permissions with grules
+	if s.rules != nil {
+		allowed, forbidden, err := s.rules.Eval(card, s.doors)
+		if err != nil {
+			return nil, err
+		}
 
-			for _, door := range forbidden {
-				device := catalog.GetDoorDeviceID(door.OID)
-				doorID := catalog.GetDoorDeviceDoor(door.OID)
-				revoke(card, device, doorID)
+		for _, g := range allowed {
+			if g.Profile == "" {
+				grant(g.Door.OID, 1)
+			} else if oid, ok := s.profiles.Find(g.Profile); !ok {
+				warnf("ACL", "card %v: unknown time profile '%v' for door %v - access denied", card.CardID, g.Profile, g.Door.OID)
+			} else if id, ok := s.profiles.ProfileID(oid); !ok {
+				warnf("ACL", "card %v: incomplete time profile '%v' for door %v - access denied", card.CardID, g.Profile, g.Door.OID)
+			} else {
+				grant(g.Door.OID, id)
 			}
 		}
+
+		for _, door := range forbidden {
+			delete(permissions, door.OID)
+		}
 	}
 
-	return acl, nil
+	return permissions, nil
 }
@@ -0,0 +1,691 @@
+package system
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/hyperjumptech/grule-rule-engine/ast"
+	"github.com/hyperjumptech/grule-rule-engine/builder"
+	"github.com/hyperjumptech/grule-rule-engine/pkg"
+
+	"github.com/uhppoted/uhppote-core/uhppote"
+	"github.com/uhppoted/uhppoted-lib/config"
+
+	"github.com/uhppoted/uhppoted-httpd/audit"
+	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/backup"
+	authdb "github.com/uhppoted/uhppoted-httpd/httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/settings"
+	"github.com/uhppoted/uhppoted-httpd/system/cards"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog"
+	memdb "github.com/uhppoted/uhppoted-httpd/system/catalog/impl"
+	"github.com/uhppoted/uhppoted-httpd/system/controllers"
+	"github.com/uhppoted/uhppoted-httpd/system/db"
+	"github.com/uhppoted/uhppoted-httpd/system/doors"
+	"github.com/uhppoted/uhppoted-httpd/system/events"
+	"github.com/uhppoted/uhppoted-httpd/system/groups"
+	"github.com/uhppoted/uhppoted-httpd/system/history"
+	"github.com/uhppoted/uhppoted-httpd/system/interfaces"
+	"github.com/uhppoted/uhppoted-httpd/system/logs"
+	"github.com/uhppoted/uhppoted-httpd/system/sqlite"
+	"github.com/uhppoted/uhppoted-httpd/system/tasks"
+	"github.com/uhppoted/uhppoted-httpd/system/timeprofiles"
+	"github.com/uhppoted/uhppoted-httpd/system/users"
+	"github.com/uhppoted/uhppoted-httpd/types"
+)
+
+const application = "uhppoted-httpd"
+
+// backups holds the files included in a backup archive (other than the system files) and the
+// destinations for scheduled backups.
+type backups struct {
+	auth   string
+	grules map[string]string
+	audit  string
+	dir    string
+	keep   int
+	s3     *backup.S3
+}
+
+// Restored summarises a restore from a backup archive.
+type Restored struct {
+	Manifest backup.Manifest
+	Backup   string
+	Files    []string
+	Skipped  []string
+}
+
+type replacement struct {
+	file  string
+	bytes []byte
+}
+
+func newBackups(cfg config.Config, s *settings.Settings) backups {
+	b := backups{
+		auth: cfg.HTTPD.Security.AuthDB,
+		grules: map[string]string{
+			"acl":           cfg.HTTPD.DB.Rules.ACL,
+			"interfaces":    cfg.HTTPD.DB.Rules.Interfaces,
+			"controllers":   cfg.HTTPD.DB.Rules.Controllers,
+			"doors":         cfg.HTTPD.DB.Rules.Doors,
+			"cards":         cfg.HTTPD.DB.Rules.Cards,
+			"groups":        cfg.HTTPD.DB.Rules.Groups,
+			"events":        cfg.HTTPD.DB.Rules.Events,
+			"logs":          cfg.HTTPD.DB.Rules.Logs,
+			"users":         cfg.HTTPD.DB.Rules.Users,
+			"time-profiles": s.DB.Rules.TimeProfiles,
+			"tasks":         s.DB.Rules.Tasks,
+		},
+		audit: cfg.HTTPD.Audit.File,
+		dir:   s.Backup.Dir,
+		keep:  int(s.Backup.Keep),
+	}
+
+	if b.dir == "" && cfg.HTTPD.System.Groups != "" {
+		b.dir = filepath.Join(filepath.Dir(filepath.Dir(cfg.HTTPD.System.Groups)), "backup")
+	}
+
+	if s.Backup.S3.Bucket != "" {
+		b.s3 = &backup.S3{
+			Endpoint:  s.Backup.S3.Endpoint,
+			Region:    s.Backup.S3.Region,
+			Bucket:    s.Backup.S3.Bucket,
+			Prefix:    s.Backup.S3.Prefix,
+			AccessKey: s.Backup.S3.AccessKey,
+			SecretKey: s.Backup.S3.SecretKey,
+		}
+	}
+
+	return b
+}
+
+func (b backups) stores() []backup.Store {
+	stores := []backup.Store{}
+
+	if b.dir != "" {
+		stores = append(stores, backup.Dir{Path: b.dir})
+	}
+
+	if b.s3 != nil {
+		stores = append(stores, *b.s3)
+	}
+
+	return stores
+}
+
+// Backup returns a backup archive of the running system. The system files are serialized from
+// the in-memory system while holding the system lock so that the archive is a consistent
+// snapshot. Backups are restricted to unscoped administrators.
+func Backup(uid, role string) ([]byte, error) {
+	sys.RLock()
+	err := sys.users.CanBackup(auth.NewAuthorizator(uid, role))
+	sys.RUnlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := sys.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	if err := a.Write(&b); err != nil {
+		return nil, err
+	}
+
+	sys.Lock()
+	defer sys.Unlock()
+
+	dbc := db.NewDBC(sys.trail)
+	dbc.Log(uid, "backup", "", "system", "", "", "", "", "", "Downloaded backup archive (%v files)", len(a.Manifest.Files))
+	dbc.Commit(&sys, func() {})
+
+	return b.Bytes(), nil
+}
+
+// BackupFile returns the file name for a backup archive created at 'timestamp'.
+func BackupFile(timestamp time.Time) string {
+	return backup.Filename(application, timestamp)
+}
+
+// Snapshot writes a backup archive of the stored system i.e. from the JSON files or SQLite
+// database. Intended for use while the service is not running.
+func Snapshot(cfg config.Config, s *settings.Settings, w io.Writer) (*backup.Manifest, error) {
+	blobs, err := stored(cfg, s)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := archive(blobs, newBackups(cfg, s))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.Write(w); err != nil {
+		return nil, err
+	}
+
+	return &a.Manifest, nil
+}
+
+// Restore validates a backup archive and then replaces the system files, auth DB and grules
+// files with the contents of the archive. The audit trail files are only restored if 'withAudit'
+// is set. The current state is saved to the backup folder before any files are replaced and
+// the replaced files are restored if any of the files cannot be replaced.
+//
+// Intended for use while the service is not running.
+func Restore(cfg config.Config, s *settings.Settings, r io.Reader, withAudit bool) (*Restored, error) {
+	a, err := backup.Read(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verify(a); err != nil {
+		return nil, err
+	}
+
+	b := newBackups(cfg, s)
+	files := files(cfg, s)
+	restored := Restored{
+		Manifest: a.Manifest,
+		Files:    []string{},
+		Skipped:  []string{},
+	}
+
+	list := []replacement{}
+	blobs := map[string]json.RawMessage{}
+	dbfile := database(cfg, s)
+
+	replace := func(f backup.File, file string) {
+		if file == "" || slices.ContainsFunc(list, func(v replacement) bool { return v.file == file }) {
+			restored.Skipped = append(restored.Skipped, f.Name)
+		} else {
+			list = append(list, replacement{file, a.Contents(f)})
+		}
+	}
+
+	for _, f := range a.Files(backup.System) {
+		tag := Tag(f.Tag)
+
+		if tag == TagSessions {
+			restored.Skipped = append(restored.Skipped, f.Name)
+			continue
+		}
+
+		if dbfile != "" {
+			m := map[Tag]json.RawMessage{}
+			if err := json.Unmarshal(a.Contents(f), &m); err != nil {
+				return nil, err
+			}
+
+			blobs[f.Tag] = m[tag]
+		} else {
+			replace(f, files[tag])
+		}
+	}
+
+	for _, f := range a.Files(backup.Auth) {
+		replace(f, b.auth)
+	}
+
+	for _, f := range a.Files(backup.Grules) {
+		replace(f, b.grules[f.Tag])
+	}
+
+	for _, f := range a.Files(backup.Audit) {
+		if !withAudit || b.audit == "" {
+			restored.Skipped = append(restored.Skipped, f.Name)
+		} else {
+			replace(f, filepath.Join(filepath.Dir(b.audit), path.Base(f.Name)))
+		}
+	}
+
+	// ... save the current state
+	if b.dir != "" {
+		var current bytes.Buffer
+		if manifest, err := Snapshot(cfg, s, &current); err != nil {
+			return nil, fmt.Errorf("error backing up current system (%v)", err)
+		} else {
+			name := backup.Filename(application, manifest.Created)
+			if err := (backup.Dir{Path: b.dir}).Put(name, current.Bytes()); err != nil {
+				return nil, fmt.Errorf("error backing up current system (%v)", err)
+			}
+
+			restored.Backup = filepath.Join(b.dir, name)
+		}
+	}
+
+	// ... swap in the restored files
+	sw, err := swap(list)
+	if err != nil {
+		return nil, err
+	}
+
+	if dbfile != "" && len(blobs) > 0 {
+		d, err := sqlite.Open(dbfile)
+		if err != nil {
+			sw.rollback()
+			return nil, err
+		}
+
+		defer d.Close()
+
+		if err := d.Replace(blobs); err != nil {
+			sw.rollback()
+			return nil, err
+		}
+
+		for tag := range blobs {
+			restored.Files = append(restored.Files, fmt.Sprintf("%v (%v)", dbfile, tag))
+		}
+	}
+
+	sw.commit()
+
+	for _, v := range list {
+		restored.Files = append(restored.Files, v.file)
+	}
+
+	slices.Sort(restored.Files)
+
+	return &restored, nil
+}
+
+// Serializes the system files while holding the system lock. The auth DB, grules and audit trail
+// files are read after the lock has been released (audit.Snapshot takes the audit trail lock,
+// which is also taken while holding the system lock to write audit records).
+func (s *system) snapshot() (*backup.Archive, error) {
+	blobs, err := func() (map[Tag]json.RawMessage, error) {
+		s.Lock()
+		defer s.Unlock()
+
+		blobs := map[Tag]json.RawMessage{}
+		for _, v := range backedUp() {
+			if blob, err := v.Save(); err != nil {
+				return nil, err
+			} else {
+				blobs[v.tag] = blob
+			}
+		}
+
+		return blobs, nil
+	}()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return archive(blobs, s.backups)
+}
+
+// Backs up the running system to the backup folder and S3 bucket (if configured), deleting all
+// but the most recent backups.
+func (s *system) backup() error {
+	stores := s.backups.stores()
+	if len(stores) == 0 {
+		return fmt.Errorf("no backup folder or S3 bucket configured")
+	}
+
+	a, err := s.snapshot()
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	if err := a.Write(&b); err != nil {
+		return err
+	}
+
+	name := backup.Filename(application, a.Manifest.Created)
+	errs := []error{}
+
+	for _, store := range stores {
+		if err := store.Put(name, b.Bytes()); err != nil {
+			errs = append(errs, fmt.Errorf("error storing backup to %v (%v)", store, err))
+			continue
+		}
+
+		infof("backup", "stored backup %v to %v", name, store)
+
+		if deleted, err := backup.Rotate(store, application, s.backups.keep); err != nil {
+			errs = append(errs, err)
+		} else if len(deleted) > 0 {
+			infof("backup", "deleted %v old backups from %v", len(deleted), store)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Returns the stored system subsystems from the SQLite database (if configured) or JSON files.
+// Subsystems that have not been migrated to the SQLite database are read from the JSON files.
+func stored(cfg config.Config, s *settings.Settings) (map[Tag]json.RawMessage, error) {
+	files := files(cfg, s)
+	blobs := map[Tag]json.RawMessage{}
+
+	var d *sqlite.DB
+
+	if file := database(cfg, s); file != "" {
+		if _, err := os.Stat(file); err == nil {
+			if d, err = sqlite.Open(file); err != nil {
+				return nil, err
+			}
+
+			defer d.Close()
+		}
+	}
+
+	for _, v := range backedUp() {
+		if d != nil {
+			if blob, ok, err := d.Load(string(v.tag)); err != nil {
+				return nil, err
+			} else if ok {
+				blobs[v.tag] = blob
+				continue
+			}
+		}
+
+		if file := files[v.tag]; file != "" {
+			bytes, err := os.ReadFile(file)
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+
+			m := map[Tag]json.RawMessage{}
+			if err := json.Unmarshal(bytes, &m); err != nil {
+				return nil, fmt.Errorf("%v: %v", file, err)
+			}
+
+			if blob, ok := m[v.tag]; ok {
+				blobs[v.tag] = blob
+			}
+		}
+	}
+
+	return blobs, nil
+}
+
+// Returns the subsystems included in a backup archive. Login sessions are excluded - the session
+// tokens are credentials and restoring them would revive ended sessions.
+func backedUp() []struct {
+	serializable
+	tag Tag
+} {
+	list := subsystems()
+	filtered := list[:0]
+
+	for _, v := range list {
+		if v.tag != TagSessions {
+			filtered = append(filtered, v)
+		}
+	}
+
+	return filtered
+}
+
+// Builds a backup archive from the system subsystems, auth DB, grules files and audit trail.
+func archive(blobs map[Tag]json.RawMessage, b backups) (*backup.Archive, error) {
+	a := backup.NewArchive(application, uhppote.VERSION)
+
+	for _, v := range backedUp() {
+		if blob, ok := blobs[v.tag]; ok {
+			if bytes, err := encode(v.tag, blob); err != nil {
+				return nil, err
+			} else {
+				a.Add(backup.System, string(v.tag), fmt.Sprintf("%v.json", v.tag), bytes)
+			}
+		}
+	}
+
+	if b.auth != "" {
+		if bytes, err := os.ReadFile(b.auth); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		} else if err == nil {
+			a.Add(backup.Auth, "", "auth.json", bytes)
+		}
+	}
+
+	tags := []string{}
+	for tag := range b.grules {
+		tags = append(tags, tag)
+	}
+
+	slices.Sort(tags)
+
+	for _, tag := range tags {
+		if file := b.grules[tag]; file != "" {
+			if bytes, err := os.ReadFile(file); err != nil && !os.IsNotExist(err) {
+				return nil, err
+			} else if err == nil {
+				a.Add(backup.Grules, tag, fmt.Sprintf("%v.grl", tag), bytes)
+			}
+		}
+	}
+
+	if b.audit != "" {
+		files, err := audit.Snapshot(b.audit)
+		if err != nil {
+			return nil, err
+		}
+
+		names := []string{}
+		for name := range files {
+			names = append(names, name)
+		}
+
+		slices.Sort(names)
+
+		for _, name := range names {
+			a.Add(backup.Audit, "", name, files[name])
+		}
+	}
+
+	return a, nil
+}
+
+// Verifies the contents of a backup archive by loading the system files into empty subsystems,
+// loading the auth DB and compiling the grules files.
+func verify(a *backup.Archive) error {
+	catalog.Init(memdb.NewCatalog())
+
+	for _, f := range a.Files(backup.System) {
+		tag := Tag(f.Tag)
+		if tag == TagSessions {
+			continue
+		}
+
+		v := restorable(tag)
+		if v == nil {
+			return fmt.Errorf("%v: unknown system file '%v'", f.Name, f.Tag)
+		}
+
+		m := map[Tag]json.RawMessage{}
+		if err := json.Unmarshal(a.Contents(f), &m); err != nil {
+			return fmt.Errorf("%v: %v", f.Name, err)
+		}
+
+		blob, ok := m[tag]
+		if !ok {
+			return fmt.Errorf("%v: missing '%v'", f.Name, tag)
+		}
+
+		if err := v.Load(blob); err != nil {
+			return fmt.Errorf("%v: %v", f.Name, err)
+		}
+
+		if validator, ok := v.(interface{ Validate() error }); ok {
+			if err := validator.Validate(); err != nil {
+				return fmt.Errorf("%v: %v", f.Name, err)
+			}
+		}
+	}
+
+	for _, f := range a.Files(backup.Auth) {
+		if err := authdb.ValidateAuthDB(bytes.NewReader(a.Contents(f))); err != nil {
+			return fmt.Errorf("%v: %v", f.Name, err)
+		}
+	}
+
+	for _, f := range a.Files(backup.Grules) {
+		kb := ast.NewKnowledgeLibrary()
+		if err := builder.NewRuleBuilder(kb).BuildRuleFromResource(f.Tag, "0.0.0", pkg.NewBytesResource(a.Contents(f))); err != nil {
+			return fmt.Errorf("%v: %v", f.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Returns an empty subsystem for validating a restored system file.
+func restorable(tag Tag) serializable {
+	switch tag {
+	case TagInterfaces:
+		v := interfaces.NewInterfaces(make(chan types.EventsList))
+		return &v
+	case TagControllers:
+		v := controllers.NewControllers()
+		return &v
+	case TagDoors:
+		v := doors.NewDoors()
+		return &v
+	case TagCards:
+		v := cards.NewCards()
+		return &v
+	case TagGroups:
+		v := groups.NewGroups()
+		return &v
+	case TagEvents:
+		v := events.NewEvents()
+		return &v
+	case TagLogs:
+		v := logs.NewLogs()
+		return &v
+	case TagUsers:
+		v := users.NewUsers()
+		return &v
+	case TagHistory:
+		v := history.NewHistory()
+		return &v
+	case TagTimeProfiles:
+		v := timeprofiles.NewTimeProfiles()
+		return &v
+	case TagTasks:
+		v := tasks.NewTasks()
+		return &v
+	case TagTaskRuns:
+		v := tasks.NewRuns()
+		return &v
+	default:
+		return nil
+	}
+}
+
+// Replaces the files with the restored files. The restored files are first written to temporary
+// files in the same folder as the file being replaced and then renamed, setting aside the files
+// being replaced until the restore is either committed or rolled back.
+func swap(list []replacement) (*swapped, error) {
+	staged := []string{}
+
+	cleanup := func() {
+		for _, f := range staged {
+			os.Remove(f)
+		}
+	}
+
+	for _, v := range list {
+		if err := os.MkdirAll(filepath.Dir(v.file), 0770); err != nil {
+			cleanup()
+			return nil, err
+		}
+
+		tmp, err := os.CreateTemp(filepath.Dir(v.file), "."+filepath.Base(v.file)+".*.restore")
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+
+		staged = append(staged, tmp.Name())
+
+		if _, err := tmp.Write(v.bytes); err != nil {
+			tmp.Close()
+			cleanup()
+			return nil, err
+		}
+
+		if err := tmp.Sync(); err != nil {
+			tmp.Close()
+			cleanup()
+			return nil, err
+		}
+
+		if err := tmp.Close(); err != nil {
+			cleanup()
+			return nil, err
+		}
+	}
+
+	suffix := fmt.Sprintf(".%v.replaced", time.Now().Format("20060102150405"))
+	sw := swapped{
+		files:    []string{},
+		replaced: map[string]string{},
+	}
+
+	for i, v := range list {
+		if _, err := os.Stat(v.file); err == nil {
+			if err := os.Rename(v.file, v.file+suffix); err != nil {
+				sw.rollback()
+				cleanup()
+				return nil, err
+			}
+
+			sw.replaced[v.file] = v.file + suffix
+		}
+
+		sw.files = append(sw.files, v.file)
+
+		if err := os.Rename(staged[i], v.file); err != nil {
+			sw.rollback()
+			cleanup()
+			return nil, err
+		}
+	}
+
+	return &sw, nil
+}
+
+type swapped struct {
+	files    []string
+	replaced map[string]string
+}
+
+// Reinstates the replaced files.
+func (s swapped) rollback() {
+	for _, file := range slices.Backward(s.files) {
+		if original, ok := s.replaced[file]; ok {
+			if err := os.Rename(original, file); err != nil {
+				warnf("restore", "error reinstating %v (%v)", file, err)
+			}
+		} else {
+			os.Remove(file)
+		}
+	}
+}
+
+// Deletes the replaced files.
+func (s swapped) commit() {
+	for _, original := range s.replaced {
+		os.Remove(original)
+	}
+}
@@ -0,0 +1,185 @@
+package system
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/uhppoted/uhppoted-lib/config"
+
+	"github.com/uhppoted/uhppoted-httpd/backup"
+	"github.com/uhppoted/uhppoted-httpd/settings"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	cfg, s := starterkit(t)
+
+	var b bytes.Buffer
+	if _, err := Snapshot(*cfg, s, &b); err != nil {
+		t.Fatalf("Error backing up system (%v)", err)
+	}
+
+	original, _ := os.ReadFile(cfg.HTTPD.System.Cards)
+	if err := os.WriteFile(cfg.HTTPD.System.Cards, []byte(`{"cards":[]}`), 0600); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	restored, err := Restore(*cfg, s, &b, false)
+	if err != nil {
+		t.Fatalf("Error restoring system (%v)", err)
+	}
+
+	if restored, _ := os.ReadFile(cfg.HTTPD.System.Cards); !equal(restored, original) {
+		t.Errorf("Incorrect restored cards\n   expected:%s\n   got:     %s", original, restored)
+	}
+
+	// ... current state should have been backed up before restoring
+	f, err := os.Open(restored.Backup)
+	if err != nil {
+		t.Fatalf("Error opening pre-restore backup (%v)", err)
+	}
+
+	defer f.Close()
+
+	if archive, err := backup.Read(f); err != nil {
+		t.Fatalf("Error reading pre-restore backup (%v)", err)
+	} else {
+		for _, f := range archive.Files(backup.System) {
+			if f.Tag == "cards" && !equal(archive.Contents(f), []byte(`{"cards":[]}`)) {
+				t.Errorf("Incorrect pre-restore backup cards\n   expected:%s\n   got:     %s", `{"cards":[]}`, archive.Contents(f))
+			}
+		}
+	}
+
+	// ... no staged or replaced files should be left behind
+	files, _ := os.ReadDir(filepath.Dir(cfg.HTTPD.System.Cards))
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), ".restore") || strings.HasSuffix(f.Name(), ".replaced") {
+			t.Errorf("Unexpected file %v after restore", f.Name())
+		}
+	}
+}
+
+func TestRestoreInvalidArchive(t *testing.T) {
+	tests := map[string]struct {
+		filetype string
+		tag      string
+		name     string
+		contents string
+	}{
+		"cards":   {backup.System, "cards", "cards.json", `{"cards":{}}`},
+		"missing": {backup.System, "cards", "cards.json", `{"groups":[]}`},
+		"unknown": {backup.System, "widgets", "widgets.json", `{"widgets":[]}`},
+		"auth":    {backup.Auth, "", "auth.json", `{"resources":[{"path":"^/cards(","authorised":"admin"}]}`},
+		"grules":  {backup.Grules, "acl", "acl.grl", `rule Start "Start" {`},
+	}
+
+	for name, test := range tests {
+		cfg, s := starterkit(t)
+		original, _ := os.ReadFile(cfg.HTTPD.System.Cards)
+
+		a := backup.NewArchive("uhppoted-httpd", "v0.9.x")
+		a.Add(backup.System, "groups", "groups.json", []byte(`{"groups":[]}`))
+		a.Add(test.filetype, test.tag, test.name, []byte(test.contents))
+
+		var b bytes.Buffer
+		if err := a.Write(&b); err != nil {
+			t.Fatalf("%v: error creating backup archive (%v)", name, err)
+		}
+
+		if _, err := Restore(*cfg, s, &b, false); err == nil {
+			t.Errorf("%v: expected error restoring invalid backup archive", name)
+		}
+
+		if cards, _ := os.ReadFile(cfg.HTTPD.System.Cards); !bytes.Equal(cards, original) {
+			t.Errorf("%v: cards unexpectedly modified by invalid restore", name)
+		}
+
+		if groups, _ := os.ReadFile(cfg.HTTPD.System.Groups); equal(groups, []byte(`{"groups":[]}`)) {
+			t.Errorf("%v: groups unexpectedly modified by invalid restore", name)
+		}
+	}
+}
+
+func TestRestoreSkipsSessions(t *testing.T) {
+	cfg, s := starterkit(t)
+	s.System.Sessions = filepath.Join(filepath.Dir(cfg.HTTPD.System.Cards), "sessions.json")
+
+	a := backup.NewArchive("uhppoted-httpd", "v0.9.x")
+	a.Add(backup.System, "groups", "groups.json", []byte(`{"groups":[]}`))
+	a.Add(backup.System, "sessions", "sessions.json", []byte(`{"sessions":[]}`))
+
+	var b bytes.Buffer
+	if err := a.Write(&b); err != nil {
+		t.Fatalf("Error creating backup archive (%v)", err)
+	}
+
+	restored, err := Restore(*cfg, s, &b, false)
+	if err != nil {
+		t.Fatalf("Error restoring system (%v)", err)
+	}
+
+	if !slices.Contains(restored.Skipped, "system/sessions.json") {
+		t.Errorf("Expected sessions to be skipped - skipped:%v", restored.Skipped)
+	}
+
+	if _, err := os.Stat(s.System.Sessions); !os.IsNotExist(err) {
+		t.Errorf("Unexpected restored sessions file (%v)", err)
+	}
+}
+
+// Copies the starter kit system files, auth DB and ACL rules to a temporary folder.
+func starterkit(t *testing.T) (*config.Config, *settings.Settings) {
+	t.Helper()
+
+	kit := filepath.Join("..", "documentation", "starter-kit")
+	dir := t.TempDir()
+
+	cp := func(src, dest string) string {
+		bytes, err := os.ReadFile(filepath.Join(kit, src))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		file := filepath.Join(dir, dest)
+		if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+			t.Fatalf("%v", err)
+		} else if err := os.WriteFile(file, bytes, 0600); err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		return file
+	}
+
+	cfg := config.NewConfig()
+	cfg.HTTPD.System.Cards = cp("var/httpd/system/cards.json", "system/cards.json")
+	cfg.HTTPD.System.Groups = cp("var/httpd/system/groups.json", "system/groups.json")
+	cfg.HTTPD.System.Users = cp("var/httpd/system/users.json", "system/users.json")
+	cfg.HTTPD.Security.AuthDB = cp("etc/httpd/auth.json", "etc/auth.json")
+	cfg.HTTPD.DB.Rules.ACL = cp("etc/httpd/acl.grl", "etc/acl.grl")
+	cfg.HTTPD.Audit.File = ""
+
+	s := settings.NewSettings()
+	s.Backup.Dir = filepath.Join(dir, "backup")
+
+	return cfg, s
+}
+
+func equal(p, q []byte) bool {
+	var u, v any
+
+	if err := json.Unmarshal(p, &u); err != nil {
+		return false
+	}
+
+	if err := json.Unmarshal(q, &v); err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(u, v)
+}
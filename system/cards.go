@@ -1,16 +1,21 @@
 package system
 
 import (
+	"bytes"
 	"fmt"
+	"strings"
 
 	lib "github.com/uhppoted/uhppote-core/types"
 
 	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/system/cards"
 	"github.com/uhppoted/uhppoted-httpd/system/catalog"
 	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
 	"github.com/uhppoted/uhppoted-httpd/system/db"
 )
 
+type CardChange = cards.Change
+
 func SetDefaultCardStartDate(v string) {
 	if date, err := lib.ParseDate(v); err == nil && !date.IsZero() {
 		sys.acl.defaultStartDate = date
@@ -102,3 +107,75 @@ func UpdateCards(uid, role string, m map[string]any) (any, error) {
 
 	return dbc.Objects(), nil
 }
+
+// Returns the groups for a card number, for checking whether a card is within the scope of a
+// scoped administrator.
+func GetCardGroups(card uint32) ([]string, bool) {
+	if c, _ := sys.cards.Lookup(card); c != nil && !c.IsDeleted() {
+		groups := []string{}
+		for _, g := range c.Groups() {
+			groups = append(groups, string(g))
+		}
+
+		return groups, true
+	}
+
+	return nil, false
+}
+
+// Returns the cards list as CSV, with a column for each group.
+func ExportCards(uid, role string) ([]byte, error) {
+	sys.RLock()
+	defer sys.RUnlock()
+
+	auth := auth.NewAuthorizator(uid, role)
+
+	var b bytes.Buffer
+	if err := sys.cards.ToCSV(auth, &b); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// Imports a cards CSV file. Returns the list of changes without updating the cards list unless
+// confirmed, in which case the changes are applied in the same way as an interactive edit. Cards
+// that are not in the CSV file are only deleted if deleteMissing is true.
+func ImportCards(uid, role string, csv string, confirm, deleteMissing bool) ([]CardChange, []schema.Object, error) {
+	sys.Lock()
+	defer sys.Unlock()
+
+	records, err := cards.ParseCSV(strings.NewReader(csv))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	auth := auth.NewAuthorizator(uid, role)
+	changes := sys.cards.Diff(auth, records, deleteMissing)
+	if !confirm || len(changes) == 0 {
+		return changes, nil, nil
+	}
+
+	dbc := db.NewDBC(sys.trail)
+	shadow := sys.cards.Clone()
+
+	if objects, err := shadow.Import(auth, changes, dbc); err != nil {
+		return nil, nil, err
+	} else {
+		dbc.Stash(objects)
+	}
+
+	if err := shadow.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	if err := save(TagCards, &shadow); err != nil {
+		return nil, nil, err
+	}
+
+	dbc.Commit(&sys, func() {
+		sys.cards = shadow
+	})
+
+	return changes, dbc.Objects(), nil
+}
@@ -13,18 +13,59 @@ type TAuthable interface {
 
 var rulesets = []auth.RuleSet{auth.Cards}
 
+// A scoped administrator can view a card if it is a member of any group in scope but can only
+// update or delete a card if all its groups are in scope. Cards that are not a member of any group
+// (including new cards) are only in scope if the scope includes ungrouped cards.
 func CanView[T TAuthable](a auth.OpAuth, u T, field string, value any) error {
+	if !auth.ScopeOf(a).HasAnyGroup(memberOf(u)...) {
+		return auth.ErrUnauthorised
+	}
+
 	return auth.CanView(a, u, field, value, rulesets...)
 }
 
 func CanAdd[T TAuthable](a auth.OpAuth, u T) error {
+	if !auth.ScopeOf(a).HasAllGroups(memberOf(u)...) {
+		return auth.ErrUnauthorised
+	}
+
 	return auth.CanAdd(a, u, rulesets...)
 }
 
 func CanUpdate[T TAuthable](a auth.OpAuth, u T, field string, value any) error {
+	if field == "group" && !auth.ScopeOf(a).HasAnyGroup(memberOf(u)...) {
+		return auth.ErrUnauthorised
+	} else if field != "group" && !auth.ScopeOf(a).HasAllGroups(memberOf(u)...) {
+		return auth.ErrUnauthorised
+	}
+
 	return auth.CanUpdate(a, u, field, value, rulesets...)
 }
 
 func CanDelete[T TAuthable](a auth.OpAuth, u T) error {
+	if !auth.ScopeOf(a).HasAllGroups(memberOf(u)...) {
+		return auth.ErrUnauthorised
+	}
+
 	return auth.CanDelete(a, u, rulesets...)
 }
+
+func memberOf[T TAuthable](u T) []string {
+	var c Card
+
+	switch v := any(u).(type) {
+	case Card:
+		c = v
+	case *Card:
+		if v != nil {
+			c = *v
+		}
+	}
+
+	list := []string{}
+	for _, g := range c.Groups() {
+		list = append(list, string(g))
+	}
+
+	return list
+}
@@ -134,11 +134,16 @@ func (c *Card) AsObjects(a *auth.Authorizator) []schema.Object {
 		list = append(list, kv{CardPIN, c.pin})
 
 		groups := catalog.GetGroups()
+		scope := auth.ScopeOf(a)
 		re := regexp.MustCompile(`^(.*?)(\.[0-9]+)$`)
 
 		for _, group := range groups {
 			g := group
 
+			if !scope.HasGroup(string(g)) {
+				continue
+			}
+
 			if m := re.FindStringSubmatch(string(g)); len(m) > 2 {
 				gid := m[2]
 				member := c.groups[g]
@@ -299,6 +304,8 @@ func (c *Card) set(a *auth.Authorizator, oid schema.OID, value string, dbc db.DB
 
 			if err := CanUpdate(a, c, "group", value); err != nil {
 				return nil, err
+			} else if !auth.ScopeOf(a).HasGroup(string(k)) {
+				return nil, auth.ErrUnauthorised
 			} else if !catalog.HasGroup(schema.OID(k)) {
 				return nil, fmt.Errorf("invalid group OID (%v)", k)
 			} else {
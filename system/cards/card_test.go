@@ -263,3 +263,139 @@ func TestCardSetWithDeleted(t *testing.T) {
 		t.Errorf("Card name unexpectedly updated - expected:%v, got:%v", "Le Carte", c.name)
 	}
 }
+
+func TestCardAsObjectsWithScope(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+	catalog.PutT(catalog.CatalogGroup{OID: "0.5.1"})
+	catalog.PutT(catalog.CatalogGroup{OID: "0.5.2"})
+
+	if err := auth.Init(nil, "admin"); err != nil {
+		t.Fatalf("Error initialising auth (%v)", err)
+	}
+
+	auth.SetScopes(func(uid string) *auth.Scope {
+		return &auth.Scope{Groups: []string{"0.5.1"}}
+	})
+
+	defer auth.SetScopes(nil)
+
+	a := auth.NewAuthorizator("moony", "admin")
+
+	tests := []struct {
+		card     Card
+		expected []schema.OID
+	}{
+		{makeCard("0.4.1", "Hagrid", 6514231, "0.5.1"), []schema.OID{"0.4.1", "0.4.1.5.1", "0.4.1.5.1.1"}},
+		{makeCard("0.4.2", "Dobby", 1234567, "0.5.2"), []schema.OID{}},
+		{makeCard("0.4.3", "Harry", 7654321, "0.5.1", "0.5.2"), []schema.OID{"0.4.3", "0.4.3.5.1", "0.4.3.5.1.1"}},
+		{makeCard("0.4.4", "Ron", 8165537), []schema.OID{}},
+	}
+
+	for _, test := range tests {
+		oids := []schema.OID{}
+		for _, o := range test.card.AsObjects(a) {
+			if o.OID == test.card.OID || strings.HasPrefix(string(o.OID), string(test.card.OID.Append(CardGroups))) {
+				oids = append(oids, o.OID)
+			}
+		}
+
+		if !reflect.DeepEqual(oids, test.expected) {
+			t.Errorf("%v: incorrect scoped objects\n   expected:%v\n   got:     %v", test.card.name, test.expected, oids)
+		}
+	}
+}
+
+func TestCardSetWithScope(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+	catalog.PutT(catalog.CatalogGroup{OID: "0.5.1"})
+	catalog.PutT(catalog.CatalogGroup{OID: "0.5.2"})
+
+	if err := auth.Init(nil, "admin"); err != nil {
+		t.Fatalf("Error initialising auth (%v)", err)
+	}
+
+	auth.SetScopes(func(uid string) *auth.Scope {
+		return &auth.Scope{Groups: []string{"0.5.1"}}
+	})
+
+	defer auth.SetScopes(nil)
+
+	a := auth.NewAuthorizator("moony", "admin")
+
+	tests := []struct {
+		card  Card
+		oid   schema.OID
+		value string
+		ok    bool
+	}{
+		{makeCard("0.4.1", "Hagrid", 6514231, "0.5.1"), "0.4.1.1", "Hagrid II", true},
+		{makeCard("0.4.2", "Dobby", 1234567, "0.5.2"), "0.4.2.1", "Dobby II", false},
+		{makeCard("0.4.3", "Harry", 7654321, "0.5.1", "0.5.2"), "0.4.3.1", "Harry II", false},
+		{makeCard("0.4.3", "Harry", 7654321, "0.5.1", "0.5.2"), "0.4.3.5.1", "false", true},
+		{makeCard("0.4.3", "Harry", 7654321, "0.5.1", "0.5.2"), "0.4.3.5.2", "false", false},
+		{makeCard("0.4.4", "Ron", 8165537), "0.4.4.5.1", "true", false},
+		{makeCard("0.4.4", "Ron", 8165537), "0.4.4.5.2", "true", false},
+	}
+
+	for _, test := range tests {
+		c := test.card.clone()
+
+		if _, err := c.set(a, test.oid, test.value, db.DBC{}); test.ok && err != nil {
+			t.Errorf("%v: unexpected error updating %v (%v)", test.card.name, test.oid, err)
+		} else if !test.ok && !errors.Is(err, auth.ErrUnauthorised) {
+			t.Errorf("%v: expected 'unauthorised' error updating %v, got %v", test.card.name, test.oid, err)
+		}
+	}
+
+	if err := CanDelete(a, makeCard("0.4.3", "Harry", 7654321, "0.5.1", "0.5.2")); !errors.Is(err, auth.ErrUnauthorised) {
+		t.Errorf("Expected 'unauthorised' error deleting card with out of scope group, got %v", err)
+	}
+}
+
+func TestCardSetWithUngroupedScope(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+	catalog.PutT(catalog.CatalogGroup{OID: "0.5.1"})
+	catalog.PutT(catalog.CatalogGroup{OID: "0.5.2"})
+
+	if err := auth.Init(nil, "admin"); err != nil {
+		t.Fatalf("Error initialising auth (%v)", err)
+	}
+
+	auth.SetScopes(func(uid string) *auth.Scope {
+		switch uid {
+		case "moony":
+			return &auth.Scope{Groups: []string{"0.5.1"}}
+		case "padfoot":
+			return &auth.Scope{Groups: []string{"0.5.1"}, Ungrouped: true}
+		default:
+			return nil
+		}
+	})
+
+	defer auth.SetScopes(nil)
+
+	tests := []struct {
+		uid string
+		ok  bool
+	}{
+		{"moony", false},
+		{"padfoot", true},
+	}
+
+	for _, test := range tests {
+		a := auth.NewAuthorizator(test.uid, "admin")
+		c := makeCard("0.4.4", "Ron", 8165537)
+
+		if _, err := c.set(a, "0.4.4.5.1", "true", db.DBC{}); test.ok && err != nil {
+			t.Errorf("%v: unexpected error updating ungrouped card (%v)", test.uid, err)
+		} else if !test.ok && !errors.Is(err, auth.ErrUnauthorised) {
+			t.Errorf("%v: expected 'unauthorised' error updating ungrouped card, got %v", test.uid, err)
+		}
+
+		if err := CanAdd(a, &Card{}); test.ok && err != nil {
+			t.Errorf("%v: unexpected error adding card (%v)", test.uid, err)
+		} else if !test.ok && !errors.Is(err, auth.ErrUnauthorised) {
+			t.Errorf("%v: expected 'unauthorised' error adding card, got %v", test.uid, err)
+		}
+	}
+}
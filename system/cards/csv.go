@@ -0,0 +1,501 @@
+package cards
+
+import (
+	"cmp"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	lib "github.com/uhppoted/uhppote-core/types"
+
+	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+	"github.com/uhppoted/uhppoted-httpd/system/db"
+)
+
+// Record is a single row in a cards CSV file. The PIN, From and To fields are nil if the
+// corresponding cell is blank, in which case the card field is left unchanged on import.
+type Record struct {
+	Name   string
+	Card   uint32
+	PIN    *uint32
+	From   *lib.Date
+	To     *lib.Date
+	Groups map[schema.OID]bool
+}
+
+// Change is a single difference between an imported cards CSV file and the cards list, i.e. a
+// card to be added, updated or deleted.
+type Change struct {
+	Action string     `json:"action"`
+	OID    schema.OID `json:"OID,omitempty"`
+	Card   uint32     `json:"card"`
+	Name   string     `json:"name"`
+	Fields []Field    `json:"fields,omitempty"`
+}
+
+// Field is a single updated card field. The PIN is masked in the original and updated values
+// so that a preview doesn't disclose it.
+type Field struct {
+	Field    string `json:"field"`
+	Original string `json:"original"`
+	Value    string `json:"value"`
+
+	suffix schema.Suffix
+	value  string
+}
+
+const (
+	ChangeAdd    = "add"
+	ChangeUpdate = "update"
+	ChangeDelete = "delete"
+)
+
+// The fixed CSV columns. Any additional columns are group memberships with the group name as the
+// column header.
+var header = []string{"Name", "Card Number", "PIN", "From", "To"}
+
+// Writes the cards list as CSV with a column for each group in the user's scope. Deleted cards and
+// cards that are not viewable by the user are not exported and fields that are not viewable are
+// left blank. Values that a spreadsheet would interpret as a formula are prefixed with a quote.
+func (cc *Cards) ToCSV(a *auth.Authorizator, w io.Writer) error {
+	guard.RLock()
+	defer guard.RUnlock()
+
+	type group struct {
+		oid  schema.OID
+		name string
+	}
+
+	scope := auth.ScopeOf(a)
+	groups := []group{}
+	for _, oid := range catalog.GetGroups() {
+		if scope.HasGroup(string(oid)) {
+			groups = append(groups, group{oid, fmt.Sprintf("%v", catalog.GetV(oid, GroupName))})
+		}
+	}
+
+	slices.SortFunc(groups, func(p, q group) int {
+		return p.oid.Compare(q.oid)
+	})
+
+	list := []*Card{}
+	for _, c := range cc.cards {
+		if c != nil && !c.IsDeleted() && CanView(a, c, "OID", c.OID) == nil {
+			list = append(list, c)
+		}
+	}
+
+	slices.SortFunc(list, func(p, q *Card) int {
+		if v := cmp.Compare(p.CardID, q.CardID); v != 0 {
+			return v
+		}
+
+		return cmp.Compare(p.name, q.name)
+	})
+
+	out := csv.NewWriter(w)
+	columns := slices.Clone(header)
+	for _, g := range groups {
+		columns = append(columns, escape(g.name))
+	}
+
+	if err := out.Write(columns); err != nil {
+		return err
+	}
+
+	for _, c := range list {
+		field := func(name string, value any) string {
+			if CanView(a, c, name, value) != nil {
+				return ""
+			}
+
+			return escape(fmt.Sprintf("%v", value))
+		}
+
+		row := []string{
+			field("name", c.name),
+			field("number", c.CardID),
+			field("PIN", c.pin),
+			field("from", c.from),
+			field("to", c.to),
+		}
+
+		for _, g := range groups {
+			switch {
+			case CanView(a, c, "group", c.groups[g.oid]) != nil:
+				row = append(row, "")
+			case c.groups[g.oid]:
+				row = append(row, "Y")
+			default:
+				row = append(row, "N")
+			}
+		}
+
+		if err := out.Write(row); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+
+	return out.Error()
+}
+
+// Parses a cards CSV file in the format written by ToCSV. Group columns are matched (case
+// insensitively) to the group names and only the groups included in the file are updated
+// on import. Blank PIN, From and To cells are returned as nil i.e. 'unchanged'.
+func ParseCSV(r io.Reader) ([]Record, error) {
+	in := csv.NewReader(r)
+	in.TrimLeadingSpace = true
+
+	rows, err := in.ReadAll()
+	if err != nil {
+		return nil, err
+	} else if len(rows) == 0 {
+		return nil, fmt.Errorf("missing CSV header")
+	}
+
+	columns := rows[0]
+	if len(columns) < len(header) {
+		return nil, fmt.Errorf("invalid CSV header - expected %v", strings.Join(header, ","))
+	}
+
+	for i, h := range header {
+		if !strings.EqualFold(strings.TrimSpace(columns[i]), h) {
+			return nil, fmt.Errorf("invalid CSV header - expected '%v', got '%v'", h, columns[i])
+		}
+	}
+
+	groups := []schema.OID{}
+loop:
+	for _, column := range columns[len(header):] {
+		for _, oid := range catalog.GetGroups() {
+			if name := fmt.Sprintf("%v", catalog.GetV(oid, GroupName)); strings.EqualFold(name, unescape(strings.TrimSpace(column))) {
+				groups = append(groups, oid)
+				continue loop
+			}
+		}
+
+		return nil, fmt.Errorf("unknown group '%v'", column)
+	}
+
+	records := []Record{}
+	cards := map[uint32]bool{}
+
+	for i, row := range rows[1:] {
+		line := i + 2
+		record := Record{
+			Name:   unescape(strings.TrimSpace(row[0])),
+			Groups: map[schema.OID]bool{},
+		}
+
+		if card, err := strconv.ParseUint(strings.TrimSpace(row[1]), 10, 32); err != nil || card == 0 {
+			return nil, fmt.Errorf("line %v: invalid card number '%v'", line, row[1])
+		} else if cards[uint32(card)] {
+			return nil, fmt.Errorf("line %v: duplicate card number %v", line, card)
+		} else {
+			record.Card = uint32(card)
+			cards[uint32(card)] = true
+		}
+
+		if s := strings.TrimSpace(row[2]); s != "" {
+			if pin, err := strconv.ParseUint(s, 10, 32); err != nil || pin > 999999 {
+				return nil, fmt.Errorf("line %v: invalid PIN - valid range is [0..999999]", line)
+			} else {
+				v := uint32(pin)
+				record.PIN = &v
+			}
+		}
+
+		if s := strings.TrimSpace(row[3]); s != "" {
+			if from, err := lib.ParseDate(s); err != nil {
+				return nil, fmt.Errorf("line %v: invalid 'from' date '%v'", line, row[3])
+			} else {
+				record.From = &from
+			}
+		}
+
+		if s := strings.TrimSpace(row[4]); s != "" {
+			if to, err := lib.ParseDate(s); err != nil {
+				return nil, fmt.Errorf("line %v: invalid 'to' date '%v'", line, row[4])
+			} else {
+				record.To = &to
+			}
+		}
+
+		for j, oid := range groups {
+			if member, err := parseMember(row[len(header)+j]); err != nil {
+				return nil, fmt.Errorf("line %v: %v", line, err)
+			} else {
+				record.Groups[oid] = member
+			}
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// Compares the imported records with the cards list and returns the list of cards to be added,
+// updated and deleted. Records are matched to cards by card number - cards without a card number
+// and unconfigured cards are left as is. The diff is restricted to the changes the user could
+// make interactively, i.e. records for cards that are not in scope are ignored, fields are only
+// updated if the user can update them, group memberships are only changed for the groups in the
+// user's scope and cards are only deleted if the user can delete them.
+// Cards that are not in the imported records are only deleted if deleteMissing is true, so that
+// e.g. a list of new cards can be imported without deleting the existing cards.
+func (cc *Cards) Diff(a *auth.Authorizator, records []Record, deleteMissing bool) []Change {
+	guard.RLock()
+	defer guard.RUnlock()
+
+	scope := auth.ScopeOf(a)
+	changes := []Change{}
+	imported := map[uint32]bool{}
+
+	for _, r := range records {
+		imported[r.Card] = true
+
+		var card *Card
+		for _, c := range cc.cards {
+			if c != nil && !c.IsDeleted() && c.CardID == r.Card {
+				card = c
+				break
+			}
+		}
+
+		if card != nil && CanView(a, card, "OID", card.OID) != nil {
+			continue
+		}
+
+		if card == nil {
+			change := Change{
+				Action: ChangeAdd,
+				Card:   r.Card,
+				Name:   r.Name,
+				Fields: []Field{
+					field("name", CardName, "", r.Name),
+					field("number", CardNumber, "", fmt.Sprintf("%v", r.Card)),
+				},
+			}
+
+			if r.PIN != nil && *r.PIN != 0 {
+				change.Fields = append(change.Fields, field("PIN", CardPIN, "", fmt.Sprintf("%v", *r.PIN)))
+			}
+
+			if r.From != nil && !r.From.IsZero() {
+				change.Fields = append(change.Fields, field("from", CardFrom, "", fmt.Sprintf("%v", *r.From)))
+			}
+
+			if r.To != nil && !r.To.IsZero() {
+				change.Fields = append(change.Fields, field("to", CardTo, "", fmt.Sprintf("%v", *r.To)))
+			}
+
+			for _, oid := range sorted(r.Groups) {
+				if r.Groups[oid] && scope.HasGroup(string(oid)) {
+					change.Fields = append(change.Fields, group(oid, false, true))
+				}
+			}
+
+			changes = append(changes, change)
+			continue
+		}
+
+		fields := []Field{}
+
+		if card.name != r.Name && CanUpdate(a, card, "name", r.Name) == nil {
+			fields = append(fields, field("name", CardName, card.name, r.Name))
+		}
+
+		if r.PIN != nil && card.pin != *r.PIN && CanUpdate(a, card, "PIN", uint64(*r.PIN)) == nil {
+			fields = append(fields, field("PIN", CardPIN, fmt.Sprintf("%v", card.pin), fmt.Sprintf("%v", *r.PIN)))
+		}
+
+		if r.From != nil && !card.from.Equals(*r.From) {
+			if from := fmt.Sprintf("%v", *r.From); CanUpdate(a, card, "from", from) == nil {
+				fields = append(fields, field("from", CardFrom, fmt.Sprintf("%v", card.from), from))
+			}
+		}
+
+		if r.To != nil && !card.to.Equals(*r.To) {
+			if to := fmt.Sprintf("%v", *r.To); CanUpdate(a, card, "to", to) == nil {
+				fields = append(fields, field("to", CardTo, fmt.Sprintf("%v", card.to), to))
+			}
+		}
+
+		for _, oid := range sorted(r.Groups) {
+			member := fmt.Sprintf("%v", r.Groups[oid])
+			if card.groups[oid] != r.Groups[oid] && CanUpdate(a, card, "group", member) == nil && scope.HasGroup(string(oid)) {
+				fields = append(fields, group(oid, card.groups[oid], r.Groups[oid]))
+			}
+		}
+
+		if len(fields) > 0 {
+			changes = append(changes, Change{
+				Action: ChangeUpdate,
+				OID:    card.OID,
+				Card:   r.Card,
+				Name:   r.Name,
+				Fields: fields,
+			})
+		}
+	}
+
+	if !deleteMissing {
+		return changes
+	}
+
+	deleted := []Change{}
+	for _, c := range cc.cards {
+		if c != nil && !c.IsDeleted() && !c.unconfigured && c.CardID != 0 && !imported[c.CardID] && CanDelete(a, c) == nil {
+			deleted = append(deleted, Change{
+				Action: ChangeDelete,
+				OID:    c.OID,
+				Card:   c.CardID,
+				Name:   c.name,
+			})
+		}
+	}
+
+	slices.SortFunc(deleted, func(p, q Change) int {
+		return cmp.Compare(p.Card, q.Card)
+	})
+
+	return append(changes, deleted...)
+}
+
+// Applies the changes returned by Diff using the same Create, Update and Delete operations as an
+// interactive edit so that the changes are authorised and logged in the audit trail.
+func (cc *Cards) Import(a *auth.Authorizator, changes []Change, dbc db.DBC) ([]schema.Object, error) {
+	objects := []schema.Object{}
+
+	for _, change := range changes {
+		oid := change.OID
+
+		switch change.Action {
+		case ChangeAdd:
+			if list, err := cc.Create(a, "", "", dbc); err != nil {
+				return nil, err
+			} else {
+				for _, o := range list {
+					if o.Value == "new" {
+						oid = o.OID
+					}
+				}
+
+				catalog.Join(&objects, list...)
+			}
+
+			if oid == "" {
+				return nil, fmt.Errorf("failed to add card %v", change.Card)
+			}
+
+			fallthrough
+
+		case ChangeUpdate:
+			for _, f := range change.Fields {
+				if list, err := cc.Update(a, oid.Append(f.suffix), f.value, dbc); err != nil {
+					return nil, err
+				} else {
+					catalog.Join(&objects, list...)
+				}
+			}
+
+		case ChangeDelete:
+			if list, err := cc.Delete(a, oid, dbc); err != nil {
+				return nil, err
+			} else {
+				catalog.Join(&objects, list...)
+			}
+
+		default:
+			return nil, fmt.Errorf("invalid import action '%v'", change.Action)
+		}
+	}
+
+	return objects, nil
+}
+
+func field(name string, suffix schema.Suffix, original, value string) Field {
+	f := Field{
+		Field:    name,
+		Original: original,
+		Value:    value,
+		suffix:   suffix,
+		value:    value,
+	}
+
+	if suffix == CardPIN {
+		f.Original = mask(original)
+		f.Value = mask(value)
+	}
+
+	return f
+}
+
+func group(oid schema.OID, original, value bool) Field {
+	gid := regexp.MustCompile(`^(?:.*?)\.([0-9]+)$`).FindStringSubmatch(string(oid))[1]
+
+	return Field{
+		Field:    fmt.Sprintf("group:%v", catalog.GetV(oid, GroupName)),
+		Original: fmt.Sprintf("%v", original),
+		Value:    fmt.Sprintf("%v", value),
+		suffix:   CardGroups.Append(gid),
+		value:    fmt.Sprintf("%v", value),
+	}
+}
+
+func mask(pin string) string {
+	if pin == "" || pin == "0" {
+		return ""
+	}
+
+	return "----"
+}
+
+func sorted(groups map[schema.OID]bool) []schema.OID {
+	list := []schema.OID{}
+	for oid := range groups {
+		list = append(list, oid)
+	}
+
+	slices.SortFunc(list, schema.OID.Compare)
+
+	return list
+}
+
+// Prefixes values that a spreadsheet would interpret as a formula with a quote (CSV injection).
+func escape(s string) string {
+	if s != "" && strings.ContainsRune("=+-@", rune(s[0])) {
+		return "'" + s
+	}
+
+	return s
+}
+
+// Removes the quote added by escape from an imported value.
+func unescape(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.ContainsRune("=+-@", rune(s[1])) {
+		return s[1:]
+	}
+
+	return s
+}
+
+func parseMember(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "y", "yes", "true", "1", "x":
+		return true, nil
+
+	case "", "n", "no", "false", "0":
+		return false, nil
+	}
+
+	return false, fmt.Errorf("invalid group membership '%v'", s)
+}
@@ -0,0 +1,409 @@
+package cards
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	core "github.com/uhppoted/uhppote-core/types"
+
+	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog"
+	memdb "github.com/uhppoted/uhppoted-httpd/system/catalog/impl"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+	"github.com/uhppoted/uhppoted-httpd/system/db"
+)
+
+func TestCardsToCSV(t *testing.T) {
+	initCSVCatalog()
+
+	expected := `Name,Card Number,PIN,From,To,Teachers,Students
+Dobby,1234567,0,2021-01-02,2021-12-30,N,Y
+Hagrid,6514231,7531,2021-01-02,2021-12-30,Y,N
+`
+	cards := makeCards(hagrid, dobby)
+	cards.cards["0.4.1"].pin = 7531
+	cards.cards["0.4.1"].groups["0.5.1"] = true
+	cards.cards["0.4.2"].groups["0.5.2"] = true
+
+	var b bytes.Buffer
+	if err := cards.ToCSV(nil, &b); err != nil {
+		t.Fatalf("Unexpected error exporting cards (%v)", err)
+	}
+
+	if b.String() != expected {
+		t.Errorf("Incorrect CSV\n   expected:%v\n   got:     %v", expected, b.String())
+	}
+}
+
+func TestCardsToCSVWithScope(t *testing.T) {
+	initCSVCatalog()
+
+	if err := auth.Init(nil, "admin"); err != nil {
+		t.Fatalf("Error initialising auth (%v)", err)
+	}
+
+	auth.SetScopes(func(uid string) *auth.Scope {
+		return &auth.Scope{Groups: []string{"0.5.1"}}
+	})
+
+	defer auth.SetScopes(nil)
+
+	expected := `Name,Card Number,PIN,From,To,Teachers
+Harry,7654321,0,2021-01-02,2021-12-30,Y
+`
+	harry := makeCard("0.4.3", "Harry", 7654321, "0.5.1", "0.5.2")
+	cards := makeCards(dobby, harry)
+
+	var b bytes.Buffer
+	if err := cards.ToCSV(auth.NewAuthorizator("moony", "admin"), &b); err != nil {
+		t.Fatalf("Unexpected error exporting cards (%v)", err)
+	}
+
+	if b.String() != expected {
+		t.Errorf("Incorrect CSV\n   expected:%v\n   got:     %v", expected, b.String())
+	}
+}
+
+func TestCardsToCSVWithFormulas(t *testing.T) {
+	initCSVCatalog()
+
+	catalog.PutV("0.5.2", GroupName, "@Students")
+
+	expected := `Name,Card Number,PIN,From,To,Teachers,'@Students
+"'=HYPERLINK(""http://example.com"")",6514231,0,2021-01-02,2021-12-30,N,N
+`
+	cards := makeCards(makeCard("0.4.1", `=HYPERLINK("http://example.com")`, 6514231))
+
+	var b bytes.Buffer
+	if err := cards.ToCSV(nil, &b); err != nil {
+		t.Fatalf("Unexpected error exporting cards (%v)", err)
+	}
+
+	if b.String() != expected {
+		t.Errorf("Incorrect CSV\n   expected:%v\n   got:     %v", expected, b.String())
+	}
+
+	if records, err := ParseCSV(strings.NewReader(b.String())); err != nil {
+		t.Fatalf("Unexpected error parsing CSV (%v)", err)
+	} else if len(records) != 1 || records[0].Name != `=HYPERLINK("http://example.com")` {
+		t.Errorf("Incorrectly parsed CSV - got %+v", records)
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	initCSVCatalog()
+
+	csv := `name,card number,PIN,from,to,students
+Hagrid, 6514231,7531,2021-01-02,2021-12-30,Y
+Dobby,1234567,,,,
+`
+	expected := []Record{
+		{
+			Name:   "Hagrid",
+			Card:   6514231,
+			PIN:    pin(7531),
+			From:   datep("2021-01-02"),
+			To:     datep("2021-12-30"),
+			Groups: map[schema.OID]bool{"0.5.2": true},
+		},
+		{
+			Name:   "Dobby",
+			Card:   1234567,
+			Groups: map[schema.OID]bool{"0.5.2": false},
+		},
+	}
+
+	records, err := ParseCSV(strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing CSV (%v)", err)
+	}
+
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("Incorrectly parsed CSV\n   expected:%v\n   got:     %v", expected, records)
+	}
+}
+
+func TestParseCSVWithInvalidData(t *testing.T) {
+	initCSVCatalog()
+
+	tests := map[string]string{
+		"header":    "Name,Card,PIN,From,To\n",
+		"group":     "Name,Card Number,PIN,From,To,Prefects\n",
+		"card":      "Name,Card Number,PIN,From,To\nHagrid,qwerty,,,\n",
+		"duplicate": "Name,Card Number,PIN,From,To\nHagrid,6514231,,,\nDobby,6514231,,,\n",
+		"PIN":       "Name,Card Number,PIN,From,To\nHagrid,6514231,1234567,,\n",
+		"date":      "Name,Card Number,PIN,From,To\nHagrid,6514231,,2021-02-30,\n",
+		"member":    "Name,Card Number,PIN,From,To,Teachers\nHagrid,6514231,,,,maybe\n",
+	}
+
+	for k, csv := range tests {
+		if _, err := ParseCSV(strings.NewReader(csv)); err == nil {
+			t.Errorf("Expected error parsing CSV with invalid %v", k)
+		}
+	}
+}
+
+func TestCardsDiff(t *testing.T) {
+	initCSVCatalog()
+
+	cards := makeCards(hagrid, dobby)
+	records := []Record{
+		{
+			Name:   "Rubeus Hagrid",
+			Card:   6514231,
+			From:   datep("2021-01-02"),
+			To:     datep("2021-12-30"),
+			Groups: map[schema.OID]bool{"0.5.1": true},
+		},
+		{
+			Name:   "Hermione",
+			Card:   8165538,
+			PIN:    pin(1234),
+			Groups: map[schema.OID]bool{"0.5.1": false, "0.5.2": true},
+		},
+	}
+
+	expected := []Change{
+		{
+			Action: ChangeUpdate,
+			OID:    "0.4.1",
+			Card:   6514231,
+			Name:   "Rubeus Hagrid",
+			Fields: []Field{
+				{Field: "name", Original: "Hagrid", Value: "Rubeus Hagrid", suffix: CardName, value: "Rubeus Hagrid"},
+				{Field: "group:Teachers", Original: "false", Value: "true", suffix: CardGroups.Append("1"), value: "true"},
+			},
+		},
+		{
+			Action: ChangeAdd,
+			Card:   8165538,
+			Name:   "Hermione",
+			Fields: []Field{
+				{Field: "name", Original: "", Value: "Hermione", suffix: CardName, value: "Hermione"},
+				{Field: "number", Original: "", Value: "8165538", suffix: CardNumber, value: "8165538"},
+				{Field: "PIN", Original: "", Value: "----", suffix: CardPIN, value: "1234"},
+				{Field: "group:Students", Original: "false", Value: "true", suffix: CardGroups.Append("2"), value: "true"},
+			},
+		},
+		{
+			Action: ChangeDelete,
+			OID:    "0.4.2",
+			Card:   1234567,
+			Name:   "Dobby",
+		},
+	}
+
+	changes := cards.Diff(nil, records, true)
+
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Incorrect diff\n   expected:%+v\n   got:     %+v", expected, changes)
+	}
+}
+
+func TestCardsDiffWithoutDeleteMissing(t *testing.T) {
+	initCSVCatalog()
+
+	cards := makeCards(hagrid, dobby)
+	records := []Record{
+		{Name: "Hermione", Card: 8165538, Groups: map[schema.OID]bool{"0.5.2": true}},
+	}
+
+	expected := []Change{
+		{
+			Action: ChangeAdd,
+			Card:   8165538,
+			Name:   "Hermione",
+			Fields: []Field{
+				{Field: "name", Original: "", Value: "Hermione", suffix: CardName, value: "Hermione"},
+				{Field: "number", Original: "", Value: "8165538", suffix: CardNumber, value: "8165538"},
+				{Field: "group:Students", Original: "false", Value: "true", suffix: CardGroups.Append("2"), value: "true"},
+			},
+		},
+	}
+
+	changes := cards.Diff(nil, records, false)
+
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Incorrect diff\n   expected:%+v\n   got:     %+v", expected, changes)
+	}
+}
+
+func TestCardsDiffWithScope(t *testing.T) {
+	initCSVCatalog()
+
+	if err := auth.Init(nil, "admin"); err != nil {
+		t.Fatalf("Error initialising auth (%v)", err)
+	}
+
+	auth.SetScopes(func(uid string) *auth.Scope {
+		return &auth.Scope{Groups: []string{"0.5.1"}}
+	})
+
+	defer auth.SetScopes(nil)
+
+	harry := makeCard("0.4.3", "Harry", 7654321, "0.5.1")
+	cards := makeCards(hagrid, dobby, harry)
+	records := []Record{
+		{Name: "Hagrid", Card: 6514231, Groups: map[schema.OID]bool{"0.5.1": true}},
+		{Name: "Dobby", Card: 1234567, Groups: map[schema.OID]bool{"0.5.1": true}},
+	}
+
+	expected := []Change{
+		{
+			Action: ChangeDelete,
+			OID:    "0.4.3",
+			Card:   7654321,
+			Name:   "Harry",
+		},
+	}
+
+	changes := cards.Diff(auth.NewAuthorizator("moony", "admin"), records, true)
+
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Incorrect scoped diff\n   expected:%+v\n   got:     %+v", expected, changes)
+	}
+}
+
+func TestCardsDiffWithPartialScope(t *testing.T) {
+	initCSVCatalog()
+
+	if err := auth.Init(nil, "admin"); err != nil {
+		t.Fatalf("Error initialising auth (%v)", err)
+	}
+
+	auth.SetScopes(func(uid string) *auth.Scope {
+		return &auth.Scope{Groups: []string{"0.5.1"}}
+	})
+
+	defer auth.SetScopes(nil)
+
+	harry := makeCard("0.4.3", "Harry", 7654321, "0.5.1", "0.5.2")
+	ron := makeCard("0.4.4", "Ron", 7654322, "0.5.1", "0.5.2")
+	cards := makeCards(harry, ron)
+	records := []Record{
+		{Name: "Harry Potter", Card: 7654321, Groups: map[schema.OID]bool{"0.5.1": false}},
+	}
+
+	expected := []Change{
+		{
+			Action: ChangeUpdate,
+			OID:    "0.4.3",
+			Card:   7654321,
+			Name:   "Harry Potter",
+			Fields: []Field{
+				{Field: "group:Teachers", Original: "true", Value: "false", suffix: CardGroups.Append("1"), value: "false"},
+			},
+		},
+	}
+
+	changes := cards.Diff(auth.NewAuthorizator("moony", "admin"), records, true)
+
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Incorrect partially scoped diff\n   expected:%+v\n   got:     %+v", expected, changes)
+	}
+}
+
+func TestCardsDiffWithBlankFields(t *testing.T) {
+	initCSVCatalog()
+
+	cards := makeCards(hagrid)
+	cards.cards["0.4.1"].pin = 7531
+
+	records := []Record{
+		{Name: "Hagrid", Card: 6514231},
+	}
+
+	if changes := cards.Diff(nil, records, false); len(changes) != 0 {
+		t.Errorf("Incorrect diff\n   expected:%+v\n   got:     %+v", []Change{}, changes)
+	}
+}
+
+func TestCardsDiffAddWithScope(t *testing.T) {
+	initCSVCatalog()
+
+	if err := auth.Init(nil, "admin"); err != nil {
+		t.Fatalf("Error initialising auth (%v)", err)
+	}
+
+	auth.SetScopes(func(uid string) *auth.Scope {
+		return &auth.Scope{Groups: []string{"0.5.1"}}
+	})
+
+	defer auth.SetScopes(nil)
+
+	cards := makeCards()
+	records := []Record{
+		{Name: "Hermione", Card: 8165538, Groups: map[schema.OID]bool{"0.5.1": true, "0.5.2": true}},
+	}
+
+	expected := []Change{
+		{
+			Action: ChangeAdd,
+			Card:   8165538,
+			Name:   "Hermione",
+			Fields: []Field{
+				{Field: "name", Original: "", Value: "Hermione", suffix: CardName, value: "Hermione"},
+				{Field: "number", Original: "", Value: "8165538", suffix: CardNumber, value: "8165538"},
+				{Field: "group:Teachers", Original: "false", Value: "true", suffix: CardGroups.Append("1"), value: "true"},
+			},
+		},
+	}
+
+	changes := cards.Diff(auth.NewAuthorizator("moony", "admin"), records, false)
+
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Incorrect scoped diff\n   expected:%+v\n   got:     %+v", expected, changes)
+	}
+}
+
+func TestCardsImport(t *testing.T) {
+	initCSVCatalog()
+
+	catalog.PutT(hagrid.CatalogCard)
+	catalog.PutT(dobby.CatalogCard)
+
+	cards := makeCards(hagrid, dobby)
+	records := []Record{
+		{Name: "Rubeus Hagrid", Card: 6514231, From: datep("2021-01-02"), To: datep("2021-12-30")},
+		{Name: "Hermione", Card: 8165538, Groups: map[schema.OID]bool{"0.5.2": true}},
+	}
+
+	if _, err := cards.Import(nil, cards.Diff(nil, records, true), db.DBC{}); err != nil {
+		t.Fatalf("Unexpected error importing cards (%v)", err)
+	}
+
+	if c := cards.cards["0.4.1"]; c.name != "Rubeus Hagrid" {
+		t.Errorf("Card not updated - expected name '%v', got '%v'", "Rubeus Hagrid", c.name)
+	}
+
+	if c := cards.cards["0.4.2"]; !c.IsDeleted() {
+		t.Errorf("Card %v not deleted", c.CardID)
+	}
+
+	if c := cards.cards["0.4.3"]; c == nil {
+		t.Errorf("Card not added")
+	} else if c.name != "Hermione" || c.CardID != 8165538 || !c.groups["0.5.2"] {
+		t.Errorf("Incorrectly added card - got %v %v %v", c.name, c.CardID, c.groups)
+	}
+}
+
+func initCSVCatalog() {
+	catalog.Init(memdb.NewCatalog())
+
+	catalog.PutT(catalog.CatalogGroup{OID: "0.5.1"})
+	catalog.PutT(catalog.CatalogGroup{OID: "0.5.2"})
+	catalog.PutV("0.5.1", GroupName, "Teachers")
+	catalog.PutV("0.5.2", GroupName, "Students")
+}
+
+func pin(v uint32) *uint32 {
+	return &v
+}
+
+func datep(s string) *core.Date {
+	d := date(s)
+
+	return &d
+}
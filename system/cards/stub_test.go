@@ -56,6 +56,10 @@ func (x *stub) CanCache(object auth.Operant, field string, cache string, ruleset
 	return nil
 }
 
+func (x *stub) CanAction(object auth.Operant, action string, rulesets ...auth.RuleSet) error {
+	return nil
+}
+
 func (x *stub) Write(e audit.AuditRecord) {
 	x.write(e)
 }
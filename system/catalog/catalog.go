@@ -30,7 +30,9 @@ type CatalogType interface {
 		CatalogGroup |
 		CatalogEvent |
 		CatalogLogEntry |
-		CatalogUser
+		CatalogUser |
+		CatalogTimeProfile |
+		CatalogTask
 
 	oid() schema.OID
 }
@@ -60,17 +62,23 @@ func NewObject2(oid schema.OID, suffix schema.Suffix, value any) schema.Object {
 }
 
 func NewT[T CatalogType](v T) schema.OID {
-	return catalog.NewT(v)
+	oid := catalog.NewT(v)
+
+	changed(oid)
+
+	return oid
 }
 
 func PutT[T CatalogType](v T) {
 	oid := v.oid()
 
 	catalog.PutT(v, oid)
+	changed(oid)
 }
 
 func DeleteT[T CatalogType](v T, oid schema.OID) {
 	catalog.DeleteT(v, oid)
+	changed(oid)
 }
 
 func GetV(oid schema.OID, suffix schema.Suffix) any {
@@ -99,10 +107,12 @@ func GetUint8(oid schema.OID, suffix schema.Suffix) (uint8, bool) {
 
 func Put(oid schema.OID, v any) {
 	catalog.Put(oid, v)
+	changed(oid)
 }
 
 func PutV(oid schema.OID, suffix schema.Suffix, v any) {
 	catalog.PutV(oid, suffix, v)
+	changed(oid)
 }
 
 func Find(prefix schema.OID, suffix schema.Suffix, value any) (schema.OID, bool) {
@@ -113,6 +123,27 @@ func FindController(deviceID uint32) schema.OID {
 	return catalog.FindController(CatalogController{DeviceID: deviceID})
 }
 
+// Returns the OID of the door assigned to a controller door, or "" if the controller is not
+// configured or the door is not assigned.
+func FindDoor(deviceID uint32, door uint8) schema.OID {
+	suffixes := map[uint8]schema.Suffix{
+		1: schema.ControllerDoor1,
+		2: schema.ControllerDoor2,
+		3: schema.ControllerDoor3,
+		4: schema.ControllerDoor4,
+	}
+
+	if suffix, ok := suffixes[door]; ok && deviceID != 0 {
+		if controller := FindController(deviceID); controller != "" {
+			if oid, ok := GetV(controller, suffix).(schema.OID); ok {
+				return oid
+			}
+		}
+	}
+
+	return ""
+}
+
 func GetDoors() []schema.OID {
 	return catalog.ListT(schema.DoorsOID)
 }
@@ -136,3 +167,15 @@ func HasGroup(oid schema.OID) bool {
 
 	return catalog.HasT(group{}.CatalogGroup, oid)
 }
+
+func GetTimeProfiles() []schema.OID {
+	return catalog.ListT(schema.TimeProfilesOID)
+}
+
+func HasTimeProfile(oid schema.OID) bool {
+	type profile struct {
+		CatalogTimeProfile
+	}
+
+	return catalog.HasT(profile{}.CatalogTimeProfile, oid)
+}
@@ -17,6 +17,8 @@ type db struct {
 	events      Table
 	logs        Table
 	users       Table
+	profiles    Table
+	tasks       Table
 	sync.RWMutex
 }
 
@@ -55,6 +57,14 @@ func NewCatalog() *db {
 			base: schema.UsersOID,
 			m:    map[schema.OID]*record{},
 		},
+		profiles: &table{
+			base: schema.TimeProfilesOID,
+			m:    map[schema.OID]*record{},
+		},
+		tasks: &table{
+			base: schema.TasksOID,
+			m:    map[schema.OID]*record{},
+		},
 	}
 }
 
@@ -96,7 +106,7 @@ func (cc *db) DeleteT(v any, oid schema.OID) {
 	cc.Lock()
 	defer cc.Unlock()
 
-	tt.Delete(oid)
+	tt.Delete(v, oid)
 }
 
 func (cc *db) ListT(oid schema.OID) []schema.OID {
@@ -167,6 +177,12 @@ func tableForT(cc *db, v any) Table {
 
 		case catalog.TUser:
 			return cc.users
+
+		case catalog.TTimeProfile:
+			return cc.profiles
+
+		case catalog.TTask:
+			return cc.tasks
 		}
 	}
 
@@ -199,6 +215,12 @@ func tableFor(cc *db, oid schema.OID) Table {
 	case schema.UsersOID:
 		return cc.users
 
+	case schema.TimeProfilesOID:
+		return cc.profiles
+
+	case schema.TasksOID:
+		return cc.tasks
+
 	default:
 		return nil
 	}
@@ -95,7 +95,7 @@ func TestControllerDelete(t *testing.T) {
 		last: 123,
 	}
 
-	tt.Delete("0.2.2")
+	tt.Delete(nil, "0.2.2")
 
 	if !reflect.DeepEqual(tt, expected) {
 		t.Errorf("'delete' failed\n   expected:%v\n   got:     %v", expected, tt)
@@ -77,7 +77,7 @@ func (t *controllers) Put(oid schema.OID, v any) {
 	}
 }
 
-func (t *controllers) Delete(oid schema.OID) {
+func (t *controllers) Delete(v any, oid schema.OID) {
 	if v, ok := t.m[oid]; ok {
 		v.deleted = true
 		t.m[oid] = v
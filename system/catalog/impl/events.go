@@ -106,13 +106,24 @@ func (t *events) List() []schema.OID {
 	return list
 }
 
-// FIXME Horrifically inefficient for large event lists but also never invoked
-//
-//	in the current system
-func (t *events) Delete(oid schema.OID) {
-	for _, v := range t.m {
-		if v.OID == oid {
-			v.deleted = true
+// Removes a (purged) event from the table. The event is looked up by controller and index
+// if 'v' is a CatalogEvent, falling back to a (horrifically inefficient) search by OID.
+func (t *events) Delete(v any, oid schema.OID) {
+	if u, ok := v.(catalog.CatalogEvent); ok {
+		key := eventKey{
+			deviceID: u.DeviceID,
+			index:    u.Index,
+		}
+
+		if e, ok := t.m[key]; ok && e.OID == oid {
+			delete(t.m, key)
+			return
+		}
+	}
+
+	for k, e := range t.m {
+		if e.OID == oid {
+			delete(t.m, k)
 		}
 	}
 }
@@ -91,18 +91,19 @@ func TestEventsDelete(t *testing.T) {
 			eventKey{405419896, 1}:  &event{OID: "0.6.1"},
 			eventKey{405419896, 2}:  &event{OID: "0.6.2"},
 			eventKey{405419896, 10}: &event{OID: "0.6.10"},
+			eventKey{303986753, 3}:  &event{OID: "0.6.11"},
 		},
 	}
 
 	expected := events{
 		m: map[eventKey]*event{
-			eventKey{405419896, 1}:  &event{OID: "0.6.1"},
-			eventKey{405419896, 2}:  &event{OID: "0.6.2", deleted: true},
-			eventKey{405419896, 10}: &event{OID: "0.6.10"},
+			eventKey{405419896, 1}: &event{OID: "0.6.1"},
 		},
 	}
 
-	tt.Delete("0.6.2")
+	tt.Delete(nil, "0.6.2")
+	tt.Delete(catalog.CatalogEvent{DeviceID: 405419896, Index: 10}, "0.6.10")
+	tt.Delete(catalog.CatalogEvent{DeviceID: 405419896, Index: 3}, "0.6.11")
 
 	if !reflect.DeepEqual(tt, expected) {
 		t.Errorf("'delete' failed\n   expected:%v\n   got:     %v", expected, tt)
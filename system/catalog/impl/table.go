@@ -12,7 +12,7 @@ import (
 type Table interface {
 	New(any) schema.OID
 	Put(schema.OID, any)
-	Delete(schema.OID)
+	Delete(v any, oid schema.OID)
 	List() []schema.OID
 	Has(v any, oid schema.OID) bool
 }
@@ -70,7 +70,7 @@ func (t *table) Put(oid schema.OID, v any) {
 	}
 }
 
-func (t *table) Delete(oid schema.OID) {
+func (t *table) Delete(v any, oid schema.OID) {
 	if v, ok := t.m[oid]; ok {
 		v.deleted = true
 	}
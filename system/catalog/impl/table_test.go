@@ -88,7 +88,7 @@ func TestTableDelete(t *testing.T) {
 		},
 	}
 
-	tt.Delete("0.3.2")
+	tt.Delete(nil, "0.3.2")
 
 	if !reflect.DeepEqual(tt, expected) {
 		t.Errorf("'delete' failed\n   expected:%v\n   got:     %v", expected, tt)
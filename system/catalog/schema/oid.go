@@ -1,9 +1,11 @@
 package schema
 
 import (
+	"cmp"
 	"encoding/json"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -22,11 +24,13 @@ func (oid OID) Trim(suffix Suffix) OID {
 	return OID(strings.TrimSuffix(string(oid), string(suffix)))
 }
 
+// Returns true if the OID is the prefix OID or is prefixed by the prefix OID. Matches whole
+// OID components only i.e. 0.10.1 is not prefixed by 0.1.
 func (oid OID) HasPrefix(o OID) bool {
 	p := fmt.Sprintf("%v", oid)
-	q := fmt.Sprintf("%v", o)
+	q := strings.TrimSuffix(fmt.Sprintf("%v", o), ".")
 
-	return strings.HasPrefix(p, q)
+	return p == q || strings.HasPrefix(p, q+".")
 }
 
 func (oid OID) HasSuffix(suffix Suffix) bool {
@@ -43,6 +47,23 @@ func (oid OID) Contains(o OID) bool {
 	return strings.HasPrefix(q, p+".")
 }
 
+// Orders OIDs by the numeric value of each component so that e.g. 0.5.2 sorts before 0.5.10.
+func (oid OID) Compare(o OID) int {
+	u := strings.Split(string(oid), ".")
+	v := strings.Split(string(o), ".")
+
+	for i := 0; i < len(u) && i < len(v); i++ {
+		m, _ := strconv.Atoi(u[i])
+		n, _ := strconv.Atoi(v[i])
+
+		if c := cmp.Compare(m, n); c != 0 {
+			return c
+		}
+	}
+
+	return cmp.Compare(len(u), len(v))
+}
+
 func (oid OID) MarshalJSON() ([]byte, error) {
 	return json.Marshal(string(oid))
 }
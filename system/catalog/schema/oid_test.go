@@ -46,6 +46,27 @@ func TestOIDAppendS(t *testing.T) {
 	}
 }
 
+func TestOIDHasPrefix(t *testing.T) {
+	tests := []struct {
+		oid      OID
+		prefix   OID
+		expected bool
+	}{
+		{oid: OID("0.1"), prefix: "0.1", expected: true},
+		{oid: OID("0.1.2.3"), prefix: "0.1", expected: true},
+		{oid: OID("0.1.2.3"), prefix: "0.1.", expected: true},
+		{oid: OID("0.10.2.3"), prefix: "0.1", expected: false},
+		{oid: OID("0.10.2.3"), prefix: "0.10", expected: true},
+		{oid: OID("0.2.3"), prefix: "0.1", expected: false},
+	}
+
+	for _, v := range tests {
+		if prefixed := v.oid.HasPrefix(v.prefix); prefixed != v.expected {
+			t.Errorf("Incorrect HasPrefix for %v, %v - expected:%v, got:%v", v.oid, v.prefix, v.expected, prefixed)
+		}
+	}
+}
+
 func TestOIDMarshalJSON(t *testing.T) {
 	oid := OID("0.1.2.3")
 	b, err := json.Marshal(oid)
@@ -1,15 +1,17 @@
 package schema
 
 type Schema struct {
-	System      System      `json:"system"`
-	Interfaces  Interfaces  `json:"interfaces"`
-	Controllers Controllers `json:"controllers"`
-	Doors       Doors       `json:"doors"`
-	Cards       Cards       `json:"cards"`
-	Groups      Groups      `json:"groups"`
-	Events      Events      `json:"events"`
-	Logs        Logs        `json:"logs"`
-	Users       Users       `json:"users"`
+	System       System       `json:"system"`
+	Interfaces   Interfaces   `json:"interfaces"`
+	Controllers  Controllers  `json:"controllers"`
+	Doors        Doors        `json:"doors"`
+	Cards        Cards        `json:"cards"`
+	Groups       Groups       `json:"groups"`
+	Events       Events       `json:"events"`
+	Logs         Logs         `json:"logs"`
+	Users        Users        `json:"users"`
+	TimeProfiles TimeProfiles `json:"time-profiles"`
+	Tasks        Tasks        `json:"tasks"`
 }
 
 type Metadata struct {
@@ -36,6 +38,9 @@ type Interfaces struct {
 	Bind      Suffix `json:"bind"`
 	Broadcast Suffix `json:"broadcast"`
 	Listen    Suffix `json:"listen"`
+	URL       Suffix `json:"url"`
+	Broker    Suffix `json:"broker"`
+	ClientID  Suffix `json:"client-id"`
 }
 
 type Controllers struct {
@@ -78,6 +83,7 @@ type Controllers struct {
 		Configured   Suffix `json:"configured"`
 		Modified     Suffix `json:"modified"`
 	} `json:"antipassback"`
+	Interface Suffix `json:"interface"`
 }
 
 type Doors struct {
@@ -100,6 +106,11 @@ type Doors struct {
 	} `json:"control"`
 	Keypad    Suffix `json:"keypad"`
 	Passcodes Suffix `json:"passcodes"`
+	State     struct {
+		Open     Suffix `json:"open"`
+		Button   Suffix `json:"button"`
+		Unlocked Suffix `json:"unlocked"`
+	} `json:"state"`
 }
 
 type Cards struct {
@@ -120,6 +131,34 @@ type Groups struct {
 	Doors Suffix `json:"doors"`
 }
 
+type TimeProfiles struct {
+	OID OID `json:"OID"`
+	Metadata
+	Name     Suffix `json:"name"`
+	ID       Suffix `json:"ID"`
+	From     Suffix `json:"from"`
+	To       Suffix `json:"to"`
+	Weekdays Suffix `json:"weekdays"`
+	Segments struct {
+		Segment1 Suffix `json:"1"`
+		Segment2 Suffix `json:"2"`
+		Segment3 Suffix `json:"3"`
+	} `json:"segments"`
+}
+
+type Tasks struct {
+	OID OID `json:"OID"`
+	Metadata
+	Name     Suffix `json:"name"`
+	Schedule Suffix `json:"schedule"`
+	Action   Suffix `json:"action"`
+	Door     Suffix `json:"door"`
+	Days     Suffix `json:"days"`
+	Enabled  Suffix `json:"enabled"`
+	LastRun  Suffix `json:"last-run"`
+	Result   Suffix `json:"result"`
+}
+
 type Events struct {
 	OID OID `json:"OID"`
 	Metadata
@@ -166,6 +205,15 @@ type Users struct {
 	OTP      Suffix `json:"otp"`
 	OTPKey   Suffix `json:"otpkey"`
 	Locked   Suffix `json:"locked"`
+	Passkeys Suffix `json:"passkeys"`
+	Certs    Suffix `json:"certificates"`
+	Scope    struct {
+		Scoped      Suffix `json:"scoped"`
+		Groups      Suffix `json:"groups"`
+		Doors       Suffix `json:"doors"`
+		Controllers Suffix `json:"controllers"`
+		Ungrouped   Suffix `json:"ungrouped"`
+	} `json:"scope"`
 }
 
 func GetSchema() Schema {
@@ -200,6 +248,9 @@ var schema = Schema{
 		Bind:      LANBindAddress,
 		Broadcast: LANBroadcastAddress,
 		Listen:    LANListenAddress,
+		URL:       RESTURL,
+		Broker:    MQTTBroker,
+		ClientID:  MQTTClientID,
 	},
 
 	Controllers: Controllers{
@@ -276,6 +327,7 @@ var schema = Schema{
 			Configured:   ControllerAntiPassbackConfigured,
 			Modified:     ControllerAntiPassbackModified,
 		},
+		Interface: ControllerInterface,
 	},
 
 	Doors: Doors{
@@ -316,6 +368,15 @@ var schema = Schema{
 		},
 		Keypad:    DoorKeypad,
 		Passcodes: DoorPasscodes,
+		State: struct {
+			Open     Suffix `json:"open"`
+			Button   Suffix `json:"button"`
+			Unlocked Suffix `json:"unlocked"`
+		}{
+			Open:     DoorStateOpen,
+			Button:   DoorStateButton,
+			Unlocked: DoorStateUnlocked,
+		},
 	},
 
 	Cards: Cards{
@@ -398,6 +459,65 @@ var schema = Schema{
 		OTP:      UserOTP,
 		OTPKey:   UserOTPKey,
 		Locked:   UserLocked,
+		Passkeys: UserPasskeys,
+		Certs:    UserCertificates,
+		Scope: struct {
+			Scoped      Suffix `json:"scoped"`
+			Groups      Suffix `json:"groups"`
+			Doors       Suffix `json:"doors"`
+			Controllers Suffix `json:"controllers"`
+			Ungrouped   Suffix `json:"ungrouped"`
+		}{
+			Scoped:      UserScope,
+			Groups:      UserScopeGroups,
+			Doors:       UserScopeDoors,
+			Controllers: UserScopeControllers,
+			Ungrouped:   UserScopeUngrouped,
+		},
+	},
+
+	TimeProfiles: TimeProfiles{
+		OID: TimeProfilesOID,
+		Metadata: Metadata{
+			Status:   Status,
+			Created:  Created,
+			Deleted:  Deleted,
+			Modified: Modified,
+			Type:     Type,
+		},
+		Name:     TimeProfileName,
+		ID:       TimeProfileID,
+		From:     TimeProfileFrom,
+		To:       TimeProfileTo,
+		Weekdays: TimeProfileWeekdays,
+		Segments: struct {
+			Segment1 Suffix `json:"1"`
+			Segment2 Suffix `json:"2"`
+			Segment3 Suffix `json:"3"`
+		}{
+			Segment1: TimeProfileSegment1,
+			Segment2: TimeProfileSegment2,
+			Segment3: TimeProfileSegment3,
+		},
+	},
+
+	Tasks: Tasks{
+		OID: TasksOID,
+		Metadata: Metadata{
+			Status:   Status,
+			Created:  Created,
+			Deleted:  Deleted,
+			Modified: Modified,
+			Type:     Type,
+		},
+		Name:     TaskName,
+		Schedule: TaskSchedule,
+		Action:   TaskAction,
+		Door:     TaskDoor,
+		Days:     TaskDays,
+		Enabled:  TaskEnabled,
+		LastRun:  TaskLastRun,
+		Result:   TaskResult,
 	},
 }
 
@@ -410,6 +530,8 @@ const GroupsOID OID = "0.5"
 const EventsOID OID = "0.6"
 const LogsOID OID = "0.7"
 const UsersOID OID = "0.8"
+const TimeProfilesOID OID = "0.9"
+const TasksOID OID = "0.10"
 
 const Status Suffix = ".0.0"
 const Created Suffix = ".0.1"
@@ -426,6 +548,9 @@ const InterfaceID Suffix = ".2"
 const LANBindAddress Suffix = ".3.1"
 const LANBroadcastAddress Suffix = ".3.2"
 const LANListenAddress Suffix = ".3.3"
+const RESTURL Suffix = ".4.1"
+const MQTTBroker Suffix = ".5.1"
+const MQTTClientID Suffix = ".5.2"
 
 const ControllerName Suffix = ".1"
 const ControllerDeviceID Suffix = ".2"
@@ -454,6 +579,7 @@ const ControllerAntiPassbackStatus Suffix = ".9.0"
 const ControllerAntiPassback Suffix = ".9.1"
 const ControllerAntiPassbackConfigured Suffix = ".9.2"
 const ControllerAntiPassbackModified Suffix = ".9.3"
+const ControllerInterface Suffix = ".10"
 
 const DoorName Suffix = ".1"
 const DoorDelay Suffix = ".2"
@@ -468,6 +594,10 @@ const DoorControlError Suffix = ".3.3"
 const DoorControlModified Suffix = ".3.4"
 const DoorKeypad Suffix = ".4"
 const DoorPasscodes Suffix = ".5"
+const DoorState Suffix = ".6"
+const DoorStateOpen Suffix = ".6.1"
+const DoorStateButton Suffix = ".6.2"
+const DoorStateUnlocked Suffix = ".6.3"
 
 const CardName Suffix = ".1"
 const CardNumber Suffix = ".2"
@@ -478,6 +608,7 @@ const CardPIN Suffix = ".6"
 
 const GroupName Suffix = ".1"
 const GroupDoors Suffix = ".2"
+const GroupDoorProfile Suffix = ".2"
 
 const EventsStatus Suffix = ".0.0"
 const EventsFirst Suffix = ".0.1"
@@ -514,3 +645,29 @@ const UserPassword Suffix = ".4"
 const UserOTP Suffix = ".5"
 const UserOTPKey Suffix = ".5.1"
 const UserLocked Suffix = ".6"
+const UserScope Suffix = ".7"
+const UserScopeGroups Suffix = ".7.1"
+const UserScopeDoors Suffix = ".7.2"
+const UserScopeControllers Suffix = ".7.3"
+const UserScopeUngrouped Suffix = ".7.4"
+const UserPasskeys Suffix = ".8"
+const UserCertificates Suffix = ".9"
+
+const TimeProfileName Suffix = ".1"
+const TimeProfileID Suffix = ".2"
+const TimeProfileFrom Suffix = ".3"
+const TimeProfileTo Suffix = ".4"
+const TimeProfileWeekdays Suffix = ".5"
+const TimeProfileSegments Suffix = ".6"
+const TimeProfileSegment1 Suffix = ".6.1"
+const TimeProfileSegment2 Suffix = ".6.2"
+const TimeProfileSegment3 Suffix = ".6.3"
+
+const TaskName Suffix = ".1"
+const TaskSchedule Suffix = ".2"
+const TaskAction Suffix = ".3"
+const TaskDoor Suffix = ".4"
+const TaskDays Suffix = ".5"
+const TaskEnabled Suffix = ".6"
+const TaskLastRun Suffix = ".7"
+const TaskResult Suffix = ".8"
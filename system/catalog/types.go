@@ -15,6 +15,8 @@ const (
 	TEvent
 	TLogEntry
 	TUser
+	TTimeProfile
+	TTask
 )
 
 func (t Type) String() string {
@@ -27,6 +29,8 @@ func (t Type) String() string {
 		"event",
 		"log entry",
 		"user",
+		"time profile",
+		"task",
 	}[t]
 }
 
@@ -133,3 +137,27 @@ func (t CatalogUser) TypeOf() Type {
 func (t CatalogUser) oid() schema.OID {
 	return t.OID
 }
+
+type CatalogTimeProfile struct {
+	OID schema.OID
+}
+
+func (t CatalogTimeProfile) TypeOf() Type {
+	return TTimeProfile
+}
+
+func (t CatalogTimeProfile) oid() schema.OID {
+	return t.OID
+}
+
+type CatalogTask struct {
+	OID schema.OID
+}
+
+func (t CatalogTask) TypeOf() Type {
+	return TTask
+}
+
+func (t CatalogTask) oid() schema.OID {
+	return t.OID
+}
@@ -0,0 +1,80 @@
+package catalog
+
+import (
+	"sync"
+
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+)
+
+// Watcher accumulates the OIDs of catalog entries that have been created, updated or deleted
+// since the last invocation of Changed. C is signalled (without blocking) whenever the list
+// of pending changes becomes non-empty.
+type Watcher struct {
+	C       chan struct{}
+	pending map[schema.OID]struct{}
+	sync.Mutex
+}
+
+var watchers = struct {
+	watchers map[*Watcher]struct{}
+	sync.RWMutex
+}{
+	watchers: map[*Watcher]struct{}{},
+}
+
+func Watch() *Watcher {
+	w := Watcher{
+		C:       make(chan struct{}, 1),
+		pending: map[schema.OID]struct{}{},
+	}
+
+	watchers.Lock()
+	defer watchers.Unlock()
+
+	watchers.watchers[&w] = struct{}{}
+
+	return &w
+}
+
+func (w *Watcher) Close() {
+	watchers.Lock()
+	defer watchers.Unlock()
+
+	delete(watchers.watchers, w)
+}
+
+// Returns (and clears) the list of OIDs changed since the previous invocation.
+func (w *Watcher) Changed() []schema.OID {
+	w.Lock()
+	defer w.Unlock()
+
+	list := make([]schema.OID, 0, len(w.pending))
+	for oid := range w.pending {
+		list = append(list, oid)
+	}
+
+	w.pending = map[schema.OID]struct{}{}
+
+	return list
+}
+
+func (w *Watcher) changed(oid schema.OID) {
+	w.Lock()
+	defer w.Unlock()
+
+	w.pending[oid] = struct{}{}
+
+	select {
+	case w.C <- struct{}{}:
+	default:
+	}
+}
+
+func changed(oid schema.OID) {
+	watchers.RLock()
+	defer watchers.RUnlock()
+
+	for w := range watchers.watchers {
+		w.changed(oid)
+	}
+}
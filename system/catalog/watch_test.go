@@ -0,0 +1,54 @@
+package catalog
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+)
+
+func TestWatch(t *testing.T) {
+	w := Watch()
+	defer w.Close()
+
+	expected := []schema.OID{"0.2.1", "0.4.3"}
+
+	changed("0.4.3")
+	changed("0.2.1")
+	changed("0.4.3")
+
+	select {
+	case <-w.C:
+	default:
+		t.Fatalf("Watcher not signalled")
+	}
+
+	list := w.Changed()
+	slices.Sort(list)
+
+	if !reflect.DeepEqual(list, expected) {
+		t.Errorf("Incorrect list of changed OIDs\n   expected:%v\n   got:     %v", expected, list)
+	}
+
+	if list := w.Changed(); len(list) != 0 {
+		t.Errorf("Pending changes not cleared - expected:%v, got:%v", []schema.OID{}, list)
+	}
+}
+
+func TestUnwatch(t *testing.T) {
+	w := Watch()
+	w.Close()
+
+	changed("0.4.3")
+
+	select {
+	case <-w.C:
+		t.Errorf("Closed watcher signalled")
+	default:
+	}
+
+	if list := w.Changed(); len(list) != 0 {
+		t.Errorf("Closed watcher updated - expected:%v, got:%v", []schema.OID{}, list)
+	}
+}
@@ -80,6 +80,10 @@ func validate(cc *controllers.Controllers) error {
 	controllers := cc.List()
 
 	for _, r := range controllers {
+		if v := r.Interface(); v != "" && !r.IsDeleted() && !sys.interfaces.Has(v) {
+			return fmt.Errorf("%v: invalid interface (%v)", r.DeviceID, v)
+		}
+
 		for _, v := range r.Doors() {
 			if v != "" {
 				if _, ok := sys.doors.Door(schema.OID(v)); !ok {
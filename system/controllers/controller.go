@@ -28,6 +28,7 @@ type Controller struct {
 	antipassback lib.AntiPassback
 	timezone     string
 	protocol     string
+	iface        schema.OID
 
 	created  types.Timestamp
 	modified types.Timestamp
@@ -41,6 +42,7 @@ type icontroller struct {
 	endpoint     lib.ControllerAddr
 	timezone     *time.Location
 	protocol     string
+	iface        schema.OID
 	doors        map[uint8]schema.OID
 	interlock    lib.Interlock
 	antipassback lib.AntiPassback
@@ -96,6 +98,10 @@ func (c Controller) Doors() map[uint8]schema.OID {
 	return c.doors
 }
 
+func (c Controller) Interface() schema.OID {
+	return c.iface
+}
+
 func (c *Controller) AsObjects(a *auth.Authorizator) []schema.Object {
 	list := []kv{}
 
@@ -270,6 +276,7 @@ func (c *Controller) AsObjects(a *auth.Authorizator) []schema.Object {
 		list = append(list, kv{ControllerAntiPassbackStatus, antipassback.status})
 		list = append(list, kv{ControllerAntiPassback, antipassback.antipassback})
 		list = append(list, kv{ControllerAntiPassbackConfigured, antipassback.configured})
+		list = append(list, kv{ControllerInterface, c.iface})
 	}
 
 	return c.toObjects(list, a)
@@ -336,6 +343,7 @@ func (c *Controller) AsIController() types.IController {
 		endpoint:     endpoint,
 		timezone:     location,
 		protocol:     c.protocol,
+		iface:        c.iface,
 		doors:        doors,
 		interlock:    c.interlock,
 		antipassback: c.antipassback,
@@ -661,6 +669,20 @@ func (c *Controller) set(a *auth.Authorizator, oid schema.OID, value string, dbc
 			dbc.Updated(c.OID, ControllerAntiPassback, c.antipassback)
 			c.updated(dbc, uid, "antipassback", clone.antipassback, c.antipassback)
 		}
+
+	case c.OID.Append(ControllerInterface):
+		if iface := schema.OID(strings.TrimSpace(value)); iface != "" && !schema.InterfacesOID.Contains(iface) {
+			return nil, fmt.Errorf("invalid interface (%v)", value)
+		} else if err := CanUpdate(a, c, "interface", iface); err != nil {
+			return nil, err
+		} else {
+			c.iface = iface
+			c.modified = types.TimestampNow()
+
+			list = append(list, kv{ControllerInterface, c.iface})
+
+			c.updated(dbc, uid, "interface", clone.iface, c.iface)
+		}
 	}
 
 	list = append(list, kv{ControllerStatus, c.Status()})
@@ -731,6 +753,7 @@ func (c Controller) serialize() ([]byte, error) {
 		AntiPassback lib.AntiPassback     `json:"antipassback"`
 		TimeZone     string               `json:"timezone,omitempty"`
 		Protocol     string               `json:"protocol,omitempty"`
+		Interface    schema.OID           `json:"interface,omitempty"`
 		Created      types.Timestamp      `json:"created"`
 		Modified     types.Timestamp      `json:"modified"`
 	}{
@@ -743,6 +766,7 @@ func (c Controller) serialize() ([]byte, error) {
 		AntiPassback: c.antipassback,
 		TimeZone:     c.timezone,
 		Protocol:     c.protocol,
+		Interface:    c.iface,
 		Created:      c.created.UTC(),
 		Modified:     c.modified.UTC(),
 	}
@@ -765,6 +789,7 @@ func (c *Controller) deserialize(bytes []byte) error {
 		AntiPassback lib.AntiPassback   `json:"antipassback"`
 		TimeZone     string             `json:"timezone,omitempty"`
 		Protocol     string             `json:"protocol,omitempty"`
+		Interface    schema.OID         `json:"interface,omitempty"`
 		Created      types.Timestamp    `json:"created"`
 		Modified     types.Timestamp    `json:"modified"`
 	}{
@@ -785,6 +810,7 @@ func (c *Controller) deserialize(bytes []byte) error {
 	c.antipassback = record.AntiPassback
 	c.timezone = record.TimeZone
 	c.protocol = record.Protocol
+	c.iface = record.Interface
 	c.created = record.Created
 	c.modified = record.Modified
 
@@ -806,6 +832,7 @@ func (c *Controller) clone() *Controller {
 			IP:           c.IP,
 			timezone:     c.timezone,
 			protocol:     c.protocol,
+			iface:        c.iface,
 			doors:        map[uint8]schema.OID{1: "", 2: "", 3: "", 4: ""},
 			interlock:    c.interlock,
 			antipassback: c.antipassback,
@@ -859,6 +886,10 @@ func (c icontroller) Protocol() string {
 	return "udp"
 }
 
+func (c icontroller) Interface() schema.OID {
+	return c.iface
+}
+
 func (c icontroller) Door(d uint8) (schema.OID, bool) {
 	oid, ok := c.doors[d]
 
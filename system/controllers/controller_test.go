@@ -73,6 +73,7 @@ func TestControllerAsObjects(t *testing.T) {
 		{OID: "0.2.3.9.0", Value: types.StatusOk},
 		{OID: "0.2.3.9.1", Value: "0"},
 		{OID: "0.2.3.9.2", Value: "0"},
+		{OID: "0.2.3.10", Value: schema.OID("")},
 	}
 
 	objects := c.AsObjects(nil)
@@ -169,6 +170,7 @@ func TestControllerAsObjectsWithAuth(t *testing.T) {
 		{OID: "0.2.3.9.0", Value: types.StatusOk},
 		{OID: "0.2.3.9.1", Value: "0"},
 		{OID: "0.2.3.9.2", Value: "0"},
+		{OID: "0.2.3.10", Value: schema.OID("")},
 	}
 
 	a := auth.Authorizator{
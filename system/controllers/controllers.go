@@ -3,6 +3,8 @@ package controllers
 import (
 	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
 	"sync"
 	"time"
 
@@ -203,22 +205,27 @@ func (cc Controllers) Print() {
 	}
 }
 
-func (cc *Controllers) Found(found []uint32) {
+// Adds the controllers found by the interface search that are not already configured, assigned
+// to the interface that found them.
+func (cc *Controllers) Found(found map[uint32]schema.OID) {
 loop:
-	for _, v := range found {
+	for _, v := range slices.Sorted(maps.Keys(found)) {
 		for _, c := range cc.controllers {
 			if c.DeviceID == v && !c.IsDeleted() {
 				continue loop
 			}
 		}
 
-		log.Infof("Adding unconfigured controller %v", v)
+		iface := found[v]
+
+		log.Infof("Adding unconfigured controller %v (interface %v)", v, iface)
 
 		id := v // because .. Go loop variable gotcha (the loop variable is mutable)
 		c := Controller{
 			CatalogController: catalog.CatalogController{
 				DeviceID: id,
 			},
+			iface:   iface,
 			created: types.TimestampNow(),
 		}
 
@@ -46,6 +46,7 @@ const ControllerAntiPassback = schema.ControllerAntiPassback
 const ControllerAntiPassbackStatus = schema.ControllerAntiPassbackStatus
 const ControllerAntiPassbackConfigured = schema.ControllerAntiPassbackConfigured
 const ControllerAntiPassbackModified = schema.ControllerAntiPassbackModified
+const ControllerInterface = schema.ControllerInterface
 
 var lookup = map[schema.Suffix]string{
 	ControllerStatus:             "controller.status",
@@ -74,4 +75,5 @@ var lookup = map[schema.Suffix]string{
 	ControllerDoor4:              "controller.door.4",
 	ControllerInterlock:          "controller.interlock",
 	ControllerAntiPassback:       "controller.antipassback",
+	ControllerInterface:          "controller.interface",
 }
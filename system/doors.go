@@ -91,3 +91,35 @@ func UpdateDoors(uid, role string, m map[string]any) (any, error) {
 
 	return dbc.Objects(), nil
 }
+
+// Remotely opens a door. The request is authorised against the 'open::door' rule and recorded
+// in the audit trail whether the door was opened, the request was denied or the controller
+// failed to open the door. The system lock is not held while waiting for the controller, which
+// can take up to the interface timeout for the uhppoted-rest and uhppoted-mqtt interfaces.
+func OpenDoor(uid, role string, oid schema.OID) error {
+	sys.RLock()
+	doors := sys.doors.Clone()
+	controllers := sys.controllers.AsIControllers()
+	interfaces := sys.interfaces.Clone()
+	dbc := db.NewDBC(sys.trail)
+	sys.RUnlock()
+
+	auth := auth.NewAuthorizator(uid, role)
+
+	err := doors.Open(auth, oid, dbc, func(deviceID uint32, door uint8) error {
+		for _, c := range controllers {
+			if c.ID() == deviceID {
+				return interfaces.OpenDoor(c, door)
+			}
+		}
+
+		return fmt.Errorf("controller %v not configured", deviceID)
+	})
+
+	sys.Lock()
+	defer sys.Unlock()
+
+	dbc.Commit(&sys, func() {})
+
+	return err
+}
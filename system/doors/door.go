@@ -196,6 +196,9 @@ func (d *Door) AsObjects(a *auth.Authorizator) []schema.Object {
 		list = append(list, kv{DoorControlError, control.err})
 		list = append(list, kv{DoorKeypad, d.keypad})
 		list = append(list, kv{DoorPasscodes, passcodes})
+		list = append(list, kv{DoorStateOpen, state(d.OID, DoorStateOpen, "open", "closed")})
+		list = append(list, kv{DoorStateButton, state(d.OID, DoorStateButton, "pressed", "released")})
+		list = append(list, kv{DoorStateUnlocked, state(d.OID, DoorStateUnlocked, "unlocked", "locked")})
 	}
 
 	return d.toObjects(list, a)
@@ -368,6 +371,41 @@ func (d *Door) delete(a *auth.Authorizator, dbc db.DBC) ([]schema.Object, error)
 	return d.toObjects(list, a), nil
 }
 
+// Authorises a remote 'open door' request and invokes 'f' with the controller ID and door to
+// open the door. The request is recorded in the audit trail whether it succeeded, was denied
+// or failed.
+func (d *Door) open(a *auth.Authorizator, dbc db.DBC, f func(deviceID uint32, door uint8) error) error {
+	if d == nil {
+		return fmt.Errorf("invalid door")
+	} else if d.IsDeleted() {
+		return fmt.Errorf("Door has been deleted")
+	}
+
+	uid := auth.UID(a)
+
+	if err := CanOpen(a, d); err != nil {
+		d.log(dbc, uid, "open", "door", "", "", "Denied request to open door %v", d.name)
+		return err
+	}
+
+	deviceID := catalog.GetDoorDeviceID(d.OID)
+	door := catalog.GetDoorDeviceDoor(d.OID)
+
+	if door == 0 {
+		d.log(dbc, uid, "open", "door", "", "", "Failed to open door %v (not assigned to a controller)", d.name)
+		return fmt.Errorf("cannot open door %v - not assigned to a controller", d.name)
+	}
+
+	if err := f(deviceID, door); err != nil {
+		d.log(dbc, uid, "open", "door", "", "", "Failed to open door %v (%v)", d.name, err)
+		return err
+	}
+
+	d.log(dbc, uid, "open", "door", "", "", "Opened door %v", d.name)
+
+	return nil
+}
+
 func (d Door) toObjects(list []kv, a *auth.Authorizator) []schema.Object {
 	objects := []schema.Object{}
 
@@ -465,6 +503,18 @@ func (d *Door) clone() Door {
 	}
 }
 
+// Returns the last reported sensor/relay state for a door (or "" if the door state has not
+// been retrieved from the controller).
+func state(oid schema.OID, field schema.Suffix, yes, no string) string {
+	if v, ok := catalog.GetBool(oid, field); !ok {
+		return ""
+	} else if v {
+		return yes
+	} else {
+		return no
+	}
+}
+
 func (d *Door) log(dbc db.DBC, uid string, operation string, field string, before, after any, format string, fields ...any) {
 	deviceID := catalog.GetDoorDeviceID(d.OID)
 	door := catalog.GetDoorDeviceDoor(d.OID)
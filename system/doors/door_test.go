@@ -9,6 +9,7 @@ import (
 
 	core "github.com/uhppoted/uhppote-core/types"
 
+	"github.com/uhppoted/uhppoted-httpd/audit"
 	"github.com/uhppoted/uhppoted-httpd/auth"
 	"github.com/uhppoted/uhppoted-httpd/system/catalog"
 	"github.com/uhppoted/uhppoted-httpd/system/catalog/impl"
@@ -50,6 +51,9 @@ func TestDoorAsObjects(t *testing.T) {
 		{OID: "0.3.3.3.3", Value: ""},
 		{OID: "0.3.3.4", Value: true},
 		{OID: "0.3.3.5", Value: "******"},
+		{OID: "0.3.3.6.1", Value: ""},
+		{OID: "0.3.3.6.2", Value: ""},
+		{OID: "0.3.3.6.3", Value: ""},
 	}
 
 	objects := d.AsObjects(nil)
@@ -114,6 +118,9 @@ func TestDoorAsObjectsWithAuth(t *testing.T) {
 		{OID: "0.3.3.3.3", Value: ""},
 		{OID: "0.3.3.4", Value: true},
 		{OID: "0.3.3.5", Value: "******"},
+		{OID: "0.3.3.6.1", Value: ""},
+		{OID: "0.3.3.6.2", Value: ""},
+		{OID: "0.3.3.6.3", Value: ""},
 	}
 
 	a := auth.Authorizator{
@@ -135,6 +142,119 @@ func TestDoorAsObjectsWithAuth(t *testing.T) {
 	}
 }
 
+func TestDoorAsObjectsWithState(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+
+	d := Door{
+		CatalogDoor: catalog.CatalogDoor{
+			OID: "0.3.3",
+		},
+		name: "Le Door",
+	}
+
+	catalog.PutV("0.3.3", DoorStateOpen, true)
+	catalog.PutV("0.3.3", DoorStateButton, false)
+	catalog.PutV("0.3.3", DoorStateUnlocked, true)
+
+	expected := map[schema.OID]any{
+		"0.3.3.6.1": "open",
+		"0.3.3.6.2": "released",
+		"0.3.3.6.3": "unlocked",
+	}
+
+	objects := d.AsObjects(nil)
+
+	for _, o := range objects {
+		if v, ok := expected[o.OID]; ok && o.Value != v {
+			t.Errorf("Incorrect door state %v - expected:%v, got:%v", o.OID, v, o.Value)
+		}
+	}
+}
+
+func TestDoorOpen(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+	catalog.PutT(catalog.CatalogController{OID: "0.2.7", DeviceID: 405419896})
+	catalog.PutV("0.2.7", schema.ControllerDoor3, schema.OID("0.3.3"))
+
+	d := Door{
+		CatalogDoor: catalog.CatalogDoor{
+			OID: "0.3.3",
+		},
+		name: "Le Door",
+	}
+
+	var opened []any
+
+	f := func(deviceID uint32, door uint8) error {
+		opened = []any{deviceID, door}
+		return nil
+	}
+
+	if err := d.open(nil, db.DBC{}, f); err != nil {
+		t.Errorf("Unexpected error opening door (%v)", err)
+	} else if !reflect.DeepEqual(opened, []any{uint32(405419896), uint8(3)}) {
+		t.Errorf("Incorrect door opened - expected:%v, got:%v", []any{405419896, 3}, opened)
+	}
+}
+
+func TestDoorOpenWithUnassignedDoor(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+
+	d := Door{
+		CatalogDoor: catalog.CatalogDoor{
+			OID: "0.3.3",
+		},
+		name: "Le Door",
+	}
+
+	f := func(deviceID uint32, door uint8) error {
+		t.Errorf("Unexpected open of unassigned door")
+		return nil
+	}
+
+	if err := d.open(nil, db.DBC{}, f); err == nil {
+		t.Errorf("Expected error opening unassigned door")
+	}
+}
+
+func TestDoorOpenAudit(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+	catalog.PutT(catalog.CatalogController{OID: "0.2.7", DeviceID: 405419896})
+	catalog.PutV("0.2.7", schema.ControllerDoor3, schema.OID("0.3.3"))
+
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{nil, "Opened door Le Door"},
+		{errors.New("timeout"), "Failed to open door Le Door (timeout)"},
+	}
+
+	for _, test := range tests {
+		d := Door{
+			CatalogDoor: catalog.CatalogDoor{
+				OID: "0.3.3",
+			},
+			name: "Le Door",
+		}
+
+		trail := trail{}
+		dbc := db.NewDBC(&trail)
+
+		if err := d.open(nil, dbc, func(uint32, uint8) error { return test.err }); !errors.Is(err, test.err) {
+			t.Errorf("Incorrect error opening door - expected:%v, got:%v", test.err, err)
+		}
+
+		dbc.Commit(system{}, func() {})
+
+		if len(trail.records) != 1 {
+			t.Fatalf("Incorrect number of audit records - expected:%v, got:%v", 1, len(trail.records))
+		} else if r := trail.records[0]; r.Operation != "open" || r.Details.Description != test.expected {
+			t.Errorf("Incorrect audit record - expected:%v, got:%v %v", test.expected, r.Operation, r.Details.Description)
+		}
+	}
+}
+
 func TestDoorSet(t *testing.T) {
 	expected := []schema.Object{
 		schema.Object{OID: "0.3.3", Value: ""},
@@ -194,3 +314,17 @@ func TestDoorSetWithDeleted(t *testing.T) {
 		t.Errorf("Door name unexpectedly updated - expected:%v, got:%v", "Le Door", d.name)
 	}
 }
+
+type trail struct {
+	records []audit.AuditRecord
+}
+
+func (t *trail) Write(records ...audit.AuditRecord) {
+	t.records = append(t.records, records...)
+}
+
+type system struct {
+}
+
+func (s system) Update(oid schema.OID, field schema.Suffix, value any) {
+}
@@ -121,6 +121,18 @@ func (dd *Doors) Delete(a *auth.Authorizator, oid schema.OID, dbc db.DBC) ([]sch
 	return objects, nil
 }
 
+// Authorises a remote 'open door' request, opens the door with 'f' and records the request in
+// the audit trail.
+func (dd *Doors) Open(a *auth.Authorizator, oid schema.OID, dbc db.DBC, f func(deviceID uint32, door uint8) error) error {
+	if dd != nil {
+		if d, ok := dd.doors[oid]; ok {
+			return d.open(a, dbc, f)
+		}
+	}
+
+	return fmt.Errorf("unknown door (%v)", oid)
+}
+
 func (dd *Doors) Load(blob json.RawMessage) error {
 	rs := []json.RawMessage{}
 	if err := json.Unmarshal(blob, &rs); err != nil {
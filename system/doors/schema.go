@@ -21,6 +21,9 @@ const DoorControlError = schema.DoorControlError
 const DoorControlModified = schema.DoorControlModified
 const DoorKeypad = schema.DoorKeypad
 const DoorPasscodes = schema.DoorPasscodes
+const DoorStateOpen = schema.DoorStateOpen
+const DoorStateButton = schema.DoorStateButton
+const DoorStateUnlocked = schema.DoorStateUnlocked
 
 var lookup = map[schema.Suffix]string{
 	DoorStatus:            "door.status",
@@ -39,4 +42,7 @@ var lookup = map[schema.Suffix]string{
 	DoorControlModified:   "door.control.modified",
 	DoorKeypad:            "door.keypad",
 	DoorPasscodes:         "door.passcodes",
+	DoorStateOpen:         "door.state.open",
+	DoorStateButton:       "door.state.button",
+	DoorStateUnlocked:     "door.state.unlocked",
 }
@@ -5,6 +5,7 @@ import (
 
 	"github.com/uhppoted/uhppoted-httpd/auth"
 	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+	"github.com/uhppoted/uhppoted-httpd/system/events"
 	"github.com/uhppoted/uhppoted-httpd/types"
 	"github.com/uhppoted/uhppoted-lib/uhppoted"
 )
@@ -19,6 +20,18 @@ func Events(uid, role string, start, count int) []schema.Object {
 	return objects
 }
 
+type EventsQuery = events.Query
+
+// Returns the events matching the query and the cursor for the next page of results.
+func QueryEvents(uid, role string, q EventsQuery) ([]schema.Object, string, error) {
+	sys.RLock()
+	defer sys.RUnlock()
+
+	auth := auth.NewAuthorizator(uid, role)
+
+	return sys.events.Query(q, auth)
+}
+
 func AppendEvents(list types.EventsList) {
 	deviceID := list.DeviceID
 	recent := list.Events
@@ -36,11 +49,14 @@ func AppendEvents(list types.EventsList) {
 		return device, door, name
 	}
 
-	sys.events.Received(deviceID, recent, l)
+	added := sys.events.Received(deviceID, recent, l)
+
+	for _, e := range recent {
+		_, door, card := l(e)
+		sys.denied(e, door, card)
+	}
 
-	if len(recent) > 0 {
-		if err := save(TagEvents, &sys.events); err != nil {
-			warnf("events", "%v", err)
-		}
+	if err := appendRecords(TagEvents, &sys.events, added); err != nil {
+		warnf("events", "%v", err)
 	}
 }
@@ -2,6 +2,7 @@ package events
 
 import (
 	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog"
 )
 
 type TAuthable interface {
@@ -13,7 +14,14 @@ type TAuthable interface {
 
 var rulesets = []auth.RuleSet{auth.Events}
 
+// A scoped administrator can only view the events for the doors in scope and for the cards that
+// are members of a group in scope. Events without a door or card (e.g. controller system events)
+// are only in scope if the controller is in scope.
 func CanView[T TAuthable](a auth.OpAuth, u T, field string, value any) error {
+	if !inScope(auth.ScopeOf(a), Event(u)) {
+		return auth.ErrUnauthorised
+	}
+
 	return auth.CanView(a, u, field, value, rulesets...)
 }
 
@@ -28,3 +36,27 @@ func CanUpdate[T TAuthable](a auth.OpAuth, u T, field string, value any) error {
 func CanDelete[T TAuthable](a auth.OpAuth, u T) error {
 	return auth.CanDelete(a, u, rulesets...)
 }
+
+func inScope(scope *auth.Scope, e Event) bool {
+	if !scope.IsScoped() {
+		return true
+	}
+
+	if e.Door != 0 {
+		if door := catalog.FindDoor(e.DeviceID, e.Door); door != "" && scope.HasDoor(string(door)) {
+			return true
+		}
+	}
+
+	if e.Card != 0 && scope.HasCard(e.Card) {
+		return true
+	}
+
+	if e.Door == 0 && e.Card == 0 {
+		if controller := catalog.FindController(e.DeviceID); controller != "" {
+			return scope.HasController(string(controller))
+		}
+	}
+
+	return false
+}
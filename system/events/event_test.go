@@ -12,6 +12,7 @@ import (
 
 	"github.com/uhppoted/uhppoted-httpd/auth"
 	"github.com/uhppoted/uhppoted-httpd/system/catalog"
+	memdb "github.com/uhppoted/uhppoted-httpd/system/catalog/impl"
 	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
 	"github.com/uhppoted/uhppoted-httpd/types"
 )
@@ -120,3 +121,59 @@ func TestEventAsObjectsWithAuth(t *testing.T) {
 		t.Errorf("Incorrect return from AsObjects:\n   expected:%#v\n   got:     %#v", expected, objects)
 	}
 }
+
+func TestEventInScope(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+	catalog.PutT(catalog.CatalogController{OID: "0.2.1", DeviceID: 405419896})
+	catalog.PutT(catalog.CatalogController{OID: "0.2.2", DeviceID: 303986753})
+	catalog.PutV("0.2.1", schema.ControllerDoor1, schema.OID("0.3.1"))
+	catalog.PutV("0.2.1", schema.ControllerDoor3, schema.OID("0.3.3"))
+
+	auth.SetCardGroups(func(card uint32) ([]string, bool) {
+		switch card {
+		case 8165537:
+			return []string{"0.5.1"}, true
+		case 8165538:
+			return []string{"0.5.2"}, true
+		}
+
+		return nil, false
+	})
+
+	defer auth.SetCardGroups(nil)
+
+	scope := &auth.Scope{
+		Groups:      []string{"0.5.1"},
+		Doors:       []string{"0.3.3"},
+		Controllers: []string{"0.2.1"},
+	}
+
+	event := func(deviceID uint32, door uint8, card uint32) Event {
+		return Event{
+			CatalogEvent: catalog.CatalogEvent{DeviceID: deviceID},
+			Door:         door,
+			Card:         card,
+		}
+	}
+
+	tests := []struct {
+		scope    *auth.Scope
+		event    Event
+		expected bool
+	}{
+		{nil, event(303986753, 1, 8165538), true},
+		{scope, event(405419896, 3, 8165538), true},
+		{scope, event(405419896, 1, 8165537), true},
+		{scope, event(405419896, 1, 8165538), false},
+		{scope, event(405419896, 1, 0), false},
+		{scope, event(405419896, 1, 1234567), false},
+		{scope, event(405419896, 0, 0), true},
+		{scope, event(303986753, 0, 0), false},
+	}
+
+	for _, test := range tests {
+		if v := inScope(test.scope, test.event); v != test.expected {
+			t.Errorf("Incorrect scope for event %v/%v/%v - expected:%v, got:%v", test.event.DeviceID, test.event.Door, test.event.Card, test.expected, v)
+		}
+	}
+}
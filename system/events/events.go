@@ -3,6 +3,7 @@ package events
 import (
 	"encoding/json"
 	"fmt"
+	"maps"
 	"math"
 	"slices"
 	"sort"
@@ -17,7 +18,8 @@ import (
 )
 
 type Events struct {
-	events map[eventKey]Event
+	events  map[eventKey]Event
+	horizon map[uint32]uint32 // index of the most recent purged event for each controller
 	sync.RWMutex
 }
 
@@ -64,7 +66,8 @@ var cache = struct {
 
 func NewEvents() Events {
 	return Events{
-		events: map[eventKey]Event{},
+		events:  map[eventKey]Event{},
+		horizon: map[uint32]uint32{},
 	}
 }
 
@@ -103,7 +106,7 @@ func (ee *Events) AsObjects(start, max int, auth auth.OpAuth) []schema.Object {
 
 		if e, ok := ee.events[k]; ok {
 			if e.IsValid() || e.IsDeleted() {
-				if l := e.AsObjects(auth); l != nil {
+				if l := e.AsObjects(auth); len(l) > 0 {
 					catalog.Join(&objects, l...)
 					count++
 				}
@@ -129,6 +132,30 @@ func (ee *Events) AsObjects(start, max int, auth auth.OpAuth) []schema.Object {
 	return objects
 }
 
+// Returns the objects for the events with the matching OIDs. Searches the events list sequentially
+// so is only intended for the (relatively) small number of recently received events.
+func (ee *Events) AsObjectsByOID(auth auth.OpAuth, oids ...schema.OID) []schema.Object {
+	ee.RLock()
+	defer ee.RUnlock()
+
+	objects := []schema.Object{}
+	set := map[schema.OID]bool{}
+
+	for _, oid := range oids {
+		set[oid] = true
+	}
+
+	for _, e := range ee.events {
+		if set[e.OID] && (e.IsValid() || e.IsDeleted()) {
+			if l := e.AsObjects(auth); len(l) > 0 {
+				catalog.Join(&objects, l...)
+			}
+		}
+	}
+
+	return objects
+}
+
 // FIXME searches events list sequentially to find matching OID. But not ever actually invoked (as yet...)
 func (ee *Events) UpdateByOID(auth auth.OpAuth, oid schema.OID, value string) ([]any, error) {
 	ee.Lock()
@@ -157,9 +184,19 @@ func (ee *Events) Load(blob json.RawMessage) error {
 		return err
 	}
 
+	if ee.horizon == nil {
+		ee.horizon = map[uint32]uint32{}
+	}
+
 	for _, v := range rs {
 		var e Event
-		if err := e.deserialize(v); err == nil {
+		var h horizon
+
+		if err := h.deserialize(v); err == nil {
+			if h.Index > ee.horizon[h.DeviceID] {
+				ee.horizon[h.DeviceID] = h.Index
+			}
+		} else if err := e.deserialize(v); err == nil {
 			deviceID := e.DeviceID
 
 			k := eventKey{
@@ -203,6 +240,13 @@ func (ee *Events) Save() (json.RawMessage, error) {
 		}
 	}
 
+	for _, k := range slices.Sorted(maps.Keys(ee.horizon)) {
+		h := horizon{DeviceID: k, Index: ee.horizon[k]}
+		if record, err := h.serialize(); err == nil {
+			serializable = append(serializable, record)
+		}
+	}
+
 	return json.MarshalIndent(serializable, "", "  ")
 }
 
@@ -230,13 +274,18 @@ func (ee *Events) Clone() *Events {
 	defer ee.RUnlock()
 
 	shadow := Events{
-		events: map[eventKey]Event{},
+		events:  map[eventKey]Event{},
+		horizon: map[uint32]uint32{},
 	}
 
 	for k, e := range ee.events {
 		shadow.events[k] = e.clone()
 	}
 
+	for k, v := range ee.horizon {
+		shadow.horizon[k] = v
+	}
+
 	return &shadow
 }
 
@@ -275,6 +324,7 @@ func (ee *Events) Missing(gaps int, controllers ...uint32) map[uint32][]types.In
 	for _, c := range controllers {
 		first := uint32(0)
 		last := uint32(0)
+		horizon := ee.horizon[c]
 		list := cache.events.events[c]
 
 		if N := len(list); N > 0 {
@@ -282,9 +332,14 @@ func (ee *Events) Missing(gaps int, controllers ...uint32) map[uint32][]types.In
 			last = list[N-1]
 		}
 
+		// ... don't retrieve purged events
+		if last < horizon {
+			last = horizon
+		}
+
 		missing[c] = append(missing[c], types.Interval{From: last + 1, To: math.MaxUint32})
-		if first > 1 {
-			missing[c] = append(missing[c], types.Interval{From: 1, To: first - 1})
+		if first > horizon+1 {
+			missing[c] = append(missing[c], types.Interval{From: horizon + 1, To: first - 1})
 		}
 
 		slice := list[0:]
@@ -307,10 +362,15 @@ func (ee *Events) Missing(gaps int, controllers ...uint32) map[uint32][]types.In
 	return missing
 }
 
-func (ee *Events) Received(deviceID uint32, recent []uhppoted.Event, lookup func(uhppoted.Event) (string, string, string)) {
+// Adds the events received from a controller to the events list, ignoring events that have
+// already been received or purged. Returns the serialized added events so that they can be
+// appended to the events store without saving the whole events list.
+func (ee *Events) Received(deviceID uint32, recent []uhppoted.Event, lookup func(uhppoted.Event) (string, string, string)) []json.RawMessage {
 	ee.Lock()
 	defer ee.Unlock()
 
+	added := []json.RawMessage{}
+
 	for _, e := range recent {
 		k := eventKey{
 			deviceID: e.DeviceID,
@@ -321,6 +381,10 @@ func (ee *Events) Received(deviceID uint32, recent []uhppoted.Event, lookup func
 			continue
 		}
 
+		if e.Index <= ee.horizon[e.DeviceID] {
+			continue
+		}
+
 		event := Event{
 			CatalogEvent: catalog.CatalogEvent{
 				DeviceID: e.DeviceID,
@@ -331,9 +395,79 @@ func (ee *Events) Received(deviceID uint32, recent []uhppoted.Event, lookup func
 		oid := catalog.NewT(event.CatalogEvent)
 		device, door, card := lookup(e)
 
-		ee.events[k] = NewEvent(oid, e, device, door, card)
+		event = NewEvent(oid, e, device, door, card)
+		ee.events[k] = event
+
+		if event.IsValid() && !event.IsDeleted() {
+			if record, err := event.serialize(); err == nil && record != nil {
+				added = append(added, record)
+			}
+		}
 	}
 
 	cache.events.dirty = true
 	cache.objects.dirty = true
+
+	return added
+}
+
+// Permanently removes all events before the cutoff time and returns the number of events
+// removed. The index of the most recent purged event is retained for each controller so that
+// purged events are not retrieved again from the controllers.
+func (ee *Events) Purge(cutoff time.Time) int {
+	ee.Lock()
+	defer ee.Unlock()
+
+	if ee.horizon == nil {
+		ee.horizon = map[uint32]uint32{}
+	}
+
+	count := 0
+	for k, e := range ee.events {
+		if time.Time(e.Timestamp).Before(cutoff) {
+			if k.index > ee.horizon[k.deviceID] {
+				ee.horizon[k.deviceID] = k.index
+			}
+
+			delete(ee.events, k)
+			catalog.DeleteT(e.CatalogEvent, e.OID)
+			count++
+		}
+	}
+
+	if count > 0 {
+		cache.events.dirty = true
+		cache.objects.dirty = true
+	}
+
+	return count
+}
+
+// horizon is the serialized index of the most recent purged event for a controller, saved
+// as a record in the events list so that the events are always saved as a list.
+type horizon struct {
+	DeviceID uint32 `json:"device-id"`
+	Index    uint32 `json:"horizon"`
+}
+
+func (h horizon) serialize() ([]byte, error) {
+	return json.Marshal(h)
+}
+
+func (h *horizon) deserialize(bytes []byte) error {
+	record := struct {
+		DeviceID uint32  `json:"device-id"`
+		Index    *uint32 `json:"horizon"`
+	}{}
+
+	if err := json.Unmarshal(bytes, &record); err != nil {
+		return err
+	} else if record.Index == nil {
+		return fmt.Errorf("not a purge horizon record")
+	}
+
+	h.DeviceID = record.DeviceID
+	h.Index = *record.Index
+
+	return nil
 }
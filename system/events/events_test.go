@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"testing"
@@ -12,7 +13,9 @@ import (
 	core "github.com/uhppoted/uhppote-core/types"
 
 	"github.com/uhppoted/uhppoted-httpd/system/catalog"
+	memdb "github.com/uhppoted/uhppoted-httpd/system/catalog/impl"
 	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+	"github.com/uhppoted/uhppoted-httpd/system/sqlite"
 	"github.com/uhppoted/uhppoted-httpd/types"
 )
 
@@ -408,6 +411,87 @@ func TestEventsMissingWithGapsLimit(t *testing.T) {
 	}
 }
 
+func TestEventsPurge(t *testing.T) {
+	cc := memdb.NewCatalog()
+	catalog.Init(cc)
+
+	cache.events.dirty = true
+	events := NewEvents()
+	start := time.Date(2026, time.January, 1, 12, 0, 0, 0, time.Local)
+
+	for ix := uint32(1); ix <= 69; ix++ {
+		k := eventKey{deviceID: 405419896, index: ix}
+		e := Event{
+			CatalogEvent: catalog.CatalogEvent{OID: schema.OID(fmt.Sprintf("0.6.%v", ix)), DeviceID: 405419896, Index: ix},
+			Timestamp:    core.DateTime(start.AddDate(0, 0, int(ix))),
+		}
+
+		events.events[k] = e
+		catalog.PutT(e.CatalogEvent)
+	}
+
+	for ix := uint32(1); ix <= 10; ix++ {
+		k := eventKey{deviceID: 201020304, index: ix}
+		e := Event{
+			CatalogEvent: catalog.CatalogEvent{OID: schema.OID(fmt.Sprintf("0.6.%v", 100+ix)), DeviceID: 201020304, Index: ix},
+			Timestamp:    core.DateTime(start.AddDate(0, 0, int(ix))),
+		}
+
+		events.events[k] = e
+		catalog.PutT(e.CatalogEvent)
+	}
+
+	if purged := events.Purge(start.AddDate(0, 0, 40)); purged != 49 {
+		t.Errorf("Incorrect number of purged events - expected:%v, got:%v", 49, purged)
+	}
+
+	expected := map[uint32][]types.Interval{
+		201020304: {
+			{From: 11, To: math.MaxUint32},
+		},
+		405419896: {
+			{From: 70, To: math.MaxUint32},
+		},
+	}
+
+	missing := events.Missing(-1, 201020304, 405419896)
+	if !reflect.DeepEqual(missing, expected) {
+		t.Errorf("Incorrect missing events list\n   expected:%v\n   got:     %v", expected, missing)
+	}
+
+	if list := cc.ListT(schema.EventsOID); len(list) != 30 {
+		t.Errorf("Incorrect number of catalog events - expected:%v, got:%v", 30, len(list))
+	}
+
+	blob, err := events.Save()
+	if err != nil {
+		t.Fatalf("Error saving purged events (%v)", err)
+	}
+
+	// ... round trip through SQLite store
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "httpd.db"))
+	if err != nil {
+		t.Fatalf("Error opening SQLite database (%v)", err)
+	}
+
+	defer db.Close()
+
+	if err := db.Save("events", blob); err != nil {
+		t.Fatalf("Error saving purged events to SQLite database (%v)", err)
+	} else if blob, _, err = db.Load("events"); err != nil {
+		t.Fatalf("Error loading purged events from SQLite database (%v)", err)
+	}
+
+	restored := NewEvents()
+	if err := restored.Load(blob); err != nil {
+		t.Fatalf("Error loading purged events (%v)", err)
+	} else if !reflect.DeepEqual(restored.horizon, events.horizon) {
+		t.Errorf("Incorrect restored purge horizon\n   expected:%v\n   got:     %v", events.horizon, restored.horizon)
+	} else if len(restored.events) != 30 {
+		t.Errorf("Incorrect number of restored events - expected:%v, got:%v", 30, len(restored.events))
+	}
+}
+
 func BenchmarkMissingEvents(b *testing.B) {
 	list := []Event{}
 	for ix := uint32(1); ix <= 100000; ix++ {
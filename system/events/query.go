@@ -0,0 +1,290 @@
+package events
+
+import (
+	"cmp"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+)
+
+// Query is a filter for the events list. Zero valued fields match all events. The time range
+// includes events at From but excludes events at To.
+//
+// Door and Device match either the door number/controller ID or the (case insensitive) door/
+// controller name. CardName matches any event card name containing the (case insensitive) value.
+// Type and Reason match either the numeric code or the description (e.g. "swipe", "no privilege").
+type Query struct {
+	From      time.Time
+	To        time.Time
+	Device    string
+	Door      string
+	Card      uint32
+	CardName  string
+	Granted   *bool
+	Type      string
+	Reason    string
+	Ascending bool
+	Cursor    string
+	Count     int
+}
+
+// Sort key for an event. Events are ordered by timestamp and then by controller ID and
+// event index so that the order (and hence a cursor) is stable when new events are added.
+type position struct {
+	timestamp int64
+	deviceID  uint32
+	index     uint32
+}
+
+const DefaultQueryCount = 100
+
+// Returns the (authorised) objects for the events matching the query along with a cursor for
+// the next page of results. The returned cursor is "" if there are no more matching events.
+func (ee *Events) Query(q Query, auth auth.OpAuth) ([]schema.Object, string, error) {
+	ee.RLock()
+	defer ee.RUnlock()
+
+	var after *position
+	var byType, byReason *uint8
+
+	if q.Type != "" {
+		if v, err := parseEventType(q.Type); err != nil {
+			return nil, "", err
+		} else {
+			byType = &v
+		}
+	}
+
+	if q.Reason != "" {
+		if v, err := parseReason(q.Reason); err != nil {
+			return nil, "", err
+		} else {
+			byReason = &v
+		}
+	}
+
+	if q.Cursor != "" {
+		if p, err := parseCursor(q.Cursor); err != nil {
+			return nil, "", err
+		} else {
+			after = &p
+		}
+	}
+
+	count := q.Count
+	if count <= 0 {
+		count = DefaultQueryCount
+	}
+
+	compare := func(p, r position) int {
+		v := cmp.Compare(p.timestamp, r.timestamp)
+		if v == 0 {
+			v = cmp.Compare(p.deviceID, r.deviceID)
+		}
+
+		if v == 0 {
+			v = cmp.Compare(p.index, r.index)
+		}
+
+		if !q.Ascending {
+			return -v
+		}
+
+		return v
+	}
+
+	type matched struct {
+		position
+		event Event
+	}
+
+	list := []matched{}
+	for _, e := range ee.events {
+		if !e.IsValid() || e.IsDeleted() || !q.matches(e, byType, byReason, auth) {
+			continue
+		}
+
+		p := positionOf(e)
+		if after != nil && compare(p, *after) <= 0 {
+			continue
+		}
+
+		list = append(list, matched{p, e})
+	}
+
+	slices.SortFunc(list, func(p, r matched) int {
+		return compare(p.position, r.position)
+	})
+
+	objects := []schema.Object{}
+	cursor := ""
+
+	if len(list) > count {
+		cursor = list[count-1].position.String()
+		list = list[:count]
+	}
+
+	for _, v := range list {
+		catalog.Join(&objects, v.event.AsObjects(auth)...)
+	}
+
+	return objects, cursor, nil
+}
+
+// Matches an event against the query, treating fields that are not viewable by the user as
+// not matching i.e. a query can't be used to infer restricted information.
+func (q Query) matches(e Event, byType, byReason *uint8, a auth.OpAuth) bool {
+	viewable := func(field schema.Suffix, value any) bool {
+		return CanView(a, e, lookup[field], value) == nil
+	}
+
+	timestamp := time.Time(e.Timestamp)
+
+	if !q.From.IsZero() || !q.To.IsZero() {
+		if !viewable(EventTimestamp, e.Timestamp) {
+			return false
+		}
+
+		if !q.From.IsZero() && timestamp.Before(q.From) {
+			return false
+		}
+
+		if !q.To.IsZero() && !timestamp.Before(q.To) {
+			return false
+		}
+	}
+
+	if q.Device != "" {
+		if v, err := strconv.ParseUint(q.Device, 10, 32); err == nil {
+			if !viewable(EventDeviceID, e.DeviceID) || e.DeviceID != uint32(v) {
+				return false
+			}
+		} else if !viewable(EventDeviceName, e.DeviceName) || !strings.EqualFold(strings.TrimSpace(e.DeviceName), strings.TrimSpace(q.Device)) {
+			return false
+		}
+	}
+
+	if q.Door != "" {
+		if v, err := strconv.ParseUint(q.Door, 10, 8); err == nil {
+			if !viewable(EventDoor, e.Door) || e.Door != uint8(v) {
+				return false
+			}
+		} else if !viewable(EventDoorName, e.DoorName) || !strings.EqualFold(strings.TrimSpace(e.DoorName), strings.TrimSpace(q.Door)) {
+			return false
+		}
+	}
+
+	if q.Card != 0 {
+		if !viewable(EventCard, e.Card) || e.Card != q.Card {
+			return false
+		}
+	}
+
+	if q.CardName != "" {
+		name := strings.ToLower(e.CardName)
+		if !viewable(EventCardName, e.CardName) || !strings.Contains(name, strings.ToLower(strings.TrimSpace(q.CardName))) {
+			return false
+		}
+	}
+
+	if q.Granted != nil {
+		if !viewable(EventGranted, e.Granted) || e.Granted != *q.Granted {
+			return false
+		}
+	}
+
+	if byType != nil {
+		if !viewable(EventType, e.Type) || uint8(e.Type) != *byType {
+			return false
+		}
+	}
+
+	if byReason != nil {
+		if !viewable(EventReason, e.Reason) || uint8(e.Reason) != *byReason {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Parses an event type from either the event type code or the event type name (e.g. "swipe").
+func parseEventType(s string) (uint8, error) {
+	if v, err := strconv.ParseUint(s, 10, 8); err == nil {
+		return uint8(v), nil
+	}
+
+	for i := range 256 {
+		if strings.EqualFold(eventType(i).String(), strings.TrimSpace(s)) {
+			return uint8(i), nil
+		}
+	}
+
+	return 0, fmt.Errorf("invalid event type '%v'", s)
+}
+
+// Parses an event reason from either the reason code or the reason description (e.g. "no privilege").
+func parseReason(s string) (uint8, error) {
+	if v, err := strconv.ParseUint(s, 10, 8); err == nil {
+		return uint8(v), nil
+	}
+
+	for i := range 256 {
+		if r := reason(i).String(); r != "" && strings.EqualFold(r, strings.TrimSpace(s)) {
+			return uint8(i), nil
+		}
+	}
+
+	return 0, fmt.Errorf("invalid event reason '%v'", s)
+}
+
+func positionOf(e Event) position {
+	return position{
+		timestamp: time.Time(e.Timestamp).Unix(),
+		deviceID:  e.DeviceID,
+		index:     e.Index,
+	}
+}
+
+func (p position) String() string {
+	s := fmt.Sprintf("%v:%v:%v", p.timestamp, p.deviceID, p.index)
+
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+func parseCursor(s string) (position, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return position{}, fmt.Errorf("invalid cursor '%v'", s)
+	}
+
+	match := regexp.MustCompile(`^(-?[0-9]+):([0-9]+):([0-9]+)$`).FindStringSubmatch(string(b))
+	if match == nil {
+		return position{}, fmt.Errorf("invalid cursor '%v'", s)
+	}
+
+	timestamp, _ := strconv.ParseInt(match[1], 10, 64)
+	deviceID, err := strconv.ParseUint(match[2], 10, 32)
+	if err != nil {
+		return position{}, fmt.Errorf("invalid cursor '%v'", s)
+	}
+
+	index, err := strconv.ParseUint(match[3], 10, 32)
+	if err != nil {
+		return position{}, fmt.Errorf("invalid cursor '%v'", s)
+	}
+
+	return position{
+		timestamp: timestamp,
+		deviceID:  uint32(deviceID),
+		index:     uint32(index),
+	}, nil
+}
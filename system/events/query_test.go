@@ -0,0 +1,193 @@
+package events
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	core "github.com/uhppoted/uhppote-core/types"
+
+	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+)
+
+func TestEventsQuery(t *testing.T) {
+	events := queryTestEvents()
+	granted := false
+
+	q := Query{
+		From:     time.Date(2022, time.April, 20, 0, 0, 0, 0, time.Local),
+		To:       time.Date(2022, time.April, 21, 0, 0, 0, 0, time.Local),
+		Door:     "dungeon",
+		Card:     10058400,
+		Granted:  &granted,
+		Reason:   "no privilege",
+		Type:     "swipe",
+		CardName: "hermione",
+	}
+
+	expected := []schema.OID{"0.6.1015", "0.6.1009", "0.6.1003"}
+
+	objects, cursor, err := events.Query(q, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error querying events (%v)", err)
+	}
+
+	if list := oids(objects); !reflect.DeepEqual(list, expected) {
+		t.Errorf("Incorrect query result\n   expected:%v\n   got:     %v", expected, list)
+	}
+
+	if cursor != "" {
+		t.Errorf("Unexpected cursor for final page (%v)", cursor)
+	}
+}
+
+func TestEventsQueryAscending(t *testing.T) {
+	events := queryTestEvents()
+
+	q := Query{
+		Device:    "201020304",
+		Ascending: true,
+		Count:     3,
+	}
+
+	expected := []schema.OID{"0.6.1001", "0.6.1002", "0.6.1003"}
+
+	objects, _, err := events.Query(q, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error querying events (%v)", err)
+	}
+
+	if list := oids(objects); !reflect.DeepEqual(list, expected) {
+		t.Errorf("Incorrect query result\n   expected:%v\n   got:     %v", expected, list)
+	}
+}
+
+func TestEventsQueryCursor(t *testing.T) {
+	events := queryTestEvents()
+
+	q := Query{
+		Door:  "Dungeon",
+		Count: 4,
+	}
+
+	pages := [][]schema.OID{
+		{"0.6.1018", "0.6.1015", "0.6.1012", "0.6.1009"},
+		{"0.6.1006", "0.6.1003"},
+	}
+
+	objects, cursor, err := events.Query(q, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error querying events (%v)", err)
+	} else if cursor == "" {
+		t.Fatalf("Missing cursor for next page")
+	}
+
+	if list := oids(objects); !reflect.DeepEqual(list, pages[0]) {
+		t.Errorf("Incorrect first page\n   expected:%v\n   got:     %v", pages[0], list)
+	}
+
+	// ... new event should not affect next page
+	timestamp := time.Date(2022, time.April, 21, 12, 34, 50, 0, time.Local)
+	events.events[eventKey{201020304, 2001}] = Event{
+		CatalogEvent: catalog.CatalogEvent{OID: "0.6.2001", DeviceID: 201020304, Index: 2001},
+		Timestamp:    core.DateTime(timestamp),
+		DoorName:     "Dungeon",
+	}
+
+	q.Cursor = cursor
+	objects, cursor, err = events.Query(q, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error querying events (%v)", err)
+	}
+
+	if list := oids(objects); !reflect.DeepEqual(list, pages[1]) {
+		t.Errorf("Incorrect second page\n   expected:%v\n   got:     %v", pages[1], list)
+	}
+
+	if cursor != "" {
+		t.Errorf("Unexpected cursor for final page (%v)", cursor)
+	}
+}
+
+func TestEventsQueryWithAuth(t *testing.T) {
+	events := queryTestEvents()
+
+	q := Query{
+		CardName: "Hermione",
+	}
+
+	auth := stub{
+		canView: func(ruleset auth.RuleSet, object auth.Operant, field string, value any) error {
+			if field == "event.card.name" {
+				return errors.New("test")
+			}
+
+			return nil
+		},
+	}
+
+	objects, _, err := events.Query(q, &auth)
+	if err != nil {
+		t.Fatalf("Unexpected error querying events (%v)", err)
+	}
+
+	if len(objects) != 0 {
+		t.Errorf("Query matched on restricted field\n   expected:%v\n   got:     %v", []schema.OID{}, oids(objects))
+	}
+}
+
+func TestEventsQueryWithInvalidCursor(t *testing.T) {
+	events := queryTestEvents()
+
+	if _, _, err := events.Query(Query{Cursor: "garbage"}, nil); err == nil {
+		t.Errorf("Expected error for invalid cursor")
+	}
+}
+
+func queryTestEvents() *Events {
+	events := &Events{
+		events: map[eventKey]Event{},
+	}
+
+	base := time.Date(2022, time.April, 20, 12, 34, 50, 0, time.Local)
+	doors := []string{"Dungeon", "Great Hall", "Kitchen"}
+
+	for i := range 18 {
+		ix := uint32(1001 + i)
+		k := eventKey{deviceID: 201020304, index: ix}
+		events.events[k] = Event{
+			CatalogEvent: catalog.CatalogEvent{
+				OID:      schema.EventsOID.AppendS(strconv.Itoa(int(ix))),
+				DeviceID: 201020304,
+				Index:    ix,
+			},
+			Timestamp:  core.DateTime(base.Add(time.Duration(i) * time.Minute)),
+			Type:       1,
+			Door:       uint8(1 + i%3),
+			Card:       10058400,
+			Granted:    i%6 == 5,
+			Reason:     6,
+			DeviceName: "Alpha",
+			DoorName:   doors[(i+1)%3],
+			CardName:   "Hermione Granger",
+		}
+	}
+
+	return events
+}
+
+func oids(objects []schema.Object) []schema.OID {
+	list := []schema.OID{}
+	for _, o := range objects {
+		if strings.Count(string(o.OID), ".") == 2 {
+			list = append(list, o.OID)
+		}
+	}
+
+	return list
+}
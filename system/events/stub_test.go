@@ -31,3 +31,7 @@ func (x *stub) CanDelete(operant auth.Operant, rulesets ...auth.RuleSet) error {
 func (x *stub) CanCache(object auth.Operant, field string, cache string, rulesets ...auth.RuleSet) error {
 	return nil
 }
+
+func (x *stub) CanAction(object auth.Operant, action string, rulesets ...auth.RuleSet) error {
+	return nil
+}
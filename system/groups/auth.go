@@ -13,18 +13,49 @@ type TAuthable interface {
 
 var rulesets = []auth.RuleSet{auth.Groups}
 
+// A scoped administrator can only view and update the groups in scope and cannot add or
+// delete groups.
 func CanView[T TAuthable](a auth.OpAuth, u T, field string, value any) error {
+	if !auth.ScopeOf(a).HasGroup(oid(u)) {
+		return auth.ErrUnauthorised
+	}
+
 	return auth.CanView(a, u, field, value, rulesets...)
 }
 
 func CanAdd[T TAuthable](a auth.OpAuth, u T) error {
+	if auth.ScopeOf(a).IsScoped() {
+		return auth.ErrUnauthorised
+	}
+
 	return auth.CanAdd(a, u, rulesets...)
 }
 
 func CanUpdate[T TAuthable](a auth.OpAuth, u T, field string, value any) error {
+	if !auth.ScopeOf(a).HasGroup(oid(u)) {
+		return auth.ErrUnauthorised
+	}
+
 	return auth.CanUpdate(a, u, field, value, rulesets...)
 }
 
 func CanDelete[T TAuthable](a auth.OpAuth, u T) error {
+	if auth.ScopeOf(a).IsScoped() {
+		return auth.ErrUnauthorised
+	}
+
 	return auth.CanDelete(a, u, rulesets...)
 }
+
+func oid[T TAuthable](u T) string {
+	switch v := any(u).(type) {
+	case Group:
+		return string(v.OID)
+	case *Group:
+		if v != nil {
+			return string(v.OID)
+		}
+	}
+
+	return ""
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"maps"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 
@@ -20,6 +21,9 @@ type Group struct {
 	Name  string              `json:"name"`
 	Doors map[schema.OID]bool `json:"doors"`
 
+	// Time profile (if any) for each door i.e. door OID -> time profile OID
+	Profiles map[schema.OID]schema.OID `json:"profiles"`
+
 	created  types.Timestamp
 	modified types.Timestamp
 	deleted  types.Timestamp
@@ -68,17 +72,23 @@ func (g *Group) AsObjects(a *auth.Authorizator) []schema.Object {
 		list = append(list, kv{GroupName, name})
 
 		doors := catalog.GetDoors()
+		scope := auth.ScopeOf(a)
 		re := regexp.MustCompile(`^(.*?)(\.[0-9]+)$`)
 
 		for _, door := range doors {
 			d := fmt.Sprintf("%v", door)
 
+			if !scope.HasDoor(d) {
+				continue
+			}
+
 			if m := re.FindStringSubmatch(d); len(m) > 2 {
 				did := m[2]
 				allowed := g.Doors[door]
 
 				list = append(list, kv{GroupDoors.Append(did), allowed})
 				list = append(list, kv{GroupDoors.Append(did + ".1"), door})
+				list = append(list, kv{GroupDoors.Append(did + ".2"), g.Profiles[door]})
 			}
 		}
 	}
@@ -146,6 +156,38 @@ func (g *Group) set(a *auth.Authorizator, oid schema.OID, value string, dbc db.D
 			g.log(dbc, uid, "update", "name", g.Name, value, "Updated name from %v to %v", original.Name, g.Name)
 		}
 
+	case regexp.MustCompile(`^` + regexp.QuoteMeta(string(g.OID.Append(GroupDoors))) + `\.[0-9]+` + regexp.QuoteMeta(string(GroupDoorProfile)) + `$`).MatchString(string(oid)):
+		if m := regexp.MustCompile(`^(?:.*?)\.([0-9]+)\.[0-9]+$`).FindStringSubmatch(string(oid)); len(m) > 1 {
+			did := m[1]
+			k := schema.DoorsOID.AppendS(did)
+			door := catalog.GetV(k, DoorName)
+			profile := schema.OID(value)
+
+			if err := CanUpdate(a, g, door.(string), value); err != nil {
+				return nil, err
+			} else if !auth.ScopeOf(a).HasDoor(string(k)) {
+				return nil, auth.ErrUnauthorised
+			} else if profile != "" && !catalog.HasTimeProfile(profile) {
+				return nil, fmt.Errorf("invalid time profile (%v)", value)
+			} else {
+				if profile == "" {
+					g.log(dbc, uid, "update", "door", "", "", "Removed time profile for %v", door)
+					delete(g.Profiles, k)
+				} else {
+					g.log(dbc, uid, "update", "door", "", "", "Restricted access to %v with time profile %v", door, catalog.GetV(profile, TimeProfileName))
+					if g.Profiles == nil {
+						g.Profiles = map[schema.OID]schema.OID{}
+					}
+
+					g.Profiles[k] = profile
+				}
+
+				g.modified = types.TimestampNow()
+
+				list = append(list, kv{GroupDoors.Append(did + ".2"), g.Profiles[k]})
+			}
+		}
+
 	case schema.OID(g.OID.Append(GroupDoors)).Contains(oid):
 		if m := regexp.MustCompile(`^(?:.*?)\.([0-9]+)$`).FindStringSubmatch(string(oid)); len(m) > 1 {
 			did := m[1]
@@ -154,6 +196,8 @@ func (g *Group) set(a *auth.Authorizator, oid schema.OID, value string, dbc db.D
 
 			if err := CanUpdate(a, g, door.(string), value); err != nil {
 				return nil, err
+			} else if !auth.ScopeOf(a).HasDoor(string(k)) {
+				return nil, auth.ErrUnauthorised
 			} else {
 				if value == "true" {
 					g.log(dbc, uid, "update", "door", "", "", "Granted access to %v", door)
@@ -216,11 +260,12 @@ func (g Group) toObjects(list []kv, a *auth.Authorizator) []schema.Object {
 
 func (g Group) serialize() ([]byte, error) {
 	record := struct {
-		OID      schema.OID      `json:"OID"`
-		Name     string          `json:"name,omitempty"`
-		Doors    []schema.OID    `json:"doors"`
-		Created  types.Timestamp `json:"created"`
-		Modified types.Timestamp `json:"modified"`
+		OID      schema.OID                `json:"OID"`
+		Name     string                    `json:"name,omitempty"`
+		Doors    []schema.OID              `json:"doors"`
+		Profiles map[schema.OID]schema.OID `json:"profiles,omitempty"`
+		Created  types.Timestamp           `json:"created"`
+		Modified types.Timestamp           `json:"modified"`
 	}{
 		OID:      g.OID,
 		Name:     g.Name,
@@ -229,11 +274,19 @@ func (g Group) serialize() ([]byte, error) {
 		Modified: g.modified.UTC(),
 	}
 
-	doors := catalog.GetDoors()
+	doors := slices.SortedFunc(slices.Values(catalog.GetDoors()), schema.OID.Compare)
 
 	for _, d := range doors {
 		if g.Doors[d] {
 			record.Doors = append(record.Doors, d)
+
+			if p, ok := g.Profiles[d]; ok && p != "" {
+				if record.Profiles == nil {
+					record.Profiles = map[schema.OID]schema.OID{}
+				}
+
+				record.Profiles[d] = p
+			}
 		}
 	}
 
@@ -244,11 +297,12 @@ func (g *Group) deserialize(bytes []byte) error {
 	created = created.Add(1 * time.Minute)
 
 	record := struct {
-		OID      string          `json:"OID"`
-		Name     string          `json:"name,omitempty"`
-		Doors    []schema.OID    `json:"doors"`
-		Created  types.Timestamp `json:"created"`
-		Modified types.Timestamp `json:"modified"`
+		OID      string                    `json:"OID"`
+		Name     string                    `json:"name,omitempty"`
+		Doors    []schema.OID              `json:"doors"`
+		Profiles map[schema.OID]schema.OID `json:"profiles"`
+		Created  types.Timestamp           `json:"created"`
+		Modified types.Timestamp           `json:"modified"`
 	}{
 		Created: created,
 	}
@@ -260,6 +314,7 @@ func (g *Group) deserialize(bytes []byte) error {
 	g.OID = schema.OID(record.OID)
 	g.Name = record.Name
 	g.Doors = map[schema.OID]bool{}
+	g.Profiles = map[schema.OID]schema.OID{}
 	g.created = record.Created
 	g.modified = record.Modified
 
@@ -267,6 +322,12 @@ func (g *Group) deserialize(bytes []byte) error {
 		g.Doors[schema.OID(d)] = true
 	}
 
+	for d, p := range record.Profiles {
+		if p != "" {
+			g.Profiles[d] = p
+		}
+	}
+
 	return nil
 }
 
@@ -277,12 +338,14 @@ func (g Group) clone() Group {
 		},
 		Name:     g.Name,
 		Doors:    map[schema.OID]bool{},
+		Profiles: map[schema.OID]schema.OID{},
 		created:  g.created,
 		modified: g.modified,
 		deleted:  g.deleted,
 	}
 
 	maps.Copy(group.Doors, g.Doors)
+	maps.Copy(group.Profiles, g.Profiles)
 
 	return group
 }
@@ -2,7 +2,9 @@ package groups
 
 import (
 	"errors"
+	"maps"
 	"reflect"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -28,7 +30,8 @@ func TestGroupDeserialize(t *testing.T) {
 			"0.3.3": true,
 			"0.3.7": true,
 		},
-		created: created,
+		Profiles: map[schema.OID]schema.OID{},
+		created:  created,
 	}
 
 	var g Group
@@ -55,7 +58,8 @@ func TestGroupDeserializeWithDefaultCreated(t *testing.T) {
 			"0.3.3": true,
 			"0.3.7": true,
 		},
-		created: created.Add(1 * time.Minute),
+		Profiles: map[schema.OID]schema.OID{},
+		created:  created.Add(1 * time.Minute),
 	}
 
 	var g Group
@@ -235,3 +239,133 @@ func TestGroupSetWithDeleted(t *testing.T) {
 		t.Errorf("Group name unexpectedly updated - expected:%v, got:%v", "Le Group", g.Name)
 	}
 }
+
+func TestGroupSetDoorProfile(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+	catalog.PutT(catalog.CatalogDoor{OID: "0.3.7"})
+	catalog.PutV("0.3.7", DoorName, "Gryffindor")
+	catalog.PutT(catalog.CatalogTimeProfile{OID: "0.9.1"})
+	catalog.PutV("0.9.1", TimeProfileName, "Cleaners")
+
+	g := Group{
+		CatalogGroup: catalog.CatalogGroup{
+			OID: "0.5.3",
+		},
+		Name: "Le Groupe",
+		Doors: map[schema.OID]bool{
+			"0.3.7": true,
+		},
+	}
+
+	expected := []schema.Object{
+		{OID: "0.5.3", Value: ""},
+		{OID: "0.5.3.2.7.2", Value: schema.OID("0.9.1")},
+		{OID: "0.5.3.0.0", Value: types.StatusOk},
+	}
+
+	objects, err := g.set(nil, "0.5.3.2.7.2", "0.9.1", db.DBC{})
+	if err != nil {
+		t.Fatalf("Unexpected error (%v)", err)
+	}
+
+	if !reflect.DeepEqual(objects, expected) {
+		t.Errorf("Invalid result\n   expected:%#v\n   got:     %#v", expected, objects)
+	}
+
+	if !g.Doors["0.3.7"] || g.Profiles["0.3.7"] != "0.9.1" {
+		t.Errorf("Door time profile not updated - expected:%v %v, got:%v %v", true, "0.9.1", g.Doors["0.3.7"], g.Profiles["0.3.7"])
+	}
+
+	if _, err := g.set(nil, "0.5.3.2.7.2", "0.9.2", db.DBC{}); err == nil {
+		t.Errorf("Expected error updating door with invalid time profile")
+	}
+}
+
+func TestGroupSerializeWithProfiles(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+	catalog.PutT(catalog.CatalogDoor{OID: "0.3.3"})
+	catalog.PutT(catalog.CatalogDoor{OID: "0.3.7"})
+
+	g := Group{
+		CatalogGroup: catalog.CatalogGroup{
+			OID: "0.5.3",
+		},
+		Name: "Le Groupe",
+		Doors: map[schema.OID]bool{
+			"0.3.3": true,
+			"0.3.7": true,
+		},
+		Profiles: map[schema.OID]schema.OID{
+			"0.3.7": "0.9.1",
+		},
+		created: types.Timestamp(time.Date(2022, time.April, 1, 0, 0, 0, 0, time.UTC)),
+	}
+
+	expected := `{"OID":"0.5.3","name":"Le Groupe","doors":["0.3.3","0.3.7"],"profiles":{"0.3.7":"0.9.1"},"created":"2022-04-01 00:00:00 UTC","modified":""}`
+
+	if bytes, err := g.serialize(); err != nil {
+		t.Fatalf("Error serializing group (%v)", err)
+	} else if string(bytes) != expected {
+		t.Errorf("Group incorrectly serialized\n   expected:%v\n   got:     %v", expected, string(bytes))
+	}
+}
+
+func TestGroupWithScope(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+	catalog.PutT(catalog.CatalogDoor{OID: "0.3.3"})
+	catalog.PutV("0.3.3", DoorName, "Hufflepuff")
+	catalog.PutT(catalog.CatalogDoor{OID: "0.3.7"})
+	catalog.PutV("0.3.7", DoorName, "Gryffindor")
+
+	if err := auth.Init(nil, "admin"); err != nil {
+		t.Fatalf("Error initialising auth (%v)", err)
+	}
+
+	auth.SetScopes(func(uid string) *auth.Scope {
+		return &auth.Scope{
+			Groups: []string{"0.5.3"},
+			Doors:  []string{"0.3.7"},
+		}
+	})
+
+	defer auth.SetScopes(nil)
+
+	a := auth.NewAuthorizator("moony", "admin")
+	doors := map[schema.OID]bool{"0.3.3": true, "0.3.7": true}
+
+	g := Group{CatalogGroup: catalog.CatalogGroup{OID: "0.5.3"}, Name: "Le Groupe", Doors: maps.Clone(doors)}
+	h := Group{CatalogGroup: catalog.CatalogGroup{OID: "0.5.4"}, Name: "Ze Gruppe", Doors: maps.Clone(doors)}
+
+	// ... view
+	oids := []schema.OID{}
+	for _, o := range g.AsObjects(a) {
+		if strings.HasPrefix(string(o.OID), string(g.OID.Append(GroupDoors))) {
+			oids = append(oids, o.OID)
+		}
+	}
+
+	if expected := []schema.OID{"0.5.3.2.7", "0.5.3.2.7.1", "0.5.3.2.7.2"}; !slices.Equal(oids, expected) {
+		t.Errorf("Incorrect scoped group doors\n   expected:%v\n   got:     %v", expected, oids)
+	}
+
+	if objects := h.AsObjects(a); len(objects) != 0 {
+		t.Errorf("Expected no objects for out of scope group, got %v", objects)
+	}
+
+	// ... update
+	if _, err := g.set(a, "0.5.3.2.7", "false", db.DBC{}); err != nil {
+		t.Errorf("Unexpected error updating in scope door (%v)", err)
+	}
+
+	if _, err := g.set(a, "0.5.3.2.3", "false", db.DBC{}); !errors.Is(err, auth.ErrUnauthorised) {
+		t.Errorf("Expected 'unauthorised' error updating out of scope door, got %v", err)
+	}
+
+	if _, err := h.set(a, "0.5.4.1", "Das Gruppe", db.DBC{}); !errors.Is(err, auth.ErrUnauthorised) {
+		t.Errorf("Expected 'unauthorised' error updating out of scope group, got %v", err)
+	}
+
+	if err := CanAdd(a, &Group{}); !errors.Is(err, auth.ErrUnauthorised) {
+		t.Errorf("Expected 'unauthorised' error adding group, got %v", err)
+	}
+}
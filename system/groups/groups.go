@@ -259,3 +259,17 @@ func (gg *Groups) add(a auth.OpAuth, g Group) (*Group, error) {
 
 	return &group, nil
 }
+
+func (gg *Groups) List() []Group {
+	guard.RLock()
+	defer guard.RUnlock()
+
+	list := []Group{}
+	for _, g := range gg.groups {
+		if !g.IsDeleted() {
+			list = append(list, g)
+		}
+	}
+
+	return list
+}
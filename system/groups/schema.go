@@ -10,8 +10,10 @@ const GroupDeleted = schema.Deleted
 const GroupModified = schema.Modified
 const GroupName = schema.GroupName
 const GroupDoors = schema.GroupDoors
+const GroupDoorProfile = schema.GroupDoorProfile
 
 const DoorName = schema.DoorName
+const TimeProfileName = schema.TimeProfileName
 
 var lookup = map[schema.Suffix]string{
 	GroupStatus:   "group.status",
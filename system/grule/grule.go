@@ -13,7 +13,14 @@ import (
 )
 
 type Rules interface {
-	Eval(cards.Card, doors.Doors) ([]doors.Door, []doors.Door, error)
+	Eval(cards.Card, doors.Doors) ([]Grant, []doors.Door, error)
+}
+
+// Grant is a door allowed by the rules. Profile is the name (or ID) of the time profile
+// that restricts access to the door and is blank for unrestricted access.
+type Grant struct {
+	Door    doors.Door
+	Profile string
 }
 
 type rules struct {
@@ -21,10 +28,15 @@ type rules struct {
 }
 
 type permissions struct {
-	allowed   []string
+	allowed   []allow
 	forbidden []string
 }
 
+type allow struct {
+	door    string
+	profile string
+}
+
 type query struct {
 }
 
@@ -40,7 +52,11 @@ func (q query) HasGroup(groups []string, group string) bool {
 }
 
 func (p *permissions) Allow(door string) {
-	p.allowed = append(p.allowed, door)
+	p.allowed = append(p.allowed, allow{door, ""})
+}
+
+func (p *permissions) AllowWithProfile(door string, profile string) {
+	p.allowed = append(p.allowed, allow{door, profile})
 }
 
 func (p *permissions) Forbid(door string) {
@@ -53,10 +69,13 @@ func NewGrule(library *ast.KnowledgeLibrary) (*rules, error) {
 	}, nil
 }
 
-func (r *rules) Eval(c cards.Card, dd doors.Doors) ([]doors.Door, []doors.Door, error) {
+// Evaluates the rules for a card and returns the list of allowed and forbidden doors. A door that
+// is both allowed and forbidden is forbidden. A door allowed more than once (e.g. with different
+// time profiles) is returned once for each grant.
+func (r *rules) Eval(c cards.Card, dd doors.Doors) ([]Grant, []doors.Door, error) {
 	if r != nil {
 		p := permissions{
-			allowed:   []string{},
+			allowed:   []allow{},
 			forbidden: []string{},
 		}
 
@@ -85,29 +104,24 @@ func (r *rules) Eval(c cards.Card, dd doors.Doors) ([]doors.Door, []doors.Door,
 			return nil, nil, err
 		}
 
-		list := map[schema.OID]int{}
-		for _, a := range p.allowed {
-			if d, ok := dd.ByName(a); ok {
-				list[d.OID] = 1
+		list := map[schema.OID]bool{}
+		for _, f := range p.forbidden {
+			if d, ok := dd.ByName(f); ok {
+				list[d.OID] = true
 			}
 		}
 
-		for _, f := range p.forbidden {
-			if d, ok := dd.ByName(f); ok {
-				list[d.OID] = -1
+		allowed := []Grant{}
+		for _, a := range p.allowed {
+			if d, ok := dd.ByName(a.door); ok && !list[d.OID] {
+				allowed = append(allowed, Grant{Door: d, Profile: a.profile})
 			}
 		}
 
-		allowed := []doors.Door{}
 		forbidden := []doors.Door{}
-		for k, v := range list {
+		for k := range list {
 			if d, ok := dd.Door(k); ok {
-				switch v {
-				case 1:
-					allowed = append(allowed, d)
-				case -1:
-					forbidden = append(forbidden, d)
-				}
+				forbidden = append(forbidden, d)
 			}
 		}
 
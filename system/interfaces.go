@@ -1,11 +1,41 @@
 package system
 
 import (
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/uhppoted/uhppoted-httpd/auth"
 	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
 	"github.com/uhppoted/uhppoted-httpd/system/db"
+	"github.com/uhppoted/uhppoted-httpd/system/interfaces"
+	"github.com/uhppoted/uhppoted-httpd/system/simulator"
 )
 
+// Simulate replaces the controllers with in-process virtual controllers, created for the
+// comma separated list of controller IDs (and for any other configured controllers as they
+// are addressed). A synthetic card swipe is generated at each 'swipes' interval.
+func Simulate(controllers string, swipes time.Duration) {
+	ids := []uint32{}
+	for _, v := range strings.Split(controllers, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			if id, err := strconv.ParseUint(v, 10, 32); err != nil || id == 0 {
+				warnf("interfaces", "invalid simulator controller ID (%v)", v)
+			} else {
+				ids = append(ids, uint32(id))
+			}
+		}
+	}
+
+	s := simulator.NewSimulator(ids...)
+
+	interfaces.Simulate(s)
+
+	go s.Run(swipes, nil)
+
+	infof("interfaces", "simulating controllers %v (swipe interval %v)", ids, swipes)
+}
+
 func Interfaces(uid, role string) []schema.Object {
 	sys.RLock()
 	defer sys.RUnlock()
@@ -63,6 +93,7 @@ func UpdateInterfaces(uid, role string, m map[string]any) (any, error) {
 
 	dbc.Commit(&sys, func() {
 		sys.interfaces = shadow
+		sys.interfaces.Listen()
 	})
 
 	return dbc.Objects(), nil
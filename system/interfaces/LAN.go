@@ -205,6 +205,7 @@ func (l LAN) Clone() LAN {
 		ListenAddress:    l.ListenAddress,
 		Debug:            l.Debug,
 
+		ch:           l.ch,
 		created:      l.created,
 		modified:     l.modified,
 		deleted:      l.deleted,
@@ -253,6 +254,8 @@ func (l *LAN) refresh(c types.IController) {
 	} else {
 		catalog.PutV(c.OID(), ControllerTouched, time.Now())
 		catalog.PutV(c.OID(), ControllerDateTimeCurrent, status.SystemDateTime)
+
+		putDoorStates(status, c.Door)
 	}
 
 	if cards, err := api.GetCardRecords(uhppoted.GetCardRecordsRequest{DeviceID: deviceID}); err != nil {
@@ -306,21 +309,11 @@ func (l *LAN) getEvents(c types.IController, intervals []types.Interval) {
 		catalog.PutV(oid, ControllerEventsFirst, first)
 		catalog.PutV(oid, ControllerEventsLast, last)
 		catalog.PutV(oid, ControllerEventsCurrent, current)
+		catalog.PutV(oid, ControllerEventsStatus, eventsStatus(intervals, first, last))
 
-		status := types.StatusOk
-		for _, interval := range intervals {
-			if interval.Contains(last) || interval.Contains(first) || (interval.From >= first && interval.To <= last) {
-				status = types.StatusIncomplete
-				break
-			}
-		}
-
-		catalog.PutV(oid, ControllerEventsStatus, status)
-
-		count := 0
 		events := []uhppoted.Event{}
 
-		f := func(index uint32) {
+		retrieve(intervals, first, last, func(index uint32) {
 			if e, err := api.UHPPOTE.GetEvent(deviceID, index); err != nil {
 				log.Warnf("%v", err)
 			} else if e == nil {
@@ -342,35 +335,7 @@ func (l *LAN) getEvents(c types.IController, intervals []types.Interval) {
 					Reason:     e.Reason,
 				})
 			}
-
-			count++
-		}
-
-		for _, interval := range intervals {
-			if interval.Contains(last) {
-				index := max(interval.From, first)
-
-				for index <= last && count < MAX {
-					f(index)
-					index++
-				}
-			}
-
-			if interval.Contains(first) {
-				index := min(interval.To, last)
-
-				for index >= first && count < MAX {
-					f(index)
-					index--
-				}
-			}
-
-			if interval.From >= first && interval.To <= last {
-				for index := interval.From; index <= interval.To; index++ {
-					f(index)
-				}
-			}
-		}
+		})
 
 		if l.ch != nil {
 			l.ch <- types.EventsList{
@@ -440,6 +405,24 @@ func (l *LAN) setDoor(c types.IController, door uint8, mode lib.ControlState, de
 	}
 }
 
+func (l *LAN) openDoor(c types.IController, door uint8) error {
+	lock(c.ID())
+	defer unlock(c.ID())
+
+	api := l.api([]types.IController{c})
+	deviceID := c.ID()
+
+	if result, err := api.UHPPOTE.OpenDoor(deviceID, door); err != nil {
+		return err
+	} else if result == nil || !result.Succeeded {
+		return fmt.Errorf("%v  failed to open door %v", deviceID, door)
+	} else {
+		log.Infof("%v  opened door %v", deviceID, door)
+
+		return nil
+	}
+}
+
 func (l *LAN) setInterlock(c types.IController, interlock lib.Interlock) error {
 	lock(c.ID())
 	defer unlock(c.ID())
@@ -559,7 +542,23 @@ func (l *LAN) deleteCard(c types.IController, card uint32) {
 	}
 }
 
-func (l *LAN) compareACL(controllers []types.IController, permissions acl.ACL, withPIN bool) (map[uint32]acl.Diff, error) {
+func (l *LAN) putTimeProfile(c types.IController, profile lib.TimeProfile) error {
+	lock(c.ID())
+	defer unlock(c.ID())
+
+	api := l.api([]types.IController{c})
+	deviceID := c.ID()
+
+	if ok, err := api.UHPPOTE.SetTimeProfile(deviceID, profile); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("%v  failed to set time profile %v", deviceID, profile.ID)
+	} else {
+		return nil
+	}
+}
+
+func (l *LAN) compareACL(controllers []types.IController, permissions acl.ACL, profiles []lib.TimeProfile, withPIN bool) (map[uint32]acl.Diff, map[uint32][]uint8, error) {
 	log.Debugf("Comparing ACL (with-pin:%v)", withPIN)
 
 	devices := []uhppote.Device{}
@@ -584,9 +583,9 @@ func (l *LAN) compareACL(controllers []types.IController, permissions acl.ACL, w
 
 	compare, err := f(permissions, current)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	} else if compare == nil {
-		return nil, fmt.Errorf("invalid ACL compare report: %v", compare)
+		return nil, nil, fmt.Errorf("invalid ACL compare report: %v", compare)
 	}
 
 	for k, v := range compare {
@@ -596,7 +595,7 @@ func (l *LAN) compareACL(controllers []types.IController, permissions acl.ACL, w
 	diff := acl.SystemDiff(compare)
 	report := diff.Consolidate()
 	if report == nil {
-		return nil, fmt.Errorf("invalid consolidated ACL compare report: %v", report)
+		return nil, nil, fmt.Errorf("invalid consolidated ACL compare report: %v", report)
 	}
 
 	unchanged := len(report.Unchanged)
@@ -606,11 +605,27 @@ func (l *LAN) compareACL(controllers []types.IController, permissions acl.ACL, w
 
 	log.Infof("ACL compare    unchanged:%-3v updated:%-3v added:%-3v deleted:%-3v", unchanged, updated, added, deleted)
 
+	mismatched := map[uint32][]uint8{}
+	for _, d := range devices {
+		for _, p := range profiles {
+			if q, err := api.UHPPOTE.GetTimeProfile(d.DeviceID, p.ID); err != nil {
+				log.Warnf("%v", err)
+				mismatched[d.DeviceID] = append(mismatched[d.DeviceID], p.ID)
+			} else if q == nil || !sameTimeProfile(p, *q) {
+				mismatched[d.DeviceID] = append(mismatched[d.DeviceID], p.ID)
+			}
+		}
+
+		if len(mismatched[d.DeviceID]) > 0 {
+			log.Infof("ACL %v  time profiles out of synch:%v", d.DeviceID, mismatched[d.DeviceID])
+		}
+	}
+
 	for _, c := range controllers {
 		for _, d := range devices {
 			if c.ID() == d.DeviceID {
 				rs := compare[c.ID()]
-				if len(rs.Updated)+len(rs.Added)+len(rs.Deleted) > 0 {
+				if len(rs.Updated)+len(rs.Added)+len(rs.Deleted)+len(mismatched[c.ID()]) > 0 {
 					catalog.PutV(c.OID(), ControllerCardsStatus, types.StatusError)
 				} else {
 					catalog.PutV(c.OID(), ControllerCardsStatus, types.StatusOk)
@@ -620,7 +635,39 @@ func (l *LAN) compareACL(controllers []types.IController, permissions acl.ACL, w
 		}
 	}
 
-	return compare, nil
+	return compare, mismatched, nil
+}
+
+// Updates the catalog with the door sensor, pushbutton and lock relay states reported in a
+// controller status.
+func putDoorStates(status *lib.Status, door func(uint8) (schema.OID, bool)) {
+	for _, d := range []uint8{1, 2, 3, 4} {
+		if oid, ok := door(d); ok && oid != "" {
+			catalog.PutV(oid, DoorStateOpen, status.DoorState[d])
+			catalog.PutV(oid, DoorStateButton, status.DoorButton[d])
+			catalog.PutV(oid, DoorStateUnlocked, status.RelayState&(1<<(d-1)) != 0)
+		}
+	}
+}
+
+func sameTimeProfile(p, q lib.TimeProfile) bool {
+	if p.ID != q.ID || !p.From.Equals(q.From) || !p.To.Equals(q.To) {
+		return false
+	}
+
+	for _, d := range []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday} {
+		if p.Weekdays[d] != q.Weekdays[d] {
+			return false
+		}
+	}
+
+	for _, ix := range []uint8{1, 2, 3} {
+		if !p.Segments[ix].Start.Equals(q.Segments[ix].Start) || !p.Segments[ix].End.Equals(q.Segments[ix].End) {
+			return false
+		}
+	}
+
+	return true
 }
 
 func (l *LAN) status() types.Status {
@@ -644,14 +691,23 @@ func (l *LAN) api(controllers []types.IController) *uhppoted.UHPPOTED {
 		}
 	}
 
-	u := uhppote.NewUHPPOTE(l.BindAddress, l.BroadcastAddress, l.ListenAddress, 1*time.Second, devices, l.Debug)
 	api := uhppoted.UHPPOTED{
-		UHPPOTE: u,
+		UHPPOTE: l.uhppote(devices),
 	}
 
 	return &api
 }
 
+// Returns the UDP uhppote-core API for the LAN or the controller simulator API if running
+// in simulation mode.
+func (l *LAN) uhppote(devices []uhppote.Device) uhppote.IUHPPOTE {
+	if simulation != nil {
+		return simulation.UHPPOTE(devices)
+	}
+
+	return uhppote.NewUHPPOTE(l.BindAddress, l.BroadcastAddress, l.ListenAddress, 1*time.Second, devices, l.Debug)
+}
+
 func (l LAN) serialize() ([]byte, error) {
 	record := struct {
 		OID              schema.OID        `json:"OID"`
@@ -0,0 +1,989 @@
+package interfaces
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	lib "github.com/uhppoted/uhppote-core/types"
+
+	"github.com/uhppoted/uhppoted-lib/acl"
+	"github.com/uhppoted/uhppoted-lib/uhppoted"
+
+	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/log"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+	"github.com/uhppoted/uhppoted-httpd/system/db"
+	"github.com/uhppoted/uhppoted-httpd/types"
+)
+
+// MQTT is an interface to controllers that are only reachable through a uhppoted-mqtt
+// gateway.
+type MQTT struct {
+	catalog.CatalogInterface
+	Name     string
+	Broker   string
+	ClientID string
+	Username string
+	Password string
+	Topics   MQTTTopics
+	Debug    bool
+
+	ch       chan types.EventsList
+	created  types.Timestamp
+	modified types.Timestamp
+	deleted  types.Timestamp
+}
+
+// MQTTTopics are the uhppoted-mqtt gateway topics. Requests are published to <requests>/<method>
+// e.g. uhppoted/gateway/requests/device:get.
+type MQTTTopics struct {
+	Requests string `json:"requests,omitempty"`
+	Replies  string `json:"replies,omitempty"`
+	Events   string `json:"events,omitempty"`
+}
+
+// connection is the (shared) broker connection for an MQTT interface. Replies are matched to
+// the pending requests by request ID and events are forwarded to the events channel once the
+// interface has been started by Listen.
+type connection struct {
+	key     string
+	name    string
+	client  paho.Client
+	ready   chan struct{}
+	once    sync.Once
+	pending map[string]chan reply
+	events  chan types.EventsList
+	sync.Mutex
+}
+
+type reply struct {
+	RequestID string          `json:"request-id"`
+	Response  json.RawMessage `json:"response"`
+	Error     *struct {
+		Code    int    `json:"error-code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type request map[string]any
+
+const MQTTTimeout = 5 * time.Second
+
+const MQTTClientIDDefault = "uhppoted-httpd"
+const MQTTRequestsDefault = "uhppoted/gateway/requests"
+const MQTTRepliesDefault = "uhppoted/gateway/replies"
+const MQTTEventsDefault = "uhppoted/gateway/events"
+
+var connections = struct {
+	connections map[schema.OID]*connection
+	sync.Mutex
+}{
+	connections: map[schema.OID]*connection{},
+}
+
+var requestID atomic.Uint64
+
+func (m MQTT) String() string {
+	return fmt.Sprintf("%v", m.Name)
+}
+
+func (m MQTT) IsValid() bool {
+	return m.validate() == nil
+}
+
+func (m MQTT) validate() error {
+	if strings.TrimSpace(m.Name) == "" {
+		return fmt.Errorf("uhppoted-mqtt interface name is blank")
+	}
+
+	if _, err := parseBroker(m.Broker); err != nil {
+		return fmt.Errorf("uhppoted-mqtt interface %v: %w", m.Name, err)
+	}
+
+	return nil
+}
+
+func (m MQTT) IsDeleted() bool {
+	return !m.deleted.IsZero()
+}
+
+func (m *MQTT) AsObjects(a *auth.Authorizator) []schema.Object {
+	list := []kv{}
+
+	if m.IsDeleted() {
+		list = append(list, kv{LANDeleted, m.deleted})
+	} else {
+		list = append(list, kv{LANStatus, m.status()})
+		list = append(list, kv{LANCreated, m.created})
+		list = append(list, kv{LANDeleted, m.deleted})
+		list = append(list, kv{LANType, "MQTT"})
+		list = append(list, kv{LANName, m.Name})
+		list = append(list, kv{MQTTBroker, m.Broker})
+		list = append(list, kv{MQTTClientID, m.clientID()})
+	}
+
+	return m.toObjects(list, a)
+}
+
+func (m MQTT) AsRuleEntity() (string, any) {
+	entity := struct {
+		Type string
+		Name string
+	}{
+		Type: "MQTT",
+		Name: fmt.Sprintf("%v", m.Name),
+	}
+
+	return "mqtt", &entity
+}
+
+func (m MQTT) CacheKey() string {
+	return ""
+}
+
+func (m *MQTT) set(a *auth.Authorizator, oid schema.OID, value string, dbc db.DBC) ([]schema.Object, error) {
+	if m == nil {
+		return []schema.Object{}, nil
+	}
+
+	if m.IsDeleted() {
+		return m.toObjects([]kv{{LANDeleted, m.deleted}}, a), fmt.Errorf("uhppoted-mqtt interface has been deleted")
+	}
+
+	uid := auth.UID(a)
+	list := []kv{}
+
+	switch oid {
+	case m.OID.Append(LANName):
+		if err := CanUpdate(a, m, "name", value); err != nil {
+			return nil, err
+		} else {
+			m.log(dbc, uid, "update", m.OID, "name", m.Name, value, "Updated name from %v to %v", m.Name, value)
+
+			m.Name = value
+			m.modified = types.TimestampNow()
+
+			list = append(list, kv{LANName, m.Name})
+		}
+
+	case m.OID.Append(MQTTBroker):
+		if broker, err := parseBroker(value); err != nil {
+			return nil, err
+		} else if err := CanUpdate(a, m, "broker", broker); err != nil {
+			return nil, err
+		} else {
+			m.log(dbc, uid, "update", m.OID, "broker", m.Broker, broker, "Updated broker from %v to %v", m.Broker, broker)
+
+			m.Broker = broker
+			m.modified = types.TimestampNow()
+
+			list = append(list, kv{MQTTBroker, m.Broker})
+		}
+
+	case m.OID.Append(MQTTClientID):
+		if clientID := strings.TrimSpace(value); clientID == "" {
+			return nil, fmt.Errorf("invalid MQTT client ID (%v)", value)
+		} else if err := CanUpdate(a, m, "client-id", clientID); err != nil {
+			return nil, err
+		} else {
+			m.log(dbc, uid, "update", m.OID, "client-id", m.clientID(), clientID, "Updated client ID from %v to %v", m.clientID(), clientID)
+
+			m.ClientID = clientID
+			m.modified = types.TimestampNow()
+
+			list = append(list, kv{MQTTClientID, m.clientID()})
+		}
+	}
+
+	list = append(list, kv{LANStatus, m.status()})
+
+	return m.toObjects(list, a), nil
+}
+
+func (m MQTT) toObjects(list []kv, a *auth.Authorizator) []schema.Object {
+	objects := []schema.Object{}
+
+	if err := CanView(a, m, "OID", m.OID); err == nil && !m.IsDeleted() {
+		objects = append(objects, catalog.NewObject(m.OID, ""))
+	}
+
+	for _, v := range list {
+		field := lookup[v.field]
+		if err := CanView(a, m, field, v.value); err == nil {
+			objects = append(objects, catalog.NewObject2(m.OID, v.field, v.value))
+		}
+	}
+
+	return objects
+}
+
+func (m MQTT) Clone() MQTT {
+	return MQTT{
+		CatalogInterface: catalog.CatalogInterface{
+			OID: m.OID,
+		},
+		Name:     m.Name,
+		Broker:   m.Broker,
+		ClientID: m.ClientID,
+		Username: m.Username,
+		Password: m.Password,
+		Topics:   m.Topics,
+		Debug:    m.Debug,
+
+		ch:       m.ch,
+		created:  m.created,
+		modified: m.modified,
+		deleted:  m.deleted,
+	}
+}
+
+func (m *MQTT) search(controllers []types.IController) ([]uint32, error) {
+	list := []uint32{}
+
+	response := struct {
+		Devices map[string]json.RawMessage `json:"devices"`
+	}{}
+
+	if err := m.call("devices:get", request{}, &response); err != nil {
+		return list, err
+	}
+
+	for k := range response.Devices {
+		if v, err := strconv.ParseUint(k, 10, 32); err != nil {
+			log.Warnf("%v  invalid device ID (%v)", m.Name, k)
+		} else {
+			list = append(list, uint32(v))
+		}
+	}
+
+	return list, nil
+}
+
+// A long-running function i.e. expects to be invoked from an external goroutine
+func (m *MQTT) refresh(c types.IController) {
+	log.Infof("%v: refreshing uhppoted-mqtt controller status", c.ID())
+
+	deviceID := c.ID()
+
+	device := struct {
+		DeviceID  uint32     `json:"device-id"`
+		IpAddress net.IP     `json:"ip-address"`
+		Address   netip.Addr `json:"address"`
+	}{}
+
+	if err := m.call("device:get", request{"device-id": deviceID}, &device); err != nil {
+		log.Warnf("%v", err)
+	} else {
+		addr := device.Address
+		if !addr.IsValid() {
+			addr, _ = netip.AddrFromSlice(device.IpAddress.To4())
+		}
+
+		catalog.PutV(c.OID(), ControllerTouched, time.Now())
+		if addr.IsValid() {
+			catalog.PutV(c.OID(), ControllerEndpointAddress, lib.ControllerAddrFrom(addr, 60000))
+		}
+	}
+
+	if status, err := m.getStatus(deviceID); err != nil {
+		log.Warnf("%v", err)
+	} else {
+		catalog.PutV(c.OID(), ControllerTouched, time.Now())
+		catalog.PutV(c.OID(), ControllerDateTimeCurrent, status.SystemDateTime)
+
+		putDoorStates(status, c.Door)
+	}
+
+	cards := struct {
+		Cards []uint32 `json:"cards"`
+	}{}
+
+	if err := m.call("device:cards:get", request{"device-id": deviceID}, &cards); err != nil {
+		log.Warnf("%v", err)
+	} else {
+		catalog.PutV(c.OID(), ControllerTouched, time.Now())
+		catalog.PutV(c.OID(), ControllerCardsCount, uint32(len(cards.Cards)))
+	}
+
+	for _, d := range []uint8{1, 2, 3, 4} {
+		delay := struct {
+			Delay uint8 `json:"delay"`
+		}{}
+
+		if err := m.call("device:door:delay:get", request{"device-id": deviceID, "door": d}, &delay); err != nil {
+			log.Warnf("%v", err)
+		} else if door, ok := c.Door(d); ok {
+			catalog.PutV(door, DoorDelay, delay.Delay)
+		}
+	}
+
+	for _, d := range []uint8{1, 2, 3, 4} {
+		control := struct {
+			Control lib.ControlState `json:"control"`
+		}{}
+
+		if err := m.call("device:door:control:get", request{"device-id": deviceID, "door": d}, &control); err != nil {
+			log.Warnf("%v", err)
+		} else if door, ok := c.Door(d); ok {
+			catalog.PutV(door, DoorControl, control.Control)
+		}
+	}
+}
+
+func (m *MQTT) getEvents(c types.IController, intervals []types.Interval) {
+	deviceID := c.ID()
+	oid := c.OID()
+
+	log.Infof("%v: retrieving uhppoted-mqtt controller events (%v)", deviceID, intervals)
+
+	indices := struct {
+		Events struct {
+			First   uint32 `json:"first"`
+			Last    uint32 `json:"last"`
+			Current uint32 `json:"current"`
+		} `json:"events"`
+	}{}
+
+	if err := m.call("device:events:get", request{"device-id": deviceID}, &indices); err != nil {
+		log.Warnf("%v", err)
+		return
+	}
+
+	first := indices.Events.First
+	last := indices.Events.Last
+
+	catalog.PutV(oid, ControllerTouched, time.Now())
+	catalog.PutV(oid, ControllerEventsFirst, first)
+	catalog.PutV(oid, ControllerEventsLast, last)
+	catalog.PutV(oid, ControllerEventsCurrent, indices.Events.Current)
+	catalog.PutV(oid, ControllerEventsStatus, eventsStatus(intervals, first, last))
+
+	events := []uhppoted.Event{}
+
+	retrieve(intervals, first, last, func(index uint32) {
+		response := struct {
+			Event *uhppoted.Event `json:"event"`
+		}{}
+
+		if err := m.call("device:event:get", request{"device-id": deviceID, "event-index": index}, &response); err != nil {
+			log.Warnf("%v", err)
+		} else if response.Event == nil {
+			log.Warnf("%v: missing event %v", deviceID, index)
+			events = append(events, uhppoted.Event{
+				DeviceID: deviceID,
+				Index:    index,
+			})
+		} else {
+			e := *response.Event
+			e.DeviceID = deviceID
+
+			events = append(events, e)
+		}
+	})
+
+	if m.ch != nil {
+		m.ch <- types.EventsList{
+			DeviceID: deviceID,
+			Events:   events,
+		}
+	}
+}
+
+func (m *MQTT) setTime(c types.IController, t time.Time) {
+	lock(c.ID())
+	defer unlock(c.ID())
+
+	deviceID := c.ID()
+	datetime := lib.DateTime(t.In(c.TimeZone()))
+
+	if err := m.call("device:time:set", request{"device-id": deviceID, "date-time": datetime}, nil); err != nil {
+		log.Warnf("%v", err)
+	} else {
+		catalog.PutV(c.OID(), ControllerDateTimeModified, false)
+
+		if status, err := m.getStatus(deviceID); err != nil {
+			log.Warnf("%v", err)
+		} else {
+			catalog.PutV(c.OID(), ControllerDateTimeCurrent, status.SystemDateTime)
+		}
+
+		log.Infof("%v  set date/time: %v", deviceID, datetime)
+	}
+}
+
+func (m *MQTT) setDoor(c types.IController, door uint8, mode lib.ControlState, delay uint8) error {
+	lock(c.ID())
+	defer unlock(c.ID())
+
+	deviceID := c.ID()
+
+	if mode != lib.ModeUnknown {
+		if err := m.call("device:door:control:set", request{"device-id": deviceID, "door": door, "control": mode}, nil); err != nil {
+			return err
+		}
+	}
+
+	if delay != 0 {
+		if err := m.call("device:door:delay:set", request{"device-id": deviceID, "door": door, "delay": delay}, nil); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("%v  set door %v mode:%-15v delay:%vs", deviceID, door, mode, delay)
+
+	return nil
+}
+
+func (m *MQTT) openDoor(c types.IController, door uint8) error {
+	return m.unsupported(c, "open door")
+}
+
+func (m *MQTT) setInterlock(c types.IController, interlock lib.Interlock) error {
+	return m.unsupported(c, "set interlock")
+}
+
+func (m *MQTT) setAntiPassback(c types.IController, antipassback lib.AntiPassback) error {
+	return m.unsupported(c, "set anti-passback")
+}
+
+func (m *MQTT) activateKeypads(c types.IController, keypads map[uint8]bool) error {
+	return m.unsupported(c, "activate keypads")
+}
+
+func (m *MQTT) setDoorPasscodes(c types.IController, door uint8, passcodes ...uint32) error {
+	return m.unsupported(c, "set door passcodes")
+}
+
+func (m *MQTT) putCard(c types.IController, cardID uint32, PIN uint32, from, to lib.Date, permissions map[uint8]uint8) {
+	lock(c.ID())
+	defer unlock(c.ID())
+
+	deviceID := c.ID()
+
+	card := lib.Card{
+		CardNumber: cardID,
+		PIN:        lib.PIN(PIN),
+		From:       from,
+		To:         to,
+		Doors: map[uint8]uint8{
+			1: permissions[1],
+			2: permissions[2],
+			3: permissions[3],
+			4: permissions[4],
+		},
+	}
+
+	if err := m.call("device:card:put", request{"device-id": deviceID, "card": card}, nil); err != nil {
+		log.Warnf("%v", err)
+	} else {
+		log.Infof("%v  put card %v", deviceID, card)
+	}
+}
+
+func (m *MQTT) deleteCard(c types.IController, card uint32) {
+	lock(c.ID())
+	defer unlock(c.ID())
+
+	deviceID := c.ID()
+
+	if err := m.call("device:card:delete", request{"device-id": deviceID, "card-number": card}, nil); err != nil {
+		log.Warnf("%v", err)
+	} else {
+		log.Infof("%v  deleted card %v", deviceID, card)
+	}
+}
+
+func (m *MQTT) putTimeProfile(c types.IController, profile lib.TimeProfile) error {
+	lock(c.ID())
+	defer unlock(c.ID())
+
+	return m.call("device:time-profile:set", request{"device-id": c.ID(), "profile": profile}, nil)
+}
+
+func (m *MQTT) compareACL(controllers []types.IController, permissions acl.ACL, profiles []lib.TimeProfile, withPIN bool) (map[uint32]acl.Diff, map[uint32][]uint8, error) {
+	log.Debugf("Comparing uhppoted-mqtt ACL (with-pin:%v)", withPIN)
+
+	current := acl.ACL{}
+	for _, c := range controllers {
+		if cards, err := m.getCards(c.ID()); err != nil {
+			log.Warnf("%v", err)
+		} else {
+			current[c.ID()] = cards
+		}
+	}
+
+	f := func(permissions, current acl.ACL) (map[uint32]acl.Diff, error) {
+		if withPIN {
+			return acl.CompareWithPIN(permissions, current)
+		} else {
+			return acl.Compare(permissions, current)
+		}
+	}
+
+	compare, err := f(permissions, current)
+	if err != nil {
+		return nil, nil, err
+	} else if compare == nil {
+		return nil, nil, fmt.Errorf("invalid ACL compare report: %v", compare)
+	}
+
+	for k, v := range compare {
+		log.Infof("ACL %v  unchanged:%-3v updated:%-3v added:%-3v deleted:%-3v", k, len(v.Unchanged), len(v.Updated), len(v.Added), len(v.Deleted))
+	}
+
+	mismatched := map[uint32][]uint8{}
+	for _, c := range controllers {
+		deviceID := c.ID()
+		if _, ok := current[deviceID]; !ok {
+			continue
+		}
+
+		for _, p := range profiles {
+			response := struct {
+				TimeProfile *lib.TimeProfile `json:"time-profile"`
+			}{}
+
+			if err := m.call("device:time-profile:get", request{"device-id": deviceID, "profile-id": p.ID}, &response); err != nil {
+				log.Warnf("%v", err)
+				mismatched[deviceID] = append(mismatched[deviceID], p.ID)
+			} else if response.TimeProfile == nil || !sameTimeProfile(p, *response.TimeProfile) {
+				mismatched[deviceID] = append(mismatched[deviceID], p.ID)
+			}
+		}
+
+		if len(mismatched[deviceID]) > 0 {
+			log.Infof("ACL %v  time profiles out of synch:%v", deviceID, mismatched[deviceID])
+		}
+
+		rs := compare[deviceID]
+		if len(rs.Updated)+len(rs.Added)+len(rs.Deleted)+len(mismatched[deviceID]) > 0 {
+			catalog.PutV(c.OID(), ControllerCardsStatus, types.StatusError)
+		} else {
+			catalog.PutV(c.OID(), ControllerCardsStatus, types.StatusOk)
+		}
+	}
+
+	return compare, mismatched, nil
+}
+
+func (m *MQTT) getStatus(deviceID uint32) (*lib.Status, error) {
+	response := struct {
+		Status struct {
+			DoorState      map[uint8]bool `json:"door-states"`
+			DoorButton     map[uint8]bool `json:"door-buttons"`
+			SystemDateTime lib.DateTime   `json:"system-datetime"`
+			RelayState     uint8          `json:"relay-state"`
+		} `json:"status"`
+	}{}
+
+	if err := m.call("device:status:get", request{"device-id": deviceID}, &response); err != nil {
+		return nil, err
+	}
+
+	return &lib.Status{
+		SerialNumber:   lib.SerialNumber(deviceID),
+		DoorState:      response.Status.DoorState,
+		DoorButton:     response.Status.DoorButton,
+		SystemDateTime: response.Status.SystemDateTime,
+		RelayState:     response.Status.RelayState,
+	}, nil
+}
+
+func (m *MQTT) getCards(deviceID uint32) (map[uint32]lib.Card, error) {
+	list := struct {
+		Cards []uint32 `json:"cards"`
+	}{}
+
+	if err := m.call("device:cards:get", request{"device-id": deviceID}, &list); err != nil {
+		return nil, err
+	}
+
+	cards := map[uint32]lib.Card{}
+	for _, v := range list.Cards {
+		response := struct {
+			Card *lib.Card `json:"card"`
+		}{}
+
+		if err := m.call("device:card:get", request{"device-id": deviceID, "card-number": v}, &response); err != nil {
+			return nil, err
+		} else if response.Card == nil {
+			return nil, fmt.Errorf("%v  invalid response to get-card %v", deviceID, v)
+		} else {
+			cards[v] = *response.Card
+		}
+	}
+
+	return cards, nil
+}
+
+func (m *MQTT) unsupported(c types.IController, operation string) error {
+	return fmt.Errorf("%v  %v not supported by uhppoted-mqtt interface %v", c.ID(), operation, m.Name)
+}
+
+func (m *MQTT) status() types.Status {
+	return types.StatusOk
+}
+
+func (m MQTT) clientID() string {
+	if m.ClientID != "" {
+		return m.ClientID
+	}
+
+	return MQTTClientIDDefault
+}
+
+func (m MQTT) topics() MQTTTopics {
+	topics := MQTTTopics{
+		Requests: MQTTRequestsDefault,
+		Replies:  MQTTRepliesDefault,
+		Events:   MQTTEventsDefault,
+	}
+
+	if m.Topics.Requests != "" {
+		topics.Requests = m.Topics.Requests
+	}
+
+	if m.Topics.Replies != "" {
+		topics.Replies = m.Topics.Replies
+	}
+
+	if m.Topics.Events != "" {
+		topics.Events = m.Topics.Events
+	}
+
+	return topics
+}
+
+// Publishes a uhppoted-mqtt request to <requests>/<method> and waits for the reply with the
+// matching request ID.
+func (m *MQTT) call(method string, rq request, response any) error {
+	c, err := m.connect()
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-c.ready:
+	case <-time.After(MQTTTimeout):
+	}
+
+	if !c.client.IsConnectionOpen() {
+		return fmt.Errorf("%v: not connected to MQTT broker %v", m.Name, m.Broker)
+	}
+
+	id := fmt.Sprintf("%v", requestID.Add(1))
+	topics := m.topics()
+	topic := topics.Requests + "/" + method
+	replies := make(chan reply, 1)
+
+	c.Lock()
+	c.pending[id] = replies
+	c.Unlock()
+
+	defer func() {
+		c.Lock()
+		delete(c.pending, id)
+		c.Unlock()
+	}()
+
+	rq["client-id"] = m.clientID()
+	rq["request-id"] = id
+	rq["reply-to"] = topics.Replies
+
+	message := struct {
+		Message struct {
+			Request request `json:"request"`
+		} `json:"message"`
+	}{}
+
+	message.Message.Request = rq
+
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	if m.Debug {
+		log.Debugf("%v  %v %s", m.Name, topic, payload)
+	}
+
+	if token := c.client.Publish(topic, 0, false, payload); !token.WaitTimeout(MQTTTimeout) {
+		return fmt.Errorf("%v %v: timeout publishing request", m.Name, method)
+	} else if err := token.Error(); err != nil {
+		return fmt.Errorf("%v %v: %v", m.Name, method, err)
+	}
+
+	select {
+	case r := <-replies:
+		if r.Error != nil {
+			return fmt.Errorf("%v %v: %v (%v)", m.Name, method, r.Error.Message, r.Error.Code)
+		}
+
+		if response != nil {
+			if err := json.Unmarshal(r.Response, response); err != nil {
+				return fmt.Errorf("%v %v: invalid response (%v)", m.Name, method, err)
+			}
+		}
+
+		return nil
+
+	case <-time.After(MQTTTimeout):
+		return fmt.Errorf("%v %v: timeout waiting for reply", m.Name, method)
+	}
+}
+
+// Returns the broker connection for the interface, (re)connecting if the connection settings
+// have changed. The connection is retried in the background until the broker is reachable.
+func (m *MQTT) connect() (*connection, error) {
+	if _, err := parseBroker(m.Broker); err != nil {
+		return nil, err
+	}
+
+	topics := m.topics()
+	key := strings.Join([]string{m.Broker, m.clientID(), m.Username, m.Password, topics.Replies, topics.Events}, "|")
+
+	connections.Lock()
+	defer connections.Unlock()
+
+	if c, ok := connections.connections[m.OID]; ok && c.key == key {
+		return c, nil
+	} else if ok {
+		c.client.Disconnect(250)
+		delete(connections.connections, m.OID)
+	}
+
+	c := &connection{
+		key:     key,
+		name:    m.Name,
+		ready:   make(chan struct{}),
+		pending: map[string]chan reply{},
+	}
+
+	options := paho.NewClientOptions().
+		AddBroker(m.Broker).
+		SetClientID(m.clientID()).
+		SetUsername(m.Username).
+		SetPassword(m.Password).
+		SetCleanSession(true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(RETRY).
+		SetConnectTimeout(MQTTTimeout).
+		SetOnConnectHandler(func(client paho.Client) {
+			c.subscribe(client, topics)
+		}).
+		SetConnectionLostHandler(func(client paho.Client, err error) {
+			log.Warnf("%v  lost connection to MQTT broker %v (%v)", m.Name, m.Broker, err)
+		})
+
+	c.client = paho.NewClient(options)
+	c.client.Connect()
+
+	connections.connections[m.OID] = c
+
+	return c, nil
+}
+
+// Starts forwarding the events published by the uhppoted-mqtt gateway to the events channel.
+func (m *MQTT) listen(ch chan types.EventsList) error {
+	c, err := m.connect()
+	if err != nil {
+		return err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	c.events = ch
+
+	return nil
+}
+
+// Closes the broker connection for the interface (if any).
+func (m *MQTT) close() {
+	disconnect(m.OID)
+}
+
+func disconnect(oid schema.OID) {
+	connections.Lock()
+	defer connections.Unlock()
+
+	if c, ok := connections.connections[oid]; ok {
+		c.client.Disconnect(250)
+		delete(connections.connections, oid)
+	}
+}
+
+func (c *connection) subscribe(client paho.Client, topics MQTTTopics) {
+	if token := client.Subscribe(topics.Replies, 0, c.onReply); token.WaitTimeout(MQTTTimeout) && token.Error() != nil {
+		log.Warnf("%v  error subscribing to %v (%v)", c.name, topics.Replies, token.Error())
+		return
+	}
+
+	if token := client.Subscribe(topics.Events, 0, c.onEvent); token.WaitTimeout(MQTTTimeout) && token.Error() != nil {
+		log.Warnf("%v  error subscribing to %v (%v)", c.name, topics.Events, token.Error())
+		return
+	}
+
+	log.Infof("%v  listening for events on %v", c.name, topics.Events)
+
+	c.once.Do(func() {
+		close(c.ready)
+	})
+}
+
+func (c *connection) onReply(client paho.Client, msg paho.Message) {
+	message := struct {
+		Message struct {
+			Reply reply `json:"reply"`
+		} `json:"message"`
+	}{}
+
+	if err := json.Unmarshal(msg.Payload(), &message); err != nil {
+		log.Warnf("%v  invalid reply (%v)", c.name, err)
+		return
+	}
+
+	c.Lock()
+	ch, ok := c.pending[message.Message.Reply.RequestID]
+	c.Unlock()
+
+	if ok {
+		select {
+		case ch <- message.Message.Reply:
+		default:
+		}
+	}
+}
+
+func (c *connection) onEvent(client paho.Client, msg paho.Message) {
+	message := struct {
+		Message struct {
+			Event *uhppoted.Event `json:"event"`
+		} `json:"message"`
+	}{}
+
+	if err := json.Unmarshal(msg.Payload(), &message); err != nil {
+		log.Warnf("%v  invalid event (%v)", c.name, err)
+		return
+	} else if message.Message.Event == nil || message.Message.Event.IsZero() {
+		return
+	}
+
+	c.Lock()
+	ch := c.events
+	c.Unlock()
+
+	if ch == nil {
+		return
+	}
+
+	event := *message.Message.Event
+	oid := catalog.FindController(event.DeviceID)
+
+	// NTS: discard events from controllers that are not in the system (as for the LAN listener)
+	if oid == "" {
+		log.Debugf("%v  ignoring event from unknown controller %v", c.name, event.DeviceID)
+		return
+	}
+
+	catalog.PutV(oid, ControllerTouched, time.Now())
+
+	received(oid, event, ch)
+}
+
+func (m MQTT) serialize() ([]byte, error) {
+	record := struct {
+		OID      schema.OID      `json:"OID"`
+		Type     string          `json:"type"`
+		Name     string          `json:"name,omitempty"`
+		Broker   string          `json:"broker"`
+		ClientID string          `json:"client-id,omitempty"`
+		Username string          `json:"username,omitempty"`
+		Password string          `json:"password,omitempty"`
+		Topics   *MQTTTopics     `json:"topics,omitempty"`
+		Created  types.Timestamp `json:"created"`
+		Modified types.Timestamp `json:"modified"`
+	}{
+		OID:      m.OID,
+		Type:     "MQTT",
+		Name:     m.Name,
+		Broker:   m.Broker,
+		ClientID: m.ClientID,
+		Username: m.Username,
+		Password: m.Password,
+		Created:  m.created.UTC(),
+		Modified: m.modified.UTC(),
+	}
+
+	if m.Topics != (MQTTTopics{}) {
+		record.Topics = &m.Topics
+	}
+
+	return json.MarshalIndent(record, "", "  ")
+}
+
+func (m *MQTT) deserialize(bytes []byte) error {
+	created = created.Add(1 * time.Minute)
+
+	record := struct {
+		OID      schema.OID      `json:"OID"`
+		Type     string          `json:"type"`
+		Name     string          `json:"name,omitempty"`
+		Broker   string          `json:"broker"`
+		ClientID string          `json:"client-id,omitempty"`
+		Username string          `json:"username,omitempty"`
+		Password string          `json:"password,omitempty"`
+		Topics   MQTTTopics      `json:"topics"`
+		Created  types.Timestamp `json:"created"`
+		Modified types.Timestamp `json:"modified"`
+	}{
+		Created: created,
+	}
+
+	if err := json.Unmarshal(bytes, &record); err != nil {
+		return err
+	}
+
+	m.OID = record.OID
+	m.Name = record.Name
+	m.Broker = record.Broker
+	m.ClientID = record.ClientID
+	m.Username = record.Username
+	m.Password = record.Password
+	m.Topics = record.Topics
+	m.created = record.Created
+	m.modified = record.Modified
+
+	return nil
+}
+
+func (m *MQTT) log(dbc db.DBC, uid string, op string, OID schema.OID, field string, before, after any, format string, fields ...any) {
+	dbc.Log(uid, op, OID, "interface", "MQTT", m.Name, field, before, after, format, fields...)
+}
+
+// Validates an MQTT broker URL e.g. tcp://192.168.1.100:1883.
+func parseBroker(s string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return "", fmt.Errorf("invalid MQTT broker (%v)", s)
+	}
+
+	if !slices.Contains([]string{"tcp", "mqtt", "ssl", "tls", "mqtts", "ws", "wss"}, u.Scheme) || u.Host == "" {
+		return "", fmt.Errorf("invalid MQTT broker (%v)", s)
+	}
+
+	return u.String(), nil
+}
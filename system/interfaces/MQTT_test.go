@@ -0,0 +1,462 @@
+package interfaces
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	mlisteners "github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+
+	core "github.com/uhppoted/uhppote-core/types"
+	"github.com/uhppoted/uhppoted-lib/acl"
+	"github.com/uhppoted/uhppoted-lib/uhppoted"
+
+	"github.com/uhppoted/uhppoted-httpd/system/catalog"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/impl"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+	"github.com/uhppoted/uhppoted-httpd/types"
+)
+
+type broker struct {
+	*mochi.Server
+	url string
+}
+
+// Starts an embedded MQTT broker with a minimal uhppoted-mqtt gateway stub for controller
+// 405419896 with cards 10058400 and 10058401.
+func mqttStub(t *testing.T, put func(method string, request map[string]any)) broker {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error allocating TCP port (%v)", err)
+	}
+
+	address := l.Addr().String()
+	l.Close()
+
+	server := mochi.New(&mochi.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+
+	server.AddHook(new(auth.AllowHook), nil)
+
+	if err := server.AddListener(mlisteners.NewTCP(mlisteners.Config{ID: "test", Address: address})); err != nil {
+		t.Fatalf("Error starting MQTT broker (%v)", err)
+	}
+
+	go server.Serve()
+
+	responses := map[string]func(rq map[string]any) any{
+		"devices:get": func(rq map[string]any) any {
+			return map[string]any{
+				"devices": map[string]any{
+					"405419896": map[string]any{"device-type": "UTO311-L04"},
+					"303986753": map[string]any{"device-type": "UTO311-L02"},
+				},
+			}
+		},
+
+		"device:events:get": func(rq map[string]any) any {
+			return map[string]any{
+				"device-id": 405419896,
+				"events":    map[string]any{"first": 1, "last": 69, "current": 69},
+			}
+		},
+
+		"device:event:get": func(rq map[string]any) any {
+			return map[string]any{
+				"device-id": 405419896,
+				"event": map[string]any{
+					"event-id":       rq["event-index"],
+					"event-type":     1,
+					"access-granted": true,
+					"door-id":        3,
+					"direction":      1,
+					"card-number":    10058400,
+					"timestamp":      "2024-11-26 12:34:56",
+					"event-reason":   1,
+				},
+			}
+		},
+
+		"device:cards:get": func(rq map[string]any) any {
+			return map[string]any{
+				"device-id": 405419896,
+				"cards":     []uint32{10058400, 10058401},
+			}
+		},
+
+		"device:card:get": func(rq map[string]any) any {
+			return map[string]any{
+				"device-id": 405419896,
+				"card": map[string]any{
+					"card-number": rq["card-number"],
+					"start-date":  "2024-01-01",
+					"end-date":    "2024-12-31",
+					"doors":       map[string]any{"1": 1, "2": 0, "3": 0, "4": 0},
+				},
+			}
+		},
+	}
+
+	handler := func(cl *mochi.Client, sub packets.Subscription, pk packets.Packet) {
+		method := strings.TrimPrefix(pk.TopicName, MQTTRequestsDefault+"/")
+		message := struct {
+			Message struct {
+				Request map[string]any `json:"request"`
+			} `json:"message"`
+		}{}
+
+		if err := json.Unmarshal(pk.Payload, &message); err != nil {
+			return
+		}
+
+		rq := message.Message.Request
+		reply := map[string]any{
+			"server-id":  "uhppoted",
+			"method":     method,
+			"request-id": rq["request-id"],
+			"client-id":  rq["client-id"],
+		}
+
+		if f, ok := responses[method]; ok {
+			reply["response"] = f(rq)
+		} else if strings.HasSuffix(method, ":set") || strings.HasSuffix(method, ":put") || strings.HasSuffix(method, ":delete") {
+			if put != nil {
+				put(method, rq)
+			}
+
+			reply["response"] = map[string]any{"device-id": rq["device-id"]}
+		} else {
+			reply["error"] = map[string]any{"error-code": 404, "message": "not found"}
+		}
+
+		bytes, _ := json.Marshal(map[string]any{"message": map[string]any{"reply": reply}})
+		topic := rq["reply-to"].(string)
+
+		go server.Publish(topic, bytes, false, 0)
+	}
+
+	if err := server.Subscribe(MQTTRequestsDefault+"/#", 1, handler); err != nil {
+		t.Fatalf("Error subscribing to requests (%v)", err)
+	}
+
+	return broker{
+		Server: server,
+		url:    "tcp://" + address,
+	}
+}
+
+func TestMQTTAsObjects(t *testing.T) {
+	created = types.Timestamp(time.Date(2021, time.February, 28, 12, 34, 56, 0, time.Local))
+
+	m := MQTT{
+		CatalogInterface: catalog.CatalogInterface{
+			OID: "0.1.6",
+		},
+		Name:   "Le MQTT",
+		Broker: "tcp://127.0.0.1:1883",
+
+		created: created,
+	}
+
+	expected := []schema.Object{
+		{OID: "0.1.6", Value: ""},
+		{OID: "0.1.6.0.0", Value: types.StatusOk},
+		{OID: "0.1.6.0.1", Value: created},
+		{OID: "0.1.6.0.2", Value: types.Timestamp{}},
+		{OID: "0.1.6.0.4", Value: "MQTT"},
+		{OID: "0.1.6.1", Value: "Le MQTT"},
+		{OID: "0.1.6.5.1", Value: "tcp://127.0.0.1:1883"},
+		{OID: "0.1.6.5.2", Value: "uhppoted-httpd"},
+	}
+
+	objects := m.AsObjects(nil)
+
+	if !reflect.DeepEqual(objects, expected) {
+		t.Errorf("Incorrect return from AsObjects:\n   expected:%#v\n   got:     %#v", expected, objects)
+	}
+}
+
+func TestMQTTSearch(t *testing.T) {
+	server := mqttStub(t, nil)
+	defer server.Close()
+
+	m := MQTT{CatalogInterface: catalog.CatalogInterface{OID: "0.1.11"}, Name: "MQTT", Broker: server.url, ClientID: "test-search"}
+	defer m.close()
+
+	list, err := m.search(nil)
+	if err != nil {
+		t.Fatalf("Unexpected error (%v)", err)
+	}
+
+	slices.Sort(list)
+	if !slices.Equal(list, []uint32{303986753, 405419896}) {
+		t.Errorf("Incorrect search result - expected:%v, got:%v", []uint32{303986753, 405419896}, list)
+	}
+}
+
+func TestMQTTGetEvents(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+
+	server := mqttStub(t, nil)
+	defer server.Close()
+
+	ch := make(chan types.EventsList, 1)
+	m := MQTT{CatalogInterface: catalog.CatalogInterface{OID: "0.1.12"}, Name: "MQTT", Broker: server.url, ClientID: "test-events", ch: ch}
+	c := controller{oid: "0.2.11", id: 405419896}
+
+	defer m.close()
+
+	m.getEvents(c, []types.Interval{{From: 67, To: 100}})
+
+	list := <-ch
+	if list.DeviceID != 405419896 {
+		t.Errorf("Incorrect events device ID - expected:%v, got:%v", 405419896, list.DeviceID)
+	}
+
+	if len(list.Events) != 3 {
+		t.Fatalf("Incorrect number of events - expected:%v, got:%v", 3, len(list.Events))
+	}
+
+	for i, e := range list.Events {
+		if e.DeviceID != 405419896 || e.Index != uint32(67+i) || e.CardNumber != 10058400 || e.Door != 3 || !e.Granted {
+			t.Errorf("Incorrect event %v - got:%+v", i, e)
+		}
+	}
+
+	if status := catalog.GetV("0.2.11", ControllerEventsStatus); status != types.StatusIncomplete {
+		t.Errorf("Incorrect events status - expected:%v, got:%v", types.StatusIncomplete, status)
+	}
+}
+
+func TestMQTTPutCard(t *testing.T) {
+	var mutex sync.Mutex
+	var method string
+	var request map[string]any
+
+	server := mqttStub(t, func(m string, rq map[string]any) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		method = m
+		request = rq
+	})
+
+	defer server.Close()
+
+	m := MQTT{CatalogInterface: catalog.CatalogInterface{OID: "0.1.13"}, Name: "MQTT", Broker: server.url, ClientID: "test-put-card"}
+	c := controller{oid: "0.2.11", id: 405419896}
+
+	defer m.close()
+
+	from := core.MustParseDate("2024-01-01")
+	to := core.MustParseDate("2024-12-31")
+
+	m.putCard(c, 10058400, 0, from, to, map[uint8]uint8{1: 1, 3: 29})
+
+	expected := map[string]any{
+		"card-number": float64(10058400),
+		"start-date":  "2024-01-01",
+		"end-date":    "2024-12-31",
+		"doors":       map[string]any{"1": float64(1), "2": float64(0), "3": float64(29), "4": float64(0)},
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if method != "device:card:put" {
+		t.Errorf("Incorrect put-card method - expected:%v, got:%v", "device:card:put", method)
+	}
+
+	if request["device-id"] != float64(405419896) {
+		t.Errorf("Incorrect put-card device ID - expected:%v, got:%v", 405419896, request["device-id"])
+	}
+
+	if !reflect.DeepEqual(request["card"], expected) {
+		t.Errorf("Incorrect put-card request\n   expected:%v\n   got:     %v", expected, request["card"])
+	}
+}
+
+func TestMQTTCompareACL(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+
+	server := mqttStub(t, nil)
+	defer server.Close()
+
+	m := MQTT{CatalogInterface: catalog.CatalogInterface{OID: "0.1.14"}, Name: "MQTT", Broker: server.url, ClientID: "test-compare-acl"}
+	c := controller{oid: "0.2.11", id: 405419896}
+
+	defer m.close()
+
+	from := core.MustParseDate("2024-01-01")
+	to := core.MustParseDate("2024-12-31")
+
+	permissions := acl.ACL{
+		405419896: map[uint32]core.Card{
+			10058400: {CardNumber: 10058400, From: from, To: to, Doors: map[uint8]uint8{1: 1, 2: 0, 3: 0, 4: 0}},
+			10058402: {CardNumber: 10058402, From: from, To: to, Doors: map[uint8]uint8{1: 1, 2: 0, 3: 0, 4: 0}},
+		},
+	}
+
+	diff, _, err := m.compareACL([]types.IController{c}, permissions, nil, false)
+	if err != nil {
+		t.Fatalf("Unexpected error (%v)", err)
+	}
+
+	d := diff[405419896]
+	if len(d.Unchanged) != 1 || len(d.Added) != 1 || len(d.Deleted) != 1 || len(d.Updated) != 0 {
+		t.Errorf("Incorrect ACL diff - got unchanged:%v updated:%v added:%v deleted:%v", len(d.Unchanged), len(d.Updated), len(d.Added), len(d.Deleted))
+	}
+
+	if status := catalog.GetV("0.2.11", ControllerCardsStatus); status != types.StatusError {
+		t.Errorf("Incorrect cards status - expected:%v, got:%v", types.StatusError, status)
+	}
+}
+
+func TestMQTTListen(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+	catalog.PutT(catalog.CatalogController{OID: "0.2.12", DeviceID: 405419896})
+
+	server := mqttStub(t, nil)
+	defer server.Close()
+
+	ch := make(chan types.EventsList, 1)
+	m := MQTT{CatalogInterface: catalog.CatalogInterface{OID: "0.1.15"}, Name: "MQTT", Broker: server.url, ClientID: "test-listen"}
+
+	defer m.close()
+
+	if err := m.listen(ch); err != nil {
+		t.Fatalf("Unexpected error (%v)", err)
+	}
+
+	c, _ := m.connect()
+	select {
+	case <-c.ready:
+	case <-time.After(MQTTTimeout):
+		t.Fatalf("Timeout connecting to MQTT broker")
+	}
+
+	event := []byte(`{
+	  "message": {
+	    "event": {
+	      "device-id": 405419896,
+	      "event-id": 71,
+	      "event-type": 1,
+	      "access-granted": true,
+	      "door-id": 3,
+	      "direction": 1,
+	      "card-number": 10058400,
+	      "timestamp": "2024-11-26 12:34:56",
+	      "event-reason": 1
+	    }
+	  }
+	}`)
+
+	if err := server.Publish(MQTTEventsDefault, event, false, 0); err != nil {
+		t.Fatalf("Error publishing test event (%v)", err)
+	}
+
+	timestamp, _ := time.ParseInLocation("2006-01-02 15:04:05", "2024-11-26 12:34:56", time.Local)
+	expected := types.EventsList{
+		DeviceID: 405419896,
+		Events: []uhppoted.Event{
+			{
+				DeviceID:   405419896,
+				Index:      71,
+				Type:       1,
+				Granted:    true,
+				Door:       3,
+				Direction:  1,
+				CardNumber: 10058400,
+				Timestamp:  core.DateTime(timestamp),
+				Reason:     1,
+			},
+		},
+	}
+
+	select {
+	case events := <-ch:
+		if !reflect.DeepEqual(events, expected) {
+			t.Errorf("Incorrect event\n   expected:%#v\n   got:     %#v", expected, events)
+		}
+
+	case <-time.After(1 * time.Second):
+		t.Fatalf("Timeout waiting for event")
+	}
+
+	if last := catalog.GetV("0.2.12", ControllerEventsLast); last != uint32(71) {
+		t.Errorf("Incorrect last event index - expected:%v, got:%v", 71, last)
+	}
+}
+
+func TestMQTTRoute(t *testing.T) {
+	ii := Interfaces{
+		lans: map[schema.OID]*LAN{
+			"0.1.1": {CatalogInterface: catalog.CatalogInterface{OID: "0.1.1"}, Name: "LAN"},
+		},
+		rests: map[schema.OID]*REST{
+			"0.1.2": {CatalogInterface: catalog.CatalogInterface{OID: "0.1.2"}, Name: "REST", URL: "http://127.0.0.1:8080"},
+		},
+		mqtts: map[schema.OID]*MQTT{
+			"0.1.3": {CatalogInterface: catalog.CatalogInterface{OID: "0.1.3"}, Name: "MQTT", Broker: "tcp://127.0.0.1:1883"},
+		},
+	}
+
+	tests := map[schema.OID]string{
+		"0.1.1": "*interfaces.LAN",
+		"0.1.2": "*interfaces.REST",
+		"0.1.3": "*interfaces.MQTT",
+	}
+
+	for oid, expected := range tests {
+		if i, ok := ii.route(controller{id: 405419896, iface: oid}); !ok {
+			t.Errorf("No interface for controller with interface '%v'", oid)
+		} else if s := fmt.Sprintf("%T", i); s != expected {
+			t.Errorf("Incorrect interface for '%v' - expected:%v, got:%v", oid, expected, s)
+		}
+	}
+}
+
+func TestMQTTLoad(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+
+	blob := []byte(`[
+	  { "OID": "0.1.1", "name": "LAN", "bind-address": "0.0.0.0", "broadcast-address": "255.255.255.255:60000", "listen-address": "0.0.0.0:60001" },
+	  { "OID": "0.1.3", "type": "MQTT", "name": "Gateway", "broker": "tcp://127.0.0.1:1883", "client-id": "httpd", "topics": { "requests": "site/requests" } }
+	]`)
+
+	ii := NewInterfaces(nil)
+	if err := ii.Load(blob); err != nil {
+		t.Fatalf("Unexpected error (%v)", err)
+	}
+
+	if len(ii.lans) != 1 || len(ii.mqtts) != 1 {
+		t.Fatalf("Incorrect interfaces - expected:1 LAN and 1 MQTT, got:%v LAN and %v MQTT", len(ii.lans), len(ii.mqtts))
+	}
+
+	m := ii.mqtts["0.1.3"]
+	if m == nil || m.Name != "Gateway" || m.Broker != "tcp://127.0.0.1:1883" || m.ClientID != "httpd" {
+		t.Fatalf("Incorrect uhppoted-mqtt interface - got:%+v", m)
+	}
+
+	expected := MQTTTopics{Requests: "site/requests", Replies: MQTTRepliesDefault, Events: MQTTEventsDefault}
+	if topics := m.topics(); topics != expected {
+		t.Errorf("Incorrect uhppoted-mqtt topics - expected:%+v, got:%+v", expected, topics)
+	}
+
+	if err := ii.Validate(); err != nil {
+		t.Errorf("Unexpected validation error (%v)", err)
+	}
+}
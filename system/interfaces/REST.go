@@ -0,0 +1,717 @@
+package interfaces
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	lib "github.com/uhppoted/uhppote-core/types"
+
+	"github.com/uhppoted/uhppoted-lib/acl"
+	"github.com/uhppoted/uhppoted-lib/uhppoted"
+
+	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/log"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+	"github.com/uhppoted/uhppoted-httpd/system/db"
+	"github.com/uhppoted/uhppoted-httpd/types"
+)
+
+// REST is an interface to controllers that are only reachable through a uhppoted-rest
+// gateway.
+type REST struct {
+	catalog.CatalogInterface
+	Name  string
+	URL   string
+	Debug bool
+
+	ch       chan types.EventsList
+	created  types.Timestamp
+	modified types.Timestamp
+	deleted  types.Timestamp
+}
+
+const RESTTimeout = 5 * time.Second
+
+func (r REST) String() string {
+	return fmt.Sprintf("%v", r.Name)
+}
+
+func (r REST) IsValid() bool {
+	return r.validate() == nil
+}
+
+func (r REST) validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return fmt.Errorf("uhppoted-rest interface name is blank")
+	}
+
+	if _, err := parseURL(r.URL); err != nil {
+		return fmt.Errorf("uhppoted-rest interface %v: %w", r.Name, err)
+	}
+
+	return nil
+}
+
+func (r REST) IsDeleted() bool {
+	return !r.deleted.IsZero()
+}
+
+func (r *REST) AsObjects(a *auth.Authorizator) []schema.Object {
+	list := []kv{}
+
+	if r.IsDeleted() {
+		list = append(list, kv{LANDeleted, r.deleted})
+	} else {
+		list = append(list, kv{LANStatus, r.status()})
+		list = append(list, kv{LANCreated, r.created})
+		list = append(list, kv{LANDeleted, r.deleted})
+		list = append(list, kv{LANType, "REST"})
+		list = append(list, kv{LANName, r.Name})
+		list = append(list, kv{RESTURL, r.URL})
+	}
+
+	return r.toObjects(list, a)
+}
+
+func (r REST) AsRuleEntity() (string, any) {
+	entity := struct {
+		Type string
+		Name string
+	}{
+		Type: "REST",
+		Name: fmt.Sprintf("%v", r.Name),
+	}
+
+	return "rest", &entity
+}
+
+func (r REST) CacheKey() string {
+	return ""
+}
+
+func (r *REST) set(a *auth.Authorizator, oid schema.OID, value string, dbc db.DBC) ([]schema.Object, error) {
+	if r == nil {
+		return []schema.Object{}, nil
+	}
+
+	if r.IsDeleted() {
+		return r.toObjects([]kv{{LANDeleted, r.deleted}}, a), fmt.Errorf("uhppoted-rest interface has been deleted")
+	}
+
+	uid := auth.UID(a)
+	list := []kv{}
+
+	switch oid {
+	case r.OID.Append(LANName):
+		if err := CanUpdate(a, r, "name", value); err != nil {
+			return nil, err
+		} else {
+			r.log(dbc, uid, "update", r.OID, "name", r.Name, value, "Updated name from %v to %v", r.Name, value)
+
+			r.Name = value
+			r.modified = types.TimestampNow()
+
+			list = append(list, kv{LANName, r.Name})
+		}
+
+	case r.OID.Append(RESTURL):
+		if u, err := parseURL(value); err != nil {
+			return nil, err
+		} else if err := CanUpdate(a, r, "url", u); err != nil {
+			return nil, err
+		} else {
+			r.log(dbc, uid, "update", r.OID, "url", r.URL, u, "Updated URL from %v to %v", r.URL, u)
+
+			r.URL = u
+			r.modified = types.TimestampNow()
+
+			list = append(list, kv{RESTURL, r.URL})
+		}
+	}
+
+	list = append(list, kv{LANStatus, r.status()})
+
+	return r.toObjects(list, a), nil
+}
+
+func (r REST) toObjects(list []kv, a *auth.Authorizator) []schema.Object {
+	objects := []schema.Object{}
+
+	if err := CanView(a, r, "OID", r.OID); err == nil && !r.IsDeleted() {
+		objects = append(objects, catalog.NewObject(r.OID, ""))
+	}
+
+	for _, v := range list {
+		field := lookup[v.field]
+		if err := CanView(a, r, field, v.value); err == nil {
+			objects = append(objects, catalog.NewObject2(r.OID, v.field, v.value))
+		}
+	}
+
+	return objects
+}
+
+func (r REST) Clone() REST {
+	return REST{
+		CatalogInterface: catalog.CatalogInterface{
+			OID: r.OID,
+		},
+		Name:  r.Name,
+		URL:   r.URL,
+		Debug: r.Debug,
+
+		ch:       r.ch,
+		created:  r.created,
+		modified: r.modified,
+		deleted:  r.deleted,
+	}
+}
+
+func (r *REST) search(controllers []types.IController) ([]uint32, error) {
+	list := []uint32{}
+
+	response := struct {
+		Devices []struct {
+			DeviceID uint32 `json:"device-id"`
+		} `json:"devices"`
+	}{}
+
+	if err := r.get(&response, "device"); err != nil {
+		return list, err
+	}
+
+	for _, d := range response.Devices {
+		list = append(list, d.DeviceID)
+	}
+
+	return list, nil
+}
+
+// A long-running function i.e. expects to be invoked from an external goroutine
+func (r *REST) refresh(c types.IController) {
+	log.Infof("%v: refreshing uhppoted-rest controller status", c.ID())
+
+	deviceID := c.ID()
+
+	device := struct {
+		DeviceID  uint32     `json:"device-id"`
+		IpAddress net.IP     `json:"ip-address"`
+		Address   netip.Addr `json:"address"`
+	}{}
+
+	if err := r.get(&device, "device", deviceID); err != nil {
+		log.Warnf("%v", err)
+	} else {
+		addr := device.Address
+		if !addr.IsValid() {
+			addr, _ = netip.AddrFromSlice(device.IpAddress.To4())
+		}
+
+		catalog.PutV(c.OID(), ControllerTouched, time.Now())
+		if addr.IsValid() {
+			catalog.PutV(c.OID(), ControllerEndpointAddress, lib.ControllerAddrFrom(addr, 60000))
+		}
+	}
+
+	if status, err := r.getStatus(deviceID); err != nil {
+		log.Warnf("%v", err)
+	} else {
+		catalog.PutV(c.OID(), ControllerTouched, time.Now())
+		catalog.PutV(c.OID(), ControllerDateTimeCurrent, status.SystemDateTime)
+
+		putDoorStates(status, c.Door)
+	}
+
+	cards := struct {
+		Cards []uint32 `json:"cards"`
+	}{}
+
+	if err := r.get(&cards, "device", deviceID, "cards"); err != nil {
+		log.Warnf("%v", err)
+	} else {
+		catalog.PutV(c.OID(), ControllerTouched, time.Now())
+		catalog.PutV(c.OID(), ControllerCardsCount, uint32(len(cards.Cards)))
+	}
+
+	for _, d := range []uint8{1, 2, 3, 4} {
+		delay := struct {
+			Delay uint8 `json:"delay"`
+		}{}
+
+		if err := r.get(&delay, "device", deviceID, "door", d, "delay"); err != nil {
+			log.Warnf("%v", err)
+		} else if door, ok := c.Door(d); ok {
+			catalog.PutV(door, DoorDelay, delay.Delay)
+		}
+	}
+
+	for _, d := range []uint8{1, 2, 3, 4} {
+		control := struct {
+			Control lib.ControlState `json:"control"`
+		}{}
+
+		if err := r.get(&control, "device", deviceID, "door", d, "control"); err != nil {
+			log.Warnf("%v", err)
+		} else if door, ok := c.Door(d); ok {
+			catalog.PutV(door, DoorControl, control.Control)
+		}
+	}
+}
+
+func (r *REST) getEvents(c types.IController, intervals []types.Interval) {
+	deviceID := c.ID()
+	oid := c.OID()
+
+	log.Infof("%v: retrieving uhppoted-rest controller events (%v)", deviceID, intervals)
+
+	indices := struct {
+		Events struct {
+			First   uint32 `json:"first"`
+			Last    uint32 `json:"last"`
+			Current uint32 `json:"current"`
+		} `json:"events"`
+	}{}
+
+	if err := r.get(&indices, "device", deviceID, "events"); err != nil {
+		log.Warnf("%v", err)
+		return
+	}
+
+	first := indices.Events.First
+	last := indices.Events.Last
+
+	catalog.PutV(oid, ControllerTouched, time.Now())
+	catalog.PutV(oid, ControllerEventsFirst, first)
+	catalog.PutV(oid, ControllerEventsLast, last)
+	catalog.PutV(oid, ControllerEventsCurrent, indices.Events.Current)
+	catalog.PutV(oid, ControllerEventsStatus, eventsStatus(intervals, first, last))
+
+	events := []uhppoted.Event{}
+
+	retrieve(intervals, first, last, func(index uint32) {
+		response := struct {
+			Event *uhppoted.Event `json:"event"`
+		}{}
+
+		if err := r.get(&response, "device", deviceID, "event", index); err != nil {
+			log.Warnf("%v", err)
+		} else if response.Event == nil {
+			log.Warnf("%v: missing event %v", deviceID, index)
+			events = append(events, uhppoted.Event{
+				DeviceID: deviceID,
+				Index:    index,
+			})
+		} else {
+			e := *response.Event
+			e.DeviceID = deviceID
+
+			events = append(events, e)
+		}
+	})
+
+	if r.ch != nil {
+		r.ch <- types.EventsList{
+			DeviceID: deviceID,
+			Events:   events,
+		}
+	}
+}
+
+func (r *REST) setTime(c types.IController, t time.Time) {
+	lock(c.ID())
+	defer unlock(c.ID())
+
+	deviceID := c.ID()
+	datetime := lib.DateTime(t.In(c.TimeZone()))
+
+	request := struct {
+		DateTime lib.DateTime `json:"datetime"`
+	}{
+		DateTime: datetime,
+	}
+
+	if err := r.put(request, "device", deviceID, "time"); err != nil {
+		log.Warnf("%v", err)
+	} else {
+		catalog.PutV(c.OID(), ControllerDateTimeModified, false)
+
+		if status, err := r.getStatus(deviceID); err != nil {
+			log.Warnf("%v", err)
+		} else {
+			catalog.PutV(c.OID(), ControllerDateTimeCurrent, status.SystemDateTime)
+		}
+
+		log.Infof("%v  set date/time: %v", deviceID, datetime)
+	}
+}
+
+func (r *REST) setDoor(c types.IController, door uint8, mode lib.ControlState, delay uint8) error {
+	lock(c.ID())
+	defer unlock(c.ID())
+
+	deviceID := c.ID()
+
+	if mode != lib.ModeUnknown {
+		request := struct {
+			Control lib.ControlState `json:"control"`
+		}{
+			Control: mode,
+		}
+
+		if err := r.put(request, "device", deviceID, "door", door, "control"); err != nil {
+			return err
+		}
+	}
+
+	if delay != 0 {
+		request := struct {
+			Delay uint8 `json:"delay"`
+		}{
+			Delay: delay,
+		}
+
+		if err := r.put(request, "device", deviceID, "door", door, "delay"); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("%v  set door %v mode:%-15v delay:%vs", deviceID, door, mode, delay)
+
+	return nil
+}
+
+func (r *REST) openDoor(c types.IController, door uint8) error {
+	return r.unsupported(c, "open door")
+}
+
+func (r *REST) setInterlock(c types.IController, interlock lib.Interlock) error {
+	return r.unsupported(c, "set interlock")
+}
+
+func (r *REST) setAntiPassback(c types.IController, antipassback lib.AntiPassback) error {
+	return r.unsupported(c, "set anti-passback")
+}
+
+func (r *REST) activateKeypads(c types.IController, keypads map[uint8]bool) error {
+	return r.unsupported(c, "activate keypads")
+}
+
+func (r *REST) setDoorPasscodes(c types.IController, door uint8, passcodes ...uint32) error {
+	return r.unsupported(c, "set door passcodes")
+}
+
+func (r *REST) putCard(c types.IController, cardID uint32, PIN uint32, from, to lib.Date, permissions map[uint8]uint8) {
+	lock(c.ID())
+	defer unlock(c.ID())
+
+	deviceID := c.ID()
+
+	card := lib.Card{
+		CardNumber: cardID,
+		PIN:        lib.PIN(PIN),
+		From:       from,
+		To:         to,
+		Doors: map[uint8]uint8{
+			1: permissions[1],
+			2: permissions[2],
+			3: permissions[3],
+			4: permissions[4],
+		},
+	}
+
+	if err := r.put(card, "device", deviceID, "card", cardID); err != nil {
+		log.Warnf("%v", err)
+	} else {
+		log.Infof("%v  put card %v", deviceID, card)
+	}
+}
+
+func (r *REST) deleteCard(c types.IController, card uint32) {
+	lock(c.ID())
+	defer unlock(c.ID())
+
+	deviceID := c.ID()
+
+	if err := r.delete("device", deviceID, "card", card); err != nil {
+		log.Warnf("%v", err)
+	} else {
+		log.Infof("%v  deleted card %v", deviceID, card)
+	}
+}
+
+func (r *REST) putTimeProfile(c types.IController, profile lib.TimeProfile) error {
+	lock(c.ID())
+	defer unlock(c.ID())
+
+	return r.put(profile, "device", c.ID(), "time-profile", profile.ID)
+}
+
+func (r *REST) compareACL(controllers []types.IController, permissions acl.ACL, profiles []lib.TimeProfile, withPIN bool) (map[uint32]acl.Diff, map[uint32][]uint8, error) {
+	log.Debugf("Comparing uhppoted-rest ACL (with-pin:%v)", withPIN)
+
+	current := acl.ACL{}
+	for _, c := range controllers {
+		if cards, err := r.getCards(c.ID()); err != nil {
+			log.Warnf("%v", err)
+		} else {
+			current[c.ID()] = cards
+		}
+	}
+
+	f := func(permissions, current acl.ACL) (map[uint32]acl.Diff, error) {
+		if withPIN {
+			return acl.CompareWithPIN(permissions, current)
+		} else {
+			return acl.Compare(permissions, current)
+		}
+	}
+
+	compare, err := f(permissions, current)
+	if err != nil {
+		return nil, nil, err
+	} else if compare == nil {
+		return nil, nil, fmt.Errorf("invalid ACL compare report: %v", compare)
+	}
+
+	for k, v := range compare {
+		log.Infof("ACL %v  unchanged:%-3v updated:%-3v added:%-3v deleted:%-3v", k, len(v.Unchanged), len(v.Updated), len(v.Added), len(v.Deleted))
+	}
+
+	mismatched := map[uint32][]uint8{}
+	for _, c := range controllers {
+		deviceID := c.ID()
+		if _, ok := current[deviceID]; !ok {
+			continue
+		}
+
+		for _, p := range profiles {
+			response := struct {
+				TimeProfile *lib.TimeProfile `json:"time-profile"`
+			}{}
+
+			if err := r.get(&response, "device", deviceID, "time-profile", p.ID); err != nil {
+				log.Warnf("%v", err)
+				mismatched[deviceID] = append(mismatched[deviceID], p.ID)
+			} else if response.TimeProfile == nil || !sameTimeProfile(p, *response.TimeProfile) {
+				mismatched[deviceID] = append(mismatched[deviceID], p.ID)
+			}
+		}
+
+		if len(mismatched[deviceID]) > 0 {
+			log.Infof("ACL %v  time profiles out of synch:%v", deviceID, mismatched[deviceID])
+		}
+
+		rs := compare[deviceID]
+		if len(rs.Updated)+len(rs.Added)+len(rs.Deleted)+len(mismatched[deviceID]) > 0 {
+			catalog.PutV(c.OID(), ControllerCardsStatus, types.StatusError)
+		} else {
+			catalog.PutV(c.OID(), ControllerCardsStatus, types.StatusOk)
+		}
+	}
+
+	return compare, mismatched, nil
+}
+
+func (r *REST) getStatus(deviceID uint32) (*lib.Status, error) {
+	response := struct {
+		Status struct {
+			DoorState      map[uint8]bool `json:"door-states"`
+			DoorButton     map[uint8]bool `json:"door-buttons"`
+			SystemDateTime lib.DateTime   `json:"system-datetime"`
+			RelayState     uint8          `json:"relay-state"`
+		} `json:"status"`
+	}{}
+
+	if err := r.get(&response, "device", deviceID, "status"); err != nil {
+		return nil, err
+	}
+
+	return &lib.Status{
+		SerialNumber:   lib.SerialNumber(deviceID),
+		DoorState:      response.Status.DoorState,
+		DoorButton:     response.Status.DoorButton,
+		SystemDateTime: response.Status.SystemDateTime,
+		RelayState:     response.Status.RelayState,
+	}, nil
+}
+
+func (r *REST) getCards(deviceID uint32) (map[uint32]lib.Card, error) {
+	list := struct {
+		Cards []uint32 `json:"cards"`
+	}{}
+
+	if err := r.get(&list, "device", deviceID, "cards"); err != nil {
+		return nil, err
+	}
+
+	cards := map[uint32]lib.Card{}
+	for _, v := range list.Cards {
+		response := struct {
+			Card *lib.Card `json:"card"`
+		}{}
+
+		if err := r.get(&response, "device", deviceID, "card", v); err != nil {
+			return nil, err
+		} else if response.Card == nil {
+			return nil, fmt.Errorf("%v  invalid response to get-card %v", deviceID, v)
+		} else {
+			cards[v] = *response.Card
+		}
+	}
+
+	return cards, nil
+}
+
+func (r *REST) unsupported(c types.IController, operation string) error {
+	return fmt.Errorf("%v  %v not supported by uhppoted-rest interface %v", c.ID(), operation, r.Name)
+}
+
+func (r *REST) status() types.Status {
+	return types.StatusOk
+}
+
+func (r *REST) get(response any, path ...any) error {
+	return r.call(http.MethodGet, nil, response, path...)
+}
+
+func (r *REST) put(request any, path ...any) error {
+	return r.call(http.MethodPut, request, nil, path...)
+}
+
+func (r *REST) delete(path ...any) error {
+	return r.call(http.MethodDelete, nil, nil, path...)
+}
+
+// Invokes a uhppoted-rest API function. The request path is relative to <url>/uhppote.
+func (r *REST) call(method string, request any, response any, path ...any) error {
+	elements := []string{"uhppote"}
+	for _, v := range path {
+		elements = append(elements, fmt.Sprintf("%v", v))
+	}
+
+	uri, err := url.JoinPath(r.URL, elements...)
+	if err != nil {
+		return err
+	}
+
+	var body io.Reader
+	if request != nil {
+		if b, err := json.Marshal(request); err != nil {
+			return err
+		} else {
+			body = bytes.NewReader(b)
+		}
+	}
+
+	rq, err := http.NewRequest(method, uri, body)
+	if err != nil {
+		return err
+	}
+
+	rq.Header.Set("Accept", "application/json")
+	if request != nil {
+		rq.Header.Set("Content-Type", "application/json")
+	}
+
+	if r.Debug {
+		log.Debugf("%v %v", method, uri)
+	}
+
+	client := http.Client{
+		Timeout: RESTTimeout,
+	}
+
+	rs, err := client.Do(rq)
+	if err != nil {
+		return err
+	}
+
+	defer rs.Body.Close()
+
+	if rs.StatusCode < 200 || rs.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(rs.Body, 256))
+		return fmt.Errorf("%v %v: %v (%v)", method, uri, rs.Status, strings.TrimSpace(string(msg)))
+	}
+
+	if response != nil {
+		if err := json.NewDecoder(rs.Body).Decode(response); err != nil {
+			return fmt.Errorf("%v %v: invalid response (%v)", method, uri, err)
+		}
+	}
+
+	return nil
+}
+
+func (r REST) serialize() ([]byte, error) {
+	record := struct {
+		OID      schema.OID      `json:"OID"`
+		Type     string          `json:"type"`
+		Name     string          `json:"name,omitempty"`
+		URL      string          `json:"url"`
+		Created  types.Timestamp `json:"created"`
+		Modified types.Timestamp `json:"modified"`
+	}{
+		OID:      r.OID,
+		Type:     "REST",
+		Name:     r.Name,
+		URL:      r.URL,
+		Created:  r.created.UTC(),
+		Modified: r.modified.UTC(),
+	}
+
+	return json.MarshalIndent(record, "", "  ")
+}
+
+func (r *REST) deserialize(bytes []byte) error {
+	created = created.Add(1 * time.Minute)
+
+	record := struct {
+		OID      schema.OID      `json:"OID"`
+		Type     string          `json:"type"`
+		Name     string          `json:"name,omitempty"`
+		URL      string          `json:"url"`
+		Created  types.Timestamp `json:"created"`
+		Modified types.Timestamp `json:"modified"`
+	}{
+		Created: created,
+	}
+
+	if err := json.Unmarshal(bytes, &record); err != nil {
+		return err
+	}
+
+	r.OID = record.OID
+	r.Name = record.Name
+	r.URL = record.URL
+	r.created = record.Created
+	r.modified = record.Modified
+
+	return nil
+}
+
+func (r *REST) log(dbc db.DBC, uid string, op string, OID schema.OID, field string, before, after any, format string, fields ...any) {
+	dbc.Log(uid, op, OID, "interface", "REST", r.Name, field, before, after, format, fields...)
+}
+
+// Validates a uhppoted-rest base URL e.g. http://192.168.1.100:8080.
+func parseURL(s string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return "", fmt.Errorf("invalid uhppoted-rest URL (%v)", s)
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid uhppoted-rest URL (%v)", s)
+	}
+
+	return strings.TrimSuffix(u.String(), "/"), nil
+}
@@ -0,0 +1,315 @@
+package interfaces
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	core "github.com/uhppoted/uhppote-core/types"
+	"github.com/uhppoted/uhppoted-lib/acl"
+
+	"github.com/uhppoted/uhppoted-httpd/system/catalog"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/impl"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+	"github.com/uhppoted/uhppoted-httpd/types"
+)
+
+type controller struct {
+	oid   schema.OID
+	id    uint32
+	iface schema.OID
+}
+
+func (c controller) OID() schema.OID                 { return c.oid }
+func (c controller) Name() string                    { return "" }
+func (c controller) ID() uint32                      { return c.id }
+func (c controller) EndPoint() core.ControllerAddr   { return core.ControllerAddr{} }
+func (c controller) TimeZone() *time.Location        { return time.Local }
+func (c controller) Protocol() string                { return "udp" }
+func (c controller) Interface() schema.OID           { return c.iface }
+func (c controller) Door(d uint8) (schema.OID, bool) { return "", false }
+func (c controller) DateTimeOk() bool                { return true }
+
+// Minimal uhppoted-rest stub for controller 405419896 with cards 10058400 and 10058401.
+func restStub(put func(path string, body map[string]any)) *httptest.Server {
+	mux := http.NewServeMux()
+
+	number := func(s string) uint64 {
+		v, _ := strconv.ParseUint(s, 10, 32)
+		return v
+	}
+
+	reply := func(w http.ResponseWriter, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+	}
+
+	mux.HandleFunc("GET /uhppote/device", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, map[string]any{
+			"devices": []any{
+				map[string]any{"device-id": 405419896, "device-type": "UTO311-L04"},
+				map[string]any{"device-id": 303986753, "device-type": "UTO311-L02"},
+			},
+		})
+	})
+
+	mux.HandleFunc("GET /uhppote/device/405419896/events", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, map[string]any{
+			"events": map[string]any{"first": 1, "last": 69, "current": 69},
+		})
+	})
+
+	mux.HandleFunc("GET /uhppote/device/405419896/event/{index}", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, map[string]any{
+			"event": map[string]any{
+				"event-id":       number(r.PathValue("index")),
+				"event-type":     1,
+				"access-granted": true,
+				"door-id":        3,
+				"direction":      1,
+				"card-number":    10058400,
+				"timestamp":      "2024-11-26 12:34:56",
+				"event-reason":   1,
+			},
+		})
+	})
+
+	mux.HandleFunc("GET /uhppote/device/405419896/cards", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, map[string]any{
+			"cards": []uint32{10058400, 10058401},
+		})
+	})
+
+	mux.HandleFunc("GET /uhppote/device/405419896/card/{card}", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, map[string]any{
+			"card": map[string]any{
+				"card-number": number(r.PathValue("card")),
+				"start-date":  "2024-01-01",
+				"end-date":    "2024-12-31",
+				"doors":       map[string]any{"1": 1, "2": 0, "3": 0, "4": 0},
+			},
+		})
+	})
+
+	mux.HandleFunc("PUT /uhppote/device/405419896/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]any{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else if put != nil {
+			put(r.PathValue("path"), body)
+		}
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestRESTAsObjects(t *testing.T) {
+	created = types.Timestamp(time.Date(2021, time.February, 28, 12, 34, 56, 0, time.Local))
+
+	r := REST{
+		CatalogInterface: catalog.CatalogInterface{
+			OID: "0.1.5",
+		},
+		Name: "Le REST",
+		URL:  "http://127.0.0.1:8080",
+
+		created: created,
+	}
+
+	expected := []schema.Object{
+		{OID: "0.1.5", Value: ""},
+		{OID: "0.1.5.0.0", Value: types.StatusOk},
+		{OID: "0.1.5.0.1", Value: created},
+		{OID: "0.1.5.0.2", Value: types.Timestamp{}},
+		{OID: "0.1.5.0.4", Value: "REST"},
+		{OID: "0.1.5.1", Value: "Le REST"},
+		{OID: "0.1.5.4.1", Value: "http://127.0.0.1:8080"},
+	}
+
+	objects := r.AsObjects(nil)
+
+	if !reflect.DeepEqual(objects, expected) {
+		t.Errorf("Incorrect return from AsObjects:\n   expected:%#v\n   got:     %#v", expected, objects)
+	}
+}
+
+func TestRESTSearch(t *testing.T) {
+	server := restStub(nil)
+	defer server.Close()
+
+	r := REST{Name: "REST", URL: server.URL}
+
+	list, err := r.search(nil)
+	if err != nil {
+		t.Fatalf("Unexpected error (%v)", err)
+	}
+
+	slices.Sort(list)
+	if !slices.Equal(list, []uint32{303986753, 405419896}) {
+		t.Errorf("Incorrect search result - expected:%v, got:%v", []uint32{303986753, 405419896}, list)
+	}
+}
+
+func TestRESTGetEvents(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+
+	server := restStub(nil)
+	defer server.Close()
+
+	ch := make(chan types.EventsList, 1)
+	r := REST{Name: "REST", URL: server.URL, ch: ch}
+	c := controller{oid: "0.2.9", id: 405419896}
+
+	r.getEvents(c, []types.Interval{{From: 67, To: 100}})
+
+	list := <-ch
+	if list.DeviceID != 405419896 {
+		t.Errorf("Incorrect events device ID - expected:%v, got:%v", 405419896, list.DeviceID)
+	}
+
+	if len(list.Events) != 3 {
+		t.Fatalf("Incorrect number of events - expected:%v, got:%v", 3, len(list.Events))
+	}
+
+	for i, e := range list.Events {
+		if e.DeviceID != 405419896 || e.Index != uint32(67+i) || e.CardNumber != 10058400 || e.Door != 3 || !e.Granted {
+			t.Errorf("Incorrect event %v - got:%+v", i, e)
+		}
+	}
+
+	if status := catalog.GetV("0.2.9", ControllerEventsStatus); status != types.StatusIncomplete {
+		t.Errorf("Incorrect events status - expected:%v, got:%v", types.StatusIncomplete, status)
+	}
+}
+
+func TestRESTPutCard(t *testing.T) {
+	var path string
+	var body map[string]any
+
+	server := restStub(func(p string, b map[string]any) {
+		path = p
+		body = b
+	})
+
+	defer server.Close()
+
+	r := REST{Name: "REST", URL: server.URL}
+	c := controller{oid: "0.2.9", id: 405419896}
+
+	from := core.MustParseDate("2024-01-01")
+	to := core.MustParseDate("2024-12-31")
+
+	r.putCard(c, 10058400, 0, from, to, map[uint8]uint8{1: 1, 3: 29})
+
+	expected := map[string]any{
+		"card-number": float64(10058400),
+		"start-date":  "2024-01-01",
+		"end-date":    "2024-12-31",
+		"doors":       map[string]any{"1": float64(1), "2": float64(0), "3": float64(29), "4": float64(0)},
+	}
+
+	if path != "card/10058400" {
+		t.Errorf("Incorrect put-card path - expected:%v, got:%v", "card/10058400", path)
+	}
+
+	if !reflect.DeepEqual(body, expected) {
+		t.Errorf("Incorrect put-card request\n   expected:%v\n   got:     %v", expected, body)
+	}
+}
+
+func TestRESTCompareACL(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+
+	server := restStub(nil)
+	defer server.Close()
+
+	r := REST{Name: "REST", URL: server.URL}
+	c := controller{oid: "0.2.9", id: 405419896}
+
+	from := core.MustParseDate("2024-01-01")
+	to := core.MustParseDate("2024-12-31")
+
+	permissions := acl.ACL{
+		405419896: map[uint32]core.Card{
+			10058400: {CardNumber: 10058400, From: from, To: to, Doors: map[uint8]uint8{1: 1, 2: 0, 3: 0, 4: 0}},
+			10058402: {CardNumber: 10058402, From: from, To: to, Doors: map[uint8]uint8{1: 1, 2: 0, 3: 0, 4: 0}},
+		},
+	}
+
+	diff, _, err := r.compareACL([]types.IController{c}, permissions, nil, false)
+	if err != nil {
+		t.Fatalf("Unexpected error (%v)", err)
+	}
+
+	d := diff[405419896]
+	if len(d.Unchanged) != 1 || len(d.Added) != 1 || len(d.Deleted) != 1 || len(d.Updated) != 0 {
+		t.Errorf("Incorrect ACL diff - got unchanged:%v updated:%v added:%v deleted:%v", len(d.Unchanged), len(d.Updated), len(d.Added), len(d.Deleted))
+	}
+
+	if status := catalog.GetV("0.2.9", ControllerCardsStatus); status != types.StatusError {
+		t.Errorf("Incorrect cards status - expected:%v, got:%v", types.StatusError, status)
+	}
+}
+
+func TestRESTRoute(t *testing.T) {
+	ii := Interfaces{
+		lans: map[schema.OID]*LAN{
+			"0.1.1": {CatalogInterface: catalog.CatalogInterface{OID: "0.1.1"}, Name: "LAN"},
+		},
+		rests: map[schema.OID]*REST{
+			"0.1.2": {CatalogInterface: catalog.CatalogInterface{OID: "0.1.2"}, Name: "REST", URL: "http://127.0.0.1:8080"},
+		},
+	}
+
+	tests := []struct {
+		iface    schema.OID
+		expected string
+	}{
+		{"", "*interfaces.LAN"},
+		{"0.1.1", "*interfaces.LAN"},
+		{"0.1.2", "*interfaces.REST"},
+		{"0.1.3", ""},
+	}
+
+	for _, test := range tests {
+		if i, ok := ii.route(controller{id: 405419896, iface: test.iface}); ok && test.expected == "" {
+			t.Errorf("Unexpected interface for controller with interface '%v' (%T)", test.iface, i)
+		} else if !ok && test.expected != "" {
+			t.Errorf("No interface for controller with interface '%v'", test.iface)
+		} else if s := fmt.Sprintf("%T", i); ok && s != test.expected {
+			t.Errorf("Incorrect interface for '%v' - expected:%v, got:%v", test.iface, test.expected, s)
+		}
+	}
+}
+
+func TestRESTLoad(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+
+	blob := []byte(`[
+	  { "OID": "0.1.1", "name": "LAN", "bind-address": "0.0.0.0", "broadcast-address": "255.255.255.255:60000", "listen-address": "0.0.0.0:60001" },
+	  { "OID": "0.1.2", "type": "REST", "name": "Gateway", "url": "http://127.0.0.1:8080" }
+	]`)
+
+	ii := NewInterfaces(nil)
+	if err := ii.Load(blob); err != nil {
+		t.Fatalf("Unexpected error (%v)", err)
+	}
+
+	if len(ii.lans) != 1 || len(ii.rests) != 1 {
+		t.Fatalf("Incorrect interfaces - expected:1 LAN and 1 REST, got:%v LAN and %v REST", len(ii.lans), len(ii.rests))
+	}
+
+	if r := ii.rests["0.1.2"]; r == nil || r.Name != "Gateway" || r.URL != "http://127.0.0.1:8080" {
+		t.Errorf("Incorrect uhppoted-rest interface - got:%+v", r)
+	}
+
+	if err := ii.Validate(); err != nil {
+		t.Errorf("Unexpected validation error (%v)", err)
+	}
+}
@@ -5,7 +5,7 @@ import (
 )
 
 type TAuthable interface {
-	LAN | *LAN
+	LAN | *LAN | REST | *REST | MQTT | *MQTT
 
 	AsRuleEntity() (string, any)
 	CacheKey() string
@@ -13,18 +13,32 @@ type TAuthable interface {
 
 var rulesets = []auth.RuleSet{auth.Interfaces}
 
+// A scoped administrator can view but cannot add, update or delete interfaces, which are
+// shared by all controllers.
 func CanView[T TAuthable](a auth.OpAuth, u T, field string, value any) error {
 	return auth.CanView(a, u, field, value, rulesets...)
 }
 
 func CanAdd[T TAuthable](a auth.OpAuth, u T) error {
+	if auth.ScopeOf(a).IsScoped() {
+		return auth.ErrUnauthorised
+	}
+
 	return auth.CanAdd(a, u, rulesets...)
 }
 
 func CanUpdate[T TAuthable](a auth.OpAuth, u T, field string, value any) error {
+	if auth.ScopeOf(a).IsScoped() {
+		return auth.ErrUnauthorised
+	}
+
 	return auth.CanUpdate(a, u, field, value, rulesets...)
 }
 
 func CanDelete[T TAuthable](a auth.OpAuth, u T) error {
+	if auth.ScopeOf(a).IsScoped() {
+		return auth.ErrUnauthorised
+	}
+
 	return auth.CanDelete(a, u, rulesets...)
 }
@@ -3,6 +3,8 @@ package interfaces
 import (
 	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -15,24 +17,56 @@ import (
 	"github.com/uhppoted/uhppoted-httpd/system/catalog"
 	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
 	"github.com/uhppoted/uhppoted-httpd/system/db"
+	"github.com/uhppoted/uhppoted-httpd/system/simulator"
 	"github.com/uhppoted/uhppoted-httpd/types"
 )
 
 type Interfaces struct {
-	lans map[schema.OID]*LAN
-	ch   chan types.EventsList
+	lans  map[schema.OID]*LAN
+	rests map[schema.OID]*REST
+	mqtts map[schema.OID]*MQTT
+	ch    chan types.EventsList
+}
+
+// iface is the set of controller operations implemented by the LAN, uhppoted-rest and
+// uhppoted-mqtt interfaces.
+type iface interface {
+	search([]types.IController) ([]uint32, error)
+	refresh(types.IController)
+	getEvents(types.IController, []types.Interval)
+	setTime(types.IController, time.Time)
+	setDoor(types.IController, uint8, lib.ControlState, uint8) error
+	openDoor(types.IController, uint8) error
+	setInterlock(types.IController, lib.Interlock) error
+	setAntiPassback(types.IController, lib.AntiPassback) error
+	activateKeypads(types.IController, map[uint8]bool) error
+	setDoorPasscodes(types.IController, uint8, ...uint32) error
+	putCard(types.IController, uint32, uint32, lib.Date, lib.Date, map[uint8]uint8)
+	deleteCard(types.IController, uint32)
+	putTimeProfile(types.IController, lib.TimeProfile) error
+	compareACL([]types.IController, acl.ACL, []lib.TimeProfile, bool) (map[uint32]acl.Diff, map[uint32][]uint8, error)
 }
 
 var guards = sync.Map{}
 var guard sync.RWMutex
+var simulation *simulator.Simulator
 
 func NewInterfaces(ch chan types.EventsList) Interfaces {
 	return Interfaces{
-		lans: map[schema.OID]*LAN{},
-		ch:   ch,
+		lans:  map[schema.OID]*LAN{},
+		rests: map[schema.OID]*REST{},
+		mqtts: map[schema.OID]*MQTT{},
+		ch:    ch,
 	}
 }
 
+// Simulate replaces the LAN interface UDP transport with the controller simulator and routes
+// controllers assigned to a gateway to the default LAN interface. Expected to be invoked once
+// on startup.
+func Simulate(s *simulator.Simulator) {
+	simulation = s
+}
+
 func (ii *Interfaces) AsObjects(a *auth.Authorizator) []schema.Object {
 	objects := []schema.Object{}
 
@@ -42,6 +76,18 @@ func (ii *Interfaces) AsObjects(a *auth.Authorizator) []schema.Object {
 		}
 	}
 
+	for _, r := range ii.rests {
+		if r.IsValid() {
+			catalog.Join(&objects, r.AsObjects(a)...)
+		}
+	}
+
+	for _, m := range ii.mqtts {
+		if m.IsValid() {
+			catalog.Join(&objects, m.AsObjects(a)...)
+		}
+	}
+
 	return objects
 }
 
@@ -59,6 +105,18 @@ func (ii *Interfaces) Update(a *auth.Authorizator, oid schema.OID, value string,
 			}
 		}
 
+		for _, r := range ii.rests {
+			if r != nil && r.OID.Contains(oid) {
+				return r.set(a, oid, value, dbc)
+			}
+		}
+
+		for _, m := range ii.mqtts {
+			if m != nil && m.OID.Contains(oid) {
+				return m.set(a, oid, value, dbc)
+			}
+		}
+
 	}
 
 	return objects, nil
@@ -68,16 +126,78 @@ func (ii *Interfaces) Delete(a *auth.Authorizator, oid schema.OID, dbc db.DBC) (
 	return []schema.Object{}, nil
 }
 
+// Returns the default LAN interface i.e. the (non-deleted) LAN interface with the lowest OID.
 func (ii *Interfaces) LAN() (LAN, bool) {
+	var lan *LAN
+
 	for _, v := range ii.lans {
-		if v != nil {
-			return *v, true
+		if v != nil && !v.IsDeleted() && (lan == nil || v.OID.Compare(lan.OID) < 0) {
+			lan = v
 		}
 	}
 
+	if lan != nil {
+		return *lan, true
+	}
+
 	return LAN{}, false
 }
 
+// Returns the interface for a controller.
+func (ii *Interfaces) route(controller types.IController) (iface, bool) {
+	_, i, ok := ii.resolve(controller)
+
+	return i, ok
+}
+
+// Returns the OID and interface for a controller i.e. the LAN, uhppoted-rest or uhppoted-mqtt
+// interface assigned to the controller or the default LAN interface for unassigned controllers.
+// Controllers assigned to a gateway are routed to the default LAN in simulation mode. Returns
+// false for controllers assigned to an unknown or deleted interface.
+func (ii *Interfaces) resolve(controller types.IController) (schema.OID, iface, bool) {
+	if oid := controller.Interface(); oid != "" {
+		if i, ok := ii.gateway(oid); ok {
+			return oid, i, true
+		} else if l, ok := ii.lans[oid]; ok && l != nil && !l.IsDeleted() {
+			lan := *l
+			return oid, &lan, true
+		} else if r, ok := ii.rests[oid]; simulation != nil && ok && r != nil && !r.IsDeleted() {
+			// ... routed to default LAN
+		} else if m, ok := ii.mqtts[oid]; simulation != nil && ok && m != nil && !m.IsDeleted() {
+			// ... routed to default LAN
+		} else {
+			log.Warnf("%v: unknown interface %v", controller.ID(), oid)
+			return "", nil, false
+		}
+	}
+
+	if lan, ok := ii.LAN(); ok {
+		return lan.OID, &lan, true
+	}
+
+	return "", nil, false
+}
+
+// Returns the (non-deleted) uhppoted-rest or uhppoted-mqtt interface with the OID. Always
+// returns false in simulation mode.
+func (ii *Interfaces) gateway(oid schema.OID) (iface, bool) {
+	if simulation != nil {
+		return nil, false
+	}
+
+	if r, ok := ii.rests[oid]; ok && r != nil && !r.IsDeleted() {
+		rest := r.Clone()
+		return &rest, true
+	}
+
+	if m, ok := ii.mqtts[oid]; ok && m != nil && !m.IsDeleted() {
+		mqtt := m.Clone()
+		return &mqtt, true
+	}
+
+	return nil, false
+}
+
 func (ii *Interfaces) Load(blob json.RawMessage) error {
 	rs := []json.RawMessage{}
 	if err := json.Unmarshal(blob, &rs); err != nil {
@@ -85,14 +205,49 @@ func (ii *Interfaces) Load(blob json.RawMessage) error {
 	}
 
 	for _, v := range rs {
-		var l LAN
-		if err := l.deserialize(v); err == nil {
-			if _, ok := ii.lans[l.OID]; ok {
-				return fmt.Errorf("card '%v': duplicate OID (%v)", l.Name, l.OID)
+		record := struct {
+			Type string `json:"type"`
+		}{}
+
+		if err := json.Unmarshal(v, &record); err != nil {
+			continue
+		}
+
+		switch strings.ToUpper(record.Type) {
+		case "REST":
+			var r REST
+			if err := r.deserialize(v); err == nil {
+				if ii.exists(r.OID) {
+					return fmt.Errorf("uhppoted-rest interface '%v': duplicate OID (%v)", r.Name, r.OID)
+				}
+
+				r.ch = ii.ch
+				ii.rests[r.OID] = &r
+			}
+
+		case "MQTT":
+			var m MQTT
+			if err := m.deserialize(v); err == nil {
+				if ii.exists(m.OID) {
+					return fmt.Errorf("uhppoted-mqtt interface '%v': duplicate OID (%v)", m.Name, m.OID)
+				}
+
+				m.ch = ii.ch
+				ii.mqtts[m.OID] = &m
 			}
 
-			l.ch = ii.ch
-			ii.lans[l.OID] = &l
+		default:
+			var l LAN
+			if err := l.deserialize(v); err == nil {
+				if _, ok := ii.lans[l.OID]; ok {
+					return fmt.Errorf("card '%v': duplicate OID (%v)", l.Name, l.OID)
+				} else if ii.exists(l.OID) {
+					return fmt.Errorf("LAN '%v': duplicate OID (%v)", l.Name, l.OID)
+				}
+
+				l.ch = ii.ch
+				ii.lans[l.OID] = &l
+			}
 		}
 	}
 
@@ -100,9 +255,38 @@ func (ii *Interfaces) Load(blob json.RawMessage) error {
 		catalog.PutT(i.CatalogInterface)
 	}
 
+	for _, i := range ii.rests {
+		catalog.PutT(i.CatalogInterface)
+	}
+
+	for _, i := range ii.mqtts {
+		catalog.PutT(i.CatalogInterface)
+	}
+
 	return nil
 }
 
+// Returns true if the OID is a (non-deleted) LAN, uhppoted-rest or uhppoted-mqtt interface.
+func (ii *Interfaces) Has(oid schema.OID) bool {
+	if l, ok := ii.lans[oid]; ok && l != nil && !l.IsDeleted() {
+		return true
+	} else if r, ok := ii.rests[oid]; ok && r != nil && !r.IsDeleted() {
+		return true
+	} else if m, ok := ii.mqtts[oid]; ok && m != nil && !m.IsDeleted() {
+		return true
+	}
+
+	return false
+}
+
+func (ii *Interfaces) exists(oid schema.OID) bool {
+	_, lan := ii.lans[oid]
+	_, rest := ii.rests[oid]
+	_, mqtt := ii.mqtts[oid]
+
+	return lan || rest || mqtt
+}
+
 func (ii Interfaces) Save() (json.RawMessage, error) {
 	if err := ii.Validate(); err != nil {
 		return nil, err
@@ -117,6 +301,22 @@ func (ii Interfaces) Save() (json.RawMessage, error) {
 		}
 	}
 
+	for _, r := range ii.rests {
+		if r.IsValid() && !r.IsDeleted() {
+			if record, err := r.serialize(); err == nil && record != nil {
+				serializable = append(serializable, record)
+			}
+		}
+	}
+
+	for _, m := range ii.mqtts {
+		if m.IsValid() && !m.IsDeleted() {
+			if record, err := m.serialize(); err == nil && record != nil {
+				serializable = append(serializable, record)
+			}
+		}
+	}
+
 	return json.MarshalIndent(serializable, "", "  ")
 }
 
@@ -130,6 +330,22 @@ func (ii Interfaces) Print() {
 		}
 	}
 
+	for _, r := range ii.rests {
+		if r.IsValid() && !r.IsDeleted() {
+			if record, err := r.serialize(); err == nil && record != nil {
+				serializable = append(serializable, record)
+			}
+		}
+	}
+
+	for _, m := range ii.mqtts {
+		if m.IsValid() && !m.IsDeleted() {
+			if record, err := m.serialize(); err == nil && record != nil {
+				serializable = append(serializable, record)
+			}
+		}
+	}
+
 	if b, err := json.MarshalIndent(serializable, "", "  "); err == nil {
 		fmt.Printf("----------------- INTERFACES\n%s\n", string(b))
 	}
@@ -140,7 +356,10 @@ func (ii *Interfaces) Clone() Interfaces {
 	defer guard.RUnlock()
 
 	shadow := Interfaces{
-		lans: map[schema.OID]*LAN{},
+		lans:  map[schema.OID]*LAN{},
+		rests: map[schema.OID]*REST{},
+		mqtts: map[schema.OID]*MQTT{},
+		ch:    ii.ch,
 	}
 
 	for k, v := range ii.lans {
@@ -148,6 +367,16 @@ func (ii *Interfaces) Clone() Interfaces {
 		shadow.lans[k] = &clone
 	}
 
+	for k, v := range ii.rests {
+		clone := v.Clone()
+		shadow.rests[k] = &clone
+	}
+
+	for k, v := range ii.mqtts {
+		clone := v.Clone()
+		shadow.mqtts[k] = &clone
+	}
+
 	return shadow
 }
 
@@ -179,69 +408,154 @@ func (ii Interfaces) Validate() error {
 		names[n] = l.Name
 	}
 
+	for k, r := range ii.rests {
+		if r.IsDeleted() {
+			continue
+		}
+
+		if r.OID == "" {
+			return fmt.Errorf("invalid uhppoted-rest interface OID (%v)", r.OID)
+		}
+
+		if k != r.OID {
+			return fmt.Errorf("uhppoted-rest interface %s: mismatched OID %v (expected %v)", r.Name, r.OID, k)
+		}
+
+		if _, ok := ii.lans[k]; ok {
+			return fmt.Errorf("uhppoted-rest interface %s: duplicate OID %v", r.Name, r.OID)
+		}
+
+		if err := r.validate(); err != nil {
+			return err
+		}
+
+		n := strings.TrimSpace(strings.ToLower(r.Name))
+		if v, ok := names[n]; ok && n != "" {
+			return fmt.Errorf("'%v': duplicate interface name (%v)", r.Name, v)
+		}
+
+		names[n] = r.Name
+	}
+
+	for k, m := range ii.mqtts {
+		if m.IsDeleted() {
+			continue
+		}
+
+		if m.OID == "" {
+			return fmt.Errorf("invalid uhppoted-mqtt interface OID (%v)", m.OID)
+		}
+
+		if k != m.OID {
+			return fmt.Errorf("uhppoted-mqtt interface %s: mismatched OID %v (expected %v)", m.Name, m.OID, k)
+		}
+
+		if _, ok := ii.lans[k]; ok {
+			return fmt.Errorf("uhppoted-mqtt interface %s: duplicate OID %v", m.Name, m.OID)
+		} else if _, ok := ii.rests[k]; ok {
+			return fmt.Errorf("uhppoted-mqtt interface %s: duplicate OID %v", m.Name, m.OID)
+		}
+
+		if err := m.validate(); err != nil {
+			return err
+		}
+
+		n := strings.TrimSpace(strings.ToLower(m.Name))
+		if v, ok := names[n]; ok && n != "" {
+			return fmt.Errorf("'%v': duplicate interface name (%v)", m.Name, v)
+		}
+
+		names[n] = m.Name
+	}
+
 	return nil
 }
 
-func (ii *Interfaces) Search(controllers []types.IController) []uint32 {
+// Searches all the interfaces for controllers, with the configured controllers routed to each
+// interface. Returns the controllers found along with the interface that found each controller,
+// which is the interface assigned to the controller if it was found on more than one interface
+// (or otherwise the interface with the lowest OID).
+func (ii *Interfaces) Search(controllers []types.IController) map[uint32]schema.OID {
 	var mutex sync.Mutex
 	var wg sync.WaitGroup
-	var found = map[uint32]struct{}{}
+	var routed = map[schema.OID][]types.IController{}
+	var assigned = map[uint32]schema.OID{}
+	var found = map[uint32]schema.OID{}
+
+	for _, c := range controllers {
+		if oid, _, ok := ii.resolve(c); ok {
+			routed[oid] = append(routed[oid], c)
+			assigned[c.ID()] = oid
+		}
+	}
 
-	f := func(lan *LAN) {
+	f := func(oid schema.OID, i iface) {
 		defer wg.Done()
 
-		if list, err := lan.search(controllers); err != nil {
+		if list, err := i.search(routed[oid]); err != nil {
 			log.Warnf("%v", err)
 		} else {
 			mutex.Lock()
 			defer mutex.Unlock()
 			for _, v := range list {
-				found[v] = struct{}{}
+				if other, ok := found[v]; !ok || (other != assigned[v] && (oid == assigned[v] || oid.Compare(other) < 0)) {
+					found[v] = oid
+				}
 			}
 		}
-
 	}
 
-	for _, l := range ii.lans {
-		lan := l
-		wg.Add(1)
-		go f(lan)
+	for oid, l := range ii.lans {
+		if l != nil && !l.IsDeleted() {
+			lan := *l
+			wg.Add(1)
+			go f(oid, &lan)
+		}
 	}
 
-	wg.Wait()
+	for oid := range ii.rests {
+		if i, ok := ii.gateway(oid); ok {
+			wg.Add(1)
+			go f(oid, i)
+		}
+	}
 
-	list := []uint32{}
-	for k := range found {
-		list = append(list, k)
+	for oid := range ii.mqtts {
+		if i, ok := ii.gateway(oid); ok {
+			wg.Add(1)
+			go f(oid, i)
+		}
 	}
 
-	return list
+	wg.Wait()
+
+	return found
 }
 
 func (ii *Interfaces) Refresh(controllers []types.IController) {
-	if lan, ok := ii.LAN(); ok {
-		var wg sync.WaitGroup
+	var wg sync.WaitGroup
 
-		for _, c := range controllers {
+	for _, c := range controllers {
+		if i, ok := ii.route(c); ok {
 			wg.Add(1)
 
 			controller := c
 
 			go func(v types.IController) {
 				defer wg.Done()
-				lan.refresh(controller)
+				i.refresh(controller)
 			}(controller)
 		}
-
-		wg.Wait()
 	}
+
+	wg.Wait()
 }
 
 func (ii *Interfaces) GetEvents(controllers []types.IController, missing map[uint32][]types.Interval) {
-	if lan, ok := ii.LAN(); ok {
-		var wg sync.WaitGroup
+	var wg sync.WaitGroup
 
-		for _, c := range controllers {
+	for _, c := range controllers {
+		if i, ok := ii.route(c); ok {
 			wg.Add(1)
 
 			controller := c
@@ -249,31 +563,31 @@ func (ii *Interfaces) GetEvents(controllers []types.IController, missing map[uin
 
 			go func(v types.IController) {
 				defer wg.Done()
-				lan.getEvents(controller, intervals)
+				i.getEvents(controller, intervals)
 			}(controller)
 		}
-
-		wg.Wait()
 	}
+
+	wg.Wait()
 }
 
 func (ii *Interfaces) SetTime(controller types.IController, t time.Time) {
-	if lan, ok := ii.LAN(); ok {
-		lan.setTime(controller, t)
+	if i, ok := ii.route(controller); ok {
+		i.setTime(controller, t)
 	}
 }
 
 func (ii *Interfaces) SetDoor(controller types.IController, door uint8, mode lib.ControlState, delay uint8) {
-	if lan, ok := ii.LAN(); ok {
-		if err := lan.setDoor(controller, door, mode, delay); err != nil {
+	if i, ok := ii.route(controller); ok {
+		if err := i.setDoor(controller, door, mode, delay); err != nil {
 			log.Warnf("%v", err)
 		}
 	}
 }
 
 func (ii *Interfaces) SetDoorControl(controller types.IController, door uint8, mode lib.ControlState) {
-	if lan, ok := ii.LAN(); ok {
-		if err := lan.setDoor(controller, door, mode, 0); err != nil {
+	if i, ok := ii.route(controller); ok {
+		if err := i.setDoor(controller, door, mode, 0); err != nil {
 			log.Warnf("%v", err)
 		} else if oid, ok := controller.Door(door); ok {
 			catalog.PutV(oid, DoorControl, mode)
@@ -283,8 +597,8 @@ func (ii *Interfaces) SetDoorControl(controller types.IController, door uint8, m
 }
 
 func (ii *Interfaces) SetDoorDelay(controller types.IController, door uint8, delay uint8) {
-	if lan, ok := ii.LAN(); ok {
-		if err := lan.setDoor(controller, door, lib.ModeUnknown, delay); err != nil {
+	if i, ok := ii.route(controller); ok {
+		if err := i.setDoor(controller, door, lib.ModeUnknown, delay); err != nil {
 			log.Warnf("%v", err)
 		} else if oid, ok := controller.Door(door); ok {
 			catalog.PutV(oid, DoorDelay, delay)
@@ -293,9 +607,17 @@ func (ii *Interfaces) SetDoorDelay(controller types.IController, door uint8, del
 	}
 }
 
+func (ii *Interfaces) OpenDoor(controller types.IController, door uint8) error {
+	if i, ok := ii.route(controller); ok {
+		return i.openDoor(controller, door)
+	}
+
+	return fmt.Errorf("no interface for controller %v", controller.ID())
+}
+
 func (ii *Interfaces) SetInterlock(controller types.IController, interlock lib.Interlock) {
-	if lan, ok := ii.LAN(); ok {
-		if err := lan.setInterlock(controller, interlock); err != nil {
+	if i, ok := ii.route(controller); ok {
+		if err := i.setInterlock(controller, interlock); err != nil {
 			log.Warnf("%v", err)
 		} else {
 			log.Infof("%v  set interlock %v", controller.ID(), interlock)
@@ -304,16 +626,16 @@ func (ii *Interfaces) SetInterlock(controller types.IController, interlock lib.I
 }
 
 func (ii *Interfaces) SetAntiPassback(controller types.IController, antipassback lib.AntiPassback) {
-	if lan, ok := ii.LAN(); ok {
-		if err := lan.setAntiPassback(controller, antipassback); err != nil {
+	if i, ok := ii.route(controller); ok {
+		if err := i.setAntiPassback(controller, antipassback); err != nil {
 			log.Warnf("%v", err)
 		}
 	}
 }
 
 func (ii *Interfaces) ActivateKeypads(controller types.IController, keypads map[uint8]bool) {
-	if lan, ok := ii.LAN(); ok {
-		if err := lan.activateKeypads(controller, keypads); err != nil {
+	if i, ok := ii.route(controller); ok {
+		if err := i.activateKeypads(controller, keypads); err != nil {
 			log.Warnf("%v", err)
 		} else {
 			log.Infof("%v  activate/deactivated keypads 1:%v, 2:%v, 3:%v, 4:%v",
@@ -327,8 +649,8 @@ func (ii *Interfaces) ActivateKeypads(controller types.IController, keypads map[
 }
 
 func (ii *Interfaces) SetDoorPasscodes(controller types.IController, door uint8, passcodes ...uint32) {
-	if lan, ok := ii.LAN(); ok {
-		if err := lan.setDoorPasscodes(controller, door, passcodes...); err != nil {
+	if i, ok := ii.route(controller); ok {
+		if err := i.setDoorPasscodes(controller, door, passcodes...); err != nil {
 			log.Warnf("%v", err)
 		} else {
 			log.Infof("%v  set door %v passcodes", controller.ID(), door)
@@ -337,23 +659,120 @@ func (ii *Interfaces) SetDoorPasscodes(controller types.IController, door uint8,
 }
 
 func (ii *Interfaces) PutCard(controller types.IController, card uint32, PIN uint32, from, to lib.Date, permissions map[uint8]uint8) {
-	if lan, ok := ii.LAN(); ok {
-		lan.putCard(controller, card, PIN, from, to, permissions)
+	if i, ok := ii.route(controller); ok {
+		i.putCard(controller, card, PIN, from, to, permissions)
 	}
 }
 
 func (ii *Interfaces) DeleteCard(controller types.IController, card uint32) {
-	if lan, ok := ii.LAN(); ok {
-		lan.deleteCard(controller, card)
+	if i, ok := ii.route(controller); ok {
+		i.deleteCard(controller, card)
 	}
 }
 
-func (ii *Interfaces) CompareACL(controllers []types.IController, permissions acl.ACL, withPIN bool) (map[uint32]acl.Diff, error) {
-	if lan, ok := ii.LAN(); ok {
-		return lan.compareACL(controllers, permissions, withPIN)
+func (ii *Interfaces) PutTimeProfile(controller types.IController, profile lib.TimeProfile) {
+	if i, ok := ii.route(controller); ok {
+		if err := i.putTimeProfile(controller, profile); err != nil {
+			log.Warnf("%v", err)
+		} else {
+			log.Infof("%v  set time profile %v", controller.ID(), profile)
+		}
+	}
+}
+
+// Compares the controller cards and time profiles with the system ACL and time profiles. Returns
+// the card differences and the list of time profiles that are missing or different for each
+// controller.
+func (ii *Interfaces) CompareACL(controllers []types.IController, permissions acl.ACL, profiles []lib.TimeProfile, withPIN bool) (map[uint32]acl.Diff, map[uint32][]uint8, error) {
+	routed := map[schema.OID][]types.IController{}
+	interfaces := map[schema.OID]iface{}
+
+	for _, c := range controllers {
+		if oid, i, ok := ii.resolve(c); ok {
+			routed[oid] = append(routed[oid], c)
+			interfaces[oid] = i
+		}
+	}
+
+	if len(routed) == 0 {
+		return nil, nil, nil
+	}
+
+	diffs := map[uint32]acl.Diff{}
+	mismatched := map[uint32][]uint8{}
+
+	for _, oid := range slices.SortedFunc(maps.Keys(routed), schema.OID.Compare) {
+		list := routed[oid]
+
+		if diff, m, err := interfaces[oid].compareACL(list, subset(permissions, list), profiles, withPIN); err != nil {
+			return nil, nil, err
+		} else {
+			maps.Copy(diffs, diff)
+			maps.Copy(mismatched, m)
+		}
+	}
+
+	return diffs, mismatched, nil
+}
+
+// Returns the ACL entries for a list of controllers.
+func subset(permissions acl.ACL, controllers []types.IController) acl.ACL {
+	list := acl.ACL{}
+	for _, c := range controllers {
+		if v, ok := permissions[c.ID()]; ok {
+			list[c.ID()] = v
+		}
 	}
 
-	return nil, nil
+	return list
+}
+
+// Returns 'incomplete' if any of the missing event intervals overlaps the controller event range.
+func eventsStatus(intervals []types.Interval, first, last uint32) types.Status {
+	for _, interval := range intervals {
+		if interval.Contains(last) || interval.Contains(first) || (interval.From >= first && interval.To <= last) {
+			return types.StatusIncomplete
+		}
+	}
+
+	return types.StatusOk
+}
+
+// Invokes f for each missing event in the controller event range, retrieving at most MAX events
+// at the (rolling) start and end of the range.
+func retrieve(intervals []types.Interval, first, last uint32, f func(index uint32)) {
+	count := 0
+
+	g := func(index uint32) {
+		f(index)
+		count++
+	}
+
+	for _, interval := range intervals {
+		if interval.Contains(last) {
+			index := max(interval.From, first)
+
+			for index <= last && count < MAX {
+				g(index)
+				index++
+			}
+		}
+
+		if interval.Contains(first) {
+			index := min(interval.To, last)
+
+			for index >= first && count < MAX {
+				g(index)
+				index--
+			}
+		}
+
+		if interval.From >= first && interval.To <= last {
+			for index := interval.From; index <= interval.To; index++ {
+				g(index)
+			}
+		}
+	}
 }
 
 func lock(id uint32) {
@@ -0,0 +1,92 @@
+package interfaces
+
+import (
+	"maps"
+	"testing"
+
+	"github.com/uhppoted/uhppoted-httpd/system/catalog"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+	"github.com/uhppoted/uhppoted-httpd/types"
+)
+
+func TestDefaultLAN(t *testing.T) {
+	ii := Interfaces{
+		lans: map[schema.OID]*LAN{
+			"0.1.10": {CatalogInterface: catalog.CatalogInterface{OID: "0.1.10"}, Name: "VLAN-10"},
+			"0.1.3":  {CatalogInterface: catalog.CatalogInterface{OID: "0.1.3"}, Name: "VLAN-3"},
+			"0.1.2":  {CatalogInterface: catalog.CatalogInterface{OID: "0.1.2"}, Name: "VLAN-2", deleted: types.TimestampNow()},
+		},
+	}
+
+	if lan, ok := ii.LAN(); !ok {
+		t.Fatalf("Expected default LAN")
+	} else if lan.OID != "0.1.3" {
+		t.Errorf("Incorrect default LAN - expected:%v, got:%v", "0.1.3", lan.OID)
+	}
+}
+
+func TestRouteWithMultipleLANs(t *testing.T) {
+	ii := Interfaces{
+		lans: map[schema.OID]*LAN{
+			"0.1.1": {CatalogInterface: catalog.CatalogInterface{OID: "0.1.1"}, Name: "VLAN-1"},
+			"0.1.2": {CatalogInterface: catalog.CatalogInterface{OID: "0.1.2"}, Name: "VLAN-2"},
+			"0.1.3": {CatalogInterface: catalog.CatalogInterface{OID: "0.1.3"}, Name: "VLAN-3"},
+			"0.1.4": {CatalogInterface: catalog.CatalogInterface{OID: "0.1.4"}, Name: "VLAN-4", deleted: types.TimestampNow()},
+		},
+		rests: map[schema.OID]*REST{
+			"0.1.5": {CatalogInterface: catalog.CatalogInterface{OID: "0.1.5"}, Name: "REST", URL: "http://127.0.0.1:8080"},
+		},
+	}
+
+	tests := []struct {
+		iface    schema.OID
+		expected schema.OID
+	}{
+		{"", "0.1.1"},
+		{"0.1.1", "0.1.1"},
+		{"0.1.2", "0.1.2"},
+		{"0.1.3", "0.1.3"},
+		{"0.1.4", ""},
+		{"0.1.5", "0.1.5"},
+		{"0.1.9", ""},
+	}
+
+	for _, test := range tests {
+		if oid, i, ok := ii.resolve(controller{id: 405419896, iface: test.iface}); ok && test.expected == "" {
+			t.Errorf("Unexpected interface %v for controller with interface '%v'", oid, test.iface)
+		} else if !ok && test.expected != "" {
+			t.Errorf("No interface for controller with interface '%v'", test.iface)
+		} else if oid != test.expected {
+			t.Errorf("Incorrect interface for '%v' - expected:%v, got:%v", test.iface, test.expected, oid)
+		} else if l, ok := i.(*LAN); ok && l.OID != test.expected {
+			t.Errorf("Incorrect LAN for '%v' - expected:%v, got:%v", test.iface, test.expected, l.OID)
+		}
+	}
+}
+
+func TestSearchWithMultipleInterfaces(t *testing.T) {
+	server1 := restStub(nil)
+	server2 := restStub(nil)
+	defer server1.Close()
+	defer server2.Close()
+
+	ii := Interfaces{
+		rests: map[schema.OID]*REST{
+			"0.1.12": {CatalogInterface: catalog.CatalogInterface{OID: "0.1.12"}, Name: "REST-12", URL: server1.URL},
+			"0.1.6":  {CatalogInterface: catalog.CatalogInterface{OID: "0.1.6"}, Name: "REST-6", URL: server2.URL},
+		},
+	}
+
+	controllers := []types.IController{
+		controller{oid: "0.2.1", id: 405419896, iface: "0.1.12"},
+	}
+
+	expected := map[uint32]schema.OID{
+		405419896: "0.1.12",
+		303986753: "0.1.6",
+	}
+
+	if found := ii.Search(controllers); !maps.Equal(found, expected) {
+		t.Errorf("Incorrect search result\n   expected:%v\n   got:     %v", expected, found)
+	}
+}
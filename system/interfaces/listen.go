@@ -0,0 +1,228 @@
+package interfaces
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	lib "github.com/uhppoted/uhppote-core/types"
+	"github.com/uhppoted/uhppote-core/uhppote"
+	"github.com/uhppoted/uhppoted-lib/uhppoted"
+
+	"github.com/uhppoted/uhppoted-httpd/log"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+	"github.com/uhppoted/uhppoted-httpd/types"
+)
+
+type listener struct {
+	name    string
+	address lib.ListenAddr
+	q       chan os.Signal
+}
+
+type eventListener struct {
+	name    string
+	address lib.ListenAddr
+	ch      chan types.EventsList
+}
+
+var listeners = struct {
+	listeners map[schema.OID]*listener
+	sync.Mutex
+}{
+	listeners: map[schema.OID]*listener{},
+}
+
+const RETRY = 30 * time.Second
+
+// Starts an event listener for each LAN interface with a valid listen address and subscribes
+// to the event topic for each uhppoted-mqtt interface. Listeners for deleted interfaces (or for
+// which the listen address has changed) are stopped and, if required, restarted on the updated
+// address. In simulation mode only the default LAN interface listens for events (the simulator
+// delivers all events to every listener) and the uhppoted-mqtt interfaces are not connected.
+func (ii *Interfaces) Listen() {
+	listeners.Lock()
+	defer listeners.Unlock()
+
+	active := map[schema.OID]struct{}{}
+	dflt, _ := ii.LAN()
+
+	for oid, lan := range ii.lans {
+		if lan == nil || lan.IsDeleted() || !lan.ListenAddress.IsValid() {
+			continue
+		} else if simulation != nil && oid != dflt.OID {
+			continue
+		}
+
+		active[oid] = struct{}{}
+
+		if l, ok := listeners.listeners[oid]; ok && l.address == lan.ListenAddress {
+			continue
+		} else if ok {
+			l.stop()
+			delete(listeners.listeners, oid)
+		}
+
+		l := listener{
+			name:    lan.Name,
+			address: lan.ListenAddress,
+			q:       make(chan os.Signal),
+		}
+
+		listeners.listeners[oid] = &l
+
+		go l.run(lan.Clone(), ii.ch)
+	}
+
+	for oid, l := range listeners.listeners {
+		if _, ok := active[oid]; !ok {
+			l.stop()
+			delete(listeners.listeners, oid)
+		}
+	}
+
+	subscribed := map[schema.OID]struct{}{}
+	for oid, m := range ii.mqtts {
+		if m == nil || m.IsDeleted() || simulation != nil {
+			continue
+		}
+
+		if err := m.listen(ii.ch); err != nil {
+			log.Warnf("%v  %v", m.Name, err)
+		} else {
+			subscribed[oid] = struct{}{}
+		}
+	}
+
+	connections.Lock()
+	var closed []schema.OID
+	for oid := range connections.connections {
+		if _, ok := subscribed[oid]; !ok {
+			closed = append(closed, oid)
+		}
+	}
+	connections.Unlock()
+
+	for _, oid := range closed {
+		disconnect(oid)
+	}
+}
+
+// A long-running function i.e. expects to be invoked from an external goroutine. Retries
+// at RETRY intervals if the listen socket could not be opened (e.g. address in use).
+func (l *listener) run(lan LAN, ch chan types.EventsList) {
+	for {
+		if err := lan.listen(ch, l.q); err != nil {
+			log.Warnf("%v  %v", lan.Name, err)
+		} else {
+			log.Infof("%v  closed event listener on %v", l.name, l.address)
+			return
+		}
+
+		select {
+		case <-l.q:
+			return
+
+		case <-time.After(RETRY):
+		}
+	}
+}
+
+func (l *listener) stop() {
+	close(l.q)
+}
+
+// Binds to the LAN listen address and forwards events pushed by the controllers to the
+// events channel. Blocks until the 'q' channel is closed.
+func (l *LAN) listen(ch chan types.EventsList, q chan os.Signal) error {
+	if !l.ListenAddress.IsValid() {
+		return fmt.Errorf("invalid listen address (%v)", l.ListenAddress)
+	}
+
+	u := l.uhppote([]uhppote.Device{})
+	handler := eventListener{
+		name:    l.Name,
+		address: l.ListenAddress,
+		ch:      ch,
+	}
+
+	return u.Listen(&handler, q)
+}
+
+func (e *eventListener) OnConnected() {
+	log.Infof("%v  listening for events on %v", e.name, e.address)
+}
+
+func (e *eventListener) OnEvent(status *lib.Status) {
+	if status == nil {
+		return
+	}
+
+	deviceID := uint32(status.SerialNumber)
+	oid := catalog.FindController(deviceID)
+
+	// NTS: discard events from controllers that are not in the system (polling only retrieves events
+	//      for known controllers and the listener should do likewise)
+	if oid == "" {
+		log.Debugf("%v  ignoring event from unknown controller %v", e.name, deviceID)
+		return
+	}
+
+	catalog.PutV(oid, ControllerTouched, time.Now())
+
+	if !status.SystemDateTime.IsZero() {
+		catalog.PutV(oid, ControllerDateTimeCurrent, status.SystemDateTime)
+	}
+
+	putDoorStates(status, func(d uint8) (schema.OID, bool) {
+		fields := map[uint8]schema.Suffix{
+			1: ControllerDoor1,
+			2: ControllerDoor2,
+			3: ControllerDoor3,
+			4: ControllerDoor4,
+		}
+
+		door, ok := catalog.GetV(oid, fields[d]).(schema.OID)
+
+		return door, ok
+	})
+
+	if event := status.Event; !event.IsZero() {
+		received(oid, uhppoted.Event{
+			DeviceID:   deviceID,
+			Index:      event.Index,
+			Type:       event.Type,
+			Granted:    event.Granted,
+			Door:       event.Door,
+			Direction:  event.Direction,
+			CardNumber: event.CardNumber,
+			Timestamp:  event.Timestamp,
+			Reason:     event.Reason,
+		}, e.ch)
+	}
+}
+
+func (e *eventListener) OnError(err error) bool {
+	log.Warnf("%v  %v", e.name, err)
+
+	return true
+}
+
+// Updates the controller event indices for an event pushed by a controller (or gateway) and
+// forwards the event to the events channel.
+func received(oid schema.OID, event uhppoted.Event, ch chan types.EventsList) {
+	catalog.PutV(oid, ControllerEventsCurrent, event.Index)
+
+	if v, ok := catalog.GetV(oid, ControllerEventsLast).(uint32); !ok || event.Index > v {
+		catalog.PutV(oid, ControllerEventsLast, event.Index)
+	}
+
+	if ch != nil {
+		ch <- types.EventsList{
+			DeviceID: event.DeviceID,
+			Events:   []uhppoted.Event{event},
+		}
+	}
+}
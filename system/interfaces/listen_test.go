@@ -0,0 +1,167 @@
+package interfaces
+
+import (
+	"net"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	core "github.com/uhppoted/uhppote-core/types"
+	"github.com/uhppoted/uhppoted-lib/uhppoted"
+
+	"github.com/uhppoted/uhppoted-httpd/system/catalog"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/impl"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+	"github.com/uhppoted/uhppoted-httpd/types"
+)
+
+func TestLANListen(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+	catalog.PutT(catalog.CatalogController{OID: "0.2.1", DeviceID: 423187757})
+	catalog.PutV("0.2.1", ControllerDoor3, schema.OID("0.3.5"))
+
+	message := []byte{
+		0x17, 0x20, 0x00, 0x00, 0x2d, 0x55, 0x39, 0x19, 0x39, 0x00, 0x00, 0x00, 0x01, 0x01, 0x03, 0x01,
+		0xaa, 0xe8, 0x5d, 0x00, 0x20, 0x19, 0x04, 0x19, 0x17, 0x00, 0x09, 0x01, 0x01, 0x00, 0x01, 0x01,
+		0x00, 0x00, 0x01, 0x01, 0x09, 0x14, 0x37, 0x02, 0x11, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x2b, 0x04, 0x01, 0x19, 0x04, 0x20, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+
+	swiped, _ := time.ParseInLocation("2006-01-02 15:04:05", "2019-04-19 17:00:09", time.Local)
+
+	expected := types.EventsList{
+		DeviceID: 423187757,
+		Events: []uhppoted.Event{
+			{
+				DeviceID:   423187757,
+				Index:      57,
+				Type:       1,
+				Granted:    true,
+				Door:       3,
+				Direction:  1,
+				CardNumber: 6154410,
+				Timestamp:  core.DateTime(swiped),
+				Reason:     1,
+			},
+		},
+	}
+
+	port := freePort(t)
+	ch := make(chan types.EventsList)
+	q := make(chan os.Signal)
+
+	l := LAN{
+		Name:          "Le LAN",
+		ListenAddress: core.MustParseListenAddr(port),
+	}
+
+	go func() {
+		if err := l.listen(ch, q); err != nil {
+			t.Errorf("Unexpected error (%v)", err)
+		}
+	}()
+
+	defer close(q)
+
+	time.Sleep(100 * time.Millisecond)
+
+	if err := send(port, message); err != nil {
+		t.Fatalf("Error sending test event (%v)", err)
+	}
+
+	select {
+	case events := <-ch:
+		if !reflect.DeepEqual(events, expected) {
+			t.Errorf("Incorrect event\n   expected:%#v\n   got:     %#v", expected, events)
+		}
+
+	case <-time.After(1 * time.Second):
+		t.Fatalf("Timeout waiting for event")
+	}
+
+	if v := catalog.GetV("0.2.1", ControllerEventsLast); v != uint32(57) {
+		t.Errorf("Controller 'last event' not updated - expected:%v, got:%v", 57, v)
+	}
+
+	for _, v := range []struct {
+		field    schema.Suffix
+		expected bool
+	}{
+		{DoorStateOpen, true},
+		{DoorStateButton, true},
+		{DoorStateUnlocked, true},
+	} {
+		if state, ok := catalog.GetBool("0.3.5", v.field); !ok || state != v.expected {
+			t.Errorf("Door state %v not updated - expected:%v, got:%v", v.field, v.expected, state)
+		}
+	}
+}
+
+func TestLANListenWithUnknownController(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+
+	message := []byte{
+		0x17, 0x20, 0x00, 0x00, 0x2d, 0x55, 0x39, 0x19, 0x39, 0x00, 0x00, 0x00, 0x01, 0x01, 0x03, 0x01,
+		0xaa, 0xe8, 0x5d, 0x00, 0x20, 0x19, 0x04, 0x19, 0x17, 0x00, 0x09, 0x01, 0x01, 0x00, 0x01, 0x01,
+		0x00, 0x00, 0x01, 0x01, 0x09, 0x14, 0x37, 0x02, 0x11, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x2b, 0x04, 0x01, 0x19, 0x04, 0x20, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+
+	port := freePort(t)
+	ch := make(chan types.EventsList)
+	q := make(chan os.Signal)
+
+	l := LAN{
+		Name:          "Le LAN",
+		ListenAddress: core.MustParseListenAddr(port),
+	}
+
+	go func() {
+		l.listen(ch, q)
+	}()
+
+	defer close(q)
+
+	time.Sleep(100 * time.Millisecond)
+
+	if err := send(port, message); err != nil {
+		t.Fatalf("Error sending test event (%v)", err)
+	}
+
+	select {
+	case events := <-ch:
+		t.Errorf("Unexpected event from unknown controller (%v)", events)
+
+	case <-time.After(250 * time.Millisecond):
+	}
+}
+
+func freePort(t *testing.T) string {
+	c, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Error allocating UDP port (%v)", err)
+	}
+
+	defer c.Close()
+
+	return c.LocalAddr().String()
+}
+
+func send(addr string, message []byte) error {
+	dest, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return err
+	}
+
+	c, err := net.DialUDP("udp", nil, dest)
+	if err != nil {
+		return err
+	}
+
+	defer c.Close()
+
+	_, err = c.Write(message)
+
+	return err
+}
@@ -13,6 +13,9 @@ const LANName = schema.InterfaceName
 const LANBindAddress = schema.LANBindAddress
 const LANBroadcastAddress = schema.LANBroadcastAddress
 const LANListenAddress = schema.LANListenAddress
+const RESTURL = schema.RESTURL
+const MQTTBroker = schema.MQTTBroker
+const MQTTClientID = schema.MQTTClientID
 
 const ControllerTouched = schema.Touched
 const ControllerEndpointAddress = schema.ControllerEndpointAddress
@@ -33,6 +36,13 @@ const DoorDelayModified = schema.DoorDelayModified
 const DoorControl = schema.DoorControl
 const DoorControlModified = schema.DoorControlModified
 const DoorControlConfigured = schema.DoorControlConfigured
+const DoorStateOpen = schema.DoorStateOpen
+const DoorStateButton = schema.DoorStateButton
+const DoorStateUnlocked = schema.DoorStateUnlocked
+const ControllerDoor1 = schema.ControllerDoor1
+const ControllerDoor2 = schema.ControllerDoor2
+const ControllerDoor3 = schema.ControllerDoor3
+const ControllerDoor4 = schema.ControllerDoor4
 
 var lookup = map[schema.Suffix]string{
 	LANStatus:           "LAN.status",
@@ -43,4 +53,7 @@ var lookup = map[schema.Suffix]string{
 	LANBindAddress:      "LAN.address.bind",
 	LANBroadcastAddress: "LAN.address.broadcast",
 	LANListenAddress:    "LAN.address.listen",
+	RESTURL:             "REST.url",
+	MQTTBroker:          "MQTT.broker",
+	MQTTClientID:        "MQTT.client-id",
 }
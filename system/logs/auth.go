@@ -1,7 +1,12 @@
 package logs
 
 import (
+	"strconv"
+	"strings"
+
 	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
 )
 
 type TAuthable interface {
@@ -13,7 +18,13 @@ type TAuthable interface {
 
 var rulesets = []auth.RuleSet{auth.Logs}
 
+// A scoped administrator can only view the log entries for the cards, doors, groups and
+// controllers in scope.
 func CanView[T TAuthable](a auth.OpAuth, u T, field string, value any) error {
+	if !inScope(auth.ScopeOf(a), LogEntry(u)) {
+		return auth.ErrUnauthorised
+	}
+
 	return auth.CanView(a, u, field, value, rulesets...)
 }
 
@@ -28,3 +39,43 @@ func CanUpdate[T TAuthable](a auth.OpAuth, u T, field string, value any) error {
 func CanDelete[T TAuthable](a auth.OpAuth, u T) error {
 	return auth.CanDelete(a, u, rulesets...)
 }
+
+func inScope(scope *auth.Scope, l LogEntry) bool {
+	if !scope.IsScoped() {
+		return true
+	}
+
+	switch l.Item {
+	case "card":
+		if card, err := strconv.ParseUint(l.ItemID, 10, 32); err == nil {
+			return scope.HasCard(uint32(card))
+		}
+
+	case "door":
+		// ... door log entries are identified by 'device-id/door'
+		if device, door, ok := strings.Cut(l.ItemID, "/"); ok {
+			deviceID, err1 := strconv.ParseUint(device, 10, 32)
+			d, err2 := strconv.ParseUint(door, 10, 8)
+
+			if err1 == nil && err2 == nil {
+				if oid := catalog.FindDoor(uint32(deviceID), uint8(d)); oid != "" {
+					return scope.HasDoor(string(oid))
+				}
+			}
+		}
+
+	case "group":
+		if oid, ok := catalog.Find(schema.GroupsOID, schema.GroupName, l.ItemName); ok {
+			return scope.HasGroup(string(oid.Trim(schema.GroupName)))
+		}
+
+	case "controller":
+		if deviceID, err := strconv.ParseUint(l.ItemID, 10, 32); err == nil {
+			if oid := catalog.FindController(uint32(deviceID)); oid != "" {
+				return scope.HasController(string(oid))
+			}
+		}
+	}
+
+	return false
+}
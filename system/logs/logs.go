@@ -94,7 +94,7 @@ func (ll *Logs) AsObjects(start, max int, auth auth.OpAuth) []schema.Object {
 		k := keys[ix]
 		if l, ok := ll.logs[k]; ok {
 			if l.IsValid() || l.IsDeleted() {
-				if l := l.AsObjects(auth); l != nil {
+				if l := l.AsObjects(auth); len(l) > 0 {
 					catalog.Join(&objects, l...)
 					count++
 				}
@@ -114,6 +114,29 @@ func (ll *Logs) AsObjects(start, max int, auth auth.OpAuth) []schema.Object {
 	return objects
 }
 
+// Returns the objects for the log entries with the matching OIDs.
+func (ll *Logs) AsObjectsByOID(auth auth.OpAuth, oids ...schema.OID) []schema.Object {
+	guard.RLock()
+	defer guard.RUnlock()
+
+	objects := []schema.Object{}
+	set := map[schema.OID]bool{}
+
+	for _, oid := range oids {
+		set[oid] = true
+	}
+
+	for _, l := range ll.logs {
+		if set[l.OID] && (l.IsValid() || l.IsDeleted()) {
+			if l := l.AsObjects(auth); len(l) > 0 {
+				catalog.Join(&objects, l...)
+			}
+		}
+	}
+
+	return objects
+}
+
 func (ll *Logs) UpdateByOID(auth auth.OpAuth, oid schema.OID, value string) ([]any, error) {
 	if ll == nil {
 		return nil, nil
@@ -217,7 +240,14 @@ func (ll Logs) Validate() error {
 	return nil
 }
 
-func (ll *Logs) Received(records ...audit.AuditRecord) {
+// Adds the audit records to the logs, returning the serialized added log entries so that they
+// can be appended to the logs store without saving all the logs.
+func (ll *Logs) Received(records ...audit.AuditRecord) []json.RawMessage {
+	guard.Lock()
+	defer guard.Unlock()
+
+	added := []json.RawMessage{}
+
 	for _, record := range records {
 		unknown := time.Time{}
 		timestamp := record.Timestamp
@@ -225,10 +255,7 @@ func (ll *Logs) Received(records ...audit.AuditRecord) {
 			timestamp = time.Now()
 		}
 
-		guard.Lock()
-		defer guard.Unlock()
-
-		k := newKey(record.Timestamp,
+		k := newKey(timestamp,
 			record.UID,
 			record.Component,
 			record.Details.ID,
@@ -238,9 +265,18 @@ func (ll *Logs) Received(records ...audit.AuditRecord) {
 
 		if _, ok := ll.logs[k]; !ok {
 			oid := catalog.NewT(LogEntry{}.CatalogLogEntry)
-			ll.logs[k] = NewLogEntry(oid, timestamp, record)
+			entry := NewLogEntry(oid, timestamp, record)
+			ll.logs[k] = entry
+
+			if entry.IsValid() && !entry.IsDeleted() {
+				if record, err := entry.serialize(); err == nil && record != nil {
+					added = append(added, record)
+				}
+			}
 		}
 	}
+
+	return added
 }
 
 // func (ll Logs) LookupController(timestamp time.Time, deviceID uint32) string {
@@ -9,6 +9,7 @@ import (
 
 	"github.com/uhppoted/uhppoted-httpd/auth"
 	"github.com/uhppoted/uhppoted-httpd/system/catalog"
+	memdb "github.com/uhppoted/uhppoted-httpd/system/catalog/impl"
 	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
 	"github.com/uhppoted/uhppoted-httpd/types"
 )
@@ -90,3 +91,61 @@ func TestLogEntryAsObjectsWithAuth(t *testing.T) {
 		t.Errorf("Incorrect return from AsObjects:\n   expected:%#v\n   got:     %#v", expected, objects)
 	}
 }
+
+func TestLogEntryInScope(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+	catalog.PutT(catalog.CatalogController{OID: "0.2.1", DeviceID: 405419896})
+	catalog.PutT(catalog.CatalogController{OID: "0.2.2", DeviceID: 303986753})
+	catalog.PutV("0.2.1", schema.ControllerDoor1, schema.OID("0.3.1"))
+	catalog.PutV("0.2.1", schema.ControllerDoor3, schema.OID("0.3.3"))
+	catalog.PutT(catalog.CatalogGroup{OID: "0.5.1"})
+	catalog.PutT(catalog.CatalogGroup{OID: "0.5.2"})
+	catalog.PutV("0.5.1", schema.GroupName, "Teachers")
+	catalog.PutV("0.5.2", schema.GroupName, "Students")
+
+	auth.SetCardGroups(func(card uint32) ([]string, bool) {
+		switch card {
+		case 8165537:
+			return []string{"0.5.1"}, true
+		case 8165538:
+			return []string{"0.5.2"}, true
+		}
+
+		return nil, false
+	})
+
+	defer auth.SetCardGroups(nil)
+
+	scope := &auth.Scope{
+		Groups:      []string{"0.5.1"},
+		Doors:       []string{"0.3.3"},
+		Controllers: []string{"0.2.1"},
+	}
+
+	entry := func(item, id, name string) LogEntry {
+		return LogEntry{Item: item, ItemID: id, ItemName: name}
+	}
+
+	tests := []struct {
+		scope    *auth.Scope
+		entry    LogEntry
+		expected bool
+	}{
+		{nil, entry("user", "", "Admin"), true},
+		{scope, entry("card", "8165537", "Hermione"), true},
+		{scope, entry("card", "8165538", "Harry"), false},
+		{scope, entry("door", "405419896/3", "Dungeon"), true},
+		{scope, entry("door", "405419896/1", "Great Hall"), false},
+		{scope, entry("group", "", "Teachers"), true},
+		{scope, entry("group", "", "Students"), false},
+		{scope, entry("controller", "405419896", "Alpha"), true},
+		{scope, entry("controller", "303986753", "Beta"), false},
+		{scope, entry("user", "", "Admin"), false},
+	}
+
+	for _, test := range tests {
+		if v := inScope(test.scope, test.entry); v != test.expected {
+			t.Errorf("Incorrect scope for %v log entry %v - expected:%v, got:%v", test.entry.Item, test.entry.ItemName, test.expected, v)
+		}
+	}
+}
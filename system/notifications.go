@@ -0,0 +1,41 @@
+package system
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/uhppoted/uhppoted-httpd/types"
+	"github.com/uhppoted/uhppoted-lib/uhppoted"
+)
+
+// Checks for controllers that have gone offline and for cards that are about to expire.
+func (s *system) alerts() {
+	for _, c := range s.controllers.List() {
+		if c.DeviceID != 0 && !c.IsDeleted() {
+			status := c.Status()
+			online := status == types.StatusOk || status == types.StatusUncertain
+
+			s.notifications.Controller(c.DeviceID, fmt.Sprintf("%v", &c), online)
+		}
+	}
+
+	for _, c := range s.cards.List() {
+		if c.CardID != 0 && !c.IsDeleted() {
+			s.notifications.CardExpiry(c.CardID, fmt.Sprintf("%v", c), time.Time(c.To()))
+		}
+	}
+}
+
+// Counts denied card swipes for the 'repeated denied swipes' alert.
+func (s *system) denied(e uhppoted.Event, door, card string) {
+	const swipe = 1
+
+	if e.Type == swipe && !e.Granted && e.CardNumber != 0 {
+		name := fmt.Sprintf("%v", e.CardNumber)
+		if card != "" {
+			name = fmt.Sprintf("%v (%v)", e.CardNumber, card)
+		}
+
+		s.notifications.Denied(e.CardNumber, name, door, time.Time(e.Timestamp))
+	}
+}
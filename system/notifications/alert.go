@@ -0,0 +1,37 @@
+package notifications
+
+import (
+	"time"
+)
+
+type AlertType string
+
+const (
+	ControllerOffline AlertType = "controller-offline"
+	ACLOutOfSync      AlertType = "acl-out-of-sync"
+	CardExpiring      AlertType = "card-expiring"
+	DeniedSwipes      AlertType = "denied-swipes"
+	UserLocked        AlertType = "user-locked"
+)
+
+var alertTypes = []AlertType{
+	ControllerOffline,
+	ACLOutOfSync,
+	CardExpiring,
+	DeniedSwipes,
+	UserLocked,
+}
+
+// Alert is the notification sent to the sinks configured for the alert type.
+type Alert struct {
+	Type      AlertType `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	Subject   string    `json:"subject"`
+	Message   string    `json:"message"`
+
+	key string
+}
+
+func (a Alert) String() string {
+	return a.Subject
+}
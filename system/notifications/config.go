@@ -0,0 +1,150 @@
+package notifications
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// config is the JSON notifications configuration file e.g.
+//
+//	{
+//	  "suppress": "1h",
+//	  "card-expiry": { "days": 14 },
+//	  "denied-swipes": { "count": 3, "window": "5m" },
+//	  "sinks": [
+//	    { "name": "email", "type": "smtp", "server": "localhost:25", "from": "uhppoted@example.com", "to": [ "security@example.com" ] },
+//	    { "name": "ops", "type": "webhook", "url": "https://example.com/hooks/uhppoted" }
+//	  ],
+//	  "rules": [
+//	    { "alerts": [ "controller-offline", "acl-out-of-sync" ], "sink": "ops" },
+//	    { "alerts": [ "card-expiring" ], "sink": "email", "to": [ "reception@example.com" ] },
+//	    { "alerts": [ "*" ], "sink": "email" }
+//	  ]
+//	}
+type config struct {
+	Suppress   duration `json:"suppress"`
+	CardExpiry struct {
+		Days int `json:"days"`
+	} `json:"card-expiry"`
+	DeniedSwipes struct {
+		Count  int      `json:"count"`
+		Window duration `json:"window"`
+	} `json:"denied-swipes"`
+	Sinks []sinkConfig `json:"sinks"`
+	Rules []Rule       `json:"rules"`
+}
+
+type sinkConfig struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+
+	// ... SMTP
+	Server   string   `json:"server,omitempty"`
+	From     string   `json:"from,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	To       []string `json:"to,omitempty"`
+
+	// ... webhook
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// ... script
+	Command string   `json:"command,omitempty"`
+	Args    []string `json:"args,omitempty"`
+
+	Timeout duration `json:"timeout,omitempty"`
+}
+
+// Rule routes the listed alert types to a sink. The optional 'to' list overrides the
+// default recipients for the sink.
+type Rule struct {
+	Alerts []AlertType `json:"alerts"`
+	Sink   string      `json:"sink"`
+	To     []string    `json:"to,omitempty"`
+}
+
+type duration time.Duration
+
+const (
+	defaultSuppress     = time.Hour
+	defaultExpiryDays   = 14
+	defaultDeniedCount  = 3
+	defaultDeniedWindow = 5 * time.Minute
+	defaultTimeout      = 15 * time.Second
+)
+
+func newConfig() config {
+	c := config{
+		Suppress: duration(defaultSuppress),
+		Sinks:    []sinkConfig{},
+		Rules:    []Rule{},
+	}
+
+	c.CardExpiry.Days = defaultExpiryDays
+	c.DeniedSwipes.Count = defaultDeniedCount
+	c.DeniedSwipes.Window = duration(defaultDeniedWindow)
+
+	return c
+}
+
+func (c config) validate() error {
+	names := map[string]bool{}
+
+	for _, s := range c.Sinks {
+		if s.Name == "" {
+			return fmt.Errorf("notification sink with missing name")
+		} else if names[s.Name] {
+			return fmt.Errorf("duplicate notification sink '%v'", s.Name)
+		} else {
+			names[s.Name] = true
+		}
+	}
+
+	for _, r := range c.Rules {
+		if !names[r.Sink] {
+			return fmt.Errorf("notification rule for unknown sink '%v'", r.Sink)
+		}
+
+		for _, a := range r.Alerts {
+			if a != "*" && !slices.Contains(alertTypes, a) {
+				return fmt.Errorf("notification rule for unknown alert '%v'", a)
+			}
+		}
+	}
+
+	if c.CardExpiry.Days < 0 {
+		return fmt.Errorf("invalid card expiry notification period (%v days)", c.CardExpiry.Days)
+	}
+
+	if c.DeniedSwipes.Count < 1 {
+		return fmt.Errorf("invalid denied swipes count (%v)", c.DeniedSwipes.Count)
+	}
+
+	return nil
+}
+
+func (r Rule) matches(alert AlertType) bool {
+	return slices.Contains(r.Alerts, alert) || slices.Contains(r.Alerts, "*")
+}
+
+func (d *duration) UnmarshalJSON(bytes []byte) error {
+	var s string
+	if err := json.Unmarshal(bytes, &s); err != nil {
+		return err
+	}
+
+	if v, err := time.ParseDuration(s); err != nil {
+		return err
+	} else {
+		*d = duration(v)
+	}
+
+	return nil
+}
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
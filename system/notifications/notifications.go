@@ -0,0 +1,224 @@
+package notifications
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/uhppoted/uhppoted-httpd/log"
+)
+
+// Notifications routes alerts to the sinks configured in the notification rules. Repeated
+// alerts for the same condition are suppressed for the configured 'suppress' interval.
+type Notifications struct {
+	config config
+	sinks  map[string]Sink
+	sent   map[string]time.Time
+	online map[uint32]bool
+	denied map[uint32][]time.Time
+	sync.Mutex
+}
+
+func NewNotifications() *Notifications {
+	return &Notifications{
+		config: newConfig(),
+		sinks:  map[string]Sink{},
+		sent:   map[string]time.Time{},
+		online: map[uint32]bool{},
+		denied: map[uint32][]time.Time{},
+	}
+}
+
+// Loads the notification sinks and rules from a JSON configuration file.
+func (n *Notifications) Load(file string) error {
+	bytes, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	c := newConfig()
+	if err := json.Unmarshal(bytes, &c); err != nil {
+		return fmt.Errorf("%v: %v", file, err)
+	} else if err := c.validate(); err != nil {
+		return fmt.Errorf("%v: %v", file, err)
+	}
+
+	sinks := map[string]Sink{}
+	for _, v := range c.Sinks {
+		if sink, err := newSink(v); err != nil {
+			return fmt.Errorf("%v: %v", file, err)
+		} else {
+			sinks[v.Name] = sink
+		}
+	}
+
+	n.Lock()
+	defer n.Unlock()
+
+	n.config = c
+	n.sinks = sinks
+
+	return nil
+}
+
+// Alerts on the transition of a controller from online to offline. Controllers that have
+// not yet been seen online are ignored so that a restart doesn't raise spurious alerts.
+func (n *Notifications) Controller(deviceID uint32, name string, online bool) {
+	n.Lock()
+	defer n.Unlock()
+
+	key := fmt.Sprintf("%v:%v", ControllerOffline, deviceID)
+
+	if online {
+		n.online[deviceID] = true
+		delete(n.sent, key)
+		return
+	}
+
+	if n.online[deviceID] {
+		n.online[deviceID] = false
+		n.notify(Alert{
+			Type:    ControllerOffline,
+			Subject: fmt.Sprintf("Controller %v offline", name),
+			Message: fmt.Sprintf("Controller %v is not responding", name),
+			key:     key,
+		})
+	}
+}
+
+// Alerts if the ACL on one or more controllers does not match the configured card
+// permissions.
+func (n *Notifications) ACL(cards int) {
+	n.Lock()
+	defer n.Unlock()
+
+	key := string(ACLOutOfSync)
+
+	if cards == 0 {
+		delete(n.sent, key)
+		return
+	}
+
+	n.notify(Alert{
+		Type:    ACLOutOfSync,
+		Subject: "ACL out of sync",
+		Message: fmt.Sprintf("The access control list on the controllers does not match the configured permissions for %v cards", cards),
+		key:     key,
+	})
+}
+
+// Alerts (daily) if a card end date is within the configured number of days.
+func (n *Notifications) CardExpiry(card uint32, name string, expires time.Time) {
+	n.Lock()
+	defer n.Unlock()
+
+	if expires.IsZero() {
+		return
+	}
+
+	date := func(t time.Time) time.Time {
+		year, month, day := t.Date()
+		return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+	}
+
+	today := date(time.Now())
+	cutoff := today.AddDate(0, 0, n.config.CardExpiry.Days)
+
+	if d := date(expires); d.Before(today) || d.After(cutoff) {
+		return
+	}
+
+	n.notify(Alert{
+		Type:    CardExpiring,
+		Subject: fmt.Sprintf("Card %v expires on %v", name, expires.Format("2006-01-02")),
+		Message: fmt.Sprintf("Card %v expires on %v", name, expires.Format("2006-01-02")),
+		key:     fmt.Sprintf("%v:%v:%v", CardExpiring, card, expires.Format("2006-01-02")),
+	})
+}
+
+// Counts denied swipes for a card and alerts if the number of denied swipes within the
+// configured window reaches the configured count. Swipes older than the window (e.g. from
+// events retrieved after a restart) are ignored.
+func (n *Notifications) Denied(card uint32, name string, door string, timestamp time.Time) {
+	n.Lock()
+	defer n.Unlock()
+
+	window := time.Duration(n.config.DeniedSwipes.Window)
+	cutoff := time.Now().Add(-window)
+
+	if timestamp.Before(cutoff) {
+		return
+	}
+
+	list := []time.Time{}
+	for _, t := range n.denied[card] {
+		if !t.Before(cutoff) {
+			list = append(list, t)
+		}
+	}
+
+	list = append(list, timestamp)
+
+	if len(list) < n.config.DeniedSwipes.Count {
+		n.denied[card] = list
+		return
+	}
+
+	delete(n.denied, card)
+
+	n.notify(Alert{
+		Type:    DeniedSwipes,
+		Subject: fmt.Sprintf("Repeated denied swipes for card %v", name),
+		Message: fmt.Sprintf("Card %v was denied access %v times in %v (last at %v)", name, len(list), window, door),
+		key:     fmt.Sprintf("%v:%v", DeniedSwipes, card),
+	})
+}
+
+// Alerts when a user account is locked after too many failed logins.
+func (n *Notifications) UserLocked(uid string, name string) {
+	n.Lock()
+	defer n.Unlock()
+
+	n.notify(Alert{
+		Type:    UserLocked,
+		Subject: fmt.Sprintf("User %v locked", uid),
+		Message: fmt.Sprintf("User %v (%v) has been locked after too many failed logins", uid, name),
+		key:     fmt.Sprintf("%v:%v", UserLocked, uid),
+	})
+}
+
+// Sends the alert to the sinks for all matching rules unless an alert with the same key
+// was sent within the suppression interval. Sinks are invoked asynchronously so that a
+// slow or unreachable sink doesn't block the caller.
+func (n *Notifications) notify(alert Alert) {
+	now := time.Now()
+	suppress := time.Duration(n.config.Suppress)
+
+	if alert.Type == CardExpiring && suppress < 24*time.Hour {
+		suppress = 24 * time.Hour
+	}
+
+	if sent, ok := n.sent[alert.key]; ok && now.Sub(sent) < suppress {
+		return
+	}
+
+	n.sent[alert.key] = now
+
+	alert.Timestamp = now
+
+	for _, rule := range n.config.Rules {
+		if rule.matches(alert.Type) {
+			if sink, ok := n.sinks[rule.Sink]; ok {
+				go func(name string, to []string) {
+					if err := sink.Send(alert, to); err != nil {
+						log.Warnf("notifications: %v alert to '%v' failed (%v)", alert.Type, name, err)
+					} else {
+						log.Infof("notifications: sent %v alert to '%v'", alert.Type, name)
+					}
+				}(rule.Sink, rule.To)
+			}
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package notifications
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type sent struct {
+	alert Alert
+	to    []string
+}
+
+type stub struct {
+	ch chan sent
+}
+
+func (s *stub) Send(alert Alert, to []string) error {
+	s.ch <- sent{alert, to}
+
+	return nil
+}
+
+func newStub() *stub {
+	return &stub{
+		ch: make(chan sent, 16),
+	}
+}
+
+func (s *stub) expect(t *testing.T, alert AlertType) sent {
+	t.Helper()
+
+	select {
+	case v := <-s.ch:
+		if v.alert.Type != alert {
+			t.Fatalf("Incorrect alert - expected:%v, got:%v", alert, v.alert.Type)
+		}
+		return v
+
+	case <-time.After(time.Second):
+		t.Fatalf("Expected %v alert", alert)
+	}
+
+	return sent{}
+}
+
+func (s *stub) none(t *testing.T) {
+	t.Helper()
+
+	select {
+	case v := <-s.ch:
+		t.Fatalf("Unexpected alert %v", v.alert.Type)
+
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func setup(rules ...Rule) (*Notifications, *stub, *stub) {
+	email := newStub()
+	webhook := newStub()
+
+	n := NewNotifications()
+	n.sinks = map[string]Sink{
+		"email":   email,
+		"webhook": webhook,
+	}
+	n.config.Rules = rules
+
+	return n, email, webhook
+}
+
+func TestNotificationRules(t *testing.T) {
+	n, email, webhook := setup(
+		Rule{Alerts: []AlertType{UserLocked}, Sink: "email", To: []string{"security@example.com"}},
+		Rule{Alerts: []AlertType{"*"}, Sink: "webhook"})
+
+	n.UserLocked("admin", "Admin")
+
+	v := email.expect(t, UserLocked)
+	if len(v.to) != 1 || v.to[0] != "security@example.com" {
+		t.Errorf("Incorrect recipients - expected:%v, got:%v", []string{"security@example.com"}, v.to)
+	}
+
+	webhook.expect(t, UserLocked)
+
+	n.ACL(3)
+
+	email.none(t)
+	webhook.expect(t, ACLOutOfSync)
+}
+
+func TestNotificationSuppression(t *testing.T) {
+	n, email, _ := setup(Rule{Alerts: []AlertType{ACLOutOfSync}, Sink: "email"})
+
+	n.ACL(3)
+	n.ACL(5)
+
+	email.expect(t, ACLOutOfSync)
+	email.none(t)
+
+	// ... back in sync resets suppression
+	n.ACL(0)
+	n.ACL(1)
+
+	email.expect(t, ACLOutOfSync)
+}
+
+func TestControllerOffline(t *testing.T) {
+	n, email, _ := setup(Rule{Alerts: []AlertType{ControllerOffline}, Sink: "email"})
+
+	n.Controller(405419896, "Alpha", false)
+	email.none(t)
+
+	n.Controller(405419896, "Alpha", true)
+	n.Controller(405419896, "Alpha", false)
+	n.Controller(405419896, "Alpha", false)
+
+	email.expect(t, ControllerOffline)
+	email.none(t)
+}
+
+func TestDeniedSwipes(t *testing.T) {
+	n, email, _ := setup(Rule{Alerts: []AlertType{DeniedSwipes}, Sink: "email"})
+
+	now := time.Now()
+
+	n.Denied(10058400, "10058400 (Eine Kleine)", "Gryffindor", now.Add(-1*time.Hour))
+	n.Denied(10058400, "10058400 (Eine Kleine)", "Gryffindor", now.Add(-2*time.Minute))
+	n.Denied(10058400, "10058400 (Eine Kleine)", "Gryffindor", now.Add(-1*time.Minute))
+	email.none(t)
+
+	n.Denied(10058400, "10058400 (Eine Kleine)", "Gryffindor", now)
+	email.expect(t, DeniedSwipes)
+}
+
+func TestCardExpiry(t *testing.T) {
+	n, email, _ := setup(Rule{Alerts: []AlertType{CardExpiring}, Sink: "email"})
+
+	now := time.Now()
+
+	n.CardExpiry(10058400, "10058400 (Eine Kleine)", now.AddDate(0, 0, 30))
+	n.CardExpiry(10058400, "10058400 (Eine Kleine)", now.AddDate(0, 0, -1))
+	email.none(t)
+
+	n.CardExpiry(10058400, "10058400 (Eine Kleine)", now.AddDate(0, 0, 7))
+	n.CardExpiry(10058400, "10058400 (Eine Kleine)", now.AddDate(0, 0, 7))
+	email.expect(t, CardExpiring)
+	email.none(t)
+}
+
+func TestLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "notifications.json")
+	config := `{
+  "suppress": "30m",
+  "card-expiry": { "days": 7 },
+  "denied-swipes": { "count": 5, "window": "10m" },
+  "sinks": [
+    { "name": "email", "type": "smtp", "server": "localhost:25", "from": "uhppoted@example.com", "to": [ "security@example.com" ] },
+    { "name": "ops", "type": "webhook", "url": "http://localhost:8080/alerts" },
+    { "name": "pager", "type": "script", "command": "/usr/local/bin/page" }
+  ],
+  "rules": [
+    { "alerts": [ "controller-offline", "acl-out-of-sync" ], "sink": "ops" },
+    { "alerts": [ "*" ], "sink": "email" }
+  ]
+}`
+
+	if err := os.WriteFile(file, []byte(config), 0600); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	n := NewNotifications()
+	if err := n.Load(file); err != nil {
+		t.Fatalf("Error loading notifications configuration (%v)", err)
+	}
+
+	if len(n.sinks) != 3 {
+		t.Errorf("Incorrect number of sinks - expected:%v, got:%v", 3, len(n.sinks))
+	}
+
+	if len(n.config.Rules) != 2 {
+		t.Errorf("Incorrect number of rules - expected:%v, got:%v", 2, len(n.config.Rules))
+	}
+
+	if n.config.Suppress != duration(30*time.Minute) {
+		t.Errorf("Incorrect suppression interval - expected:%v, got:%v", 30*time.Minute, time.Duration(n.config.Suppress))
+	}
+
+	if n.config.CardExpiry.Days != 7 {
+		t.Errorf("Incorrect card expiry days - expected:%v, got:%v", 7, n.config.CardExpiry.Days)
+	}
+
+	if n.config.DeniedSwipes.Count != 5 || n.config.DeniedSwipes.Window != duration(10*time.Minute) {
+		t.Errorf("Incorrect denied swipes - expected:%v/%v, got:%v/%v", 5, 10*time.Minute, n.config.DeniedSwipes.Count, time.Duration(n.config.DeniedSwipes.Window))
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []string{
+		`{ "sinks": [ { "name": "email", "type": "carrier-pigeon" } ] }`,
+		`{ "sinks": [ { "name": "email", "type": "smtp", "from": "uhppoted@example.com" } ] }`,
+		`{ "rules": [ { "alerts": [ "*" ], "sink": "email" } ] }`,
+		`{ "sinks": [ { "name": "ops", "type": "webhook", "url": "http://localhost" } ], "rules": [ { "alerts": [ "weather" ], "sink": "ops" } ] }`,
+		`{ "suppress": "forever" }`,
+	}
+
+	for _, config := range tests {
+		file := filepath.Join(t.TempDir(), "notifications.json")
+		if err := os.WriteFile(file, []byte(config), 0600); err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		if err := NewNotifications().Load(file); err == nil {
+			t.Errorf("Expected error loading invalid configuration %v", config)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Sink delivers alerts to an external notification channel. 'to' is the list of recipients
+// from the matching rule (or the sink default recipients if the rule doesn't specify any).
+type Sink interface {
+	Send(alert Alert, to []string) error
+}
+
+type smtpSink struct {
+	server   string
+	from     string
+	username string
+	password string
+	to       []string
+	timeout  time.Duration
+}
+
+type webhookSink struct {
+	url     string
+	headers map[string]string
+	timeout time.Duration
+}
+
+type scriptSink struct {
+	command string
+	args    []string
+	to      []string
+	timeout time.Duration
+}
+
+func newSink(c sinkConfig) (Sink, error) {
+	timeout := time.Duration(c.Timeout)
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	switch strings.ToLower(c.Type) {
+	case "smtp":
+		if c.Server == "" {
+			return nil, fmt.Errorf("SMTP sink '%v': missing server", c.Name)
+		} else if c.From == "" {
+			return nil, fmt.Errorf("SMTP sink '%v': missing 'from' address", c.Name)
+		}
+
+		return &smtpSink{
+			server:   c.Server,
+			from:     c.From,
+			username: c.Username,
+			password: c.Password,
+			to:       c.To,
+			timeout:  timeout,
+		}, nil
+
+	case "webhook":
+		if c.URL == "" {
+			return nil, fmt.Errorf("webhook sink '%v': missing URL", c.Name)
+		}
+
+		return &webhookSink{
+			url:     c.URL,
+			headers: c.Headers,
+			timeout: timeout,
+		}, nil
+
+	case "script":
+		if c.Command == "" {
+			return nil, fmt.Errorf("script sink '%v': missing command", c.Name)
+		}
+
+		return &scriptSink{
+			command: c.Command,
+			args:    c.Args,
+			to:      c.To,
+			timeout: timeout,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("sink '%v': unknown sink type '%v'", c.Name, c.Type)
+	}
+}
+
+// Sends the alert as a plain text email. Authenticates with PLAIN auth if a username is
+// configured (net/smtp only permits this over TLS or to localhost).
+func (s *smtpSink) Send(alert Alert, to []string) error {
+	recipients := to
+	if len(recipients) == 0 {
+		recipients = s.to
+	}
+
+	if len(recipients) == 0 {
+		return fmt.Errorf("no recipients for alert '%v'", alert.Subject)
+	}
+
+	host, _, err := net.SplitHostPort(s.server)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.username != "" {
+		auth = smtp.PlainAuth("", s.username, s.password, host)
+	}
+
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "From: %v\r\n", s.from)
+	fmt.Fprintf(&b, "To: %v\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&b, "Subject: %v\r\n", subject(alert.Subject))
+	fmt.Fprintf(&b, "Date: %v\r\n", alert.Timestamp.Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Content-Type: text/plain; charset=UTF-8\r\n")
+	fmt.Fprintf(&b, "\r\n")
+	fmt.Fprintf(&b, "%v\r\n", strings.ReplaceAll(alert.Message, "\n", "\r\n"))
+
+	return s.send(host, auth, recipients, b.Bytes())
+}
+
+// Equivalent to smtp.SendMail but with the connection and the whole SMTP conversation limited
+// to the sink timeout so that a stalled mail server doesn't block the alert indefinitely.
+func (s *smtpSink) send(host string, auth smtp.Auth, recipients []string, msg []byte) error {
+	conn, err := net.DialTimeout("tcp", s.server, s.timeout)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(s.timeout)); err != nil {
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+
+	defer c.Close()
+
+	if err := c.Hello("localhost"); err != nil {
+		return err
+	}
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+
+	if auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return fmt.Errorf("SMTP server %v doesn't support AUTH", s.server)
+		} else if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(s.from); err != nil {
+		return err
+	}
+
+	for _, addr := range recipients {
+		if err := c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// Returns the alert subject as a single line mail header value. Alert subjects include card,
+// door and controller names which can contain (e.g. CSV imported) newlines that would otherwise
+// inject additional mail headers.
+func subject(s string) string {
+	s = strings.Join(strings.FieldsFunc(s, func(r rune) bool {
+		return r == '\r' || r == '\n'
+	}), " ")
+
+	return mime.QEncoding.Encode("UTF-8", s)
+}
+
+// POSTs the alert as a JSON object to the webhook URL.
+func (s *webhookSink) Send(alert Alert, to []string) error {
+	payload := struct {
+		Alert
+		To []string `json:"to,omitempty"`
+	}{
+		Alert: alert,
+		To:    to,
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	rq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	rq.Header.Set("Content-Type", "application/json")
+	for k, v := range s.headers {
+		rq.Header.Set(k, v)
+	}
+
+	response, err := http.DefaultClient.Do(rq)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("webhook %v returned %v", s.url, response.Status)
+	}
+
+	return nil
+}
+
+// Runs the script with the alert as a JSON object on stdin. The alert type, subject and
+// recipients are also passed in the UHPPOTED_ALERT, UHPPOTED_ALERT_SUBJECT and UHPPOTED_ALERT_TO
+// environment variables.
+func (s *scriptSink) Send(alert Alert, to []string) error {
+	recipients := to
+	if len(recipients) == 0 {
+		recipients = s.to
+	}
+
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, s.command, s.args...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("UHPPOTED_ALERT=%v", alert.Type),
+		fmt.Sprintf("UHPPOTED_ALERT_SUBJECT=%v", alert.Subject),
+		fmt.Sprintf("UHPPOTED_ALERT_TO=%v", strings.Join(recipients, ",")))
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v (%v)", err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
@@ -0,0 +1,289 @@
+package notifications
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Minimal SMTP server that accepts a single message and returns the envelope recipients
+// and message data.
+func smtpd(t *testing.T) (string, <-chan []string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	ch := make(chan []string, 1)
+
+	go func() {
+		defer listener.Close()
+
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(s string) {
+			conn.Write([]byte(s + "\r\n"))
+		}
+
+		received := []string{}
+		data := false
+
+		reply("220 localhost ESMTP")
+
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			line = strings.TrimRight(line, "\r\n")
+
+			switch {
+			case data && line == ".":
+				data = false
+				reply("250 OK")
+
+			case data:
+				received = append(received, line)
+
+			case strings.HasPrefix(line, "EHLO"), strings.HasPrefix(line, "HELO"):
+				reply("250 localhost")
+
+			case strings.HasPrefix(line, "MAIL FROM:"):
+				reply("250 OK")
+
+			case strings.HasPrefix(line, "RCPT TO:"):
+				received = append(received, line)
+				reply("250 OK")
+
+			case line == "DATA":
+				data = true
+				reply("354 Start mail input")
+
+			case line == "QUIT":
+				reply("221 Bye")
+				ch <- received
+				return
+
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+
+	return listener.Addr().String(), ch
+}
+
+func TestSMTPSink(t *testing.T) {
+	server, ch := smtpd(t)
+
+	sink, err := newSink(sinkConfig{
+		Name:   "email",
+		Type:   "smtp",
+		Server: server,
+		From:   "uhppoted@example.com",
+		To:     []string{"security@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	alert := Alert{
+		Type:      UserLocked,
+		Timestamp: time.Now(),
+		Subject:   "User admin locked",
+		Message:   "User admin (Admin) has been locked after too many failed logins",
+	}
+
+	if err := sink.Send(alert, []string{"admin@example.com"}); err != nil {
+		t.Fatalf("Error sending alert (%v)", err)
+	}
+
+	select {
+	case received := <-ch:
+		message := strings.Join(received, "\n")
+
+		if !strings.Contains(message, "RCPT TO:<admin@example.com>") {
+			t.Errorf("Missing/incorrect recipient\n%v", message)
+		}
+
+		if strings.Contains(message, "security@example.com") {
+			t.Errorf("Sink default recipients not overridden by rule recipients\n%v", message)
+		}
+
+		if !strings.Contains(message, "Subject: User admin locked") {
+			t.Errorf("Missing/incorrect subject\n%v", message)
+		}
+
+	case <-time.After(time.Second):
+		t.Fatalf("Timeout waiting for SMTP message")
+	}
+}
+
+func TestSMTPSinkWithMultilineSubject(t *testing.T) {
+	server, ch := smtpd(t)
+
+	sink, err := newSink(sinkConfig{
+		Name:   "email",
+		Type:   "smtp",
+		Server: server,
+		From:   "uhppoted@example.com",
+		To:     []string{"security@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	alert := Alert{
+		Type:      CardExpiring,
+		Timestamp: time.Now(),
+		Subject:   "Card 10058400 (Hermione\r\nBcc: mallory@example.com) expires soon",
+		Message:   "Card 10058400 expires on 2023-12-31",
+	}
+
+	if err := sink.Send(alert, nil); err != nil {
+		t.Fatalf("Error sending alert (%v)", err)
+	}
+
+	select {
+	case received := <-ch:
+		for _, line := range received {
+			if strings.HasPrefix(line, "Bcc:") {
+				t.Errorf("Subject injected mail header '%v'", line)
+			}
+		}
+
+		if !strings.Contains(strings.Join(received, "\n"), "Subject: Card 10058400 (Hermione Bcc: mallory@example.com) expires soon") {
+			t.Errorf("Missing/incorrect subject\n%v", strings.Join(received, "\n"))
+		}
+
+	case <-time.After(time.Second):
+		t.Fatalf("Timeout waiting for SMTP message")
+	}
+}
+
+func TestSMTPSinkTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	defer listener.Close()
+
+	// ... accept the connection but never reply
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	sink, err := newSink(sinkConfig{
+		Name:    "email",
+		Type:    "smtp",
+		Server:  listener.Addr().String(),
+		From:    "uhppoted@example.com",
+		To:      []string{"security@example.com"},
+		Timeout: duration(250 * time.Millisecond),
+	})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	alert := Alert{
+		Type:      UserLocked,
+		Timestamp: time.Now(),
+		Subject:   "User admin locked",
+	}
+
+	start := time.Now()
+	if err := sink.Send(alert, nil); err == nil {
+		t.Errorf("Expected timeout error sending alert to stalled SMTP server")
+	} else if dt := time.Since(start); dt > 2*time.Second {
+		t.Errorf("SMTP sink did not time out - took %v", dt)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	ch := make(chan map[string]any, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]any{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		body["authorization"] = r.Header.Get("Authorization")
+		ch <- body
+	}))
+
+	defer server.Close()
+
+	sink, err := newSink(sinkConfig{
+		Name:    "ops",
+		Type:    "webhook",
+		URL:     server.URL,
+		Headers: map[string]string{"Authorization": "Bearer qwerty"},
+	})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if err := sink.Send(Alert{Type: ACLOutOfSync, Subject: "ACL out of sync"}, nil); err != nil {
+		t.Fatalf("Error sending alert (%v)", err)
+	}
+
+	body := <-ch
+
+	if body["type"] != string(ACLOutOfSync) {
+		t.Errorf("Incorrect alert type - expected:%v, got:%v", ACLOutOfSync, body["type"])
+	}
+
+	if body["authorization"] != "Bearer qwerty" {
+		t.Errorf("Incorrect authorization header - expected:%v, got:%v", "Bearer qwerty", body["authorization"])
+	}
+}
+
+func TestScriptSink(t *testing.T) {
+	shell, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skipf("no shell (%v)", err)
+	}
+
+	file := filepath.Join(t.TempDir(), "alert.txt")
+
+	sink, err := newSink(sinkConfig{
+		Name:    "script",
+		Type:    "script",
+		Command: shell,
+		Args:    []string{"-c", `echo "$UHPPOTED_ALERT $UHPPOTED_ALERT_TO" > "$0"`, file},
+	})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if err := sink.Send(Alert{Type: DeniedSwipes}, []string{"security"}); err != nil {
+		t.Fatalf("Error running script (%v)", err)
+	}
+
+	if bytes, err := os.ReadFile(file); err != nil {
+		t.Fatalf("%v", err)
+	} else if s := strings.TrimSpace(string(bytes)); s != "denied-swipes security" {
+		t.Errorf("Incorrect script output - expected:%v, got:%v", "denied-swipes security", s)
+	}
+}
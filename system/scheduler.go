@@ -0,0 +1,253 @@
+package system
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+	"github.com/uhppoted/uhppoted-httpd/system/db"
+	"github.com/uhppoted/uhppoted-httpd/system/tasks"
+	"github.com/uhppoted/uhppoted-httpd/types"
+)
+
+// UID recorded in the audit trail for scheduled task runs.
+const scheduler = "scheduler"
+
+func Tasks(uid, role string) []schema.Object {
+	sys.RLock()
+	defer sys.RUnlock()
+
+	auth := auth.NewAuthorizator(uid, role)
+	objects := sys.tasks.AsObjects(auth)
+
+	return objects
+}
+
+func UpdateTasks(uid, role string, m map[string]any) (any, error) {
+	sys.Lock()
+	defer sys.Unlock()
+
+	created, updated, deleted, err := unpack(m)
+	if err != nil {
+		return nil, err
+	}
+
+	auth := auth.NewAuthorizator(uid, role)
+	dbc := db.NewDBC(sys.trail)
+	shadow := sys.tasks.Clone()
+
+	for _, o := range created {
+		if objects, err := shadow.Create(auth, o.OID, o.Value, dbc); err != nil {
+			return nil, err
+		} else {
+			dbc.Stash(objects)
+		}
+	}
+
+	for _, o := range updated {
+		if objects, err := shadow.Update(auth, o.OID, o.Value, dbc); err != nil {
+			return nil, err
+		} else {
+			dbc.Stash(objects)
+		}
+	}
+
+	for _, oid := range deleted {
+		if objects, err := shadow.Delete(auth, oid, dbc); err != nil {
+			return nil, err
+		} else {
+			dbc.Stash(objects)
+		}
+	}
+
+	if err := shadow.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := save(TagTasks, &shadow); err != nil {
+		return nil, err
+	}
+
+	dbc.Commit(&sys, func() {
+		sys.tasks = shadow
+	})
+
+	return dbc.Objects(), nil
+}
+
+// Returns the most recent runs (latest first) for a scheduled task, or for all tasks if 'task'
+// is blank.
+func TaskRuns(uid, role string, task schema.OID, count int) []tasks.Run {
+	auth := auth.NewAuthorizator(uid, role)
+
+	return sys.runs.Query(auth, task, count)
+}
+
+// Checks for due tasks at the start of every minute and queues them to run on the background
+// task queue.
+func (s *system) scheduler() {
+	for {
+		now := time.Now()
+		next := now.Truncate(time.Minute).Add(time.Minute)
+
+		time.Sleep(next.Sub(now))
+
+		s.RLock()
+		due := s.tasks.Due(next)
+		s.RUnlock()
+
+		for _, t := range due {
+			task := t
+			s.taskQ.Add(Task{
+				f: func() {
+					s.run(task)
+				},
+			})
+		}
+	}
+}
+
+func (s *system) run(task tasks.Task) {
+	infof("scheduler", "running task '%v' (%v)", task.Name, task.Action)
+
+	started := types.TimestampNow()
+	err := s.execute(task)
+	finished := types.TimestampNow()
+
+	if err != nil {
+		warnf("scheduler", "task '%v' failed (%v)", task.Name, err)
+	}
+
+	run := tasks.NewRun(task, started, finished, err)
+
+	s.Lock()
+	defer s.Unlock()
+
+	dbc := db.NewDBC(s.trail)
+
+	s.tasks.Ran(run)
+	s.runs.Add(run)
+
+	if err := save(TagTasks, &s.tasks); err != nil {
+		warnf("scheduler", "%v", err)
+	}
+
+	if err := save(TagTaskRuns, &s.runs); err != nil {
+		warnf("scheduler", "%v", err)
+	}
+
+	if err != nil {
+		dbc.Log(scheduler, "run", task.OID, "task", "", task.Name, "result", "", run.Result, "Ran task '%v' (%v): %v", task.Name, task.Action, err)
+	} else {
+		dbc.Log(scheduler, "run", task.OID, "task", "", task.Name, "result", "", run.Result, "Ran task '%v' (%v)", task.Name, task.Action)
+	}
+
+	dbc.Commit(s, func() {})
+}
+
+func (s *system) execute(task tasks.Task) error {
+	auth, err := s.owner(task)
+	if err != nil {
+		return err
+	}
+
+	switch task.Action {
+	case tasks.UnlockDoor:
+		return s.setDoorMode(auth, task.Door, "normally open")
+
+	case tasks.LockDoor:
+		return s.setDoorMode(auth, task.Door, "controlled")
+
+	case tasks.SynchronizeDateTime:
+		return SynchronizeDateTime()
+
+	case tasks.SynchronizeACL:
+		return SynchronizeACL()
+
+	case tasks.SynchronizeDoors:
+		return SynchronizeDoors()
+
+	case tasks.PurgeEvents:
+		return s.purgeEvents(task.Days)
+
+	case tasks.Backup:
+		return s.backup()
+
+	default:
+		return fmt.Errorf("invalid task action (%v)", task.Action)
+	}
+}
+
+// Returns an authorizator for the user that created (or last modified) a task so that a task
+// can only do what the user is authorised to do. Fails if the user has been deleted or locked.
+// Tasks are unrestricted when running without authentication.
+func (s *system) owner(task tasks.Task) (*auth.Authorizator, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	if s.noauth {
+		return auth.NewSystemAuthorizator(scheduler), nil
+	}
+
+	uid := task.Owner()
+	if u, ok := s.users.User(uid); !ok || u.IsDeleted() || u.Locked() {
+		return nil, fmt.Errorf("task owner '%v' is not a valid user", uid)
+	} else {
+		return auth.NewAuthorizator(uid, u.Role()), nil
+	}
+}
+
+// Sets the door control mode using the same path as an update from the UI so that the change is
+// validated, authorised, persisted, audited and pushed to the controller.
+func (s *system) setDoorMode(auth *auth.Authorizator, door schema.OID, mode string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	dbc := db.NewDBC(s.trail)
+	shadow := s.doors.Clone()
+
+	if _, ok := shadow.Door(door); !ok {
+		return fmt.Errorf("invalid door (%v)", door)
+	}
+
+	if objects, err := shadow.Update(auth, door.Append(schema.DoorControl), mode, dbc); err != nil {
+		return err
+	} else {
+		dbc.Stash(objects)
+	}
+
+	if err := shadow.Validate(); err != nil {
+		return err
+	}
+
+	if err := save(TagDoors, &shadow); err != nil {
+		return err
+	}
+
+	dbc.Commit(s, func() {
+		s.doors = shadow
+	})
+
+	return nil
+}
+
+func (s *system) purgeEvents(days uint16) error {
+	if days == 0 {
+		return fmt.Errorf("invalid event retention period (%v days)", days)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	cutoff := time.Now().AddDate(0, 0, -int(days))
+	purged := s.events.Purge(cutoff)
+
+	infof("scheduler", "purged %v events before %v", purged, cutoff.Format("2006-01-02"))
+
+	if purged > 0 {
+		return save(TagEvents, &s.events)
+	}
+
+	return nil
+}
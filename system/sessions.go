@@ -0,0 +1,145 @@
+package system
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/system/db"
+	"github.com/uhppoted/uhppoted-httpd/system/sessions"
+)
+
+// Creates a new persistent login session for a user, returning the session token. The oldest
+// sessions for the user are ended if the user already has the maximum number of concurrent
+// sessions (0 for unlimited).
+func CreateSession(uid, role string, lifetime time.Duration, max uint32) (string, error) {
+	token, _, evicted, err := sys.sessions.Add(uid, role, lifetime, max)
+	if err != nil {
+		return "", err
+	}
+
+	sys.Lock()
+	defer sys.Unlock()
+
+	if len(evicted) > 0 {
+		dbc := db.NewDBC(sys.trail)
+
+		for _, s := range evicted {
+			dbc.Log(uid, "delete", "", "session", s.ID, s.UID, "", "", "", "Ended session %v (exceeded %v concurrent sessions)", s.ID, max)
+		}
+
+		dbc.Commit(&sys, func() {})
+	}
+
+	if err := save(TagSessions, &sys.sessions); err != nil {
+		warnf("sessions", "%v", err)
+	}
+
+	return token, nil
+}
+
+// Returns the session for a session token if the session is valid, has not expired and has not
+// been idle for longer than the idle time (0 to not check idle time).
+func GetSession(token string, idle time.Duration) (sessions.Session, error) {
+	return sys.sessions.Get(token, idle)
+}
+
+// Validates a session token and updates the session last seen time, client address and user
+// agent, returning the session user ID and role.
+func AuthenticateSession(token, address, userAgent string, idle time.Duration) (string, string, error) {
+	session, err := sys.sessions.Get(token, idle)
+	if err != nil {
+		return "", "", err
+	}
+
+	sys.sessions.Touch(session.ID, address, userAgent)
+
+	return session.UID, session.Role, nil
+}
+
+// Ends the session for a session token e.g. on logout.
+func EndSession(token string) {
+	if _, ok := sys.sessions.Delete(token); ok {
+		if err := save(TagSessions, &sys.sessions); err != nil {
+			warnf("sessions", "%v", err)
+		}
+	}
+}
+
+// Returns the login sessions that the user is authorised to view.
+func Sessions(uid, role string) []sessions.Session {
+	sys.RLock()
+	defer sys.RUnlock()
+
+	auth := auth.NewAuthorizator(uid, role)
+	list := []sessions.Session{}
+
+	for _, s := range sys.sessions.List() {
+		if sys.users.CanViewSession(auth, s.UID, s.ID) == nil {
+			list = append(list, s)
+		}
+	}
+
+	return list
+}
+
+// Revokes the session with the session ID or, if the session ID is blank, all the sessions
+// for the user 'user'. Returns the number of revoked sessions.
+func RevokeSessions(uid, role, id, user string) (int, error) {
+	id = strings.TrimSpace(id)
+	user = strings.TrimSpace(user)
+
+	if id == "" && user == "" {
+		return 0, fmt.Errorf("missing session ID or user ID")
+	}
+
+	sys.Lock()
+	defer sys.Unlock()
+
+	auth := auth.NewAuthorizator(uid, role)
+
+	for _, s := range sys.sessions.List() {
+		if (id != "" && s.ID == id) || (id == "" && s.UID == user) {
+			if err := sys.users.CanRevokeSession(auth, s.UID, s.ID); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	revoked := sys.sessions.Revoke(id, user)
+	if len(revoked) == 0 {
+		if id != "" {
+			return 0, fmt.Errorf("no session with ID %v", id)
+		}
+
+		return 0, nil
+	}
+
+	if err := save(TagSessions, &sys.sessions); err != nil {
+		return 0, err
+	}
+
+	dbc := db.NewDBC(sys.trail)
+
+	for _, s := range revoked {
+		dbc.Log(uid, "delete", "", "session", s.ID, s.UID, "", "", "", "Revoked session %v for %v", s.ID, s.UID)
+	}
+
+	dbc.Commit(&sys, func() {})
+
+	return len(revoked), nil
+}
+
+// Removes expired and idle sessions and persists any session activity since the last sweep.
+func SweepSessions(idle time.Duration) {
+	for _, s := range sys.sessions.Sweep(idle) {
+		infof("sessions", "session %v for %v expired", s.ID, s.UID)
+	}
+
+	if sys.sessions.Dirty() {
+		if err := save(TagSessions, &sys.sessions); err != nil {
+			warnf("sessions", "%v", err)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package sessions
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uhppoted/uhppoted-httpd/types"
+)
+
+// Session is a persistent login session. Only the SHA-256 hash of the session secret is stored -
+// the session token itself is only ever held in the session cookie.
+type Session struct {
+	ID        string          `json:"id"`
+	UID       string          `json:"uid"`
+	Role      string          `json:"role"`
+	Address   string          `json:"address"`
+	UserAgent string          `json:"user-agent"`
+	Created   types.Timestamp `json:"created"`
+	LastSeen  types.Timestamp `json:"last-seen"`
+	Expires   types.Timestamp `json:"expires"`
+	hash      string
+}
+
+// Sessions is the list of active login sessions, keyed by session ID.
+type Sessions struct {
+	sessions map[string]Session
+	dirty    bool
+	sync.RWMutex
+}
+
+type record struct {
+	ID        string          `json:"id"`
+	UID       string          `json:"uid"`
+	Role      string          `json:"role"`
+	Address   string          `json:"address,omitempty"`
+	UserAgent string          `json:"user-agent,omitempty"`
+	Created   types.Timestamp `json:"created"`
+	LastSeen  types.Timestamp `json:"last-seen"`
+	Expires   types.Timestamp `json:"expires"`
+	Hash      string          `json:"hash"`
+}
+
+func NewSessions() Sessions {
+	return Sessions{
+		sessions: map[string]Session{},
+	}
+}
+
+// Returns true if the session has passed its absolute expiry or has not been used for longer than
+// the idle time. An idle time of 0 disables the idle check.
+func (s Session) Expired(now time.Time, idle time.Duration) bool {
+	if !s.Expires.IsZero() && !now.Before(time.Time(s.Expires)) {
+		return true
+	}
+
+	if idle > 0 && now.Sub(time.Time(s.LastSeen)) > idle {
+		return true
+	}
+
+	return false
+}
+
+// Creates a new session for a user, returning the session token and any sessions that were ended
+// to keep the user within the limit on concurrent sessions. A lifetime of 0 creates a session that
+// never expires and a limit of 0 allows any number of concurrent sessions.
+func (ss *Sessions) Add(uid, role string, lifetime time.Duration, max uint32) (string, Session, []Session, error) {
+	id := make([]byte, 8)
+	secret := make([]byte, 32)
+
+	if _, err := io.ReadFull(rand.Reader, id); err != nil {
+		return "", Session{}, nil, err
+	}
+
+	if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+		return "", Session{}, nil, err
+	}
+
+	now := time.Now()
+	token := base64.RawURLEncoding.EncodeToString(secret)
+	session := Session{
+		ID:       hex.EncodeToString(id),
+		UID:      uid,
+		Role:     role,
+		Created:  types.Timestamp(now),
+		LastSeen: types.Timestamp(now),
+		hash:     hash(token),
+	}
+
+	if lifetime > 0 {
+		session.Expires = types.Timestamp(now.Add(lifetime))
+	}
+
+	ss.Lock()
+	defer ss.Unlock()
+
+	evicted := []Session{}
+
+	if max > 0 {
+		list := []Session{}
+		for _, s := range ss.sessions {
+			if s.UID == uid {
+				list = append(list, s)
+			}
+		}
+
+		slices.SortFunc(list, func(p, q Session) int {
+			return time.Time(p.Created).Compare(time.Time(q.Created))
+		})
+
+		for len(list) >= int(max) {
+			delete(ss.sessions, list[0].ID)
+			evicted = append(evicted, list[0])
+			list = list[1:]
+		}
+	}
+
+	ss.sessions[session.ID] = session
+	ss.dirty = true
+
+	return fmt.Sprintf("%v.%v", session.ID, token), session, evicted, nil
+}
+
+// Returns the session for a session token, provided the token is valid and the session has
+// not expired.
+func (ss *Sessions) Get(token string, idle time.Duration) (Session, error) {
+	id, secret, err := parse(token)
+	if err != nil {
+		return Session{}, err
+	}
+
+	ss.RLock()
+	defer ss.RUnlock()
+
+	if s, ok := ss.sessions[id]; !ok || !s.verify(secret) {
+		return Session{}, fmt.Errorf("no extant session for ID '%v'", id)
+	} else if s.Expired(time.Now(), idle) {
+		return Session{}, fmt.Errorf("session '%v' expired", id)
+	} else {
+		return s, nil
+	}
+}
+
+// Updates the last seen time and the client address and user agent of a session. The updates
+// are persisted on the next Save.
+func (ss *Sessions) Touch(id, address, userAgent string) {
+	ss.Lock()
+	defer ss.Unlock()
+
+	if s, ok := ss.sessions[id]; ok {
+		s.LastSeen = types.TimestampNow()
+
+		if address != "" {
+			s.Address = address
+		}
+
+		if userAgent != "" {
+			s.UserAgent = userAgent
+		}
+
+		ss.sessions[id] = s
+		ss.dirty = true
+	}
+}
+
+// Ends the session for a session token (e.g. on logout).
+func (ss *Sessions) Delete(token string) (Session, bool) {
+	id, secret, err := parse(token)
+	if err != nil {
+		return Session{}, false
+	}
+
+	ss.Lock()
+	defer ss.Unlock()
+
+	if s, ok := ss.sessions[id]; ok && s.verify(secret) {
+		delete(ss.sessions, id)
+		ss.dirty = true
+
+		return s, true
+	}
+
+	return Session{}, false
+}
+
+// Returns the sessions, ordered by user ID and creation time.
+func (ss *Sessions) List() []Session {
+	ss.RLock()
+	defer ss.RUnlock()
+
+	list := []Session{}
+	for _, s := range ss.sessions {
+		list = append(list, s)
+	}
+
+	slices.SortFunc(list, func(p, q Session) int {
+		if c := strings.Compare(p.UID, q.UID); c != 0 {
+			return c
+		}
+
+		return time.Time(p.Created).Compare(time.Time(q.Created))
+	})
+
+	return list
+}
+
+// Ends the session with the session ID or, if the ID is blank, all the sessions for the user.
+// Returns the ended sessions.
+func (ss *Sessions) Revoke(id, uid string) []Session {
+	ss.Lock()
+	defer ss.Unlock()
+
+	revoked := []Session{}
+
+	for k, s := range ss.sessions {
+		if (id != "" && s.ID == id) || (id == "" && uid != "" && s.UID == uid) {
+			delete(ss.sessions, k)
+			revoked = append(revoked, s)
+		}
+	}
+
+	if len(revoked) > 0 {
+		ss.dirty = true
+	}
+
+	return revoked
+}
+
+// Removes expired and idle sessions, returning the removed sessions.
+func (ss *Sessions) Sweep(idle time.Duration) []Session {
+	ss.Lock()
+	defer ss.Unlock()
+
+	now := time.Now()
+	list := []Session{}
+
+	for k, s := range ss.sessions {
+		if s.Expired(now, idle) {
+			delete(ss.sessions, k)
+			list = append(list, s)
+		}
+	}
+
+	if len(list) > 0 {
+		ss.dirty = true
+	}
+
+	return list
+}
+
+// Returns true if the sessions have changed since the last call to Dirty.
+func (ss *Sessions) Dirty() bool {
+	ss.Lock()
+	defer ss.Unlock()
+
+	dirty := ss.dirty
+	ss.dirty = false
+
+	return dirty
+}
+
+func (ss *Sessions) Load(blob json.RawMessage) error {
+	ss.Lock()
+	defer ss.Unlock()
+
+	records := []record{}
+	if blob != nil {
+		if err := json.Unmarshal(blob, &records); err != nil {
+			return err
+		}
+	}
+
+	ss.sessions = map[string]Session{}
+	for _, r := range records {
+		ss.sessions[r.ID] = Session{
+			ID:        r.ID,
+			UID:       r.UID,
+			Role:      r.Role,
+			Address:   r.Address,
+			UserAgent: r.UserAgent,
+			Created:   r.Created,
+			LastSeen:  r.LastSeen,
+			Expires:   r.Expires,
+			hash:      r.Hash,
+		}
+	}
+
+	ss.dirty = false
+
+	return nil
+}
+
+func (ss *Sessions) Save() (json.RawMessage, error) {
+	records := []record{}
+	for _, s := range ss.List() {
+		records = append(records, record{
+			ID:        s.ID,
+			UID:       s.UID,
+			Role:      s.Role,
+			Address:   s.Address,
+			UserAgent: s.UserAgent,
+			Created:   s.Created,
+			LastSeen:  s.LastSeen,
+			Expires:   s.Expires,
+			Hash:      s.hash,
+		})
+	}
+
+	return json.MarshalIndent(records, "", "  ")
+}
+
+func (ss *Sessions) Print() {
+	if b, err := json.MarshalIndent(ss.List(), "", "  "); err == nil {
+		fmt.Printf("----------------- SESSIONS\n%s\n", string(b))
+	}
+}
+
+func (s Session) verify(secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(s.hash), []byte(hash(secret))) == 1
+}
+
+// Splits a session token into the session ID and secret.
+func parse(token string) (string, string, error) {
+	id, secret, ok := strings.Cut(token, ".")
+	if !ok || id == "" || secret == "" {
+		return "", "", fmt.Errorf("invalid session token")
+	}
+
+	return id, secret, nil
+}
+
+func hash(secret string) string {
+	h := sha256.Sum256([]byte(secret))
+
+	return hex.EncodeToString(h[:])
+}
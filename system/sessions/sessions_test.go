@@ -0,0 +1,175 @@
+package sessions
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uhppoted/uhppoted-httpd/types"
+)
+
+func TestAddAndGet(t *testing.T) {
+	ss := NewSessions()
+
+	token, session, evicted, err := ss.Add("moony", "admin", 1*time.Hour, 0)
+	if err != nil {
+		t.Fatalf("Error creating session (%v)", err)
+	}
+
+	if len(evicted) != 0 {
+		t.Errorf("Unexpected evicted sessions %v", evicted)
+	}
+
+	if !strings.HasPrefix(token, session.ID+".") {
+		t.Errorf("Invalid session token %v", token)
+	}
+
+	if s, err := ss.Get(token, 10*time.Minute); err != nil {
+		t.Errorf("Unexpected error (%v)", err)
+	} else if s.UID != "moony" || s.Role != "admin" {
+		t.Errorf("Incorrect session - expected:%v/%v, got:%v/%v", "moony", "admin", s.UID, s.Role)
+	}
+
+	if _, err := ss.Get(token+"x", 10*time.Minute); err == nil {
+		t.Errorf("Expected error for invalid session token")
+	}
+
+	if _, err := ss.Get(session.ID, 10*time.Minute); err == nil {
+		t.Errorf("Expected error for session ID without secret")
+	}
+}
+
+func TestExpired(t *testing.T) {
+	ss := NewSessions()
+
+	token, session, _, _ := ss.Add("moony", "admin", 1*time.Hour, 0)
+
+	s := ss.sessions[session.ID]
+	s.LastSeen = types.Timestamp(time.Now().Add(-15 * time.Minute))
+	ss.sessions[session.ID] = s
+
+	if _, err := ss.Get(token, 10*time.Minute); err == nil {
+		t.Errorf("Expected error for idle session")
+	}
+
+	if _, err := ss.Get(token, 0); err != nil {
+		t.Errorf("Unexpected error with idle check disabled (%v)", err)
+	}
+
+	s.Expires = types.Timestamp(time.Now().Add(-1 * time.Second))
+	ss.sessions[session.ID] = s
+
+	if _, err := ss.Get(token, 0); err == nil {
+		t.Errorf("Expected error for expired session")
+	}
+
+	if swept := ss.Sweep(10 * time.Minute); len(swept) != 1 || swept[0].ID != session.ID {
+		t.Errorf("Incorrect swept sessions - expected:%v, got:%v", session.ID, swept)
+	}
+}
+
+func TestMaxSessions(t *testing.T) {
+	ss := NewSessions()
+
+	tokens := []string{}
+	for i := 0; i < 3; i++ {
+		token, _, _, _ := ss.Add("moony", "admin", 1*time.Hour, 2)
+		tokens = append(tokens, token)
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	ss.Add("padfoot", "user", 1*time.Hour, 2)
+
+	if _, err := ss.Get(tokens[0], 0); err == nil {
+		t.Errorf("Expected oldest session to have been ended")
+	}
+
+	for _, token := range tokens[1:] {
+		if _, err := ss.Get(token, 0); err != nil {
+			t.Errorf("Unexpected error (%v)", err)
+		}
+	}
+
+	if N := len(ss.List()); N != 3 {
+		t.Errorf("Incorrect number of sessions - expected:%v, got:%v", 3, N)
+	}
+}
+
+func TestTouch(t *testing.T) {
+	ss := NewSessions()
+
+	token, session, _, _ := ss.Add("moony", "admin", 1*time.Hour, 0)
+	ss.Dirty()
+
+	ss.Touch(session.ID, "192.168.1.100", "Mozilla/5.0")
+
+	if !ss.Dirty() {
+		t.Errorf("Expected touched sessions to be dirty")
+	}
+
+	if s, _ := ss.Get(token, 0); s.Address != "192.168.1.100" || s.UserAgent != "Mozilla/5.0" {
+		t.Errorf("Incorrect session client - expected:%v/%v, got:%v/%v", "192.168.1.100", "Mozilla/5.0", s.Address, s.UserAgent)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	ss := NewSessions()
+
+	token1, session1, _, _ := ss.Add("moony", "admin", 1*time.Hour, 0)
+	token2, _, _, _ := ss.Add("moony", "admin", 1*time.Hour, 0)
+	token3, _, _, _ := ss.Add("padfoot", "user", 1*time.Hour, 0)
+
+	if revoked := ss.Revoke(session1.ID, ""); len(revoked) != 1 {
+		t.Errorf("Incorrect number of revoked sessions - expected:%v, got:%v", 1, len(revoked))
+	}
+
+	if _, err := ss.Get(token1, 0); err == nil {
+		t.Errorf("Expected error for revoked session")
+	}
+
+	if revoked := ss.Revoke("", "moony"); len(revoked) != 1 {
+		t.Errorf("Incorrect number of revoked sessions - expected:%v, got:%v", 1, len(revoked))
+	}
+
+	if _, err := ss.Get(token2, 0); err == nil {
+		t.Errorf("Expected error for revoked session")
+	}
+
+	if _, err := ss.Get(token3, 0); err != nil {
+		t.Errorf("Unexpected error (%v)", err)
+	}
+
+	if _, ok := ss.Delete(token3); !ok {
+		t.Errorf("Expected session to be deleted")
+	}
+
+	if N := len(ss.List()); N != 0 {
+		t.Errorf("Incorrect number of sessions - expected:%v, got:%v", 0, N)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	ss := NewSessions()
+
+	token, _, _, _ := ss.Add("moony", "admin", 1*time.Hour, 0)
+
+	blob, err := ss.Save()
+	if err != nil {
+		t.Fatalf("Error saving sessions (%v)", err)
+	}
+
+	if strings.Contains(string(blob), strings.SplitN(token, ".", 2)[1]) {
+		t.Errorf("Session secret should not be stored\n%s", blob)
+	}
+
+	restored := NewSessions()
+	if err := restored.Load(blob); err != nil {
+		t.Fatalf("Error loading sessions (%v)", err)
+	}
+
+	if s, err := restored.Get(token, 10*time.Minute); err != nil {
+		t.Errorf("Unexpected error (%v)", err)
+	} else if s.UID != "moony" || s.Role != "admin" {
+		t.Errorf("Incorrect session - expected:%v/%v, got:%v/%v", "moony", "admin", s.UID, s.Role)
+	}
+}
@@ -0,0 +1,442 @@
+package simulator
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"time"
+
+	lib "github.com/uhppoted/uhppote-core/types"
+	"github.com/uhppoted/uhppote-core/uhppote"
+)
+
+// api implements the uhppote-core IUHPPOTE interface for the virtual controllers.
+type api struct {
+	simulator *Simulator
+	devices   map[uint32]uhppote.Device
+}
+
+func (u *api) GetDevices() ([]lib.Device, error) {
+	s := u.simulator
+	s.RLock()
+	defer s.RUnlock()
+
+	list := []lib.Device{}
+	for _, c := range s.controllers {
+		list = append(list, c.device())
+	}
+
+	return list, nil
+}
+
+func (u *api) GetDevice(deviceID uint32) (*lib.Device, error) {
+	return get(u, deviceID, func(c *controller) *lib.Device {
+		device := c.device()
+		return &device
+	})
+}
+
+func (u *api) SetAddress(deviceID uint32, address, mask, gateway net.IP) (*lib.Result, error) {
+	return update(u, deviceID, func(c *controller) *lib.Result {
+		if addr, ok := netip.AddrFromSlice(address.To4()); ok {
+			c.address = netip.AddrPortFrom(addr, c.address.Port())
+		}
+
+		return &lib.Result{SerialNumber: lib.SerialNumber(deviceID), Succeeded: true}
+	})
+}
+
+func (u *api) GetListener(deviceID uint32) (netip.AddrPort, uint8, error) {
+	var interval uint8
+
+	addr, err := get(u, deviceID, func(c *controller) netip.AddrPort {
+		interval = c.interval
+		return c.listener
+	})
+
+	return addr, interval, err
+}
+
+func (u *api) SetListener(deviceID uint32, address netip.AddrPort, interval uint8) (bool, error) {
+	return update(u, deviceID, func(c *controller) bool {
+		c.listener = address
+		c.interval = interval
+
+		return true
+	})
+}
+
+func (u *api) GetTime(deviceID uint32) (*lib.Time, error) {
+	return get(u, deviceID, func(c *controller) *lib.Time {
+		return &lib.Time{
+			SerialNumber: lib.SerialNumber(deviceID),
+			DateTime:     lib.DateTime(c.now()),
+		}
+	})
+}
+
+func (u *api) SetTime(deviceID uint32, datetime time.Time) (*lib.Time, error) {
+	return update(u, deviceID, func(c *controller) *lib.Time {
+		now := time.Now().In(c.timezone)
+		local := time.Date(datetime.Year(), datetime.Month(), datetime.Day(), datetime.Hour(), datetime.Minute(), datetime.Second(), 0, c.timezone)
+
+		c.offset = local.Sub(now).Round(time.Second)
+
+		return &lib.Time{
+			SerialNumber: lib.SerialNumber(deviceID),
+			DateTime:     lib.DateTime(c.now()),
+		}
+	})
+}
+
+func (u *api) GetDoorControlState(deviceID uint32, d byte) (*lib.DoorControlState, error) {
+	if d < 1 || d > 4 {
+		return nil, fmt.Errorf("%v  invalid door (%v)", deviceID, d)
+	}
+
+	return get(u, deviceID, func(c *controller) *lib.DoorControlState {
+		return &lib.DoorControlState{
+			SerialNumber: lib.SerialNumber(deviceID),
+			Door:         d,
+			ControlState: c.doors[d].control,
+			Delay:        c.doors[d].delay,
+		}
+	})
+}
+
+func (u *api) SetDoorControlState(deviceID uint32, d uint8, state lib.ControlState, delay uint8) (*lib.DoorControlState, error) {
+	if d < 1 || d > 4 {
+		return nil, fmt.Errorf("%v  invalid door (%v)", deviceID, d)
+	}
+
+	return update(u, deviceID, func(c *controller) *lib.DoorControlState {
+		c.doors[d].control = state
+		c.doors[d].delay = delay
+
+		return &lib.DoorControlState{
+			SerialNumber: lib.SerialNumber(deviceID),
+			Door:         d,
+			ControlState: state,
+			Delay:        delay,
+		}
+	})
+}
+
+func (u *api) GetStatus(deviceID uint32) (*lib.Status, error) {
+	return get(u, deviceID, func(c *controller) *lib.Status {
+		status := c.status(nil)
+		return &status
+	})
+}
+
+func (u *api) GetCards(deviceID uint32) (uint32, error) {
+	return get(u, deviceID, func(c *controller) uint32 {
+		return uint32(len(c.cards))
+	})
+}
+
+func (u *api) GetCardByIndex(deviceID, index uint32) (*lib.Card, error) {
+	return get(u, deviceID, func(c *controller) *lib.Card {
+		if index < 1 || int(index) > len(c.cards) {
+			return nil
+		}
+
+		card := c.cards[index-1].Clone()
+		return &card
+	})
+}
+
+func (u *api) GetCardByID(deviceID, cardNumber uint32) (*lib.Card, error) {
+	return get(u, deviceID, func(c *controller) *lib.Card {
+		if ix := slices.IndexFunc(c.cards, func(v lib.Card) bool { return v.CardNumber == cardNumber }); ix >= 0 {
+			card := c.cards[ix].Clone()
+			return &card
+		}
+
+		return nil
+	})
+}
+
+func (u *api) PutCard(deviceID uint32, card lib.Card, formats ...lib.CardFormat) (bool, error) {
+	if card.CardNumber == 0 || card.CardNumber == 0xffffffff {
+		return false, fmt.Errorf("%v  invalid card number (%v)", deviceID, card.CardNumber)
+	}
+
+	return update(u, deviceID, func(c *controller) bool {
+		if ix := slices.IndexFunc(c.cards, func(v lib.Card) bool { return v.CardNumber == card.CardNumber }); ix >= 0 {
+			c.cards[ix] = card.Clone()
+		} else {
+			c.cards = append(c.cards, card.Clone())
+		}
+
+		return true
+	})
+}
+
+func (u *api) DeleteCard(deviceID uint32, cardNumber uint32) (bool, error) {
+	return update(u, deviceID, func(c *controller) bool {
+		N := len(c.cards)
+		c.cards = slices.DeleteFunc(c.cards, func(v lib.Card) bool { return v.CardNumber == cardNumber })
+
+		return len(c.cards) < N
+	})
+}
+
+func (u *api) DeleteCards(deviceID uint32) (bool, error) {
+	return update(u, deviceID, func(c *controller) bool {
+		c.cards = nil
+		return true
+	})
+}
+
+func (u *api) GetTimeProfile(deviceID uint32, profileID uint8) (*lib.TimeProfile, error) {
+	return get(u, deviceID, func(c *controller) *lib.TimeProfile {
+		if p, ok := c.profiles[profileID]; ok {
+			return &p
+		}
+
+		return nil
+	})
+}
+
+func (u *api) SetTimeProfile(deviceID uint32, profile lib.TimeProfile) (bool, error) {
+	if profile.ID < 2 || profile.ID > 254 {
+		return false, fmt.Errorf("%v  invalid time profile ID (%v)", deviceID, profile.ID)
+	}
+
+	return update(u, deviceID, func(c *controller) bool {
+		c.profiles[profile.ID] = profile
+		return true
+	})
+}
+
+func (u *api) ClearTimeProfiles(deviceID uint32) (bool, error) {
+	return update(u, deviceID, func(c *controller) bool {
+		c.profiles = map[uint8]lib.TimeProfile{}
+		return true
+	})
+}
+
+func (u *api) ClearTaskList(deviceID uint32) (bool, error) {
+	return update(u, deviceID, func(c *controller) bool { return true })
+}
+
+func (u *api) AddTask(deviceID uint32, task lib.Task) (bool, error) {
+	return update(u, deviceID, func(c *controller) bool { return true })
+}
+
+func (u *api) RefreshTaskList(deviceID uint32) (bool, error) {
+	return update(u, deviceID, func(c *controller) bool { return true })
+}
+
+func (u *api) RecordSpecialEvents(deviceID uint32, enable bool) (bool, error) {
+	return update(u, deviceID, func(c *controller) bool { return true })
+}
+
+// Returns the first event for index 0, the last event for index 0xffffffff and nil if there
+// is no event at the index.
+func (u *api) GetEvent(deviceID, index uint32) (*lib.Event, error) {
+	return get(u, deviceID, func(c *controller) *lib.Event {
+		N := uint32(len(c.events))
+
+		switch {
+		case N == 0:
+			return nil
+
+		case index == 0:
+			e := c.events[0]
+			return &e
+
+		case index == 0xffffffff:
+			e := c.events[N-1]
+			return &e
+
+		case index >= c.first && index < c.first+N:
+			e := c.events[index-c.first]
+			return &e
+
+		default:
+			return nil
+		}
+	})
+}
+
+func (u *api) GetEventIndex(deviceID uint32) (*lib.EventIndex, error) {
+	return get(u, deviceID, func(c *controller) *lib.EventIndex {
+		return &lib.EventIndex{
+			SerialNumber: lib.SerialNumber(deviceID),
+			Index:        c.index,
+		}
+	})
+}
+
+func (u *api) SetEventIndex(deviceID, index uint32) (*lib.EventIndexResult, error) {
+	return update(u, deviceID, func(c *controller) *lib.EventIndexResult {
+		changed := c.index != index
+		c.index = index
+
+		return &lib.EventIndexResult{
+			SerialNumber: lib.SerialNumber(deviceID),
+			Index:        index,
+			Changed:      changed,
+		}
+	})
+}
+
+// Forwards the events generated by the virtual controllers to the listener. Blocks until the
+// 'q' channel is closed.
+func (u *api) Listen(listener uhppote.Listener, q chan os.Signal) error {
+	s := u.simulator
+
+	s.Lock()
+	s.listeners[listener] = struct{}{}
+	s.Unlock()
+
+	defer func() {
+		s.Lock()
+		delete(s.listeners, listener)
+		s.Unlock()
+	}()
+
+	listener.OnConnected()
+
+	<-q
+
+	return nil
+}
+
+func (u *api) SetDoorPasscodes(deviceID uint32, d uint8, passcodes ...uint32) (bool, error) {
+	if d < 1 || d > 4 {
+		return false, fmt.Errorf("%v  invalid door (%v)", deviceID, d)
+	}
+
+	return update(u, deviceID, func(c *controller) bool {
+		c.doors[d].passcodes = []uint32{}
+		for _, v := range passcodes {
+			if v > 0 && v < 1000000 && len(c.doors[d].passcodes) < 4 {
+				c.doors[d].passcodes = append(c.doors[d].passcodes, v)
+			}
+		}
+
+		return true
+	})
+}
+
+// Unlocks the door for the door delay and adds a 'host control' event.
+func (u *api) OpenDoor(deviceID uint32, d uint8) (*lib.Result, error) {
+	if d < 1 || d > 4 {
+		return nil, fmt.Errorf("%v  invalid door (%v)", deviceID, d)
+	}
+
+	var status lib.Status
+
+	result, err := update(u, deviceID, func(c *controller) *lib.Result {
+		now := c.now()
+		c.doors[d].unlocked = now.Add(time.Duration(c.doors[d].delay) * time.Second)
+
+		event := c.add(eventDoor, true, d, 1, 0, reasonHostControl, now)
+		status = c.status(&event)
+
+		return &lib.Result{SerialNumber: lib.SerialNumber(deviceID), Succeeded: true}
+	})
+
+	if err == nil {
+		u.simulator.notify(status)
+	}
+
+	return result, err
+}
+
+func (u *api) SetPCControl(deviceID uint32, enable bool) (bool, error) {
+	return update(u, deviceID, func(c *controller) bool { return true })
+}
+
+func (u *api) SetInterlock(deviceID uint32, interlock lib.Interlock) (bool, error) {
+	return update(u, deviceID, func(c *controller) bool {
+		c.interlock = interlock
+		return true
+	})
+}
+
+func (u *api) ActivateKeypads(deviceID uint32, keypads map[uint8]bool) (bool, error) {
+	return update(u, deviceID, func(c *controller) bool {
+		for _, r := range []uint8{1, 2, 3, 4} {
+			c.keypads[r] = keypads[r]
+		}
+
+		return true
+	})
+}
+
+func (u *api) GetAntiPassback(deviceID uint32) (lib.AntiPassback, error) {
+	return get(u, deviceID, func(c *controller) lib.AntiPassback {
+		return c.antipassback
+	})
+}
+
+func (u *api) SetAntiPassback(deviceID uint32, antipassback lib.AntiPassback) (bool, error) {
+	return update(u, deviceID, func(c *controller) bool {
+		c.antipassback = antipassback
+		return true
+	})
+}
+
+func (u *api) RestoreDefaultParameters(deviceID uint32) (bool, error) {
+	s := u.simulator
+
+	if _, err := s.find(deviceID); err != nil {
+		return false, err
+	}
+
+	s.Lock()
+	delete(s.controllers, deviceID)
+	s.Unlock()
+
+	if d, ok := u.devices[deviceID]; ok {
+		s.controller(deviceID, &d)
+	} else {
+		s.controller(deviceID, nil)
+	}
+
+	return true, nil
+}
+
+func (u *api) DeviceList() map[uint32]uhppote.Device {
+	return u.devices
+}
+
+func (u *api) ListenAddrList() []netip.AddrPort {
+	return []netip.AddrPort{}
+}
+
+// Invokes f with the (read locked) virtual controller.
+func get[T any](u *api, deviceID uint32, f func(c *controller) T) (T, error) {
+	var zero T
+
+	c, err := u.simulator.find(deviceID)
+	if err != nil {
+		return zero, err
+	}
+
+	u.simulator.RLock()
+	defer u.simulator.RUnlock()
+
+	return f(c), nil
+}
+
+// Invokes f with the (write locked) virtual controller.
+func update[T any](u *api, deviceID uint32, f func(c *controller) T) (T, error) {
+	var zero T
+
+	c, err := u.simulator.find(deviceID)
+	if err != nil {
+		return zero, err
+	}
+
+	u.simulator.Lock()
+	defer u.simulator.Unlock()
+
+	return f(c), nil
+}
@@ -0,0 +1,400 @@
+// Package simulator implements an in-process set of virtual UHPPOTE controllers that answer the
+// same uhppote-core API calls as the real (UDP) controllers, for demos and end-to-end tests.
+package simulator
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	lib "github.com/uhppoted/uhppote-core/types"
+	"github.com/uhppoted/uhppote-core/uhppote"
+
+	"github.com/uhppoted/uhppoted-httpd/log"
+)
+
+// Simulator holds the virtual controllers, which are created on first use (or from the
+// list of controllers passed to NewSimulator).
+type Simulator struct {
+	controllers map[uint32]*controller
+	listeners   map[uhppote.Listener]struct{}
+	sync.RWMutex
+}
+
+type controller struct {
+	deviceID     uint32
+	address      netip.AddrPort
+	timezone     *time.Location
+	offset       time.Duration
+	cards        []lib.Card
+	profiles     map[uint8]lib.TimeProfile
+	doors        map[uint8]*door
+	interlock    lib.Interlock
+	antipassback lib.AntiPassback
+	keypads      map[uint8]bool
+	listener     netip.AddrPort
+	interval     uint8
+	events       []lib.Event
+	first        uint32
+	index        uint32
+}
+
+type door struct {
+	control   lib.ControlState
+	delay     uint8
+	open      bool
+	button    bool
+	unlocked  time.Time
+	passcodes []uint32
+}
+
+// The maximum number of events stored by a virtual controller (the oldest events are discarded).
+const MaxEvents = 100000
+
+const (
+	reasonSwipe          uint8 = 1
+	reasonHostControl    uint8 = 5
+	reasonNoPrivilege    uint8 = 6
+	reasonNormallyClosed uint8 = 11
+)
+
+const (
+	eventSwipe uint8 = 1
+	eventDoor  uint8 = 2
+)
+
+// NewSimulator creates a simulator with a virtual controller for each of the listed controller
+// IDs. Virtual controllers for any other controller IDs are created as they are addressed.
+func NewSimulator(controllers ...uint32) *Simulator {
+	s := Simulator{
+		controllers: map[uint32]*controller{},
+		listeners:   map[uhppote.Listener]struct{}{},
+	}
+
+	for _, id := range controllers {
+		s.controller(id, nil)
+	}
+
+	return &s
+}
+
+// UHPPOTE returns the uhppote-core API for the simulator, with the devices as the configured
+// controllers.
+func (s *Simulator) UHPPOTE(devices []uhppote.Device) uhppote.IUHPPOTE {
+	u := api{
+		simulator: s,
+		devices:   map[uint32]uhppote.Device{},
+	}
+
+	for _, d := range devices {
+		u.devices[d.DeviceID] = d
+		s.controller(d.DeviceID, &d)
+	}
+
+	return &u
+}
+
+// Run generates a synthetic card swipe on a random virtual controller at each interval. Blocks
+// until the 'q' channel is closed.
+func (s *Simulator) Run(interval time.Duration, q chan os.Signal) {
+	if interval <= 0 {
+		return
+	}
+
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-q:
+			return
+
+		case <-tick.C:
+			s.swipe()
+		}
+	}
+}
+
+// Swipe simulates a card swiped at a door reader and returns the resulting event.
+func (s *Simulator) Swipe(deviceID uint32, card uint32, d uint8) (lib.Event, error) {
+	if d < 1 || d > 4 {
+		return lib.Event{}, fmt.Errorf("%v  invalid door (%v)", deviceID, d)
+	}
+
+	c, err := s.find(deviceID)
+	if err != nil {
+		return lib.Event{}, err
+	}
+
+	s.Lock()
+
+	now := c.now()
+	granted, reason := c.authorised(card, d, now)
+
+	if granted {
+		c.doors[d].unlocked = now.Add(time.Duration(c.doors[d].delay) * time.Second)
+	}
+
+	event := c.add(eventSwipe, granted, d, 1, card, reason, now)
+	status := c.status(&event)
+
+	s.Unlock()
+
+	s.notify(status)
+
+	return event, nil
+}
+
+func (s *Simulator) swipe() {
+	s.RLock()
+	list := []uint32{}
+	for id := range s.controllers {
+		list = append(list, id)
+	}
+	s.RUnlock()
+
+	if len(list) == 0 {
+		return
+	}
+
+	slices.Sort(list)
+
+	deviceID := list[rand.IntN(len(list))]
+	card := uint32(1000000 + rand.IntN(9000000))
+	door := uint8(1 + rand.IntN(4))
+
+	// ... mostly swipes for cards on the controller
+	s.RLock()
+	if c := s.controllers[deviceID]; c != nil && len(c.cards) > 0 && rand.IntN(10) < 8 {
+		card = c.cards[rand.IntN(len(c.cards))].CardNumber
+	}
+	s.RUnlock()
+
+	if event, err := s.Swipe(deviceID, card, door); err != nil {
+		log.Warnf("%v", err)
+	} else {
+		log.Debugf("simulator  %v  swipe %v", deviceID, event)
+	}
+}
+
+func (s *Simulator) notify(status lib.Status) {
+	s.RLock()
+	listeners := []uhppote.Listener{}
+	for l := range s.listeners {
+		listeners = append(listeners, l)
+	}
+	s.RUnlock()
+
+	for _, l := range listeners {
+		l.OnEvent(&status)
+	}
+}
+
+// Returns the virtual controller for the device ID, creating it if necessary.
+func (s *Simulator) controller(deviceID uint32, device *uhppote.Device) *controller {
+	s.Lock()
+	defer s.Unlock()
+
+	c, ok := s.controllers[deviceID]
+	if !ok {
+		c = &controller{
+			deviceID: deviceID,
+			address:  netip.AddrPortFrom(netip.MustParseAddr("127.0.0.1"), 60000),
+			timezone: time.Local,
+			profiles: map[uint8]lib.TimeProfile{},
+			doors:    map[uint8]*door{},
+			keypads:  map[uint8]bool{},
+			first:    1,
+		}
+
+		for _, d := range []uint8{1, 2, 3, 4} {
+			c.doors[d] = &door{
+				control: lib.Controlled,
+				delay:   5,
+			}
+		}
+
+		s.controllers[deviceID] = c
+	}
+
+	if device != nil {
+		if addr := device.Address; addr.IsValid() {
+			c.address = netip.AddrPortFrom(addr.Addr(), addr.Port())
+		}
+
+		if device.TimeZone != nil {
+			c.timezone = device.TimeZone
+		}
+	}
+
+	return c
+}
+
+func (s *Simulator) find(deviceID uint32) (*controller, error) {
+	if deviceID == 0 {
+		return nil, fmt.Errorf("invalid device ID (%v)", deviceID)
+	}
+
+	return s.controller(deviceID, nil), nil
+}
+
+// Returns the controller date/time i.e. the system time adjusted by any set-time offset.
+func (c *controller) now() time.Time {
+	return time.Now().In(c.timezone).Add(c.offset)
+}
+
+// Checks the card against the controller card list, door control mode and time profiles and
+// returns the access granted flag and event reason.
+func (c *controller) authorised(cardNumber uint32, d uint8, now time.Time) (bool, uint8) {
+	switch c.doors[d].control {
+	case lib.NormallyOpen:
+		return true, reasonSwipe
+
+	case lib.NormallyClosed:
+		return false, reasonNormallyClosed
+	}
+
+	ix := slices.IndexFunc(c.cards, func(v lib.Card) bool { return v.CardNumber == cardNumber })
+	if ix < 0 {
+		return false, reasonNoPrivilege
+	}
+
+	card := c.cards[ix]
+	today := lib.ToDate(now.Year(), now.Month(), now.Day())
+
+	if card.From.After(today) || card.To.Before(today) {
+		return false, reasonNoPrivilege
+	}
+
+	switch permission := card.Doors[d]; {
+	case permission == 1:
+		return true, reasonSwipe
+
+	case permission >= 2 && permission <= 254:
+		if p, ok := c.profiles[permission]; ok && active(p, now) {
+			return true, reasonSwipe
+		}
+	}
+
+	return false, reasonNoPrivilege
+}
+
+// Appends an event to the controller event buffer, discarding the oldest event if the buffer
+// is full.
+func (c *controller) add(eventType uint8, granted bool, d uint8, direction uint8, card uint32, reason uint8, now time.Time) lib.Event {
+	event := lib.Event{
+		SerialNumber: lib.SerialNumber(c.deviceID),
+		Index:        c.first + uint32(len(c.events)),
+		Type:         eventType,
+		Granted:      granted,
+		Door:         d,
+		Direction:    direction,
+		CardNumber:   card,
+		Timestamp:    lib.DateTime(now),
+		Reason:       reason,
+	}
+
+	c.events = append(c.events, event)
+
+	if len(c.events) > MaxEvents {
+		c.events = c.events[1:]
+		c.first++
+	}
+
+	return event
+}
+
+func (c *controller) status(event *lib.Event) lib.Status {
+	now := c.now()
+	status := lib.Status{
+		SerialNumber:   lib.SerialNumber(c.deviceID),
+		DoorState:      map[uint8]bool{},
+		DoorButton:     map[uint8]bool{},
+		SystemDateTime: lib.DateTime(now),
+	}
+
+	for _, d := range []uint8{1, 2, 3, 4} {
+		status.DoorState[d] = c.doors[d].open
+		status.DoorButton[d] = c.doors[d].button
+
+		if c.doors[d].control == lib.NormallyOpen || (c.doors[d].control == lib.Controlled && now.Before(c.doors[d].unlocked)) {
+			status.RelayState |= 1 << (d - 1)
+		}
+	}
+
+	if event != nil {
+		status.SequenceId = event.Index
+		status.Event = lib.StatusEvent{
+			Index:      event.Index,
+			Type:       event.Type,
+			Granted:    event.Granted,
+			Door:       event.Door,
+			Direction:  event.Direction,
+			CardNumber: event.CardNumber,
+			Timestamp:  event.Timestamp,
+			Reason:     event.Reason,
+		}
+	} else if N := len(c.events); N > 0 {
+		e := c.events[N-1]
+		status.SequenceId = e.Index
+		status.Event = lib.StatusEvent{
+			Index:      e.Index,
+			Type:       e.Type,
+			Granted:    e.Granted,
+			Door:       e.Door,
+			Direction:  e.Direction,
+			CardNumber: e.CardNumber,
+			Timestamp:  e.Timestamp,
+			Reason:     e.Reason,
+		}
+	}
+
+	return status
+}
+
+func (c *controller) device() lib.Device {
+	ip := net.IP(c.address.Addr().AsSlice())
+
+	return lib.Device{
+		Name:         fmt.Sprintf("simulator-%v", c.deviceID),
+		SerialNumber: lib.SerialNumber(c.deviceID),
+		IpAddress:    ip,
+		SubnetMask:   net.IPv4(255, 255, 255, 0),
+		Gateway:      net.IPv4(0, 0, 0, 0),
+		MacAddress:   lib.MacAddress{0x00, 0x12, 0x23, 0x34, 0x45, 0x56},
+		Version:      0x0892,
+		Date:         lib.ToDate(2018, time.November, 5),
+		Address:      c.address,
+		TimeZone:     c.timezone,
+	}
+}
+
+// Returns true if the time profile allows access at the date/time (linked profiles are not
+// simulated).
+func active(p lib.TimeProfile, now time.Time) bool {
+	today := lib.ToDate(now.Year(), now.Month(), now.Day())
+	hhmm := lib.NewHHmm(now.Hour(), now.Minute())
+
+	if p.From.After(today) || p.To.Before(today) || !p.Weekdays[now.Weekday()] {
+		return false
+	}
+
+	midnight := lib.NewHHmm(0, 0)
+
+	for _, segment := range p.Segments {
+		if segment.Start.Equals(midnight) && segment.End.Equals(midnight) {
+			continue
+		}
+
+		if !hhmm.Before(segment.Start) && !hhmm.After(segment.End) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,251 @@
+package simulator
+
+import (
+	"net/netip"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	lib "github.com/uhppoted/uhppote-core/types"
+	"github.com/uhppoted/uhppote-core/uhppote"
+	"github.com/uhppoted/uhppoted-lib/acl"
+)
+
+type listener struct {
+	connected chan struct{}
+	events    chan lib.Status
+}
+
+func (l *listener) OnConnected()               { close(l.connected) }
+func (l *listener) OnEvent(status *lib.Status) { l.events <- *status }
+func (l *listener) OnError(err error) bool     { return true }
+
+func devices() []uhppote.Device {
+	return []uhppote.Device{
+		uhppote.NewDevice("Alpha", 405419896, lib.MustParseControllerAddr("192.168.1.100:60000"), "udp", []string{}, time.Local),
+	}
+}
+
+func card(cardNumber uint32, doors map[uint8]uint8) lib.Card {
+	return lib.Card{
+		CardNumber: cardNumber,
+		From:       lib.ToDate(2024, time.January, 1),
+		To:         lib.ToDate(2099, time.December, 31),
+		Doors:      doors,
+	}
+}
+
+func TestGetDevices(t *testing.T) {
+	s := NewSimulator(405419896, 303986753)
+	u := s.UHPPOTE(devices())
+
+	list, err := u.GetDevices()
+	if err != nil {
+		t.Fatalf("Unexpected error (%v)", err)
+	}
+
+	if len(list) != 2 {
+		t.Fatalf("Incorrect number of controllers - expected:%v, got:%v", 2, len(list))
+	}
+
+	device, err := u.GetDevice(405419896)
+	if err != nil {
+		t.Fatalf("Unexpected error (%v)", err)
+	} else if device == nil {
+		t.Fatalf("Expected controller, got %v", device)
+	}
+
+	if expected := netip.MustParseAddrPort("192.168.1.100:60000"); device.Address != expected {
+		t.Errorf("Incorrect controller address - expected:%v, got:%v", expected, device.Address)
+	}
+}
+
+func TestCards(t *testing.T) {
+	s := NewSimulator()
+	u := s.UHPPOTE(devices())
+
+	for _, c := range []lib.Card{
+		card(10058400, map[uint8]uint8{1: 1, 2: 0, 3: 0, 4: 0}),
+		card(10058401, map[uint8]uint8{1: 0, 2: 1, 3: 0, 4: 0}),
+		card(10058402, map[uint8]uint8{1: 0, 2: 0, 3: 1, 4: 0}),
+	} {
+		if ok, err := u.PutCard(405419896, c); err != nil || !ok {
+			t.Fatalf("Error adding card %v (%v)", c.CardNumber, err)
+		}
+	}
+
+	if ok, err := u.DeleteCard(405419896, 10058401); err != nil || !ok {
+		t.Fatalf("Error deleting card (%v)", err)
+	}
+
+	if N, err := u.GetCards(405419896); err != nil {
+		t.Fatalf("Unexpected error (%v)", err)
+	} else if N != 2 {
+		t.Errorf("Incorrect number of cards - expected:%v, got:%v", 2, N)
+	}
+
+	if c, err := u.GetCardByID(405419896, 10058401); err != nil {
+		t.Fatalf("Unexpected error (%v)", err)
+	} else if c != nil {
+		t.Errorf("Expected deleted card, got %v", c)
+	}
+
+	// ... uhppoted-lib ACL
+	current, errors := acl.GetACL(u, devices())
+	if len(errors) > 0 {
+		t.Fatalf("Unexpected error retrieving ACL (%v)", errors)
+	}
+
+	expected := acl.ACL{
+		405419896: map[uint32]lib.Card{
+			10058400: card(10058400, map[uint8]uint8{1: 1, 2: 0, 3: 0, 4: 0}),
+			10058402: card(10058402, map[uint8]uint8{1: 0, 2: 0, 3: 1, 4: 0}),
+		},
+	}
+
+	if !reflect.DeepEqual(current, expected) {
+		t.Errorf("Incorrect ACL\n   expected:%v\n   got:     %v", expected, current)
+	}
+}
+
+func TestSwipe(t *testing.T) {
+	s := NewSimulator()
+	u := s.UHPPOTE(devices())
+
+	if _, err := u.PutCard(405419896, card(10058400, map[uint8]uint8{1: 1, 2: 0, 3: 0, 4: 0})); err != nil {
+		t.Fatalf("Error adding card (%v)", err)
+	}
+
+	if _, err := u.SetDoorControlState(405419896, 3, lib.NormallyOpen, 5); err != nil {
+		t.Fatalf("Error setting door control (%v)", err)
+	}
+
+	tests := []struct {
+		card    uint32
+		door    uint8
+		granted bool
+		reason  uint8
+	}{
+		{10058400, 1, true, reasonSwipe},
+		{10058400, 2, false, reasonNoPrivilege},
+		{10058401, 1, false, reasonNoPrivilege},
+		{10058401, 3, true, reasonSwipe},
+	}
+
+	for i, test := range tests {
+		event, err := s.Swipe(405419896, test.card, test.door)
+		if err != nil {
+			t.Fatalf("Unexpected error (%v)", err)
+		}
+
+		if event.Index != uint32(i+1) {
+			t.Errorf("Incorrect event index - expected:%v, got:%v", i+1, event.Index)
+		}
+
+		if event.Granted != test.granted || event.Reason != test.reason {
+			t.Errorf("Incorrect swipe for card %v door %v - expected:%v/%v, got:%v/%v",
+				test.card, test.door, test.granted, test.reason, event.Granted, event.Reason)
+		}
+	}
+
+	if status, err := u.GetStatus(405419896); err != nil {
+		t.Fatalf("Unexpected error (%v)", err)
+	} else if status.RelayState&0x01 != 0x01 {
+		t.Errorf("Expected door 1 to be unlocked, relay state %02x", status.RelayState)
+	}
+
+	if _, err := s.Swipe(405419896, 10058400, 5); err == nil {
+		t.Errorf("Expected error for invalid door")
+	}
+}
+
+func TestGetEvent(t *testing.T) {
+	s := NewSimulator()
+	u := s.UHPPOTE(devices())
+
+	if e, err := u.GetEvent(405419896, 0); err != nil {
+		t.Fatalf("Unexpected error (%v)", err)
+	} else if e != nil {
+		t.Errorf("Expected no events, got %v", e)
+	}
+
+	for range 3 {
+		if _, err := s.Swipe(405419896, 10058400, 1); err != nil {
+			t.Fatalf("Unexpected error (%v)", err)
+		}
+	}
+
+	tests := map[uint32]uint32{
+		0:          1,
+		2:          2,
+		0xffffffff: 3,
+	}
+
+	for index, expected := range tests {
+		if e, err := u.GetEvent(405419896, index); err != nil {
+			t.Fatalf("Unexpected error (%v)", err)
+		} else if e == nil || e.Index != expected {
+			t.Errorf("Incorrect event for index %v - expected:%v, got:%v", index, expected, e)
+		}
+	}
+
+	if e, err := u.GetEvent(405419896, 4); err != nil {
+		t.Fatalf("Unexpected error (%v)", err)
+	} else if e != nil {
+		t.Errorf("Expected no event at index 4, got %v", e)
+	}
+}
+
+func TestSetTime(t *testing.T) {
+	s := NewSimulator()
+	u := s.UHPPOTE(devices())
+
+	datetime := time.Now().Add(-36 * time.Hour)
+
+	if _, err := u.SetTime(405419896, datetime); err != nil {
+		t.Fatalf("Unexpected error (%v)", err)
+	}
+
+	v, err := u.GetTime(405419896)
+	if err != nil {
+		t.Fatalf("Unexpected error (%v)", err)
+	}
+
+	if dt := time.Time(v.DateTime).Sub(datetime); dt < -2*time.Second || dt > 2*time.Second {
+		t.Errorf("Incorrect controller time - expected:%v, got:%v", datetime, v.DateTime)
+	}
+}
+
+func TestListen(t *testing.T) {
+	s := NewSimulator()
+	u := s.UHPPOTE(devices())
+	q := make(chan os.Signal)
+	l := listener{
+		connected: make(chan struct{}),
+		events:    make(chan lib.Status, 1),
+	}
+
+	go u.Listen(&l, q)
+	defer close(q)
+
+	select {
+	case <-l.connected:
+	case <-time.After(1 * time.Second):
+		t.Fatalf("Timeout waiting for listener")
+	}
+
+	if _, err := s.Swipe(405419896, 10058400, 2); err != nil {
+		t.Fatalf("Unexpected error (%v)", err)
+	}
+
+	select {
+	case status := <-l.events:
+		if uint32(status.SerialNumber) != 405419896 || status.Event.CardNumber != 10058400 || status.Event.Door != 2 {
+			t.Errorf("Incorrect event %v", status.Event)
+		}
+
+	case <-time.After(1 * time.Second):
+		t.Errorf("Timeout waiting for event")
+	}
+}
@@ -0,0 +1,379 @@
+package sqlite
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// DB is an SQLite backed store for the serialized system subsystems. Each subsystem is stored as
+// a set of records keyed by OID (or by the record hash and occurrence for records without an OID)
+// so that a Save only inserts, updates or deletes the records that have changed since the last Save.
+type DB struct {
+	db     *sql.DB
+	hashes map[string]map[string]string
+	sync.Mutex
+}
+
+type record struct {
+	key    string
+	hash   string
+	record json.RawMessage
+}
+
+const schema = `
+CREATE TABLE IF NOT EXISTS subsystems (
+  tag      TEXT PRIMARY KEY,
+  migrated TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS records (
+  tag    TEXT NOT NULL,
+  key    TEXT NOT NULL,
+  hash   TEXT NOT NULL,
+  record TEXT NOT NULL,
+  PRIMARY KEY (tag, key)
+);
+`
+
+func Open(file string) (*DB, error) {
+	if err := os.MkdirAll(filepath.Dir(file), 0770); err != nil {
+		return nil, err
+	}
+
+	dsn := fmt.Sprintf("file:%v?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", file)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// NTS: SQLite only supports a single writer
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &DB{
+		db:     db,
+		hashes: map[string]map[string]string{},
+	}, nil
+}
+
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+// Returns the stored records for a subsystem as a JSON array. Returns false if the subsystem
+// has not (yet) been saved to the database.
+func (d *DB) Load(tag string) (json.RawMessage, bool, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	var migrated string
+	if err := d.db.QueryRow(`SELECT migrated FROM subsystems WHERE tag=?`, tag).Scan(&migrated); err == sql.ErrNoRows {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	rows, err := d.db.Query(`SELECT key,hash,record FROM records WHERE tag=? ORDER BY rowid`, tag)
+	if err != nil {
+		return nil, false, err
+	}
+
+	defer rows.Close()
+
+	hashes := map[string]string{}
+	records := []json.RawMessage{}
+
+	for rows.Next() {
+		var key, hash, record string
+
+		if err := rows.Scan(&key, &hash, &record); err != nil {
+			return nil, false, err
+		}
+
+		hashes[key] = hash
+		records = append(records, json.RawMessage(record))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	blob, err := json.Marshal(records)
+	if err != nil {
+		return nil, false, err
+	}
+
+	d.hashes[tag] = hashes
+
+	return blob, true, nil
+}
+
+// Updates the stored records for a subsystem from the JSON array returned by the subsystem Save.
+// Only records that have been added, changed or removed are written to the database.
+func (d *DB) Save(tag string, blob json.RawMessage) error {
+	d.Lock()
+	defer d.Unlock()
+
+	records, err := unpack(blob)
+	if err != nil {
+		return err
+	}
+
+	hashes, ok := d.hashes[tag]
+	if !ok {
+		if hashes, err = d.stored(tag); err != nil {
+			return err
+		}
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	migrated := time.Now().Format(time.RFC3339)
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO subsystems (tag,migrated) VALUES (?,?)`, tag, migrated); err != nil {
+		return err
+	}
+
+	updated := map[string]string{}
+	for _, r := range records {
+		updated[r.key] = r.hash
+
+		if hash, ok := hashes[r.key]; !ok || hash != r.hash {
+			query := `INSERT INTO records (tag,key,hash,record) VALUES (?,?,?,?)
+			        ON CONFLICT(tag,key) DO UPDATE SET hash=excluded.hash, record=excluded.record`
+
+			if _, err := tx.Exec(query, tag, r.key, r.hash, string(r.record)); err != nil {
+				return err
+			}
+		}
+	}
+
+	for key := range hashes {
+		if _, ok := updated[key]; !ok {
+			if _, err := tx.Exec(`DELETE FROM records WHERE tag=? AND key=?`, tag, key); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	d.hashes[tag] = updated
+
+	return nil
+}
+
+// Appends records to the stored records for a subsystem without reading, diffing or rewriting
+// the existing records i.e. the cost of an append depends only on the number of records appended
+// and not on the size of the subsystem. Used for events and logs, which are only ever appended
+// to between saves.
+func (d *DB) Append(tag string, list []json.RawMessage) error {
+	d.Lock()
+	defer d.Unlock()
+
+	hashes, ok := d.hashes[tag]
+	if !ok {
+		var err error
+		if hashes, err = d.stored(tag); err != nil {
+			return err
+		}
+	}
+
+	records := []record{}
+	keys := map[string]bool{}
+	for _, v := range list {
+		r, err := newRecord(v, func(key string) bool {
+			_, ok := hashes[key]
+			return ok || keys[key]
+		})
+
+		if err != nil {
+			return err
+		}
+
+		keys[r.key] = true
+		records = append(records, r)
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	migrated := time.Now().Format(time.RFC3339)
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO subsystems (tag,migrated) VALUES (?,?)`, tag, migrated); err != nil {
+		return err
+	}
+
+	for _, r := range records {
+		if _, err := tx.Exec(`INSERT INTO records (tag,key,hash,record) VALUES (?,?,?,?)`, tag, r.key, r.hash, string(r.record)); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for _, r := range records {
+		hashes[r.key] = r.hash
+	}
+
+	d.hashes[tag] = hashes
+
+	return nil
+}
+
+// Replaces the stored records for the subsystems in a single transaction i.e. either all the
+// subsystems are replaced or none are. Used to restore the system from a backup.
+func (d *DB) Replace(blobs map[string]json.RawMessage) error {
+	d.Lock()
+	defer d.Unlock()
+
+	unpacked := map[string][]record{}
+	for tag, blob := range blobs {
+		if records, err := unpack(blob); err != nil {
+			return fmt.Errorf("%v: %v", tag, err)
+		} else {
+			unpacked[tag] = records
+		}
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	migrated := time.Now().Format(time.RFC3339)
+	hashes := map[string]map[string]string{}
+
+	for tag, records := range unpacked {
+		if _, err := tx.Exec(`DELETE FROM records WHERE tag=?`, tag); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO subsystems (tag,migrated) VALUES (?,?)`, tag, migrated); err != nil {
+			return err
+		}
+
+		hashes[tag] = map[string]string{}
+		for _, r := range records {
+			if _, err := tx.Exec(`INSERT INTO records (tag,key,hash,record) VALUES (?,?,?,?)`, tag, r.key, r.hash, string(r.record)); err != nil {
+				return err
+			}
+
+			hashes[tag][r.key] = r.hash
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for tag, h := range hashes {
+		d.hashes[tag] = h
+	}
+
+	return nil
+}
+
+func (d *DB) stored(tag string) (map[string]string, error) {
+	rows, err := d.db.Query(`SELECT key,hash FROM records WHERE tag=?`, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	hashes := map[string]string{}
+	for rows.Next() {
+		var key, hash string
+		if err := rows.Scan(&key, &hash); err != nil {
+			return nil, err
+		}
+
+		hashes[key] = hash
+	}
+
+	return hashes, rows.Err()
+}
+
+func unpack(blob json.RawMessage) ([]record, error) {
+	list := []json.RawMessage{}
+	if err := json.Unmarshal(blob, &list); err != nil {
+		return nil, err
+	}
+
+	records := []record{}
+	keys := map[string]bool{}
+
+	for _, v := range list {
+		r, err := newRecord(v, func(key string) bool {
+			return keys[key]
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		keys[r.key] = true
+		records = append(records, r)
+	}
+
+	return records, nil
+}
+
+// Returns the record keyed by OID or, for records without an OID or with an OID that is already
+// in use, by the record hash.
+func newRecord(v json.RawMessage, exists func(key string) bool) (record, error) {
+	var b bytes.Buffer
+	if err := json.Compact(&b, v); err != nil {
+		return record{}, err
+	}
+
+	hash := sha256.Sum256(b.Bytes())
+	r := record{
+		key:    hex.EncodeToString(hash[:]),
+		hash:   hex.EncodeToString(hash[:]),
+		record: b.Bytes(),
+	}
+
+	oid := struct {
+		OID string `json:"OID"`
+	}{}
+
+	if err := json.Unmarshal(b.Bytes(), &oid); err == nil && oid.OID != "" && !exists(oid.OID) {
+		r.key = oid.OID
+	}
+
+	// ... identical records (e.g. duplicate history entries) are keyed by occurrence
+	for key, n := r.key, 1; exists(r.key); n++ {
+		r.key = fmt.Sprintf("%v.%v", key, n)
+	}
+
+	return r, nil
+}
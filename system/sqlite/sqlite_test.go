@@ -0,0 +1,315 @@
+package sqlite
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "httpd.db"))
+	if err != nil {
+		t.Fatalf("Error opening database (%v)", err)
+	}
+
+	defer db.Close()
+
+	blob := json.RawMessage(`[
+	  { "OID": "0.4.1", "card": 10058400, "name": "Hermione Granger" },
+	  { "OID": "0.4.2", "card": 10058401, "name": "Harry Potter" }
+	]`)
+
+	if err := db.Save("cards", blob); err != nil {
+		t.Fatalf("Error saving records (%v)", err)
+	}
+
+	loaded, ok, err := db.Load("cards")
+	if err != nil {
+		t.Fatalf("Error loading records (%v)", err)
+	} else if !ok {
+		t.Fatalf("Expected stored records for 'cards'")
+	}
+
+	compare(t, loaded, blob)
+}
+
+func TestLoadWithNoRecords(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "httpd.db"))
+	if err != nil {
+		t.Fatalf("Error opening database (%v)", err)
+	}
+
+	defer db.Close()
+
+	if _, ok, err := db.Load("cards"); err != nil {
+		t.Fatalf("Error loading records (%v)", err)
+	} else if ok {
+		t.Errorf("Expected no stored records for 'cards'")
+	}
+}
+
+func TestIncrementalSave(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "httpd.db")
+	db, err := Open(file)
+	if err != nil {
+		t.Fatalf("Error opening database (%v)", err)
+	}
+
+	initial := json.RawMessage(`[
+	  { "OID": "0.4.1", "card": 10058400, "name": "Hermione Granger" },
+	  { "OID": "0.4.2", "card": 10058401, "name": "Harry Potter" },
+	  { "OID": "0.4.3", "card": 10058402, "name": "Ron Weasley" }
+	]`)
+
+	updated := json.RawMessage(`[
+	  { "OID": "0.4.1", "card": 10058400, "name": "Hermione Granger" },
+	  { "OID": "0.4.3", "card": 10058402, "name": "Ronald Weasley" },
+	  { "OID": "0.4.4", "card": 10058403, "name": "Neville Longbottom" }
+	]`)
+
+	if err := db.Save("cards", initial); err != nil {
+		t.Fatalf("Error saving records (%v)", err)
+	}
+
+	var rowid int64
+	if err := db.db.QueryRow(`SELECT rowid FROM records WHERE key='0.4.1'`).Scan(&rowid); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if err := db.Save("cards", updated); err != nil {
+		t.Fatalf("Error saving records (%v)", err)
+	}
+
+	var rowid2 int64
+	if err := db.db.QueryRow(`SELECT rowid FROM records WHERE key='0.4.1'`).Scan(&rowid2); err != nil {
+		t.Fatalf("%v", err)
+	} else if rowid2 != rowid {
+		t.Errorf("Unchanged record rewritten - expected rowid %v, got %v", rowid, rowid2)
+	}
+
+	db.Close()
+
+	// ... reopen and check
+	db, err = Open(file)
+	if err != nil {
+		t.Fatalf("Error opening database (%v)", err)
+	}
+
+	defer db.Close()
+
+	loaded, _, err := db.Load("cards")
+	if err != nil {
+		t.Fatalf("Error loading records (%v)", err)
+	}
+
+	compare(t, loaded, updated)
+}
+
+func TestSaveRecordsWithoutOID(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "httpd.db"))
+	if err != nil {
+		t.Fatalf("Error opening database (%v)", err)
+	}
+
+	defer db.Close()
+
+	blob := json.RawMessage(`[
+	  { "timestamp": "2023-04-01 12:34:56", "item": "card", "field": "name", "after": "Hermione" },
+	  { "timestamp": "2023-04-01 12:35:00", "item": "card", "field": "name", "after": "Harry" }
+	]`)
+
+	if err := db.Save("history", blob); err != nil {
+		t.Fatalf("Error saving records (%v)", err)
+	}
+
+	loaded, _, err := db.Load("history")
+	if err != nil {
+		t.Fatalf("Error loading records (%v)", err)
+	}
+
+	compare(t, loaded, blob)
+}
+
+func TestSaveIdenticalRecords(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "httpd.db"))
+	if err != nil {
+		t.Fatalf("Error opening database (%v)", err)
+	}
+
+	defer db.Close()
+
+	blob := json.RawMessage(`[
+	  { "timestamp": "2023-04-01 12:34:56", "item": "card", "field": "name", "after": "Hermione" },
+	  { "timestamp": "2023-04-01 12:34:56", "item": "card", "field": "name", "after": "Hermione" },
+	  { "timestamp": "2023-04-01 12:35:00", "item": "card", "field": "name", "after": "Harry" },
+	  { "timestamp": "2023-04-01 12:34:56", "item": "card", "field": "name", "after": "Hermione" }
+	]`)
+
+	for _, expected := range []int{4, 4} {
+		if err := db.Save("history", blob); err != nil {
+			t.Fatalf("Error saving records (%v)", err)
+		}
+
+		loaded, _, err := db.Load("history")
+		if err != nil {
+			t.Fatalf("Error loading records (%v)", err)
+		}
+
+		records := []json.RawMessage{}
+		if err := json.Unmarshal(loaded, &records); err != nil {
+			t.Fatalf("%v", err)
+		} else if len(records) != expected {
+			t.Errorf("Incorrect number of records - expected:%v, got:%v", expected, len(records))
+		}
+	}
+
+	// ... removing a duplicate should delete only one record
+	updated := json.RawMessage(`[
+	  { "timestamp": "2023-04-01 12:34:56", "item": "card", "field": "name", "after": "Hermione" },
+	  { "timestamp": "2023-04-01 12:35:00", "item": "card", "field": "name", "after": "Harry" }
+	]`)
+
+	if err := db.Save("history", updated); err != nil {
+		t.Fatalf("Error saving records (%v)", err)
+	}
+
+	var count int
+	if err := db.db.QueryRow(`SELECT COUNT(*) FROM records WHERE tag='history'`).Scan(&count); err != nil {
+		t.Fatalf("%v", err)
+	} else if count != 2 {
+		t.Errorf("Incorrect number of stored records - expected:%v, got:%v", 2, count)
+	}
+}
+
+func TestAppend(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "httpd.db"))
+	if err != nil {
+		t.Fatalf("Error opening database (%v)", err)
+	}
+
+	defer db.Close()
+
+	initial := json.RawMessage(`[
+	  { "OID": "0.6.1", "device-id": 405419896, "index": 1 },
+	  { "OID": "0.6.2", "device-id": 405419896, "index": 2 }
+	]`)
+
+	appended := []json.RawMessage{
+		json.RawMessage(`{ "OID": "0.6.3", "device-id": 405419896, "index": 3 }`),
+		json.RawMessage(`{ "device-id": 405419896, "horizon": 2 }`),
+	}
+
+	expected := json.RawMessage(`[
+	  { "OID": "0.6.1", "device-id": 405419896, "index": 1 },
+	  { "OID": "0.6.2", "device-id": 405419896, "index": 2 },
+	  { "OID": "0.6.3", "device-id": 405419896, "index": 3 },
+	  { "device-id": 405419896, "horizon": 2 }
+	]`)
+
+	if err := db.Save("events", initial); err != nil {
+		t.Fatalf("Error saving records (%v)", err)
+	}
+
+	if err := db.Append("events", appended); err != nil {
+		t.Fatalf("Error appending records (%v)", err)
+	}
+
+	loaded, _, err := db.Load("events")
+	if err != nil {
+		t.Fatalf("Error loading records (%v)", err)
+	}
+
+	compare(t, loaded, expected)
+
+	// ... a full save of the same records should not rewrite any records
+	records, err := unpack(expected)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	for _, r := range records {
+		if hash := db.hashes["events"][r.key]; hash != r.hash {
+			t.Errorf("Incorrect hash for appended record %v - expected:%v, got:%v", r.key, r.hash, hash)
+		}
+	}
+}
+
+func TestReplace(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "httpd.db"))
+	if err != nil {
+		t.Fatalf("Error opening database (%v)", err)
+	}
+
+	defer db.Close()
+
+	db.Save("cards", json.RawMessage(`[
+	  { "OID": "0.4.1", "card": 10058400, "name": "Hermione Granger" },
+	  { "OID": "0.4.2", "card": 10058401, "name": "Harry Potter" }
+	]`))
+
+	db.Save("groups", json.RawMessage(`[
+	  { "OID": "0.5.1", "name": "Gryffindor" }
+	]`))
+
+	cards := json.RawMessage(`[
+	  { "OID": "0.4.3", "card": 10058402, "name": "Ron Weasley" }
+	]`)
+
+	groups := json.RawMessage(`[]`)
+
+	if err := db.Replace(map[string]json.RawMessage{"cards": cards, "groups": groups}); err != nil {
+		t.Fatalf("Error replacing records (%v)", err)
+	}
+
+	if loaded, _, err := db.Load("cards"); err != nil {
+		t.Fatalf("Error loading records (%v)", err)
+	} else {
+		compare(t, loaded, cards)
+	}
+
+	if loaded, _, err := db.Load("groups"); err != nil {
+		t.Fatalf("Error loading records (%v)", err)
+	} else {
+		compare(t, loaded, groups)
+	}
+
+	// ... invalid blob should leave the stored records unchanged
+	if err := db.Replace(map[string]json.RawMessage{"cards": json.RawMessage(`[]`), "groups": json.RawMessage(`{}`)}); err == nil {
+		t.Errorf("Expected error replacing records with invalid blob")
+	}
+
+	if loaded, _, err := db.Load("cards"); err != nil {
+		t.Fatalf("Error loading records (%v)", err)
+	} else {
+		compare(t, loaded, cards)
+	}
+}
+
+func compare(t *testing.T, blob, expected json.RawMessage) {
+	t.Helper()
+
+	var p, q []map[string]any
+
+	if err := json.Unmarshal(blob, &p); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if err := json.Unmarshal(expected, &q); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	index := func(list []map[string]any) map[string]map[string]any {
+		m := map[string]map[string]any{}
+		for _, v := range list {
+			b, _ := json.Marshal(v)
+			m[string(b)] = v
+		}
+
+		return m
+	}
+
+	if !reflect.DeepEqual(index(p), index(q)) {
+		t.Errorf("Incorrect records\n   expected:%v\n   got:     %v", q, p)
+	}
+}
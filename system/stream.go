@@ -0,0 +1,128 @@
+package system
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+)
+
+// Interval over which catalog changes are accumulated before being published to a stream.
+const STREAM_HOLDOFF = 250 * time.Millisecond
+
+// Publishes the objects for catalog entries that have been created, updated or deleted to the
+// 'ch' channel, until 'q' is closed. Objects are filtered by the same authorisation rules as the
+// equivalent GET request and are further restricted to the subsystems in 'scope'.
+func Stream(uid, role string, scope []schema.OID, ch chan<- []schema.Object, q <-chan struct{}) {
+	w := catalog.Watch()
+	defer w.Close()
+
+	for {
+		select {
+		case <-q:
+			return
+
+		case <-w.C:
+		}
+
+		select {
+		case <-q:
+			return
+
+		case <-time.After(STREAM_HOLDOFF):
+		}
+
+		if objects := changes(uid, role, scope, w.Changed()); len(objects) > 0 {
+			select {
+			case <-q:
+				return
+
+			case ch <- objects:
+			}
+		}
+	}
+}
+
+func changes(uid, role string, scope []schema.OID, oids []schema.OID) []schema.Object {
+	sys.RLock()
+	defer sys.RUnlock()
+
+	auth := auth.NewAuthorizator(uid, role)
+	records := map[schema.OID][]schema.OID{}
+
+	for _, oid := range oids {
+		if record, ok := recordOf(oid); ok {
+			for _, prefix := range scope {
+				if prefix.Contains(record) {
+					records[prefix] = append(records[prefix], record)
+				}
+			}
+		}
+	}
+
+	objects := []schema.Object{}
+
+	filter := func(list []schema.Object, records []schema.OID) {
+		set := map[schema.OID]bool{}
+		for _, oid := range records {
+			set[oid] = true
+		}
+
+		for _, o := range list {
+			if record, ok := recordOf(o.OID); ok && set[record] {
+				objects = append(objects, o)
+			}
+		}
+	}
+
+	for prefix, list := range records {
+		switch prefix {
+		case schema.InterfacesOID:
+			filter(sys.interfaces.AsObjects(auth), list)
+
+		case schema.ControllersOID:
+			filter(sys.controllers.AsObjects(auth), list)
+
+		case schema.DoorsOID:
+			filter(sys.doors.AsObjects(auth), list)
+
+		case schema.CardsOID:
+			filter(sys.cards.AsObjects(auth, 0, math.MaxInt32), list)
+
+		case schema.GroupsOID:
+			filter(sys.groups.AsObjects(auth), list)
+
+		case schema.EventsOID:
+			catalog.Join(&objects, sys.events.AsObjectsByOID(auth, list...)...)
+
+		case schema.LogsOID:
+			catalog.Join(&objects, sys.logs.AsObjectsByOID(auth, list...)...)
+
+		case schema.UsersOID:
+			filter(sys.users.AsObjects(auth), list)
+
+		case schema.TimeProfilesOID:
+			filter(sys.profiles.AsObjects(auth), list)
+
+		case schema.TasksOID:
+			filter(sys.tasks.AsObjects(auth), list)
+		}
+	}
+
+	return objects
+}
+
+// Returns the OID of the record for a catalog OID i.e. the OID truncated to <system>.<subsystem>.<item>.
+// Subsystem metadata (e.g. 0.6.0.1) is not associated with a record.
+func recordOf(oid schema.OID) (schema.OID, bool) {
+	tokens := strings.SplitN(string(oid), ".", 4)
+
+	if len(tokens) < 3 || tokens[2] == "0" || tokens[2] == "" {
+		return "", false
+	}
+
+	return schema.OID(strings.Join(tokens[:3], ".")), true
+}
@@ -17,7 +17,9 @@ import (
 	libos "github.com/uhppoted/uhppoted-lib/os"
 
 	"github.com/uhppoted/uhppoted-httpd/audit"
+	"github.com/uhppoted/uhppoted-httpd/auth"
 	"github.com/uhppoted/uhppoted-httpd/log"
+	"github.com/uhppoted/uhppoted-httpd/settings"
 	"github.com/uhppoted/uhppoted-httpd/system/cards"
 	"github.com/uhppoted/uhppoted-httpd/system/catalog"
 	"github.com/uhppoted/uhppoted-httpd/system/catalog/impl"
@@ -30,6 +32,11 @@ import (
 	"github.com/uhppoted/uhppoted-httpd/system/history"
 	"github.com/uhppoted/uhppoted-httpd/system/interfaces"
 	"github.com/uhppoted/uhppoted-httpd/system/logs"
+	"github.com/uhppoted/uhppoted-httpd/system/notifications"
+	"github.com/uhppoted/uhppoted-httpd/system/sessions"
+	"github.com/uhppoted/uhppoted-httpd/system/sqlite"
+	"github.com/uhppoted/uhppoted-httpd/system/tasks"
+	"github.com/uhppoted/uhppoted-httpd/system/timeprofiles"
 	"github.com/uhppoted/uhppoted-httpd/system/users"
 	"github.com/uhppoted/uhppoted-httpd/types"
 )
@@ -37,15 +44,19 @@ import (
 type Tag string
 
 const (
-	TagInterfaces  Tag = "interfaces"
-	TagControllers Tag = "controllers"
-	TagDoors       Tag = "doors"
-	TagCards       Tag = "cards"
-	TagGroups      Tag = "groups"
-	TagEvents      Tag = "events"
-	TagLogs        Tag = "logs"
-	TagUsers       Tag = "users"
-	TagHistory     Tag = "history"
+	TagInterfaces   Tag = "interfaces"
+	TagControllers  Tag = "controllers"
+	TagDoors        Tag = "doors"
+	TagCards        Tag = "cards"
+	TagGroups       Tag = "groups"
+	TagEvents       Tag = "events"
+	TagLogs         Tag = "logs"
+	TagUsers        Tag = "users"
+	TagHistory      Tag = "history"
+	TagTimeProfiles Tag = "time-profiles"
+	TagTasks        Tag = "tasks"
+	TagTaskRuns     Tag = "task-runs"
+	TagSessions     Tag = "sessions"
 )
 
 var channels = struct {
@@ -64,6 +75,12 @@ var sys = system{
 	logs:        logs.NewLogs(),
 	users:       users.NewUsers(),
 	history:     history.NewHistory(),
+	profiles:    timeprofiles.NewTimeProfiles(),
+	tasks:       tasks.NewTasks(),
+	runs:        tasks.NewRuns(),
+	sessions:    sessions.NewSessions(),
+
+	notifications: notifications.NewNotifications(),
 
 	mode:      types.Normal,
 	withPIN:   false,
@@ -89,14 +106,23 @@ type system struct {
 	logs        logs.Logs
 	users       users.Users
 	history     history.History
+	profiles    timeprofiles.TimeProfiles
+	tasks       tasks.Tasks
+	runs        tasks.Runs
+	sessions    sessions.Sessions
+
+	notifications *notifications.Notifications
 
 	files     map[Tag]string
+	db        *sqlite.DB
+	backups   backups
 	rules     grule.Rules
 	taskQ     TaskQ
 	retention time.Duration // time after which 'deleted' items are permanently removed
 	trail     trail
 	mode      types.RunMode
 	withPIN   bool
+	noauth    bool // true if running without authentication i.e. auth 'none'
 	debug     bool
 
 	acl struct {
@@ -111,10 +137,10 @@ type trail struct {
 
 func (t trail) Write(records ...audit.AuditRecord) {
 	t.trail.Write(records...)
-	sys.logs.Received(records...)
+	added := sys.logs.Received(records...)
 	sys.history.Received(records...)
 
-	if err := save(TagLogs, &sys.logs); err != nil {
+	if err := appendRecords(TagLogs, &sys.logs, added); err != nil {
 		warnf("system", "%v", err)
 	}
 
@@ -134,22 +160,32 @@ type serializable interface {
 	Print()
 }
 
-func Init(cfg config.Config, conf string, mode types.RunMode, debug bool) error {
+func Init(cfg config.Config, s *settings.Settings, conf string, mode types.RunMode, debug bool) error {
 	catalog.Init(memdb.NewCatalog())
 
 	sys.mode = mode
 	sys.withPIN = cfg.HTTPD.PIN.Enabled
+	sys.noauth = cfg.HTTPD.Security.Auth == "none"
+
+	sys.files = files(cfg, s)
+	sys.backups = newBackups(cfg, s)
+
+	switch s.DB.Backend {
+	case "", settings.BackendJSON:
 
-	sys.files = map[Tag]string{
-		TagInterfaces:  cfg.HTTPD.System.Interfaces,
-		TagControllers: cfg.HTTPD.System.Controllers,
-		TagDoors:       cfg.HTTPD.System.Doors,
-		TagCards:       cfg.HTTPD.System.Cards,
-		TagGroups:      cfg.HTTPD.System.Groups,
-		TagEvents:      cfg.HTTPD.System.Events,
-		TagLogs:        cfg.HTTPD.System.Logs,
-		TagUsers:       cfg.HTTPD.System.Users,
-		TagHistory:     cfg.HTTPD.System.History,
+	case settings.BackendSQLite:
+		file := database(cfg, s)
+		db, err := sqlite.Open(file)
+		if err != nil {
+			log.Errorf("Unable to open SQLite database %v (%v)", file, err)
+			return err
+		}
+
+		sys.db = db
+		infof("system", "using SQLite database %v", file)
+
+	default:
+		return fmt.Errorf("unsupported database backend '%v'", s.DB.Backend)
 	}
 
 	list := subsystems()
@@ -160,6 +196,18 @@ func Init(cfg config.Config, conf string, mode types.RunMode, debug bool) error
 		}
 	}
 
+	auth.SetScopes(GetScope)
+	auth.SetCardGroups(GetCardGroups)
+
+	if file := s.Notifications.File; file != "" {
+		if err := sys.notifications.Load(file); err != nil {
+			log.Errorf("Unable to load notifications configuration from %v (%v)", file, err)
+			return err
+		}
+
+		infof("system", "loaded notifications configuration from %v", file)
+	}
+
 	kb := ast.NewKnowledgeLibrary()
 	if err := builder.NewRuleBuilder(kb).BuildRuleFromResource("acl", "0.0.0", pkg.NewFileResource(cfg.HTTPD.DB.Rules.ACL)); err != nil {
 		log.Fatalf("Error loading ACL ruleset (%v)", err)
@@ -193,15 +241,71 @@ func Init(cfg config.Config, conf string, mode types.RunMode, debug bool) error
 		}
 	}()
 
+	go sys.scheduler()
+
 	go func(ch <-chan types.EventsList) {
 		for v := range ch {
 			AppendEvents(v)
 		}
 	}(channels.events)
 
+	sys.interfaces.Listen()
+
 	return nil
 }
 
+// Returns the JSON files for the system subsystems. The time profiles, tasks, task runs and
+// sessions files default to the folder containing the groups file.
+func files(cfg config.Config, s *settings.Settings) map[Tag]string {
+	files := map[Tag]string{
+		TagInterfaces:   cfg.HTTPD.System.Interfaces,
+		TagControllers:  cfg.HTTPD.System.Controllers,
+		TagDoors:        cfg.HTTPD.System.Doors,
+		TagCards:        cfg.HTTPD.System.Cards,
+		TagGroups:       cfg.HTTPD.System.Groups,
+		TagEvents:       cfg.HTTPD.System.Events,
+		TagLogs:         cfg.HTTPD.System.Logs,
+		TagUsers:        cfg.HTTPD.System.Users,
+		TagHistory:      cfg.HTTPD.System.History,
+		TagTimeProfiles: s.System.TimeProfiles,
+		TagTasks:        s.System.Tasks,
+		TagTaskRuns:     s.System.TaskRuns,
+		TagSessions:     s.System.Sessions,
+	}
+
+	if files[TagTimeProfiles] == "" && cfg.HTTPD.System.Groups != "" {
+		files[TagTimeProfiles] = filepath.Join(filepath.Dir(cfg.HTTPD.System.Groups), "time-profiles.json")
+	}
+
+	if files[TagTasks] == "" && cfg.HTTPD.System.Groups != "" {
+		files[TagTasks] = filepath.Join(filepath.Dir(cfg.HTTPD.System.Groups), "tasks.json")
+	}
+
+	if files[TagTaskRuns] == "" && cfg.HTTPD.System.Groups != "" {
+		files[TagTaskRuns] = filepath.Join(filepath.Dir(cfg.HTTPD.System.Groups), "task-runs.json")
+	}
+
+	if files[TagSessions] == "" && cfg.HTTPD.System.Groups != "" {
+		files[TagSessions] = filepath.Join(filepath.Dir(cfg.HTTPD.System.Groups), "sessions.json")
+	}
+
+	return files
+}
+
+// Returns the SQLite database file if the database backend is SQLite and a blank string if the
+// system is stored in JSON files.
+func database(cfg config.Config, s *settings.Settings) string {
+	if s.DB.Backend != settings.BackendSQLite {
+		return ""
+	}
+
+	if s.DB.SQLite.File != "" {
+		return s.DB.SQLite.File
+	}
+
+	return filepath.Join(filepath.Dir(cfg.HTTPD.System.Events), "httpd.db")
+}
+
 func (s *system) refresh() {
 	if s == nil {
 		return
@@ -232,6 +336,10 @@ func (s *system) refresh() {
 		},
 	})
 
+	sys.taskQ.Add(Task{
+		f: s.alerts,
+	})
+
 	sys.taskQ.Add(Task{
 		f: func() {
 			s.interfaces.GetEvents(controllers, missing)
@@ -287,7 +395,7 @@ func (s *system) synchronize() {
 
 	if acl, err := s.permissions(controllers); err != nil {
 		warnf("system", "%v", err)
-	} else if diff, err := s.interfaces.CompareACL(controllers, acl, s.withPIN); err != nil {
+	} else if diff, profiles, err := s.interfaces.CompareACL(controllers, acl, s.profiles.AsTimeProfiles(), s.withPIN); err != nil {
 		warnf("system", "%v", err)
 	} else if diff == nil {
 		warnf("system", "Invalid ACL diff (%v)", diff)
@@ -299,6 +407,10 @@ func (s *system) synchronize() {
 			count += len(v.Deleted)
 		}
 
+		for _, v := range profiles {
+			count += len(v)
+		}
+
 		if count > 0 {
 			warnf("system", "ACL out of synch")
 			unsynchronized.ACL = true
@@ -405,6 +517,49 @@ func (s *system) Update(oid schema.OID, field schema.Suffix, value any) {
 
 		return
 
+	case oid.HasPrefix(schema.TimeProfilesOID):
+		if profile, ok := s.profiles.TimeProfile(oid); ok {
+			if p, ok := profile.AsTimeProfile(); ok {
+				for _, c := range controllers {
+					controller := c
+					go func() {
+						s.interfaces.PutTimeProfile(controller, p)
+					}()
+				}
+			}
+		}
+
+		groups := map[schema.OID]bool{}
+		for _, g := range s.groups.List() {
+			for _, p := range g.Profiles {
+				if p == oid {
+					groups[g.OID] = true
+				}
+			}
+		}
+
+		list := map[schema.OID]cards.Card{}
+		for _, c := range s.cards.List() {
+			card := c
+			for _, g := range c.Groups() {
+				if groups[g] {
+					list[c.OID] = card
+				}
+			}
+		}
+
+		for _, card := range list {
+			cardID := card.CardID
+			for _, c := range controllers {
+				controller := c
+				go func() {
+					s.updateCardPermissions(controller, cardID)
+				}()
+			}
+		}
+
+		return
+
 	case oid.HasPrefix(schema.ControllersOID) && field == schema.ControllerDateTime:
 		for _, c := range controllers {
 			if c.OID() == oid {
@@ -523,6 +678,8 @@ func (s *system) sweep() {
 	s.cards.Sweep(s.retention)
 	s.groups.Sweep(s.retention)
 	s.users.Sweep(s.retention)
+	s.profiles.Sweep(s.retention)
+	s.tasks.Sweep(s.retention)
 }
 
 func subsystems() []struct {
@@ -537,11 +694,15 @@ func subsystems() []struct {
 		{&sys.controllers, TagControllers},
 		{&sys.doors, TagDoors},
 		{&sys.cards, TagCards},
+		{&sys.profiles, TagTimeProfiles},
 		{&sys.groups, TagGroups},
 		{&sys.events, TagEvents},
 		{&sys.logs, TagLogs},
 		{&sys.users, TagUsers},
 		{&sys.history, TagHistory},
+		{&sys.tasks, TagTasks},
+		{&sys.runs, TagTaskRuns},
+		{&sys.sessions, TagSessions},
 	}
 }
 
@@ -571,6 +732,31 @@ func unpack(m map[string]any) ([]object, []object, []schema.OID, error) {
 }
 
 func load(tag Tag, v serializable) error {
+	if sys.db != nil {
+		if blob, ok, err := sys.db.Load(string(tag)); err != nil {
+			return err
+		} else if ok {
+			return v.Load(blob)
+		}
+
+		// ... one-shot migration from the JSON file
+		if err := loadJSON(tag, v); err != nil {
+			return err
+		}
+
+		if err := save(tag, v); err != nil {
+			return err
+		}
+
+		infof("system", "migrated %v from %v to SQLite database", tag, sys.files[tag])
+
+		return nil
+	}
+
+	return loadJSON(tag, v)
+}
+
+func loadJSON(tag Tag, v serializable) error {
 	if file, ok := sys.files[tag]; !ok || file == "" {
 		return nil
 	} else {
@@ -593,7 +779,28 @@ func load(tag Tag, v serializable) error {
 	}
 }
 
+// Saves the records appended to a subsystem (e.g. received events or audit log entries). The
+// SQLite store only inserts the appended records whereas the JSON store has to rewrite the whole
+// file.
+func appendRecords(tag Tag, v serializable, records []json.RawMessage) error {
+	if len(records) == 0 {
+		return nil
+	} else if sys.db != nil {
+		return sys.db.Append(string(tag), records)
+	}
+
+	return save(tag, v)
+}
+
 func save(tag Tag, v serializable) error {
+	if sys.db != nil {
+		if bytes, err := v.Save(); err != nil {
+			return err
+		} else {
+			return sys.db.Save(string(tag), bytes)
+		}
+	}
+
 	var file string
 	var ok bool
 
@@ -606,11 +813,7 @@ func save(tag Tag, v serializable) error {
 		return err
 	}
 
-	blob := map[Tag]json.RawMessage{
-		tag: bytes,
-	}
-
-	b, err := json.MarshalIndent(blob, "", "  ")
+	b, err := encode(tag, bytes)
 	if err != nil {
 		return err
 	}
@@ -641,6 +844,12 @@ func save(tag Tag, v serializable) error {
 	return libos.Rename(tmp.Name(), file)
 }
 
+// Encodes a subsystem blob in the JSON file format i.e. as an object with the subsystem tag as
+// the only field.
+func encode(tag Tag, blob json.RawMessage) ([]byte, error) {
+	return json.MarshalIndent(map[Tag]json.RawMessage{tag: blob}, "", "  ")
+}
+
 func infof(tag string, format string, args ...any) {
 	if tag == "" {
 		log.Infof("%v", args...)
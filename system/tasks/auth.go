@@ -0,0 +1,43 @@
+package tasks
+
+import (
+	"github.com/uhppoted/uhppoted-httpd/auth"
+)
+
+type TAuthable interface {
+	Task | *Task | Run
+	AsRuleEntity() (string, any)
+	CacheKey() string
+}
+
+var rulesets = []auth.RuleSet{auth.Tasks}
+
+// A scoped administrator can view but cannot add, update or delete tasks, which run
+// door, controller and event actions across the whole system.
+func CanView[T TAuthable](a auth.OpAuth, u T, field string, value any) error {
+	return auth.CanView(a, u, field, value, rulesets...)
+}
+
+func CanAdd[T TAuthable](a auth.OpAuth, u T) error {
+	if auth.ScopeOf(a).IsScoped() {
+		return auth.ErrUnauthorised
+	}
+
+	return auth.CanAdd(a, u, rulesets...)
+}
+
+func CanUpdate[T TAuthable](a auth.OpAuth, u T, field string, value any) error {
+	if auth.ScopeOf(a).IsScoped() {
+		return auth.ErrUnauthorised
+	}
+
+	return auth.CanUpdate(a, u, field, value, rulesets...)
+}
+
+func CanDelete[T TAuthable](a auth.OpAuth, u T) error {
+	if auth.ScopeOf(a).IsScoped() {
+		return auth.ErrUnauthorised
+	}
+
+	return auth.CanDelete(a, u, rulesets...)
+}
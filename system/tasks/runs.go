@@ -0,0 +1,150 @@
+package tasks
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+	"github.com/uhppoted/uhppoted-httpd/types"
+)
+
+// Run is the run history record for a single run of a scheduled task.
+type Run struct {
+	Task     schema.OID      `json:"task"`
+	Name     string          `json:"name"`
+	Action   string          `json:"action"`
+	Started  types.Timestamp `json:"started"`
+	Finished types.Timestamp `json:"finished"`
+	Result   string          `json:"result"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// Runs is the (capped) run history for all scheduled tasks, in the order in which the tasks
+// were run.
+type Runs struct {
+	runs []Run
+	sync.RWMutex
+}
+
+// Maximum number of task runs retained in the run history.
+const MaxRuns = 1000
+
+const (
+	ResultOk     = "ok"
+	ResultFailed = "failed"
+)
+
+func NewRuns() Runs {
+	return Runs{
+		runs: []Run{},
+	}
+}
+
+func NewRun(task Task, started, finished types.Timestamp, err error) Run {
+	run := Run{
+		Task:     task.OID,
+		Name:     task.Name,
+		Action:   task.Action.String(),
+		Started:  started,
+		Finished: finished,
+		Result:   ResultOk,
+	}
+
+	if err != nil {
+		run.Result = ResultFailed
+		run.Error = fmt.Sprintf("%v", err)
+	}
+
+	return run
+}
+
+func (r Run) AsRuleEntity() (string, any) {
+	entity := struct {
+		Name   string
+		Action string
+	}{
+		Name:   r.Name,
+		Action: r.Action,
+	}
+
+	return "task", &entity
+}
+
+func (r Run) CacheKey() string {
+	return ""
+}
+
+// Appends a task run to the run history, discarding the oldest runs if the history exceeds
+// MaxRuns.
+func (rr *Runs) Add(run Run) {
+	rr.Lock()
+	defer rr.Unlock()
+
+	rr.runs = append(rr.runs, run)
+
+	if N := len(rr.runs); N > MaxRuns {
+		rr.runs = rr.runs[N-MaxRuns:]
+	}
+}
+
+// Returns the most recent runs (latest first) for a task, or for all tasks if 'task' is blank.
+// A 'count' of 0 returns all the matching runs.
+func (rr *Runs) Query(a *auth.Authorizator, task schema.OID, count int) []Run {
+	rr.RLock()
+	defer rr.RUnlock()
+
+	list := []Run{}
+
+	for i := len(rr.runs) - 1; i >= 0; i-- {
+		run := rr.runs[i]
+
+		if task != "" && run.Task != task {
+			continue
+		}
+
+		if err := CanView(a, run, "task.runs", run.Task); err != nil {
+			continue
+		}
+
+		list = append(list, run)
+
+		if count > 0 && len(list) >= count {
+			break
+		}
+	}
+
+	return list
+}
+
+func (rr *Runs) Load(blob json.RawMessage) error {
+	rr.Lock()
+	defer rr.Unlock()
+
+	runs := []Run{}
+	if err := json.Unmarshal(blob, &runs); err != nil {
+		return err
+	}
+
+	if N := len(runs); N > MaxRuns {
+		runs = runs[N-MaxRuns:]
+	}
+
+	rr.runs = runs
+
+	return nil
+}
+
+func (rr *Runs) Save() (json.RawMessage, error) {
+	rr.RLock()
+	defer rr.RUnlock()
+
+	return json.MarshalIndent(rr.runs, "", "  ")
+}
+
+func (rr *Runs) Print() {
+	if b, err := rr.Save(); err == nil {
+		fmt.Printf("----------------- TASK RUNS\n%s\n", string(b))
+	}
+}
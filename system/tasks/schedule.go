@@ -0,0 +1,205 @@
+package tasks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schedule is a parsed cron-like task schedule with the standard five fields i.e.
+//
+//	minute hour day-of-month month day-of-week
+//
+// Each field is a comma separated list of values, ranges (e.g. 1-5) and steps (e.g. */15 or
+// 8-18/2). Months and weekdays may also be specified by their three letter abbreviations. As
+// with cron, if both the day of the month and the day of the week are restricted then a time
+// matches if either field matches.
+type schedule struct {
+	minutes  []bool
+	hours    []bool
+	days     []bool
+	months   []bool
+	weekdays []bool
+
+	anyDay     bool
+	anyWeekday bool
+}
+
+type field struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var shortcuts = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * sun",
+	"@monthly":  "0 0 1 * *",
+}
+
+var fields = struct {
+	minute  field
+	hour    field
+	day     field
+	month   field
+	weekday field
+}{
+	minute: field{"minute", 0, 59, nil},
+	hour:   field{"hour", 0, 23, nil},
+	day:    field{"day of month", 1, 31, nil},
+	month: field{"month", 1, 12, map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}},
+	weekday: field{"day of week", 0, 7, map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}},
+}
+
+func parseSchedule(spec string) (*schedule, error) {
+	s := strings.ToLower(strings.TrimSpace(spec))
+	if v, ok := shortcuts[s]; ok {
+		s = v
+	}
+
+	tokens := strings.Fields(s)
+	if len(tokens) != 5 {
+		return nil, fmt.Errorf("invalid schedule '%v' - expected 'minute hour day-of-month month day-of-week'", spec)
+	}
+
+	minutes, err := fields.minute.parse(tokens[0])
+	if err != nil {
+		return nil, err
+	}
+
+	hours, err := fields.hour.parse(tokens[1])
+	if err != nil {
+		return nil, err
+	}
+
+	days, err := fields.day.parse(tokens[2])
+	if err != nil {
+		return nil, err
+	}
+
+	months, err := fields.month.parse(tokens[3])
+	if err != nil {
+		return nil, err
+	}
+
+	weekdays, err := fields.weekday.parse(tokens[4])
+	if err != nil {
+		return nil, err
+	}
+
+	// ... 7 is an alias for Sunday
+	weekdays[0] = weekdays[0] || weekdays[7]
+
+	return &schedule{
+		minutes:    minutes,
+		hours:      hours,
+		days:       days,
+		months:     months,
+		weekdays:   weekdays[0:7],
+		anyDay:     strings.HasPrefix(tokens[2], "*"),
+		anyWeekday: strings.HasPrefix(tokens[4], "*"),
+	}, nil
+}
+
+// Returns true if the schedule includes the minute containing t.
+func (s schedule) matches(t time.Time) bool {
+	if !s.minutes[t.Minute()] || !s.hours[t.Hour()] || !s.months[int(t.Month())] {
+		return false
+	}
+
+	day := s.days[t.Day()]
+	weekday := s.weekdays[int(t.Weekday())]
+
+	switch {
+	case s.anyDay && s.anyWeekday:
+		return true
+
+	case s.anyDay:
+		return weekday
+
+	case s.anyWeekday:
+		return day
+
+	default:
+		return day || weekday
+	}
+}
+
+func (f field) parse(s string) ([]bool, error) {
+	list := make([]bool, f.max+1)
+
+	for _, item := range strings.Split(s, ",") {
+		from := f.min
+		to := f.max
+		step := 1
+
+		expr := item
+		if ix := strings.Index(item, "/"); ix != -1 {
+			if v, err := strconv.Atoi(item[ix+1:]); err != nil || v < 1 {
+				return nil, fmt.Errorf("invalid %v step '%v'", f.name, item)
+			} else {
+				step = v
+				expr = item[:ix]
+			}
+		}
+
+		switch {
+		case expr == "*":
+
+		case strings.Contains(expr, "-"):
+			tokens := strings.SplitN(expr, "-", 2)
+			if v, err := f.value(tokens[0]); err != nil {
+				return nil, err
+			} else {
+				from = v
+			}
+
+			if v, err := f.value(tokens[1]); err != nil {
+				return nil, err
+			} else {
+				to = v
+			}
+
+			if to < from {
+				return nil, fmt.Errorf("invalid %v range '%v'", f.name, item)
+			}
+
+		default:
+			if v, err := f.value(expr); err != nil {
+				return nil, err
+			} else if step > 1 {
+				from = v
+			} else {
+				from = v
+				to = v
+			}
+		}
+
+		for v := from; v <= to; v += step {
+			list[v] = true
+		}
+	}
+
+	return list, nil
+}
+
+func (f field) value(s string) (int, error) {
+	if v, ok := f.names[s]; ok {
+		return v, nil
+	}
+
+	if v, err := strconv.Atoi(s); err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %v '%v' - valid range is [%v..%v]", f.name, s, f.min, f.max)
+	} else {
+		return v, nil
+	}
+}
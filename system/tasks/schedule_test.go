@@ -0,0 +1,61 @@
+package tasks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		spec     string
+		time     time.Time
+		expected bool
+	}{
+		{"0 8 * * mon-fri", time.Date(2026, time.October, 19, 8, 0, 0, 0, time.Local), true},
+		{"0 8 * * mon-fri", time.Date(2026, time.October, 18, 8, 0, 0, 0, time.Local), false},
+		{"0 8 * * mon-fri", time.Date(2026, time.October, 19, 8, 1, 0, 0, time.Local), false},
+		{"30 17 * * 1-5", time.Date(2026, time.October, 23, 17, 30, 15, 0, time.Local), true},
+		{"*/15 * * * *", time.Date(2026, time.October, 23, 17, 45, 0, 0, time.Local), true},
+		{"*/15 * * * *", time.Date(2026, time.October, 23, 17, 46, 0, 0, time.Local), false},
+		{"0 3 * * *", time.Date(2026, time.October, 23, 3, 0, 0, 0, time.Local), true},
+		{"0 0 1 jan,jul *", time.Date(2026, time.July, 1, 0, 0, 0, 0, time.Local), true},
+		{"0 0 1 jan,jul *", time.Date(2026, time.June, 1, 0, 0, 0, 0, time.Local), false},
+		{"0 0 1 * mon", time.Date(2026, time.October, 19, 0, 0, 0, 0, time.Local), true},
+		{"0 0 1 * mon", time.Date(2026, time.October, 1, 0, 0, 0, 0, time.Local), true},
+		{"0 0 1 * mon", time.Date(2026, time.October, 2, 0, 0, 0, 0, time.Local), false},
+		{"0 12 * * 7", time.Date(2026, time.October, 18, 12, 0, 0, 0, time.Local), true},
+		{"@daily", time.Date(2026, time.October, 18, 0, 0, 0, 0, time.Local), true},
+		{"@hourly", time.Date(2026, time.October, 18, 13, 0, 0, 0, time.Local), true},
+		{"@weekly", time.Date(2026, time.October, 19, 0, 0, 0, 0, time.Local), false},
+	}
+
+	for _, test := range tests {
+		if s, err := parseSchedule(test.spec); err != nil {
+			t.Errorf("Error parsing schedule '%v' (%v)", test.spec, err)
+		} else if matched := s.matches(test.time); matched != test.expected {
+			t.Errorf("Incorrect match for schedule '%v' at %v - expected:%v, got:%v", test.spec, test.time, test.expected, matched)
+		}
+	}
+}
+
+func TestParseInvalidSchedule(t *testing.T) {
+	tests := []string{
+		"",
+		"0 8 * *",
+		"0 8 * * * *",
+		"60 8 * * *",
+		"0 24 * * *",
+		"0 8 0 * *",
+		"0 8 * 13 *",
+		"0 8 * * 8",
+		"0 8 * * fri-mon",
+		"*/0 * * * *",
+		"0 8 * * funday",
+	}
+
+	for _, spec := range tests {
+		if _, err := parseSchedule(spec); err == nil {
+			t.Errorf("Expected error parsing schedule '%v'", spec)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package tasks
+
+import (
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+)
+
+const TaskStatus = schema.Status
+const TaskCreated = schema.Created
+const TaskDeleted = schema.Deleted
+const TaskModified = schema.Modified
+const TaskName = schema.TaskName
+const TaskSchedule = schema.TaskSchedule
+const TaskAction = schema.TaskAction
+const TaskDoor = schema.TaskDoor
+const TaskDays = schema.TaskDays
+const TaskEnabled = schema.TaskEnabled
+const TaskLastRun = schema.TaskLastRun
+const TaskResult = schema.TaskResult
+
+var lookup = map[schema.Suffix]string{
+	TaskStatus:   "task.status",
+	TaskCreated:  "task.created",
+	TaskDeleted:  "task.deleted",
+	TaskModified: "task.modified",
+	TaskName:     "task.name",
+	TaskSchedule: "task.schedule",
+	TaskAction:   "task.action",
+	TaskDoor:     "task.door",
+	TaskDays:     "task.days",
+	TaskEnabled:  "task.enabled",
+	TaskLastRun:  "task.last-run",
+	TaskResult:   "task.result",
+}
@@ -0,0 +1,453 @@
+package tasks
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+	"github.com/uhppoted/uhppoted-httpd/system/db"
+	"github.com/uhppoted/uhppoted-httpd/types"
+)
+
+type Task struct {
+	catalog.CatalogTask
+	Name     string     `json:"name"`
+	Schedule string     `json:"schedule"`
+	Action   Action     `json:"action"`
+	Door     schema.OID `json:"door"`
+	Days     uint16     `json:"days"`
+	Enabled  bool       `json:"enabled"`
+
+	owner    string // user that created or last modified the task
+	lastRun  types.Timestamp
+	result   string
+	created  types.Timestamp
+	modified types.Timestamp
+	deleted  types.Timestamp
+}
+
+type Action int
+
+const (
+	NoAction Action = iota
+	UnlockDoor
+	LockDoor
+	SynchronizeDateTime
+	SynchronizeACL
+	SynchronizeDoors
+	PurgeEvents
+	Backup
+)
+
+func (a Action) String() string {
+	return [...]string{
+		"",
+		"unlock door",
+		"lock door",
+		"synchronize date/time",
+		"synchronize ACL",
+		"synchronize doors",
+		"purge events",
+		"backup",
+	}[a]
+}
+
+type kv = struct {
+	field schema.Suffix
+	value any
+}
+
+var created = types.TimestampNow()
+
+func (t Task) String() string {
+	return fmt.Sprintf("%v", t.Name)
+}
+
+// Returns the UID of the user that created or last modified the task. Scheduled runs are
+// authorised as this user.
+func (t Task) Owner() string {
+	return t.owner
+}
+
+func (t Task) IsValid() bool {
+	return t.validate() == nil
+}
+
+// Checks the task name and schedule. A task that is incomplete (i.e. has no action or a door
+// action without a door) is valid but not runnable.
+func (t Task) validate() error {
+	if strings.TrimSpace(t.Name) == "" {
+		return fmt.Errorf("Task name is blank")
+	}
+
+	if strings.TrimSpace(t.Schedule) != "" {
+		if _, err := parseSchedule(t.Schedule); err != nil {
+			return fmt.Errorf("Task %v: %v", t.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (t Task) IsDeleted() bool {
+	return !t.deleted.IsZero()
+}
+
+// Returns true if the task is enabled, valid and complete.
+func (t Task) IsRunnable() bool {
+	if !t.Enabled || t.IsDeleted() || !t.IsValid() || strings.TrimSpace(t.Schedule) == "" {
+		return false
+	}
+
+	switch t.Action {
+	case UnlockDoor, LockDoor:
+		return t.Door != ""
+
+	case SynchronizeDateTime, SynchronizeACL, SynchronizeDoors, Backup:
+		return true
+
+	case PurgeEvents:
+		return t.Days > 0
+
+	default:
+		return false
+	}
+}
+
+// Returns true if the task is runnable and scheduled to run in the minute containing 'now'.
+func (t Task) IsDue(now time.Time) bool {
+	if t.IsRunnable() {
+		if s, err := parseSchedule(t.Schedule); err == nil {
+			return s.matches(now)
+		}
+	}
+
+	return false
+}
+
+func (t *Task) AsObjects(a *auth.Authorizator) []schema.Object {
+	list := []kv{}
+
+	if t.IsDeleted() {
+		list = append(list, kv{TaskDeleted, t.deleted})
+	} else {
+		list = append(list, kv{TaskStatus, t.Status()})
+		list = append(list, kv{TaskCreated, t.created})
+		list = append(list, kv{TaskDeleted, t.deleted})
+		list = append(list, kv{TaskName, t.Name})
+		list = append(list, kv{TaskSchedule, t.Schedule})
+		list = append(list, kv{TaskAction, t.Action.String()})
+		list = append(list, kv{TaskDoor, t.Door})
+		list = append(list, kv{TaskDays, t.days()})
+		list = append(list, kv{TaskEnabled, t.Enabled})
+		list = append(list, kv{TaskLastRun, t.lastRun})
+		list = append(list, kv{TaskResult, t.result})
+	}
+
+	return t.toObjects(list, a)
+}
+
+func (t Task) AsRuleEntity() (string, any) {
+	entity := struct {
+		Name   string
+		Action string
+	}{
+		Name:   fmt.Sprintf("%v", t.Name),
+		Action: fmt.Sprintf("%v", t.Action),
+	}
+
+	return "task", &entity
+}
+
+func (t Task) CacheKey() string {
+	return ""
+}
+
+func (t Task) Status() types.Status {
+	if t.IsDeleted() {
+		return types.StatusDeleted
+	}
+
+	return types.StatusOk
+}
+
+func (t *Task) set(a *auth.Authorizator, oid schema.OID, value string, dbc db.DBC) ([]schema.Object, error) {
+	if t == nil {
+		return []schema.Object{}, nil
+	}
+
+	if t.IsDeleted() {
+		return t.toObjects([]kv{{TaskDeleted, t.deleted}}, a), fmt.Errorf("Task has been deleted")
+	}
+
+	uid := auth.UID(a)
+	original := t.clone()
+	list := []kv{}
+
+	switch oid {
+	case t.OID.Append(TaskName):
+		if err := CanUpdate(a, t, "name", value); err != nil {
+			return nil, err
+		} else {
+			t.Name = value
+			t.modified = types.TimestampNow()
+
+			list = append(list, kv{TaskName, t.Name})
+
+			t.log(dbc, uid, "update", "name", original.Name, value, "Updated name from %v to %v", original.Name, t.Name)
+		}
+
+	case t.OID.Append(TaskSchedule):
+		spec := strings.Join(strings.Fields(value), " ")
+
+		if err := CanUpdate(a, t, "schedule", spec); err != nil {
+			return nil, err
+		} else if _, err := parseSchedule(spec); err != nil && spec != "" {
+			return nil, err
+		} else {
+			t.Schedule = spec
+			t.modified = types.TimestampNow()
+
+			list = append(list, kv{TaskSchedule, t.Schedule})
+
+			t.log(dbc, uid, "update", "schedule", original.Schedule, t.Schedule, "Updated schedule from '%v' to '%v'", original.Schedule, t.Schedule)
+		}
+
+	case t.OID.Append(TaskAction):
+		if err := CanUpdate(a, t, "action", value); err != nil {
+			return nil, err
+		} else if action, err := parseAction(value); err != nil {
+			return nil, err
+		} else {
+			t.Action = action
+			t.modified = types.TimestampNow()
+
+			list = append(list, kv{TaskAction, t.Action.String()})
+
+			t.log(dbc, uid, "update", "action", original.Action, t.Action, "Updated action from '%v' to '%v'", original.Action, t.Action)
+		}
+
+	case t.OID.Append(TaskDoor):
+		door := schema.OID(strings.TrimSpace(value))
+
+		if err := CanUpdate(a, t, "door", door); err != nil {
+			return nil, err
+		} else if door != "" && !slices.Contains(catalog.GetDoors(), door) {
+			return nil, fmt.Errorf("invalid door (%v)", value)
+		} else {
+			t.Door = door
+			t.modified = types.TimestampNow()
+
+			list = append(list, kv{TaskDoor, t.Door})
+
+			t.log(dbc, uid, "update", "door", original.Door, t.Door, "Updated door from %v to %v", original.Door, t.Door)
+		}
+
+	case t.OID.Append(TaskDays):
+		if err := CanUpdate(a, t, "days", value); err != nil {
+			return nil, err
+		} else if strings.TrimSpace(value) == "" {
+			t.Days = 0
+		} else if v, err := strconv.ParseUint(strings.TrimSpace(value), 10, 16); err != nil {
+			return nil, fmt.Errorf("invalid number of days (%v)", value)
+		} else {
+			t.Days = uint16(v)
+		}
+
+		t.modified = types.TimestampNow()
+
+		list = append(list, kv{TaskDays, t.days()})
+
+		t.log(dbc, uid, "update", "days", original.Days, t.Days, "Updated days from %v to %v", original.Days, t.Days)
+
+	case t.OID.Append(TaskEnabled):
+		if err := CanUpdate(a, t, "enabled", value); err != nil {
+			return nil, err
+		} else {
+			t.Enabled = value == "true"
+			t.modified = types.TimestampNow()
+
+			list = append(list, kv{TaskEnabled, t.Enabled})
+
+			if t.Enabled {
+				t.log(dbc, uid, "update", "enabled", original.Enabled, t.Enabled, "Enabled task %v", t.Name)
+			} else {
+				t.log(dbc, uid, "update", "enabled", original.Enabled, t.Enabled, "Disabled task %v", t.Name)
+			}
+		}
+	}
+
+	if len(list) > 0 {
+		t.owner = uid
+	}
+
+	list = append(list, kv{TaskStatus, t.Status()})
+
+	return t.toObjects(list, a), nil
+}
+
+func (t *Task) delete(a *auth.Authorizator, dbc db.DBC) ([]schema.Object, error) {
+	list := []kv{}
+
+	if t != nil {
+		if err := CanDelete(a, t); err != nil {
+			return nil, err
+		}
+
+		t.log(dbc, auth.UID(a), "delete", "task", t.Name, "", "Deleted task %v", t.Name)
+
+		t.deleted = types.TimestampNow()
+		t.modified = types.TimestampNow()
+
+		list = append(list, kv{TaskStatus, t.Status()})
+		list = append(list, kv{TaskDeleted, t.deleted})
+
+		catalog.DeleteT(t.CatalogTask, t.OID)
+	}
+
+	return t.toObjects(list, a), nil
+}
+
+func (t Task) toObjects(list []kv, a *auth.Authorizator) []schema.Object {
+	objects := []schema.Object{}
+
+	if err := CanView(a, t, "OID", t.OID); err == nil && !t.IsDeleted() {
+		catalog.Join(&objects, catalog.NewObject(t.OID, ""))
+	}
+
+	for _, v := range list {
+		field := lookup[v.field]
+		if err := CanView(a, t, field, v.value); err == nil {
+			catalog.Join(&objects, catalog.NewObject2(t.OID, v.field, v.value))
+		}
+	}
+
+	return objects
+}
+
+func (t Task) days() string {
+	if t.Days == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%v", t.Days)
+}
+
+func (t Task) serialize() ([]byte, error) {
+	record := struct {
+		OID      schema.OID      `json:"OID"`
+		Name     string          `json:"name,omitempty"`
+		Schedule string          `json:"schedule,omitempty"`
+		Action   string          `json:"action,omitempty"`
+		Door     schema.OID      `json:"door,omitempty"`
+		Days     uint16          `json:"days,omitempty"`
+		Enabled  bool            `json:"enabled"`
+		Owner    string          `json:"owner,omitempty"`
+		LastRun  types.Timestamp `json:"last-run"`
+		Result   string          `json:"result,omitempty"`
+		Created  types.Timestamp `json:"created"`
+		Modified types.Timestamp `json:"modified"`
+	}{
+		OID:      t.OID,
+		Name:     t.Name,
+		Schedule: t.Schedule,
+		Action:   t.Action.String(),
+		Door:     t.Door,
+		Days:     t.Days,
+		Enabled:  t.Enabled,
+		Owner:    t.owner,
+		LastRun:  t.lastRun.UTC(),
+		Result:   t.result,
+		Created:  t.created.UTC(),
+		Modified: t.modified.UTC(),
+	}
+
+	return json.Marshal(record)
+}
+
+func (t *Task) deserialize(bytes []byte) error {
+	created = created.Add(1 * time.Minute)
+
+	record := struct {
+		OID      schema.OID      `json:"OID"`
+		Name     string          `json:"name,omitempty"`
+		Schedule string          `json:"schedule,omitempty"`
+		Action   string          `json:"action,omitempty"`
+		Door     schema.OID      `json:"door,omitempty"`
+		Days     uint16          `json:"days,omitempty"`
+		Enabled  bool            `json:"enabled"`
+		Owner    string          `json:"owner,omitempty"`
+		LastRun  types.Timestamp `json:"last-run"`
+		Result   string          `json:"result,omitempty"`
+		Created  types.Timestamp `json:"created"`
+		Modified types.Timestamp `json:"modified"`
+	}{
+		Created: created,
+	}
+
+	if err := json.Unmarshal(bytes, &record); err != nil {
+		return err
+	}
+
+	action, err := parseAction(record.Action)
+	if err != nil {
+		return err
+	}
+
+	t.OID = record.OID
+	t.Name = record.Name
+	t.Schedule = record.Schedule
+	t.Action = action
+	t.Door = record.Door
+	t.Days = record.Days
+	t.Enabled = record.Enabled
+	t.owner = record.Owner
+	t.lastRun = record.LastRun
+	t.result = record.Result
+	t.created = record.Created
+	t.modified = record.Modified
+
+	return nil
+}
+
+func (t Task) clone() Task {
+	return Task{
+		CatalogTask: catalog.CatalogTask{
+			OID: t.OID,
+		},
+		Name:     t.Name,
+		Schedule: t.Schedule,
+		Action:   t.Action,
+		Door:     t.Door,
+		Days:     t.Days,
+		Enabled:  t.Enabled,
+		owner:    t.owner,
+		lastRun:  t.lastRun,
+		result:   t.result,
+		created:  t.created,
+		modified: t.modified,
+		deleted:  t.deleted,
+	}
+}
+
+func (t *Task) log(dbc db.DBC, uid, op string, field string, before, after any, format string, fields ...any) {
+	dbc.Log(uid, op, t.OID, "task", "", t.Name, field, before, after, format, fields...)
+}
+
+func parseAction(s string) (Action, error) {
+	v := strings.ToLower(strings.TrimSpace(s))
+
+	for _, a := range []Action{NoAction, UnlockDoor, LockDoor, SynchronizeDateTime, SynchronizeACL, SynchronizeDoors, PurgeEvents, Backup} {
+		if strings.ToLower(a.String()) == v {
+			return a, nil
+		}
+	}
+
+	return NoAction, fmt.Errorf("invalid task action '%v'", s)
+}
@@ -0,0 +1,255 @@
+package tasks
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog"
+	memdb "github.com/uhppoted/uhppoted-httpd/system/catalog/impl"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+	"github.com/uhppoted/uhppoted-httpd/system/db"
+	"github.com/uhppoted/uhppoted-httpd/types"
+)
+
+func TestTaskSerialize(t *testing.T) {
+	created = types.Timestamp(time.Date(2022, time.April, 1, 0, 0, 0, 0, time.UTC))
+
+	task := unlock()
+	task.created = created
+
+	expected := `{"OID":"0.10.1","name":"Unlock front door","schedule":"0 8 * * mon-fri","action":"unlock door","door":"0.3.1","enabled":true,"last-run":"","created":"2022-04-01 00:00:00 UTC","modified":""}`
+
+	if bytes, err := task.serialize(); err != nil {
+		t.Fatalf("Error serializing task (%v)", err)
+	} else if string(bytes) != expected {
+		t.Errorf("Task incorrectly serialized\n   expected:%v\n   got:     %v", expected, string(bytes))
+	}
+}
+
+func TestTaskDeserialize(t *testing.T) {
+	created = types.Timestamp(time.Date(2022, time.April, 1, 0, 0, 0, 0, time.Local))
+
+	encoded := `{ "OID":"0.10.1", "name":"Unlock front door", "schedule":"0 8 * * mon-fri", "action":"unlock door", "door":"0.3.1", "enabled":true, "created":"2022-04-01 00:00:00" }`
+
+	expected := unlock()
+	expected.created = created
+
+	var task Task
+	if err := task.deserialize([]byte(encoded)); err != nil {
+		t.Fatalf("Error deserializing task (%v)", err)
+	}
+
+	if !reflect.DeepEqual(task, expected) {
+		t.Errorf("Task incorrectly deserialized\n   expected:%#v\n   got:     %#v", expected, task)
+	}
+}
+
+func TestTaskAsObjectsWithAuth(t *testing.T) {
+	created = types.Timestamp(time.Date(2021, time.February, 28, 12, 34, 56, 0, time.Local))
+
+	task := unlock()
+	task.created = created
+
+	expected := []schema.Object{
+		{OID: "0.10.1", Value: ""},
+		{OID: "0.10.1.0.0", Value: types.StatusOk},
+		{OID: "0.10.1.0.1", Value: created},
+		{OID: "0.10.1.0.2", Value: types.Timestamp{}},
+		{OID: "0.10.1.1", Value: "Unlock front door"},
+		{OID: "0.10.1.2", Value: "0 8 * * mon-fri"},
+		{OID: "0.10.1.3", Value: "unlock door"},
+		{OID: "0.10.1.4", Value: schema.OID("0.3.1")},
+		{OID: "0.10.1.5", Value: ""},
+		{OID: "0.10.1.6", Value: true},
+		{OID: "0.10.1.7", Value: types.Timestamp{}},
+		// {OID: "0.10.1.8", Value: ""},
+	}
+
+	a := auth.Authorizator{
+		OpAuth: &stub{
+			canView: func(ruleset auth.RuleSet, object auth.Operant, field string, value any) error {
+				if strings.HasPrefix(field, "task.result") {
+					return errors.New("test")
+				}
+
+				return nil
+			},
+		},
+	}
+
+	objects := task.AsObjects(&a)
+
+	if !reflect.DeepEqual(objects, expected) {
+		t.Errorf("Incorrect return from AsObjects:\n   expected:%#v\n   got:     %#v", expected, objects)
+	}
+}
+
+func TestTaskSet(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+	catalog.PutT(catalog.CatalogDoor{OID: "0.3.5"})
+
+	tests := []struct {
+		suffix   schema.Suffix
+		value    string
+		expected any
+	}{
+		{TaskName, "Relock front door", "Relock front door"},
+		{TaskSchedule, " 30  17 * *  1-5 ", "30 17 * * 1-5"},
+		{TaskSchedule, "", ""},
+		{TaskAction, "Synchronize ACL", "synchronize ACL"},
+		{TaskAction, "Backup", "backup"},
+		{TaskAction, "", ""},
+		{TaskDoor, "0.3.5", schema.OID("0.3.5")},
+		{TaskDays, "90", "90"},
+		{TaskDays, "", ""},
+		{TaskEnabled, "false", false},
+	}
+
+	for _, test := range tests {
+		task := unlock()
+
+		expected := []schema.Object{
+			{OID: "0.10.1", Value: ""},
+			{OID: schema.OID("0.10.1").Append(test.suffix), Value: test.expected},
+			{OID: "0.10.1.0.0", Value: types.StatusOk},
+		}
+
+		objects, err := task.set(nil, schema.OID("0.10.1").Append(test.suffix), test.value, db.DBC{})
+		if err != nil {
+			t.Errorf("Unexpected error updating %v (%v)", test.suffix, err)
+		} else if !reflect.DeepEqual(objects, expected) {
+			t.Errorf("Invalid result updating %v\n   expected:%#v\n   got:     %#v", test.suffix, expected, objects)
+		}
+	}
+}
+
+func TestTaskOwner(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+	catalog.PutT(catalog.CatalogDoor{OID: "0.3.5"})
+
+	tt := NewTasks()
+	objects, err := tt.Create(auth.NewSystemAuthorizator("admin"), "<new>", "", db.DBC{})
+	if err != nil || len(objects) == 0 {
+		t.Fatalf("Error creating task (%v)", err)
+	}
+
+	oid := objects[0].OID
+	if task := tt.tasks[oid]; task.Owner() != "admin" {
+		t.Errorf("Incorrect task owner - expected:%v, got:%v", "admin", task.Owner())
+	}
+
+	if _, err := tt.Update(auth.NewSystemAuthorizator("moony"), oid.Append(TaskDoor), "0.3.5", db.DBC{}); err != nil {
+		t.Fatalf("Error updating task (%v)", err)
+	}
+
+	task := tt.tasks[oid]
+	if task.Owner() != "moony" {
+		t.Errorf("Incorrect task owner - expected:%v, got:%v", "moony", task.Owner())
+	}
+
+	var restored Task
+	if bytes, err := task.serialize(); err != nil {
+		t.Fatalf("Error serializing task (%v)", err)
+	} else if err := restored.deserialize(bytes); err != nil {
+		t.Fatalf("Error deserializing task (%v)", err)
+	} else if restored.Owner() != "moony" {
+		t.Errorf("Incorrect deserialized task owner - expected:%v, got:%v", "moony", restored.Owner())
+	}
+}
+
+func TestTaskWithScope(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+	catalog.PutT(catalog.CatalogDoor{OID: "0.3.5"})
+
+	if err := auth.Init(nil, "admin"); err != nil {
+		t.Fatalf("Error initialising auth (%v)", err)
+	}
+
+	auth.SetScopes(func(uid string) *auth.Scope {
+		return &auth.Scope{Doors: []string{"0.3.5"}}
+	})
+
+	defer auth.SetScopes(nil)
+
+	a := auth.NewAuthorizator("moony", "admin")
+	task := unlock()
+
+	if _, err := task.set(a, task.OID.Append(TaskAction), "synchronize ACL", db.DBC{}); !errors.Is(err, auth.ErrUnauthorised) {
+		t.Errorf("Expected 'unauthorised' error updating task, got %v", err)
+	}
+
+	if err := CanAdd(a, &Task{}); !errors.Is(err, auth.ErrUnauthorised) {
+		t.Errorf("Expected 'unauthorised' error adding task, got %v", err)
+	}
+
+	if err := CanDelete(a, task); !errors.Is(err, auth.ErrUnauthorised) {
+		t.Errorf("Expected 'unauthorised' error deleting task, got %v", err)
+	}
+}
+
+func TestTaskSetWithInvalidValues(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+	catalog.PutT(catalog.CatalogDoor{OID: "0.3.5"})
+
+	tests := []struct {
+		suffix schema.Suffix
+		value  string
+	}{
+		{TaskSchedule, "0 25 * * *"},
+		{TaskSchedule, "daily"},
+		{TaskAction, "open sesame"},
+		{TaskDoor, "0.3.7"},
+		{TaskDays, "-1"},
+		{TaskDays, "ninety"},
+	}
+
+	for _, test := range tests {
+		task := unlock()
+
+		if _, err := task.set(nil, schema.OID("0.10.1").Append(test.suffix), test.value, db.DBC{}); err == nil {
+			t.Errorf("Expected error updating %v with '%v'", test.suffix, test.value)
+		}
+	}
+}
+
+func TestTaskIsDue(t *testing.T) {
+	monday := time.Date(2026, time.October, 19, 8, 0, 0, 0, time.Local)
+	sunday := time.Date(2026, time.October, 18, 8, 0, 0, 0, time.Local)
+
+	task := unlock()
+	if !task.IsDue(monday) {
+		t.Errorf("Expected task to be due at %v", monday)
+	}
+
+	if task.IsDue(sunday) {
+		t.Errorf("Expected task not to be due at %v", sunday)
+	}
+
+	task.Enabled = false
+	if task.IsDue(monday) {
+		t.Errorf("Expected disabled task not to be due at %v", monday)
+	}
+
+	task = unlock()
+	task.Door = ""
+	if task.IsDue(monday) {
+		t.Errorf("Expected incomplete task not to be due at %v", monday)
+	}
+}
+
+func unlock() Task {
+	return Task{
+		CatalogTask: catalog.CatalogTask{
+			OID: "0.10.1",
+		},
+		Name:     "Unlock front door",
+		Schedule: "0 8 * * mon-fri",
+		Action:   UnlockDoor,
+		Door:     "0.3.1",
+		Enabled:  true,
+	}
+}
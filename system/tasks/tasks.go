@@ -0,0 +1,278 @@
+package tasks
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+	"github.com/uhppoted/uhppoted-httpd/system/db"
+	"github.com/uhppoted/uhppoted-httpd/types"
+)
+
+type Tasks struct {
+	tasks map[schema.OID]Task
+}
+
+var guard sync.RWMutex
+
+func NewTasks() Tasks {
+	return Tasks{
+		tasks: map[schema.OID]Task{},
+	}
+}
+
+func (tt *Tasks) AsObjects(a *auth.Authorizator) []schema.Object {
+	guard.RLock()
+	defer guard.RUnlock()
+
+	objects := []schema.Object{}
+
+	for _, t := range tt.tasks {
+		if t.IsValid() || t.IsDeleted() {
+			catalog.Join(&objects, t.AsObjects(a)...)
+		}
+	}
+
+	return objects
+}
+
+func (tt *Tasks) Create(a *auth.Authorizator, oid schema.OID, value string, dbc db.DBC) ([]schema.Object, error) {
+	objects := []schema.Object{}
+
+	if tt != nil {
+		if t, err := tt.add(a, Task{owner: auth.UID(a)}); err != nil {
+			return nil, err
+		} else if t == nil {
+			return nil, fmt.Errorf("failed to add 'new' task")
+		} else {
+			t.log(dbc, auth.UID(a), "add", "task", "", "", "Added 'new' task")
+
+			catalog.Join(&objects, catalog.NewObject(t.OID, "new"))
+			catalog.Join(&objects, catalog.NewObject2(t.OID, TaskCreated, t.created))
+		}
+	}
+
+	return objects, nil
+}
+
+func (tt *Tasks) Update(a *auth.Authorizator, oid schema.OID, value string, dbc db.DBC) ([]schema.Object, error) {
+	objects := []schema.Object{}
+
+	if tt != nil {
+		for k, t := range tt.tasks {
+			if t.OID.Contains(oid) {
+				objects, err := t.set(a, oid, value, dbc)
+				if err == nil {
+					tt.tasks[k] = t
+				}
+
+				return objects, err
+			}
+		}
+	}
+
+	return objects, nil
+}
+
+func (tt *Tasks) Delete(a *auth.Authorizator, oid schema.OID, dbc db.DBC) ([]schema.Object, error) {
+	if tt != nil {
+		for k, t := range tt.tasks {
+			if t.OID == oid {
+				objects, err := t.delete(a, dbc)
+				if err == nil {
+					tt.tasks[k] = t
+				}
+
+				return objects, err
+			}
+		}
+	}
+
+	return []schema.Object{}, nil
+}
+
+func (tt *Tasks) Load(blob json.RawMessage) error {
+	rs := []json.RawMessage{}
+	if err := json.Unmarshal(blob, &rs); err != nil {
+		return err
+	}
+
+	for _, v := range rs {
+		var t Task
+		if err := t.deserialize(v); err == nil {
+			if _, ok := tt.tasks[t.OID]; ok {
+				return fmt.Errorf("task '%v': duplicate OID (%v)", t.Name, t.OID)
+			}
+
+			tt.tasks[t.OID] = t
+		}
+	}
+
+	for _, t := range tt.tasks {
+		catalog.PutT(t.CatalogTask)
+		catalog.PutV(t.OID, TaskName, t.Name)
+		catalog.PutV(t.OID, TaskCreated, t.created)
+	}
+
+	return nil
+}
+
+func (tt Tasks) Save() (json.RawMessage, error) {
+	if err := tt.Validate(); err != nil {
+		return nil, err
+	}
+
+	guard.RLock()
+	defer guard.RUnlock()
+
+	serializable := []json.RawMessage{}
+
+	for _, t := range tt.tasks {
+		if t.IsValid() && !t.IsDeleted() {
+			if record, err := t.serialize(); err == nil && record != nil {
+				serializable = append(serializable, record)
+			}
+		}
+	}
+
+	return json.MarshalIndent(serializable, "", "  ")
+}
+
+func (tt *Tasks) Task(oid schema.OID) (Task, bool) {
+	guard.RLock()
+	defer guard.RUnlock()
+
+	t, ok := tt.tasks[oid]
+
+	return t, ok
+}
+
+// Returns the tasks scheduled to run in the minute containing 'now'.
+func (tt *Tasks) Due(now time.Time) []Task {
+	guard.RLock()
+	defer guard.RUnlock()
+
+	list := []Task{}
+	for _, t := range tt.tasks {
+		if t.IsDue(now) {
+			list = append(list, t.clone())
+		}
+	}
+
+	return list
+}
+
+// Updates the 'last run' timestamp and result for a task after it has been run.
+func (tt *Tasks) Ran(run Run) {
+	guard.Lock()
+	defer guard.Unlock()
+
+	if t, ok := tt.tasks[run.Task]; ok {
+		t.lastRun = run.Finished
+		t.result = run.Result
+		if run.Error != "" {
+			t.result = run.Error
+		}
+
+		tt.tasks[run.Task] = t
+
+		catalog.PutV(t.OID, TaskLastRun, t.lastRun)
+		catalog.PutV(t.OID, TaskResult, t.result)
+	}
+}
+
+func (tt Tasks) Print() {
+	serializable := []json.RawMessage{}
+	for _, t := range tt.tasks {
+		if t.IsValid() && !t.IsDeleted() {
+			if record, err := t.serialize(); err == nil && record != nil {
+				serializable = append(serializable, record)
+			}
+		}
+	}
+
+	if b, err := json.MarshalIndent(serializable, "", "  "); err == nil {
+		fmt.Printf("----------------- TASKS\n%s\n", string(b))
+	}
+}
+
+func (tt *Tasks) Clone() Tasks {
+	guard.RLock()
+	defer guard.RUnlock()
+
+	shadow := Tasks{
+		tasks: map[schema.OID]Task{},
+	}
+
+	for k, v := range tt.tasks {
+		shadow.tasks[k] = v.clone()
+	}
+
+	return shadow
+}
+
+func (tt Tasks) Validate() error {
+	names := map[string]string{}
+
+	for k, t := range tt.tasks {
+		if t.IsDeleted() {
+			continue
+		}
+
+		if t.OID == "" {
+			return fmt.Errorf("invalid task OID (%v)", t.OID)
+		} else if k != t.OID {
+			return fmt.Errorf("task %s: mismatched task OID %v (expected %v)", t.Name, t.OID, k)
+		}
+
+		if err := t.validate(); err != nil {
+			if !t.modified.IsZero() {
+				return err
+			}
+		}
+
+		n := strings.TrimSpace(strings.ToLower(t.Name))
+		if v, ok := names[n]; ok && n != "" {
+			return fmt.Errorf("'%v': duplicate task name (%v)", t.Name, v)
+		}
+
+		names[n] = t.Name
+	}
+
+	return nil
+}
+
+func (tt *Tasks) Sweep(retention time.Duration) {
+	if tt != nil {
+		cutoff := time.Now().Add(-retention)
+		for i, v := range tt.tasks {
+			if v.IsDeleted() && v.deleted.Before(cutoff) {
+				delete(tt.tasks, i)
+			}
+		}
+	}
+}
+
+func (tt *Tasks) add(a auth.OpAuth, t Task) (*Task, error) {
+	oid := catalog.NewT(t.CatalogTask)
+	if _, ok := tt.tasks[oid]; ok {
+		return nil, fmt.Errorf("catalog returned duplicate OID (%v)", oid)
+	}
+
+	task := t.clone()
+	task.OID = oid
+	task.created = types.TimestampNow()
+
+	if err := CanAdd(a, &task); err != nil {
+		return nil, err
+	}
+
+	tt.tasks[task.OID] = task
+
+	return &task, nil
+}
@@ -0,0 +1,110 @@
+package tasks
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/uhppoted/uhppoted-httpd/system/catalog"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+	"github.com/uhppoted/uhppoted-httpd/types"
+)
+
+func TestValidateWithDuplicateTaskName(t *testing.T) {
+	p := unlock()
+	q := unlock()
+	q.OID = "0.10.2"
+	q.modified = types.TimestampNow()
+
+	tt := Tasks{
+		tasks: map[schema.OID]Task{
+			p.OID: p,
+			q.OID: q,
+		},
+	}
+
+	if err := tt.Validate(); err == nil {
+		t.Errorf("Expected error validating tasks with duplicate task name")
+	}
+}
+
+func TestValidateWithNewTask(t *testing.T) {
+	tt := Tasks{
+		tasks: map[schema.OID]Task{
+			"0.10.7": Task{
+				CatalogTask: catalog.CatalogTask{
+					OID: "0.10.7",
+				},
+				created: types.TimestampNow(),
+			},
+		},
+	}
+
+	if err := tt.Validate(); err != nil {
+		t.Errorf("Unexpected error validating tasks with new task (%v)", err)
+	}
+}
+
+func TestDue(t *testing.T) {
+	p := unlock()
+	q := unlock()
+	q.OID = "0.10.2"
+	q.Name = "Synchronize time"
+	q.Schedule = "0 3 * * *"
+	q.Action = SynchronizeDateTime
+
+	tt := Tasks{
+		tasks: map[schema.OID]Task{
+			p.OID: p,
+			q.OID: q,
+		},
+	}
+
+	due := tt.Due(time.Date(2026, time.October, 19, 3, 0, 0, 0, time.Local))
+
+	if len(due) != 1 || due[0].OID != "0.10.2" {
+		t.Errorf("Incorrect list of due tasks - expected:[%v], got:%v", "0.10.2", due)
+	}
+}
+
+func TestRunsQuery(t *testing.T) {
+	rr := NewRuns()
+	start := time.Date(2026, time.October, 19, 8, 0, 0, 0, time.Local)
+
+	for i := 0; i < MaxRuns+10; i++ {
+		task := unlock()
+		if i%2 == 1 {
+			task.OID = "0.10.2"
+		}
+
+		started := types.Timestamp(start.Add(time.Duration(i) * time.Minute))
+		rr.Add(NewRun(task, started, started, nil))
+	}
+
+	if N := len(rr.runs); N != MaxRuns {
+		t.Errorf("Incorrect run history length - expected:%v, got:%v", MaxRuns, N)
+	}
+
+	expected := []Run{
+		{
+			Task:     "0.10.1",
+			Name:     "Unlock front door",
+			Action:   "unlock door",
+			Started:  types.Timestamp(start.Add((MaxRuns + 8) * time.Minute)),
+			Finished: types.Timestamp(start.Add((MaxRuns + 8) * time.Minute)),
+			Result:   ResultOk,
+		},
+		{
+			Task:     "0.10.1",
+			Name:     "Unlock front door",
+			Action:   "unlock door",
+			Started:  types.Timestamp(start.Add((MaxRuns + 6) * time.Minute)),
+			Finished: types.Timestamp(start.Add((MaxRuns + 6) * time.Minute)),
+			Result:   ResultOk,
+		},
+	}
+
+	if runs := rr.Query(nil, "0.10.1", 2); !reflect.DeepEqual(runs, expected) {
+		t.Errorf("Incorrect task runs\n   expected:%v\n   got:     %v", expected, runs)
+	}
+}
@@ -0,0 +1,44 @@
+package timeprofiles
+
+import (
+	"github.com/uhppoted/uhppoted-httpd/auth"
+)
+
+type TAuthable interface {
+	TimeProfile | *TimeProfile
+
+	AsRuleEntity() (string, any)
+	CacheKey() string
+}
+
+var rulesets = []auth.RuleSet{auth.TimeProfiles}
+
+// A scoped administrator can view but cannot add, update or delete time profiles, which are
+// shared by all groups.
+func CanView[T TAuthable](a auth.OpAuth, u T, field string, value any) error {
+	return auth.CanView(a, u, field, value, rulesets...)
+}
+
+func CanAdd[T TAuthable](a auth.OpAuth, u T) error {
+	if auth.ScopeOf(a).IsScoped() {
+		return auth.ErrUnauthorised
+	}
+
+	return auth.CanAdd(a, u, rulesets...)
+}
+
+func CanUpdate[T TAuthable](a auth.OpAuth, u T, field string, value any) error {
+	if auth.ScopeOf(a).IsScoped() {
+		return auth.ErrUnauthorised
+	}
+
+	return auth.CanUpdate(a, u, field, value, rulesets...)
+}
+
+func CanDelete[T TAuthable](a auth.OpAuth, u T) error {
+	if auth.ScopeOf(a).IsScoped() {
+		return auth.ErrUnauthorised
+	}
+
+	return auth.CanDelete(a, u, rulesets...)
+}
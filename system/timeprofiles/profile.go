@@ -0,0 +1,539 @@
+package timeprofiles
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+	"time"
+
+	lib "github.com/uhppoted/uhppote-core/types"
+
+	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+	"github.com/uhppoted/uhppoted-httpd/system/db"
+	"github.com/uhppoted/uhppoted-httpd/types"
+)
+
+type TimeProfile struct {
+	catalog.CatalogTimeProfile
+	ProfileID uint8        `json:"ID"`
+	Name      string       `json:"name"`
+	From      lib.Date     `json:"from"`
+	To        lib.Date     `json:"to"`
+	Weekdays  lib.Weekdays `json:"weekdays"`
+	Segments  lib.Segments `json:"segments"`
+
+	created  types.Timestamp
+	modified types.Timestamp
+	deleted  types.Timestamp
+}
+
+type kv = struct {
+	field schema.Suffix
+	value any
+}
+
+// Profile IDs 0 and 1 are reserved by the controllers for 'no access' and 'unrestricted access'
+// and 255 is not a valid profile.
+const (
+	MinProfileID = 2
+	MaxProfileID = 254
+)
+
+var created = types.TimestampNow()
+
+var weekdays = []time.Weekday{
+	time.Monday,
+	time.Tuesday,
+	time.Wednesday,
+	time.Thursday,
+	time.Friday,
+	time.Saturday,
+	time.Sunday,
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"monday":    time.Monday,
+	"mon":       time.Monday,
+	"tuesday":   time.Tuesday,
+	"tue":       time.Tuesday,
+	"wednesday": time.Wednesday,
+	"wed":       time.Wednesday,
+	"thursday":  time.Thursday,
+	"thu":       time.Thursday,
+	"thurs":     time.Thursday,
+	"friday":    time.Friday,
+	"fri":       time.Friday,
+	"saturday":  time.Saturday,
+	"sat":       time.Saturday,
+	"sunday":    time.Sunday,
+	"sun":       time.Sunday,
+}
+
+var segments = map[schema.Suffix]uint8{
+	TimeProfileSegment1: 1,
+	TimeProfileSegment2: 2,
+	TimeProfileSegment3: 3,
+}
+
+func (p TimeProfile) String() string {
+	return fmt.Sprintf("%v", p.Name)
+}
+
+func (p TimeProfile) IsValid() bool {
+	return p.validate() == nil
+}
+
+func (p TimeProfile) validate() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("Time profile name is blank")
+	}
+
+	if p.ProfileID < MinProfileID || p.ProfileID > MaxProfileID {
+		return fmt.Errorf("Invalid time profile ID (%v) - valid range is [%v..%v]", p.ProfileID, MinProfileID, MaxProfileID)
+	}
+
+	if !p.From.IsZero() && !p.To.IsZero() && p.To.Before(p.From) {
+		return fmt.Errorf("Time profile %v: 'to' date %v is before 'from' date %v", p.Name, p.To, p.From)
+	}
+
+	for _, ix := range []uint8{1, 2, 3} {
+		if s, ok := p.Segments[ix]; ok && s.End.Before(s.Start) {
+			return fmt.Errorf("Time profile %v: segment %v end time %v is before the start time %v", p.Name, ix, s.End, s.Start)
+		}
+	}
+
+	return nil
+}
+
+func (p TimeProfile) IsDeleted() bool {
+	return !p.deleted.IsZero()
+}
+
+// Returns the time profile as a controller time profile. Returns false if the profile is
+// incomplete i.e. is not valid or does not have 'from' and 'to' dates. Undefined segments
+// are set to 00:00-00:00 which the controllers treat as 'not in use'.
+func (p TimeProfile) AsTimeProfile() (lib.TimeProfile, bool) {
+	if !p.IsValid() || p.IsDeleted() || p.From.IsZero() || p.To.IsZero() {
+		return lib.TimeProfile{}, false
+	}
+
+	profile := lib.TimeProfile{
+		ID:       p.ProfileID,
+		From:     p.From,
+		To:       p.To,
+		Weekdays: lib.Weekdays{},
+		Segments: lib.Segments{},
+	}
+
+	for _, d := range weekdays {
+		profile.Weekdays[d] = p.Weekdays[d]
+	}
+
+	for _, ix := range []uint8{1, 2, 3} {
+		if s, ok := p.Segments[ix]; ok {
+			profile.Segments[ix] = s
+		} else {
+			profile.Segments[ix] = lib.Segment{
+				Start: lib.NewHHmm(0, 0),
+				End:   lib.NewHHmm(0, 0),
+			}
+		}
+	}
+
+	return profile, true
+}
+
+func (p *TimeProfile) AsObjects(a *auth.Authorizator) []schema.Object {
+	list := []kv{}
+
+	if p.IsDeleted() {
+		list = append(list, kv{TimeProfileDeleted, p.deleted})
+	} else {
+		list = append(list, kv{TimeProfileStatus, p.Status()})
+		list = append(list, kv{TimeProfileCreated, p.created})
+		list = append(list, kv{TimeProfileDeleted, p.deleted})
+		list = append(list, kv{TimeProfileName, p.Name})
+		list = append(list, kv{TimeProfileID, p.ProfileID})
+		list = append(list, kv{TimeProfileFrom, p.From})
+		list = append(list, kv{TimeProfileTo, p.To})
+		list = append(list, kv{TimeProfileWeekdays, p.Weekdays.String()})
+		list = append(list, kv{TimeProfileSegment1, p.segment(1)})
+		list = append(list, kv{TimeProfileSegment2, p.segment(2)})
+		list = append(list, kv{TimeProfileSegment3, p.segment(3)})
+	}
+
+	return p.toObjects(list, a)
+}
+
+func (p TimeProfile) AsRuleEntity() (string, any) {
+	entity := struct {
+		ID   uint8
+		Name string
+	}{
+		ID:   p.ProfileID,
+		Name: fmt.Sprintf("%v", p.Name),
+	}
+
+	return "time-profile", &entity
+}
+
+func (p TimeProfile) CacheKey() string {
+	return ""
+}
+
+func (p TimeProfile) Status() types.Status {
+	if p.IsDeleted() {
+		return types.StatusDeleted
+	}
+
+	return types.StatusOk
+}
+
+func (p *TimeProfile) set(a *auth.Authorizator, oid schema.OID, value string, dbc db.DBC) ([]schema.Object, error) {
+	if p == nil {
+		return []schema.Object{}, nil
+	}
+
+	if p.IsDeleted() {
+		return p.toObjects([]kv{{TimeProfileDeleted, p.deleted}}, a), fmt.Errorf("Time profile has been deleted")
+	}
+
+	uid := auth.UID(a)
+	original := p.clone()
+	list := []kv{}
+
+	switch {
+	case oid == p.OID.Append(TimeProfileName):
+		if err := CanUpdate(a, p, "name", value); err != nil {
+			return nil, err
+		} else {
+			p.Name = value
+			p.modified = types.TimestampNow()
+
+			list = append(list, kv{TimeProfileName, p.Name})
+
+			p.log(dbc, uid, "update", "name", original.Name, value, "Updated name from %v to %v", original.Name, p.Name)
+		}
+
+	case oid == p.OID.Append(TimeProfileID):
+		if err := CanUpdate(a, p, "ID", value); err != nil {
+			return nil, err
+		} else if v, err := strconv.ParseUint(strings.TrimSpace(value), 10, 8); err != nil || v < MinProfileID || v > MaxProfileID {
+			return nil, fmt.Errorf("invalid time profile ID (%v) - valid range is [%v..%v]", value, MinProfileID, MaxProfileID)
+		} else {
+			p.ProfileID = uint8(v)
+			p.modified = types.TimestampNow()
+
+			list = append(list, kv{TimeProfileID, p.ProfileID})
+
+			p.log(dbc, uid, "update", "ID", original.ProfileID, p.ProfileID, "Updated profile ID from %v to %v", original.ProfileID, p.ProfileID)
+		}
+
+	case oid == p.OID.Append(TimeProfileFrom):
+		if err := CanUpdate(a, p, "from", value); err != nil {
+			return nil, err
+		} else if from, err := lib.ParseDate(value); err != nil && value != "" {
+			return nil, err
+		} else {
+			p.log(dbc, uid, "update", "from", p.From, value, "Updated VALID FROM date from %v to %v", p.From, value)
+			p.From = from
+			p.modified = types.TimestampNow()
+
+			list = append(list, kv{TimeProfileFrom, p.From})
+		}
+
+	case oid == p.OID.Append(TimeProfileTo):
+		if err := CanUpdate(a, p, "to", value); err != nil {
+			return nil, err
+		} else if to, err := lib.ParseDate(value); err != nil && value != "" {
+			return nil, err
+		} else {
+			p.log(dbc, uid, "update", "to", p.To, value, "Updated VALID UNTIL date from %v to %v", p.To, value)
+			p.To = to
+			p.modified = types.TimestampNow()
+
+			list = append(list, kv{TimeProfileTo, p.To})
+		}
+
+	case oid == p.OID.Append(TimeProfileWeekdays):
+		if err := CanUpdate(a, p, "weekdays", value); err != nil {
+			return nil, err
+		} else if days, err := parseWeekdays(value); err != nil {
+			return nil, err
+		} else {
+			p.Weekdays = days
+			p.modified = types.TimestampNow()
+
+			list = append(list, kv{TimeProfileWeekdays, p.Weekdays.String()})
+
+			p.log(dbc, uid, "update", "weekdays", original.Weekdays, p.Weekdays, "Updated weekdays from %v to %v", original.Weekdays, p.Weekdays)
+		}
+
+	case segments[schema.Suffix(strings.TrimPrefix(string(oid), string(p.OID)))] != 0:
+		suffix := schema.Suffix(strings.TrimPrefix(string(oid), string(p.OID)))
+		ix := segments[suffix]
+
+		if err := CanUpdate(a, p, "segment", value); err != nil {
+			return nil, err
+		} else if segment, err := parseSegment(value); err != nil {
+			return nil, err
+		} else {
+			if segment == nil {
+				delete(p.Segments, ix)
+			} else {
+				p.Segments[ix] = *segment
+			}
+
+			p.modified = types.TimestampNow()
+
+			list = append(list, kv{suffix, p.segment(ix)})
+
+			p.log(dbc, uid, "update", "segment", original.segment(ix), p.segment(ix), "Updated segment %v from %v to %v", ix, original.segment(ix), p.segment(ix))
+		}
+	}
+
+	dbc.Updated(p.OID, "", p.ProfileID)
+
+	list = append(list, kv{TimeProfileStatus, p.Status()})
+
+	return p.toObjects(list, a), nil
+}
+
+func (p *TimeProfile) delete(a *auth.Authorizator, dbc db.DBC) ([]schema.Object, error) {
+	list := []kv{}
+
+	if p != nil {
+		if err := CanDelete(a, p); err != nil {
+			return nil, err
+		}
+
+		p.log(dbc, auth.UID(a), "delete", "time-profile", p.Name, "", "Deleted time profile %v", p.Name)
+		p.deleted = types.TimestampNow()
+		p.modified = types.TimestampNow()
+
+		list = append(list, kv{TimeProfileStatus, p.Status()})
+		list = append(list, kv{TimeProfileDeleted, p.deleted})
+
+		catalog.DeleteT(p.CatalogTimeProfile, p.OID)
+
+		dbc.Updated(p.OID, "", p.ProfileID)
+	}
+
+	return p.toObjects(list, a), nil
+}
+
+func (p TimeProfile) toObjects(list []kv, a *auth.Authorizator) []schema.Object {
+	objects := []schema.Object{}
+
+	if err := CanView(a, p, "OID", p.OID); err == nil && !p.IsDeleted() {
+		catalog.Join(&objects, catalog.NewObject(p.OID, ""))
+	}
+
+	for _, v := range list {
+		field := lookup[v.field]
+		if err := CanView(a, p, field, v.value); err == nil {
+			catalog.Join(&objects, catalog.NewObject2(p.OID, v.field, v.value))
+		}
+	}
+
+	return objects
+}
+
+func (p TimeProfile) segment(ix uint8) string {
+	if s, ok := p.Segments[ix]; ok {
+		return fmt.Sprintf("%v-%v", s.Start, s.End)
+	}
+
+	return ""
+}
+
+func (p TimeProfile) serialize() ([]byte, error) {
+	record := struct {
+		OID      schema.OID      `json:"OID"`
+		ID       uint8           `json:"ID"`
+		Name     string          `json:"name,omitempty"`
+		From     string          `json:"from,omitempty"`
+		To       string          `json:"to,omitempty"`
+		Weekdays []string        `json:"weekdays"`
+		Segments []string        `json:"segments"`
+		Created  types.Timestamp `json:"created"`
+		Modified types.Timestamp `json:"modified"`
+	}{
+		OID:      p.OID,
+		ID:       p.ProfileID,
+		Name:     p.Name,
+		Weekdays: []string{},
+		Segments: []string{},
+		Created:  p.created.UTC(),
+		Modified: p.modified.UTC(),
+	}
+
+	if !p.From.IsZero() {
+		record.From = fmt.Sprintf("%v", p.From)
+	}
+
+	if !p.To.IsZero() {
+		record.To = fmt.Sprintf("%v", p.To)
+	}
+
+	for _, d := range weekdays {
+		if p.Weekdays[d] {
+			record.Weekdays = append(record.Weekdays, d.String())
+		}
+	}
+
+	for _, ix := range []uint8{1, 2, 3} {
+		record.Segments = append(record.Segments, p.segment(ix))
+	}
+
+	return json.Marshal(record)
+}
+
+func (p *TimeProfile) deserialize(bytes []byte) error {
+	created = created.Add(1 * time.Minute)
+
+	record := struct {
+		OID      schema.OID      `json:"OID"`
+		ID       uint8           `json:"ID"`
+		Name     string          `json:"name,omitempty"`
+		From     string          `json:"from,omitempty"`
+		To       string          `json:"to,omitempty"`
+		Weekdays []string        `json:"weekdays"`
+		Segments []string        `json:"segments"`
+		Created  types.Timestamp `json:"created"`
+		Modified types.Timestamp `json:"modified"`
+	}{
+		Created: created,
+	}
+
+	if err := json.Unmarshal(bytes, &record); err != nil {
+		return err
+	}
+
+	p.OID = record.OID
+	p.ProfileID = record.ID
+	p.Name = record.Name
+	p.Weekdays = lib.Weekdays{}
+	p.Segments = lib.Segments{}
+	p.created = record.Created
+	p.modified = record.Modified
+
+	if record.From != "" {
+		if from, err := lib.ParseDate(record.From); err != nil {
+			return err
+		} else {
+			p.From = from
+		}
+	}
+
+	if record.To != "" {
+		if to, err := lib.ParseDate(record.To); err != nil {
+			return err
+		} else {
+			p.To = to
+		}
+	}
+
+	if days, err := parseWeekdays(strings.Join(record.Weekdays, ",")); err != nil {
+		return err
+	} else {
+		p.Weekdays = days
+	}
+
+	for i, v := range record.Segments {
+		if i < 3 {
+			if segment, err := parseSegment(v); err != nil {
+				return err
+			} else if segment != nil {
+				p.Segments[uint8(i+1)] = *segment
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p TimeProfile) clone() TimeProfile {
+	profile := TimeProfile{
+		CatalogTimeProfile: catalog.CatalogTimeProfile{
+			OID: p.OID,
+		},
+		ProfileID: p.ProfileID,
+		Name:      p.Name,
+		From:      p.From,
+		To:        p.To,
+		Weekdays:  lib.Weekdays{},
+		Segments:  lib.Segments{},
+		created:   p.created,
+		modified:  p.modified,
+		deleted:   p.deleted,
+	}
+
+	maps.Copy(profile.Weekdays, p.Weekdays)
+	maps.Copy(profile.Segments, p.Segments)
+
+	return profile
+}
+
+func (p *TimeProfile) log(dbc db.DBC, uid, op string, field string, before, after any, format string, fields ...any) {
+	dbc.Log(uid, op, p.OID, "time-profile", p.ProfileID, p.Name, field, before, after, format, fields...)
+}
+
+// Parses a comma separated list of weekdays. Accepts the full names and the abbreviations used by
+// lib.Weekdays e.g. 'Monday,Tue,Thurs'.
+func parseWeekdays(s string) (lib.Weekdays, error) {
+	days := lib.Weekdays{}
+
+	for _, token := range strings.Split(s, ",") {
+		t := strings.ToLower(strings.TrimSpace(token))
+		if t == "" {
+			continue
+		}
+
+		if d, ok := weekdayNames[t]; ok {
+			days[d] = true
+		} else {
+			return nil, fmt.Errorf("invalid weekday '%v'", token)
+		}
+	}
+
+	return days, nil
+}
+
+// Parses a time segment formatted as HH:mm-HH:mm. A blank segment (or 00:00-00:00) is returned
+// as nil i.e. 'not in use'.
+func parseSegment(s string) (*lib.Segment, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	tokens := strings.Split(s, "-")
+	if len(tokens) != 2 {
+		return nil, fmt.Errorf("invalid time segment '%v' - expected HH:mm-HH:mm", s)
+	}
+
+	start, err := lib.HHmmFromString(strings.TrimSpace(tokens[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	end, err := lib.HHmmFromString(strings.TrimSpace(tokens[1]))
+	if err != nil {
+		return nil, err
+	}
+
+	if end.Before(*start) {
+		return nil, fmt.Errorf("invalid time segment '%v' - end time is before start time", s)
+	}
+
+	if start.Equals(lib.NewHHmm(0, 0)) && end.Equals(lib.NewHHmm(0, 0)) {
+		return nil, nil
+	}
+
+	return &lib.Segment{Start: *start, End: *end}, nil
+}
@@ -0,0 +1,199 @@
+package timeprofiles
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	lib "github.com/uhppoted/uhppote-core/types"
+
+	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+	"github.com/uhppoted/uhppoted-httpd/system/db"
+	"github.com/uhppoted/uhppoted-httpd/types"
+)
+
+func TestTimeProfileSerialize(t *testing.T) {
+	created = types.Timestamp(time.Date(2022, time.April, 1, 0, 0, 0, 0, time.UTC))
+
+	p := cleaners()
+	p.created = created
+
+	expected := `{"OID":"0.9.1","ID":29,"name":"Cleaners","from":"2024-01-01","to":"2024-12-31","weekdays":["Monday","Tuesday","Wednesday","Thursday","Friday"],"segments":["18:00-22:00","",""],"created":"2022-04-01 00:00:00 UTC","modified":""}`
+
+	if bytes, err := p.serialize(); err != nil {
+		t.Fatalf("Error serializing time profile (%v)", err)
+	} else if string(bytes) != expected {
+		t.Errorf("Time profile incorrectly serialized\n   expected:%v\n   got:     %v", expected, string(bytes))
+	}
+}
+
+func TestTimeProfileDeserialize(t *testing.T) {
+	created = types.Timestamp(time.Date(2022, time.April, 1, 0, 0, 0, 0, time.Local))
+
+	encoded := `{ "OID":"0.9.1", "ID":29, "name":"Cleaners", "from":"2024-01-01", "to":"2024-12-31", "weekdays":["Monday","Tuesday","Wednesday","Thursday","Friday"], "segments":["18:00-22:00","",""], "created":"2022-04-01 00:00:00" }`
+	expected := cleaners()
+	expected.created = created
+
+	var p TimeProfile
+
+	if err := p.deserialize([]byte(encoded)); err != nil {
+		t.Fatalf("Error deserializing time profile (%v)", err)
+	}
+
+	if !reflect.DeepEqual(p, expected) {
+		t.Errorf("Time profile incorrectly deserialized\n   expected:%#v\n   got:     %#v", expected, p)
+	}
+}
+
+func TestTimeProfileAsObjectsWithAuth(t *testing.T) {
+	created = types.Timestamp(time.Date(2021, time.February, 28, 12, 34, 56, 0, time.Local))
+
+	p := cleaners()
+	p.created = created
+
+	expected := []schema.Object{
+		{OID: "0.9.1", Value: ""},
+		{OID: "0.9.1.0.0", Value: types.StatusOk},
+		{OID: "0.9.1.0.1", Value: created},
+		{OID: "0.9.1.0.2", Value: types.Timestamp{}},
+		{OID: "0.9.1.1", Value: "Cleaners"},
+		{OID: "0.9.1.2", Value: uint8(29)},
+		{OID: "0.9.1.3", Value: lib.MustParseDate("2024-01-01")},
+		{OID: "0.9.1.4", Value: lib.MustParseDate("2024-12-31")},
+		{OID: "0.9.1.5", Value: "Mon,Tue,Wed,Thurs,Fri"},
+		{OID: "0.9.1.6.1", Value: "18:00-22:00"},
+		{OID: "0.9.1.6.2", Value: ""},
+		// {OID: "0.9.1.6.3", Value: ""},
+	}
+
+	a := auth.Authorizator{
+		OpAuth: &stub{
+			canView: func(ruleset auth.RuleSet, object auth.Operant, field string, value any) error {
+				if strings.HasPrefix(field, "profile.segment.3") {
+					return errors.New("test")
+				}
+
+				return nil
+			},
+		},
+	}
+
+	objects := p.AsObjects(&a)
+
+	if !reflect.DeepEqual(objects, expected) {
+		t.Errorf("Incorrect return from AsObjects:\n   expected:%#v\n   got:     %#v", expected, objects)
+	}
+}
+
+func TestTimeProfileAsTimeProfile(t *testing.T) {
+	p := cleaners()
+
+	expected := lib.TimeProfile{
+		ID:   29,
+		From: lib.MustParseDate("2024-01-01"),
+		To:   lib.MustParseDate("2024-12-31"),
+		Weekdays: lib.Weekdays{
+			time.Monday:    true,
+			time.Tuesday:   true,
+			time.Wednesday: true,
+			time.Thursday:  true,
+			time.Friday:    true,
+			time.Saturday:  false,
+			time.Sunday:    false,
+		},
+		Segments: lib.Segments{
+			1: {Start: lib.NewHHmm(18, 0), End: lib.NewHHmm(22, 0)},
+			2: {Start: lib.NewHHmm(0, 0), End: lib.NewHHmm(0, 0)},
+			3: {Start: lib.NewHHmm(0, 0), End: lib.NewHHmm(0, 0)},
+		},
+	}
+
+	if profile, ok := p.AsTimeProfile(); !ok {
+		t.Errorf("Expected valid time profile")
+	} else if !reflect.DeepEqual(profile, expected) {
+		t.Errorf("Incorrect time profile\n   expected:%v\n   got:     %v", expected, profile)
+	}
+
+	p.To = lib.Date{}
+	if _, ok := p.AsTimeProfile(); ok {
+		t.Errorf("Expected incomplete time profile to be invalid")
+	}
+}
+
+func TestTimeProfileSet(t *testing.T) {
+	tests := []struct {
+		suffix   schema.Suffix
+		value    string
+		expected any
+	}{
+		{TimeProfileName, "Night Shift", "Night Shift"},
+		{TimeProfileID, "37", uint8(37)},
+		{TimeProfileFrom, "2025-01-01", lib.MustParseDate("2025-01-01")},
+		{TimeProfileWeekdays, "Sat, sunday", "Sat,Sun"},
+		{TimeProfileSegment2, "06:00-08:30", "06:00-08:30"},
+		{TimeProfileSegment1, "", ""},
+	}
+
+	for _, test := range tests {
+		p := cleaners()
+		expected := []schema.Object{
+			{OID: "0.9.1", Value: ""},
+			{OID: schema.OID("0.9.1").Append(test.suffix), Value: test.expected},
+			{OID: "0.9.1.0.0", Value: types.StatusOk},
+		}
+
+		objects, err := p.set(nil, schema.OID("0.9.1").Append(test.suffix), test.value, db.DBC{})
+		if err != nil {
+			t.Errorf("Unexpected error updating %v (%v)", test.suffix, err)
+		} else if !reflect.DeepEqual(objects, expected) {
+			t.Errorf("Invalid result updating %v\n   expected:%#v\n   got:     %#v", test.suffix, expected, objects)
+		}
+	}
+}
+
+func TestTimeProfileSetWithInvalidValues(t *testing.T) {
+	tests := []struct {
+		suffix schema.Suffix
+		value  string
+	}{
+		{TimeProfileID, "1"},
+		{TimeProfileID, "255"},
+		{TimeProfileFrom, "2025-02-30"},
+		{TimeProfileWeekdays, "Mon,Funday"},
+		{TimeProfileSegment1, "22:00-18:00"},
+		{TimeProfileSegment1, "18:00"},
+	}
+
+	for _, test := range tests {
+		p := cleaners()
+		if _, err := p.set(nil, schema.OID("0.9.1").Append(test.suffix), test.value, db.DBC{}); err == nil {
+			t.Errorf("Expected error updating %v with '%v'", test.suffix, test.value)
+		}
+	}
+}
+
+func cleaners() TimeProfile {
+	return TimeProfile{
+		CatalogTimeProfile: catalog.CatalogTimeProfile{
+			OID: "0.9.1",
+		},
+		ProfileID: 29,
+		Name:      "Cleaners",
+		From:      lib.MustParseDate("2024-01-01"),
+		To:        lib.MustParseDate("2024-12-31"),
+		Weekdays: lib.Weekdays{
+			time.Monday:    true,
+			time.Tuesday:   true,
+			time.Wednesday: true,
+			time.Thursday:  true,
+			time.Friday:    true,
+		},
+		Segments: lib.Segments{
+			1: {Start: lib.NewHHmm(18, 0), End: lib.NewHHmm(22, 0)},
+		},
+	}
+}
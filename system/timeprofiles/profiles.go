@@ -0,0 +1,327 @@
+package timeprofiles
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	lib "github.com/uhppoted/uhppote-core/types"
+
+	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+	"github.com/uhppoted/uhppoted-httpd/system/db"
+	"github.com/uhppoted/uhppoted-httpd/types"
+)
+
+type TimeProfiles struct {
+	profiles map[schema.OID]TimeProfile
+}
+
+var guard sync.RWMutex
+
+func NewTimeProfiles() TimeProfiles {
+	return TimeProfiles{
+		profiles: map[schema.OID]TimeProfile{},
+	}
+}
+
+func (pp *TimeProfiles) AsObjects(a *auth.Authorizator) []schema.Object {
+	guard.RLock()
+	defer guard.RUnlock()
+
+	objects := []schema.Object{}
+
+	for _, p := range pp.profiles {
+		if p.IsValid() || p.IsDeleted() {
+			catalog.Join(&objects, p.AsObjects(a)...)
+		}
+	}
+
+	return objects
+}
+
+func (pp *TimeProfiles) Create(a *auth.Authorizator, oid schema.OID, value string, dbc db.DBC) ([]schema.Object, error) {
+	objects := []schema.Object{}
+
+	if pp != nil {
+		if p, err := pp.add(a, TimeProfile{}); err != nil {
+			return nil, err
+		} else if p == nil {
+			return nil, fmt.Errorf("failed to add 'new' time profile")
+		} else {
+			p.log(dbc, auth.UID(a), "add", "time-profile", "", "", "Added 'new' time profile")
+
+			catalog.Join(&objects, catalog.NewObject(p.OID, "new"))
+			catalog.Join(&objects, catalog.NewObject2(p.OID, TimeProfileCreated, p.created))
+			catalog.Join(&objects, catalog.NewObject2(p.OID, TimeProfileID, p.ProfileID))
+		}
+	}
+
+	return objects, nil
+}
+
+func (pp *TimeProfiles) Update(a *auth.Authorizator, oid schema.OID, value string, dbc db.DBC) ([]schema.Object, error) {
+	objects := []schema.Object{}
+
+	if pp != nil {
+		for k, p := range pp.profiles {
+			if p.OID.Contains(oid) {
+				objects, err := p.set(a, oid, value, dbc)
+				if err == nil {
+					pp.profiles[k] = p
+				}
+
+				return objects, err
+			}
+		}
+	}
+
+	return objects, nil
+}
+
+func (pp *TimeProfiles) Delete(a *auth.Authorizator, oid schema.OID, dbc db.DBC) ([]schema.Object, error) {
+	if pp != nil {
+		for k, p := range pp.profiles {
+			if p.OID == oid {
+				objects, err := p.delete(a, dbc)
+				if err == nil {
+					pp.profiles[k] = p
+				}
+
+				return objects, err
+			}
+		}
+	}
+
+	return []schema.Object{}, nil
+}
+
+func (pp *TimeProfiles) Load(blob json.RawMessage) error {
+	rs := []json.RawMessage{}
+	if err := json.Unmarshal(blob, &rs); err != nil {
+		return err
+	}
+
+	for _, v := range rs {
+		var p TimeProfile
+		if err := p.deserialize(v); err == nil {
+			if _, ok := pp.profiles[p.OID]; ok {
+				return fmt.Errorf("time profile '%v': duplicate OID (%v)", p.Name, p.OID)
+			}
+
+			pp.profiles[p.OID] = p
+		}
+	}
+
+	for _, p := range pp.profiles {
+		catalog.PutT(p.CatalogTimeProfile)
+		catalog.PutV(p.OID, TimeProfileName, p.Name)
+		catalog.PutV(p.OID, TimeProfileID, p.ProfileID)
+		catalog.PutV(p.OID, TimeProfileCreated, p.created)
+	}
+
+	return nil
+}
+
+func (pp TimeProfiles) Save() (json.RawMessage, error) {
+	if err := pp.Validate(); err != nil {
+		return nil, err
+	}
+
+	serializable := []json.RawMessage{}
+
+	for _, p := range pp.profiles {
+		if p.IsValid() && !p.IsDeleted() {
+			if record, err := p.serialize(); err == nil && record != nil {
+				serializable = append(serializable, record)
+			}
+		}
+	}
+
+	return json.MarshalIndent(serializable, "", "  ")
+}
+
+func (pp *TimeProfiles) TimeProfile(oid schema.OID) (TimeProfile, bool) {
+	p, ok := pp.profiles[oid]
+
+	return p, ok
+}
+
+// Returns the controller time profiles for all valid time profiles. Incomplete profiles are
+// not included.
+func (pp *TimeProfiles) AsTimeProfiles() []lib.TimeProfile {
+	guard.RLock()
+	defer guard.RUnlock()
+
+	list := []lib.TimeProfile{}
+	for _, p := range pp.profiles {
+		if profile, ok := p.AsTimeProfile(); ok {
+			list = append(list, profile)
+		}
+	}
+
+	return list
+}
+
+// Returns the controller profile ID for a time profile OID. Returns false if the time profile
+// does not exist or is incomplete and cannot be used in a card ACL.
+func (pp *TimeProfiles) ProfileID(oid schema.OID) (uint8, bool) {
+	guard.RLock()
+	defer guard.RUnlock()
+
+	if p, ok := pp.profiles[oid]; ok {
+		if _, ok := p.AsTimeProfile(); ok {
+			return p.ProfileID, true
+		}
+	}
+
+	return 0, false
+}
+
+// Looks up a time profile by name or profile ID (e.g. for grule 'DOORS.AllowWithProfile')
+// and returns the profile OID.
+func (pp *TimeProfiles) Find(profile string) (schema.OID, bool) {
+	guard.RLock()
+	defer guard.RUnlock()
+
+	key := strings.TrimSpace(profile)
+	id, err := strconv.ParseUint(key, 10, 8)
+
+	for _, p := range pp.profiles {
+		if p.IsDeleted() {
+			continue
+		}
+
+		if strings.EqualFold(strings.TrimSpace(p.Name), key) {
+			return p.OID, true
+		}
+
+		if err == nil && uint64(p.ProfileID) == id {
+			return p.OID, true
+		}
+	}
+
+	return "", false
+}
+
+func (pp TimeProfiles) Print() {
+	serializable := []json.RawMessage{}
+	for _, p := range pp.profiles {
+		if p.IsValid() && !p.IsDeleted() {
+			if record, err := p.serialize(); err == nil && record != nil {
+				serializable = append(serializable, record)
+			}
+		}
+	}
+
+	if b, err := json.MarshalIndent(serializable, "", "  "); err == nil {
+		fmt.Printf("----------------- TIME PROFILES\n%s\n", string(b))
+	}
+}
+
+func (pp *TimeProfiles) Clone() TimeProfiles {
+	guard.RLock()
+	defer guard.RUnlock()
+
+	shadow := TimeProfiles{
+		profiles: map[schema.OID]TimeProfile{},
+	}
+
+	for k, v := range pp.profiles {
+		shadow.profiles[k] = v.clone()
+	}
+
+	return shadow
+}
+
+func (pp TimeProfiles) Validate() error {
+	names := map[string]string{}
+	ids := map[uint8]string{}
+
+	for k, p := range pp.profiles {
+		if p.IsDeleted() {
+			continue
+		}
+
+		if p.OID == "" {
+			return fmt.Errorf("invalid time profile OID (%v)", p.OID)
+		} else if k != p.OID {
+			return fmt.Errorf("time profile %s: mismatched time profile OID %v (expected %v)", p.Name, p.OID, k)
+		}
+
+		if err := p.validate(); err != nil {
+			if !p.modified.IsZero() {
+				return err
+			}
+		}
+
+		n := strings.TrimSpace(strings.ToLower(p.Name))
+		if v, ok := names[n]; ok && n != "" {
+			return fmt.Errorf("'%v': duplicate time profile name (%v)", p.Name, v)
+		}
+
+		if v, ok := ids[p.ProfileID]; ok && p.ProfileID != 0 {
+			return fmt.Errorf("'%v': duplicate time profile ID %v (%v)", p.Name, p.ProfileID, v)
+		}
+
+		names[n] = p.Name
+		ids[p.ProfileID] = p.Name
+	}
+
+	return nil
+}
+
+func (pp *TimeProfiles) Sweep(retention time.Duration) {
+	if pp != nil {
+		cutoff := time.Now().Add(-retention)
+		for i, v := range pp.profiles {
+			if v.IsDeleted() && v.deleted.Before(cutoff) {
+				delete(pp.profiles, i)
+			}
+		}
+	}
+}
+
+// Adds a time profile with the lowest unused profile ID.
+func (pp *TimeProfiles) add(a auth.OpAuth, p TimeProfile) (*TimeProfile, error) {
+	used := map[uint8]bool{}
+	for _, v := range pp.profiles {
+		if !v.IsDeleted() {
+			used[v.ProfileID] = true
+		}
+	}
+
+	id := uint8(0)
+	for v := MinProfileID; v <= MaxProfileID; v++ {
+		if !used[uint8(v)] {
+			id = uint8(v)
+			break
+		}
+	}
+
+	if id == 0 {
+		return nil, fmt.Errorf("no unused time profile IDs")
+	}
+
+	oid := catalog.NewT(p.CatalogTimeProfile)
+	if _, ok := pp.profiles[oid]; ok {
+		return nil, fmt.Errorf("catalog returned duplicate OID (%v)", oid)
+	}
+
+	profile := p.clone()
+	profile.OID = oid
+	profile.ProfileID = id
+	profile.created = types.TimestampNow()
+
+	if err := CanAdd(a, &profile); err != nil {
+		return nil, err
+	}
+
+	pp.profiles[profile.OID] = profile
+
+	return &profile, nil
+}
@@ -0,0 +1,93 @@
+package timeprofiles
+
+import (
+	"testing"
+
+	"github.com/uhppoted/uhppoted-httpd/system/catalog"
+	memdb "github.com/uhppoted/uhppoted-httpd/system/catalog/impl"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+	"github.com/uhppoted/uhppoted-httpd/system/db"
+	"github.com/uhppoted/uhppoted-httpd/types"
+)
+
+func TestValidateWithDuplicateProfileID(t *testing.T) {
+	p := cleaners()
+	q := cleaners()
+	q.OID = "0.9.2"
+	q.Name = "Night Shift"
+	q.modified = types.TimestampNow()
+
+	pp := TimeProfiles{
+		profiles: map[schema.OID]TimeProfile{
+			p.OID: p,
+			q.OID: q,
+		},
+	}
+
+	if err := pp.Validate(); err == nil {
+		t.Errorf("Expected error validating time profiles with duplicate profile ID")
+	}
+}
+
+func TestValidateWithNewTimeProfile(t *testing.T) {
+	pp := TimeProfiles{
+		profiles: map[schema.OID]TimeProfile{
+			"0.9.7": TimeProfile{
+				CatalogTimeProfile: catalog.CatalogTimeProfile{
+					OID: "0.9.7",
+				},
+				created: types.TimestampNow(),
+			},
+		},
+	}
+
+	if err := pp.Validate(); err != nil {
+		t.Errorf("Unexpected error validating time profiles with new time profile (%v)", err)
+	}
+}
+
+func TestCreateAssignsUnusedProfileID(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+
+	p := cleaners()
+	p.ProfileID = 2
+	catalog.PutT(p.CatalogTimeProfile)
+
+	pp := TimeProfiles{
+		profiles: map[schema.OID]TimeProfile{
+			p.OID: p,
+		},
+	}
+
+	objects, err := pp.Create(nil, "", "", db.DBC{})
+	if err != nil {
+		t.Fatalf("Unexpected error creating time profile (%v)", err)
+	}
+
+	if len(objects) == 0 {
+		t.Fatalf("Expected 'new' time profile, got %v", objects)
+	} else if q, ok := pp.TimeProfile(objects[0].OID); !ok {
+		t.Errorf("Time profile %v not created", objects[0].OID)
+	} else if q.ProfileID != 3 {
+		t.Errorf("Incorrect profile ID for new time profile - expected:%v, got:%v", 3, q.ProfileID)
+	}
+}
+
+func TestFind(t *testing.T) {
+	p := cleaners()
+	pp := TimeProfiles{
+		profiles: map[schema.OID]TimeProfile{
+			p.OID: p,
+		},
+	}
+
+	for _, v := range []string{"Cleaners", "cleaners", "29"} {
+		if oid, ok := pp.Find(v); !ok || oid != "0.9.1" {
+			t.Errorf("Incorrect time profile for '%v' - expected:%v, got:%v", v, "0.9.1", oid)
+		}
+	}
+
+	if oid, ok := pp.Find("Night Shift"); ok {
+		t.Errorf("Expected no time profile for 'Night Shift', got %v", oid)
+	}
+}
@@ -0,0 +1,34 @@
+package timeprofiles
+
+import (
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+)
+
+const TimeProfileStatus = schema.Status
+const TimeProfileCreated = schema.Created
+const TimeProfileDeleted = schema.Deleted
+const TimeProfileModified = schema.Modified
+const TimeProfileName = schema.TimeProfileName
+const TimeProfileID = schema.TimeProfileID
+const TimeProfileFrom = schema.TimeProfileFrom
+const TimeProfileTo = schema.TimeProfileTo
+const TimeProfileWeekdays = schema.TimeProfileWeekdays
+const TimeProfileSegments = schema.TimeProfileSegments
+const TimeProfileSegment1 = schema.TimeProfileSegment1
+const TimeProfileSegment2 = schema.TimeProfileSegment2
+const TimeProfileSegment3 = schema.TimeProfileSegment3
+
+var lookup = map[schema.Suffix]string{
+	TimeProfileStatus:   "profile.status",
+	TimeProfileCreated:  "profile.created",
+	TimeProfileDeleted:  "profile.deleted",
+	TimeProfileModified: "profile.modified",
+	TimeProfileName:     "profile.name",
+	TimeProfileID:       "profile.ID",
+	TimeProfileFrom:     "profile.from",
+	TimeProfileTo:       "profile.to",
+	TimeProfileWeekdays: "profile.weekdays",
+	TimeProfileSegment1: "profile.segment.1",
+	TimeProfileSegment2: "profile.segment.2",
+	TimeProfileSegment3: "profile.segment.3",
+}
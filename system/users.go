@@ -1,11 +1,30 @@
 package system
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/uhppoted/uhppoted-httpd/auth"
 	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
 	"github.com/uhppoted/uhppoted-httpd/system/db"
+	"github.com/uhppoted/uhppoted-httpd/system/users"
+	"github.com/uhppoted/uhppoted-httpd/types"
 )
 
+// Sets the number of failed logins after which a user account is locked and the cooldown after
+// which a locked account is unlocked automatically (0 for never).
+func SetLockout(attempts uint32, cooldown time.Duration) {
+	users.SetLockout(attempts, cooldown)
+
+	if attempts == 0 {
+		infof("users", "account lockout disabled")
+	} else if cooldown > 0 {
+		infof("users", "account lockout after %v failed logins (cooldown %v)", attempts, cooldown)
+	} else {
+		infof("users", "account lockout after %v failed logins", attempts)
+	}
+}
+
 func Users(uid, role string) []schema.Object {
 	sys.RLock()
 	defer sys.RUnlock()
@@ -72,6 +91,11 @@ func GetUser(uid string) (auth.IUser, bool) {
 	return sys.users.User(uid)
 }
 
+// Returns the scope of a delegated administrator, or nil if the user is not scoped.
+func GetScope(uid string) *auth.Scope {
+	return sys.users.Scope(uid)
+}
+
 func SetPassword(uid, role, pwd string) error {
 	sys.Lock()
 	defer sys.Unlock()
@@ -159,6 +183,181 @@ func RevokeOTP(uid, role string) error {
 	return nil
 }
 
+// Returns the API tokens issued to the user.
+func Tokens(uid, role string) []users.Token {
+	sys.RLock()
+	defer sys.RUnlock()
+
+	return sys.users.Tokens(uid)
+}
+
+// Issues a new API token for the user. The returned bearer token is not stored and cannot be
+// retrieved again.
+func CreateToken(uid, role, name string, scopes []string, expires types.Timestamp) (string, users.Token, error) {
+	sys.Lock()
+	defer sys.Unlock()
+
+	auth := auth.NewAuthorizator(uid, role)
+	dbc := db.NewDBC(sys.trail)
+	shadow := sys.users.Clone()
+
+	token, t, err := shadow.AddToken(auth, uid, name, scopes, expires, dbc)
+	if err != nil {
+		return "", users.Token{}, err
+	}
+
+	if err := shadow.Validate(); err != nil {
+		return "", users.Token{}, err
+	}
+
+	if err := save(TagUsers, &shadow); err != nil {
+		return "", users.Token{}, err
+	}
+
+	dbc.Commit(&sys, func() {
+		sys.users = shadow
+	})
+
+	return token, t, nil
+}
+
+func RevokeToken(uid, role, id string) error {
+	sys.Lock()
+	defer sys.Unlock()
+
+	auth := auth.NewAuthorizator(uid, role)
+	dbc := db.NewDBC(sys.trail)
+	shadow := sys.users.Clone()
+
+	if err := shadow.RevokeToken(auth, uid, id, dbc); err != nil {
+		return err
+	}
+
+	if err := shadow.Validate(); err != nil {
+		return err
+	}
+
+	if err := save(TagUsers, &shadow); err != nil {
+		return err
+	}
+
+	dbc.Commit(&sys, func() {
+		sys.users = shadow
+	})
+
+	return nil
+}
+
+// Returns the WebAuthn user handle and the passkeys registered by the user.
+func Passkeys(uid string) (string, []users.Passkey) {
+	sys.RLock()
+	defer sys.RUnlock()
+
+	return sys.users.Passkeys(uid)
+}
+
+// Returns the UID of the user with the WebAuthn user handle.
+func PasskeyUser(handle string) (string, bool) {
+	sys.RLock()
+	defer sys.RUnlock()
+
+	return sys.users.PasskeyUser(handle)
+}
+
+// Returns the UID of the user mapped to a client certificate identity.
+func CertificateUser(identities []string) (string, bool) {
+	sys.RLock()
+	defer sys.RUnlock()
+
+	return sys.users.CertificateUser(identities)
+}
+
+func AddPasskey(uid, role, handle, id, name string, credential []byte) (users.Passkey, error) {
+	sys.Lock()
+	defer sys.Unlock()
+
+	auth := auth.NewAuthorizator(uid, role)
+	dbc := db.NewDBC(sys.trail)
+	shadow := sys.users.Clone()
+
+	p, err := shadow.AddPasskey(auth, uid, handle, id, name, credential, dbc)
+	if err != nil {
+		return users.Passkey{}, err
+	}
+
+	if err := shadow.Validate(); err != nil {
+		return users.Passkey{}, err
+	}
+
+	if err := save(TagUsers, &shadow); err != nil {
+		return users.Passkey{}, err
+	}
+
+	dbc.Commit(&sys, func() {
+		sys.users = shadow
+	})
+
+	return p, nil
+}
+
+// Updates the stored passkey credential (signature counter and flags) after a passkey login.
+func UsePasskey(uid, id string, credential []byte) error {
+	sys.Lock()
+	defer sys.Unlock()
+
+	dbc := db.NewDBC(sys.trail)
+	shadow := sys.users.Clone()
+
+	if err := shadow.UsePasskey(uid, id, credential); err != nil {
+		return err
+	}
+
+	if err := save(TagUsers, &shadow); err != nil {
+		return err
+	}
+
+	dbc.Commit(&sys, func() {
+		sys.users = shadow
+	})
+
+	return nil
+}
+
+func RevokePasskey(uid, role, id string) error {
+	sys.Lock()
+	defer sys.Unlock()
+
+	auth := auth.NewAuthorizator(uid, role)
+	dbc := db.NewDBC(sys.trail)
+	shadow := sys.users.Clone()
+
+	if err := shadow.RevokePasskey(auth, uid, id, dbc); err != nil {
+		return err
+	}
+
+	if err := shadow.Validate(); err != nil {
+		return err
+	}
+
+	if err := save(TagUsers, &shadow); err != nil {
+		return err
+	}
+
+	dbc.Commit(&sys, func() {
+		sys.users = shadow
+	})
+
+	return nil
+}
+
+// Returns the UID, role and scopes for an API bearer token.
+func AuthenticateToken(token string) (string, string, []string, error) {
+	sys.RLock()
+	defer sys.RUnlock()
+
+	return sys.users.Authenticate(token)
+}
+
 func HasAdmin(role string) bool {
 	return sys.users.HasAdmin(role)
 }
@@ -185,6 +384,90 @@ func MakeAdminUser(name, uid, pwd, role string) error {
 	return nil
 }
 
+// Links (or auto-provisions) the user for an OIDC identity, returning the UID of the linked user.
+func OIDCLogin(subject, uid, name, role string, provision bool) (string, error) {
+	sys.Lock()
+	defer sys.Unlock()
+
+	dbc := db.NewDBC(sys.trail)
+	shadow := sys.users.Clone()
+
+	linked, err := shadow.Provision(subject, uid, name, role, provision, dbc)
+	if err != nil {
+		return "", err
+	}
+
+	if err := shadow.Validate(); err != nil {
+		return "", err
+	}
+
+	if err := save(TagUsers, &shadow); err != nil {
+		return "", err
+	}
+
+	dbc.Commit(&sys, func() {
+		sys.users = shadow
+	})
+
+	return linked, nil
+}
+
+// Links (or creates) the user for a directory login. Local users are only linked if they are
+// fallback users.
+func DirectoryLogin(uid, name, role string, fallback bool) error {
+	sys.Lock()
+	defer sys.Unlock()
+
+	dbc := db.NewDBC(sys.trail)
+	shadow := sys.users.Clone()
+
+	if err := shadow.Link(uid, name, role, fallback, dbc); err != nil {
+		return err
+	}
+
+	if err := shadow.Validate(); err != nil {
+		return err
+	}
+
+	if err := save(TagUsers, &shadow); err != nil {
+		return err
+	}
+
+	dbc.Commit(&sys, func() {
+		sys.users = shadow
+	})
+
+	return nil
+}
+
+// Replaces a legacy (or outdated) password hash with a hash in the configured format after
+// a successful login.
+func RehashPassword(uid, hash, pwd string) error {
+	sys.Lock()
+	defer sys.Unlock()
+
+	dbc := db.NewDBC(sys.trail)
+	shadow := sys.users.Clone()
+
+	if err := shadow.RehashPassword(uid, hash, pwd, dbc); err != nil {
+		return err
+	}
+
+	if err := shadow.Validate(); err != nil {
+		return err
+	}
+
+	if err := save(TagUsers, &shadow); err != nil {
+		return err
+	}
+
+	dbc.Commit(&sys, func() {
+		sys.users = shadow
+	})
+
+	return nil
+}
+
 func UserLogin(uid, role string, err error) {
 	sys.Lock()
 	defer sys.Unlock()
@@ -193,9 +476,22 @@ func UserLogin(uid, role string, err error) {
 	dbc := db.NewDBC(sys.trail)
 	shadow := sys.users.Clone()
 
+	locked := false
+	if u, ok := sys.users.User(uid); ok {
+		locked = u.Locked()
+	}
+
 	shadow.UserLogin(auth, uid, err, dbc)
 
+	if err := save(TagUsers, &shadow); err != nil {
+		warnf("users", "%v", err)
+	}
+
 	dbc.Commit(&sys, func() {
 		sys.users = shadow
 	})
+
+	if u, ok := shadow.User(uid); ok && u.Locked() && !locked {
+		sys.notifications.UserLocked(uid, fmt.Sprintf("%v", u))
+	}
 }
@@ -1,6 +1,8 @@
 package users
 
 import (
+	"slices"
+
 	"github.com/uhppoted/uhppoted-httpd/auth"
 )
 
@@ -17,14 +19,48 @@ func CanView[T TAuthable](a auth.OpAuth, u T, field string, value any) error {
 	return auth.CanView(a, u, field, value, rulesets...)
 }
 
+// A scoped administrator cannot add or delete users and can only update their own password,
+// OTP, passkeys and API tokens (otherwise they could escape their scope).
 func CanAdd[T TAuthable](a auth.OpAuth, u T) error {
+	if auth.ScopeOf(a).IsScoped() {
+		return auth.ErrUnauthorised
+	}
+
 	return auth.CanAdd(a, u, auth.Users)
 }
 
 func CanUpdate[T TAuthable](a auth.OpAuth, u T, field string, value any) error {
+	if auth.ScopeOf(a).IsScoped() && !self(a, u, field) {
+		return auth.ErrUnauthorised
+	}
+
 	return auth.CanUpdate(a, u, field, value, rulesets...)
 }
 
 func CanDelete[T TAuthable](a auth.OpAuth, u T) error {
+	if auth.ScopeOf(a).IsScoped() {
+		return auth.ErrUnauthorised
+	}
+
 	return auth.CanDelete(a, u, rulesets...)
 }
+
+// Returns true if the update is for the authorised user's own credentials or sessions.
+func self[T TAuthable](a auth.OpAuth, u T, field string) bool {
+	if !slices.Contains([]string{"password", "otp", "passkey", "token", "session"}, field) {
+		return false
+	}
+
+	if v, ok := a.(*auth.Authorizator); ok {
+		uid := auth.UID(v)
+
+		switch w := any(u).(type) {
+		case User:
+			return uid != "" && w.uid == uid
+		case *User:
+			return uid != "" && w != nil && w.uid == uid
+		}
+	}
+
+	return false
+}
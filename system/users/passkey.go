@@ -0,0 +1,77 @@
+package users
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/uhppoted/uhppoted-httpd/types"
+)
+
+// Passkey is a WebAuthn credential registered by a user. The credential record (public key,
+// signature counter, flags, etc) is opaque to the users list and is stored as the JSON encoded
+// record returned by the WebAuthn library.
+type Passkey struct {
+	ID         string          `json:"id"`
+	Name       string          `json:"name,omitempty"`
+	Created    types.Timestamp `json:"created"`
+	Used       types.Timestamp `json:"used"`
+	credential json.RawMessage
+}
+
+func (p Passkey) Credential() []byte {
+	return slices.Clone(p.credential)
+}
+
+func (p Passkey) clone() Passkey {
+	return Passkey{
+		ID:         p.ID,
+		Name:       p.Name,
+		Created:    p.Created,
+		Used:       p.Used,
+		credential: slices.Clone(p.credential),
+	}
+}
+
+// Creates a passkey for a verified WebAuthn credential record.
+func NewPasskey(id, name string, credential []byte) (Passkey, error) {
+	if strings.TrimSpace(id) == "" {
+		return Passkey{}, fmt.Errorf("invalid passkey ID")
+	}
+
+	if !json.Valid(credential) {
+		return Passkey{}, fmt.Errorf("invalid passkey credential")
+	}
+
+	return Passkey{
+		ID:         id,
+		Name:       strings.TrimSpace(name),
+		Created:    types.TimestampNow(),
+		credential: slices.Clone(credential),
+	}, nil
+}
+
+// Generates a random WebAuthn user handle. The handle is sent to the authenticator when a passkey
+// is registered and identifies the user for a passwordless login, so it is deliberately not
+// derived from the UID or OID (both of which can be reassigned).
+func NewHandle() (string, error) {
+	handle := make([]byte, 32)
+
+	if _, err := io.ReadFull(rand.Reader, handle); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(handle), nil
+}
+
+type passkeyRecord struct {
+	ID         string          `json:"id"`
+	Name       string          `json:"name,omitempty"`
+	Created    types.Timestamp `json:"created"`
+	Used       types.Timestamp `json:"used"`
+	Credential json.RawMessage `json:"credential"`
+}
@@ -0,0 +1,154 @@
+package users
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/impl"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+	"github.com/uhppoted/uhppoted-httpd/system/db"
+)
+
+func TestPasskeySerialization(t *testing.T) {
+	p, err := NewPasskey("AAECAw", "laptop", []byte(`{"id":"AAECAw","authenticator":{"signCount":7}}`))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	u := User{
+		CatalogUser: catalog.CatalogUser{OID: "0.8.1"},
+		uid:         "moony",
+		handle:      "qwerty",
+		passkeys:    []Passkey{p},
+	}
+
+	bytes, err := u.serialize()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	var v User
+	if err := v.deserialize(bytes); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if v.handle != "qwerty" {
+		t.Errorf("Incorrect WebAuthn user handle - expected:%v, got:%v", "qwerty", v.handle)
+	}
+
+	if len(v.passkeys) != 1 {
+		t.Fatalf("Incorrect number of passkeys - expected:%v, got:%v", 1, len(v.passkeys))
+	}
+
+	if v.passkeys[0].ID != "AAECAw" || v.passkeys[0].Name != "laptop" {
+		t.Errorf("Incorrect passkey - expected:%v/%v, got:%v/%v", "AAECAw", "laptop", v.passkeys[0].ID, v.passkeys[0].Name)
+	}
+
+	if !equalJSON(v.passkeys[0].Credential(), p.Credential()) {
+		t.Errorf("Incorrect passkey credential - expected:%s, got:%s", p.Credential(), v.passkeys[0].Credential())
+	}
+}
+
+func TestAddPasskey(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+
+	if err := auth.Init(nil, "admin"); err != nil {
+		t.Fatalf("Error initialising auth (%v)", err)
+	}
+
+	uu := Users{
+		users: map[schema.OID]*User{
+			"0.8.1": {CatalogUser: catalog.CatalogUser{OID: "0.8.1"}, uid: "moony", role: "admin"},
+			"0.8.2": {CatalogUser: catalog.CatalogUser{OID: "0.8.2"}, uid: "padfoot", role: "user"},
+		},
+	}
+
+	a := auth.NewAuthorizator("moony", "admin")
+	credential := []byte(`{"id":"AAECAw"}`)
+
+	if _, err := uu.AddPasskey(a, "moony", "handle-1", "AAECAw", "laptop", credential, db.DBC{}); err != nil {
+		t.Fatalf("Unexpected error adding passkey (%v)", err)
+	}
+
+	if uid, ok := uu.PasskeyUser("handle-1"); !ok || uid != "moony" {
+		t.Errorf("Incorrect passkey user - expected:%v, got:%v", "moony", uid)
+	}
+
+	if _, err := uu.AddPasskey(a, "moony", "handle-1", "AAECAw", "laptop", credential, db.DBC{}); err == nil {
+		t.Errorf("Expected error adding duplicate passkey")
+	}
+
+	if _, err := uu.AddPasskey(a, "moony", "handle-2", "BAECAw", "phone", credential, db.DBC{}); err == nil {
+		t.Errorf("Expected error adding passkey with a different user handle")
+	}
+
+	if _, err := uu.AddPasskey(a, "padfoot", "handle-1", "BAECAw", "phone", credential, db.DBC{}); err == nil {
+		t.Errorf("Expected error adding passkey with another user's handle")
+	}
+
+	if _, err := uu.AddPasskey(a, "moony", "handle-1", "BAECAw", "phone", []byte("{"), db.DBC{}); err == nil {
+		t.Errorf("Expected error adding passkey with invalid credential")
+	}
+
+	if handle, passkeys := uu.Passkeys("moony"); handle != "handle-1" || len(passkeys) != 1 {
+		t.Errorf("Incorrect passkeys - expected:%v/%v, got:%v/%v", "handle-1", 1, handle, len(passkeys))
+	}
+}
+
+func TestRevokePasskeys(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+
+	if err := auth.Init(nil, "admin"); err != nil {
+		t.Fatalf("Error initialising auth (%v)", err)
+	}
+
+	p1, _ := NewPasskey("AAECAw", "laptop", []byte(`{}`))
+	p2, _ := NewPasskey("BAECAw", "phone", []byte(`{}`))
+
+	uu := Users{
+		users: map[schema.OID]*User{
+			"0.8.1": {CatalogUser: catalog.CatalogUser{OID: "0.8.1"}, uid: "moony", role: "admin", handle: "handle-1", passkeys: []Passkey{p1, p2}},
+		},
+	}
+
+	for _, u := range uu.users {
+		catalog.PutT(u.CatalogUser)
+	}
+
+	a := auth.NewAuthorizator("moony", "admin")
+
+	if err := uu.RevokePasskey(a, "moony", "AAECAw", db.DBC{}); err != nil {
+		t.Fatalf("Unexpected error revoking passkey (%v)", err)
+	}
+
+	if err := uu.RevokePasskey(a, "moony", "AAECAw", db.DBC{}); err == nil {
+		t.Errorf("Expected error revoking unknown passkey")
+	}
+
+	if _, passkeys := uu.Passkeys("moony"); len(passkeys) != 1 || passkeys[0].ID != "BAECAw" {
+		t.Errorf("Incorrect passkeys after revoke - expected:%v, got:%v", []string{"BAECAw"}, passkeys)
+	}
+
+	if _, err := uu.Update(a, "0.8.1.8", "false", db.DBC{}); err != nil {
+		t.Fatalf("Unexpected error revoking all passkeys (%v)", err)
+	}
+
+	if _, passkeys := uu.Passkeys("moony"); len(passkeys) != 0 {
+		t.Errorf("Incorrect passkeys after revoke - expected:%v, got:%v", 0, len(passkeys))
+	}
+}
+
+func equalJSON(p, q []byte) bool {
+	var b1, b2 bytes.Buffer
+
+	if err := json.Compact(&b1, p); err != nil {
+		return false
+	} else if err := json.Compact(&b2, q); err != nil {
+		return false
+	}
+
+	return bytes.Equal(b1.Bytes(), b2.Bytes())
+}
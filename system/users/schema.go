@@ -15,6 +15,13 @@ const UserPassword = schema.UserPassword
 const UserOTP = schema.UserOTP
 const UserOTPKey = schema.UserOTPKey
 const UserLocked = schema.UserLocked
+const UserScope = schema.UserScope
+const UserScopeGroups = schema.UserScopeGroups
+const UserScopeDoors = schema.UserScopeDoors
+const UserScopeControllers = schema.UserScopeControllers
+const UserScopeUngrouped = schema.UserScopeUngrouped
+const UserPasskeys = schema.UserPasskeys
+const UserCertificates = schema.UserCertificates
 
 var lookup = map[schema.Suffix]string{
 	UserStatus:   "user.status",
@@ -27,4 +34,13 @@ var lookup = map[schema.Suffix]string{
 	UserPassword: "user.password",
 	UserOTP:      "user.otp",
 	UserLocked:   "user.locked",
+	UserPasskeys: "user.passkeys",
+
+	UserCertificates: "user.certificates",
+
+	UserScope:            "user.scope",
+	UserScopeGroups:      "user.scope.groups",
+	UserScopeDoors:       "user.scope.doors",
+	UserScopeControllers: "user.scope.controllers",
+	UserScopeUngrouped:   "user.scope.ungrouped",
 }
@@ -0,0 +1,157 @@
+package users
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/uhppoted/uhppoted-httpd/types"
+)
+
+// Token is an API bearer token issued to a user for machine clients. Only the SHA-256 hash
+// of the token secret is stored - the token itself is returned once when it is created.
+type Token struct {
+	ID      string          `json:"id"`
+	Name    string          `json:"name,omitempty"`
+	Scopes  []string        `json:"scopes"`
+	Created types.Timestamp `json:"created"`
+	Expires types.Timestamp `json:"expires"`
+	hash    string
+}
+
+const tokenPrefix = "uhppoted_"
+
+// Resources that can be granted to an API token as <resource>:<access> scopes, where access
+// is 'read' (GET) or 'write' (POST and DELETE). '*' matches any resource or access e.g.
+// cards:read, cards:write, cards:*, *:read or *.
+var resources = []string{
+	"interfaces",
+	"controllers",
+	"doors",
+	"cards",
+	"groups",
+	"time-profiles",
+	"tasks",
+	"events",
+	"logs",
+	"users",
+	"sessions",
+	"synchronize",
+	"stream",
+}
+
+func validateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("API token requires at least one scope")
+	}
+
+	for _, scope := range scopes {
+		if scope == "*" {
+			continue
+		}
+
+		resource, access, ok := strings.Cut(scope, ":")
+		if !ok {
+			return fmt.Errorf("invalid API token scope '%v'", scope)
+		}
+
+		if resource != "*" && !slices.Contains(resources, resource) {
+			return fmt.Errorf("invalid API token scope '%v' (unknown resource)", scope)
+		}
+
+		if access != "*" && access != "read" && access != "write" {
+			return fmt.Errorf("invalid API token scope '%v' (expected read or write access)", scope)
+		}
+	}
+
+	return nil
+}
+
+func (t Token) Expired(now time.Time) bool {
+	return !t.Expires.IsZero() && !now.Before(time.Time(t.Expires))
+}
+
+func (t Token) clone() Token {
+	return Token{
+		ID:      t.ID,
+		Name:    t.Name,
+		Scopes:  slices.Clone(t.Scopes),
+		Created: t.Created,
+		Expires: t.Expires,
+		hash:    t.hash,
+	}
+}
+
+func (t Token) verify(secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(t.hash), []byte(hash(secret))) == 1
+}
+
+// Generates a new token with a random ID and secret. The bearer token is returned as
+// uhppoted_<id>.<secret>.
+func newToken(name string, scopes []string, expires types.Timestamp) (string, Token, error) {
+	if err := validateScopes(scopes); err != nil {
+		return "", Token{}, err
+	}
+
+	if !expires.IsZero() && !time.Now().Before(time.Time(expires)) {
+		return "", Token{}, fmt.Errorf("API token expiry %v is in the past", expires)
+	}
+
+	id := make([]byte, 8)
+	secret := make([]byte, 32)
+
+	if _, err := io.ReadFull(rand.Reader, id); err != nil {
+		return "", Token{}, err
+	}
+
+	if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+		return "", Token{}, err
+	}
+
+	t := Token{
+		ID:      hex.EncodeToString(id),
+		Name:    strings.TrimSpace(name),
+		Scopes:  slices.Clone(scopes),
+		Created: types.TimestampNow(),
+		Expires: expires,
+		hash:    hash(base64.RawURLEncoding.EncodeToString(secret)),
+	}
+
+	return fmt.Sprintf("%v%v.%v", tokenPrefix, t.ID, base64.RawURLEncoding.EncodeToString(secret)), t, nil
+}
+
+// Splits a bearer token into the token ID and secret.
+func parseToken(token string) (string, string, error) {
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return "", "", fmt.Errorf("invalid API token")
+	}
+
+	id, secret, ok := strings.Cut(strings.TrimPrefix(token, tokenPrefix), ".")
+	if !ok || id == "" || secret == "" {
+		return "", "", fmt.Errorf("invalid API token")
+	}
+
+	return id, secret, nil
+}
+
+func hash(secret string) string {
+	h := sha256.Sum256([]byte(secret))
+
+	return hex.EncodeToString(h[:])
+}
+
+type tokenRecord struct {
+	ID      string          `json:"id"`
+	Name    string          `json:"name,omitempty"`
+	Scopes  []string        `json:"scopes"`
+	Created types.Timestamp `json:"created"`
+	Expires types.Timestamp `json:"expires"`
+	Hash    string          `json:"hash"`
+}
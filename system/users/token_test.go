@@ -0,0 +1,137 @@
+package users
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/uhppoted/uhppoted-httpd/system/catalog"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+	"github.com/uhppoted/uhppoted-httpd/types"
+)
+
+func TestAuthenticateToken(t *testing.T) {
+	token, tk, err := newToken("HR", []string{"cards:read", "cards:write"}, types.Timestamp{})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	uu := Users{
+		users: map[schema.OID]*User{
+			"0.8.1": &User{
+				CatalogUser: catalog.CatalogUser{OID: "0.8.1"},
+				uid:         "hr",
+				role:        "user",
+				tokens:      []Token{tk},
+			},
+		},
+	}
+
+	uid, role, scopes, err := uu.Authenticate(token)
+	if err != nil {
+		t.Fatalf("Error authenticating API token (%v)", err)
+	}
+
+	if uid != "hr" || role != "user" {
+		t.Errorf("Incorrect user - expected:%v/%v, got:%v/%v", "hr", "user", uid, role)
+	}
+
+	if !slices.Equal(scopes, []string{"cards:read", "cards:write"}) {
+		t.Errorf("Incorrect scopes - expected:%v, got:%v", []string{"cards:read", "cards:write"}, scopes)
+	}
+
+	if _, _, _, err := uu.Authenticate(token + "x"); err == nil {
+		t.Errorf("Expected error authenticating API token with invalid secret")
+	}
+
+	uu.users["0.8.1"].locked = true
+	if _, _, _, err := uu.Authenticate(token); err == nil {
+		t.Errorf("Expected error authenticating API token for locked user")
+	}
+}
+
+func TestAuthenticateExpiredToken(t *testing.T) {
+	token, tk, err := newToken("HR", []string{"*"}, types.Timestamp(time.Now().Add(time.Hour)))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	tk.Expires = types.Timestamp(time.Now().Add(-time.Minute))
+
+	uu := Users{
+		users: map[schema.OID]*User{
+			"0.8.1": &User{
+				CatalogUser: catalog.CatalogUser{OID: "0.8.1"},
+				uid:         "hr",
+				tokens:      []Token{tk},
+			},
+		},
+	}
+
+	if _, _, _, err := uu.Authenticate(token); err == nil {
+		t.Errorf("Expected error authenticating expired API token")
+	}
+}
+
+func TestTokenSerialization(t *testing.T) {
+	token, tk, err := newToken("HR", []string{"cards:*"}, types.Timestamp{})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	u := User{
+		CatalogUser: catalog.CatalogUser{OID: "0.8.1"},
+		uid:         "hr",
+		tokens:      []Token{tk},
+	}
+
+	bytes, err := u.serialize()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	var v User
+	if err := v.deserialize(bytes); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if len(v.tokens) != 1 {
+		t.Fatalf("Incorrect number of tokens - expected:%v, got:%v", 1, len(v.tokens))
+	}
+
+	if _, secret, err := parseToken(token); err != nil {
+		t.Fatalf("%v", err)
+	} else if !v.tokens[0].verify(secret) {
+		t.Errorf("Deserialized token failed verification")
+	}
+}
+
+func TestValidateScopes(t *testing.T) {
+	valid := [][]string{
+		{"*"},
+		{"cards:read"},
+		{"cards:write", "groups:read"},
+		{"*:read"},
+		{"time-profiles:*"},
+	}
+
+	invalid := [][]string{
+		{},
+		{"cards"},
+		{"cards:delete"},
+		{"tokens:read"},
+		{"sys:read"},
+	}
+
+	for _, scopes := range valid {
+		if err := validateScopes(scopes); err != nil {
+			t.Errorf("Unexpected error validating scopes %v (%v)", scopes, err)
+		}
+	}
+
+	for _, scopes := range invalid {
+		if err := validateScopes(scopes); err == nil {
+			t.Errorf("Expected error validating scopes %v", scopes)
+		}
+	}
+}
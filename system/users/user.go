@@ -1,34 +1,55 @@
 package users
 
 import (
-	"crypto/rand"
-	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/auth/passwords"
 	"github.com/uhppoted/uhppoted-httpd/system/catalog"
 	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
 	"github.com/uhppoted/uhppoted-httpd/system/db"
 	"github.com/uhppoted/uhppoted-httpd/types"
 )
 
-const MaxFailed uint32 = 5
+// Number of consecutive failed logins after which an account is locked (0 disables lockout) and
+// the time after which a locked account is automatically unlocked (0 requires an administrator
+// to unlock the account).
+var lockout = struct {
+	attempts uint32
+	cooldown time.Duration
+}{
+	attempts: 5,
+	cooldown: 0,
+}
+
+func SetLockout(attempts uint32, cooldown time.Duration) {
+	lockout.attempts = attempts
+	lockout.cooldown = cooldown
+}
 
 type User struct {
 	catalog.CatalogUser
-	name     string
-	uid      string
-	role     string
-	salt     []byte
-	password string
-	otp      string
-	locked   bool
-	failed   uint32
+	name      string
+	uid       string
+	role      string
+	salt      []byte
+	password  string
+	otp       string
+	locked    bool
+	lockedAt  types.Timestamp
+	failed    uint32
+	tokens    []Token
+	scope     *auth.Scope
+	subject   string // OIDC identity provider subject
+	directory bool   // managed by the directory (LDAP) login
+	handle    string // WebAuthn user handle
+	passkeys  []Passkey
+	certs     []string // client certificate identities
 
 	created  types.Timestamp
 	deleted  types.Timestamp
@@ -74,9 +95,27 @@ func (u User) Role() string {
 }
 
 func (u User) Locked() bool {
+	if u.locked && lockout.cooldown > 0 && !u.lockedAt.IsZero() {
+		return time.Now().Before(time.Time(u.lockedAt).Add(lockout.cooldown))
+	}
+
 	return u.locked
 }
 
+// Returns a copy of the user scope, or nil if the user is not scoped.
+func (u User) Scope() *auth.Scope {
+	if u.scope == nil {
+		return nil
+	}
+
+	return &auth.Scope{
+		Groups:      slices.Clone(u.scope.Groups),
+		Doors:       slices.Clone(u.scope.Doors),
+		Controllers: slices.Clone(u.scope.Controllers),
+		Ungrouped:   u.scope.Ungrouped,
+	}
+}
+
 func (u User) String() string {
 	name := strings.TrimSpace(u.name)
 	if name != "" {
@@ -105,7 +144,17 @@ func (u User) AsObjects(a *auth.Authorizator) []schema.Object {
 		list = append(list, kv{UserRole, u.role})
 		list = append(list, kv{UserPassword, ""})
 		list = append(list, kv{UserOTP, u.otp != ""})
-		list = append(list, kv{UserLocked, u.locked})
+		list = append(list, kv{UserLocked, u.Locked()})
+		list = append(list, kv{UserPasskeys, len(u.passkeys) > 0})
+		list = append(list, kv{UserCertificates, strings.Join(u.certs, "; ")})
+		list = append(list, kv{UserScope, u.scope != nil})
+
+		if u.scope != nil {
+			list = append(list, kv{UserScopeGroups, strings.Join(u.scope.Groups, ",")})
+			list = append(list, kv{UserScopeDoors, strings.Join(u.scope.Doors, ",")})
+			list = append(list, kv{UserScopeControllers, strings.Join(u.scope.Controllers, ",")})
+			list = append(list, kv{UserScopeUngrouped, u.scope.Ungrouped})
+		}
 	}
 
 	return u.toObjects(list, a)
@@ -214,6 +263,33 @@ func (u *User) set(a *auth.Authorizator, oid schema.OID, value string, dbc db.DB
 			list = append(list, kv{UserOTP, u.otp != ""})
 		}
 
+	// ... 'revoke only' from UI
+	case oid == u.OID.Append(UserPasskeys):
+		if err := CanUpdate(a, u, "passkey", value); err != nil {
+			return nil, err
+		} else if value == "false" && len(u.passkeys) > 0 {
+			u.log(dbc, uid, "update", "passkey", len(u.passkeys), 0, "Revoked %v passkey(s) for %v (%v)", len(u.passkeys), u.uid, u.name)
+			u.passkeys = []Passkey{}
+			u.modified = types.TimestampNow()
+		}
+
+		list = append(list, kv{UserPasskeys, len(u.passkeys) > 0})
+
+	case oid == u.OID.Append(UserCertificates):
+		if err := CanUpdate(a, u, "certificates", value); err != nil {
+			return nil, err
+		} else {
+			before := strings.Join(u.certs, "; ")
+
+			u.certs = certificates(value)
+			u.modified = types.TimestampNow()
+			after := strings.Join(u.certs, "; ")
+
+			u.log(dbc, uid, "update", "certificates", before, after, "Updated certificates from [%v] to [%v]", before, after)
+		}
+
+		list = append(list, kv{UserCertificates, strings.Join(u.certs, "; ")})
+
 	// ... 'unlock only' from UI
 	case oid == u.OID.Append(UserLocked):
 		if err := CanUpdate(a, u, "locked", value); err != nil {
@@ -221,11 +297,70 @@ func (u *User) set(a *auth.Authorizator, oid schema.OID, value string, dbc db.DB
 		} else if value == "false" {
 			u.failed = 0
 			u.locked = false
+			u.lockedAt = types.Timestamp{}
 			u.modified = types.TimestampNow()
 			u.log(dbc, uid, "update", "locked", "locked", "unlocked", "Unlocked account for %v (%v)", u.uid, u.name)
 		}
 
-		list = append(list, kv{UserLocked, u.locked})
+		list = append(list, kv{UserLocked, u.Locked()})
+
+	case oid == u.OID.Append(UserScope):
+		if err := CanUpdate(a, u, "scope", value); err != nil {
+			return nil, err
+		} else if value == "true" && u.scope == nil {
+			u.scope = &auth.Scope{}
+			u.modified = types.TimestampNow()
+			u.log(dbc, uid, "update", "scope", "", "scoped", "Restricted %v (%v) to scope", u.uid, u.name)
+		} else if value == "false" && u.scope != nil {
+			u.scope = nil
+			u.modified = types.TimestampNow()
+			u.log(dbc, uid, "update", "scope", "scoped", "", "Removed scope for %v (%v)", u.uid, u.name)
+		}
+
+		list = append(list, kv{UserScope, u.scope != nil})
+
+	case oid == u.OID.Append(UserScopeGroups) || oid == u.OID.Append(UserScopeDoors) || oid == u.OID.Append(UserScopeControllers):
+		suffix := schema.Suffix(strings.TrimPrefix(string(oid), string(u.OID)))
+		field := strings.TrimPrefix(lookup[suffix], "user.")
+
+		if err := CanUpdate(a, u, field, value); err != nil {
+			return nil, err
+		} else {
+			if u.scope == nil {
+				u.scope = &auth.Scope{}
+			}
+
+			v := scoped(u.scope, suffix)
+			before := strings.Join(*v, ",")
+
+			*v = oids(value)
+			u.modified = types.TimestampNow()
+			after := strings.Join(*v, ",")
+
+			u.log(dbc, uid, "update", field, before, after, "Updated %v from [%v] to [%v]", strings.ReplaceAll(field, ".", " "), before, after)
+		}
+
+		list = append(list, kv{UserScope, u.scope != nil})
+		list = append(list, kv{suffix, strings.Join(*scoped(u.scope, suffix), ",")})
+
+	case oid == u.OID.Append(UserScopeUngrouped):
+		if err := CanUpdate(a, u, "scope.ungrouped", value); err != nil {
+			return nil, err
+		} else {
+			if u.scope == nil {
+				u.scope = &auth.Scope{}
+			}
+
+			before := u.scope.Ungrouped
+
+			u.scope.Ungrouped = value == "true"
+			u.modified = types.TimestampNow()
+
+			u.log(dbc, uid, "update", "scope.ungrouped", before, u.scope.Ungrouped, "Updated scope ungrouped from %v to %v", before, u.scope.Ungrouped)
+		}
+
+		list = append(list, kv{UserScope, u.scope != nil})
+		list = append(list, kv{UserScopeUngrouped, u.scope.Ungrouped})
 	}
 
 	list = append(list, kv{UserStatus, u.Status()})
@@ -287,19 +422,55 @@ func (u User) Status() types.Status {
 	return types.StatusOk
 }
 
+// Updates the failed login count and account lockout after a login attempt. An account that
+// has been locked for longer than the lockout cooldown is unlocked before the attempt is
+// recorded.
 func (u *User) login(err error, dbc db.DBC) {
-	if err != nil {
+	if u.locked && !u.Locked() {
+		u.failed = 0
+		u.locked = false
+		u.lockedAt = types.Timestamp{}
+		u.log(dbc, u.uid, "update", "locked", "locked", "unlocked", "Lockout cooldown expired")
+	}
+
+	switch {
+	case u.locked:
+		u.log(dbc, u.uid, "login", "locked", "", "", "Login attempt for locked account (%v)", err)
+
+	case err != nil:
 		u.failed++
+		u.log(dbc, u.uid, "login", "failed", "", u.failed, "Failed login (%v)", err)
 
-		if u.failed >= MaxFailed && !u.locked {
+		if lockout.attempts > 0 && u.failed >= lockout.attempts {
 			u.locked = true
-			u.log(dbc, u.uid, "update", "user", u.name, "locked", "Too many failed logins")
+			u.lockedAt = types.TimestampNow()
+			u.log(dbc, u.uid, "update", "locked", "unlocked", "locked", "Too many failed logins")
 		}
-	} else {
+
+	default:
 		u.failed = 0
 	}
 }
 
+// Replaces a password hash that is no longer in the configured format, provided the stored
+// hash has not been changed since the password was verified.
+func (u *User) rehash(hash, pwd string, dbc db.DBC) error {
+	if u.password != hash {
+		return fmt.Errorf("%v: password changed", u.uid)
+	}
+
+	if salt, hash, err := password(pwd); err != nil {
+		return err
+	} else {
+		u.salt = salt
+		u.password = hash
+
+		u.log(dbc, u.uid, "update", "password", "", "", "Upgraded password hash")
+	}
+
+	return nil
+}
+
 func (u *User) setOTP(a *auth.Authorizator, key string, dbc db.DBC) ([]schema.Object, error) {
 	uid := auth.UID(a)
 	list := []kv{}
@@ -324,27 +495,190 @@ func (u *User) setOTP(a *auth.Authorizator, key string, dbc db.DBC) ([]schema.Ob
 	return u.toObjects(list, a), nil
 }
 
+func (u *User) addToken(a *auth.Authorizator, name string, scopes []string, expires types.Timestamp, dbc db.DBC) (string, Token, error) {
+	uid := auth.UID(a)
+
+	if err := CanUpdate(a, u, "token", name); err != nil {
+		return "", Token{}, err
+	}
+
+	token, t, err := newToken(name, scopes, expires)
+	if err != nil {
+		return "", Token{}, err
+	}
+
+	u.tokens = append(u.tokens, t)
+	u.modified = types.TimestampNow()
+
+	if t.Expires.IsZero() {
+		u.log(dbc, uid, "update", "token", "", t.ID, "Issued API token %v (%v) with scopes %v", t.ID, t.Name, strings.Join(t.Scopes, ","))
+	} else {
+		u.log(dbc, uid, "update", "token", "", t.ID, "Issued API token %v (%v) with scopes %v, expiring %v", t.ID, t.Name, strings.Join(t.Scopes, ","), t.Expires)
+	}
+
+	return token, t, nil
+}
+
+func (u *User) revokeToken(a *auth.Authorizator, id string, dbc db.DBC) error {
+	uid := auth.UID(a)
+
+	ix := slices.IndexFunc(u.tokens, func(t Token) bool { return t.ID == id })
+	if ix < 0 {
+		return fmt.Errorf("no API token with ID %v", id)
+	}
+
+	if err := CanUpdate(a, u, "token", id); err != nil {
+		return err
+	}
+
+	t := u.tokens[ix]
+	u.tokens = slices.Delete(u.tokens, ix, ix+1)
+	u.modified = types.TimestampNow()
+
+	u.log(dbc, uid, "update", "token", t.ID, "", "Revoked API token %v (%v)", t.ID, t.Name)
+
+	return nil
+}
+
+func (u *User) addPasskey(a *auth.Authorizator, handle, id, name string, credential []byte, dbc db.DBC) (Passkey, error) {
+	uid := auth.UID(a)
+
+	if err := CanUpdate(a, u, "passkey", name); err != nil {
+		return Passkey{}, err
+	}
+
+	if u.handle != "" && u.handle != handle {
+		return Passkey{}, fmt.Errorf("invalid WebAuthn user handle for %v", u.uid)
+	}
+
+	if slices.ContainsFunc(u.passkeys, func(p Passkey) bool { return p.ID == id }) {
+		return Passkey{}, fmt.Errorf("passkey already registered")
+	}
+
+	p, err := NewPasskey(id, name, credential)
+	if err != nil {
+		return Passkey{}, err
+	}
+
+	u.handle = handle
+	u.passkeys = append(u.passkeys, p)
+	u.modified = types.TimestampNow()
+
+	u.log(dbc, uid, "update", "passkey", "", p.Name, "Registered passkey %v", p.Name)
+
+	return p.clone(), nil
+}
+
+// Updates the stored credential record (signature counter, flags) after a successful login.
+func (u *User) usePasskey(id string, credential []byte) error {
+	ix := slices.IndexFunc(u.passkeys, func(p Passkey) bool { return p.ID == id })
+	if ix < 0 {
+		return fmt.Errorf("no passkey with ID %v", id)
+	}
+
+	if !json.Valid(credential) {
+		return fmt.Errorf("invalid passkey credential")
+	}
+
+	u.passkeys[ix].credential = slices.Clone(credential)
+	u.passkeys[ix].Used = types.TimestampNow()
+
+	return nil
+}
+
+func (u *User) revokePasskey(a *auth.Authorizator, id string, dbc db.DBC) error {
+	uid := auth.UID(a)
+
+	ix := slices.IndexFunc(u.passkeys, func(p Passkey) bool { return p.ID == id })
+	if ix < 0 {
+		return fmt.Errorf("no passkey with ID %v", id)
+	}
+
+	if err := CanUpdate(a, u, "passkey", id); err != nil {
+		return err
+	}
+
+	p := u.passkeys[ix]
+	u.passkeys = slices.Delete(u.passkeys, ix, ix+1)
+	u.modified = types.TimestampNow()
+
+	u.log(dbc, uid, "update", "passkey", p.Name, "", "Revoked passkey %v", p.Name)
+
+	return nil
+}
+
 func (u User) serialize() ([]byte, error) {
 	record := struct {
-		OID      schema.OID      `json:"OID"`
-		Name     string          `json:"name,omitempty"`
-		UID      string          `json:"uid,omitempty"`
-		Role     string          `json:"role,omitempty"`
-		Salt     string          `json:"salt"`
-		Password string          `json:"password"`
-		OTP      string          `json:"otp,omitempty"`
-		Created  types.Timestamp `json:"created"`
-		Modified types.Timestamp `json:"modified"`
+		OID       schema.OID       `json:"OID"`
+		Name      string           `json:"name,omitempty"`
+		UID       string           `json:"uid,omitempty"`
+		Role      string           `json:"role,omitempty"`
+		Salt      string           `json:"salt"`
+		Password  string           `json:"password"`
+		OTP       string           `json:"otp,omitempty"`
+		Tokens    []tokenRecord    `json:"tokens,omitempty"`
+		Scope     *scopeRecord     `json:"scope,omitempty"`
+		Subject   string           `json:"oidc-subject,omitempty"`
+		Directory bool             `json:"directory,omitempty"`
+		Handle    string           `json:"webauthn-handle,omitempty"`
+		Passkeys  []passkeyRecord  `json:"passkeys,omitempty"`
+		Certs     []string         `json:"certificates,omitempty"`
+		Failed    uint32           `json:"failed-logins,omitempty"`
+		Locked    *types.Timestamp `json:"locked,omitempty"`
+		Created   types.Timestamp  `json:"created"`
+		Modified  types.Timestamp  `json:"modified"`
 	}{
-		OID:      u.OID,
-		Name:     strings.TrimSpace(u.name),
-		UID:      strings.TrimSpace(u.uid),
-		Role:     strings.TrimSpace(u.role),
-		Salt:     hex.EncodeToString(u.salt[:]),
-		Password: u.password,
-		OTP:      u.otp,
-		Created:  u.created.UTC(),
-		Modified: u.modified.UTC(),
+		OID:       u.OID,
+		Name:      strings.TrimSpace(u.name),
+		UID:       strings.TrimSpace(u.uid),
+		Role:      strings.TrimSpace(u.role),
+		Salt:      hex.EncodeToString(u.salt[:]),
+		Password:  u.password,
+		OTP:       u.otp,
+		Tokens:    []tokenRecord{},
+		Subject:   u.subject,
+		Directory: u.directory,
+		Handle:    u.handle,
+		Passkeys:  []passkeyRecord{},
+		Certs:     u.certs,
+		Failed:    u.failed,
+		Created:   u.created.UTC(),
+		Modified:  u.modified.UTC(),
+	}
+
+	if u.locked {
+		locked := u.lockedAt.UTC()
+		record.Locked = &locked
+	}
+
+	if u.scope != nil {
+		record.Scope = &scopeRecord{
+			Groups:      u.scope.Groups,
+			Doors:       u.scope.Doors,
+			Controllers: u.scope.Controllers,
+			Ungrouped:   u.scope.Ungrouped,
+		}
+	}
+
+	for _, t := range u.tokens {
+		record.Tokens = append(record.Tokens, tokenRecord{
+			ID:      t.ID,
+			Name:    t.Name,
+			Scopes:  t.Scopes,
+			Created: t.Created.UTC(),
+			Expires: t.Expires.UTC(),
+			Hash:    t.hash,
+		})
+	}
+
+	for _, p := range u.passkeys {
+		record.Passkeys = append(record.Passkeys, passkeyRecord{
+			ID:         p.ID,
+			Name:       p.Name,
+			Created:    p.Created.UTC(),
+			Used:       p.Used.UTC(),
+			Credential: p.credential,
+		})
 	}
 
 	return json.Marshal(record)
@@ -354,15 +688,24 @@ func (u *User) deserialize(bytes []byte) error {
 	created = created.Add(1 * time.Minute)
 
 	record := struct {
-		OID      schema.OID      `json:"OID"`
-		Name     string          `json:"name,omitempty"`
-		UID      string          `json:"uid,omitempty"`
-		Role     string          `json:"role,omitempty"`
-		Salt     string          `json:"salt"`
-		Password string          `json:"password"`
-		OTP      string          `json:"otp,omitempty"`
-		Created  types.Timestamp `json:"created"`
-		Modified types.Timestamp `json:"modified"`
+		OID       schema.OID       `json:"OID"`
+		Name      string           `json:"name,omitempty"`
+		UID       string           `json:"uid,omitempty"`
+		Role      string           `json:"role,omitempty"`
+		Salt      string           `json:"salt"`
+		Password  string           `json:"password"`
+		OTP       string           `json:"otp,omitempty"`
+		Tokens    []tokenRecord    `json:"tokens,omitempty"`
+		Scope     *scopeRecord     `json:"scope,omitempty"`
+		Subject   string           `json:"oidc-subject,omitempty"`
+		Directory bool             `json:"directory,omitempty"`
+		Handle    string           `json:"webauthn-handle,omitempty"`
+		Passkeys  []passkeyRecord  `json:"passkeys,omitempty"`
+		Certs     []string         `json:"certificates,omitempty"`
+		Failed    uint32           `json:"failed-logins,omitempty"`
+		Locked    *types.Timestamp `json:"locked,omitempty"`
+		Created   types.Timestamp  `json:"created"`
+		Modified  types.Timestamp  `json:"modified"`
 	}{
 		Created:  created,
 		Modified: types.TimestampNow(),
@@ -384,9 +727,59 @@ func (u *User) deserialize(bytes []byte) error {
 	u.salt = salt
 	u.password = record.Password
 	u.otp = record.OTP
+	u.tokens = []Token{}
+	u.scope = nil
+	u.subject = record.Subject
+	u.directory = record.Directory
+	u.handle = record.Handle
+	u.passkeys = []Passkey{}
+	u.certs = []string{}
+	u.failed = record.Failed
+	u.locked = record.Locked != nil
+	u.lockedAt = types.Timestamp{}
 	u.created = record.Created
 	u.modified = record.Modified
 
+	if record.Locked != nil {
+		u.lockedAt = *record.Locked
+	}
+
+	for _, t := range record.Tokens {
+		u.tokens = append(u.tokens, Token{
+			ID:      t.ID,
+			Name:    t.Name,
+			Scopes:  t.Scopes,
+			Created: t.Created,
+			Expires: t.Expires,
+			hash:    t.Hash,
+		})
+	}
+
+	for _, p := range record.Passkeys {
+		u.passkeys = append(u.passkeys, Passkey{
+			ID:         p.ID,
+			Name:       p.Name,
+			Created:    p.Created,
+			Used:       p.Used,
+			credential: p.Credential,
+		})
+	}
+
+	for _, c := range record.Certs {
+		if v := strings.TrimSpace(c); v != "" {
+			u.certs = append(u.certs, v)
+		}
+	}
+
+	if record.Scope != nil {
+		u.scope = &auth.Scope{
+			Groups:      oids(strings.Join(record.Scope.Groups, ",")),
+			Doors:       oids(strings.Join(record.Scope.Doors, ",")),
+			Controllers: oids(strings.Join(record.Scope.Controllers, ",")),
+			Ungrouped:   record.Scope.Ungrouped,
+		}
+	}
+
 	return nil
 }
 
@@ -395,14 +788,22 @@ func (u User) clone() *User {
 		CatalogUser: catalog.CatalogUser{
 			OID: u.OID,
 		},
-		name:     u.name,
-		uid:      u.uid,
-		role:     u.role,
-		salt:     make([]byte, len(u.salt)),
-		password: u.password,
-		otp:      u.otp,
-		locked:   u.locked,
-		failed:   u.failed,
+		name:      u.name,
+		uid:       u.uid,
+		role:      u.role,
+		salt:      make([]byte, len(u.salt)),
+		password:  u.password,
+		otp:       u.otp,
+		locked:    u.locked,
+		lockedAt:  u.lockedAt,
+		failed:    u.failed,
+		tokens:    make([]Token, 0, len(u.tokens)),
+		scope:     u.Scope(),
+		subject:   u.subject,
+		directory: u.directory,
+		handle:    u.handle,
+		passkeys:  make([]Passkey, 0, len(u.passkeys)),
+		certs:     slices.Clone(u.certs),
 
 		created: u.created,
 		deleted: u.deleted,
@@ -410,25 +811,80 @@ func (u User) clone() *User {
 
 	copy(replicant.salt, u.salt)
 
+	for _, t := range u.tokens {
+		replicant.tokens = append(replicant.tokens, t.clone())
+	}
+
+	for _, p := range u.passkeys {
+		replicant.passkeys = append(replicant.passkeys, p.clone())
+	}
+
 	return &replicant
 }
 
+// Returns a pointer to the scope OID list for a scope suffix.
+func scoped(s *auth.Scope, suffix schema.Suffix) *[]string {
+	switch suffix {
+	case UserScopeDoors:
+		return &s.Doors
+
+	case UserScopeControllers:
+		return &s.Controllers
+
+	default:
+		return &s.Groups
+	}
+}
+
+// Parses a comma separated list of OIDs into a sorted list of unique OIDs.
+func oids(value string) []string {
+	list := []string{}
+
+	for v := range strings.SplitSeq(value, ",") {
+		if oid := strings.TrimSpace(v); oid != "" && !slices.Contains(list, oid) {
+			list = append(list, oid)
+		}
+	}
+
+	slices.SortFunc(list, func(p, q string) int {
+		return schema.OID(p).Compare(schema.OID(q))
+	})
+
+	return list
+}
+
+// Parses a list of client certificate identities separated by semicolons (certificate subjects
+// are themselves comma separated).
+func certificates(value string) []string {
+	list := []string{}
+
+	for v := range strings.SplitSeq(value, ";") {
+		if c := strings.TrimSpace(v); c != "" && !slices.ContainsFunc(list, func(p string) bool { return strings.EqualFold(p, c) }) {
+			list = append(list, c)
+		}
+	}
+
+	return list
+}
+
+type scopeRecord struct {
+	Groups      []string `json:"groups"`
+	Doors       []string `json:"doors"`
+	Controllers []string `json:"controllers"`
+	Ungrouped   bool     `json:"ungrouped,omitempty"`
+}
+
 func (u User) log(dbc db.DBC, uid, op string, field string, before, after any, format string, fields ...any) {
 	dbc.Log(uid, op, u.OID, "user", u.uid, u.name, field, before, after, format, fields...)
 }
 
+// Hashes a new password using the configured algorithm. The hash is self-describing so the
+// separate salt is only retained for legacy SHA-256 hashes.
 func password(pwd string) ([]byte, string, error) {
-	salt := make([]byte, 16)
-
-	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+	hash, err := passwords.Hash(pwd)
+	if err != nil {
 		return nil, "", err
 	}
 
-	h := sha256.New()
-	h.Write(salt)
-	h.Write([]byte(pwd))
-
-	hash := fmt.Sprintf("%0x", h.Sum(nil))
-
-	return salt, hash, nil
+	return nil, hash, nil
 }
@@ -3,6 +3,7 @@ package users
 import (
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -236,6 +237,23 @@ func (uu *Users) SetPassword(a *auth.Authorizator, uid, pwd string, dbc db.DBC)
 	return []schema.Object{}, nil
 }
 
+func (uu *Users) RehashPassword(uid, hash, pwd string, dbc db.DBC) error {
+	if uu != nil {
+		for k, u := range uu.users {
+			if u.uid == uid && !u.IsDeleted() {
+				err := u.rehash(hash, pwd, dbc)
+				if err == nil {
+					uu.users[k] = u
+				}
+
+				return err
+			}
+		}
+	}
+
+	return fmt.Errorf("invalid user %v", uid)
+}
+
 func (uu *Users) SetOTP(a *auth.Authorizator, uid, secret string, dbc db.DBC) ([]schema.Object, error) {
 	if uu == nil {
 		return nil, nil
@@ -274,15 +292,361 @@ func (uu *Users) RevokeOTP(a *auth.Authorizator, uid string, dbc db.DBC) ([]sche
 	return []schema.Object{}, nil
 }
 
+func (uu Users) Tokens(uid string) []Token {
+	list := []Token{}
+
+	if strings.TrimSpace(uid) != "" {
+		for _, u := range uu.users {
+			if u.uid == uid && !u.IsDeleted() {
+				for _, t := range u.tokens {
+					list = append(list, t.clone())
+				}
+			}
+		}
+	}
+
+	return list
+}
+
+func (uu *Users) AddToken(a *auth.Authorizator, uid, name string, scopes []string, expires types.Timestamp, dbc db.DBC) (string, Token, error) {
+	if uu != nil {
+		for k, u := range uu.users {
+			if u.uid == uid && !u.IsDeleted() {
+				token, t, err := u.addToken(a, name, scopes, expires, dbc)
+				if err == nil {
+					uu.users[k] = u
+				}
+
+				return token, t, err
+			}
+		}
+	}
+
+	return "", Token{}, fmt.Errorf("invalid user %v", uid)
+}
+
+func (uu *Users) RevokeToken(a *auth.Authorizator, uid, id string, dbc db.DBC) error {
+	if uu != nil {
+		for k, u := range uu.users {
+			if u.uid == uid && !u.IsDeleted() {
+				err := u.revokeToken(a, id, dbc)
+				if err == nil {
+					uu.users[k] = u
+				}
+
+				return err
+			}
+		}
+	}
+
+	return fmt.Errorf("invalid user %v", uid)
+}
+
+// Returns the WebAuthn user handle and the passkeys registered by the user.
+func (uu Users) Passkeys(uid string) (string, []Passkey) {
+	list := []Passkey{}
+
+	if strings.TrimSpace(uid) != "" {
+		for _, u := range uu.users {
+			if u.uid == uid && !u.IsDeleted() {
+				for _, p := range u.passkeys {
+					list = append(list, p.clone())
+				}
+
+				return u.handle, list
+			}
+		}
+	}
+
+	return "", list
+}
+
+// Returns the UID of the (non-deleted) user with a client certificate identity matching one of
+// the certificate identities. A certificate that matches more than one user is not mapped to
+// any user.
+func (uu Users) CertificateUser(identities []string) (string, bool) {
+	uid := ""
+
+	for _, u := range uu.users {
+		if u.IsDeleted() {
+			continue
+		}
+
+		for _, c := range u.certs {
+			if slices.ContainsFunc(identities, func(v string) bool { return strings.EqualFold(v, c) }) {
+				if uid != "" && uid != u.uid {
+					return "", false
+				}
+
+				uid = u.uid
+			}
+		}
+	}
+
+	return uid, uid != ""
+}
+
+// Returns the UID of the (non-deleted) user with the WebAuthn user handle.
+func (uu Users) PasskeyUser(handle string) (string, bool) {
+	if handle != "" {
+		for _, u := range uu.users {
+			if u.handle == handle && !u.IsDeleted() {
+				return u.uid, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+func (uu *Users) AddPasskey(a *auth.Authorizator, uid, handle, id, name string, credential []byte, dbc db.DBC) (Passkey, error) {
+	if uu != nil && handle != "" {
+		if v, ok := uu.PasskeyUser(handle); ok && v != uid {
+			return Passkey{}, fmt.Errorf("invalid WebAuthn user handle for %v", uid)
+		}
+
+		for k, u := range uu.users {
+			if u.uid == uid && !u.IsDeleted() {
+				p, err := u.addPasskey(a, handle, id, name, credential, dbc)
+				if err == nil {
+					uu.users[k] = u
+				}
+
+				return p, err
+			}
+		}
+	}
+
+	return Passkey{}, fmt.Errorf("invalid user %v", uid)
+}
+
+func (uu *Users) UsePasskey(uid, id string, credential []byte) error {
+	if uu != nil {
+		for k, u := range uu.users {
+			if u.uid == uid && !u.IsDeleted() {
+				err := u.usePasskey(id, credential)
+				if err == nil {
+					uu.users[k] = u
+				}
+
+				return err
+			}
+		}
+	}
+
+	return fmt.Errorf("invalid user %v", uid)
+}
+
+func (uu *Users) RevokePasskey(a *auth.Authorizator, uid, id string, dbc db.DBC) error {
+	if uu != nil {
+		for k, u := range uu.users {
+			if u.uid == uid && !u.IsDeleted() {
+				err := u.revokePasskey(a, id, dbc)
+				if err == nil {
+					uu.users[k] = u
+				}
+
+				return err
+			}
+		}
+	}
+
+	return fmt.Errorf("invalid user %v", uid)
+}
+
+// Returns the UID, role and scopes for a bearer token. Tokens for deleted or locked users
+// and expired tokens are rejected.
+func (uu Users) Authenticate(token string) (string, string, []string, error) {
+	id, secret, err := parseToken(token)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	for _, u := range uu.users {
+		for _, t := range u.tokens {
+			if t.ID != id {
+				continue
+			}
+
+			switch {
+			case !t.verify(secret):
+				return "", "", nil, fmt.Errorf("invalid API token")
+
+			case u.IsDeleted():
+				return "", "", nil, fmt.Errorf("API token %v: invalid user", id)
+
+			case u.Locked():
+				return "", "", nil, fmt.Errorf("API token %v: %v account locked", id, u.uid)
+
+			case t.Expired(time.Now()):
+				return "", "", nil, fmt.Errorf("API token %v expired", id)
+
+			default:
+				return u.uid, u.role, slices.Clone(t.Scopes), nil
+			}
+		}
+	}
+
+	return "", "", nil, fmt.Errorf("invalid API token")
+}
+
+// Returns (or provisions) the user linked to an OIDC identity, returning the UID of the linked
+// user. An identity is only matched by OIDC subject i.e. an existing user must be linked to the
+// identity beforehand (by setting the user 'oidc-subject') and a local user is never taken over by
+// an identity with the same UID.
+func (uu *Users) Provision(subject, uid, name, role string, provision bool, dbc db.DBC) (string, error) {
+	if uu == nil {
+		return "", fmt.Errorf("invalid users list")
+	}
+
+	if strings.TrimSpace(subject) == "" {
+		return "", fmt.Errorf("invalid OIDC subject")
+	}
+
+	var user *User
+
+	for _, u := range uu.users {
+		if u.subject == subject && !u.IsDeleted() {
+			user = u
+			break
+		}
+	}
+
+	if user == nil {
+		for _, u := range uu.users {
+			if u.uid == uid && uid != "" && !u.IsDeleted() {
+				if u.subject != "" {
+					return "", fmt.Errorf("%v is linked to a different OIDC identity", uid)
+				}
+
+				return "", fmt.Errorf("%v is not linked to OIDC identity %v", uid, subject)
+			}
+		}
+	}
+
+	if user == nil && !provision {
+		return "", fmt.Errorf("no user linked to OIDC identity %v (%v)", subject, uid)
+	}
+
+	if user == nil {
+		if strings.TrimSpace(uid) == "" {
+			return "", fmt.Errorf("invalid UID for OIDC identity %v", subject)
+		}
+
+		u := User{
+			name:    strings.TrimSpace(name),
+			uid:     strings.TrimSpace(uid),
+			role:    role,
+			subject: subject,
+			created: types.TimestampNow(),
+		}
+
+		u.OID = catalog.NewT(u.CatalogUser)
+		if _, ok := uu.users[u.OID]; ok {
+			return "", fmt.Errorf("catalog returned duplicate OID (%v)", u.OID)
+		}
+
+		u.modified = types.TimestampNow()
+		uu.users[u.OID] = &u
+
+		catalog.PutV(u.OID, schema.UserName, u.name)
+		catalog.PutV(u.OID, schema.UserUID, u.uid)
+		catalog.PutV(u.OID, schema.UserRole, u.role)
+
+		u.log(dbc, u.uid, "add", "user", "", "", "Provisioned %v (%v) with role %v for OIDC identity %v", u.uid, u.name, u.role, subject)
+
+		return u.uid, nil
+	}
+
+	if user.Locked() {
+		return "", fmt.Errorf("%v account locked", user.uid)
+	}
+
+	if user.role != role {
+		user.log(dbc, user.uid, "update", "role", user.role, role, "Updated role from %v to %v (OIDC)", user.role, role)
+		user.role = role
+		user.modified = types.TimestampNow()
+
+		catalog.PutV(user.OID, schema.UserRole, user.role)
+	}
+
+	return user.uid, nil
+}
+
+// Links a directory (e.g. LDAP) user to the user with the same UID, creating the user if it does
+// not exist, and updates the user role from the directory group mapping. Only users created by a
+// directory login and the configured fallback users are linked - any other local user with the
+// same UID is neither taken over nor re-roled.
+func (uu *Users) Link(uid, name, role string, fallback bool, dbc db.DBC) error {
+	if uu == nil {
+		return fmt.Errorf("invalid users list")
+	}
+
+	if strings.TrimSpace(uid) == "" {
+		return fmt.Errorf("invalid UID")
+	}
+
+	for _, u := range uu.users {
+		if u.uid == uid && !u.IsDeleted() {
+			if u.Locked() {
+				return fmt.Errorf("%v account locked", uid)
+			}
+
+			if !u.directory && !fallback {
+				return fmt.Errorf("%v is a local user and cannot login with the directory", uid)
+			}
+
+			if u.role != role {
+				u.log(dbc, uid, "update", "role", u.role, role, "Updated role from %v to %v (directory)", u.role, role)
+				u.role = role
+				u.modified = types.TimestampNow()
+
+				catalog.PutV(u.OID, schema.UserRole, u.role)
+			}
+
+			return nil
+		}
+	}
+
+	u := User{
+		name:      strings.TrimSpace(name),
+		uid:       strings.TrimSpace(uid),
+		role:      role,
+		directory: true,
+		created:   types.TimestampNow(),
+	}
+
+	u.OID = catalog.NewT(u.CatalogUser)
+	if _, ok := uu.users[u.OID]; ok {
+		return fmt.Errorf("catalog returned duplicate OID (%v)", u.OID)
+	}
+
+	u.modified = types.TimestampNow()
+	uu.users[u.OID] = &u
+
+	catalog.PutV(u.OID, schema.UserName, u.name)
+	catalog.PutV(u.OID, schema.UserUID, u.uid)
+	catalog.PutV(u.OID, schema.UserRole, u.role)
+
+	u.log(dbc, u.uid, "add", "user", "", "", "Added directory user %v (%v) with role %v", u.uid, u.name, u.role)
+
+	return nil
+}
+
 func (uu *Users) UserLogin(a *auth.Authorizator, uid string, err error, dbc db.DBC) {
 	if uu != nil {
 		for k, u := range uu.users {
-			if u.uid == uid {
+			if u.uid == uid && !u.IsDeleted() {
 				u.login(err, dbc)
 				uu.users[k] = u
+				return
 			}
 		}
 	}
+
+	if err != nil {
+		dbc.Log(uid, "login", "", "user", uid, "", "failed", "", "", "Failed login for unknown user %v (%v)", uid, err)
+	}
 }
 
 func (uu Users) User(uid string) (auth.IUser, bool) {
@@ -297,8 +661,61 @@ func (uu Users) User(uid string) (auth.IUser, bool) {
 	return nil, false
 }
 
+// Returns an error if the authorised user is not allowed to view a login session for the user
+// 'uid'. A scoped administrator can only view their own sessions.
+func (uu Users) CanViewSession(a *auth.Authorizator, uid, id string) error {
+	if auth.ScopeOf(a).IsScoped() && auth.UID(a) != uid {
+		return auth.ErrUnauthorised
+	}
+
+	return CanView(a, uu.find(uid), "session", id)
+}
+
+// Returns an error if the authorised user is not allowed to revoke a login session for the user
+// 'uid'. A scoped administrator can only revoke their own sessions.
+func (uu Users) CanRevokeSession(a *auth.Authorizator, uid, id string) error {
+	return CanUpdate(a, uu.find(uid), "session", id)
+}
+
+// Returns an error if the authorised user is not allowed to download a backup archive. A backup
+// includes the users, auth DB and audit trail so is restricted to unscoped administrators, subject
+// to the users grules.
+func (uu Users) CanBackup(a *auth.Authorizator) error {
+	if !auth.IsAdmin(a) || auth.ScopeOf(a).IsScoped() {
+		return auth.ErrUnauthorised
+	}
+
+	return CanView(a, uu.find(auth.UID(a)), "backup", "")
+}
+
+// Returns the user for a UID or, if there is no such user (e.g. a session for a deleted user),
+// a placeholder user with the UID for the authorisation rules.
+func (uu Users) find(uid string) *User {
+	for _, u := range uu.users {
+		if u.uid == uid && !u.IsDeleted() {
+			return u
+		}
+	}
+
+	return &User{uid: uid}
+}
+
+// Returns the scope for a user, or nil if the user is not scoped.
+func (uu Users) Scope(uid string) *auth.Scope {
+	if strings.TrimSpace(uid) != "" {
+		for _, u := range uu.users {
+			if u.uid == uid && !u.IsDeleted() {
+				return u.Scope()
+			}
+		}
+	}
+
+	return nil
+}
+
 func (uu Users) Validate() error {
 	users := map[string]schema.OID{}
+	certificates := map[string]string{}
 
 	for k, u := range uu.users {
 		if u.IsDeleted() {
@@ -324,6 +741,14 @@ func (uu Users) Validate() error {
 		if u.uid != "" {
 			users[u.uid] = u.OID
 		}
+
+		for _, c := range u.certs {
+			if v, ok := certificates[strings.ToLower(c)]; ok && v != u.uid {
+				return fmt.Errorf("duplicate client certificate identity (%v)", c)
+			}
+
+			certificates[strings.ToLower(c)] = u.uid
+		}
 	}
 
 	return nil
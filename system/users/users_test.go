@@ -1,10 +1,18 @@
 package users
 
 import (
+	"errors"
+	"reflect"
 	"testing"
+	"time"
 
+	"github.com/uhppoted/uhppoted-httpd/audit"
+	"github.com/uhppoted/uhppoted-httpd/auth"
+	"github.com/uhppoted/uhppoted-httpd/auth/passwords"
 	"github.com/uhppoted/uhppoted-httpd/system/catalog"
+	"github.com/uhppoted/uhppoted-httpd/system/catalog/impl"
 	"github.com/uhppoted/uhppoted-httpd/system/catalog/schema"
+	"github.com/uhppoted/uhppoted-httpd/system/db"
 	"github.com/uhppoted/uhppoted-httpd/types"
 )
 
@@ -46,3 +54,531 @@ func TestValidateWithNewUser(t *testing.T) {
 		t.Errorf("Unexpected error validating users list with new user (%v)", err)
 	}
 }
+
+func TestUserScopeSerialization(t *testing.T) {
+	u := User{
+		CatalogUser: catalog.CatalogUser{OID: "0.8.1"},
+		uid:         "moony",
+		scope: &auth.Scope{
+			Groups:      []string{"0.5.1", "0.5.10", "0.5.2"},
+			Doors:       []string{"0.3.1"},
+			Controllers: []string{},
+			Ungrouped:   true,
+		},
+	}
+
+	bytes, err := u.serialize()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	var v User
+	if err := v.deserialize(bytes); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := &auth.Scope{
+		Groups:      []string{"0.5.1", "0.5.2", "0.5.10"},
+		Doors:       []string{"0.3.1"},
+		Controllers: []string{},
+		Ungrouped:   true,
+	}
+
+	if !reflect.DeepEqual(v.scope, expected) {
+		t.Errorf("Incorrect deserialized scope\n   expected:%v\n   got:     %v", expected, v.scope)
+	}
+
+	// ... unscoped
+	u.scope = nil
+	if bytes, err = u.serialize(); err != nil {
+		t.Fatalf("%v", err)
+	} else if err := v.deserialize(bytes); err != nil {
+		t.Fatalf("%v", err)
+	} else if v.scope != nil {
+		t.Errorf("Expected nil scope, got %v", v.scope)
+	}
+}
+
+func TestScopedUserUpdate(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+
+	if err := auth.Init(nil, "admin"); err != nil {
+		t.Fatalf("Error initialising auth (%v)", err)
+	}
+
+	uu := Users{
+		users: map[schema.OID]*User{
+			"0.8.1": {CatalogUser: catalog.CatalogUser{OID: "0.8.1"}, uid: "moony", role: "admin", scope: &auth.Scope{}},
+			"0.8.2": {CatalogUser: catalog.CatalogUser{OID: "0.8.2"}, uid: "padfoot", role: "admin"},
+		},
+	}
+
+	for _, u := range uu.users {
+		catalog.PutT(u.CatalogUser)
+	}
+
+	auth.SetScopes(uu.Scope)
+	defer auth.SetScopes(nil)
+
+	a := auth.NewAuthorizator("moony", "admin")
+
+	tests := []struct {
+		oid   schema.OID
+		value string
+		ok    bool
+	}{
+		{"0.8.1.4", "qwerty", true},
+		{"0.8.1.7", "false", false},
+		{"0.8.1.7.1", "0.5.1,0.5.2", false},
+		{"0.8.1.3", "guest", false},
+		{"0.8.2.4", "qwerty", false},
+	}
+
+	for _, test := range tests {
+		if _, err := uu.Update(a, test.oid, test.value, db.DBC{}); test.ok && err != nil {
+			t.Errorf("Unexpected error updating %v (%v)", test.oid, err)
+		} else if !test.ok && !errors.Is(err, auth.ErrUnauthorised) {
+			t.Errorf("Expected 'unauthorised' error updating %v, got %v", test.oid, err)
+		}
+	}
+
+	if _, err := uu.Create(a, schema.UsersOID, "", db.DBC{}); !errors.Is(err, auth.ErrUnauthorised) {
+		t.Errorf("Expected 'unauthorised' error adding user, got %v", err)
+	}
+}
+
+func TestProvision(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+
+	uu := Users{
+		users: map[schema.OID]*User{
+			"0.8.1": {CatalogUser: catalog.CatalogUser{OID: "0.8.1"}, uid: "moony", role: "user", subject: "00001"},
+			"0.8.2": {CatalogUser: catalog.CatalogUser{OID: "0.8.2"}, uid: "padfoot", role: "user", subject: "00002"},
+			"0.8.3": {CatalogUser: catalog.CatalogUser{OID: "0.8.3"}, uid: "wormtail", role: "user", subject: "00004", locked: true},
+			"0.8.4": {CatalogUser: catalog.CatalogUser{OID: "0.8.4"}, uid: "prongs", role: "admin"},
+		},
+	}
+
+	for _, u := range uu.users {
+		catalog.PutT(u.CatalogUser)
+	}
+
+	tests := []struct {
+		subject   string
+		uid       string
+		role      string
+		provision bool
+		expected  string
+		ok        bool
+	}{
+		{"00001", "moony", "admin", false, "moony", true},
+		{"00001", "remus", "admin", false, "moony", true},
+		{"00002", "sirius", "user", false, "padfoot", true},
+		{"00003", "padfoot", "user", true, "", false},
+		{"00004", "wormtail", "user", false, "", false},
+		{"00005", "prongs", "user", false, "", false},
+		{"00005", "prongs", "user", true, "", false},
+		{"00006", "lily", "user", false, "", false},
+		{"00006", "lily", "user", true, "lily", true},
+		{"", "lily", "user", true, "", false},
+	}
+
+	for _, test := range tests {
+		uid, err := uu.Provision(test.subject, test.uid, test.uid, test.role, test.provision, db.DBC{})
+		if test.ok && err != nil {
+			t.Errorf("%v: unexpected error provisioning %v (%v)", test.subject, test.uid, err)
+		} else if !test.ok && err == nil {
+			t.Errorf("%v: expected error provisioning %v", test.subject, test.uid)
+		} else if uid != test.expected {
+			t.Errorf("%v: incorrect UID - expected:%v, got:%v", test.subject, test.expected, uid)
+		}
+	}
+
+	if u := uu.users["0.8.1"]; u.subject != "00001" || u.role != "admin" {
+		t.Errorf("Incorrectly linked user - expected:%v/%v, got:%v/%v", "00001", "admin", u.subject, u.role)
+	}
+
+	if u := uu.users["0.8.4"]; u.subject != "" || u.role != "admin" {
+		t.Errorf("Local user linked to OIDC identity - expected:%v/%v, got:%v/%v", "", "admin", u.subject, u.role)
+	}
+
+	if err := uu.Validate(); err != nil {
+		t.Errorf("Unexpected error validating provisioned users (%v)", err)
+	}
+}
+
+func TestLink(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+
+	uu := Users{
+		users: map[schema.OID]*User{
+			"0.8.1": {CatalogUser: catalog.CatalogUser{OID: "0.8.1"}, uid: "moony", role: "user", directory: true},
+			"0.8.2": {CatalogUser: catalog.CatalogUser{OID: "0.8.2"}, uid: "wormtail", role: "user", directory: true, locked: true},
+			"0.8.3": {CatalogUser: catalog.CatalogUser{OID: "0.8.3"}, uid: "prongs", role: "admin"},
+			"0.8.4": {CatalogUser: catalog.CatalogUser{OID: "0.8.4"}, uid: "lily", role: "admin"},
+		},
+	}
+
+	for _, u := range uu.users {
+		catalog.PutT(u.CatalogUser)
+	}
+
+	tests := []struct {
+		uid      string
+		role     string
+		fallback bool
+		ok       bool
+	}{
+		{"moony", "admin", false, true},
+		{"padfoot", "user", false, true},
+		{"wormtail", "user", false, false},
+		{"prongs", "user", false, false},
+		{"lily", "user", true, true},
+		{"", "user", false, false},
+	}
+
+	for _, test := range tests {
+		if err := uu.Link(test.uid, test.uid, test.role, test.fallback, db.DBC{}); test.ok && err != nil {
+			t.Errorf("%v: unexpected error linking user (%v)", test.uid, err)
+		} else if !test.ok && err == nil {
+			t.Errorf("%v: expected error linking user", test.uid)
+		}
+	}
+
+	if u, ok := uu.User("moony"); !ok || u.Role() != "admin" {
+		t.Errorf("Incorrectly linked user 'moony' - expected role:%v, got:%v", "admin", u)
+	}
+
+	if u, ok := uu.User("padfoot"); !ok || u.Role() != "user" {
+		t.Errorf("Incorrectly created user 'padfoot' - expected role:%v, got:%v", "user", u)
+	} else if !u.(*User).directory {
+		t.Errorf("Directory user 'padfoot' not marked as managed by the directory")
+	}
+
+	if u, ok := uu.User("prongs"); !ok || u.Role() != "admin" {
+		t.Errorf("Local user 'prongs' re-roled by directory login - expected role:%v, got:%v", "admin", u)
+	}
+
+	if err := uu.Validate(); err != nil {
+		t.Errorf("Unexpected error validating linked users (%v)", err)
+	}
+}
+
+func TestCertificateUser(t *testing.T) {
+	uu := Users{
+		users: map[schema.OID]*User{
+			"0.8.1": {CatalogUser: catalog.CatalogUser{OID: "0.8.1"}, uid: "moony", certs: []string{"CN=kiosk-1,OU=Guards,O=Hogwarts"}},
+			"0.8.2": {CatalogUser: catalog.CatalogUser{OID: "0.8.2"}, uid: "padfoot", certs: []string{"dns:kiosk-2.hogwarts.edu", "email:padfoot@hogwarts.edu"}},
+			"0.8.3": {CatalogUser: catalog.CatalogUser{OID: "0.8.3"}, uid: "wormtail", certs: []string{"dns:kiosk-3.hogwarts.edu"}, deleted: types.TimestampNow()},
+			"0.8.4": {CatalogUser: catalog.CatalogUser{OID: "0.8.4"}, uid: "prongs", certs: []string{"dns:kiosk-4.hogwarts.edu"}},
+			"0.8.5": {CatalogUser: catalog.CatalogUser{OID: "0.8.5"}, uid: "lily", certs: []string{"dns:kiosk-4.hogwarts.edu"}},
+		},
+	}
+
+	tests := []struct {
+		identities []string
+		expected   string
+		ok         bool
+	}{
+		{[]string{"CN=kiosk-1,OU=Guards,O=Hogwarts"}, "moony", true},
+		{[]string{"cn=KIOSK-1,ou=guards,o=hogwarts"}, "moony", true},
+		{[]string{"CN=kiosk-2,OU=Guards,O=Hogwarts", "dns:kiosk-2.hogwarts.edu"}, "padfoot", true},
+		{[]string{"CN=kiosk-2,OU=Guards,O=Hogwarts", "email:padfoot@hogwarts.edu", "dns:kiosk-2.hogwarts.edu"}, "padfoot", true},
+		{[]string{"dns:kiosk-3.hogwarts.edu"}, "", false},
+		{[]string{"dns:kiosk-4.hogwarts.edu"}, "", false},
+		{[]string{"CN=kiosk-1,OU=Guards,O=Hogwarts", "dns:kiosk-2.hogwarts.edu"}, "", false},
+		{[]string{"CN=kiosk-5,OU=Guards,O=Hogwarts"}, "", false},
+		{[]string{}, "", false},
+	}
+
+	for _, test := range tests {
+		uid, ok := uu.CertificateUser(test.identities)
+		if ok != test.ok || uid != test.expected {
+			t.Errorf("%v: incorrect certificate user - expected:%v/%v, got:%v/%v", test.identities, test.expected, test.ok, uid, ok)
+		}
+	}
+}
+
+func TestUpdateCertificates(t *testing.T) {
+	catalog.Init(memdb.NewCatalog())
+
+	uu := Users{
+		users: map[schema.OID]*User{
+			"0.8.1": {CatalogUser: catalog.CatalogUser{OID: "0.8.1"}, uid: "moony", certs: []string{"dns:kiosk-1.hogwarts.edu"}},
+			"0.8.2": {CatalogUser: catalog.CatalogUser{OID: "0.8.2"}, uid: "padfoot", certs: []string{"dns:kiosk-2.hogwarts.edu"}},
+		},
+	}
+
+	trail := trail{}
+	dbc := db.NewDBC(&trail)
+
+	if _, err := uu.Update(nil, "0.8.1.9", " CN=kiosk-1,OU=Guards,O=Hogwarts; dns:kiosk-1.hogwarts.edu;DNS:KIOSK-1.hogwarts.edu;", dbc); err != nil {
+		t.Fatalf("Unexpected error updating certificates (%v)", err)
+	}
+
+	dbc.Commit(system{}, func() {})
+
+	expected := []string{"CN=kiosk-1,OU=Guards,O=Hogwarts", "dns:kiosk-1.hogwarts.edu"}
+	if certs := uu.users["0.8.1"].certs; !reflect.DeepEqual(certs, expected) {
+		t.Errorf("Incorrect certificates - expected:%v, got:%v", expected, certs)
+	}
+
+	if len(trail.records) != 1 {
+		t.Fatalf("Incorrect number of audit records - expected:%v, got:%v", 1, len(trail.records))
+	} else if r := trail.records[0]; r.Details.Field != "certificates" || r.Details.After != "CN=kiosk-1,OU=Guards,O=Hogwarts; dns:kiosk-1.hogwarts.edu" {
+		t.Errorf("Incorrect audit record for certificates update - got:%v %v", r.Details.Field, r.Details.After)
+	}
+
+	if _, err := uu.Update(nil, "0.8.2.9", "dns:kiosk-2.hogwarts.edu;dns:kiosk-1.hogwarts.edu", db.DBC{}); err != nil {
+		t.Fatalf("Unexpected error updating certificates (%v)", err)
+	} else if err := uu.Validate(); err == nil {
+		t.Errorf("Expected error validating users with duplicate client certificate identity")
+	}
+}
+
+func TestRehashPassword(t *testing.T) {
+	salt := []byte{0x3a, 0x0b, 0x91, 0x7e, 0x55, 0x1c, 0xd2, 0x44, 0x08, 0xf6, 0x6d, 0x2e, 0xa1, 0x37, 0xc9, 0x80}
+	legacy := "30f55dc91ac4ab3f1390639708f923578c58abf8be9abfa51d117a69b254662c"
+
+	uu := Users{
+		users: map[schema.OID]*User{
+			"0.8.1": {CatalogUser: catalog.CatalogUser{OID: "0.8.1"}, uid: "moony", salt: salt, password: legacy},
+		},
+	}
+
+	if err := uu.RehashPassword("moony", "uiop", "qwerty", db.DBC{}); err == nil {
+		t.Errorf("Expected error rehashing changed password")
+	}
+
+	if err := uu.RehashPassword("moony", legacy, "qwerty", db.DBC{}); err != nil {
+		t.Fatalf("Unexpected error rehashing password (%v)", err)
+	}
+
+	u := uu.users["0.8.1"]
+	if len(u.salt) != 0 {
+		t.Errorf("Expected legacy salt to be cleared, got:%v", u.salt)
+	}
+
+	if ok, rehash := passwords.Verify("qwerty", u.salt, u.password); !ok || rehash {
+		t.Errorf("Incorrect rehashed password - expected:%v/%v, got:%v/%v (%v)", true, false, ok, rehash, u.password)
+	}
+}
+
+func TestUserLoginLockout(t *testing.T) {
+	defer SetLockout(5, 0)
+
+	SetLockout(3, 0)
+
+	uu := Users{
+		users: map[schema.OID]*User{
+			"0.8.1": {CatalogUser: catalog.CatalogUser{OID: "0.8.1"}, uid: "moony", role: "user"},
+		},
+	}
+
+	a := auth.NewAuthorizator("moony", "user")
+	invalid := errors.New("invalid login credentials")
+
+	uu.UserLogin(a, "moony", invalid, db.DBC{})
+	uu.UserLogin(a, "moony", nil, db.DBC{})
+
+	if u := uu.users["0.8.1"]; u.failed != 0 || u.Locked() {
+		t.Errorf("Incorrect failed logins after successful login - expected:%v/%v, got:%v/%v", 0, false, u.failed, u.Locked())
+	}
+
+	for range 3 {
+		uu.UserLogin(a, "moony", invalid, db.DBC{})
+	}
+
+	u := uu.users["0.8.1"]
+	if !u.Locked() {
+		t.Fatalf("Expected account to be locked after %v failed logins", 3)
+	}
+
+	if u.lockedAt.IsZero() {
+		t.Errorf("Expected lockout timestamp")
+	}
+
+	uu.UserLogin(a, "moony", invalid, db.DBC{})
+
+	if u := uu.users["0.8.1"]; u.failed != 3 {
+		t.Errorf("Incorrect failed logins for locked account - expected:%v, got:%v", 3, u.failed)
+	}
+
+	// ... persisted across restarts
+	bytes, err := u.serialize()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	var v User
+	if err := v.deserialize(bytes); err != nil {
+		t.Fatalf("%v", err)
+	} else if !v.Locked() || v.failed != 3 {
+		t.Errorf("Incorrect deserialized lockout - expected:%v/%v, got:%v/%v", true, 3, v.Locked(), v.failed)
+	}
+}
+
+func TestUserLoginLockoutCooldown(t *testing.T) {
+	defer SetLockout(5, 0)
+
+	SetLockout(3, 15*time.Minute)
+
+	uu := Users{
+		users: map[schema.OID]*User{
+			"0.8.1": {CatalogUser: catalog.CatalogUser{OID: "0.8.1"}, uid: "moony", role: "user", locked: true, failed: 3, lockedAt: types.TimestampNow()},
+			"0.8.2": {CatalogUser: catalog.CatalogUser{OID: "0.8.2"}, uid: "padfoot", role: "user", locked: true, failed: 3, lockedAt: types.TimestampNow().Add(-20 * time.Minute)},
+			"0.8.3": {CatalogUser: catalog.CatalogUser{OID: "0.8.3"}, uid: "wormtail", role: "user", locked: true},
+		},
+	}
+
+	if !uu.users["0.8.1"].Locked() {
+		t.Errorf("Expected account locked during cooldown")
+	}
+
+	if uu.users["0.8.2"].Locked() {
+		t.Errorf("Expected account unlocked after cooldown")
+	}
+
+	if !uu.users["0.8.3"].Locked() {
+		t.Errorf("Expected account without lockout timestamp to remain locked")
+	}
+
+	uu.UserLogin(auth.NewAuthorizator("padfoot", "user"), "padfoot", errors.New("invalid login credentials"), db.DBC{})
+
+	if u := uu.users["0.8.2"]; u.locked || u.failed != 1 {
+		t.Errorf("Incorrect lockout after cooldown - expected:%v/%v, got:%v/%v", false, 1, u.locked, u.failed)
+	}
+}
+
+func TestUserLoginUnknownUser(t *testing.T) {
+	uu := Users{
+		users: map[schema.OID]*User{
+			"0.8.1": {CatalogUser: catalog.CatalogUser{OID: "0.8.1"}, uid: "moony", role: "user"},
+		},
+	}
+
+	trail := trail{}
+	dbc := db.NewDBC(&trail)
+
+	uu.UserLogin(auth.NewAuthorizator("snape", ""), "snape", errors.New("invalid login credentials"), dbc)
+	dbc.Commit(system{}, func() {})
+
+	if len(trail.records) != 1 {
+		t.Fatalf("Incorrect number of audit records - expected:%v, got:%v", 1, len(trail.records))
+	} else if r := trail.records[0]; r.UID != "snape" || r.Operation != "login" || r.Details.ID != "snape" {
+		t.Errorf("Incorrect audit record for unknown user - got:%v %v %v", r.UID, r.Operation, r.Details.ID)
+	}
+}
+
+type trail struct {
+	records []audit.AuditRecord
+}
+
+func (t *trail) Write(records ...audit.AuditRecord) {
+	t.records = append(t.records, records...)
+}
+
+type system struct {
+}
+
+func (s system) Update(oid schema.OID, field schema.Suffix, value any) {
+}
+
+func TestSessionsWithScope(t *testing.T) {
+	if err := auth.Init(nil, "admin"); err != nil {
+		t.Fatalf("Error initialising auth (%v)", err)
+	}
+
+	uu := Users{
+		users: map[schema.OID]*User{
+			"0.8.1": {CatalogUser: catalog.CatalogUser{OID: "0.8.1"}, uid: "moony", role: "admin"},
+			"0.8.2": {CatalogUser: catalog.CatalogUser{OID: "0.8.2"}, uid: "padfoot", role: "admin"},
+		},
+	}
+
+	auth.SetScopes(func(uid string) *auth.Scope {
+		if uid == "moony" {
+			return &auth.Scope{Groups: []string{"0.5.1"}}
+		}
+
+		return nil
+	})
+
+	defer auth.SetScopes(nil)
+
+	scoped := auth.NewAuthorizator("moony", "admin")
+	unscoped := auth.NewAuthorizator("padfoot", "admin")
+
+	tests := []struct {
+		authorizator *auth.Authorizator
+		uid          string
+		ok           bool
+	}{
+		{scoped, "moony", true},
+		{scoped, "padfoot", false},
+		{scoped, "snape", false},
+		{unscoped, "moony", true},
+		{unscoped, "padfoot", true},
+		{unscoped, "snape", true},
+	}
+
+	for _, test := range tests {
+		who := auth.UID(test.authorizator)
+
+		if err := uu.CanViewSession(test.authorizator, test.uid, "1234"); test.ok && err != nil {
+			t.Errorf("%v: unexpected error viewing session for %v (%v)", who, test.uid, err)
+		} else if !test.ok && !errors.Is(err, auth.ErrUnauthorised) {
+			t.Errorf("%v: expected 'unauthorised' error viewing session for %v, got %v", who, test.uid, err)
+		}
+
+		if err := uu.CanRevokeSession(test.authorizator, test.uid, "1234"); test.ok && err != nil {
+			t.Errorf("%v: unexpected error revoking session for %v (%v)", who, test.uid, err)
+		} else if !test.ok && !errors.Is(err, auth.ErrUnauthorised) {
+			t.Errorf("%v: expected 'unauthorised' error revoking session for %v, got %v", who, test.uid, err)
+		}
+	}
+}
+
+func TestCanBackup(t *testing.T) {
+	if err := auth.Init(nil, "admin"); err != nil {
+		t.Fatalf("Error initialising auth (%v)", err)
+	}
+
+	uu := Users{
+		users: map[schema.OID]*User{
+			"0.8.1": {CatalogUser: catalog.CatalogUser{OID: "0.8.1"}, uid: "moony", role: "admin"},
+			"0.8.2": {CatalogUser: catalog.CatalogUser{OID: "0.8.2"}, uid: "padfoot", role: "admin"},
+			"0.8.3": {CatalogUser: catalog.CatalogUser{OID: "0.8.3"}, uid: "wormtail", role: "user"},
+		},
+	}
+
+	auth.SetScopes(func(uid string) *auth.Scope {
+		if uid == "moony" {
+			return &auth.Scope{Groups: []string{"0.5.1"}}
+		}
+
+		return nil
+	})
+
+	defer auth.SetScopes(nil)
+
+	tests := []struct {
+		authorizator *auth.Authorizator
+		ok           bool
+	}{
+		{auth.NewAuthorizator("moony", "admin"), false},
+		{auth.NewAuthorizator("padfoot", "admin"), true},
+		{auth.NewAuthorizator("wormtail", "user"), false},
+		{auth.NewSystemAuthorizator("scheduler"), true},
+	}
+
+	for _, test := range tests {
+		who := auth.UID(test.authorizator)
+
+		if err := uu.CanBackup(test.authorizator); test.ok && err != nil {
+			t.Errorf("%v: unexpected error downloading backup (%v)", who, err)
+		} else if !test.ok && !errors.Is(err, auth.ErrUnauthorised) {
+			t.Errorf("%v: expected 'unauthorised' error downloading backup, got %v", who, err)
+		}
+	}
+}
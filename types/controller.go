@@ -14,6 +14,7 @@ type IController interface {
 	EndPoint() types.ControllerAddr
 	TimeZone() *time.Location
 	Protocol() string
+	Interface() schema.OID
 	Door(uint8) (schema.OID, bool)
 
 	DateTimeOk() bool